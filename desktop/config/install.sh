@@ -96,6 +96,7 @@ echo "  - gtk4-layer-shell"
 echo "  - swaybg (wallpaper)"
 echo "  - mako or dunst (notifications)"
 echo "  - wl-clipboard (clipboard)"
+echo "  - cliphist + wofi (clipboard history, Super+V)"
 echo "  - grim + slurp (screenshots)"
 echo "  - brightnessctl (brightness control)"
 echo "  - playerctl (media control)"
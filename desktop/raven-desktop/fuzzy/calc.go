@@ -0,0 +1,257 @@
+package fuzzy
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// calcFunctions are the functions the calculator understands
+var calcFunctions = map[string]func(float64) float64{
+	"sqrt":  math.Sqrt,
+	"abs":   math.Abs,
+	"sin":   math.Sin,
+	"cos":   math.Cos,
+	"tan":   math.Tan,
+	"asin":  math.Asin,
+	"acos":  math.Acos,
+	"atan":  math.Atan,
+	"ln":    math.Log,
+	"log":   math.Log10,
+	"exp":   math.Exp,
+	"floor": math.Floor,
+	"ceil":  math.Ceil,
+	"round": math.Round,
+}
+
+// calcConstants are the named values the calculator understands
+var calcConstants = map[string]float64{
+	"pi": math.Pi,
+	"e":  math.E,
+}
+
+// calcParser is a recursive-descent parser over an arithmetic expression:
+//
+//	expr    = term { ("+" | "-") term }
+//	term    = unary { ("*" | "/" | "%") unary }
+//	unary   = ("+" | "-") unary | power
+//	power   = primary [ "^" unary ]
+//	primary = number | constant | function "(" expr ")" | "(" expr ")"
+type calcParser struct {
+	input string
+	pos   int
+}
+
+// Evaluate computes the value of an arithmetic expression such as
+// "2^10 / (3 + sqrt(16))"
+func Evaluate(expression string) (float64, error) {
+	p := &calcParser{input: expression}
+	value, err := p.expr()
+	if err != nil {
+		return 0, err
+	}
+	p.skipSpace()
+	if p.pos < len(p.input) {
+		return 0, fmt.Errorf("unexpected '%c'", p.input[p.pos])
+	}
+	if math.IsNaN(value) || math.IsInf(value, 0) {
+		return 0, fmt.Errorf("result is not a number")
+	}
+	return value, nil
+}
+
+// FormatNumber prints a calculator result without float noise
+func FormatNumber(value float64) string {
+	return strconv.FormatFloat(value, 'g', 12, 64)
+}
+
+func (p *calcParser) skipSpace() {
+	for p.pos < len(p.input) && p.input[p.pos] == ' ' {
+		p.pos++
+	}
+}
+
+// accept consumes op if it is the next non-space character
+func (p *calcParser) accept(op byte) bool {
+	p.skipSpace()
+	if p.pos < len(p.input) && p.input[p.pos] == op {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *calcParser) expr() (float64, error) {
+	value, err := p.term()
+	if err != nil {
+		return 0, err
+	}
+	for {
+		switch {
+		case p.accept('+'):
+			rhs, err := p.term()
+			if err != nil {
+				return 0, err
+			}
+			value += rhs
+		case p.accept('-'):
+			rhs, err := p.term()
+			if err != nil {
+				return 0, err
+			}
+			value -= rhs
+		default:
+			return value, nil
+		}
+	}
+}
+
+func (p *calcParser) term() (float64, error) {
+	value, err := p.unary()
+	if err != nil {
+		return 0, err
+	}
+	for {
+		switch {
+		case p.accept('*'):
+			rhs, err := p.unary()
+			if err != nil {
+				return 0, err
+			}
+			value *= rhs
+		case p.accept('/'):
+			rhs, err := p.unary()
+			if err != nil {
+				return 0, err
+			}
+			if rhs == 0 {
+				return 0, fmt.Errorf("division by zero")
+			}
+			value /= rhs
+		case p.accept('%'):
+			rhs, err := p.unary()
+			if err != nil {
+				return 0, err
+			}
+			if rhs == 0 {
+				return 0, fmt.Errorf("division by zero")
+			}
+			value = math.Mod(value, rhs)
+		default:
+			return value, nil
+		}
+	}
+}
+
+func (p *calcParser) unary() (float64, error) {
+	if p.accept('-') {
+		value, err := p.unary()
+		return -value, err
+	}
+	if p.accept('+') {
+		return p.unary()
+	}
+	return p.power()
+}
+
+func (p *calcParser) power() (float64, error) {
+	base, err := p.primary()
+	if err != nil {
+		return 0, err
+	}
+	if p.accept('^') {
+		// Right-associative; a leading minus applies to the whole power,
+		// so -2^2 is -4
+		exponent, err := p.unary()
+		if err != nil {
+			return 0, err
+		}
+		return math.Pow(base, exponent), nil
+	}
+	return base, nil
+}
+
+func (p *calcParser) primary() (float64, error) {
+	p.skipSpace()
+	if p.pos >= len(p.input) {
+		return 0, fmt.Errorf("incomplete expression")
+	}
+
+	if p.accept('(') {
+		value, err := p.expr()
+		if err != nil {
+			return 0, err
+		}
+		if !p.accept(')') {
+			return 0, fmt.Errorf("missing ')'")
+		}
+		return value, nil
+	}
+
+	c := rune(p.input[p.pos])
+	switch {
+	case unicode.IsDigit(c) || c == '.':
+		return p.number()
+	case unicode.IsLetter(c):
+		return p.name()
+	}
+	return 0, fmt.Errorf("unexpected '%c'", c)
+}
+
+func (p *calcParser) number() (float64, error) {
+	start := p.pos
+	for p.pos < len(p.input) && (isDigit(p.input[p.pos]) || p.input[p.pos] == '.') {
+		p.pos++
+	}
+	// Scientific notation, e.g. 1.5e3
+	if p.pos < len(p.input) && (p.input[p.pos] == 'e' || p.input[p.pos] == 'E') {
+		next := p.pos + 1
+		if next < len(p.input) && (p.input[next] == '+' || p.input[next] == '-') {
+			next++
+		}
+		if next < len(p.input) && isDigit(p.input[next]) {
+			p.pos = next
+			for p.pos < len(p.input) && isDigit(p.input[p.pos]) {
+				p.pos++
+			}
+		}
+	}
+
+	value, err := strconv.ParseFloat(p.input[start:p.pos], 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid number '%s'", p.input[start:p.pos])
+	}
+	return value, nil
+}
+
+func (p *calcParser) name() (float64, error) {
+	start := p.pos
+	for p.pos < len(p.input) && unicode.IsLetter(rune(p.input[p.pos])) {
+		p.pos++
+	}
+	name := strings.ToLower(p.input[start:p.pos])
+
+	if fn, ok := calcFunctions[name]; ok {
+		if !p.accept('(') {
+			return 0, fmt.Errorf("%s needs '('", name)
+		}
+		arg, err := p.expr()
+		if err != nil {
+			return 0, err
+		}
+		if !p.accept(')') {
+			return 0, fmt.Errorf("missing ')'")
+		}
+		return fn(arg), nil
+	}
+	if value, ok := calcConstants[name]; ok {
+		return value, nil
+	}
+	return 0, fmt.Errorf("unknown name '%s'", name)
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
@@ -2,6 +2,7 @@ package fuzzy
 
 import (
 	"bufio"
+	"fmt"
 	"os"
 	"os/exec"
 	"path/filepath"
@@ -9,6 +10,9 @@ import (
 	"strings"
 	"sync"
 
+	"raven-file-manager/pkg/icons"
+	"raven-file-manager/pkg/search"
+
 	"github.com/diamondburned/gotk4/pkg/gdk/v4"
 	"github.com/diamondburned/gotk4/pkg/gtk/v4"
 )
@@ -20,6 +24,8 @@ const (
 	ResultTypeApp ResultType = iota
 	ResultTypeFile
 	ResultTypeCommand
+	ResultTypeCalc
+	ResultTypeRun
 )
 
 // Result represents a single search result
@@ -49,13 +55,24 @@ type Finder struct {
 	mutex        sync.Mutex
 	selectedIdx  int
 	pinCallback  PinCallback
+	history      *History
+	fileSearch   *search.Engine
+	searchGen    int  // Bumped per query so stale file searches are dropped
+	noCommands   bool // Only applications can be launched (kiosk mode)
 }
 
 // New creates a new fuzzy finder instance
 func New(parent *gtk.Window, pinCallback PinCallback) *Finder {
+	history, err := LoadHistory()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "raven-desktop: launch history: %v\n", err)
+	}
+
 	f := &Finder{
 		parentWindow: parent,
 		pinCallback:  pinCallback,
+		history:      history,
+		fileSearch:   search.NewEngine(),
 	}
 	f.buildIndex()
 	f.createUI()
@@ -253,7 +270,7 @@ func (f *Finder) createUI() {
 	// Search entry
 	f.searchEntry = gtk.NewEntry()
 	f.searchEntry.AddCSSClass("fuzzy-entry")
-	f.searchEntry.SetPlaceholderText("Search apps, files, commands...")
+	f.searchEntry.SetPlaceholderText("Search apps, files, commands...  = calc  > run  / find")
 	mainBox.Append(f.searchEntry)
 
 	// Results list in scrolled window
@@ -313,7 +330,7 @@ func (f *Finder) Show(pinMode bool) {
 	if pinMode {
 		f.searchEntry.SetPlaceholderText("Search app to pin...")
 	} else {
-		f.searchEntry.SetPlaceholderText("Search apps, files, commands...")
+		f.searchEntry.SetPlaceholderText("Search apps, files, commands...  = calc  > run  / find")
 	}
 
 	// Show initial results (all apps)
@@ -329,6 +346,7 @@ func (f *Finder) Show(pinMode bool) {
 // Hide hides the fuzzy finder window
 func (f *Finder) Hide() {
 	f.window.Hide()
+	f.fileSearch.Cancel()
 }
 
 func (f *Finder) search(query string) {
@@ -336,6 +354,14 @@ func (f *Finder) search(query string) {
 	defer f.mutex.Unlock()
 
 	f.results = []Result{}
+	f.searchGen++
+
+	// = calculates, > runs a command, / searches files under $HOME
+	if !f.pinMode && f.runPrefixMode(strings.TrimSpace(query)) {
+		f.updateResultsList()
+		return
+	}
+
 	query = strings.ToLower(strings.TrimSpace(query))
 
 	// Search applications
@@ -343,7 +369,7 @@ func (f *Finder) search(query string) {
 		score := f.fuzzyMatch(query, app.Name)
 		if score > 0 {
 			appCopy := app
-			appCopy.Score = score + 100 // Boost apps
+			appCopy.Score = score + 100 + f.history.Boost(app) // Boost apps
 			f.results = append(f.results, appCopy)
 		}
 	}
@@ -355,7 +381,7 @@ func (f *Finder) search(query string) {
 			score := f.fuzzyMatch(query, cmd.Name)
 			if score > 0 {
 				cmdCopy := cmd
-				cmdCopy.Score = score
+				cmdCopy.Score = score + f.history.Boost(cmd)
 				f.results = append(f.results, cmdCopy)
 			}
 		}
@@ -466,15 +492,16 @@ func (f *Finder) searchFiles(query string) {
 					}
 				}
 
-				f.results = append(f.results, Result{
+				result := Result{
 					Name:        name,
 					Description: dir,
 					Path:        path,
 					Exec:        "xdg-open " + path,
 					Icon:        icon,
 					Type:        ResultTypeFile,
-					Score:       score,
-				})
+				}
+				result.Score = score + f.history.Boost(result)
+				f.results = append(f.results, result)
 			}
 		}
 	}
@@ -512,8 +539,7 @@ func (f *Finder) createResultRow(result Result) *gtk.Box {
 	row.SetMarginBottom(4)
 
 	// Icon
-	image := gtk.NewImageFromIconName(result.Icon)
-	image.SetPixelSize(32)
+	image := icons.NewImage(result.Icon, 32)
 	row.Append(image)
 
 	// Text container
@@ -544,6 +570,10 @@ func (f *Finder) createResultRow(result Result) *gtk.Box {
 		typeText = "FILE"
 	case ResultTypeCommand:
 		typeText = "CMD"
+	case ResultTypeCalc:
+		typeText = "CALC"
+	case ResultTypeRun:
+		typeText = "RUN"
 	}
 	typeLabel := gtk.NewLabel(typeText)
 	typeLabel.AddCSSClass("result-type")
@@ -587,6 +617,10 @@ func (f *Finder) activateSelected() {
 }
 
 func (f *Finder) activateResult(result Result) {
+	// Hints and invalid calculator input have nothing to run
+	if result.Exec == "" {
+		return
+	}
 	f.Hide()
 
 	if f.pinMode && result.Type == ResultTypeApp {
@@ -595,7 +629,13 @@ func (f *Finder) activateResult(result Result) {
 			f.pinCallback(result.Name, result.Exec, result.Icon)
 		}
 	} else {
-		// Launch the app/command/file
+		// Launch the app/command/file. Runner results aren't ranked, so
+		// they stay out of the history.
+		if result.Type != ResultTypeCalc && result.Type != ResultTypeRun {
+			if err := f.history.Record(result); err != nil {
+				fmt.Fprintf(os.Stderr, "raven-desktop: failed to save launch history: %v\n", err)
+			}
+		}
 		go func() {
 			exec.Command("sh", "-c", result.Exec).Start()
 		}()
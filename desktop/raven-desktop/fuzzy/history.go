@@ -0,0 +1,201 @@
+package fuzzy
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// Upper bound on history entries kept on disk
+	maxHistoryEntries = 500
+
+	// Largest boost frecency can add to a match score, so a frequently used
+	// weak match cannot bury an exact match
+	maxFrecencyBoost = 60
+)
+
+// HistoryEntry records how often and how recently a result was launched
+type HistoryEntry struct {
+	Count    int   `json:"count"`
+	LastUsed int64 `json:"last_used"` // Unix seconds
+}
+
+// History is the launch history used for frecency ranking
+type History struct {
+	Entries map[string]*HistoryEntry `json:"entries"`
+
+	path string
+	mu   sync.Mutex
+}
+
+// historyPath returns ~/.local/share/raven/launch-history.json
+func historyPath() string {
+	dataHome := os.Getenv("XDG_DATA_HOME")
+	if dataHome == "" {
+		dataHome = filepath.Join(os.Getenv("HOME"), ".local", "share")
+	}
+	return filepath.Join(dataHome, "raven", "launch-history.json")
+}
+
+// LoadHistory reads the launch history. A missing or unreadable file gives
+// an empty history so the finder still works; the error is reported.
+func LoadHistory() (*History, error) {
+	h := &History{
+		Entries: make(map[string]*HistoryEntry),
+		path:    historyPath(),
+	}
+
+	data, err := os.ReadFile(h.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return h, nil
+		}
+		return h, err
+	}
+	if err := json.Unmarshal(data, h); err != nil {
+		h.Entries = make(map[string]*HistoryEntry)
+		return h, fmt.Errorf("parse %s: %w", h.path, err)
+	}
+	if h.Entries == nil {
+		h.Entries = make(map[string]*HistoryEntry)
+	}
+	return h, nil
+}
+
+// historyKey identifies a result across sessions
+func historyKey(r Result) string {
+	switch r.Type {
+	case ResultTypeApp:
+		return "app:" + r.Path
+	case ResultTypeFile:
+		return "file:" + r.Path
+	default:
+		return "cmd:" + r.Exec
+	}
+}
+
+// Record notes a launch of r and saves the history
+func (h *History) Record(r Result) error {
+	h.mu.Lock()
+	h.reload()
+	key := historyKey(r)
+	entry, ok := h.Entries[key]
+	if !ok {
+		entry = &HistoryEntry{}
+		h.Entries[key] = entry
+	}
+	entry.Count++
+	entry.LastUsed = time.Now().Unix()
+	h.prune()
+	h.mu.Unlock()
+
+	return h.save()
+}
+
+// reload picks up launches other components (raven-menu) saved since the
+// history was loaded, so saving doesn't drop them. Callers hold h.mu.
+func (h *History) reload() {
+	data, err := os.ReadFile(h.path)
+	if err != nil {
+		return
+	}
+	var onDisk History
+	if json.Unmarshal(data, &onDisk) == nil && onDisk.Entries != nil {
+		h.Entries = onDisk.Entries
+	}
+}
+
+// Boost returns the frecency bonus to blend into r's match score
+func (h *History) Boost(r Result) int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	entry, ok := h.Entries[historyKey(r)]
+	if !ok {
+		return 0
+	}
+	boost := frecency(entry, time.Now()) / 10
+	return int(math.Min(boost, maxFrecencyBoost))
+}
+
+// frecency weights the launch count by how recently the entry was used
+func frecency(entry *HistoryEntry, now time.Time) float64 {
+	age := now.Sub(time.Unix(entry.LastUsed, 0))
+
+	var weight float64
+	switch {
+	case age < 4*time.Hour:
+		weight = 100
+	case age < 24*time.Hour:
+		weight = 80
+	case age < 7*24*time.Hour:
+		weight = 60
+	case age < 30*24*time.Hour:
+		weight = 40
+	case age < 90*24*time.Hour:
+		weight = 20
+	default:
+		weight = 10
+	}
+	return float64(entry.Count) * weight
+}
+
+// prune drops the least valuable entries once the history grows too large
+func (h *History) prune() {
+	if len(h.Entries) <= maxHistoryEntries {
+		return
+	}
+
+	now := time.Now()
+	keys := make([]string, 0, len(h.Entries))
+	for key := range h.Entries {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return frecency(h.Entries[keys[i]], now) > frecency(h.Entries[keys[j]], now)
+	})
+	for _, key := range keys[maxHistoryEntries:] {
+		delete(h.Entries, key)
+	}
+}
+
+// save writes the history to a temp file and renames it into place, so a
+// crash mid-write never leaves a truncated history behind
+func (h *History) save() error {
+	h.mu.Lock()
+	data, err := json.MarshalIndent(h, "", "  ")
+	h.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	dir := filepath.Dir(h.path)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(dir, ".launch-history-*.json")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Rename(tmp.Name(), h.path); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,138 @@
+package fuzzy
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"raven-file-manager/pkg/fileview"
+
+	"github.com/diamondburned/gotk4/pkg/glib/v2"
+)
+
+// Prefixes that switch the finder into a runner mode
+const (
+	prefixCalc  = "="
+	prefixRun   = ">"
+	prefixFiles = "/"
+)
+
+// Upper bound on results from a / file search
+const maxFileSearchResults = 50
+
+// runPrefixMode handles queries starting with a mode prefix. It reports
+// whether the query was one; f.mutex must be held.
+func (f *Finder) runPrefixMode(query string) bool {
+	switch {
+	case strings.HasPrefix(query, prefixCalc):
+		f.results = []Result{calcResult(strings.TrimSpace(query[len(prefixCalc):]))}
+	case strings.HasPrefix(query, prefixRun):
+		command := strings.TrimSpace(query[len(prefixRun):])
+		if f.noCommands {
+			f.results = []Result{{
+				Name:        "Running commands is disabled",
+				Description: "Kiosk mode",
+				Icon:        "action-unavailable",
+				Type:        ResultTypeRun,
+			}}
+		} else if command != "" {
+			f.results = []Result{{
+				Name:        command,
+				Description: "Run in Raven Terminal",
+				Exec:        "raven-terminal -e " + shellQuote(command),
+				Icon:        "utilities-terminal",
+				Type:        ResultTypeRun,
+			}}
+		}
+	case strings.HasPrefix(query, prefixFiles):
+		pattern := strings.TrimSpace(query[len(prefixFiles):])
+		if pattern != "" {
+			go f.searchHome(pattern, f.searchGen)
+		}
+	default:
+		return false
+	}
+	return true
+}
+
+// DisableCommands limits the finder to launching applications: commands
+// from PATH are no longer offered and the > runner refuses to run
+// anything. Kiosk mode uses it.
+func (f *Finder) DisableCommands() {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.noCommands = true
+	f.allCommands = nil
+}
+
+// calcResult evaluates expression for the = mode. Activating the result
+// copies the value to the clipboard.
+func calcResult(expression string) Result {
+	if expression == "" {
+		return Result{
+			Name:        "Calculator",
+			Description: "Type an expression, e.g. = 2^10 / (3 + sqrt(16))",
+			Icon:        "accessories-calculator",
+			Type:        ResultTypeCalc,
+		}
+	}
+
+	value, err := Evaluate(expression)
+	if err != nil {
+		return Result{
+			Name:        "Invalid expression",
+			Description: err.Error(),
+			Icon:        "accessories-calculator",
+			Type:        ResultTypeCalc,
+		}
+	}
+
+	text := FormatNumber(value)
+	return Result{
+		Name:        text,
+		Description: expression + " · Enter to copy",
+		Exec:        "wl-copy -- " + shellQuote(text),
+		Icon:        "accessories-calculator",
+		Type:        ResultTypeCalc,
+	}
+}
+
+// searchHome runs the file manager's search engine over $HOME and shows
+// the matches, unless the query changed while it ran
+func (f *Finder) searchHome(pattern string, gen int) {
+	home := os.Getenv("HOME")
+	matches := f.fileSearch.Search(context.Background(), home, pattern, maxFileSearchResults)
+
+	results := make([]Result, 0, len(matches))
+	for _, match := range matches {
+		dir := filepath.Dir(match.Entry.Path)
+		if rel, err := filepath.Rel(home, dir); err == nil && !strings.HasPrefix(rel, "..") {
+			dir = filepath.Join("~", rel)
+		}
+		results = append(results, Result{
+			Name:        match.Entry.Name,
+			Description: dir,
+			Path:        match.Entry.Path,
+			Exec:        "xdg-open " + shellQuote(match.Entry.Path),
+			Icon:        fileview.GetFileIcon(match.Entry),
+			Type:        ResultTypeFile,
+			Score:       match.Score,
+		})
+	}
+
+	glib.IdleAdd(func() {
+		f.mutex.Lock()
+		defer f.mutex.Unlock()
+		if gen != f.searchGen {
+			return
+		}
+		f.results = results
+		f.updateResultsList()
+	})
+}
+
+// shellQuote quotes s for use as a single sh word
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
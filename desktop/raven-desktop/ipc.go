@@ -0,0 +1,175 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/diamondburned/gotk4/pkg/glib/v2"
+)
+
+const (
+	desktopSocketName = "raven-desktop.sock"
+
+	// How long a command may wait for the main loop. raven-ctl's deadline
+	// is longer, so a slow desktop still gets its reply through.
+	ipcTimeout = 5 * time.Second
+)
+
+// wallpaperModes are the modes applyWallpaper understands
+var wallpaperModes = []string{"fill", "fit", "stretch", "center", "tile"}
+
+// DesktopCommand is a request sent to the desktop control socket
+type DesktopCommand struct {
+	Action string `json:"action"`
+	Path   string `json:"path,omitempty"`
+	Mode   string `json:"mode,omitempty"`
+}
+
+// DesktopResponse is the reply to a DesktopCommand
+type DesktopResponse struct {
+	Success bool   `json:"success"`
+	Error   string `json:"error,omitempty"`
+	Data    any    `json:"data,omitempty"`
+}
+
+// desktopMethods lists the supported actions, returned by "help"
+var desktopMethods = []string{
+	"show-launcher",
+	"show-pin-dialog",
+	"set-wallpaper",
+	"refresh",
+	"help",
+}
+
+// getDesktopSocketPath returns the control socket path in the runtime dir
+func getDesktopSocketPath() string {
+	runtimeDir := os.Getenv("XDG_RUNTIME_DIR")
+	if runtimeDir == "" {
+		runtimeDir = fmt.Sprintf("/run/user/%d", os.Getuid())
+	}
+	return filepath.Join(runtimeDir, desktopSocketName)
+}
+
+// startIPCServer listens on the control socket so other components and
+// keybinds (via raven-ctl desktop) can drive the desktop
+func (d *RavenDesktop) startIPCServer() error {
+	socketPath := getDesktopSocketPath()
+
+	// Refuse to steal the socket from another running desktop, but clean up
+	// one left behind by a crash
+	if conn, err := net.DialTimeout("unix", socketPath, time.Second); err == nil {
+		conn.Close()
+		return fmt.Errorf("another raven-desktop is listening on %s", socketPath)
+	}
+	os.Remove(socketPath)
+
+	listener, err := net.Listen("unix", socketPath)
+	if err != nil {
+		return err
+	}
+	os.Chmod(socketPath, 0600)
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go d.handleIPCConn(conn)
+		}
+	}()
+
+	d.app.ConnectShutdown(func() {
+		listener.Close()
+		os.Remove(socketPath)
+	})
+
+	return nil
+}
+
+// handleIPCConn serves a single request on conn
+func (d *RavenDesktop) handleIPCConn(conn net.Conn) {
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(ipcTimeout + time.Second))
+
+	var cmd DesktopCommand
+	if err := json.NewDecoder(conn).Decode(&cmd); err != nil {
+		json.NewEncoder(conn).Encode(DesktopResponse{Error: "invalid request: " + err.Error()})
+		return
+	}
+
+	// Commands touch widgets, so run them on the main loop. A command still
+	// queued when the wait expires is dropped rather than run late, so a
+	// "timed out" reply means it really didn't happen.
+	result := make(chan DesktopResponse, 1)
+	var mu sync.Mutex
+	abandoned := false
+	glib.IdleAdd(func() {
+		mu.Lock()
+		defer mu.Unlock()
+		if !abandoned {
+			result <- d.dispatchCommand(cmd)
+		}
+	})
+
+	select {
+	case resp := <-result:
+		json.NewEncoder(conn).Encode(resp)
+	case <-time.After(ipcTimeout):
+		// Waits for a command that is already running to finish
+		mu.Lock()
+		abandoned = true
+		mu.Unlock()
+		select {
+		case resp := <-result:
+			json.NewEncoder(conn).Encode(resp)
+		default:
+			json.NewEncoder(conn).Encode(DesktopResponse{Error: "timed out waiting for the desktop"})
+		}
+	}
+}
+
+// dispatchCommand runs a control command on the main loop
+func (d *RavenDesktop) dispatchCommand(cmd DesktopCommand) DesktopResponse {
+	switch cmd.Action {
+	case "show-launcher":
+		d.showFuzzyFinder()
+
+	case "show-pin-dialog":
+		d.showFuzzyFinderForPinning()
+
+	case "set-wallpaper":
+		if cmd.Path == "" {
+			return DesktopResponse{Error: "set-wallpaper requires a path"}
+		}
+		if _, err := os.Stat(cmd.Path); err != nil {
+			return DesktopResponse{Error: err.Error()}
+		}
+		if cmd.Mode != "" {
+			if !slices.Contains(wallpaperModes, cmd.Mode) {
+				return DesktopResponse{Error: fmt.Sprintf("invalid wallpaper mode %q (use %s)", cmd.Mode, strings.Join(wallpaperModes, ", "))}
+			}
+			d.settings.WallpaperMode = cmd.Mode
+		}
+		d.setWallpaper(cmd.Path)
+
+	case "refresh":
+		d.loadIcons()
+		d.refreshIconGrid()
+
+	case "help":
+		return DesktopResponse{Success: true, Data: desktopMethods}
+
+	default:
+		return DesktopResponse{Error: "unknown action: " + cmd.Action}
+	}
+
+	return DesktopResponse{Success: true}
+}
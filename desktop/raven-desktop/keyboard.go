@@ -0,0 +1,301 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/diamondburned/gotk4/pkg/gdk/v4"
+	"github.com/diamondburned/gotk4/pkg/gtk/v4"
+)
+
+// Typed letters within this long of each other extend the type-ahead
+// search instead of starting a new one
+const typeAheadTimeout = time.Second
+
+// setupKeyboard lets the icons be used without a mouse: arrows move the
+// selection, Enter opens, F2 renames, Delete unpins or trashes, and typing
+// selects the icon whose name starts with the letters typed
+func (d *RavenDesktop) setupKeyboard() {
+	d.cursor = -1
+
+	key := gtk.NewEventControllerKey()
+	// Capture, so the icon grid's own arrow keys don't move its focus first
+	key.SetPropagationPhase(gtk.PhaseCapture)
+	key.ConnectKeyPressed(func(keyval, keycode uint, state gdk.ModifierType) bool {
+		// Keys typed into popovers (rename, menus) are theirs
+		if event := key.CurrentEvent(); event == nil ||
+			gdk.BaseSurface(gdk.BaseEvent(event).Surface()).Native() != gdk.BaseSurface(d.window.Surface()).Native() {
+			return false
+		}
+		if !d.settings.ShowDesktopIcons || len(d.icons) == 0 {
+			return false
+		}
+		return d.handleKey(keyval, state)
+	})
+	d.window.AddController(key)
+}
+
+func (d *RavenDesktop) handleKey(keyval uint, state gdk.ModifierType) bool {
+	extend := state&gdk.ShiftMask != 0
+	ctrl := state&gdk.ControlMask != 0
+
+	switch keyval {
+	case gdk.KEY_Left, gdk.KEY_KP_Left:
+		d.moveCursor(-1, 0, extend)
+	case gdk.KEY_Right, gdk.KEY_KP_Right:
+		d.moveCursor(1, 0, extend)
+	case gdk.KEY_Up, gdk.KEY_KP_Up:
+		d.moveCursor(0, -1, extend)
+	case gdk.KEY_Down, gdk.KEY_KP_Down:
+		d.moveCursor(0, 1, extend)
+	case gdk.KEY_Home:
+		d.setCursor(0, extend)
+	case gdk.KEY_End:
+		d.setCursor(len(d.icons)-1, extend)
+	case gdk.KEY_Return, gdk.KEY_KP_Enter:
+		for _, i := range d.selectedIndices() {
+			d.launchApp(d.icons[i].Exec)
+		}
+	case gdk.KEY_F2:
+		if indices := d.selectedIndices(); len(indices) == 1 {
+			d.showRenamePopover(indices[0])
+		}
+	case gdk.KEY_Delete, gdk.KEY_KP_Delete:
+		d.removeSelected()
+	case gdk.KEY_Escape:
+		d.clearSelection()
+	case gdk.KEY_a, gdk.KEY_A:
+		if !ctrl {
+			return d.typeAhead(keyval)
+		}
+		for i := range d.icons {
+			d.setSelected(i, true)
+		}
+	default:
+		if ctrl || state&gdk.AltMask != 0 {
+			return false
+		}
+		return d.typeAhead(keyval)
+	}
+	return true
+}
+
+// setCursor moves the keyboard cursor to index and selects it, alone or,
+// when extending, in addition to the current selection
+func (d *RavenDesktop) setCursor(index int, extend bool) {
+	if index < 0 || index >= len(d.icons) {
+		return
+	}
+	if !extend {
+		d.clearSelection()
+	}
+	d.setSelected(index, true)
+	d.cursor = index
+}
+
+// moveCursor moves the cursor to the nearest icon in the direction dx, dy.
+// Icons are compared by where they are on screen, so this works for the
+// auto-arranged grid and for freely placed icons alike.
+func (d *RavenDesktop) moveCursor(dx, dy int, extend bool) {
+	if d.cursor < 0 || d.cursor >= len(d.iconWidgets) {
+		d.setCursor(0, extend)
+		return
+	}
+
+	fromX, fromY, ok := d.iconCenter(d.cursor)
+	if !ok {
+		return
+	}
+	best := -1
+	bestScore := math.Inf(1)
+	for i := range d.iconWidgets {
+		if i == d.cursor {
+			continue
+		}
+		x, y, ok := d.iconCenter(i)
+		if !ok {
+			continue
+		}
+		// Distance along the direction, and off to the side of it
+		along := (x-fromX)*float64(dx) + (y-fromY)*float64(dy)
+		across := math.Abs((x-fromX)*float64(dy)) + math.Abs((y-fromY)*float64(dx))
+		if along <= 1 {
+			continue
+		}
+		// Prefer icons in the same row or column over nearer diagonal ones
+		if score := along + 2*across; score < bestScore {
+			best, bestScore = i, score
+		}
+	}
+	if best >= 0 {
+		d.setCursor(best, extend)
+	}
+}
+
+// iconCenter returns the center of an icon in overlay coordinates
+func (d *RavenDesktop) iconCenter(index int) (float64, float64, bool) {
+	widget := d.iconWidgets[index]
+	if !widget.IsVisible() {
+		return 0, 0, false
+	}
+	x, y, ok := widget.TranslateCoordinates(d.overlay, 0, 0)
+	if !ok {
+		return 0, 0, false
+	}
+	return x + float64(widget.Width())/2, y + float64(widget.Height())/2, true
+}
+
+// typeAhead selects the next icon whose name starts with the letters typed
+// so far. Typing the same letter again cycles through the icons starting
+// with it.
+func (d *RavenDesktop) typeAhead(keyval uint) bool {
+	r := rune(gdk.KeyvalToUnicode(keyval))
+	if r == 0 || !unicode.IsPrint(r) {
+		return false
+	}
+
+	if time.Since(d.typeAheadAt) > typeAheadTimeout {
+		d.typeAheadText = ""
+	}
+	d.typeAheadAt = time.Now()
+	d.typeAheadText += strings.ToLower(string(r))
+
+	prefix := d.typeAheadText
+	start := d.cursor
+	if strings.Count(prefix, string(r)) == len([]rune(prefix)) {
+		// One letter, maybe repeated: move past the current icon
+		prefix = string(r)
+		start++
+	}
+	if start < 0 {
+		start = 0
+	}
+
+	for n := 0; n < len(d.icons); n++ {
+		i := (start + n) % len(d.icons)
+		if strings.HasPrefix(strings.ToLower(d.icons[i].Name), prefix) {
+			d.setCursor(i, false)
+			break
+		}
+	}
+	return true
+}
+
+// removeSelected takes the selected icons off the desktop: pinned apps are
+// unpinned and ~/Desktop files moved to the trash. The Trash icon stays.
+func (d *RavenDesktop) removeSelected() {
+	var paths []string
+	pinned := false
+	for _, i := range d.selectedIndices() {
+		if d.icons[i].Path != "" {
+			paths = append(paths, d.icons[i].Path)
+		} else if !d.icons[i].Trash {
+			pinned = true
+		}
+	}
+	if pinned {
+		d.unpinSelected()
+	}
+	if len(paths) > 0 {
+		d.clearSelection()
+		d.moveToTrash(paths)
+	}
+}
+
+// showRenamePopover asks for a new name for an icon, below it
+func (d *RavenDesktop) showRenamePopover(index int) {
+	icon := d.icons[index]
+	if icon.Trash {
+		return
+	}
+
+	entry := gtk.NewEntry()
+	entry.SetText(icon.Name)
+	entry.SetWidthChars(20)
+
+	popover := gtk.NewPopover()
+	popover.SetChild(entry)
+	popover.SetParent(d.iconWidgets[index])
+	popover.SetPosition(gtk.PosBottom)
+	popover.ConnectClosed(func() {
+		popover.Unparent()
+	})
+	entry.ConnectActivate(func() {
+		name := strings.TrimSpace(entry.Text())
+		popover.Popdown()
+		if name == "" || name == icon.Name {
+			return
+		}
+		if err := d.renameIcon(index, name); err != nil {
+			fmt.Fprintf(os.Stderr, "raven-desktop: failed to rename %s: %v\n", icon.Name, err)
+		}
+	})
+
+	popover.Popup()
+	entry.GrabFocus()
+	entry.SelectRegion(0, -1)
+}
+
+// renameIcon changes the name shown under an icon. A pinned app is renamed
+// in pinned-apps.json; a ~/Desktop file gets a new Name= line, so the file
+// itself keeps its name.
+func (d *RavenDesktop) renameIcon(index int, name string) error {
+	for i, other := range d.icons {
+		if i != index && other.Name == name {
+			return fmt.Errorf("an icon named %q already exists", name)
+		}
+	}
+
+	icon := &d.icons[index]
+	if icon.Path != "" {
+		if err := setDesktopFileName(icon.Path, name); err != nil {
+			return err
+		}
+		icon.Name = name
+		d.refreshIconGrid()
+	} else {
+		icon.Name = name
+		d.savePinnedApps()
+		d.refreshIconGrid()
+	}
+	d.setCursor(index, false)
+	return nil
+}
+
+// setDesktopFileName rewrites the Name= line of a .desktop file's
+// [Desktop Entry] group. Translated names (Name[fr]=) are left alone.
+func setDesktopFileName(path, name string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	lines := strings.Split(string(data), "\n")
+	inEntry := false
+	replaced := false
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "[") {
+			inEntry = trimmed == "[Desktop Entry]"
+			continue
+		}
+		if inEntry && strings.HasPrefix(trimmed, "Name=") {
+			lines[i] = "Name=" + name
+			replaced = true
+			break
+		}
+	}
+	if !replaced {
+		return fmt.Errorf("%s has no Name= entry", path)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, []byte(strings.Join(lines, "\n")), info.Mode().Perm())
+}
@@ -2,15 +2,19 @@ package main
 
 import (
 	"encoding/json"
+	"fmt"
+	"math"
 	"os"
 	"os/exec"
 	"os/signal"
 	"path/filepath"
 	"strings"
 	"syscall"
+	"time"
 	"unsafe"
 
 	"raven-desktop/fuzzy"
+	"raven-file-manager/pkg/icons"
 
 	"github.com/diamondburned/gotk4/pkg/gdk/v4"
 	"github.com/diamondburned/gotk4/pkg/gio/v2"
@@ -31,10 +35,20 @@ void init_desktop_layer_shell(GtkWidget *window) {
     gtk_layer_set_anchor(GTK_WINDOW(window), GTK_LAYER_SHELL_EDGE_LEFT, TRUE);
     gtk_layer_set_anchor(GTK_WINDOW(window), GTK_LAYER_SHELL_EDGE_RIGHT, TRUE);
     gtk_layer_set_exclusive_zone(GTK_WINDOW(window), -1);
+    // Take the keyboard when clicked, for icon keyboard navigation
+    gtk_layer_set_keyboard_mode(GTK_WINDOW(window), GTK_LAYER_SHELL_KEYBOARD_MODE_ON_DEMAND);
 }
 */
 import "C"
 
+// Grid used to snap freely placed icons
+const (
+	gridCellWidth  = 100
+	gridCellHeight = 110
+	gridOriginX    = 20
+	gridOriginY    = 50 // Leave space for panel
+)
+
 // DesktopIcon represents an icon on the desktop
 type DesktopIcon struct {
 	Name string `json:"name"`
@@ -42,11 +56,23 @@ type DesktopIcon struct {
 	Icon string `json:"icon"`
 	X    int    `json:"x"`
 	Y    int    `json:"y"`
+	Path string `json:"-"` // Backing .desktop file in ~/Desktop, empty for pinned apps
+
+	Trash bool `json:"-"` // The special Trash icon
+}
+
+// IconPosition is a saved free-placement position
+type IconPosition struct {
+	X int `json:"x"`
+	Y int `json:"y"`
 }
 
 // PinnedAppsConfig holds the list of pinned desktop apps
 type PinnedAppsConfig struct {
-	PinnedApps []DesktopIcon `json:"pinned_apps"`
+	PinnedApps    []DesktopIcon           `json:"pinned_apps"`
+	FreePlacement bool                    `json:"free_placement,omitempty"` // Icons keep their X/Y instead of auto-arranging
+	FilePositions map[string]IconPosition `json:"file_positions,omitempty"` // Positions of ~/Desktop icons, by file path
+	TrashPosition *IconPosition           `json:"trash_position,omitempty"`
 }
 
 // RavenSettings holds shared settings
@@ -54,6 +80,11 @@ type RavenSettings struct {
 	WallpaperPath    string `json:"wallpaper_path"`
 	WallpaperMode    string `json:"wallpaper_mode"`
 	ShowDesktopIcons bool   `json:"show_desktop_icons"`
+
+	PauseWallpaperOnBattery bool `json:"pause_wallpaper_on_battery"`
+
+	// Shared/public machine: no terminal, settings or commands
+	KioskMode bool `json:"kiosk_mode"`
 }
 
 // RavenDesktop is the desktop background with icons
@@ -61,15 +92,36 @@ type RavenDesktop struct {
 	app            *gtk.Application
 	window         *gtk.Window
 	iconGrid       *gtk.FlowBox
+	iconFixed      *gtk.Fixed
+	iconLayer      *gtk.Box
 	overlay        *gtk.Overlay
 	bgBox          *gtk.Box
 	bgPicture      *gtk.Picture
+	wallpaperCSS   *gtk.CSSProvider // Center/tile wallpaper background
+	bgMedia        *gtk.MediaFile   // Video wallpaper
+	bgShader       *gtk.GLArea      // Shader wallpaper
 	icons          []DesktopIcon
 	popover        *gtk.PopoverMenu
+	hideIcons      *gio.SimpleAction // Context menu "Hide Desktop Icons" toggle
 	settings       RavenSettings
 	settingsPath   string
 	pinnedAppsPath string
 	fuzzyFinder    *fuzzy.Finder
+	freePlacement  bool
+	filePositions  map[string]IconPosition // Saved positions of ~/Desktop icons
+	trashPosition  IconPosition            // Saved position of the Trash icon
+	trashImage     *gtk.Image              // Trash icon image, swapped when it fills or empties
+	iconWidgets    []*gtk.Box              // Icon widgets, indexed like icons
+	selected       map[int]bool            // Selected icon indices
+	bandLayer      *gtk.Fixed              // Holds the rubber band rectangle
+	band           *gtk.Box
+	cursor         int // Icon the arrow keys move from, -1 for none
+	typeAheadText  string
+	typeAheadAt    time.Time
+
+	appliedWallpaper string // Wallpaper and mode currently shown
+	appliedMode      string
+	wallpaperPaused  bool // Animated wallpaper paused (e.g. on battery)
 }
 
 var previewMode bool
@@ -128,14 +180,25 @@ func (d *RavenDesktop) activate() {
 
 	// Set up right-click menu
 	d.setupContextMenu()
+	d.setupKeyboard()
 
-	// Set up signal handler for fuzzy finder shortcut
+	// Control socket for raven-ctl and other components
+	if err := d.startIPCServer(); err != nil {
+		fmt.Fprintf(os.Stderr, "raven-desktop: control socket unavailable: %v\n", err)
+	}
 	d.setupSignalHandler()
 
+	// Pick up wallpaper changes from raven-settings-menu
+	d.watchSettings()
+	d.watchPowerSource()
+	d.watchTrash()
+
 	d.window.SetApplication(d.app)
 	d.window.Present()
 }
 
+// setupSignalHandler keeps the old SIGUSR1/SIGUSR2 triggers working for
+// existing keybinds. New code should use the control socket (see ipc.go).
 func (d *RavenDesktop) setupSignalHandler() {
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, syscall.SIGUSR1, syscall.SIGUSR2)
@@ -171,6 +234,8 @@ func (d *RavenDesktop) loadSettings() {
 		WallpaperPath:    "",
 		WallpaperMode:    "fill",
 		ShowDesktopIcons: true,
+
+		PauseWallpaperOnBattery: true,
 	}
 
 	data, err := os.ReadFile(d.settingsPath)
@@ -209,9 +274,19 @@ func (d *RavenDesktop) applyCSS() {
 		.desktop-icon:active {
 			background-color: rgba(0, 150, 136, 0.3);
 		}
-		.desktop-icon:selected {
+		.desktop-icon:selected,
+		.desktop-icon-selected {
 			background-color: rgba(0, 150, 136, 0.4);
 		}
+		.desktop-icon-drop {
+			background-color: rgba(0, 150, 136, 0.3);
+			border: 1px dashed rgba(0, 150, 136, 0.8);
+		}
+		.rubber-band {
+			background-color: rgba(0, 150, 136, 0.15);
+			border: 1px solid rgba(0, 150, 136, 0.8);
+			border-radius: 2px;
+		}
 		.icon-label {
 			color: #ffffff;
 			font-size: 11px;
@@ -250,35 +325,14 @@ func (d *RavenDesktop) createUI() *gtk.Overlay {
 	d.bgBox.SetVExpand(true)
 
 	// Try to load wallpaper from settings first, then fallback
-	wallpaperPaths := []string{}
-	if d.settings.WallpaperPath != "" {
-		wallpaperPaths = append(wallpaperPaths, d.settings.WallpaperPath)
-	}
-	wallpaperPaths = append(wallpaperPaths,
-		"/usr/share/backgrounds/raven-wallpaper.png",
-		"/usr/share/backgrounds/raven-sky.ppm",
-		"/usr/share/backgrounds/default.png",
-	)
-
-	for _, path := range wallpaperPaths {
-		if _, err := os.Stat(path); err == nil {
-			d.bgPicture = gtk.NewPictureForFilename(path)
-			d.bgPicture.SetContentFit(gtk.ContentFitCover)
-			d.bgPicture.SetHExpand(true)
-			d.bgPicture.SetVExpand(true)
-			break
-		}
-	}
-
-	if d.bgPicture != nil {
-		d.bgBox.Append(d.bgPicture)
-	}
+	d.applyWallpaper(d.resolveWallpaper(), d.settings.WallpaperMode)
 
 	d.overlay.SetChild(d.bgBox)
 
 	// Icon grid
 	d.iconGrid = gtk.NewFlowBox()
-	d.iconGrid.SetSelectionMode(gtk.SelectionSingle)
+	// Selection is tracked per icon so it works in both layouts
+	d.iconGrid.SetSelectionMode(gtk.SelectionNone)
 	d.iconGrid.SetActivateOnSingleClick(false) // Double-click to activate
 	d.iconGrid.SetHomogeneous(true)
 	d.iconGrid.SetRowSpacing(16)
@@ -293,11 +347,18 @@ func (d *RavenDesktop) createUI() *gtk.Overlay {
 	d.iconGrid.SetHAlign(gtk.AlignStart)
 	d.iconGrid.SetCanFocus(true)
 
+	// Free placement layer - icons positioned by their saved X/Y
+	d.iconFixed = gtk.NewFixed()
+	d.iconFixed.SetHExpand(true)
+	d.iconFixed.SetVExpand(true)
+
+	d.iconLayer = gtk.NewBox(gtk.OrientationVertical, 0)
+	d.iconLayer.Append(d.iconGrid)
+	d.iconLayer.Append(d.iconFixed)
+
 	// Add icons
-	for i, icon := range d.icons {
-		iconWidget := d.createIconWidget(icon, i)
-		d.iconGrid.Append(iconWidget)
-	}
+	d.refreshIconGrid()
+	d.iconLayer.SetVisible(d.settings.ShowDesktopIcons)
 
 	// Handle double-click
 	d.iconGrid.ConnectChildActivated(func(child *gtk.FlowBoxChild) {
@@ -307,7 +368,10 @@ func (d *RavenDesktop) createUI() *gtk.Overlay {
 		}
 	})
 
-	d.overlay.AddOverlay(d.iconGrid)
+	d.overlay.AddOverlay(d.iconLayer)
+
+	// Click-drag on empty space selects icons
+	d.setupRubberBand()
 
 	return d.overlay
 }
@@ -320,29 +384,12 @@ func (d *RavenDesktop) createIconWidget(icon DesktopIcon, index int) *gtk.Box {
 	box.SetCanFocus(true)
 
 	// Icon image
-	var image *gtk.Image
-	iconPaths := []string{
-		"/usr/share/icons/hicolor/48x48/apps/" + icon.Icon + ".png",
-		"/usr/share/icons/hicolor/48x48/apps/" + icon.Icon + ".svg",
-		"/usr/share/pixmaps/" + icon.Icon + ".png",
-		"/usr/share/icons/Adwaita/48x48/apps/" + icon.Icon + ".png",
-	}
-
-	found := false
-	for _, path := range iconPaths {
-		if _, err := os.Stat(path); err == nil {
-			image = gtk.NewImageFromFile(path)
-			found = true
-			break
-		}
+	image := icons.NewImage(icon.Icon, 48)
+	if icon.Trash {
+		d.trashImage = image
+		d.attachTrashDrop(box)
 	}
 
-	if !found {
-		// Use a themed icon or fallback
-		image = gtk.NewImageFromIconName(icon.Icon)
-	}
-
-	image.SetPixelSize(48)
 	image.AddCSSClass("icon-image")
 	box.Append(image)
 
@@ -361,11 +408,7 @@ func (d *RavenDesktop) createIconWidget(icon DesktopIcon, index int) *gtk.Box {
 	leftClick := gtk.NewGestureClick()
 	leftClick.SetButton(1) // Left click
 	leftClick.ConnectPressed(func(nPress int, x, y float64) {
-		// Select this icon in the FlowBox
-		child := d.iconGrid.ChildAtIndex(iconIdx)
-		if child != nil {
-			d.iconGrid.SelectChild(child)
-		}
+		d.selectIcon(iconIdx, leftClick.CurrentEventState())
 		// Double-click to launch
 		if nPress == 2 {
 			d.launchApp(d.icons[iconIdx].Exec)
@@ -375,16 +418,135 @@ func (d *RavenDesktop) createIconWidget(icon DesktopIcon, index int) *gtk.Box {
 
 	// Add right-click menu for unpinning
 	iconName := icon.Name // Capture for closure
+	iconExec := icon.Exec
+	isTrash := icon.Trash
 	rightClick := gtk.NewGestureClick()
 	rightClick.SetButton(3) // Right click
 	rightClick.ConnectPressed(func(nPress int, x, y float64) {
+		if d.isSelected(iconIdx) && len(d.selected) > 1 {
+			d.showSelectionContextMenu(box)
+			return
+		}
+		d.selectIcon(iconIdx, 0)
+		if isTrash {
+			d.showTrashContextMenu(box, iconExec)
+			return
+		}
 		d.showIconContextMenu(box, iconName, x, y)
 	})
 	box.AddController(rightClick)
 
+	if d.freePlacement {
+		d.attachIconDrag(box, index)
+	}
+
 	return box
 }
 
+// attachIconDrag lets a freely placed icon be dragged around the desktop.
+// On release the icon snaps to the grid and its position is persisted.
+func (d *RavenDesktop) attachIconDrag(box *gtk.Box, index int) {
+	var startX, startY float64
+
+	drag := gtk.NewGestureDrag()
+	drag.SetButton(1)
+	drag.ConnectDragBegin(func(x, y float64) {
+		startX = float64(d.icons[index].X)
+		startY = float64(d.icons[index].Y)
+	})
+	drag.ConnectDragUpdate(func(offsetX, offsetY float64) {
+		d.iconFixed.Move(box, startX+offsetX, startY+offsetY)
+	})
+	drag.ConnectDragEnd(func(offsetX, offsetY float64) {
+		// Ignore clicks that barely moved
+		if math.Abs(offsetX) < 4 && math.Abs(offsetY) < 4 {
+			d.iconFixed.Move(box, startX, startY)
+			return
+		}
+
+		x, y := snapToGrid(startX+offsetX, startY+offsetY)
+		if d.cellTaken(x, y, index) {
+			x, y = d.nextFreeCell(index)
+		}
+		d.icons[index].X = x
+		d.icons[index].Y = y
+		d.iconFixed.Move(box, float64(x), float64(y))
+		d.savePinnedApps()
+	})
+	box.AddController(drag)
+}
+
+// snapToGrid rounds a desktop position to the nearest grid cell origin
+func snapToGrid(x, y float64) (int, int) {
+	col := int(math.Round((x - gridOriginX) / gridCellWidth))
+	row := int(math.Round((y - gridOriginY) / gridCellHeight))
+	if col < 0 {
+		col = 0
+	}
+	if row < 0 {
+		row = 0
+	}
+	return gridOriginX + col*gridCellWidth, gridOriginY + row*gridCellHeight
+}
+
+// cellTaken reports whether an icon other than skip occupies the cell at x, y
+func (d *RavenDesktop) cellTaken(x, y, skip int) bool {
+	for i, icon := range d.icons {
+		if i != skip && icon.X == x && icon.Y == y {
+			return true
+		}
+	}
+	return false
+}
+
+// nextFreeCell returns the first unoccupied cell, filling columns top to
+// bottom and then left to right like the auto-arranged layout
+func (d *RavenDesktop) nextFreeCell(skip int) (int, int) {
+	rows := 6
+	if height := d.window.Height(); height > gridOriginY+gridCellHeight {
+		rows = (height - gridOriginY) / gridCellHeight
+	}
+
+	for cell := 0; ; cell++ {
+		x := gridOriginX + (cell/rows)*gridCellWidth
+		y := gridOriginY + (cell%rows)*gridCellHeight
+		if !d.cellTaken(x, y, skip) {
+			return x, y
+		}
+	}
+}
+
+// placeIcons puts every icon into the free placement layer, assigning grid
+// cells to icons that have never been positioned
+func (d *RavenDesktop) placeIcons() {
+	assigned := false
+	for i := range d.icons {
+		if d.icons[i].X == 0 && d.icons[i].Y == 0 {
+			d.icons[i].X, d.icons[i].Y = d.nextFreeCell(i)
+			assigned = true
+		}
+	}
+	if assigned {
+		d.savePinnedApps()
+	}
+
+	for i, icon := range d.icons {
+		iconWidget := d.createIconWidget(icon, i)
+		d.iconWidgets = append(d.iconWidgets, iconWidget)
+		d.iconFixed.Put(iconWidget, float64(icon.X), float64(icon.Y))
+	}
+}
+
+// setFreePlacement switches between the auto-arranged grid and free placement
+func (d *RavenDesktop) setFreePlacement(enabled bool) {
+	if d.freePlacement == enabled {
+		return
+	}
+	d.freePlacement = enabled
+	d.savePinnedApps()
+	d.refreshIconGrid()
+}
+
 func (d *RavenDesktop) showIconContextMenu(parent *gtk.Box, iconName string, x, y float64) {
 	menu := gio.NewMenu()
 	menu.Append("Unpin from Desktop", "app.unpin."+strings.ReplaceAll(iconName, " ", "_"))
@@ -408,19 +570,26 @@ func (d *RavenDesktop) setupContextMenu() {
 	menu := gio.NewMenu()
 
 	// Add menu items
+	// Kiosk mode leaves out the terminal and the settings
 	section1 := gio.NewMenu()
-	section1.Append("Open Terminal", "app.terminal")
-	section1.Append("Open File Manager", "app.files")
+	if !d.settings.KioskMode {
+		section1.Append("Open Terminal", "app.terminal")
+		section1.Append("Open File Manager", "app.files")
+	}
 	section1.Append("Open Fuzzy Finder", "app.fuzzy")
 	menu.AppendSection("", section1)
 
 	section2 := gio.NewMenu()
 	section2.Append("Pin Application...", "app.pin")
 	section2.Append("Change Wallpaper...", "app.wallpaper")
-	section2.Append("Raven Settings", "app.settings")
+	if !d.settings.KioskMode {
+		section2.Append("Raven Settings", "app.settings")
+	}
 	menu.AppendSection("", section2)
 
 	section3 := gio.NewMenu()
+	section3.Append("Hide Desktop Icons", "app.hideicons")
+	section3.Append("Auto-arrange Icons", "app.autoarrange")
 	section3.Append("Refresh Desktop", "app.refresh")
 	menu.AppendSection("", section3)
 
@@ -461,6 +630,26 @@ func (d *RavenDesktop) setupContextMenu() {
 	})
 	d.app.AddAction(wallpaperAction)
 
+	autoArrangeAction := gio.NewSimpleActionStateful("autoarrange", nil, glib.NewVariantBoolean(!d.freePlacement))
+	autoArrangeAction.ConnectActivate(func(v *glib.Variant) {
+		autoArrange := !autoArrangeAction.State().Boolean()
+		autoArrangeAction.SetState(glib.NewVariantBoolean(autoArrange))
+		d.setFreePlacement(!autoArrange)
+	})
+	d.app.AddAction(autoArrangeAction)
+
+	d.hideIcons = gio.NewSimpleActionStateful("hideicons", nil, glib.NewVariantBoolean(!d.settings.ShowDesktopIcons))
+	d.hideIcons.ConnectActivate(func(v *glib.Variant) {
+		show := d.hideIcons.State().Boolean()
+		d.setShowDesktopIcons(show)
+		if err := d.updateSettings("show_desktop_icons", show); err != nil {
+			fmt.Fprintf(os.Stderr, "raven-desktop: failed to save settings: %v\n", err)
+		}
+	})
+	d.app.AddAction(d.hideIcons)
+
+	d.setupBulkActions()
+
 	refreshAction := gio.NewSimpleAction("refresh", nil)
 	refreshAction.ConnectActivate(func(v *glib.Variant) {
 		d.loadIcons()
@@ -503,6 +692,17 @@ func (d *RavenDesktop) showWallpaperChooser() {
 	filter.AddPattern("*.webp")
 	dialog.AddFilter(filter)
 
+	animated := gtk.NewFileFilter()
+	animated.SetName("Animated Wallpapers")
+	animated.AddMIMEType("video/mp4")
+	animated.AddMIMEType("video/webm")
+	animated.AddPattern("*.mp4")
+	animated.AddPattern("*.webm")
+	animated.AddPattern("*.mkv")
+	animated.AddPattern("*.glsl")
+	animated.AddPattern("*.frag")
+	dialog.AddFilter(animated)
+
 	// Set default location
 	picturesDir := filepath.Join(os.Getenv("HOME"), "Pictures")
 	if _, err := os.Stat(picturesDir); err == nil {
@@ -515,7 +715,11 @@ func (d *RavenDesktop) showWallpaperChooser() {
 			file := dialog.File()
 			if file != nil {
 				path := file.Path()
-				d.setWallpaper(path)
+				if wallpaperKind(path) != wallpaperImage {
+					d.confirmAnimatedWallpaper(path)
+				} else {
+					d.setWallpaper(path)
+				}
 			}
 		}
 	})
@@ -526,43 +730,67 @@ func (d *RavenDesktop) showWallpaperChooser() {
 func (d *RavenDesktop) setWallpaper(path string) {
 	// Update settings
 	d.settings.WallpaperPath = path
+	if err := d.updateSettings("wallpaper_path", path); err != nil {
+		fmt.Fprintf(os.Stderr, "raven-desktop: failed to save settings: %v\n", err)
+	}
 
-	// Save settings
-	data, err := json.MarshalIndent(d.settings, "", "  ")
-	if err == nil {
-		os.WriteFile(d.settingsPath, data, 0644)
+	// Update the background
+	d.applyWallpaper(path, d.settings.WallpaperMode)
+}
+
+// updateSettings sets one key in settings.json. The file is shared with
+// the settings menu and panel, so keys the desktop doesn't know are kept.
+func (d *RavenDesktop) updateSettings(key string, value any) error {
+	settings := make(map[string]json.RawMessage)
+	if data, err := os.ReadFile(d.settingsPath); err == nil {
+		if err := json.Unmarshal(data, &settings); err != nil {
+			return fmt.Errorf("parse %s: %w", d.settingsPath, err)
+		}
+	} else if !os.IsNotExist(err) {
+		return err
 	}
 
-	// Update the background picture
-	if d.bgPicture != nil {
-		d.bgBox.Remove(d.bgPicture)
+	raw, err := json.Marshal(value)
+	if err != nil {
+		return err
 	}
+	settings[key] = raw
 
-	d.bgPicture = gtk.NewPictureForFilename(path)
-	d.bgPicture.SetContentFit(gtk.ContentFitCover)
-	d.bgPicture.SetHExpand(true)
-	d.bgPicture.SetVExpand(true)
-	d.bgBox.Append(d.bgPicture)
+	data, err := json.MarshalIndent(settings, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := d.settingsPath + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, d.settingsPath)
 }
 
 func (d *RavenDesktop) showFuzzyFinder() {
-	if d.fuzzyFinder == nil {
-		d.fuzzyFinder = fuzzy.New(d.window, func(name, exec, icon string) {
-			d.pinApp(DesktopIcon{Name: name, Exec: exec, Icon: icon})
-		})
-	}
+	d.ensureFuzzyFinder()
 	d.fuzzyFinder.Show(false)
 }
 
 func (d *RavenDesktop) showFuzzyFinderForPinning() {
-	if d.fuzzyFinder == nil {
-		d.fuzzyFinder = fuzzy.New(d.window, func(name, exec, icon string) {
-			d.pinApp(DesktopIcon{Name: name, Exec: exec, Icon: icon})
-		})
-	}
+	d.ensureFuzzyFinder()
 	d.fuzzyFinder.Show(true)
 }
 
+// ensureFuzzyFinder creates the finder on first use. In kiosk mode it only
+// launches applications.
+func (d *RavenDesktop) ensureFuzzyFinder() {
+	if d.fuzzyFinder != nil {
+		return
+	}
+	d.fuzzyFinder = fuzzy.New(d.window, func(name, exec, icon string) {
+		d.pinApp(DesktopIcon{Name: name, Exec: exec, Icon: icon})
+	})
+	if d.settings.KioskMode {
+		d.fuzzyFinder.DisableCommands()
+	}
+}
+
 func (d *RavenDesktop) loadIcons() {
 	d.icons = []DesktopIcon{}
 
@@ -575,14 +803,22 @@ func (d *RavenDesktop) loadIcons() {
 		files, _ := filepath.Glob(filepath.Join(desktopDir, "*.desktop"))
 		for _, file := range files {
 			icon := parseDesktopFile(file)
+			icon.Path = file
+			if pos, ok := d.filePositions[file]; ok {
+				icon.X, icon.Y = pos.X, pos.Y
+			}
 			if icon.Name != "" {
 				d.icons = append(d.icons, icon)
 			}
 		}
 	}
+
+	d.icons = append(d.icons, d.trashIcon())
 }
 
 func (d *RavenDesktop) loadPinnedApps() {
+	d.filePositions = make(map[string]IconPosition)
+
 	data, err := os.ReadFile(d.pinnedAppsPath)
 	if err != nil {
 		return
@@ -594,11 +830,41 @@ func (d *RavenDesktop) loadPinnedApps() {
 	}
 
 	d.icons = append(d.icons, config.PinnedApps...)
+	d.freePlacement = config.FreePlacement
+	for path, pos := range config.FilePositions {
+		d.filePositions[path] = pos
+	}
+	if config.TrashPosition != nil {
+		d.trashPosition = *config.TrashPosition
+	}
 }
 
 func (d *RavenDesktop) savePinnedApps() error {
+	// Icons backed by ~/Desktop files are reloaded from there on start, so
+	// only their positions are saved, keyed by file path
+	pinned := []DesktopIcon{}
+	for _, icon := range d.icons {
+		if icon.Trash {
+			d.trashPosition = IconPosition{X: icon.X, Y: icon.Y}
+		} else if icon.Path == "" {
+			pinned = append(pinned, icon)
+		} else if icon.X != 0 || icon.Y != 0 {
+			d.filePositions[icon.Path] = IconPosition{X: icon.X, Y: icon.Y}
+		}
+	}
+
+	// Forget files that have been removed from ~/Desktop
+	for path := range d.filePositions {
+		if _, err := os.Stat(path); err != nil {
+			delete(d.filePositions, path)
+		}
+	}
+
 	config := PinnedAppsConfig{
-		PinnedApps: d.icons,
+		PinnedApps:    pinned,
+		FreePlacement: d.freePlacement,
+		FilePositions: d.filePositions,
+		TrashPosition: &d.trashPosition,
 	}
 
 	data, err := json.MarshalIndent(config, "", "  ")
@@ -625,7 +891,7 @@ func (d *RavenDesktop) pinApp(icon DesktopIcon) {
 func (d *RavenDesktop) unpinApp(name string) {
 	newIcons := []DesktopIcon{}
 	for _, icon := range d.icons {
-		if icon.Name != name {
+		if icon.Name != name || icon.Trash {
 			newIcons = append(newIcons, icon)
 		}
 	}
@@ -634,6 +900,18 @@ func (d *RavenDesktop) unpinApp(name string) {
 	d.refreshIconGrid()
 }
 
+// setShowDesktopIcons shows or hides the whole icon layer
+func (d *RavenDesktop) setShowDesktopIcons(show bool) {
+	d.settings.ShowDesktopIcons = show
+	d.iconLayer.SetVisible(show)
+	if !show {
+		d.clearSelection()
+	}
+	if d.hideIcons != nil {
+		d.hideIcons.SetState(glib.NewVariantBoolean(!show))
+	}
+}
+
 func (d *RavenDesktop) refreshIconGrid() {
 	// Remove all children
 	for {
@@ -643,10 +921,30 @@ func (d *RavenDesktop) refreshIconGrid() {
 		}
 		d.iconGrid.Remove(child)
 	}
+	for {
+		child := d.iconFixed.FirstChild()
+		if child == nil {
+			break
+		}
+		d.iconFixed.Remove(child)
+	}
+
+	d.iconWidgets = nil
+	d.selected = make(map[int]bool)
+	d.cursor = -1
+
+	d.iconGrid.SetVisible(!d.freePlacement)
+	d.iconFixed.SetVisible(d.freePlacement)
+
+	if d.freePlacement {
+		d.placeIcons()
+		return
+	}
 
 	// Re-add icons
 	for i, icon := range d.icons {
 		iconWidget := d.createIconWidget(icon, i)
+		d.iconWidgets = append(d.iconWidgets, iconWidget)
 		d.iconGrid.Append(iconWidget)
 	}
 }
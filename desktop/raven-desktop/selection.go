@@ -0,0 +1,230 @@
+package main
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/diamondburned/gotk4/pkg/gdk/v4"
+	"github.com/diamondburned/gotk4/pkg/gio/v2"
+	"github.com/diamondburned/gotk4/pkg/glib/v2"
+	"github.com/diamondburned/gotk4/pkg/gtk/v4"
+)
+
+// Minimum drag distance before a press on the desktop starts a rubber band
+const rubberBandThreshold = 4
+
+// isSelected reports whether the icon at index is selected
+func (d *RavenDesktop) isSelected(index int) bool {
+	return d.selected[index]
+}
+
+// setSelected updates the selection state and styling of one icon
+func (d *RavenDesktop) setSelected(index int, selected bool) {
+	if index < 0 || index >= len(d.iconWidgets) {
+		return
+	}
+	if selected {
+		d.selected[index] = true
+		d.iconWidgets[index].AddCSSClass("desktop-icon-selected")
+	} else {
+		delete(d.selected, index)
+		d.iconWidgets[index].RemoveCSSClass("desktop-icon-selected")
+	}
+}
+
+// clearSelection deselects every icon
+func (d *RavenDesktop) clearSelection() {
+	for index := range d.selected {
+		d.setSelected(index, false)
+	}
+}
+
+// selectIcon handles a left click on an icon: Ctrl toggles it in the
+// selection, a plain click selects only it
+func (d *RavenDesktop) selectIcon(index int, state gdk.ModifierType) {
+	d.cursor = index
+	if state&gdk.ControlMask != 0 {
+		d.setSelected(index, !d.isSelected(index))
+		return
+	}
+	if d.isSelected(index) && len(d.selected) == 1 {
+		return
+	}
+	d.clearSelection()
+	d.setSelected(index, true)
+}
+
+// selectedIndices returns the selected icon indices in icon order
+func (d *RavenDesktop) selectedIndices() []int {
+	var indices []int
+	for i := range d.icons {
+		if d.selected[i] {
+			indices = append(indices, i)
+		}
+	}
+	return indices
+}
+
+// setupRubberBand lets the user drag out a rectangle over empty desktop
+// space to select every icon it touches. Holding Ctrl adds to the selection.
+func (d *RavenDesktop) setupRubberBand() {
+	d.bandLayer = gtk.NewFixed()
+	d.bandLayer.SetCanTarget(false)
+	d.band = gtk.NewBox(gtk.OrientationVertical, 0)
+	d.band.AddCSSClass("rubber-band")
+	d.band.SetVisible(false)
+	d.bandLayer.Put(d.band, 0, 0)
+	d.overlay.AddOverlay(d.bandLayer)
+
+	var startX, startY float64
+	var additive bool
+	var base map[int]bool
+
+	drag := gtk.NewGestureDrag()
+	drag.SetButton(1)
+	drag.ConnectDragBegin(func(x, y float64) {
+		if d.iconAt(x, y) || !d.settings.ShowDesktopIcons {
+			// Icons handle their own clicks and drags
+			drag.SetState(gtk.EventSequenceDenied)
+			return
+		}
+		startX, startY = x, y
+		additive = drag.CurrentEventState()&gdk.ControlMask != 0
+		base = make(map[int]bool)
+		if additive {
+			for index := range d.selected {
+				base[index] = true
+			}
+		}
+	})
+	drag.ConnectDragUpdate(func(offsetX, offsetY float64) {
+		if math.Abs(offsetX) < rubberBandThreshold && math.Abs(offsetY) < rubberBandThreshold {
+			return
+		}
+
+		x := math.Min(startX, startX+offsetX)
+		y := math.Min(startY, startY+offsetY)
+		w := math.Abs(offsetX)
+		h := math.Abs(offsetY)
+
+		d.bandLayer.Move(d.band, x, y)
+		d.band.SetSizeRequest(int(w), int(h))
+		d.band.SetVisible(true)
+
+		for i, widget := range d.iconWidgets {
+			d.setSelected(i, base[i] || widgetIntersects(widget, d.overlay, x, y, w, h))
+		}
+	})
+	drag.ConnectDragEnd(func(offsetX, offsetY float64) {
+		d.band.SetVisible(false)
+
+		// A plain click on empty space clears the selection
+		if math.Abs(offsetX) < rubberBandThreshold && math.Abs(offsetY) < rubberBandThreshold && !additive {
+			d.clearSelection()
+		}
+	})
+	d.overlay.AddController(drag)
+}
+
+// iconAt reports whether the point (in overlay coordinates) is over an icon
+func (d *RavenDesktop) iconAt(x, y float64) bool {
+	for _, widget := range d.iconWidgets {
+		if widgetIntersects(widget, d.overlay, x, y, 1, 1) {
+			return true
+		}
+	}
+	return false
+}
+
+// widgetIntersects reports whether widget overlaps the rectangle, given in
+// the coordinate space of target
+func widgetIntersects(widget *gtk.Box, target gtk.Widgetter, x, y, w, h float64) bool {
+	if !widget.IsVisible() {
+		return false
+	}
+	wx, wy, ok := widget.TranslateCoordinates(target, 0, 0)
+	if !ok {
+		return false
+	}
+	ww := float64(widget.Width())
+	wh := float64(widget.Height())
+	return wx < x+w && wx+ww > x && wy < y+h && wy+wh > y
+}
+
+// setupBulkActions registers the actions used by the multi-selection menu
+func (d *RavenDesktop) setupBulkActions() {
+	openAction := gio.NewSimpleAction("open-selected", nil)
+	openAction.ConnectActivate(func(v *glib.Variant) {
+		for _, i := range d.selectedIndices() {
+			d.launchApp(d.icons[i].Exec)
+		}
+	})
+	d.app.AddAction(openAction)
+
+	unpinAction := gio.NewSimpleAction("unpin-selected", nil)
+	unpinAction.ConnectActivate(func(v *glib.Variant) {
+		d.unpinSelected()
+	})
+	d.app.AddAction(unpinAction)
+
+	deleteAction := gio.NewSimpleAction("delete-selected", nil)
+	deleteAction.ConnectActivate(func(v *glib.Variant) {
+		d.trashSelected()
+	})
+	d.app.AddAction(deleteAction)
+}
+
+// showSelectionContextMenu offers bulk actions for the selected icons
+func (d *RavenDesktop) showSelectionContextMenu(parent *gtk.Box) {
+	var pinned, files int
+	for _, i := range d.selectedIndices() {
+		if d.icons[i].Path != "" {
+			files++
+		} else if !d.icons[i].Trash {
+			pinned++
+		}
+	}
+
+	menu := gio.NewMenu()
+	menu.Append(fmt.Sprintf("Open %d Items", len(d.selected)), "app.open-selected")
+	if pinned > 0 {
+		menu.Append(fmt.Sprintf("Unpin %d from Desktop", pinned), "app.unpin-selected")
+	}
+	if files > 0 {
+		menu.Append(fmt.Sprintf("Move %d Files to Trash", files), "app.delete-selected")
+	}
+
+	popover := gtk.NewPopoverMenuFromModel(menu)
+	popover.SetParent(parent)
+	popover.SetPosition(gtk.PosBottom)
+	popover.Popup()
+}
+
+// unpinSelected removes every selected pinned app from the desktop
+func (d *RavenDesktop) unpinSelected() {
+	var kept []DesktopIcon
+	for i, icon := range d.icons {
+		if d.selected[i] && icon.Path == "" && !icon.Trash {
+			continue
+		}
+		kept = append(kept, icon)
+	}
+	d.icons = kept
+	d.savePinnedApps()
+	d.refreshIconGrid()
+}
+
+// trashSelected moves the selected ~/Desktop files to the trash
+func (d *RavenDesktop) trashSelected() {
+	var paths []string
+	for _, i := range d.selectedIndices() {
+		if d.icons[i].Path != "" {
+			paths = append(paths, d.icons[i].Path)
+		}
+	}
+	if len(paths) == 0 {
+		return
+	}
+	d.clearSelection()
+	d.moveToTrash(paths)
+}
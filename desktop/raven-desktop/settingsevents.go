@@ -0,0 +1,76 @@
+package main
+
+import (
+	"github.com/diamondburned/gotk4/pkg/glib/v2"
+	"github.com/godbus/dbus/v5"
+)
+
+// Where raven-settingsd announces changes to settings.json
+const (
+	settingsdName  = "org.ravenlinux.Settings"
+	settingsdPath  = dbus.ObjectPath("/org/ravenlinux/Settings")
+	settingsdIface = "org.ravenlinux.Settings"
+)
+
+// watchSettingsChanges calls changed on the main loop with the keys of
+// settings.json that raven-settingsd reports changed, and running whenever
+// raven-settingsd starts or stops
+func watchSettingsChanges(changed func(keys []string), running func(bool)) error {
+	conn, err := dbus.ConnectSessionBus()
+	if err != nil {
+		return err
+	}
+	err = conn.AddMatchSignal(
+		dbus.WithMatchObjectPath(settingsdPath),
+		dbus.WithMatchInterface(settingsdIface),
+		dbus.WithMatchMember("Changed"),
+	)
+	if err == nil {
+		err = conn.AddMatchSignal(
+			dbus.WithMatchInterface("org.freedesktop.DBus"),
+			dbus.WithMatchMember("NameOwnerChanged"),
+			dbus.WithMatchArg(0, settingsdName),
+		)
+	}
+	if err != nil {
+		return err
+	}
+
+	signals := make(chan *dbus.Signal, 16)
+	conn.Signal(signals)
+
+	var hasOwner bool
+	if err := conn.BusObject().Call("org.freedesktop.DBus.NameHasOwner", 0, settingsdName).Store(&hasOwner); err != nil {
+		return err
+	}
+	running(hasOwner)
+
+	go func() {
+		for sig := range signals {
+			switch sig.Name {
+			case settingsdIface + ".Changed":
+				if len(sig.Body) == 0 {
+					continue
+				}
+				if keys, ok := sig.Body[0].([]string); ok {
+					glib.IdleAdd(func() {
+						changed(keys)
+					})
+				}
+			case "org.freedesktop.DBus.NameOwnerChanged":
+				// NameOwnerChanged(name, old_owner, new_owner)
+				if len(sig.Body) < 3 {
+					continue
+				}
+				if name, _ := sig.Body[0].(string); name != settingsdName {
+					continue
+				}
+				newOwner, _ := sig.Body[2].(string)
+				glib.IdleAdd(func() {
+					running(newOwner != "")
+				})
+			}
+		}
+	}()
+	return nil
+}
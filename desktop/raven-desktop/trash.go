@@ -0,0 +1,134 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"raven-file-manager/pkg/icons"
+	"raven-file-manager/pkg/trash"
+
+	"github.com/diamondburned/gotk4/pkg/gdk/v4"
+	"github.com/diamondburned/gotk4/pkg/gio/v2"
+	"github.com/diamondburned/gotk4/pkg/glib/v2"
+	"github.com/diamondburned/gotk4/pkg/gtk/v4"
+)
+
+// How often the Trash icon re-checks whether the trash is empty, to catch
+// files trashed by other applications
+const trashPollSeconds = 3
+
+// trashIcon returns the special Trash icon, placed at its saved position
+func (d *RavenDesktop) trashIcon() DesktopIcon {
+	filesDir := filepath.Join(trash.Dir(), "files")
+	return DesktopIcon{
+		Name:  "Trash",
+		Exec:  "raven-file-manager '" + strings.ReplaceAll(filesDir, "'", `'\''`) + "'",
+		Icon:  trashIconName(),
+		X:     d.trashPosition.X,
+		Y:     d.trashPosition.Y,
+		Trash: true,
+	}
+}
+
+func trashIconName() string {
+	if trash.IsEmpty() {
+		return "user-trash"
+	}
+	return "user-trash-full"
+}
+
+// updateTrashIcon switches the Trash icon between its empty and full image
+func (d *RavenDesktop) updateTrashIcon() {
+	if d.trashImage != nil {
+		icons.SetImage(d.trashImage, trashIconName(), 48)
+	}
+}
+
+// watchTrash keeps the Trash icon in step with the trash contents
+func (d *RavenDesktop) watchTrash() {
+	glib.TimeoutSecondsAdd(trashPollSeconds, func() bool {
+		d.updateTrashIcon()
+		return true
+	})
+}
+
+// attachTrashDrop lets files dragged from the file manager or the desktop
+// be dropped on the Trash icon
+func (d *RavenDesktop) attachTrashDrop(box *gtk.Box) {
+	drop := gtk.NewDropTarget(gdk.GTypeFileList, gdk.ActionMove|gdk.ActionCopy)
+	drop.ConnectEnter(func(x, y float64) gdk.DragAction {
+		box.AddCSSClass("desktop-icon-drop")
+		return gdk.ActionMove
+	})
+	drop.ConnectLeave(func() {
+		box.RemoveCSSClass("desktop-icon-drop")
+	})
+	drop.ConnectDrop(func(value *glib.Value, x, y float64) bool {
+		box.RemoveCSSClass("desktop-icon-drop")
+		list, ok := value.GoValue().(*gdk.FileList)
+		if !ok {
+			return false
+		}
+
+		var paths []string
+		for _, file := range list.Files() {
+			if path := file.Path(); path != "" {
+				paths = append(paths, path)
+			}
+		}
+		if len(paths) == 0 {
+			return false
+		}
+		d.moveToTrash(paths)
+		return true
+	})
+	box.AddController(drop)
+}
+
+// moveToTrash trashes paths off the main loop, then reloads the icons
+// since some of them may have been ~/Desktop files
+func (d *RavenDesktop) moveToTrash(paths []string) {
+	go func() {
+		for _, path := range paths {
+			if err := trash.Move(path); err != nil {
+				fmt.Fprintf(os.Stderr, "raven-desktop: failed to trash %s: %v\n", path, err)
+			}
+		}
+		glib.IdleAdd(func() {
+			d.loadIcons()
+			d.refreshIconGrid()
+		})
+	}()
+}
+
+// showTrashContextMenu offers Open and Empty Trash for the Trash icon
+func (d *RavenDesktop) showTrashContextMenu(parent *gtk.Box, exec string) {
+	menu := gio.NewMenu()
+	menu.Append("Open", "app.trash-open")
+	menu.Append("Empty Trash", "app.trash-empty")
+
+	openAction := gio.NewSimpleAction("trash-open", nil)
+	openAction.ConnectActivate(func(v *glib.Variant) {
+		d.launchApp(exec)
+	})
+	d.app.AddAction(openAction)
+
+	emptyAction := gio.NewSimpleAction("trash-empty", nil)
+	emptyAction.SetEnabled(!trash.IsEmpty())
+	emptyAction.ConnectActivate(func(v *glib.Variant) {
+		go func() {
+			if err := trash.Empty(); err != nil {
+				fmt.Fprintf(os.Stderr, "raven-desktop: failed to empty trash: %v\n", err)
+			}
+			glib.IdleAdd(d.updateTrashIcon)
+		}()
+	})
+	d.app.AddAction(emptyAction)
+
+	popover := gtk.NewPopoverMenuFromModel(menu)
+	popover.SetParent(parent)
+	popover.SetPosition(gtk.PosBottom)
+	popover.Popup()
+}
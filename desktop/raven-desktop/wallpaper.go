@@ -0,0 +1,161 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/diamondburned/gotk4/pkg/gdk/v4"
+	"github.com/diamondburned/gotk4/pkg/glib/v2"
+	"github.com/diamondburned/gotk4/pkg/gtk/v4"
+)
+
+// How often settings.json is checked for changes made by other components
+const settingsPollInterval = 2
+
+// Wallpapers tried when the configured one is missing
+var fallbackWallpapers = []string{
+	"/usr/share/backgrounds/raven-wallpaper.png",
+	"/usr/share/backgrounds/raven-sky.ppm",
+	"/usr/share/backgrounds/default.png",
+}
+
+// resolveWallpaper returns the configured wallpaper, or the first installed
+// fallback if it doesn't exist
+func (d *RavenDesktop) resolveWallpaper() string {
+	if d.settings.WallpaperPath != "" {
+		if _, err := os.Stat(d.settings.WallpaperPath); err == nil {
+			return d.settings.WallpaperPath
+		}
+	}
+	return fallbackWallpaper()
+}
+
+// fallbackWallpaper returns the first installed default wallpaper
+func fallbackWallpaper() string {
+	for _, path := range fallbackWallpapers {
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+	}
+	return ""
+}
+
+// applyWallpaper shows path in the background using the given mode (fill,
+// fit, stretch, center or tile). Videos and shaders are handed to the
+// wallpaper engine; images use the background Picture.
+func (d *RavenDesktop) applyWallpaper(path, mode string) {
+	d.stopAnimatedWallpaper()
+	if d.bgPicture != nil {
+		d.bgBox.Remove(d.bgPicture)
+		d.bgPicture = nil
+	}
+	if d.wallpaperCSS == nil {
+		d.wallpaperCSS = gtk.NewCSSProvider()
+		gtk.StyleContextAddProviderForDisplay(gdk.DisplayGetDefault(), d.wallpaperCSS, gtk.STYLE_PROVIDER_PRIORITY_APPLICATION+1)
+	}
+	d.wallpaperCSS.LoadFromString("")
+	d.appliedWallpaper = path
+	d.appliedMode = mode
+
+	if path == "" {
+		return
+	}
+
+	switch wallpaperKind(path) {
+	case wallpaperVideo:
+		d.showVideoWallpaper(path, mode)
+	case wallpaperShader:
+		d.showShaderWallpaper(path)
+	default:
+		d.showStaticWallpaper(path, mode)
+	}
+}
+
+// showStaticWallpaper shows an image. The scaling modes use the background
+// Picture; center and tile keep the image at its natural size and are drawn
+// as a CSS background on the desktop box, which also handles the repeat.
+func (d *RavenDesktop) showStaticWallpaper(path, mode string) {
+	if path == "" {
+		return
+	}
+
+	if mode == "center" || mode == "tile" {
+		repeat, position := "no-repeat", "center"
+		if mode == "tile" {
+			repeat, position = "repeat", "left top"
+		}
+		fileURL := (&url.URL{Scheme: "file", Path: path}).String()
+		d.wallpaperCSS.LoadFromString(fmt.Sprintf(`
+			.desktop-bg {
+				background-image: url("%s");
+				background-repeat: %s;
+				background-position: %s;
+				background-size: auto;
+			}
+		`, fileURL, repeat, position))
+		return
+	}
+
+	d.bgPicture = gtk.NewPictureForFilename(path)
+	d.bgPicture.SetContentFit(contentFitForMode(mode))
+	d.bgPicture.SetHExpand(true)
+	d.bgPicture.SetVExpand(true)
+	d.bgBox.Append(d.bgPicture)
+}
+
+// watchSettings applies wallpaper and desktop icon changes made in
+// raven-settings-menu without restarting the desktop. raven-settingsd says
+// when settings.json changes; while it isn't running the file is polled.
+func (d *RavenDesktop) watchSettings() {
+	var lastMod time.Time
+	if info, err := os.Stat(d.settingsPath); err == nil {
+		lastMod = info.ModTime()
+	}
+
+	settingsd := false
+	err := watchSettingsChanges(func(keys []string) {
+		d.reloadSettings()
+	}, func(running bool) {
+		settingsd = running
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "raven-desktop: polling settings.json: %v\n", err)
+	}
+
+	glib.TimeoutSecondsAdd(settingsPollInterval, func() bool {
+		if settingsd {
+			return true
+		}
+		info, err := os.Stat(d.settingsPath)
+		if err != nil || !info.ModTime().After(lastMod) {
+			return true
+		}
+		lastMod = info.ModTime()
+		d.reloadSettings()
+		return true
+	})
+}
+
+// reloadSettings reads settings.json and applies the wallpaper and desktop
+// icon settings where they changed
+func (d *RavenDesktop) reloadSettings() {
+	data, err := os.ReadFile(d.settingsPath)
+	if err != nil {
+		return
+	}
+	if err := json.Unmarshal(data, &d.settings); err != nil {
+		return
+	}
+
+	if d.settings.ShowDesktopIcons != d.iconLayer.Visible() {
+		d.setShowDesktopIcons(d.settings.ShowDesktopIcons)
+	}
+
+	path := d.resolveWallpaper()
+	if path != d.appliedWallpaper || d.settings.WallpaperMode != d.appliedMode {
+		d.applyWallpaper(path, d.settings.WallpaperMode)
+	}
+}
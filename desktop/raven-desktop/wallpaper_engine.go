@@ -0,0 +1,358 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+	"unsafe"
+
+	"github.com/diamondburned/gotk4/pkg/gdk/v4"
+	"github.com/diamondburned/gotk4/pkg/glib/v2"
+	"github.com/diamondburned/gotk4/pkg/gtk/v4"
+)
+
+/*
+#cgo pkg-config: epoxy
+#include <epoxy/gl.h>
+#include <stdlib.h>
+
+static GLuint wallpaper_program;
+static GLuint wallpaper_vao;
+static GLint wallpaper_time_loc;
+static GLint wallpaper_res_loc;
+
+// Fullscreen triangle generated from gl_VertexID, no buffers needed
+static const char *wallpaper_vertex_src =
+    "#version 330 core\n"
+    "void main() {\n"
+    "    vec2 p = vec2((gl_VertexID << 1) & 2, gl_VertexID & 2);\n"
+    "    gl_Position = vec4(p * 2.0 - 1.0, 0.0, 1.0);\n"
+    "}\n";
+
+// Shaders use the Shadertoy convention: mainImage(out vec4, in vec2)
+// with iTime and iResolution uniforms
+static const char *wallpaper_fragment_prefix =
+    "#version 330 core\n"
+    "uniform float iTime;\n"
+    "uniform vec3 iResolution;\n"
+    "out vec4 ravenFragColor;\n"
+    "#line 1\n";
+
+static const char *wallpaper_fragment_suffix =
+    "\nvoid main() { mainImage(ravenFragColor, gl_FragCoord.xy); }\n";
+
+static GLuint wallpaper_compile(GLenum type, const char **src, int count, char *log, int log_len) {
+    GLuint shader = glCreateShader(type);
+    glShaderSource(shader, count, src, NULL);
+    glCompileShader(shader);
+
+    GLint ok = 0;
+    glGetShaderiv(shader, GL_COMPILE_STATUS, &ok);
+    if (!ok) {
+        glGetShaderInfoLog(shader, log_len, NULL, log);
+        glDeleteShader(shader);
+        return 0;
+    }
+    return shader;
+}
+
+// Returns 0 on success; on failure log holds the compiler output
+static int wallpaper_shader_init(const char *user_src, char *log, int log_len) {
+    const char *vsrc[] = { wallpaper_vertex_src };
+    const char *fsrc[] = { wallpaper_fragment_prefix, user_src, wallpaper_fragment_suffix };
+
+    GLuint vs = wallpaper_compile(GL_VERTEX_SHADER, vsrc, 1, log, log_len);
+    if (!vs) return -1;
+    GLuint fs = wallpaper_compile(GL_FRAGMENT_SHADER, fsrc, 3, log, log_len);
+    if (!fs) {
+        glDeleteShader(vs);
+        return -1;
+    }
+
+    wallpaper_program = glCreateProgram();
+    glAttachShader(wallpaper_program, vs);
+    glAttachShader(wallpaper_program, fs);
+    glLinkProgram(wallpaper_program);
+    glDeleteShader(vs);
+    glDeleteShader(fs);
+
+    GLint ok = 0;
+    glGetProgramiv(wallpaper_program, GL_LINK_STATUS, &ok);
+    if (!ok) {
+        glGetProgramInfoLog(wallpaper_program, log_len, NULL, log);
+        glDeleteProgram(wallpaper_program);
+        wallpaper_program = 0;
+        return -1;
+    }
+
+    wallpaper_time_loc = glGetUniformLocation(wallpaper_program, "iTime");
+    wallpaper_res_loc = glGetUniformLocation(wallpaper_program, "iResolution");
+    glGenVertexArrays(1, &wallpaper_vao);
+    return 0;
+}
+
+static void wallpaper_shader_render(float time, int width, int height) {
+    if (!wallpaper_program) return;
+    glViewport(0, 0, width, height);
+    glUseProgram(wallpaper_program);
+    glUniform1f(wallpaper_time_loc, time);
+    glUniform3f(wallpaper_res_loc, (float)width, (float)height, 1.0f);
+    glBindVertexArray(wallpaper_vao);
+    glDrawArrays(GL_TRIANGLES, 0, 3);
+}
+
+static void wallpaper_shader_free(void) {
+    if (wallpaper_vao) glDeleteVertexArrays(1, &wallpaper_vao);
+    if (wallpaper_program) glDeleteProgram(wallpaper_program);
+    wallpaper_vao = 0;
+    wallpaper_program = 0;
+}
+*/
+import "C"
+
+const (
+	// Frame interval for shader wallpapers (~30 fps)
+	shaderFrameInterval = 33
+
+	// How often the power source is checked for battery auto-pause
+	batteryPollInterval = 5
+)
+
+// Animated wallpaper kinds, detected by file extension
+const (
+	wallpaperImage  = "image"
+	wallpaperVideo  = "video"
+	wallpaperShader = "shader"
+)
+
+// wallpaperKind classifies a wallpaper file
+func wallpaperKind(path string) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".mp4", ".webm", ".mkv", ".mov", ".ogv":
+		return wallpaperVideo
+	case ".glsl", ".frag", ".shader":
+		return wallpaperShader
+	}
+	return wallpaperImage
+}
+
+// contentFitForMode maps a wallpaper mode to a Picture content fit. Center
+// and tile have no Picture equivalent and are handled by the caller.
+func contentFitForMode(mode string) gtk.ContentFit {
+	switch mode {
+	case "fit":
+		return gtk.ContentFitContain
+	case "stretch":
+		return gtk.ContentFitFill
+	}
+	return gtk.ContentFitCover
+}
+
+// showVideoWallpaper plays a muted, looping video as the background
+func (d *RavenDesktop) showVideoWallpaper(path, mode string) {
+	d.bgMedia = gtk.NewMediaFileForFilename(path)
+	d.bgMedia.SetLoop(true)
+	d.bgMedia.SetMuted(true)
+
+	d.bgPicture = gtk.NewPictureForPaintable(d.bgMedia)
+	d.bgPicture.SetContentFit(contentFitForMode(mode))
+	d.bgPicture.SetHExpand(true)
+	d.bgPicture.SetVExpand(true)
+	d.bgBox.Append(d.bgPicture)
+
+	if !d.wallpaperPaused {
+		d.bgMedia.Play()
+	}
+}
+
+// showShaderWallpaper renders a GLSL fragment shader as the background.
+// If the shader fails to compile the fallback image is shown instead.
+func (d *RavenDesktop) showShaderWallpaper(path string) {
+	src, err := os.ReadFile(path)
+	if err != nil {
+		d.showStaticWallpaper(fallbackWallpaper(), "fill")
+		return
+	}
+
+	area := gtk.NewGLArea()
+	area.SetHExpand(true)
+	area.SetVExpand(true)
+	d.bgShader = area
+
+	var elapsed float64
+	last := time.Now()
+
+	area.ConnectRealize(func() {
+		area.MakeCurrent()
+		if area.Error() != nil {
+			return
+		}
+
+		csrc := C.CString(string(src))
+		defer C.free(unsafe.Pointer(csrc))
+
+		log := make([]byte, 1024)
+		if C.wallpaper_shader_init(csrc, (*C.char)(unsafe.Pointer(&log[0])), C.int(len(log))) != 0 {
+			msg := strings.TrimRight(string(log), "\x00")
+			glib.IdleAdd(func() {
+				fmt.Fprintf(os.Stderr, "raven-desktop: shader wallpaper failed: %s\n", msg)
+				d.applyWallpaper(fallbackWallpaper(), "fill")
+			})
+		}
+	})
+
+	area.ConnectUnrealize(func() {
+		area.MakeCurrent()
+		if area.Error() == nil {
+			C.wallpaper_shader_free()
+		}
+	})
+
+	area.ConnectRender(func(context gdk.GLContexter) bool {
+		scale := area.ScaleFactor()
+		C.wallpaper_shader_render(C.float(elapsed), C.int(area.Width()*scale), C.int(area.Height()*scale))
+		return true
+	})
+
+	glib.TimeoutAdd(shaderFrameInterval, func() bool {
+		if d.bgShader != area {
+			return false
+		}
+		now := time.Now()
+		if !d.wallpaperPaused {
+			// Only advance while running so resuming continues smoothly
+			elapsed += now.Sub(last).Seconds()
+			area.QueueRender()
+		}
+		last = now
+		return true
+	})
+
+	d.bgBox.Append(area)
+}
+
+// stopAnimatedWallpaper tears down any running video or shader background
+func (d *RavenDesktop) stopAnimatedWallpaper() {
+	if d.bgMedia != nil {
+		d.bgMedia.Pause()
+		d.bgMedia = nil
+	}
+	if d.bgShader != nil {
+		d.bgBox.Remove(d.bgShader)
+		d.bgShader = nil
+	}
+}
+
+// setWallpaperPaused pauses or resumes an animated wallpaper
+func (d *RavenDesktop) setWallpaperPaused(paused bool) {
+	if d.wallpaperPaused == paused {
+		return
+	}
+	d.wallpaperPaused = paused
+
+	if d.bgMedia != nil {
+		if paused {
+			d.bgMedia.Pause()
+		} else {
+			d.bgMedia.Play()
+		}
+	}
+}
+
+// watchPowerSource pauses animated wallpapers while running on battery
+func (d *RavenDesktop) watchPowerSource() {
+	check := func() bool {
+		d.setWallpaperPaused(d.settings.PauseWallpaperOnBattery && onBattery())
+		return true
+	}
+	check()
+	glib.TimeoutSecondsAdd(batteryPollInterval, check)
+}
+
+// onBattery reports whether the system is running from a discharging battery
+func onBattery() bool {
+	supplies, _ := filepath.Glob("/sys/class/power_supply/*")
+
+	discharging := false
+	for _, supply := range supplies {
+		kind := readSysValue(filepath.Join(supply, "type"))
+		switch kind {
+		case "Mains", "USB":
+			if readSysValue(filepath.Join(supply, "online")) == "1" {
+				return false
+			}
+		case "Battery":
+			if readSysValue(filepath.Join(supply, "status")) == "Discharging" {
+				discharging = true
+			}
+		}
+	}
+	return discharging
+}
+
+func readSysValue(path string) string {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
+
+// softwareRendering reports whether GTK is likely rendering without a GPU
+func softwareRendering() bool {
+	return os.Getenv("LIBGL_ALWAYS_SOFTWARE") == "1" || os.Getenv("GSK_RENDERER") == "cairo"
+}
+
+// confirmAnimatedWallpaper warns about the cost of an animated wallpaper
+// before applying it
+func (d *RavenDesktop) confirmAnimatedWallpaper(path string) {
+	dialog := gtk.NewDialog()
+	dialog.SetTitle("Animated Wallpaper")
+	dialog.SetTransientFor(d.window)
+	dialog.SetModal(true)
+
+	content := dialog.ContentArea()
+	content.SetMarginTop(16)
+	content.SetMarginBottom(16)
+	content.SetMarginStart(16)
+	content.SetMarginEnd(16)
+	content.SetSpacing(12)
+
+	icon := gtk.NewImageFromIconName("dialog-warning-symbolic")
+	icon.SetPixelSize(48)
+	content.Append(icon)
+
+	message := "Animated wallpapers keep the GPU busy and can shorten battery life."
+	if d.settings.PauseWallpaperOnBattery {
+		message += " They pause automatically while on battery power."
+	}
+	if softwareRendering() {
+		message += "\n\nSoftware rendering is active, so the desktop may become sluggish."
+	}
+	label := gtk.NewLabel(message)
+	label.SetWrap(true)
+	label.SetMaxWidthChars(48)
+	content.Append(label)
+
+	buttonBox := gtk.NewBox(gtk.OrientationHorizontal, 8)
+	buttonBox.SetHAlign(gtk.AlignEnd)
+	buttonBox.SetMarginTop(16)
+
+	cancelBtn := gtk.NewButton()
+	cancelBtn.SetLabel("Cancel")
+	cancelBtn.ConnectClicked(func() { dialog.Destroy() })
+	buttonBox.Append(cancelBtn)
+
+	useBtn := gtk.NewButton()
+	useBtn.SetLabel("Use Anyway")
+	useBtn.ConnectClicked(func() {
+		dialog.Destroy()
+		d.setWallpaper(path)
+	})
+	buttonBox.Append(useBtn)
+
+	content.Append(buttonBox)
+	dialog.Present()
+}
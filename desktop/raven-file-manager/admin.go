@@ -0,0 +1,86 @@
+package main
+
+import (
+	"errors"
+
+	"raven-file-manager/pkg/admin"
+	"raven-file-manager/pkg/fileview"
+
+	"github.com/diamondburned/gotk4/pkg/glib/v2"
+	"github.com/diamondburned/gotk4/pkg/gtk/v4"
+)
+
+// canRetryAsAdmin reports whether an operation on paths that failed with
+// err can be run again as administrator. Servers are left out, as root
+// has no connection to them.
+func canRetryAsAdmin(err error, paths ...string) bool {
+	if !admin.IsPermission(err) || !admin.Available() {
+		return false
+	}
+	for _, p := range paths {
+		if fileview.IsRemote(p) {
+			return false
+		}
+	}
+	return true
+}
+
+// showAdminRetry says an operation was refused and offers to run retry,
+// which asks for the administrator password, with the action button
+// labeled button
+func (fm *FileManager) showAdminRetry(message, button string, retry func() error) {
+	dialog := gtk.NewDialog()
+	dialog.SetTitle("Permission Denied")
+	dialog.SetTransientFor(fm.window)
+	dialog.SetModal(true)
+	dialog.SetDefaultSize(400, -1)
+
+	content := dialog.ContentArea()
+	content.SetMarginTop(16)
+	content.SetMarginBottom(16)
+	content.SetMarginStart(16)
+	content.SetMarginEnd(16)
+	content.SetSpacing(12)
+
+	icon := gtk.NewImageFromIconName("dialog-password-symbolic")
+	icon.SetPixelSize(48)
+	content.Append(icon)
+
+	label := gtk.NewLabel(message)
+	label.SetWrap(true)
+	content.Append(label)
+
+	hint := gtk.NewLabel("You can try again as administrator; you will be asked for the administrator password.")
+	hint.AddCSSClass("dim-label")
+	hint.SetWrap(true)
+	content.Append(hint)
+
+	buttonBox := gtk.NewBox(gtk.OrientationHorizontal, 8)
+	buttonBox.SetHAlign(gtk.AlignEnd)
+	buttonBox.SetMarginTop(16)
+
+	cancelBtn := gtk.NewButton()
+	cancelBtn.SetLabel("Cancel")
+	cancelBtn.AddCSSClass("cancel")
+	cancelBtn.ConnectClicked(func() { dialog.Destroy() })
+	buttonBox.Append(cancelBtn)
+
+	retryBtn := gtk.NewButton()
+	retryBtn.SetLabel(button)
+	retryBtn.ConnectClicked(func() {
+		dialog.Destroy()
+		go func() {
+			err := retry()
+			glib.IdleAdd(func() {
+				fm.refreshAll()
+				if err != nil && !errors.Is(err, admin.ErrDismissed) {
+					fm.showError("Failed as administrator: " + err.Error())
+				}
+			})
+		}()
+	})
+	buttonBox.Append(retryBtn)
+
+	content.Append(buttonBox)
+	dialog.Present()
+}
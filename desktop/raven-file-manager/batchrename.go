@@ -0,0 +1,210 @@
+package main
+
+import (
+	"fmt"
+
+	"raven-file-manager/pkg/fileview"
+	"raven-file-manager/pkg/rename"
+
+	"github.com/diamondburned/gotk4/pkg/gtk/v4"
+)
+
+// showBatchRenameDialog renames several files at once with find and
+// replace, a numbering pattern and a case transform, previewing every new
+// name before anything is renamed
+func (fm *FileManager) showBatchRenameDialog(files []fileview.FileEntry) {
+	dialog := gtk.NewDialog()
+	dialog.SetTitle(fmt.Sprintf("Rename %d Items", len(files)))
+	dialog.SetTransientFor(fm.window)
+	dialog.SetModal(true)
+	dialog.SetDefaultSize(560, 560)
+
+	content := dialog.ContentArea()
+	content.SetMarginTop(16)
+	content.SetMarginBottom(16)
+	content.SetMarginStart(16)
+	content.SetMarginEnd(16)
+	content.SetSpacing(12)
+
+	grid := gtk.NewGrid()
+	grid.SetRowSpacing(8)
+	grid.SetColumnSpacing(12)
+	addRow := func(row int, title string, widget gtk.Widgetter) {
+		label := gtk.NewLabel(title)
+		label.SetHAlign(gtk.AlignStart)
+		grid.Attach(label, 0, row, 1, 1)
+		grid.Attach(widget, 1, row, 1, 1)
+	}
+
+	findEntry := gtk.NewEntry()
+	findEntry.SetHExpand(true)
+	addRow(0, "Find:", findEntry)
+
+	replaceEntry := gtk.NewEntry()
+	addRow(1, "Replace with:", replaceEntry)
+
+	options := gtk.NewBox(gtk.OrientationHorizontal, 12)
+	regexCheck := gtk.NewCheckButtonWithLabel("Regular expression")
+	options.Append(regexCheck)
+	ignoreCaseCheck := gtk.NewCheckButtonWithLabel("Ignore case")
+	options.Append(ignoreCaseCheck)
+	grid.Attach(options, 1, 2, 1, 1)
+
+	patternEntry := gtk.NewEntry()
+	patternEntry.SetPlaceholderText("{name}")
+	patternEntry.SetTooltipText("{name} name, {n} number, {n:3} number padded to 3 digits, " +
+		"{date} modification date, {ext} extension. The extension is kept.")
+	addRow(3, "Pattern:", patternEntry)
+
+	startSpin := gtk.NewSpinButtonWithRange(0, 1000000, 1)
+	startSpin.SetValue(1)
+	startSpin.SetHAlign(gtk.AlignStart)
+	addRow(4, "Number from:", startSpin)
+
+	caseDropdown := gtk.NewDropDown(gtk.NewStringList(rename.Cases), nil)
+	caseDropdown.SetHAlign(gtk.AlignStart)
+	addRow(5, "Case:", caseDropdown)
+
+	content.Append(grid)
+
+	// Old and new name of every file
+	header := gtk.NewBox(gtk.OrientationHorizontal, 8)
+	header.SetHomogeneous(true)
+	header.SetMarginStart(6)
+	header.SetMarginEnd(6)
+	for _, title := range []string{"Current Name", "New Name"} {
+		label := gtk.NewLabel(title)
+		label.AddCSSClass("dim-label")
+		label.SetHAlign(gtk.AlignStart)
+		header.Append(label)
+	}
+	content.Append(header)
+
+	preview := gtk.NewListBox()
+	preview.SetSelectionMode(gtk.SelectionNone)
+	preview.AddCSSClass("rename-preview")
+	scroll := gtk.NewScrolledWindow()
+	scroll.SetPolicy(gtk.PolicyNever, gtk.PolicyAutomatic)
+	scroll.SetVExpand(true)
+	scroll.SetChild(preview)
+	content.Append(scroll)
+
+	summary := gtk.NewLabel("")
+	summary.AddCSSClass("dim-label")
+	summary.SetHAlign(gtk.AlignStart)
+	content.Append(summary)
+
+	buttonBox := gtk.NewBox(gtk.OrientationHorizontal, 8)
+	buttonBox.SetHAlign(gtk.AlignEnd)
+	buttonBox.SetMarginTop(16)
+
+	cancelBtn := gtk.NewButton()
+	cancelBtn.SetLabel("Cancel")
+	cancelBtn.AddCSSClass("cancel")
+	cancelBtn.ConnectClicked(func() { dialog.Destroy() })
+	buttonBox.Append(cancelBtn)
+
+	renameBtn := gtk.NewButton()
+	renameBtn.SetLabel("Rename")
+	buttonBox.Append(renameBtn)
+	content.Append(buttonBox)
+
+	var changes []rename.Change
+	update := func() {
+		rules := rename.Rules{
+			Find:       findEntry.Text(),
+			Replace:    replaceEntry.Text(),
+			Regex:      regexCheck.Active(),
+			IgnoreCase: ignoreCaseCheck.Active(),
+			Pattern:    patternEntry.Text(),
+			Start:      startSpin.ValueAsInt(),
+			Case:       rename.Case(caseDropdown.Selected()),
+		}
+
+		var err error
+		changes, err = rename.Plan(files, rules)
+		if err != nil {
+			changes = nil
+			summary.SetText(err.Error())
+			renameBtn.SetSensitive(false)
+			return
+		}
+
+		for child := preview.FirstChild(); child != nil; child = preview.FirstChild() {
+			preview.Remove(child)
+		}
+		renamed, problems := 0, 0
+		for _, c := range changes {
+			preview.Append(createRenameRow(c))
+			if c.Renamed() {
+				renamed++
+			}
+			if c.Problem != "" {
+				problems++
+			}
+		}
+
+		switch {
+		case problems > 0:
+			summary.SetText(fileview.Pluralize(problems, "name", "names") + " can't be used")
+		case renamed == 0:
+			summary.SetText("No names change")
+		default:
+			summary.SetText(fmt.Sprintf("%s of %d will be renamed", fileview.Pluralize(renamed, "item", "items"), len(changes)))
+		}
+		renameBtn.SetSensitive(problems == 0 && renamed > 0)
+	}
+	update()
+
+	findEntry.ConnectChanged(update)
+	replaceEntry.ConnectChanged(update)
+	regexCheck.ConnectToggled(update)
+	ignoreCaseCheck.ConnectToggled(update)
+	patternEntry.ConnectChanged(update)
+	startSpin.ConnectValueChanged(update)
+	caseDropdown.Connect("notify::selected", update)
+
+	renameBtn.ConnectClicked(func() {
+		err := rename.Apply(changes)
+		dialog.Destroy()
+		fm.refreshAll()
+		if err != nil {
+			fm.showError("Failed to rename: " + err.Error())
+		}
+	})
+
+	dialog.Present()
+	findEntry.GrabFocus()
+}
+
+// createRenameRow shows a file's current and new name in the batch rename
+// preview, with why the new name can't be used
+func createRenameRow(c rename.Change) *gtk.ListBoxRow {
+	row := gtk.NewListBoxRow()
+	box := gtk.NewBox(gtk.OrientationHorizontal, 8)
+	box.SetMarginTop(4)
+	box.SetMarginBottom(4)
+	box.SetMarginStart(6)
+	box.SetMarginEnd(6)
+	box.SetHomogeneous(true)
+
+	oldName := gtk.NewLabel(c.Entry.Name)
+	oldName.SetHAlign(gtk.AlignStart)
+	oldName.SetEllipsize(3)
+	box.Append(oldName)
+
+	newName := gtk.NewLabel(c.NewName)
+	newName.SetHAlign(gtk.AlignStart)
+	newName.SetEllipsize(3)
+	switch {
+	case c.Problem != "":
+		newName.SetText(fmt.Sprintf("%s (%s)", c.NewName, c.Problem))
+		newName.AddCSSClass("rename-problem")
+	case !c.Renamed():
+		newName.AddCSSClass("dim-label")
+	}
+	box.Append(newName)
+
+	row.SetChild(box)
+	return row
+}
@@ -0,0 +1,218 @@
+package main
+
+import (
+	"raven-file-manager/pkg/config"
+
+	"github.com/diamondburned/gotk4/pkg/gio/v2"
+	"github.com/diamondburned/gotk4/pkg/glib/v2"
+	"github.com/diamondburned/gotk4/pkg/gtk/v4"
+)
+
+// How often the GTK bookmarks file is checked for changes by other apps
+const bookmarksPollSeconds = 3
+
+// watchBookmarks shows the bookmarks in Places, first bringing in those
+// added in other GTK apps, and keeps them in step with the GTK bookmarks
+// file while running
+func (fm *FileManager) watchBookmarks() {
+	config.SyncGTKBookmarks(&fm.settings)
+	fm.bookmarksModTime = config.GTKBookmarksModTime()
+	fm.showBookmarks()
+
+	glib.TimeoutSecondsAdd(bookmarksPollSeconds, func() bool {
+		if modTime := config.GTKBookmarksModTime(); !modTime.Equal(fm.bookmarksModTime) {
+			fm.bookmarksModTime = modTime
+			if config.SyncGTKBookmarks(&fm.settings) {
+				fm.showBookmarks()
+			}
+		}
+		return true
+	})
+}
+
+// showBookmarks (re)fills Places with the bookmarks, keeping the Trash last
+func (fm *FileManager) showBookmarks() {
+	for row := fm.sidebarList.RowAtIndex(0); row != nil && row != fm.trashRow; row = fm.sidebarList.RowAtIndex(0) {
+		fm.sidebarList.Remove(row)
+	}
+	for i, bookmark := range fm.settings.Bookmarks {
+		row := fm.createSidebarRow(bookmark.Name, bookmark.Icon)
+		row.SetTooltipText(bookmark.Path)
+		fm.sidebarList.Insert(row, i)
+	}
+}
+
+// saveBookmarks stores changed bookmarks and shows them
+func (fm *FileManager) saveBookmarks() {
+	err := config.SaveBookmarks(&fm.settings)
+	fm.bookmarksModTime = config.GTKBookmarksModTime()
+	fm.showBookmarks()
+	if err != nil {
+		fm.showError("Could not save bookmarks: " + err.Error())
+	}
+}
+
+// bookmarkIndex returns where path is in the bookmarks, or -1
+func (fm *FileManager) bookmarkIndex(path string) int {
+	for i, bookmark := range fm.settings.Bookmarks {
+		if bookmark.Path == path {
+			return i
+		}
+	}
+	return -1
+}
+
+// addBookmark adds path to the end of Places, unless it is there already
+func (fm *FileManager) addBookmark(path string) {
+	if i := fm.bookmarkIndex(path); i >= 0 {
+		fm.sidebarList.SelectRow(fm.sidebarList.RowAtIndex(i))
+		return
+	}
+	fm.settings.Bookmarks = append(fm.settings.Bookmarks, config.NewBookmark(path))
+	fm.saveBookmarks()
+}
+
+// bookmarkCurrentFolder adds the folder being shown to Places
+func (fm *FileManager) bookmarkCurrentFolder() {
+	if fm.inTrash() || fm.searchActive {
+		return
+	}
+	fm.addBookmark(fm.currentPath)
+}
+
+// moveBookmark moves the bookmark at i by delta places
+func (fm *FileManager) moveBookmark(i, delta int) {
+	j := i + delta
+	if j < 0 || j >= len(fm.settings.Bookmarks) {
+		return
+	}
+	b := fm.settings.Bookmarks
+	b[i], b[j] = b[j], b[i]
+	fm.saveBookmarks()
+	fm.sidebarList.SelectRow(fm.sidebarList.RowAtIndex(j))
+}
+
+// removeBookmark takes the bookmark at i out of Places. The folder itself
+// is left alone.
+func (fm *FileManager) removeBookmark(i int) {
+	fm.settings.Bookmarks = append(fm.settings.Bookmarks[:i], fm.settings.Bookmarks[i+1:]...)
+	fm.saveBookmarks()
+}
+
+// attachBookmarkMenu shows the bookmark menu on right-click in Places
+func (fm *FileManager) attachBookmarkMenu() {
+	gesture := gtk.NewGestureClick()
+	gesture.SetButton(3)
+	gesture.ConnectPressed(func(nPress int, x, y float64) {
+		row := fm.sidebarList.RowAtY(int(y))
+		if row == nil {
+			return
+		}
+		fm.showBookmarkMenu(row, row.Index())
+	})
+	fm.sidebarList.AddController(gesture)
+}
+
+// showBookmarkMenu pops up the actions for the bookmark at i. The Trash
+// row only offers to bookmark the current folder.
+func (fm *FileManager) showBookmarkMenu(row *gtk.ListBoxRow, i int) {
+	menu := gio.NewMenu()
+
+	if i >= 0 && i < len(fm.settings.Bookmarks) {
+		bookmark := fm.settings.Bookmarks[i]
+
+		open := gio.NewMenu()
+		open.Append("Open", "app.bookmark-open")
+		open.Append("Open in New Tab", "app.bookmark-open-tab")
+		menu.AppendSection("", open)
+
+		edit := gio.NewMenu()
+		edit.Append("Rename...", "app.bookmark-rename")
+		if i > 0 {
+			edit.Append("Move Up", "app.bookmark-up")
+		}
+		if i < len(fm.settings.Bookmarks)-1 {
+			edit.Append("Move Down", "app.bookmark-down")
+		}
+		edit.Append("Remove", "app.bookmark-remove")
+		menu.AppendSection("", edit)
+
+		fm.addMenuAction("bookmark-open", func() { fm.openAddress(bookmark.Path) })
+		fm.addMenuAction("bookmark-open-tab", func() { fm.openTab(bookmark.Path) })
+		fm.addMenuAction("bookmark-rename", func() { fm.showRenameBookmarkDialog(i) })
+		fm.addMenuAction("bookmark-up", func() { fm.moveBookmark(i, -1) })
+		fm.addMenuAction("bookmark-down", func() { fm.moveBookmark(i, 1) })
+		fm.addMenuAction("bookmark-remove", func() { fm.removeBookmark(i) })
+	}
+
+	if !fm.inTrash() && !fm.searchActive && fm.bookmarkIndex(fm.currentPath) < 0 {
+		add := gio.NewMenu()
+		add.Append("Bookmark Current Folder", "app.bookmark-add")
+		menu.AppendSection("", add)
+		fm.addMenuAction("bookmark-add", fm.bookmarkCurrentFolder)
+	}
+
+	if menu.NItems() == 0 {
+		return
+	}
+	popover := gtk.NewPopoverMenuFromModel(menu)
+	popover.SetParent(row)
+	popover.SetHasArrow(false)
+	popover.Popup()
+}
+
+// showRenameBookmarkDialog changes the name shown for the bookmark at i
+func (fm *FileManager) showRenameBookmarkDialog(i int) {
+	dialog := gtk.NewDialog()
+	dialog.SetTitle("Rename Bookmark")
+	dialog.SetTransientFor(fm.window)
+	dialog.SetModal(true)
+	dialog.SetDefaultSize(400, -1)
+
+	content := dialog.ContentArea()
+	content.SetMarginTop(16)
+	content.SetMarginBottom(16)
+	content.SetMarginStart(16)
+	content.SetMarginEnd(16)
+	content.SetSpacing(12)
+
+	label := gtk.NewLabel("Bookmark name:")
+	label.SetHAlign(gtk.AlignStart)
+	content.Append(label)
+
+	entry := gtk.NewEntry()
+	entry.SetText(fm.settings.Bookmarks[i].Name)
+	entry.SelectRegion(0, -1)
+	content.Append(entry)
+
+	buttonBox := gtk.NewBox(gtk.OrientationHorizontal, 8)
+	buttonBox.SetHAlign(gtk.AlignEnd)
+	buttonBox.SetMarginTop(16)
+
+	cancelBtn := gtk.NewButton()
+	cancelBtn.SetLabel("Cancel")
+	cancelBtn.AddCSSClass("cancel")
+	cancelBtn.ConnectClicked(func() { dialog.Destroy() })
+	buttonBox.Append(cancelBtn)
+
+	// The bookmarks may be synced from the GTK file while the dialog is open
+	path := fm.settings.Bookmarks[i].Path
+	rename := func() {
+		if j := fm.bookmarkIndex(path); entry.Text() != "" && j >= 0 {
+			fm.settings.Bookmarks[j].Name = entry.Text()
+			fm.saveBookmarks()
+		}
+		dialog.Destroy()
+	}
+
+	renameBtn := gtk.NewButton()
+	renameBtn.SetLabel("Rename")
+	renameBtn.ConnectClicked(rename)
+	buttonBox.Append(renameBtn)
+
+	content.Append(buttonBox)
+	entry.ConnectActivate(rename)
+
+	dialog.Present()
+	entry.GrabFocus()
+}
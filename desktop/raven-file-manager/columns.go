@@ -0,0 +1,86 @@
+package main
+
+import (
+	"raven-file-manager/pkg/config"
+
+	"github.com/diamondburned/gotk4/pkg/gtk/v4"
+)
+
+// listColumn is a column of the list view, lined up with the labels of
+// createFileListRow by sharing their CSS class and width
+type listColumn struct {
+	title  string
+	sortBy string // Empty for columns that can't be sorted
+	class  string
+	width  int
+}
+
+// listColumns returns the columns after Name that are shown
+func (fm *FileManager) listColumns() []listColumn {
+	columns := []listColumn{
+		{title: "Size", sortBy: "size", class: "file-size", width: 10},
+		{title: "Type", sortBy: "type", class: "file-type", width: 16},
+		{title: "Modified", sortBy: "date", class: "file-date", width: 12},
+	}
+	if fm.settings.ShowOwner {
+		columns = append(columns, listColumn{title: "Owner", class: "file-owner", width: 10})
+	}
+	if fm.settings.ShowPermissions {
+		columns = append(columns, listColumn{title: "Permissions", class: "file-permissions"})
+	}
+	return columns
+}
+
+// updateColumnHeader rebuilds p's column headers for dir, marking the
+// column its files are sorted by. Clicking a header sorts by it, and
+// clicking it again reverses the order.
+func (fm *FileManager) updateColumnHeader(p *pane, dir string) {
+	for child := p.columnHeader.FirstChild(); child != nil; child = p.columnHeader.FirstChild() {
+		p.columnHeader.Remove(child)
+	}
+
+	current := config.FolderSort(&fm.settings, dir)
+	addCell := func(title, sortBy string) *gtk.Label {
+		label := gtk.NewLabel(title)
+		label.AddCSSClass("column-header-cell")
+		if sortBy == "" {
+			return label
+		}
+		if sortBy == current.By {
+			label.AddCSSClass("column-header-sorted")
+			if current.Descending {
+				label.SetText(title + " ▼")
+			} else {
+				label.SetText(title + " ▲")
+			}
+		}
+		click := gtk.NewGestureClick()
+		click.SetButton(1)
+		click.ConnectReleased(func(nPress int, x, y float64) {
+			sort := config.Sort{By: sortBy}
+			if sortBy == current.By {
+				sort.Descending = !current.Descending
+			}
+			config.SetFolderSort(&fm.settings, dir, sort)
+			fm.inPane(p, fm.refresh)
+		})
+		label.AddController(click)
+		return label
+	}
+
+	// Past the file icon
+	name := addCell("Name", "name")
+	name.SetHAlign(gtk.AlignStart)
+	name.SetHExpand(true)
+	name.SetMarginStart(28)
+	p.columnHeader.Append(name)
+
+	for _, column := range fm.listColumns() {
+		label := addCell(column.title, column.sortBy)
+		label.AddCSSClass(column.class)
+		if column.width > 0 {
+			label.SetWidthChars(column.width)
+		}
+		p.columnHeader.Append(label)
+	}
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/diamondburned/gotk4/pkg/glib/v2"
+	"github.com/diamondburned/gotk4/pkg/gtk/v4"
+
+	"raven-file-manager/pkg/config"
+	"raven-file-manager/pkg/fileview"
+	"raven-file-manager/pkg/icons"
+	"raven-file-manager/pkg/search"
+)
+
+// Editors that run inside a terminal
+var terminalEditors = map[string]bool{
+	"vi": true, "vim": true, "nvim": true, "nano": true,
+	"micro": true, "emacs": true, "kak": true, "hx": true,
+}
+
+// showContentResults lists the matches of a content search, one row per
+// matching line, which opens the file at that line when activated
+func (fm *FileManager) showContentResults(results []search.Result) {
+	entries := make([]fileview.FileEntry, len(results))
+	rows := make([]*gtk.ListBoxRow, len(results))
+	for i, r := range results {
+		entries[i] = r.Entry
+		rows[i] = fm.createContentResultRow(r)
+	}
+
+	fm.setFileList(entries, rows, func(idx int) {
+		fm.openAtLine(results[idx].Entry.Path, results[idx].LineNum)
+	})
+
+	if len(results) == 0 {
+		fm.statusLabel.SetText("No matches found")
+		return
+	}
+	files := make(map[string]bool)
+	for _, r := range results {
+		files[r.Entry.Path] = true
+	}
+	fm.statusLabel.SetText(fmt.Sprintf("%s in %s",
+		fileview.Pluralize(len(results), "match", "matches"),
+		fileview.Pluralize(len(files), "file", "files")))
+}
+
+func (fm *FileManager) createContentResultRow(r search.Result) *gtk.ListBoxRow {
+	row := gtk.NewListBoxRow()
+	row.AddCSSClass("file-row")
+	row.SetActivatable(true)
+
+	box := gtk.NewBox(gtk.OrientationVertical, 2)
+	box.SetMarginStart(8)
+	box.SetMarginEnd(8)
+	box.SetMarginTop(6)
+	box.SetMarginBottom(6)
+
+	header := gtk.NewBox(gtk.OrientationHorizontal, 8)
+	header.Append(icons.NewImage(fileview.GetFileIcon(r.Entry), 16))
+
+	name := r.Entry.Path
+	if rel, err := filepath.Rel(fm.currentPath, r.Entry.Path); err == nil {
+		name = rel
+	}
+	nameLabel := gtk.NewLabel(name)
+	nameLabel.AddCSSClass("file-name")
+	nameLabel.SetHAlign(gtk.AlignStart)
+	nameLabel.SetEllipsize(1) // PANGO_ELLIPSIZE_START
+	header.Append(nameLabel)
+
+	lineLabel := gtk.NewLabel(":" + strconv.Itoa(r.LineNum))
+	lineLabel.AddCSSClass("content-line-number")
+	header.Append(lineLabel)
+	box.Append(header)
+
+	for i, line := range r.Before {
+		box.Append(contentLine(r.LineNum-len(r.Before)+i, glib.MarkupEscapeText(line), "content-context"))
+	}
+	box.Append(contentLine(r.LineNum, highlightMatches(r.Context, r.Indices), "content-match"))
+	for i, line := range r.After {
+		box.Append(contentLine(r.LineNum+1+i, glib.MarkupEscapeText(line), "content-context"))
+	}
+
+	row.SetChild(box)
+	return row
+}
+
+// contentLine returns a numbered line of a result, given as Pango markup
+func contentLine(num int, markup, class string) *gtk.Box {
+	box := gtk.NewBox(gtk.OrientationHorizontal, 8)
+
+	numLabel := gtk.NewLabel(strconv.Itoa(num))
+	numLabel.AddCSSClass("content-line-number")
+	numLabel.SetWidthChars(5)
+	numLabel.SetXAlign(1)
+	box.Append(numLabel)
+
+	textLabel := gtk.NewLabel("")
+	textLabel.SetMarkup(markup)
+	textLabel.AddCSSClass(class)
+	textLabel.SetHAlign(gtk.AlignStart)
+	textLabel.SetEllipsize(3) // PANGO_ELLIPSIZE_END
+	box.Append(textLabel)
+
+	return box
+}
+
+// highlightMatches escapes line for Pango and highlights the matches given
+// by indices, as start/end pairs
+func highlightMatches(line string, indices []int) string {
+	var b strings.Builder
+	last := 0
+	for i := 0; i+1 < len(indices); i += 2 {
+		start, end := indices[i], indices[i+1]
+		if start < last || end > len(line) {
+			continue
+		}
+		b.WriteString(glib.MarkupEscapeText(line[last:start]))
+		b.WriteString(`<span background="#00968855" foreground="#4db6ac" weight="bold">`)
+		b.WriteString(glib.MarkupEscapeText(line[start:end]))
+		b.WriteString("</span>")
+		last = end
+	}
+	b.WriteString(glib.MarkupEscapeText(line[last:]))
+	return b.String()
+}
+
+// openAtLine opens path in the configured editor with the cursor on line
+func (fm *FileManager) openAtLine(path string, line int) {
+	config.AddRecentFile(&fm.settings, path)
+
+	command := editorCommand(fm.settings.Editor, path, line)
+	go func() {
+		if err := exec.Command("sh", "-c", command).Start(); err != nil {
+			fmt.Fprintf(os.Stderr, "raven-files: failed to open %s: %v\n", path, err)
+		}
+	}()
+}
+
+// editorCommand returns the shell command opening file at line. template
+// is the editor setting, where {file} and {line} are filled in; without
+// {file}, "+line file" is appended as most editors accept. With no
+// setting $VISUAL or $EDITOR is used, and failing those xdg-open, which
+// cannot go to the line.
+func editorCommand(template, file string, line int) string {
+	lineStr := strconv.Itoa(line)
+	quoted := shellQuote(file)
+
+	if template != "" {
+		if !strings.Contains(template, "{file}") {
+			template += " +{line} {file}"
+		}
+		return strings.NewReplacer("{file}", quoted, "{line}", lineStr).Replace(template)
+	}
+
+	editor := os.Getenv("VISUAL")
+	if editor == "" {
+		editor = os.Getenv("EDITOR")
+	}
+	fields := strings.Fields(editor)
+	if len(fields) == 0 {
+		return "xdg-open " + quoted
+	}
+
+	switch name := filepath.Base(fields[0]); {
+	case name == "code" || name == "codium":
+		return editor + " --goto " + shellQuote(file+":"+lineStr)
+	case terminalEditors[name]:
+		return "raven-terminal -e " + shellQuote(editor+" +"+lineStr+" "+quoted)
+	default:
+		return editor + " +" + lineStr + " " + quoted
+	}
+}
+
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
@@ -0,0 +1,270 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"raven-file-manager/pkg/clipboard"
+	"raven-file-manager/pkg/fileview"
+
+	"github.com/diamondburned/gotk4/pkg/gdk/v4"
+	"github.com/diamondburned/gotk4/pkg/gio/v2"
+	"github.com/diamondburned/gotk4/pkg/glib/v2"
+	"github.com/diamondburned/gotk4/pkg/gtk/v4"
+)
+
+// fileAction is a context menu entry for some kinds of files, shown below
+// the standard entries
+type fileAction struct {
+	name  string // Action name, activated as app.<name>
+	label string
+	match func(entry fileview.FileEntry) bool
+	run   func(fm *FileManager, entry fileview.FileEntry)
+}
+
+// fileActions are offered for files they match
+var fileActions = []fileAction{
+	{name: "write-usb", label: "Write to USB...", match: isISO, run: writeToUSB},
+	{name: "verify-iso", label: "Verify ISO checksum", match: isISO, run: verifyISO},
+}
+
+func isISO(entry fileview.FileEntry) bool {
+	return !entry.IsDir && strings.EqualFold(filepath.Ext(entry.Name), ".iso")
+}
+
+// attachContextMenu shows the file context menu on right-click in list
+func (fm *FileManager) attachContextMenu(list *gtk.ListBox) {
+	gesture := gtk.NewGestureClick()
+	gesture.SetButton(3)
+	gesture.ConnectPressed(func(nPress int, x, y float64) {
+		row := list.RowAtY(int(y))
+		if row == nil {
+			if !fm.inTrash() && !fm.searchActive {
+				list.UnselectAll()
+				fm.showFolderContextMenu(list, x, y)
+			}
+			return
+		}
+		idx := row.Index()
+		if idx < 0 || idx >= len(fm.currentFiles) {
+			return
+		}
+
+		// Right-clicking outside the selection selects just that row
+		if !row.IsSelected() {
+			list.UnselectAll()
+			list.SelectRow(row)
+		}
+		if fm.inTrash() {
+			fm.showTrashContextMenu(row)
+			return
+		}
+		fm.showFileContextMenu(row, fm.currentFiles[idx])
+	})
+	list.AddController(gesture)
+}
+
+// showFileContextMenu pops up the actions for the selection, with entry
+// being the row that was clicked
+func (fm *FileManager) showFileContextMenu(row *gtk.ListBoxRow, entry fileview.FileEntry) {
+	menu := gio.NewMenu()
+
+	standard := gio.NewMenu()
+	standard.Append("Open", "app.file-open")
+	if entry.IsSymlink && !fileview.IsRemote(entry.Path) {
+		standard.Append("Go to Link Target", "app.file-link-target")
+	}
+	if entry.IsDir {
+		standard.Append("Open in New Tab", "app.file-open-tab")
+		if fm.bookmarkIndex(entry.Path) < 0 {
+			standard.Append("Add to Bookmarks", "app.file-bookmark")
+		}
+		if !fileview.IsRemote(entry.Path) {
+			standard.Append("Open Terminal Here", "app.file-terminal")
+		}
+	} else if !fileview.IsRemote(entry.Path) {
+		standard.AppendSubmenu("Open With", fm.openWithMenu(entry))
+	}
+	standard.Append("Cut", "app.file-cut")
+	standard.Append("Copy", "app.file-copy")
+	standard.Append("Rename...", "app.file-rename")
+	// Servers have no trash
+	if fileview.IsRemote(entry.Path) {
+		standard.Append("Delete Permanently", "app.file-delete")
+	} else {
+		standard.Append("Move to Trash", "app.file-trash")
+	}
+	menu.AppendSection("", standard)
+
+	if fm.otherPane() != nil {
+		other := gio.NewMenu()
+		other.Append("Copy to Other Pane", "app.file-copy-pane")
+		other.Append("Move to Other Pane", "app.file-move-pane")
+		menu.AppendSection("", other)
+		fm.addMenuAction("file-copy-pane", func() {
+			fm.transferToOtherPane(clipboard.OpCopy)
+		})
+		fm.addMenuAction("file-move-pane", func() {
+			fm.transferToOtherPane(clipboard.OpCut)
+		})
+	}
+
+	fm.addMenuAction("file-open", fm.openSelected)
+	fm.addMenuAction("file-open-tab", func() {
+		fm.openTab(entry.Path)
+	})
+	fm.addMenuAction("file-bookmark", func() {
+		fm.addBookmark(entry.Path)
+	})
+	fm.addMenuAction("file-terminal", func() {
+		fm.openTerminal(entry.Path)
+	})
+	fm.addMenuAction("file-link-target", func() {
+		fm.goToLinkTarget(entry)
+	})
+	fm.addMenuAction("file-cut", fm.cutSelected)
+	fm.addMenuAction("file-copy", fm.copySelected)
+	fm.addMenuAction("file-rename", fm.renameSelected)
+	fm.addMenuAction("file-trash", fm.trashSelected)
+	fm.addMenuAction("file-delete", fm.permanentDelete)
+
+	extra := gio.NewMenu()
+	for _, action := range fileActions {
+		if !action.match(entry) {
+			continue
+		}
+		action := action
+		extra.Append(action.label, "app."+action.name)
+		fm.addMenuAction(action.name, func() {
+			action.run(fm, entry)
+		})
+	}
+	if extra.NItems() > 0 {
+		menu.AppendSection("", extra)
+	}
+
+	properties := gio.NewMenu()
+	properties.Append("Properties", "app.file-properties")
+	menu.AppendSection("", properties)
+	fm.addMenuAction("file-properties", func() {
+		fm.showProperties(entry)
+	})
+
+	popover := gtk.NewPopoverMenuFromModel(menu)
+	popover.SetParent(row)
+	popover.SetHasArrow(false)
+	popover.Popup()
+}
+
+// showFolderContextMenu pops up the actions for the current folder at x, y
+// in list, for a right-click past its files
+func (fm *FileManager) showFolderContextMenu(list *gtk.ListBox, x, y float64) {
+	menu := gio.NewMenu()
+
+	paste := gio.NewMenu()
+	paste.Append("Paste", "app.folder-paste")
+	if !fileview.IsRemote(fm.currentPath) {
+		paste.Append("Make Link", "app.folder-link")
+	}
+	menu.AppendSection("", paste)
+
+	folder := gio.NewMenu()
+	folder.Append("New Folder...", "app.folder-new")
+	if !fileview.IsRemote(fm.currentPath) {
+		folder.Append("Open Terminal Here", "app.folder-terminal")
+	}
+	menu.AppendSection("", folder)
+
+	dir := fm.currentPath
+	fm.addMenuAction("folder-paste", fm.paste)
+	fm.addMenuAction("folder-link", fm.pasteLinks)
+	fm.addMenuAction("folder-new", fm.showNewFolderDialog)
+	fm.addMenuAction("folder-terminal", func() {
+		fm.openTerminal(dir)
+	})
+
+	popover := gtk.NewPopoverMenuFromModel(menu)
+	popover.SetParent(list)
+	rect := gdk.NewRectangle(int(x), int(y), 1, 1)
+	popover.SetPointingTo(&rect)
+	popover.SetHasArrow(false)
+	popover.Popup()
+}
+
+// addMenuAction (re)registers an app action run by a context menu entry
+func (fm *FileManager) addMenuAction(name string, run func()) {
+	action := gio.NewSimpleAction(name, nil)
+	action.ConnectActivate(func(v *glib.Variant) {
+		run()
+	})
+	fm.app.AddAction(action)
+}
+
+// writeToUSB opens raven-usb with the ISO already chosen. raven-usb needs
+// root, so it is started through pkexec when that is available, keeping
+// the Wayland session variables pkexec would otherwise drop.
+func writeToUSB(fm *FileManager, entry fileview.FileEntry) {
+	usb, err := exec.LookPath("raven-usb")
+	if err != nil {
+		fm.showError("raven-usb is not installed")
+		return
+	}
+
+	var cmd *exec.Cmd
+	if pkexec, err := exec.LookPath("pkexec"); err == nil && os.Geteuid() != 0 {
+		args := []string{"env"}
+		for _, name := range []string{"WAYLAND_DISPLAY", "XDG_RUNTIME_DIR", "DISPLAY", "XAUTHORITY"} {
+			if value := os.Getenv(name); value != "" {
+				args = append(args, name+"="+value)
+			}
+		}
+		args = append(args, usb, entry.Path)
+		cmd = exec.Command(pkexec, args...)
+	} else {
+		cmd = exec.Command(usb, entry.Path)
+	}
+
+	if err := cmd.Start(); err != nil {
+		fm.showError(fmt.Sprintf("Could not start raven-usb: %v", err))
+		return
+	}
+	go cmd.Wait()
+}
+
+// verifyISO checks the ISO's SHA256 and signature with raven-usb --check,
+// which needs no root, and shows what it found
+func verifyISO(fm *FileManager, entry fileview.FileEntry) {
+	usb, err := exec.LookPath("raven-usb")
+	if err != nil {
+		fm.showError("raven-usb is not installed")
+		return
+	}
+
+	fm.statusLabel.SetText("Verifying " + entry.Name + "...")
+	go func() {
+		out, err := exec.Command(usb, "--check", entry.Path).CombinedOutput()
+
+		// Keep the outcome, not the progress lines printed while hashing
+		var lines []string
+		for _, line := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+			if line != "" && !strings.HasPrefix(line, "Verifying ") {
+				lines = append(lines, strings.TrimPrefix(line, "raven-usb: "))
+			}
+		}
+		result := strings.Join(lines, "\n")
+		glib.IdleAdd(func() {
+			fm.updateStatusBar()
+			if err != nil {
+				if result == "" {
+					result = err.Error()
+				}
+				fm.showError(fmt.Sprintf("%s failed verification:\n%s", entry.Name, result))
+				return
+			}
+			fm.showMessage("ISO Verified", "emblem-ok-symbolic", entry.Name+"\n"+result)
+		})
+	}()
+}
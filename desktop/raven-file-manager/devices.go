@@ -0,0 +1,268 @@
+package main
+
+import (
+	"fmt"
+	"os/exec"
+	"reflect"
+	"strings"
+
+	"raven-file-manager/pkg/devices"
+	"raven-file-manager/pkg/fileview"
+
+	"github.com/diamondburned/gotk4/pkg/gio/v2"
+	"github.com/diamondburned/gotk4/pkg/glib/v2"
+	"github.com/diamondburned/gotk4/pkg/gtk/v4"
+)
+
+// How often, in seconds, the Devices section looks for drives plugged in,
+// removed, mounted or filled
+const devicePollSeconds = 2
+
+// createDevicesSection adds the Devices heading and list to the sidebar.
+// Both stay hidden while no removable drive is plugged in.
+func (fm *FileManager) createDevicesSection(sidebar *gtk.Box) {
+	fm.devicesLabel = gtk.NewLabel("Devices")
+	fm.devicesLabel.AddCSSClass("sidebar-section")
+	fm.devicesLabel.SetHAlign(gtk.AlignStart)
+	fm.devicesLabel.SetVisible(false)
+	sidebar.Append(fm.devicesLabel)
+
+	fm.devicesList = gtk.NewListBox()
+	fm.devicesList.AddCSSClass("sidebar-list")
+	fm.devicesList.SetSelectionMode(gtk.SelectionSingle)
+	fm.devicesList.SetVisible(false)
+	fm.devicesList.ConnectRowActivated(func(row *gtk.ListBoxRow) {
+		if d, ok := fm.deviceAt(row); ok {
+			fm.sidebarList.UnselectAll()
+			fm.networkList.UnselectAll()
+			fm.openDevice(d)
+		}
+	})
+	sidebar.Append(fm.devicesList)
+
+	gesture := gtk.NewGestureClick()
+	gesture.SetButton(3)
+	gesture.ConnectPressed(func(nPress int, x, y float64) {
+		row := fm.devicesList.RowAtY(int(y))
+		if d, ok := fm.deviceAt(row); ok {
+			fm.showDeviceMenu(row, d)
+		}
+	})
+	fm.devicesList.AddController(gesture)
+
+	// Files dropped on a mounted device go to its top folder
+	fm.addDropTarget(fm.devicesList, func(x, y float64) (string, gtk.Widgetter) {
+		row := fm.devicesList.RowAtY(int(y))
+		if d, ok := fm.deviceAt(row); ok && d.Mounted() {
+			return d.MountPoint, row
+		}
+		return "", nil
+	})
+
+	fm.scanDevices()
+	glib.TimeoutSecondsAdd(devicePollSeconds, func() bool {
+		fm.scanDevices()
+		return true
+	})
+}
+
+// deviceAt returns the device shown in row
+func (fm *FileManager) deviceAt(row *gtk.ListBoxRow) (devices.Device, bool) {
+	if row == nil || row.Index() < 0 || row.Index() >= len(fm.drives) {
+		return devices.Device{}, false
+	}
+	return fm.drives[row.Index()], true
+}
+
+// scanDevices looks for drives off the main loop and shows what it finds
+func (fm *FileManager) scanDevices() {
+	go func() {
+		found := devices.Scan()
+		glib.IdleAdd(func() {
+			fm.showDevices(found)
+		})
+	}()
+}
+
+// showDevices lists found in the sidebar. The rows are only rebuilt when a
+// device was added, removed, mounted or unmounted; otherwise just the
+// free space is brought up to date.
+func (fm *FileManager) showDevices(found []devices.Device) {
+	if !reflect.DeepEqual(found, fm.drives) {
+		fm.drives = found
+		fm.deviceBars = make([]*gtk.LevelBar, len(found))
+		for child := fm.devicesList.FirstChild(); child != nil; child = fm.devicesList.FirstChild() {
+			fm.devicesList.Remove(child)
+		}
+		for i, d := range found {
+			fm.devicesList.Append(fm.createDeviceRow(i, d))
+		}
+		fm.devicesLabel.SetVisible(len(found) > 0)
+		fm.devicesList.SetVisible(len(found) > 0)
+	}
+
+	for i, d := range fm.drives {
+		bar := fm.deviceBars[i]
+		free, total := d.Usage()
+		if bar == nil || total == 0 {
+			continue
+		}
+		bar.SetValue(float64(total-free) / float64(total))
+		bar.SetTooltipText(fileview.HumanizeSize(free) + " free of " + fileview.HumanizeSize(total))
+	}
+}
+
+// createDeviceRow shows d with a free space bar while it is mounted and a
+// button to eject it
+func (fm *FileManager) createDeviceRow(i int, d devices.Device) *gtk.ListBoxRow {
+	row := gtk.NewListBoxRow()
+
+	box := gtk.NewBox(gtk.OrientationHorizontal, 8)
+	box.SetMarginStart(8)
+	box.SetMarginEnd(8)
+	box.SetMarginTop(4)
+	box.SetMarginBottom(4)
+
+	icon := gtk.NewImageFromIconName("drive-removable-media")
+	icon.AddCSSClass("sidebar-item-icon")
+	box.Append(icon)
+
+	info := gtk.NewBox(gtk.OrientationVertical, 2)
+	info.SetHExpand(true)
+	info.SetVAlign(gtk.AlignCenter)
+
+	label := gtk.NewLabel(d.Title())
+	label.AddCSSClass("sidebar-item")
+	label.SetHAlign(gtk.AlignStart)
+	label.SetEllipsize(3)
+	info.Append(label)
+
+	if d.Mounted() {
+		bar := gtk.NewLevelBarForInterval(0, 1)
+		bar.AddCSSClass("device-usage")
+		info.Append(bar)
+		fm.deviceBars[i] = bar
+	}
+	box.Append(info)
+
+	ejectBtn := gtk.NewButtonFromIconName("media-eject-symbolic")
+	ejectBtn.AddCSSClass("device-eject")
+	ejectBtn.SetHasFrame(false)
+	ejectBtn.SetTooltipText("Eject")
+	ejectBtn.ConnectClicked(func() { fm.unmountDevice(d, true) })
+	box.Append(ejectBtn)
+
+	row.SetTooltipText(deviceTooltip(d))
+	row.SetChild(box)
+	return row
+}
+
+// deviceTooltip describes d's device node, filesystem and drive
+func deviceTooltip(d devices.Device) string {
+	parts := []string{d.Path}
+	if d.FSType != "" {
+		parts = append(parts, d.FSType)
+	}
+	parts = append(parts, fileview.HumanizeSize(d.Size))
+	if d.Model != "" {
+		parts = append(parts, d.Model)
+	}
+	return strings.Join(parts, " · ")
+}
+
+// showDeviceMenu pops up the actions for d
+func (fm *FileManager) showDeviceMenu(row *gtk.ListBoxRow, d devices.Device) {
+	menu := gio.NewMenu()
+	menu.Append("Open", "app.device-open")
+	if d.Mounted() {
+		menu.Append("Open in New Tab", "app.device-open-tab")
+		menu.Append("Unmount", "app.device-unmount")
+	} else {
+		menu.Append("Mount", "app.device-mount")
+	}
+	menu.Append("Eject", "app.device-eject")
+
+	fm.addMenuAction("device-open", func() { fm.openDevice(d) })
+	fm.addMenuAction("device-open-tab", func() { fm.openTab(d.MountPoint) })
+	fm.addMenuAction("device-mount", func() { fm.mountDevice(d, false) })
+	fm.addMenuAction("device-unmount", func() { fm.unmountDevice(d, false) })
+	fm.addMenuAction("device-eject", func() { fm.unmountDevice(d, true) })
+
+	popover := gtk.NewPopoverMenuFromModel(menu)
+	popover.SetParent(row)
+	popover.SetHasArrow(false)
+	popover.Popup()
+}
+
+// openDevice shows d's files, mounting it first if needed
+func (fm *FileManager) openDevice(d devices.Device) {
+	if d.Mounted() {
+		fm.navigateTo(d.MountPoint)
+		return
+	}
+	fm.mountDevice(d, true)
+}
+
+// mountDevice mounts d in the background, then opens it with open
+func (fm *FileManager) mountDevice(d devices.Device, open bool) {
+	go func() {
+		point, err := devices.Mount(d)
+		glib.IdleAdd(func() {
+			if err != nil {
+				fm.showError(fmt.Sprintf("Could not mount %s: %v", d.Title(), err))
+				return
+			}
+			fm.scanDevices()
+			if open {
+				fm.navigateTo(point)
+			}
+		})
+	}()
+}
+
+// unmountDevice unmounts d in the background, or with eject unmounts its
+// whole drive and powers it off. Panes showing what is unmounted go home
+// first, so they don't keep the filesystem busy.
+func (fm *FileManager) unmountDevice(d devices.Device, eject bool) {
+	for _, other := range fm.drives {
+		if other.Mounted() && (other.Path == d.Path || eject && other.Drive == d.Drive) {
+			fm.leaveFolder(other.MountPoint)
+		}
+	}
+
+	go func() {
+		var err error
+		if eject {
+			err = devices.Eject(d)
+		} else {
+			err = devices.Unmount(d)
+		}
+		glib.IdleAdd(func() {
+			fm.scanDevices()
+			if err != nil {
+				verb := "unmount"
+				if eject {
+					verb = "eject"
+				}
+				fm.showError(fmt.Sprintf("Could not %s %s: %v", verb, d.Title(), err))
+				return
+			}
+			if eject {
+				exec.Command("notify-send", "-a", "Raven Files", "-i", "media-eject",
+					d.Title()+" can be removed", "The drive was ejected safely.").Start()
+			}
+		})
+	}()
+}
+
+// leaveFolder sends every pane showing dir or a folder inside it home
+func (fm *FileManager) leaveFolder(dir string) {
+	for _, t := range fm.tabs {
+		for _, p := range t.panes {
+			if p.currentPath == dir || strings.HasPrefix(p.currentPath, dir+"/") {
+				fm.inPane(p, fm.goHome)
+			}
+		}
+	}
+	fm.updateLocationBar()
+}
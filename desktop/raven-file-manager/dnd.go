@@ -0,0 +1,236 @@
+package main
+
+import (
+	"net/url"
+	"syscall"
+
+	"raven-file-manager/pkg/clipboard"
+	"raven-file-manager/pkg/fileview"
+	"raven-file-manager/pkg/icons"
+
+	"github.com/diamondburned/gotk4/pkg/gdk/v4"
+	"github.com/diamondburned/gotk4/pkg/glib/v2"
+	"github.com/diamondburned/gotk4/pkg/gtk/v4"
+)
+
+// Dragged files travel as a text/uri-list, which GTK hands to drop targets
+// as a GdkFileList. Other apps can drop files in and take them out the same
+// way.
+
+// dropLocator returns the folder files dropped at x, y go into and the
+// widget to highlight while they are over it. An empty folder refuses the
+// drop.
+type dropLocator func(x, y float64) (dir string, highlight gtk.Widgetter)
+
+// noDrop is the action of a refused drop
+const noDrop gdk.DragAction = 0
+
+// attachDragAndDrop lets the selected rows of list, the file list of p, be
+// dragged, and files be dropped onto its folders or into p's folder
+func (fm *FileManager) attachDragAndDrop(p *pane, list *gtk.ListBox) {
+	source := gtk.NewDragSource()
+	source.SetActions(gdk.ActionCopy | gdk.ActionMove)
+
+	var dragged []*gtk.ListBoxRow
+	source.ConnectPrepare(func(x, y float64) *gdk.ContentProvider {
+		// Dragging items out of the Trash would leave their .trashinfo
+		// behind; they leave it by being restored
+		row := list.RowAtY(int(y))
+		if row == nil || row.Index() < 0 || row.Index() >= len(p.currentFiles) || p.currentPath == trashFilesDir() {
+			return nil
+		}
+
+		// Dragging outside the selection drags just that row
+		if !row.IsSelected() {
+			list.UnselectAll()
+			list.SelectRow(row)
+		}
+
+		dragged = list.SelectedRows()
+		var paths []string
+		for _, r := range dragged {
+			if idx := r.Index(); idx >= 0 && idx < len(p.currentFiles) {
+				paths = append(paths, p.currentFiles[idx].Path)
+			}
+		}
+
+		entry := p.currentFiles[row.Index()]
+		if icon := icons.Path(fileview.GetFileIcon(entry), 32); icon != "" {
+			if texture, err := gdk.NewTextureFromFilename(icon); err == nil {
+				source.SetIcon(texture, 0, 0)
+			}
+		}
+		return uriListProvider(paths)
+	})
+	source.ConnectDragBegin(func(drag gdk.Dragger) {
+		for _, r := range dragged {
+			r.AddCSSClass("dragging")
+		}
+	})
+	source.ConnectDragEnd(func(drag gdk.Dragger, deleteData bool) {
+		for _, r := range dragged {
+			r.RemoveCSSClass("dragging")
+		}
+		dragged = nil
+	})
+	list.AddController(source)
+
+	fm.addDropTarget(list, func(x, y float64) (string, gtk.Widgetter) {
+		if row := list.RowAtY(int(y)); row != nil && p.currentPath != trashFilesDir() {
+			idx := row.Index()
+			if idx >= 0 && idx < len(p.currentFiles) && p.currentFiles[idx].IsDir {
+				return p.currentFiles[idx].Path, row
+			}
+		}
+		return p.currentPath, list
+	})
+}
+
+// addDropTarget accepts files dropped on widget, copying or moving them into
+// the folder locate finds under the pointer. The folder's widget is
+// highlighted while files that can go there are over it.
+func (fm *FileManager) addDropTarget(widget gtk.Widgetter, locate dropLocator) {
+	target := gtk.NewDropTarget(gdk.GTypeFileList, gdk.ActionCopy|gdk.ActionMove)
+	target.SetPreload(true)
+
+	var highlighted gtk.Widgetter
+	setHighlight := func(w gtk.Widgetter) {
+		if highlighted != nil {
+			gtk.BaseWidget(highlighted).RemoveCSSClass("drop-target")
+		}
+		highlighted = w
+		if w != nil {
+			gtk.BaseWidget(w).AddCSSClass("drop-target")
+		}
+	}
+
+	// offered returns the actions the drag allows, narrowed to one when the
+	// user holds Ctrl (copy) or Shift (move)
+	offered := func() gdk.DragAction {
+		if drop := target.CurrentDrop(); drop != nil {
+			return gdk.BaseDrop(drop).Actions()
+		}
+		return gdk.ActionCopy | gdk.ActionMove
+	}
+
+	update := func(x, y float64) gdk.DragAction {
+		dir, w := locate(x, y)
+		action := dropAction(offered(), droppedFiles(target.Value()), dir)
+		if action == noDrop {
+			w = nil
+		}
+		setHighlight(w)
+		return action
+	}
+	target.ConnectEnter(update)
+	target.ConnectMotion(update)
+	target.ConnectLeave(func() { setHighlight(nil) })
+
+	target.ConnectDrop(func(value *glib.Value, x, y float64) bool {
+		setHighlight(nil)
+		dir, _ := locate(x, y)
+		files := droppedFiles(value)
+		action := dropAction(offered(), files, dir)
+		if action == noDrop || len(files) == 0 {
+			return false
+		}
+
+		if dir == trashFilesDir() {
+			fm.trashPaths(filesToDrop(files, dir))
+			return true
+		}
+
+		op := clipboard.OpCopy
+		if action == gdk.ActionMove {
+			op = clipboard.OpCut
+		}
+		fm.queueTransfer(filesToDrop(files, dir), op, dir, false)
+		return true
+	})
+
+	gtk.BaseWidget(widget).AddController(target)
+}
+
+// uriListProvider offers paths to drop targets as a text/uri-list
+func uriListProvider(paths []string) *gdk.ContentProvider {
+	return gdk.NewContentProviderForBytes(clipboard.URIListType, glib.NewBytes(clipboard.EncodeURIList(paths)))
+}
+
+// droppedFiles returns the local paths, and the URIs of files on connected
+// servers, in a dropped GdkFileList. It is nil while the drop's data is
+// still loading.
+func droppedFiles(value *glib.Value) []string {
+	if value == nil {
+		return nil
+	}
+	list, ok := value.GoValue().(*gdk.FileList)
+	if !ok {
+		return nil
+	}
+
+	var paths []string
+	for _, file := range list.Files() {
+		if path := file.Path(); path != "" {
+			paths = append(paths, path)
+		} else if uri, err := url.PathUnescape(file.URI()); err == nil && fileview.IsRemote(uri) {
+			paths = append(paths, uri)
+		}
+	}
+	return paths
+}
+
+// dropAction returns what dropping files into dir does, or noDrop if they
+// can't go there. Unless the user picked an action with a modifier key,
+// files move within a filesystem and are copied to another one. Files
+// dropped in the Trash are always trashed. While the files are not known
+// yet, only dir is checked.
+func dropAction(offered gdk.DragAction, files []string, dir string) gdk.DragAction {
+	trashing := dir == trashFilesDir()
+	if dir == "" || !trashing && !fileview.IsDirectory(dir) {
+		return noDrop
+	}
+	if files != nil && len(filesToDrop(files, dir)) == 0 {
+		return noDrop
+	}
+	if trashing {
+		return offered & gdk.ActionMove
+	}
+
+	switch {
+	case offered == gdk.ActionCopy || offered == gdk.ActionMove:
+		return offered
+	case offered&gdk.ActionMove != 0 && len(files) > 0 && sameFilesystem(files[0], dir):
+		return gdk.ActionMove
+	case offered&gdk.ActionCopy != 0:
+		return gdk.ActionCopy
+	}
+	return offered & gdk.ActionMove
+}
+
+// filesToDrop leaves out the files already in dir, or nothing at all if
+// one of them is dir or a folder containing it, also through a link
+func filesToDrop(files []string, dir string) []string {
+	dir = fileview.Clean(dir)
+
+	var result []string
+	for _, file := range files {
+		file = fileview.Clean(file)
+		if fileview.Contains(file, dir) {
+			return nil
+		}
+		if fileview.GetParentPath(file) != dir {
+			result = append(result, file)
+		}
+	}
+	return result
+}
+
+// sameFilesystem reports whether a and b are on the same device, where a
+// move is a cheap rename
+func sameFilesystem(a, b string) bool {
+	var sa, sb syscall.Stat_t
+	if syscall.Stat(a, &sa) != nil || syscall.Stat(b, &sb) != nil {
+		return false
+	}
+	return sa.Dev == sb.Dev
+}
@@ -1,11 +1,29 @@
 module raven-file-manager
 
-go 1.23
+go 1.23.0
 
-require github.com/diamondburned/gotk4/pkg v0.3.1
+require (
+	github.com/diamondburned/gotk4/pkg v0.3.1
+	github.com/fsnotify/fsnotify v1.8.0
+	github.com/hirochachacha/go-smb2 v1.1.0
+	github.com/pkg/sftp v1.13.7
+	golang.org/x/crypto v0.31.0
+	golang.org/x/sys v0.33.0
+	modernc.org/sqlite v1.34.5
+)
 
 require (
 	github.com/KarpelesLab/weak v0.1.1 // indirect
+	github.com/dustin/go-humanize v1.0.1 // indirect
+	github.com/geoffgarside/ber v1.1.0 // indirect
+	github.com/google/uuid v1.6.0 // indirect
+	github.com/kr/fs v0.1.0 // indirect
+	github.com/mattn/go-isatty v0.0.20 // indirect
+	github.com/ncruces/go-strftime v0.1.9 // indirect
+	github.com/remyoudompheng/bigfft v0.0.0-20230129092748-24d4a6f8daec // indirect
 	go4.org/unsafe/assume-no-moving-gc v0.0.0-20231121144256-b99613f794b6 // indirect
-	golang.org/x/sync v0.0.0-20210220032951-036812b2e83c // indirect
+	golang.org/x/sync v0.1.0 // indirect
+	modernc.org/libc v1.55.3 // indirect
+	modernc.org/mathutil v1.6.0 // indirect
+	modernc.org/memory v1.8.0 // indirect
 )
@@ -2,19 +2,29 @@ package main
 
 import (
 	"context"
+	"fmt"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strings"
 	"sync"
+	"time"
 
+	"raven-file-manager/pkg/admin"
 	"raven-file-manager/pkg/clipboard"
 	"raven-file-manager/pkg/config"
 	"raven-file-manager/pkg/css"
+	"raven-file-manager/pkg/devices"
 	"raven-file-manager/pkg/fileview"
 	"raven-file-manager/pkg/filter"
-	"raven-file-manager/pkg/navigation"
+	"raven-file-manager/pkg/gitstatus"
+	"raven-file-manager/pkg/icons"
+	"raven-file-manager/pkg/index"
+	"raven-file-manager/pkg/permissions"
 	"raven-file-manager/pkg/preview"
+	"raven-file-manager/pkg/remote"
 	"raven-file-manager/pkg/search"
+	"raven-file-manager/pkg/trash"
 
 	"github.com/diamondburned/gotk4/pkg/gdk/v4"
 	"github.com/diamondburned/gotk4/pkg/gio/v2"
@@ -22,6 +32,9 @@ import (
 	"github.com/diamondburned/gotk4/pkg/gtk/v4"
 )
 
+// How often the trash cleanup policy is re-applied while running
+const trashPurgeInterval = time.Hour
+
 // FileManager is the main application struct
 type FileManager struct {
 	app    *gtk.Application
@@ -30,9 +43,9 @@ type FileManager struct {
 	// Settings
 	settings config.Settings
 
-	// Navigation state
-	currentPath string
-	history     *navigation.History
+	// The pane being worked in: its folder, history, files and selection
+	*pane
+	tabs []*tab
 
 	// UI Components
 	headerBar     *gtk.Box
@@ -44,45 +57,86 @@ type FileManager struct {
 	homeBtn       *gtk.Button
 	sidebarBox    *gtk.Box
 	sidebarList   *gtk.ListBox
+	devicesLabel  *gtk.Label
+	devicesList   *gtk.ListBox
+	networkList   *gtk.ListBox
+	deviceBars    []*gtk.LevelBar // Free space of each mounted device
+	trashIcon     *gtk.Image
+	trashRow      *gtk.ListBoxRow // Last in Places, after the bookmarks
 	mainPaned     *gtk.Paned
 	contentPaned  *gtk.Paned
-	fileListBox   *gtk.ListBox
+	terminalPaned *gtk.Paned // Files above, terminal panel below
+	notebook      *gtk.Notebook
 	fileFlowBox   *gtk.FlowBox
-	fileScroll    *gtk.ScrolledWindow
 	previewPane   *gtk.Box
 	statusBar     *gtk.Box
 	statusLabel   *gtk.Label
 	statusRight   *gtk.Label
+	statusBranch  *gtk.Label // Git branch of the current folder
 	filterPanel   *gtk.Box
+	filterBtn     *gtk.Button
+	typeDropdown  *gtk.DropDown
+	dateChips     []*gtk.Button // Filter chips of the ranges after "any"
+	sizeChips     []*gtk.Button
+	hiddenBtn     *gtk.ToggleButton
 
 	// Search state
 	searchActive        bool
 	contentSearchActive bool
 
-	// File state
-	currentFiles  []fileview.FileEntry
-	selectedFiles []fileview.FileEntry
-	mu            sync.RWMutex
+	// Guards the files and selection of the panes
+	mu sync.RWMutex
 
 	// Components
 	searchEngine *search.Engine
+	searchIndex  *index.Index // nil when disabled or unavailable
 	previewPanel *preview.Panel
 	filterState  *filter.State
 	clipboard    *clipboard.Manager
+	transfers    transferQueue
+	drives       []devices.Device // Shown in the sidebar's Devices section
+	connections  []*remote.Conn   // Servers connected to, shown under Network
+
+	// When the GTK bookmarks file was last read or written
+	bookmarksModTime time.Time
+
+	// Embedded terminal, nil until the panel is first shown, and the
+	// folder its shell was last sent to
+	terminalPanel *gtk.Box
+	terminal      *gtk.Widget
+	terminalDir   string
+
+	cancelTrashPurge context.CancelFunc
+
+	// Directory given on the command line, opened instead of $HOME
+	startPath string
 }
 
 func main() {
+	// Run through pkexec to retry an operation as administrator
+	if len(os.Args) > 1 && os.Args[1] == admin.HelperFlag {
+		os.Exit(admin.Serve(os.Args[2:]))
+	}
+
 	app := gtk.NewApplication("org.ravenlinux.filemanager", gio.ApplicationFlagsNone)
 
 	fm := &FileManager{
 		app: app,
 	}
 
+	// GApplication rejects file arguments without G_APPLICATION_HANDLES_OPEN,
+	// so the start directory is taken here and not passed on
+	if len(os.Args) > 1 {
+		if abs, err := filepath.Abs(os.Args[1]); err == nil {
+			fm.startPath = abs
+		}
+	}
+
 	app.ConnectActivate(func() {
 		fm.activate()
 	})
 
-	if code := app.Run(os.Args); code > 0 {
+	if code := app.Run(os.Args[:1]); code > 0 {
 		os.Exit(code)
 	}
 }
@@ -92,18 +146,14 @@ func (fm *FileManager) activate() {
 	fm.settings = config.LoadSettings()
 
 	// Initialize state
-	fm.history = navigation.NewHistory()
 	fm.filterState = filter.NewState()
+	fm.filterState.ShowHidden = fm.settings.ShowHidden
+	fm.filterState.HidePatterns = fm.settings.HidePatterns
 	fm.searchEngine = search.NewEngine()
+	fm.openSearchIndex()
 	fm.previewPanel = preview.NewPanel()
 	fm.clipboard = clipboard.NewManager()
-
-	// Set initial path
-	home := os.Getenv("HOME")
-	if home == "" {
-		home = "/"
-	}
-	fm.currentPath = home
+	fm.clipboard.SetVerify(fm.settings.VerifyCopies)
 
 	// Create window
 	fm.window = gtk.NewWindow()
@@ -120,13 +170,43 @@ func (fm *FileManager) activate() {
 	// Setup keyboard shortcuts
 	fm.setupKeyboardShortcuts()
 
-	// Load initial directory
-	fm.navigateTo(fm.currentPath)
+	// Open the start directory or the last session's tabs
+	fm.restoreTabs()
+	fm.window.ConnectCloseRequest(func() bool {
+		fm.saveTabs()
+		if fm.searchIndex != nil {
+			fm.searchIndex.Close()
+		}
+		return false
+	})
+
+	// Enforce the trash cleanup policy in the background
+	fm.startTrashPurge()
+
+	if fm.settings.ShowTerminal {
+		fm.setTerminalVisible(true)
+	}
 
 	fm.window.SetApplication(fm.app)
 	fm.window.Present()
 }
 
+// openSearchIndex makes searches under $HOME use the index, which is
+// built in the background the first time
+func (fm *FileManager) openSearchIndex() {
+	home := os.Getenv("HOME")
+	if fm.settings.DisableSearchIndex || home == "" {
+		return
+	}
+	ix, err := index.Open(home, fm.settings.SearchContentMax)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "raven-files: search index: %v\n", err)
+		return
+	}
+	fm.searchIndex = ix
+	fm.searchEngine.SetIndex(ix)
+}
+
 func (fm *FileManager) applyCSS() {
 	provider := gtk.NewCSSProvider()
 	provider.LoadFromString(css.FileManagerCSS)
@@ -158,9 +238,8 @@ func (fm *FileManager) createUI() *gtk.Box {
 	fm.contentPaned = gtk.NewPaned(gtk.OrientationHorizontal)
 	fm.contentPaned.SetHExpand(true)
 
-	// File area
-	fileArea := fm.createFileArea()
-	fm.contentPaned.SetStartChild(fileArea)
+	// Tabs of file panes
+	fm.contentPaned.SetStartChild(fm.createNotebook())
 
 	// Preview pane
 	fm.previewPane = fm.createPreviewPane()
@@ -168,7 +247,14 @@ func (fm *FileManager) createUI() *gtk.Box {
 	fm.contentPaned.SetPosition(800)
 	fm.previewPane.SetVisible(fm.settings.ShowPreview)
 
-	contentBox.Append(fm.contentPaned)
+	// Terminal panel
+	fm.terminalPaned = gtk.NewPaned(gtk.OrientationVertical)
+	fm.terminalPaned.SetHExpand(true)
+	fm.terminalPaned.SetStartChild(fm.contentPaned)
+	fm.terminalPaned.SetEndChild(fm.createTerminalPanel())
+	fm.terminalPaned.SetResizeEndChild(false)
+
+	contentBox.Append(fm.terminalPaned)
 	mainBox.Append(contentBox)
 
 	// Status bar
@@ -225,7 +311,7 @@ func (fm *FileManager) createHeaderBar() *gtk.Box {
 	fm.locationEntry.ConnectActivate(func() {
 		path := fm.locationEntry.Text()
 		if path != "" {
-			fm.navigateTo(path)
+			fm.openAddress(path)
 		}
 	})
 	header.Append(fm.locationEntry)
@@ -250,14 +336,24 @@ func (fm *FileManager) createHeaderBar() *gtk.Box {
 	// Action buttons
 	actionBox := gtk.NewBox(gtk.OrientationHorizontal, 4)
 
-	filterBtn := gtk.NewButton()
-	filterBtn.SetIconName("view-more-symbolic")
-	filterBtn.AddCSSClass("nav-button")
-	filterBtn.SetTooltipText("Filters")
-	filterBtn.ConnectClicked(func() {
+	fm.filterBtn = gtk.NewButton()
+	fm.filterBtn.SetIconName("view-more-symbolic")
+	fm.filterBtn.AddCSSClass("nav-button")
+	fm.filterBtn.SetTooltipText("Filters")
+	fm.filterBtn.ConnectClicked(func() {
 		fm.filterPanel.SetVisible(!fm.filterPanel.IsVisible())
 	})
-	actionBox.Append(filterBtn)
+	actionBox.Append(fm.filterBtn)
+
+	fm.hiddenBtn = gtk.NewToggleButton()
+	fm.hiddenBtn.SetIconName("view-reveal-symbolic")
+	fm.hiddenBtn.AddCSSClass("nav-button")
+	fm.hiddenBtn.SetTooltipText("Show Hidden Files (Ctrl+H)")
+	fm.hiddenBtn.SetActive(fm.filterState.ShowHidden)
+	fm.hiddenBtn.ConnectToggled(func() {
+		fm.setShowHidden(fm.hiddenBtn.Active())
+	})
+	actionBox.Append(fm.hiddenBtn)
 
 	previewBtn := gtk.NewToggleButton()
 	previewBtn.SetIconName("view-dual-symbolic")
@@ -270,6 +366,13 @@ func (fm *FileManager) createHeaderBar() *gtk.Box {
 	})
 	actionBox.Append(previewBtn)
 
+	prefsBtn := gtk.NewButton()
+	prefsBtn.SetIconName("preferences-system-symbolic")
+	prefsBtn.AddCSSClass("nav-button")
+	prefsBtn.SetTooltipText("Preferences (Ctrl+,)")
+	prefsBtn.ConnectClicked(func() { fm.showPreferences() })
+	actionBox.Append(prefsBtn)
+
 	header.Append(actionBox)
 
 	return header
@@ -296,17 +399,35 @@ func (fm *FileManager) createSidebar() *gtk.Box {
 	fm.sidebarList.SetSelectionMode(gtk.SelectionSingle)
 	fm.sidebarList.ConnectRowActivated(func(row *gtk.ListBoxRow) {
 		idx := row.Index()
+		fm.devicesList.UnselectAll()
+		fm.networkList.UnselectAll()
 		if idx >= 0 && idx < len(fm.settings.Bookmarks) {
-			fm.navigateTo(fm.settings.Bookmarks[idx].Path)
+			fm.openAddress(fm.settings.Bookmarks[idx].Path)
+		} else if idx == len(fm.settings.Bookmarks) {
+			fm.navigateTo(trashFilesDir())
 		}
 	})
 
-	for _, bookmark := range fm.settings.Bookmarks {
-		row := fm.createSidebarRow(bookmark.Name, bookmark.Icon)
-		fm.sidebarList.Append(row)
-	}
+	fm.createTrashPlace()
+	fm.watchBookmarks()
+	fm.attachBookmarkMenu()
+
+	// Files dropped on a bookmark go into its folder, and on the Trash are
+	// trashed
+	fm.addDropTarget(fm.sidebarList, func(x, y float64) (string, gtk.Widgetter) {
+		row := fm.sidebarList.RowAtY(int(y))
+		if row == nil || row.Index() < 0 || row.Index() > len(fm.settings.Bookmarks) {
+			return "", nil
+		}
+		if row.Index() == len(fm.settings.Bookmarks) {
+			return trashFilesDir(), row
+		}
+		return fm.settings.Bookmarks[row.Index()].Path, row
+	})
 
 	sidebarContent.Append(fm.sidebarList)
+	fm.createDevicesSection(sidebarContent)
+	fm.createNetworkSection(sidebarContent)
 	scroll.SetChild(sidebarContent)
 	sidebar.Append(scroll)
 
@@ -335,58 +456,6 @@ func (fm *FileManager) createSidebarRow(name, iconName string) *gtk.ListBoxRow {
 	return row
 }
 
-func (fm *FileManager) createFileArea() *gtk.Box {
-	fileArea := gtk.NewBox(gtk.OrientationVertical, 0)
-	fileArea.SetHExpand(true)
-	fileArea.SetVExpand(true)
-
-	fm.fileScroll = gtk.NewScrolledWindow()
-	fm.fileScroll.SetPolicy(gtk.PolicyAutomatic, gtk.PolicyAutomatic)
-	fm.fileScroll.SetVExpand(true)
-
-	fm.createListView()
-
-	fileArea.Append(fm.fileScroll)
-
-	return fileArea
-}
-
-func (fm *FileManager) createListView() {
-	fm.fileListBox = gtk.NewListBox()
-	fm.fileListBox.AddCSSClass("file-list")
-	fm.fileListBox.SetSelectionMode(gtk.SelectionMultiple)
-	fm.fileListBox.SetActivateOnSingleClick(false)
-
-	fm.fileListBox.ConnectRowActivated(func(row *gtk.ListBoxRow) {
-		idx := row.Index()
-		if idx >= 0 && idx < len(fm.currentFiles) {
-			fm.openFile(fm.currentFiles[idx])
-		}
-	})
-
-	fm.fileListBox.ConnectSelectedRowsChanged(func() {
-		fm.onSelectionChanged()
-	})
-
-	// Add gesture for double-click handling
-	gesture := gtk.NewGestureClick()
-	gesture.SetButton(1) // Left mouse button
-	gesture.ConnectPressed(func(nPress int, x, y float64) {
-		if nPress == 2 { // Double-click
-			row := fm.fileListBox.RowAtY(int(y))
-			if row != nil {
-				idx := row.Index()
-				if idx >= 0 && idx < len(fm.currentFiles) {
-					fm.openFile(fm.currentFiles[idx])
-				}
-			}
-		}
-	})
-	fm.fileListBox.AddController(gesture)
-
-	fm.fileScroll.SetChild(fm.fileListBox)
-}
-
 func (fm *FileManager) createPreviewPane() *gtk.Box {
 	previewBox := gtk.NewBox(gtk.OrientationVertical, 0)
 	previewBox.AddCSSClass("preview-pane")
@@ -421,46 +490,123 @@ func (fm *FileManager) createPreviewPane() *gtk.Box {
 	return previewBox
 }
 
+// createFilterPanel returns the panel narrowing the file list by type,
+// modification date and size. The filters combine, and apply to every pane.
 func (fm *FileManager) createFilterPanel() *gtk.Box {
-	panel := gtk.NewBox(gtk.OrientationHorizontal, 12)
+	panel := gtk.NewBox(gtk.OrientationVertical, 8)
 	panel.AddCSSClass("filter-panel")
 
-	typeLabel := gtk.NewLabel("Type:")
-	typeLabel.AddCSSClass("filter-label")
-	panel.Append(typeLabel)
+	addRow := func(title string) *gtk.Box {
+		row := gtk.NewBox(gtk.OrientationHorizontal, 6)
+		label := gtk.NewLabel(title)
+		label.AddCSSClass("filter-label")
+		label.SetWidthChars(9)
+		label.SetXAlign(0)
+		row.Append(label)
+		panel.Append(row)
+		return row
+	}
 
+	typeRow := addRow("Type:")
 	typeStrings := []string{"All Files", "Documents", "Images", "Videos", "Audio", "Archives", "Code"}
 	typeModel := gtk.NewStringList(typeStrings)
-	typeDropdown := gtk.NewDropDown(typeModel, nil)
-	typeDropdown.AddCSSClass("filter-dropdown")
-	typeDropdown.Connect("notify::selected", func() {
-		idx := typeDropdown.Selected()
+	fm.typeDropdown = gtk.NewDropDown(typeModel, nil)
+	fm.typeDropdown.AddCSSClass("filter-dropdown")
+	fm.typeDropdown.Connect("notify::selected", func() {
+		idx := fm.typeDropdown.Selected()
 		if idx == 0 {
 			fm.filterState.FileTypes = nil
 		} else {
 			fm.filterState.FileTypes = []string{typeStrings[idx]}
 		}
-		fm.refresh()
+		fm.applyFilters()
 	})
-	panel.Append(typeDropdown)
+	typeRow.Append(fm.typeDropdown)
 
 	spacer := gtk.NewBox(gtk.OrientationHorizontal, 0)
 	spacer.SetHExpand(true)
-	panel.Append(spacer)
+	typeRow.Append(spacer)
 
 	clearBtn := gtk.NewButton()
 	clearBtn.SetLabel("Clear Filters")
 	clearBtn.AddCSSClass("filter-clear")
 	clearBtn.ConnectClicked(func() {
-		fm.filterState = filter.NewState()
-		fm.refresh()
+		fm.filterState.Reset()
+		fm.applyFilters()
 		fm.filterPanel.SetVisible(false)
 	})
-	panel.Append(clearBtn)
+	typeRow.Append(clearBtn)
+
+	// Chips pick one range each; clicking the picked one again drops it
+	addChips := func(row *gtk.Box, names []string, set func(int), selected func() int) []*gtk.Button {
+		chips := make([]*gtk.Button, len(names))
+		for i, name := range names {
+			i := i
+			chip := gtk.NewButton()
+			chip.SetLabel(name)
+			chip.AddCSSClass("filter-chip")
+			chip.ConnectClicked(func() {
+				if selected() == i {
+					set(0)
+				} else {
+					set(i)
+				}
+				fm.applyFilters()
+			})
+			row.Append(chip)
+			chips[i] = chip
+		}
+		return chips
+	}
+
+	// The first range of each is "any", shown by no chip being picked
+	dateRow := addRow("Modified:")
+	fm.dateChips = addChips(dateRow, filter.DateRanges[1:], func(i int) {
+		fm.filterState.SetDateFilter(i + 1)
+	}, func() int { return fm.filterState.DateRange - 1 })
+
+	sizeNames := make([]string, len(filter.SizeRanges)-1)
+	for i, r := range filter.SizeRanges[1:] {
+		sizeNames[i] = r.Name
+	}
+	sizeRow := addRow("Size:")
+	fm.sizeChips = addChips(sizeRow, sizeNames, func(i int) {
+		fm.filterState.SetSizeFilter(i + 1)
+	}, func() int { return fm.filterState.SizeRange - 1 })
 
 	return panel
 }
 
+// applyFilters shows the filter state in the filter panel and the header's
+// filter button, and reloads the panes with it
+func (fm *FileManager) applyFilters() {
+	if fm.filterState.FileTypes == nil && fm.typeDropdown.Selected() != 0 {
+		// Changing the dropdown applies the filters again
+		fm.typeDropdown.SetSelected(0)
+		return
+	}
+	markChips := func(chips []*gtk.Button, selected int) {
+		for i, chip := range chips {
+			if i+1 == selected {
+				chip.AddCSSClass("filter-chip-active")
+			} else {
+				chip.RemoveCSSClass("filter-chip-active")
+			}
+		}
+	}
+	markChips(fm.dateChips, fm.filterState.DateRange)
+	markChips(fm.sizeChips, fm.filterState.SizeRange)
+
+	if fm.filterState.IsActive() {
+		fm.filterBtn.AddCSSClass("filter-active")
+		fm.filterBtn.SetTooltipText("Filters (active)")
+	} else {
+		fm.filterBtn.RemoveCSSClass("filter-active")
+		fm.filterBtn.SetTooltipText("Filters")
+	}
+	fm.refreshAll()
+}
+
 func (fm *FileManager) createStatusBar() *gtk.Box {
 	status := gtk.NewBox(gtk.OrientationHorizontal, 8)
 	status.AddCSSClass("status-bar")
@@ -474,6 +620,11 @@ func (fm *FileManager) createStatusBar() *gtk.Box {
 	spacer.SetHExpand(true)
 	status.Append(spacer)
 
+	fm.statusBranch = gtk.NewLabel("")
+	fm.statusBranch.AddCSSClass("status-branch")
+	fm.statusBranch.SetVisible(false)
+	status.Append(fm.statusBranch)
+
 	fm.statusRight = gtk.NewLabel("")
 	fm.statusRight.AddCSSClass("status-text-right")
 	fm.statusRight.SetHAlign(gtk.AlignEnd)
@@ -507,8 +658,7 @@ func (fm *FileManager) setupKeyboardShortcuts() {
 			}
 		case gdk.KEY_h:
 			if ctrl {
-				fm.filterState.ShowHidden = !fm.filterState.ShowHidden
-				fm.refresh()
+				fm.setShowHidden(!fm.filterState.ShowHidden)
 				return true
 			}
 		case gdk.KEY_n:
@@ -516,12 +666,60 @@ func (fm *FileManager) setupKeyboardShortcuts() {
 				fm.showNewFolderDialog()
 				return true
 			}
+		case gdk.KEY_d:
+			if ctrl {
+				fm.bookmarkCurrentFolder()
+				return true
+			}
+		case gdk.KEY_comma:
+			if ctrl {
+				fm.showPreferences()
+				return true
+			}
 		case gdk.KEY_F2:
 			fm.renameSelected()
 			return true
+		case gdk.KEY_F3:
+			fm.toggleSplit()
+			return true
+		case gdk.KEY_F4:
+			if shift {
+				fm.openTerminal(fm.currentPath)
+			} else {
+				fm.toggleTerminal()
+			}
+			return true
 		case gdk.KEY_F5:
 			fm.refresh()
 			return true
+		case gdk.KEY_t:
+			if ctrl {
+				fm.openTab(fm.currentPath)
+				return true
+			}
+		case gdk.KEY_w:
+			if ctrl {
+				fm.closeTab(fm.tab)
+				return true
+			}
+		case gdk.KEY_Tab, gdk.KEY_ISO_Left_Tab:
+			if ctrl && shift {
+				fm.switchTab(-1)
+				return true
+			} else if ctrl {
+				fm.switchTab(1)
+				return true
+			}
+		case gdk.KEY_Page_Down:
+			if ctrl {
+				fm.switchTab(1)
+				return true
+			}
+		case gdk.KEY_Page_Up:
+			if ctrl {
+				fm.switchTab(-1)
+				return true
+			}
 		case gdk.KEY_Delete:
 			if shift {
 				fm.permanentDelete()
@@ -550,7 +748,11 @@ func (fm *FileManager) setupKeyboardShortcuts() {
 				return true
 			}
 		case gdk.KEY_Return, gdk.KEY_KP_Enter:
-			fm.openSelected()
+			if alt {
+				fm.showSelectedProperties()
+			} else {
+				fm.openSelected()
+			}
 			return true
 		case gdk.KEY_BackSpace:
 			fm.goBack()
@@ -597,16 +799,23 @@ func (fm *FileManager) setupKeyboardShortcuts() {
 	fm.window.AddController(keyController)
 }
 
+// homeDir returns $HOME, or / when it is unset
+func homeDir() string {
+	if home := os.Getenv("HOME"); home != "" {
+		return home
+	}
+	return "/"
+}
+
 // Navigation methods
 func (fm *FileManager) goHome() {
-	home := os.Getenv("HOME")
-	if home == "" {
-		home = "/"
-	}
-	fm.navigateTo(home)
+	fm.navigateTo(homeDir())
 }
 
 func (fm *FileManager) goUp() {
+	if fm.inTrash() {
+		return
+	}
 	parent := fileview.GetParentPath(fm.currentPath)
 	if parent != fm.currentPath {
 		fm.navigateTo(parent)
@@ -636,11 +845,35 @@ func (fm *FileManager) navigateTo(path string) {
 	fm.updateLocationBar()
 }
 
+// setShowHidden reveals or hides dotfiles, files listed in .hidden and
+// files matching the hide patterns. It lasts until the window closes; the
+// show_hidden setting is the default.
+func (fm *FileManager) setShowHidden(show bool) {
+	if fm.filterState.ShowHidden == show {
+		return
+	}
+	fm.filterState.ShowHidden = show
+	if fm.hiddenBtn != nil {
+		fm.hiddenBtn.SetActive(show)
+	}
+	fm.refresh()
+}
+
 func (fm *FileManager) refresh() {
 	fm.loadDirectory(fm.currentPath)
 }
 
 func (fm *FileManager) loadDirectory(path string) {
+	p := fm.pane
+	p.trashBar.SetVisible(path == trashFilesDir())
+	p.columnHeader.SetVisible(path != trashFilesDir())
+	if path == trashFilesDir() {
+		fm.loadTrash()
+		return
+	}
+	fm.updateColumnHeader(p, path)
+	order := config.FolderSort(&fm.settings, path)
+
 	go func() {
 		entries, err := fileview.ReadDirectory(path)
 		if err != nil {
@@ -651,11 +884,21 @@ func (fm *FileManager) loadDirectory(path string) {
 		}
 
 		filtered := fm.filterState.ApplyFilters(entries)
-		sorted := fileview.SortEntries(filtered, fm.settings.SortBy, fm.settings.SortDescending)
+		sorted := fileview.SortEntries(filtered, order.By, order.Descending)
+
+		var repo *gitstatus.Repo
+		if !fileview.IsRemote(path) {
+			repo = gitstatus.Status(path)
+		}
 
 		glib.IdleAdd(func() {
-			fm.updateFileList(sorted)
-			fm.updateStatusBar()
+			fm.inPane(p, func() {
+				fm.git = repo
+				fm.updateFileList(sorted)
+			})
+			if p == fm.pane {
+				fm.updateStatusBar()
+			}
 		})
 	}()
 }
@@ -664,7 +907,9 @@ func (fm *FileManager) updateLocationBar() {
 	if fm.locationEntry != nil {
 		fm.locationEntry.SetText(fm.currentPath)
 	}
+	fm.updateTabLabel(fm.tab)
 	fm.updateNavButtons()
+	fm.followTerminal()
 }
 
 func (fm *FileManager) updateNavButtons() {
@@ -675,11 +920,34 @@ func (fm *FileManager) updateNavButtons() {
 		fm.forwardBtn.SetSensitive(fm.history.CanGoForward())
 	}
 	if fm.upBtn != nil {
-		fm.upBtn.SetSensitive(fm.currentPath != "/")
+		fm.upBtn.SetSensitive(fileview.GetParentPath(fm.currentPath) != fm.currentPath && !fm.inTrash())
 	}
 }
 
 func (fm *FileManager) updateFileList(entries []fileview.FileEntry) {
+	rows := make([]*gtk.ListBoxRow, len(entries))
+	for i, entry := range entries {
+		rows[i] = fm.createFileListRow(entry)
+	}
+	fm.setFileList(entries, rows, func(idx int) {
+		fm.openFile(fm.currentFiles[idx])
+	})
+
+	if fm.selectOnLoad != "" {
+		for i, entry := range entries {
+			if entry.Path == fm.selectOnLoad {
+				fm.fileListBox.SelectRow(rows[i])
+				rows[i].GrabFocus()
+				break
+			}
+		}
+		fm.selectOnLoad = ""
+	}
+}
+
+// setFileList shows rows, one for each of entries, in a new list. open is
+// called with the index of an activated row.
+func (fm *FileManager) setFileList(entries []fileview.FileEntry, rows []*gtk.ListBoxRow, open func(idx int)) {
 	fm.mu.Lock()
 	fm.currentFiles = entries
 	fm.mu.Unlock()
@@ -695,7 +963,7 @@ func (fm *FileManager) updateFileList(entries []fileview.FileEntry) {
 	fm.fileListBox.ConnectRowActivated(func(row *gtk.ListBoxRow) {
 		idx := row.Index()
 		if idx >= 0 && idx < len(fm.currentFiles) {
-			fm.openFile(fm.currentFiles[idx])
+			open(idx)
 		}
 	})
 
@@ -712,16 +980,16 @@ func (fm *FileManager) updateFileList(entries []fileview.FileEntry) {
 			if row != nil {
 				idx := row.Index()
 				if idx >= 0 && idx < len(fm.currentFiles) {
-					fm.openFile(fm.currentFiles[idx])
+					open(idx)
 				}
 			}
 		}
 	})
 	fm.fileListBox.AddController(gesture)
+	fm.attachContextMenu(fm.fileListBox)
+	fm.attachDragAndDrop(fm.pane, fm.fileListBox)
 
-	// Add all entries
-	for _, entry := range entries {
-		row := fm.createFileListRow(entry)
+	for _, row := range rows {
 		fm.fileListBox.Append(row)
 	}
 
@@ -739,13 +1007,15 @@ func (fm *FileManager) createFileListRow(entry fileview.FileEntry) *gtk.ListBoxR
 	box.SetMarginTop(6)
 	box.SetMarginBottom(6)
 
-	iconName := fileview.GetFileIcon(entry)
-	icon := gtk.NewImageFromIconName(iconName)
-	icon.SetPixelSize(20)
+	icon := icons.NewImage(fileview.GetFileIcon(entry), 20)
 	if entry.IsDir {
 		icon.AddCSSClass("file-icon-folder")
 	}
-	box.Append(icon)
+	if entry.IsSymlink {
+		box.Append(linkIcon(icon))
+	} else {
+		box.Append(icon)
+	}
 
 	nameLabel := gtk.NewLabel(entry.Name)
 	nameLabel.AddCSSClass("file-name")
@@ -755,24 +1025,60 @@ func (fm *FileManager) createFileListRow(entry fileview.FileEntry) *gtk.ListBoxR
 	if entry.IsHidden {
 		nameLabel.SetOpacity(0.6)
 	}
+	if entry.IsSymlink {
+		row.SetTooltipText(linkDescription(entry))
+		if entry.LinkBroken {
+			nameLabel.AddCSSClass("file-link-broken")
+		}
+	}
 	nameLabel.SetHAlign(gtk.AlignStart)
 	nameLabel.SetHExpand(true)
 	nameLabel.SetEllipsize(3)
 	nameLabel.SetMaxWidthChars(50)
 	box.Append(nameLabel)
 
+	if fm.git != nil {
+		if state := fm.git.State(entry.Path, entry.IsDir); state != gitstatus.Clean {
+			badge := gtk.NewLabel(state.Badge())
+			badge.AddCSSClass("git-badge")
+			badge.AddCSSClass("git-" + strings.ToLower(state.String()))
+			badge.SetTooltipText(state.String())
+			box.Append(badge)
+		}
+	}
+
+	// Folders leave the size blank to keep the columns lined up
+	sizeLabel := gtk.NewLabel("")
 	if !entry.IsDir {
-		sizeLabel := gtk.NewLabel(fileview.HumanizeSize(entry.Size))
-		sizeLabel.AddCSSClass("file-size")
-		sizeLabel.SetWidthChars(10)
-		box.Append(sizeLabel)
+		sizeLabel.SetText(fileview.HumanizeSize(entry.Size))
 	}
+	sizeLabel.AddCSSClass("file-size")
+	sizeLabel.SetWidthChars(10)
+	box.Append(sizeLabel)
+
+	typeLabel := gtk.NewLabel(fileview.GetFileTypeDescription(entry))
+	typeLabel.AddCSSClass("file-type")
+	typeLabel.SetWidthChars(16)
+	typeLabel.SetMaxWidthChars(16)
+	typeLabel.SetEllipsize(3)
+	box.Append(typeLabel)
 
 	dateLabel := gtk.NewLabel(fileview.FormatDate(entry.ModTime))
 	dateLabel.AddCSSClass("file-date")
 	dateLabel.SetWidthChars(12)
 	box.Append(dateLabel)
 
+	if fm.settings.ShowOwner {
+		ownerLabel := gtk.NewLabel(permissions.OwnerName(entry.UID))
+		ownerLabel.AddCSSClass("file-owner")
+		ownerLabel.SetWidthChars(10)
+		ownerLabel.SetEllipsize(3)
+		box.Append(ownerLabel)
+	}
+	if fm.settings.ShowPermissions {
+		box.Append(fm.createPermissionsCell(entry))
+	}
+
 	row.SetChild(box)
 	return row
 }
@@ -795,7 +1101,12 @@ func (fm *FileManager) updateStatusBar() {
 	}
 
 	statusText := ""
-	if dirCount > 0 && fileCount > 0 {
+	if fm.inTrash() {
+		statusText = "Trash is empty"
+		if n := len(fm.currentFiles); n > 0 {
+			statusText = fileview.Pluralize(n, "item", "items") + " in the trash"
+		}
+	} else if dirCount > 0 && fileCount > 0 {
 		statusText = fileview.Pluralize(dirCount, "folder", "folders") + ", " + fileview.Pluralize(fileCount, "file", "files")
 	} else if dirCount > 0 {
 		statusText = fileview.Pluralize(dirCount, "folder", "folders")
@@ -807,9 +1118,22 @@ func (fm *FileManager) updateStatusBar() {
 
 	fm.statusLabel.SetText(statusText)
 
+	if fm.git != nil && !fm.inTrash() {
+		branch := fm.git.Branch
+		if branch == "" {
+			branch = "detached HEAD"
+		}
+		fm.statusBranch.SetText("⎇ " + branch)
+		fm.statusBranch.SetTooltipText(fm.git.Root)
+	}
+	fm.statusBranch.SetVisible(fm.git != nil && !fm.inTrash())
+
+	// Servers don't report their free space
 	freeSpace, totalSpace := fileview.GetDiskSpace(fm.currentPath)
 	if totalSpace > 0 {
 		fm.statusRight.SetText(fileview.HumanizeSize(freeSpace) + " free of " + fileview.HumanizeSize(totalSpace))
+	} else {
+		fm.statusRight.SetText("")
 	}
 }
 
@@ -831,10 +1155,12 @@ func (fm *FileManager) onSelectionChanged() {
 
 	if len(fm.selectedFiles) == 1 {
 		entry := fm.selectedFiles[0]
-		fm.previewPanel.ShowPreview(filepath.Join(fm.currentPath, entry.Name), entry)
+		fm.previewPanel.ShowPreview(entry.Path, entry)
 	}
 
-	if len(fm.selectedFiles) > 0 {
+	if len(fm.selectedFiles) == 1 && fm.selectedFiles[0].IsSymlink {
+		fm.statusLabel.SetText(linkDescription(fm.selectedFiles[0]))
+	} else if len(fm.selectedFiles) > 0 {
 		var totalSize int64
 		for _, f := range fm.selectedFiles {
 			totalSize += f.Size
@@ -846,10 +1172,18 @@ func (fm *FileManager) onSelectionChanged() {
 }
 
 func (fm *FileManager) openFile(entry fileview.FileEntry) {
+	if entry.LinkBroken {
+		fm.showError(linkDescription(entry) + ", which doesn't exist")
+		return
+	}
 	if entry.IsDir {
 		fm.navigateTo(entry.Path)
 		return
 	}
+	if fileview.IsRemote(entry.Path) {
+		fm.openRemoteFile(entry)
+		return
+	}
 
 	config.AddRecentFile(&fm.settings, entry.Path)
 
@@ -886,17 +1220,28 @@ func (fm *FileManager) performSearch(query string) {
 		return
 	}
 
+	// The search engines walk local folders only
+	if fileview.IsRemote(fm.currentPath) {
+		fm.statusLabel.SetText("Search is not available on servers")
+		return
+	}
+
 	fm.searchActive = true
+	// Results are in order of relevance
+	fm.pane.columnHeader.SetVisible(false)
 
 	go func() {
 		ctx := context.Background()
 		var results []search.Result
 
 		if fm.contentSearchActive {
-			results = fm.searchEngine.ContentSearch(ctx, fm.currentPath, query, fm.settings.SearchContentMax, 100)
-		} else {
-			results = fm.searchEngine.Search(ctx, fm.currentPath, query, 200)
+			results = fm.searchEngine.ContentSearch(ctx, fm.currentPath, query, fm.settings.SearchContentMax, 500)
+			glib.IdleAdd(func() {
+				fm.showContentResults(results)
+			})
+			return
 		}
+		results = fm.searchEngine.Search(ctx, fm.currentPath, query, 200)
 
 		entries := make([]fileview.FileEntry, len(results))
 		for i, r := range results {
@@ -925,6 +1270,7 @@ func (fm *FileManager) copySelected() {
 
 	if len(files) > 0 {
 		fm.clipboard.Copy(files)
+		fm.shareClipboard(files, clipboard.OpCopy)
 	}
 }
 
@@ -938,26 +1284,52 @@ func (fm *FileManager) cutSelected() {
 
 	if len(files) > 0 {
 		fm.clipboard.Cut(files)
+		fm.shareClipboard(files, clipboard.OpCut)
 	}
 }
 
 func (fm *FileManager) paste() {
-	if !fm.clipboard.HasFiles() {
+	if fm.inTrash() {
 		return
 	}
+	target := fm.currentPath
+	fm.clipboardFiles(func(files []string, op clipboard.Operation) {
+		if op == clipboard.OpCut && fm.window.Clipboard().IsLocal() {
+			// Cut files can be pasted once, here or elsewhere
+			fm.clipboard.Clear()
+			fm.window.Clipboard().SetContent(nil)
+		}
+		fm.queueTransfer(files, op, target, false)
+	})
+}
 
-	go func() {
-		err := fm.clipboard.Paste(fm.currentPath)
-		glib.IdleAdd(func() {
-			if err != nil {
-				fm.showError("Paste failed: " + err.Error())
-			}
-			fm.refresh()
-		})
-	}()
+// pasteLinks makes links in the current folder to the files on the
+// clipboard, which stay where they are even if they were cut
+func (fm *FileManager) pasteLinks() {
+	if fm.inTrash() {
+		return
+	}
+	target := fm.currentPath
+	fm.clipboardFiles(func(files []string, op clipboard.Operation) {
+		err := clipboard.MakeLinks(files, target)
+		fm.refresh()
+		if canRetryAsAdmin(err, target) {
+			fm.showAdminRetry("You don't have permission to make links in "+filepath.Base(target)+".",
+				"Make Links as Administrator", func() error {
+					return admin.Run(admin.OpLink, append([]string{target}, files...)...)
+				})
+		} else if err != nil {
+			fm.showError("Failed to make links: " + err.Error())
+		}
+	})
 }
 
 func (fm *FileManager) trashSelected() {
+	if fm.inTrash() {
+		fm.confirmDeleteFromTrash()
+		return
+	}
+
 	fm.mu.RLock()
 	files := make([]fileview.FileEntry, len(fm.selectedFiles))
 	copy(files, fm.selectedFiles)
@@ -966,19 +1338,15 @@ func (fm *FileManager) trashSelected() {
 	if len(files) == 0 {
 		return
 	}
-
-	go func() {
-		err := clipboard.TrashFiles(files)
-		glib.IdleAdd(func() {
-			if err != nil {
-				fm.showError("Trash failed: " + err.Error())
-			}
-			fm.refresh()
-		})
-	}()
+	fm.startRemoval(files, false, fm.currentPath, false)
 }
 
 func (fm *FileManager) permanentDelete() {
+	if fm.inTrash() {
+		fm.confirmDeleteFromTrash()
+		return
+	}
+
 	fm.mu.RLock()
 	files := make([]fileview.FileEntry, len(fm.selectedFiles))
 	copy(files, fm.selectedFiles)
@@ -987,20 +1355,15 @@ func (fm *FileManager) permanentDelete() {
 	if len(files) == 0 {
 		return
 	}
-
-	go func() {
-		err := clipboard.DeleteFiles(files)
-		glib.IdleAdd(func() {
-			if err != nil {
-				fm.showError("Delete failed: " + err.Error())
-			}
-			fm.refresh()
-		})
-	}()
+	fm.startRemoval(files, true, fm.currentPath, false)
 }
 
 // Dialogs
 func (fm *FileManager) showNewFolderDialog() {
+	if fm.inTrash() {
+		return
+	}
+
 	dialog := gtk.NewDialog()
 	dialog.SetTitle("New Folder")
 	dialog.SetTransientFor(fm.window)
@@ -1036,34 +1399,28 @@ func (fm *FileManager) showNewFolderDialog() {
 
 	createBtn := gtk.NewButton()
 	createBtn.SetLabel("Create")
-	createBtn.ConnectClicked(func() {
+	create := func() {
 		name := entry.Text()
 		if name != "" {
-			path := filepath.Join(fm.currentPath, name)
-			if err := os.Mkdir(path, 0755); err != nil {
+			path := fileview.Join(fm.currentPath, name)
+			if err := fileview.Mkdir(path, 0755); canRetryAsAdmin(err, path) {
+				fm.showAdminRetry("Not allowed to create folders here.", "Create as Administrator", func() error {
+					return admin.Run(admin.OpMkdir, path)
+				})
+			} else if err != nil {
 				fm.showError("Failed to create folder: " + err.Error())
 			} else {
 				fm.refresh()
 			}
 		}
 		dialog.Destroy()
-	})
+	}
+	createBtn.ConnectClicked(create)
 	buttonBox.Append(createBtn)
 
 	content.Append(buttonBox)
 
-	entry.ConnectActivate(func() {
-		name := entry.Text()
-		if name != "" {
-			path := filepath.Join(fm.currentPath, name)
-			if err := os.Mkdir(path, 0755); err != nil {
-				fm.showError("Failed to create folder: " + err.Error())
-			} else {
-				fm.refresh()
-			}
-		}
-		dialog.Destroy()
-	})
+	entry.ConnectActivate(create)
 
 	dialog.Present()
 	entry.GrabFocus()
@@ -1071,8 +1428,15 @@ func (fm *FileManager) showNewFolderDialog() {
 
 func (fm *FileManager) renameSelected() {
 	fm.mu.RLock()
-	if len(fm.selectedFiles) != 1 {
+	if len(fm.selectedFiles) == 0 || fm.inTrash() {
+		fm.mu.RUnlock()
+		return
+	}
+	if len(fm.selectedFiles) > 1 {
+		files := make([]fileview.FileEntry, len(fm.selectedFiles))
+		copy(files, fm.selectedFiles)
 		fm.mu.RUnlock()
+		fm.showBatchRenameDialog(files)
 		return
 	}
 	file := fm.selectedFiles[0]
@@ -1118,44 +1482,86 @@ func (fm *FileManager) renameSelected() {
 
 	renameBtn := gtk.NewButton()
 	renameBtn.SetLabel("Rename")
-	renameBtn.ConnectClicked(func() {
+	apply := func() {
 		newName := entry.Text()
 		if newName != "" && newName != file.Name {
 			oldPath := file.Path
-			newPath := filepath.Join(filepath.Dir(oldPath), newName)
-			if err := os.Rename(oldPath, newPath); err != nil {
+			newPath := fileview.Join(fileview.GetParentPath(oldPath), newName)
+			if err := fileview.Rename(oldPath, newPath); canRetryAsAdmin(err, oldPath) {
+				fm.showAdminRetry("Not allowed to rename "+file.Name+".", "Rename as Administrator", func() error {
+					return admin.Run(admin.OpRename, oldPath, newPath)
+				})
+			} else if err != nil {
 				fm.showError("Failed to rename: " + err.Error())
 			} else {
 				fm.refresh()
 			}
 		}
 		dialog.Destroy()
-	})
+	}
+	renameBtn.ConnectClicked(apply)
 	buttonBox.Append(renameBtn)
 
 	content.Append(buttonBox)
 
-	entry.ConnectActivate(func() {
-		newName := entry.Text()
-		if newName != "" && newName != file.Name {
-			oldPath := file.Path
-			newPath := filepath.Join(filepath.Dir(oldPath), newName)
-			if err := os.Rename(oldPath, newPath); err != nil {
-				fm.showError("Failed to rename: " + err.Error())
-			} else {
-				fm.refresh()
-			}
-		}
-		dialog.Destroy()
-	})
+	entry.ConnectActivate(apply)
 
 	dialog.Present()
 	entry.GrabFocus()
 }
 
+// startTrashPurge runs the trash cleanup policy at startup and then
+// periodically, notifying the user when anything was removed. It only runs
+// while raven-files is open. Calling it again restarts it with the current
+// settings.
+func (fm *FileManager) startTrashPurge() {
+	if fm.cancelTrashPurge != nil {
+		fm.cancelTrashPurge()
+		fm.cancelTrashPurge = nil
+	}
+
+	policy := trash.Policy{
+		MaxAge:  time.Duration(fm.settings.TrashPurgeDays) * 24 * time.Hour,
+		MaxSize: fm.settings.TrashMaxSizeMB * 1024 * 1024,
+	}
+	if !policy.Enabled() {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	fm.cancelTrashPurge = cancel
+
+	go func() {
+		ticker := time.NewTicker(trashPurgeInterval)
+		defer ticker.Stop()
+
+		for {
+			result, err := trash.Purge(policy, time.Now())
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "raven-files: trash purge: %v\n", err)
+			}
+			if result.Removed() > 0 {
+				exec.Command("notify-send", "-a", "Raven Files", "-i", "user-trash-full",
+					"Trash cleaned up", result.Summary()).Start()
+			}
+
+			select {
+			case <-ticker.C:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
 func (fm *FileManager) showError(message string) {
+	fm.showMessage("Error", "dialog-error-symbolic", message)
+}
+
+// showMessage shows message in a dialog with an OK button
+func (fm *FileManager) showMessage(title, iconName, message string) {
 	dialog := gtk.NewDialog()
-	dialog.SetTitle("Error")
+	dialog.SetTitle(title)
 	dialog.SetTransientFor(fm.window)
 	dialog.SetModal(true)
 
@@ -1166,7 +1572,7 @@ func (fm *FileManager) showError(message string) {
 	content.SetMarginEnd(16)
 	content.SetSpacing(12)
 
-	icon := gtk.NewImageFromIconName("dialog-error-symbolic")
+	icon := gtk.NewImageFromIconName(iconName)
 	icon.SetPixelSize(48)
 	content.Append(icon)
 
@@ -0,0 +1,375 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	"raven-file-manager/pkg/clipboard"
+	"raven-file-manager/pkg/config"
+	"raven-file-manager/pkg/fileview"
+	"raven-file-manager/pkg/remote"
+
+	"github.com/diamondburned/gotk4/pkg/glib/v2"
+	"github.com/diamondburned/gotk4/pkg/gtk/v4"
+)
+
+// createNetworkSection adds the Network heading to the sidebar, listing
+// the connected servers and ending with Connect to Server
+func (fm *FileManager) createNetworkSection(sidebar *gtk.Box) {
+	label := gtk.NewLabel("Network")
+	label.AddCSSClass("sidebar-section")
+	label.SetHAlign(gtk.AlignStart)
+	sidebar.Append(label)
+
+	fm.networkList = gtk.NewListBox()
+	fm.networkList.AddCSSClass("sidebar-list")
+	fm.networkList.SetSelectionMode(gtk.SelectionSingle)
+	fm.networkList.ConnectRowActivated(func(row *gtk.ListBoxRow) {
+		fm.sidebarList.UnselectAll()
+		fm.devicesList.UnselectAll()
+		if conn, ok := fm.connectionAt(row); ok {
+			fm.navigateTo(conn.Start)
+			return
+		}
+		fm.networkList.UnselectAll()
+		fm.showConnectDialog("", "")
+	})
+	sidebar.Append(fm.networkList)
+
+	// Files dropped on a server go to the folder it opens in
+	fm.addDropTarget(fm.networkList, func(x, y float64) (string, gtk.Widgetter) {
+		row := fm.networkList.RowAtY(int(y))
+		if conn, ok := fm.connectionAt(row); ok {
+			return conn.Start, row
+		}
+		return "", nil
+	})
+
+	fm.showConnections()
+}
+
+// connectionAt returns the connection shown in row
+func (fm *FileManager) connectionAt(row *gtk.ListBoxRow) (*remote.Conn, bool) {
+	if row == nil || row.Index() < 0 || row.Index() >= len(fm.connections) {
+		return nil, false
+	}
+	return fm.connections[row.Index()], true
+}
+
+// showConnections lists the connected servers, then Connect to Server
+func (fm *FileManager) showConnections() {
+	for child := fm.networkList.FirstChild(); child != nil; child = fm.networkList.FirstChild() {
+		fm.networkList.Remove(child)
+	}
+	for _, conn := range fm.connections {
+		fm.networkList.Append(fm.createConnectionRow(conn))
+	}
+	fm.networkList.Append(fm.createSidebarRow("Connect to Server...", "network-server-symbolic"))
+}
+
+// createConnectionRow shows a connected server with a button to
+// disconnect it
+func (fm *FileManager) createConnectionRow(conn *remote.Conn) *gtk.ListBoxRow {
+	row := gtk.NewListBoxRow()
+
+	box := gtk.NewBox(gtk.OrientationHorizontal, 8)
+	box.SetMarginStart(8)
+	box.SetMarginEnd(8)
+	box.SetMarginTop(4)
+	box.SetMarginBottom(4)
+
+	icon := gtk.NewImageFromIconName("folder-remote")
+	icon.AddCSSClass("sidebar-item-icon")
+	box.Append(icon)
+
+	label := gtk.NewLabel(conn.Title())
+	label.AddCSSClass("sidebar-item")
+	label.SetHAlign(gtk.AlignStart)
+	label.SetHExpand(true)
+	label.SetEllipsize(3)
+	box.Append(label)
+
+	disconnectBtn := gtk.NewButtonFromIconName("media-eject-symbolic")
+	disconnectBtn.AddCSSClass("device-eject")
+	disconnectBtn.SetHasFrame(false)
+	disconnectBtn.SetTooltipText("Disconnect")
+	disconnectBtn.ConnectClicked(func() { fm.disconnect(conn) })
+	box.Append(disconnectBtn)
+
+	row.SetTooltipText(conn.Root())
+	row.SetChild(box)
+	return row
+}
+
+// openAddress goes to path, first connecting to its server if it is a
+// network location not connected yet
+func (fm *FileManager) openAddress(path string) {
+	if fileview.IsRemote(path) {
+		if _, _, _, err := fileview.Resolve(path); err != nil {
+			fm.connectToServer(path, "")
+			return
+		}
+	}
+	fm.navigateTo(path)
+}
+
+// showConnectDialog asks for a server address and password, with address
+// filled in. message, if set, says why a previous attempt failed.
+func (fm *FileManager) showConnectDialog(address, message string) {
+	dialog := gtk.NewDialog()
+	dialog.SetTitle("Connect to Server")
+	dialog.SetTransientFor(fm.window)
+	dialog.SetModal(true)
+	dialog.SetDefaultSize(440, -1)
+
+	content := dialog.ContentArea()
+	content.SetMarginTop(16)
+	content.SetMarginBottom(16)
+	content.SetMarginStart(16)
+	content.SetMarginEnd(16)
+	content.SetSpacing(12)
+
+	label := gtk.NewLabel("Server address:")
+	label.SetHAlign(gtk.AlignStart)
+	content.Append(label)
+
+	addressEntry := gtk.NewEntry()
+	addressEntry.SetPlaceholderText("sftp://user@host/folder or smb://server/share")
+	addressEntry.SetText(address)
+	content.Append(addressEntry)
+
+	passwordLabel := gtk.NewLabel("Password:")
+	passwordLabel.SetHAlign(gtk.AlignStart)
+	content.Append(passwordLabel)
+
+	passwordEntry := gtk.NewPasswordEntry()
+	passwordEntry.SetShowPeekIcon(true)
+	passwordEntry.SetObjectProperty("placeholder-text", "Not needed with SSH keys or guest access")
+	content.Append(passwordEntry)
+
+	if message != "" {
+		errorLabel := gtk.NewLabel(message)
+		errorLabel.AddCSSClass("connect-error")
+		errorLabel.SetHAlign(gtk.AlignStart)
+		errorLabel.SetWrap(true)
+		content.Append(errorLabel)
+	}
+
+	if len(fm.settings.RecentServers) > 0 {
+		recentLabel := gtk.NewLabel("Recent servers:")
+		recentLabel.SetHAlign(gtk.AlignStart)
+		content.Append(recentLabel)
+
+		recent := gtk.NewListBox()
+		recent.AddCSSClass("recent-servers")
+		for _, server := range fm.settings.RecentServers {
+			row := gtk.NewLabel(server)
+			row.SetHAlign(gtk.AlignStart)
+			row.SetMarginTop(4)
+			row.SetMarginBottom(4)
+			row.SetMarginStart(6)
+			recent.Append(row)
+		}
+		recent.ConnectRowSelected(func(row *gtk.ListBoxRow) {
+			if row != nil {
+				addressEntry.SetText(fm.settings.RecentServers[row.Index()])
+				passwordEntry.GrabFocus()
+			}
+		})
+		content.Append(recent)
+	}
+
+	buttonBox := gtk.NewBox(gtk.OrientationHorizontal, 8)
+	buttonBox.SetHAlign(gtk.AlignEnd)
+	buttonBox.SetMarginTop(16)
+
+	cancelBtn := gtk.NewButton()
+	cancelBtn.SetLabel("Cancel")
+	cancelBtn.AddCSSClass("cancel")
+	cancelBtn.ConnectClicked(func() { dialog.Destroy() })
+	buttonBox.Append(cancelBtn)
+
+	connect := func() {
+		address := addressEntry.Text()
+		if address == "" {
+			return
+		}
+		password := passwordEntry.Text()
+		dialog.Destroy()
+		fm.connectToServer(address, password)
+	}
+
+	connectBtn := gtk.NewButton()
+	connectBtn.SetLabel("Connect")
+	connectBtn.ConnectClicked(connect)
+	buttonBox.Append(connectBtn)
+
+	content.Append(buttonBox)
+
+	addressEntry.ConnectActivate(connect)
+	passwordEntry.ConnectActivate(connect)
+
+	dialog.Present()
+	if address == "" {
+		addressEntry.GrabFocus()
+	} else {
+		passwordEntry.GrabFocus()
+	}
+}
+
+// connectToServer connects to the server at address in the background and
+// opens it. A failed login brings the dialog back to try again.
+func (fm *FileManager) connectToServer(address, password string) {
+	loc, err := remote.Parse(address)
+	if err != nil {
+		fm.showConnectDialog(address, err.Error())
+		return
+	}
+	for _, conn := range fm.connections {
+		if conn.Root() == loc.Root() {
+			fm.navigateTo(addressIn(conn, loc))
+			return
+		}
+	}
+
+	fm.statusLabel.SetText("Connecting to " + loc.Host + "...")
+	go func() {
+		conn, err := remote.Connect(loc, password)
+		glib.IdleAdd(func() {
+			var unknown *remote.UnknownHostError
+			switch {
+			case errors.As(err, &unknown):
+				fm.showTrustHostDialog(unknown, func() {
+					fm.connectToServer(address, password)
+				})
+			case err != nil:
+				fm.updateStatusBar()
+				fm.showConnectDialog(address, fmt.Sprintf("Could not connect to %s: %v", loc.Host, err))
+			default:
+				fm.connections = append(fm.connections, conn)
+				fm.showConnections()
+				config.AddRecentServer(&fm.settings, address)
+				fm.navigateTo(conn.Start)
+			}
+		})
+	}()
+}
+
+// addressIn returns where loc points on the already connected conn
+func addressIn(conn *remote.Conn, loc remote.Location) string {
+	if loc.Path == "/" {
+		return conn.Start
+	}
+	return conn.Root() + loc.Path
+}
+
+// showTrustHostDialog shows the fingerprint of a server not seen before and
+// runs retry once the user trusts it, as ssh asks on first connection
+func (fm *FileManager) showTrustHostDialog(e *remote.UnknownHostError, retry func()) {
+	dialog := gtk.NewDialog()
+	dialog.SetTitle("Unknown Server")
+	dialog.SetTransientFor(fm.window)
+	dialog.SetModal(true)
+	dialog.SetDefaultSize(440, -1)
+
+	content := dialog.ContentArea()
+	content.SetMarginTop(16)
+	content.SetMarginBottom(16)
+	content.SetMarginStart(16)
+	content.SetMarginEnd(16)
+	content.SetSpacing(12)
+
+	label := gtk.NewLabel(fmt.Sprintf("The identity of %s can't be confirmed, as it hasn't been connected to before. Only trust it if its key fingerprint is:", e.Host))
+	label.SetWrap(true)
+	label.SetHAlign(gtk.AlignStart)
+	content.Append(label)
+
+	fingerprint := gtk.NewLabel(e.Fingerprint())
+	fingerprint.AddCSSClass("host-fingerprint")
+	fingerprint.SetSelectable(true)
+	fingerprint.SetWrap(true)
+	fingerprint.SetHAlign(gtk.AlignStart)
+	content.Append(fingerprint)
+
+	buttonBox := gtk.NewBox(gtk.OrientationHorizontal, 8)
+	buttonBox.SetHAlign(gtk.AlignEnd)
+	buttonBox.SetMarginTop(16)
+
+	cancelBtn := gtk.NewButton()
+	cancelBtn.SetLabel("Cancel")
+	cancelBtn.AddCSSClass("cancel")
+	cancelBtn.ConnectClicked(func() {
+		dialog.Destroy()
+		fm.updateStatusBar()
+	})
+	buttonBox.Append(cancelBtn)
+
+	trustBtn := gtk.NewButton()
+	trustBtn.SetLabel("Trust and Connect")
+	trustBtn.ConnectClicked(func() {
+		dialog.Destroy()
+		if err := remote.TrustHost(e); err != nil {
+			fm.showError("Could not save the host key: " + err.Error())
+			return
+		}
+		retry()
+	})
+	buttonBox.Append(trustBtn)
+
+	content.Append(buttonBox)
+	dialog.Present()
+	cancelBtn.GrabFocus()
+}
+
+// disconnect closes conn. Panes showing its files go home first.
+func (fm *FileManager) disconnect(conn *remote.Conn) {
+	fm.leaveFolder(conn.Root())
+	for i, c := range fm.connections {
+		if c == conn {
+			fm.connections = append(fm.connections[:i], fm.connections[i+1:]...)
+			break
+		}
+	}
+	fm.showConnections()
+
+	go func() {
+		if err := conn.Disconnect(); err != nil {
+			fmt.Fprintf(os.Stderr, "raven-files: disconnect %s: %v\n", conn.Root(), err)
+		}
+	}()
+}
+
+// openRemoteFile downloads a file from a server to the cache and opens the
+// copy, as apps opened with xdg-open can't read sftp:// or smb:// themselves
+func (fm *FileManager) openRemoteFile(entry fileview.FileEntry) {
+	cache, err := os.UserCacheDir()
+	if err != nil {
+		cache = os.TempDir()
+	}
+	cache = filepath.Join(cache, "raven-files")
+	dir, err := os.MkdirTemp(cache, "remote-")
+	if os.IsNotExist(err) && os.MkdirAll(cache, 0700) == nil {
+		dir, err = os.MkdirTemp(cache, "remote-")
+	}
+	if err != nil {
+		fm.showError("Could not open " + entry.Name + ": " + err.Error())
+		return
+	}
+	local := filepath.Join(dir, entry.Name)
+
+	fm.statusLabel.SetText("Downloading " + entry.Name + "...")
+	go func() {
+		err := clipboard.CopyFile(entry.Path, local)
+		glib.IdleAdd(func() {
+			fm.updateStatusBar()
+			if err != nil {
+				fm.showError("Could not download " + entry.Name + ": " + err.Error())
+				return
+			}
+			exec.Command("xdg-open", local).Start()
+		})
+	}()
+}
@@ -0,0 +1,257 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"raven-file-manager/pkg/config"
+	"raven-file-manager/pkg/fileview"
+
+	"github.com/diamondburned/gotk4/pkg/gio/v2"
+	"github.com/diamondburned/gotk4/pkg/gtk/v4"
+)
+
+// Recent handlers offered directly in the context menu's Open With submenu
+const openWithMenuHandlers = 3
+
+// contentType returns the MIME type of entry as GIO and mimeapps.list know
+// it, falling back to the type fileview detected
+func contentType(entry fileview.FileEntry) string {
+	uncertain, typ := gio.ContentTypeGuess(entry.Path, nil)
+	if (uncertain || gio.ContentTypeIsUnknown(typ)) && entry.MimeType != "" {
+		return entry.MimeType
+	}
+	return typ
+}
+
+// findApp returns the installed app with the desktop ID id, or nil
+func findApp(id string) *gio.AppInfo {
+	for _, app := range gio.AppInfoGetAll() {
+		if app.ID() == id {
+			return app
+		}
+	}
+	return nil
+}
+
+// recentHandlers returns the installed apps files of mimeType were last
+// opened with, most recent first
+func (fm *FileManager) recentHandlers(mimeType string) []*gio.AppInfo {
+	var apps []*gio.AppInfo
+	for _, id := range fm.settings.RecentHandlers[mimeType] {
+		if app := findApp(id); app != nil {
+			apps = append(apps, app)
+		}
+	}
+	return apps
+}
+
+// openWith opens entry with app and remembers app for the file's type.
+// With setDefault app also becomes the type's default in mimeapps.list.
+func (fm *FileManager) openWith(entry fileview.FileEntry, app *gio.AppInfo, setDefault bool) {
+	mimeType := contentType(entry)
+	if setDefault {
+		if err := app.SetAsDefaultForType(mimeType); err != nil {
+			fm.showError(fmt.Sprintf("Could not make %s the default: %v", app.DisplayName(), err))
+		}
+	} else {
+		app.SetAsLastUsedForType(mimeType)
+	}
+	config.AddRecentHandler(&fm.settings, mimeType, app.ID())
+	config.AddRecentFile(&fm.settings, entry.Path)
+
+	files := []gio.Filer{gio.NewFileForPath(entry.Path)}
+	if err := app.Launch(files, nil); err != nil {
+		fm.showError(fmt.Sprintf("Could not open %s with %s: %v", entry.Name, app.DisplayName(), err))
+	}
+}
+
+// openWithMenu returns the Open With submenu for entry: its recent
+// handlers, then the chooser
+func (fm *FileManager) openWithMenu(entry fileview.FileEntry) *gio.Menu {
+	menu := gio.NewMenu()
+
+	recent := gio.NewMenu()
+	for i, app := range fm.recentHandlers(contentType(entry)) {
+		if i == openWithMenuHandlers {
+			break
+		}
+		name := fmt.Sprintf("file-open-with-%d", i)
+		recent.Append(app.DisplayName(), "app."+name)
+		fm.addMenuAction(name, func() {
+			fm.openWith(entry, app, false)
+		})
+	}
+	if recent.NItems() > 0 {
+		menu.AppendSection("", recent)
+	}
+
+	other := gio.NewMenu()
+	other.Append("Other Application...", "app.file-open-with")
+	menu.AppendSection("", other)
+	fm.addMenuAction("file-open-with", func() {
+		fm.showOpenWithDialog(entry)
+	})
+	return menu
+}
+
+// showOpenWithDialog lets the user pick the app to open entry with. The
+// default and recently used apps come first, then the ones installed for
+// the file's type; the rest are listed on request.
+func (fm *FileManager) showOpenWithDialog(entry fileview.FileEntry) {
+	mimeType := contentType(entry)
+	description := gio.ContentTypeGetDescription(mimeType)
+
+	dialog := gtk.NewDialog()
+	dialog.SetTitle("Open With")
+	dialog.SetTransientFor(fm.window)
+	dialog.SetModal(true)
+	dialog.SetDefaultSize(420, 480)
+
+	content := dialog.ContentArea()
+	content.SetMarginTop(16)
+	content.SetMarginBottom(16)
+	content.SetMarginStart(16)
+	content.SetMarginEnd(16)
+	content.SetSpacing(12)
+
+	label := gtk.NewLabel(fmt.Sprintf("Open “%s” (%s) with:", entry.Name, description))
+	label.SetHAlign(gtk.AlignStart)
+	label.SetWrap(true)
+	content.Append(label)
+
+	search := gtk.NewSearchEntry()
+	search.SetPlaceholderText("Search applications...")
+	content.Append(search)
+
+	list := gtk.NewListBox()
+	list.SetSelectionMode(gtk.SelectionSingle)
+	scroll := gtk.NewScrolledWindow()
+	scroll.SetPolicy(gtk.PolicyNever, gtk.PolicyAutomatic)
+	scroll.SetVExpand(true)
+	scroll.SetChild(list)
+	content.Append(scroll)
+
+	showAll := gtk.NewCheckButtonWithLabel("Show all applications")
+	content.Append(showAll)
+
+	setDefault := gtk.NewCheckButtonWithLabel(fmt.Sprintf("Always use for %s files", description))
+	content.Append(setDefault)
+
+	buttonBox := gtk.NewBox(gtk.OrientationHorizontal, 8)
+	buttonBox.SetHAlign(gtk.AlignEnd)
+	buttonBox.SetMarginTop(16)
+
+	cancelBtn := gtk.NewButton()
+	cancelBtn.SetLabel("Cancel")
+	cancelBtn.AddCSSClass("cancel")
+	cancelBtn.ConnectClicked(func() { dialog.Destroy() })
+	buttonBox.Append(cancelBtn)
+
+	openBtn := gtk.NewButton()
+	openBtn.SetLabel("Open")
+	openBtn.SetSensitive(false)
+	buttonBox.Append(openBtn)
+	content.Append(buttonBox)
+
+	// apps holds the app of each row, in list order
+	var apps []*gio.AppInfo
+	fill := func() {
+		for child := list.FirstChild(); child != nil; child = list.FirstChild() {
+			list.Remove(child)
+		}
+		apps = nil
+
+		seen := make(map[string]bool)
+		add := func(app *gio.AppInfo, note string) {
+			if seen[app.ID()] || !app.ShouldShow() {
+				return
+			}
+			seen[app.ID()] = true
+			apps = append(apps, app)
+			list.Append(createAppRow(app, note))
+		}
+
+		if def := gio.AppInfoGetDefaultForType(mimeType, false); def != nil {
+			add(def, "Default")
+		}
+		for _, app := range fm.recentHandlers(mimeType) {
+			add(app, "Recently used")
+		}
+		for _, app := range gio.AppInfoGetAllForType(mimeType) {
+			add(app, "")
+		}
+		if showAll.Active() {
+			for _, app := range gio.AppInfoGetAll() {
+				add(app, "")
+			}
+		}
+		if row := list.RowAtIndex(0); row != nil {
+			list.SelectRow(row)
+		}
+	}
+	fill()
+	showAll.ConnectToggled(fill)
+
+	search.ConnectSearchChanged(func() {
+		query := strings.ToLower(search.Text())
+		for i, app := range apps {
+			row := list.RowAtIndex(i)
+			name := strings.ToLower(app.DisplayName())
+			row.SetVisible(query == "" || strings.Contains(name, query) || strings.Contains(strings.ToLower(app.ID()), query))
+		}
+	})
+
+	open := func(row *gtk.ListBoxRow) {
+		if row == nil || row.Index() < 0 || row.Index() >= len(apps) {
+			return
+		}
+		app := apps[row.Index()]
+		dialog.Destroy()
+		fm.openWith(entry, app, setDefault.Active())
+	}
+	list.ConnectRowSelected(func(row *gtk.ListBoxRow) {
+		openBtn.SetSensitive(row != nil)
+	})
+	list.ConnectRowActivated(open)
+	openBtn.ConnectClicked(func() {
+		open(list.SelectedRow())
+	})
+
+	dialog.Present()
+	search.GrabFocus()
+}
+
+// createAppRow shows an app's icon and name in the Open With list, with
+// note (such as "Default") after the name
+func createAppRow(app *gio.AppInfo, note string) *gtk.ListBoxRow {
+	row := gtk.NewListBoxRow()
+	box := gtk.NewBox(gtk.OrientationHorizontal, 12)
+	box.SetMarginTop(6)
+	box.SetMarginBottom(6)
+	box.SetMarginStart(6)
+	box.SetMarginEnd(6)
+
+	var icon *gtk.Image
+	if gicon := app.Icon(); gicon != nil {
+		icon = gtk.NewImageFromGIcon(gicon)
+	} else {
+		icon = gtk.NewImageFromIconName("application-x-executable")
+	}
+	icon.SetPixelSize(32)
+	box.Append(icon)
+
+	name := gtk.NewLabel(app.DisplayName())
+	name.SetHAlign(gtk.AlignStart)
+	name.SetHExpand(true)
+	box.Append(name)
+
+	if note != "" {
+		noteLabel := gtk.NewLabel(note)
+		noteLabel.AddCSSClass("dim-label")
+		box.Append(noteLabel)
+	}
+
+	row.SetChild(box)
+	return row
+}
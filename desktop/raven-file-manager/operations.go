@@ -0,0 +1,190 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"raven-file-manager/pkg/admin"
+	"raven-file-manager/pkg/clipboard"
+	"raven-file-manager/pkg/fileview"
+
+	"github.com/diamondburned/gotk4/pkg/glib/v2"
+)
+
+// Operations that take longer than this are announced with a desktop
+// notification even while the file manager has focus
+const notifyAfter = 5 * time.Second
+
+// operationNotice is a desktop notification for a finished file operation
+type operationNotice struct {
+	summary string
+	body    string
+	icon    string
+	folder  string // Opened by the "Open Folder" action
+	retry   func() // Offered as "Retry" when set
+}
+
+// shouldNotify reports whether an operation that ran for elapsed is worth
+// a notification: it was long, or the user has switched to another window
+func (fm *FileManager) shouldNotify(elapsed time.Duration) bool {
+	return elapsed >= notifyAfter || !fm.window.IsActive()
+}
+
+// sendNotice shows n through the notification daemon and runs the action
+// the user picks. notify-send prints the action's name once it is clicked.
+func (fm *FileManager) sendNotice(n operationNotice) {
+	args := []string{"-a", "Raven Files", "-i", n.icon}
+	if n.folder != "" {
+		args = append(args, "--action=open=Open Folder")
+	}
+	if n.retry != nil {
+		args = append(args, "--action=retry=Retry")
+	}
+	args = append(args, n.summary, n.body)
+
+	go func() {
+		output, err := exec.Command("notify-send", args...).Output()
+		if err != nil {
+			// notify-send before libnotify 0.7.10 has no actions
+			exec.Command("notify-send", "-a", "Raven Files", "-i", n.icon, n.summary, n.body).Run()
+			return
+		}
+
+		switch strings.TrimSpace(string(output)) {
+		case "open":
+			glib.IdleAdd(func() {
+				fm.navigateTo(n.folder)
+				fm.window.Present()
+			})
+		case "retry":
+			glib.IdleAdd(n.retry)
+		}
+	}()
+}
+
+// notifyTransfer announces the end of a paste. Retrying transfers only the
+// sources that failed.
+func (fm *FileManager) notifyTransfer(files []string, op clipboard.Operation, target string, report *clipboard.CopyReport, err error) {
+	verb, icon := "copied", "edit-copy"
+	if op == clipboard.OpCut {
+		verb, icon = "moved", "edit-cut"
+	}
+	title := strings.ToUpper(verb[:1]) + verb[1:]
+	dir := filepath.Base(target)
+
+	if err != nil && len(report.Failed) > 0 {
+		failed := report.Failed
+		fm.sendNotice(operationNotice{
+			summary: fmt.Sprintf("%d of %d items not %s", len(failed), len(files), verb),
+			body:    fmt.Sprintf("To %s: %v", dir, err),
+			icon:    "dialog-error",
+			folder:  target,
+			retry: func() {
+				fm.queueTransfer(failed, op, target, true)
+			},
+		})
+		return
+	}
+
+	fm.sendNotice(operationNotice{
+		summary: title + " to " + dir,
+		body:    fmt.Sprintf("%s (%s)", fileview.Pluralize(report.Files, "file", "files"), fileview.HumanizeSize(report.Bytes)),
+		icon:    icon,
+		folder:  target,
+	})
+}
+
+// notifyRemoval announces the end of moving files to the trash or deleting
+// them. Retrying handles the files that are still there.
+func (fm *FileManager) notifyRemoval(files []fileview.FileEntry, permanent bool, folder string, err error) {
+	verb, icon := "moved to the trash", "user-trash-full"
+	if permanent {
+		verb, icon = "deleted", "edit-delete"
+	}
+
+	if err != nil {
+		var left []fileview.FileEntry
+		for _, f := range files {
+			if _, statErr := os.Lstat(f.Path); statErr == nil {
+				left = append(left, f)
+			}
+		}
+		notice := operationNotice{
+			summary: fmt.Sprintf("%d of %d items not %s", len(left), len(files), verb),
+			body:    err.Error(),
+			icon:    "dialog-error",
+			folder:  folder,
+		}
+		if len(left) > 0 {
+			notice.retry = func() {
+				fm.startRemoval(left, permanent, folder, true)
+			}
+		}
+		fm.sendNotice(notice)
+		return
+	}
+
+	fm.sendNotice(operationNotice{
+		summary: fmt.Sprintf("%s %s", fileview.Pluralize(len(files), "item", "items"), verb),
+		body:    "From " + filepath.Base(folder),
+		icon:    icon,
+		folder:  folder,
+	})
+}
+
+// startRemoval trashes or deletes files in the background. With notify the
+// result is always announced; otherwise only slow runs and failures while
+// the window is in the background are, and failures get a dialog.
+func (fm *FileManager) startRemoval(files []fileview.FileEntry, permanent bool, folder string, notify bool) {
+	start := time.Now()
+	go func() {
+		var err error
+		if permanent {
+			err = clipboard.DeleteFiles(files)
+		} else {
+			err = clipboard.TrashFiles(files)
+		}
+		glib.IdleAdd(func() {
+			var left []string
+			if admin.IsPermission(err) {
+				left = remainingFiles(files)
+			}
+			if len(left) > 0 && canRetryAsAdmin(err, left...) {
+				// Root's files don't belong in the user's trash, so refused
+				// items can only be deleted
+				items := fileview.Pluralize(len(left), "item", "items")
+				message := fmt.Sprintf("Not allowed to delete %s.", items)
+				if !permanent {
+					message = fmt.Sprintf("Not allowed to move %s to the trash. Deleting as administrator can't be undone.", items)
+				}
+				fm.showAdminRetry(message, "Delete as Administrator", func() error {
+					return admin.Run(admin.OpDelete, left...)
+				})
+			} else if notify || fm.shouldNotify(time.Since(start)) {
+				fm.notifyRemoval(files, permanent, folder, err)
+			} else if err != nil {
+				if permanent {
+					fm.showError("Delete failed: " + err.Error())
+				} else {
+					fm.showError("Trash failed: " + err.Error())
+				}
+			}
+			fm.refreshAll()
+		})
+	}()
+}
+
+// remainingFiles returns the paths of files that are still there
+func remainingFiles(files []fileview.FileEntry) []string {
+	var left []string
+	for _, f := range files {
+		if _, err := os.Lstat(f.Path); err == nil {
+			left = append(left, f.Path)
+		}
+	}
+	return left
+}
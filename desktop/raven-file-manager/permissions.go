@@ -0,0 +1,122 @@
+package main
+
+import (
+	"fmt"
+	"io/fs"
+
+	"raven-file-manager/pkg/fileview"
+	"raven-file-manager/pkg/permissions"
+
+	"github.com/diamondburned/gotk4/pkg/glib/v2"
+	"github.com/diamondburned/gotk4/pkg/gtk/v4"
+)
+
+// createPermissionsCell returns the Permissions column for a list row.
+// Files the user owns get a button that opens the chmod popover.
+func (fm *FileManager) createPermissionsCell(entry fileview.FileEntry) gtk.Widgetter {
+	text := permissions.Format(entry.Mode)
+	if !permissions.IsOwner(entry.UID) || entry.IsSymlink {
+		label := gtk.NewLabel(text)
+		label.AddCSSClass("file-permissions")
+		label.SetTooltipText("Only the owner can change permissions")
+		return label
+	}
+
+	btn := gtk.NewMenuButton()
+	btn.SetLabel(text)
+	btn.AddCSSClass("flat")
+	btn.AddCSSClass("file-permissions")
+	btn.SetTooltipText("Change permissions")
+
+	popover := gtk.NewPopover()
+	btn.SetPopover(popover)
+	popover.ConnectShow(func() {
+		// Rebuilt on every open so unapplied changes are discarded
+		popover.SetChild(fm.createPermissionsEditor(entry, popover))
+	})
+	return btn
+}
+
+// createPermissionsEditor builds the rwx checkbox grid for entry
+func (fm *FileManager) createPermissionsEditor(entry fileview.FileEntry, popover *gtk.Popover) *gtk.Box {
+	box := gtk.NewBox(gtk.OrientationVertical, 8)
+	box.SetMarginTop(8)
+	box.SetMarginBottom(8)
+	box.SetMarginStart(8)
+	box.SetMarginEnd(8)
+
+	perm := entry.Mode.Perm()
+
+	grid := gtk.NewGrid()
+	grid.SetRowSpacing(4)
+	grid.SetColumnSpacing(12)
+	for col, bit := range permissions.Bits {
+		header := gtk.NewLabel(bit)
+		header.AddCSSClass("dim-label")
+		grid.Attach(header, col+1, 0, 1, 1)
+	}
+
+	octal := gtk.NewLabel("")
+	octal.AddCSSClass("dim-label")
+	octal.SetHAlign(gtk.AlignStart)
+	updateOctal := func() {
+		octal.SetText(fmt.Sprintf("%s (%04o)", permissions.Format(perm), uint32(perm)))
+	}
+	updateOctal()
+
+	for row, class := range permissions.Classes {
+		label := gtk.NewLabel(class)
+		label.SetHAlign(gtk.AlignStart)
+		grid.Attach(label, 0, row+1, 1, 1)
+
+		for col := range permissions.Bits {
+			mask := permissions.Bit(row, col)
+			check := gtk.NewCheckButton()
+			check.SetHAlign(gtk.AlignCenter)
+			check.SetActive(perm&mask != 0)
+			check.ConnectToggled(func() {
+				if check.Active() {
+					perm |= mask
+				} else {
+					perm &^= mask
+				}
+				updateOctal()
+			})
+			grid.Attach(check, col+1, row+1, 1, 1)
+		}
+	}
+	box.Append(grid)
+	box.Append(octal)
+
+	var recursive *gtk.CheckButton
+	if entry.IsDir {
+		recursive = gtk.NewCheckButtonWithLabel("Apply to enclosed files and folders")
+		recursive.SetTooltipText("Files only keep execute permission if they already had it")
+		box.Append(recursive)
+	}
+
+	applyBtn := gtk.NewButtonWithLabel("Apply")
+	applyBtn.AddCSSClass("suggested-action")
+	applyBtn.SetHAlign(gtk.AlignEnd)
+	applyBtn.ConnectClicked(func() {
+		popover.Popdown()
+		fm.applyPermissions(entry.Path, perm, recursive != nil && recursive.Active())
+	})
+	box.Append(applyBtn)
+
+	return box
+}
+
+// applyPermissions runs the chmod off the main loop, since a recursive
+// change can touch many files, then reloads the listing
+func (fm *FileManager) applyPermissions(path string, perm fs.FileMode, recursive bool) {
+	go func() {
+		err := permissions.Apply(path, perm, recursive)
+		glib.IdleAdd(func() {
+			if err != nil {
+				fm.showError("Failed to change permissions: " + err.Error())
+			}
+			fm.refresh()
+		})
+	}()
+}
@@ -0,0 +1,151 @@
+// Package admin retries file operations that failed for lack of permission
+// as root. The file manager runs itself through pkexec with HelperFlag, and
+// that copy, the helper, performs just the one operation it was given.
+package admin
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"raven-file-manager/pkg/clipboard"
+)
+
+// HelperFlag, as the first argument, makes the file manager act as the
+// privileged helper instead of opening a window
+const HelperFlag = "--admin-helper"
+
+// Operations the helper performs, with their arguments
+const (
+	OpCopy   = "copy"   // target source...
+	OpMove   = "move"   // target source...
+	OpLink   = "link"   // target source...
+	OpMkdir  = "mkdir"  // path
+	OpRename = "rename" // old new
+	OpDelete = "delete" // path...
+)
+
+// ErrDismissed is returned when the password prompt was canceled or the
+// user isn't allowed to act as administrator
+var ErrDismissed = errors.New("authentication was canceled")
+
+// IsPermission reports whether err means the operation needs more rights
+// than the user has
+func IsPermission(err error) bool {
+	return errors.Is(err, fs.ErrPermission)
+}
+
+// Available reports whether operations can be retried as administrator:
+// pkexec is installed and the file manager isn't running as root already
+func Available() bool {
+	if os.Geteuid() == 0 {
+		return false
+	}
+	_, err := exec.LookPath("pkexec")
+	return err == nil
+}
+
+// Run performs op with args as root, asking for the administrator password
+// through polkit
+func Run(op string, args ...string) error {
+	self, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	for _, arg := range args {
+		if !filepath.IsAbs(arg) {
+			return fmt.Errorf("%s is not an absolute path", arg)
+		}
+	}
+
+	var stderr bytes.Buffer
+	cmd := exec.Command("pkexec", append([]string{self, HelperFlag, op}, args...)...)
+	cmd.Stderr = &stderr
+	err = cmd.Run()
+
+	var exit *exec.ExitError
+	if errors.As(err, &exit) {
+		// pkexec's own codes for a dismissed prompt and a refusal
+		if code := exit.ExitCode(); code == 126 || code == 127 {
+			return ErrDismissed
+		}
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return errors.New(strings.TrimPrefix(msg, "raven-files: "))
+		}
+	}
+	return err
+}
+
+// Serve runs the helper with the arguments after HelperFlag and returns
+// the exit code
+func Serve(args []string) int {
+	if err := serve(args); err != nil {
+		fmt.Fprintf(os.Stderr, "raven-files: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+func serve(args []string) error {
+	if len(args) == 0 {
+		return errors.New("no operation given")
+	}
+	op, paths := args[0], args[1:]
+	for _, p := range paths {
+		if !filepath.IsAbs(p) {
+			return fmt.Errorf("%s is not an absolute path", p)
+		}
+	}
+
+	switch op {
+	case OpCopy, OpMove:
+		if len(paths) < 2 {
+			return fmt.Errorf("%s needs a target and sources", op)
+		}
+		transfer := clipboard.OpCopy
+		if op == OpMove {
+			transfer = clipboard.OpCut
+		}
+		// Without a conflict handler, taken names are kept and the new
+		// file gets a numbered name
+		_, err := clipboard.Transfer(paths[1:], transfer, paths[0], clipboard.CopyOptions{})
+		return err
+	case OpLink:
+		if len(paths) < 2 {
+			return errors.New("link needs a target and sources")
+		}
+		return clipboard.MakeLinks(paths[1:], paths[0])
+	case OpMkdir:
+		if len(paths) != 1 {
+			return errors.New("mkdir needs one path")
+		}
+		return os.Mkdir(paths[0], 0755)
+	case OpRename:
+		if len(paths) != 2 {
+			return errors.New("rename needs two paths")
+		}
+		if _, err := os.Lstat(paths[1]); err == nil {
+			return fmt.Errorf("%s already exists", filepath.Base(paths[1]))
+		}
+		return os.Rename(paths[0], paths[1])
+	case OpDelete:
+		var lastErr error
+		for _, p := range paths {
+			if p == "/" {
+				lastErr = errors.New("refusing to delete /")
+				continue
+			}
+			if err := os.RemoveAll(p); err != nil {
+				lastErr = err
+			}
+		}
+		return lastErr
+	}
+	return fmt.Errorf("unknown operation %s", strconv.Quote(op))
+}
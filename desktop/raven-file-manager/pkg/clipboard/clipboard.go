@@ -1,14 +1,16 @@
 package clipboard
 
 import (
+	"errors"
 	"fmt"
-	"io"
+	"io/fs"
 	"os"
-	"os/exec"
 	"path/filepath"
+	"strings"
 	"sync"
 
 	"raven-file-manager/pkg/fileview"
+	"raven-file-manager/pkg/trash"
 )
 
 // Operation represents the type of clipboard operation
@@ -24,6 +26,7 @@ const (
 type Manager struct {
 	files     []string
 	operation Operation
+	verify    bool
 	mu        sync.RWMutex
 }
 
@@ -88,39 +91,147 @@ func (c *Manager) HasFiles() bool {
 	return len(c.files) > 0
 }
 
-// Paste performs the paste operation to the target directory
-func (c *Manager) Paste(targetDir string) error {
+// SetVerify enables checksumming of copies that fall back to a buffered copy
+func (c *Manager) SetVerify(verify bool) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
+	c.verify = verify
+}
 
-	if len(c.files) == 0 {
-		return nil
+// Paste performs the paste operation to the target directory and reports
+// how the file data was transferred. Verification is set with SetVerify.
+// Cut files leave the clipboard as the paste starts, so the clipboard is
+// not held while they move.
+func (c *Manager) Paste(targetDir string, opts CopyOptions) (*CopyReport, error) {
+	c.mu.Lock()
+	files, op := c.files, c.operation
+	opts.Verify = c.verify
+	if c.operation == OpCut {
+		c.files = make([]string, 0)
+		c.operation = OpNone
+	}
+	c.mu.Unlock()
+
+	return Transfer(files, op, targetDir, opts)
+}
+
+// ErrIntoItself is returned for a folder pasted into itself or a folder in it
+var ErrIntoItself = errors.New("can't be pasted into itself")
+
+// Transfer copies or moves files into targetDir. Sources that could not be
+// transferred are listed in the report's Failed, so they can be retried.
+// A canceled transfer stops at once and returns ErrCanceled; the file being
+// copied is removed, and sources not yet moved stay where they are.
+func Transfer(files []string, op Operation, targetDir string, opts CopyOptions) (*CopyReport, error) {
+	if opts.Report == nil {
+		opts.Report = &CopyReport{}
 	}
+	report := opts.Report
 
 	var lastErr error
-	for _, src := range c.files {
-		dst := filepath.Join(targetDir, filepath.Base(src))
-		dst = ResolveConflict(dst)
+	for _, src := range files {
+		if err := opts.Control.wait(); err != nil {
+			return report, err
+		}
 
-		if c.operation == OpCut {
-			err := MoveFile(src, dst)
-			if err != nil {
-				lastErr = err
+		// Folders pasted into themselves would copy forever
+		if info, err := fileview.Lstat(src); err == nil && info.IsDir() && fileview.Contains(src, targetDir) {
+			lastErr = fmt.Errorf("%s: %w", filepath.Base(src), ErrIntoItself)
+			report.Failed = append(report.Failed, src)
+			continue
+		}
+
+		dst := fileview.Join(targetDir, filepath.Base(src))
+		if fileview.FileExists(dst) {
+			// Pasting into the source's own folder always keeps both
+			action := ConflictRename
+			if opts.Conflict != nil && dst != fileview.Clean(src) {
+				action = opts.Conflict(src, dst)
 			}
+			switch action {
+			case ConflictSkip:
+				report.Skipped = append(report.Skipped, src)
+				if opts.Progress != nil {
+					size, _ := Measure([]string{src})
+					opts.Progress(size)
+				}
+				continue
+			case ConflictOverwrite:
+				if err := clearForOverwrite(src, dst); err != nil {
+					lastErr = err
+					report.Failed = append(report.Failed, src)
+					continue
+				}
+			default:
+				dst = ResolveConflict(dst)
+			}
+		}
+
+		var err error
+		if op == OpCut {
+			err = moveFile(src, dst, opts)
 		} else {
-			err := CopyFile(src, dst)
+			err = CopyFileWith(src, dst, opts)
+		}
+		if errors.Is(err, ErrCanceled) {
+			return report, err
+		}
+		if err != nil {
+			lastErr = err
+			report.Failed = append(report.Failed, src)
+		}
+	}
+
+	return report, lastErr
+}
+
+// clearForOverwrite removes dst when src can't simply be copied over it: a
+// folder replacing a file or a file replacing a folder. A folder over a
+// folder is merged.
+func clearForOverwrite(src, dst string) error {
+	if fileview.IsDirectory(src) == fileview.IsDirectory(dst) {
+		return nil
+	}
+	return fileview.RemoveAll(dst)
+}
+
+// Measure returns the size of the files and everything in the folders
+// among them, as a copy would transfer it
+func Measure(files []string) (int64, error) {
+	var total int64
+	var lastErr error
+	for _, path := range files {
+		if fileview.IsRemote(path) {
+			err := fileview.Walk(path, func(p string, info fs.FileInfo) error {
+				if info.Mode().IsRegular() {
+					total += info.Size()
+				}
+				return nil
+			})
 			if err != nil {
 				lastErr = err
 			}
+			continue
 		}
-	}
 
-	if c.operation == OpCut {
-		c.files = make([]string, 0)
-		c.operation = OpNone
+		err := filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			// Links are copied as links, without what they point at
+			if !d.Type().IsRegular() {
+				return nil
+			}
+			if info, err := d.Info(); err == nil {
+				total += info.Size()
+			}
+			return nil
+		})
+		if err != nil {
+			lastErr = err
+		}
 	}
-
-	return lastErr
+	return total, lastErr
 }
 
 // ResolveConflict generates a unique filename if target exists
@@ -129,14 +240,14 @@ func ResolveConflict(path string) string {
 		return path
 	}
 
-	dir := filepath.Dir(path)
 	base := filepath.Base(path)
+	dir := strings.TrimSuffix(path, "/"+base)
 	ext := filepath.Ext(base)
 	name := base[:len(base)-len(ext)]
 
 	for i := 1; i < 1000; i++ {
 		newName := fmt.Sprintf("%s (%d)%s", name, i, ext)
-		newPath := filepath.Join(dir, newName)
+		newPath := fileview.Join(dir, newName)
 		if !fileview.FileExists(newPath) {
 			return newPath
 		}
@@ -147,36 +258,74 @@ func ResolveConflict(path string) string {
 
 // CopyFile copies a file or directory
 func CopyFile(src, dst string) error {
-	srcInfo, err := os.Stat(src)
+	return CopyFileWith(src, dst, CopyOptions{})
+}
+
+// CopyFileWith copies a file or directory using the given options
+func CopyFileWith(src, dst string, opts CopyOptions) error {
+	if fileview.IsRemote(src) || fileview.IsRemote(dst) {
+		return copyRemote(src, dst, opts)
+	}
+
+	srcInfo, err := os.Lstat(src)
 	if err != nil {
 		return err
 	}
 
-	if srcInfo.IsDir() {
-		return copyDir(src, dst)
+	switch {
+	case srcInfo.Mode()&os.ModeSymlink != 0:
+		return copyLink(src, dst)
+	case srcInfo.IsDir():
+		return copyDir(src, dst, opts)
 	}
+	return copyRegularFile(src, dst, srcInfo.Mode(), opts)
+}
 
-	return copyRegularFile(src, dst, srcInfo.Mode())
+// copyLink makes dst a link to what src links to. Links are copied rather
+// than followed, like cp -r, which also keeps links to folders above from
+// copying forever.
+func copyLink(src, dst string) error {
+	target, err := os.Readlink(src)
+	if err != nil {
+		return err
+	}
+	// Replacing a file, as when overwriting or merging folders
+	if info, err := os.Lstat(dst); err == nil && !info.IsDir() {
+		os.Remove(dst)
+	}
+	return os.Symlink(target, dst)
 }
 
-func copyRegularFile(src, dst string, mode os.FileMode) error {
+func copyRegularFile(src, dst string, mode os.FileMode, opts CopyOptions) error {
 	srcFile, err := os.Open(src)
 	if err != nil {
 		return err
 	}
 	defer srcFile.Close()
 
-	dstFile, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, mode)
+	info, err := srcFile.Stat()
+	if err != nil {
+		return err
+	}
+
+	dstFile, err := os.OpenFile(dst, os.O_CREATE|os.O_RDWR|os.O_TRUNC, mode)
 	if err != nil {
 		return err
 	}
 	defer dstFile.Close()
 
-	_, err = io.Copy(dstFile, srcFile)
-	return err
+	method, verified, err := copyFileData(srcFile, dstFile, info.Size(), opts)
+	if errors.Is(err, ErrCanceled) {
+		os.Remove(dst)
+	}
+	if err != nil {
+		return err
+	}
+	opts.Report.add(method, info.Size(), verified)
+	return nil
 }
 
-func copyDir(src, dst string) error {
+func copyDir(src, dst string, opts CopyOptions) error {
 	srcInfo, err := os.Stat(src)
 	if err != nil {
 		return err
@@ -196,14 +345,16 @@ func copyDir(src, dst string) error {
 		srcPath := filepath.Join(src, entry.Name())
 		dstPath := filepath.Join(dst, entry.Name())
 
-		if entry.IsDir() {
-			err = copyDir(srcPath, dstPath)
+		if entry.Type()&os.ModeSymlink != 0 {
+			err = copyLink(srcPath, dstPath)
+		} else if entry.IsDir() {
+			err = copyDir(srcPath, dstPath, opts)
 		} else {
-			info, err := entry.Info()
-			if err != nil {
+			info, infoErr := entry.Info()
+			if infoErr != nil {
 				continue
 			}
-			err = copyRegularFile(srcPath, dstPath, info.Mode())
+			err = copyRegularFile(srcPath, dstPath, info.Mode(), opts)
 		}
 
 		if err != nil {
@@ -216,32 +367,60 @@ func copyDir(src, dst string) error {
 
 // MoveFile moves a file or directory
 func MoveFile(src, dst string) error {
-	err := os.Rename(src, dst)
+	return moveFile(src, dst, CopyOptions{})
+}
+
+func moveFile(src, dst string, opts CopyOptions) error {
+	var size int64
+	if opts.Progress != nil {
+		size, _ = Measure([]string{src})
+	}
+	err := fileview.Rename(src, dst)
 	if err == nil {
+		if opts.Progress != nil {
+			opts.Progress(size)
+		}
 		return nil
 	}
 
-	err = CopyFile(src, dst)
+	err = CopyFileWith(src, dst, opts)
 	if err != nil {
 		return err
 	}
 
-	return os.RemoveAll(src)
+	return fileview.RemoveAll(src)
+}
+
+// MakeLinks creates a link in targetDir to each of files, named after it,
+// or numbered like a paste when the name is taken. Links are made between
+// local folders only.
+func MakeLinks(files []string, targetDir string) error {
+	if fileview.IsRemote(targetDir) {
+		return errors.New("links can't be made on servers")
+	}
+	var lastErr error
+	for _, file := range files {
+		if fileview.IsRemote(file) {
+			lastErr = fmt.Errorf("%s is on a server and can't be linked to", filepath.Base(file))
+			continue
+		}
+		dst := ResolveConflict(filepath.Join(targetDir, filepath.Base(file)))
+		if err := os.Symlink(file, dst); err != nil {
+			lastErr = err
+		}
+	}
+	return lastErr
 }
 
 // TrashFiles moves files to trash
 func TrashFiles(files []fileview.FileEntry) error {
 	var lastErr error
 	for _, f := range files {
-		err := exec.Command("gio", "trash", f.Path).Run()
-		if err != nil {
-			trashDir := filepath.Join(os.Getenv("HOME"), ".local/share/Trash/files")
-			os.MkdirAll(trashDir, 0755)
-			trashPath := filepath.Join(trashDir, f.Name)
-			trashPath = ResolveConflict(trashPath)
-			err = MoveFile(f.Path, trashPath)
+		if fileview.IsRemote(f.Path) {
+			lastErr = fmt.Errorf("%s is on a server and can't be trashed; delete it with Shift+Delete", f.Name)
+			continue
 		}
-		if err != nil {
+		if err := trash.Move(f.Path); err != nil {
 			lastErr = err
 		}
 	}
@@ -252,7 +431,7 @@ func TrashFiles(files []fileview.FileEntry) error {
 func DeleteFiles(files []fileview.FileEntry) error {
 	var lastErr error
 	for _, f := range files {
-		err := os.RemoveAll(f.Path)
+		err := fileview.RemoveAll(f.Path)
 		if err != nil {
 			lastErr = err
 		}
@@ -0,0 +1,120 @@
+package clipboard
+
+import (
+	"errors"
+	"io"
+	"sync"
+)
+
+// ErrCanceled is returned by a transfer that was canceled through its
+// Control
+var ErrCanceled = errors.New("canceled")
+
+// ConflictAction is what to do with a source whose name is already taken
+// in the target directory
+type ConflictAction int
+
+const (
+	ConflictRename    ConflictAction = iota // Keep both, naming the new one "name (1)"
+	ConflictOverwrite                       // Replace the existing file
+	ConflictSkip                            // Leave the source out
+)
+
+// Control pauses, resumes and cancels a running transfer from another
+// goroutine. The transfer stops between chunks of data.
+type Control struct {
+	mu       sync.Mutex
+	cond     *sync.Cond
+	paused   bool
+	canceled bool
+}
+
+// NewControl creates a Control for a transfer that is running
+func NewControl() *Control {
+	c := &Control{}
+	c.cond = sync.NewCond(&c.mu)
+	return c
+}
+
+// Pause holds the transfer at its next chunk
+func (c *Control) Pause() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.paused = true
+}
+
+// Resume continues a paused transfer
+func (c *Control) Resume() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.paused = false
+	c.cond.Broadcast()
+}
+
+// Cancel stops the transfer, paused or not
+func (c *Control) Cancel() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.canceled = true
+	c.cond.Broadcast()
+}
+
+// Paused reports whether the transfer is paused
+func (c *Control) Paused() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.paused
+}
+
+// Canceled reports whether the transfer was canceled
+func (c *Control) Canceled() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.canceled
+}
+
+// wait blocks while the transfer is paused. It returns ErrCanceled once the
+// transfer is canceled.
+func (c *Control) wait() error {
+	if c == nil {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for c.paused && !c.canceled {
+		c.cond.Wait()
+	}
+	if c.canceled {
+		return ErrCanceled
+	}
+	return nil
+}
+
+// step reports n more bytes copied and waits out a pause. It returns
+// ErrCanceled when the copy should stop.
+func (o CopyOptions) step(n int64) error {
+	if o.Progress != nil && n > 0 {
+		o.Progress(n)
+	}
+	return o.Control.wait()
+}
+
+// tracked reports whether the copy is followed, in which case data is
+// copied in smaller chunks so progress and pauses are timely
+func (o CopyOptions) tracked() bool {
+	return o.Progress != nil || o.Control != nil
+}
+
+// stepWriter calls step for every write, for copies through userspace
+type stepWriter struct {
+	w    io.Writer
+	opts CopyOptions
+}
+
+func (s stepWriter) Write(p []byte) (int, error) {
+	n, err := s.w.Write(p)
+	if err == nil {
+		err = s.opts.step(int64(n))
+	}
+	return n, err
+}
@@ -0,0 +1,319 @@
+package clipboard
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+
+	"raven-file-manager/pkg/fileview"
+)
+
+// CopyMethod identifies how the data of a file was transferred
+type CopyMethod int
+
+const (
+	MethodReflink CopyMethod = iota
+	MethodCopyFileRange
+	MethodSparse
+	MethodBuffered
+	MethodNetwork
+)
+
+func (m CopyMethod) String() string {
+	switch m {
+	case MethodReflink:
+		return "reflink"
+	case MethodCopyFileRange:
+		return "copy_file_range"
+	case MethodSparse:
+		return "sparse"
+	case MethodNetwork:
+		return "network"
+	default:
+		return "buffered"
+	}
+}
+
+// Largest single copy_file_range request
+const copyChunkSize = 1 << 30
+
+// Largest copy_file_range request when progress is followed
+const trackedChunkSize = 8 << 20
+
+// CopyOptions controls how regular files are copied
+type CopyOptions struct {
+	// Verify compares SHA-256 checksums of the source and the destination
+	// after the copy, whichever method was used
+	Verify bool
+	// Report, if set, collects the methods and sizes of each copied file
+	Report *CopyReport
+	// Progress, if set, is called with the number of bytes copied as the
+	// data moves
+	Progress func(n int64)
+	// Control, if set, pauses and cancels the copy
+	Control *Control
+	// Conflict, if set, decides what happens to a source whose name is
+	// taken in the target directory. Without it the source is renamed.
+	Conflict func(src, dst string) ConflictAction
+}
+
+// CopyReport summarizes the files transferred by a copy or paste
+type CopyReport struct {
+	Files    int
+	Bytes    int64
+	Verified int
+	Methods  map[CopyMethod]int
+	Failed   []string // Sources that were not transferred
+	Skipped  []string // Sources left out after a conflict
+}
+
+func (r *CopyReport) add(method CopyMethod, size int64, verified bool) {
+	if r == nil {
+		return
+	}
+	if r.Methods == nil {
+		r.Methods = make(map[CopyMethod]int)
+	}
+	r.Files++
+	r.Bytes += size
+	r.Methods[method]++
+	if verified {
+		r.Verified++
+	}
+}
+
+// MethodSummary describes the methods used, e.g. "reflink (3), buffered (1)"
+func (r *CopyReport) MethodSummary() string {
+	if r == nil || len(r.Methods) == 0 {
+		return "none"
+	}
+
+	methods := make([]CopyMethod, 0, len(r.Methods))
+	for m := range r.Methods {
+		methods = append(methods, m)
+	}
+	sort.Slice(methods, func(i, j int) bool { return methods[i] < methods[j] })
+
+	if len(methods) == 1 {
+		return methods[0].String()
+	}
+	parts := make([]string, len(methods))
+	for i, m := range methods {
+		parts[i] = fmt.Sprintf("%s (%d)", m, r.Methods[m])
+	}
+	return strings.Join(parts, ", ")
+}
+
+// Details returns label/value pairs for the operation details pane
+func (r *CopyReport) Details() [][2]string {
+	details := [][2]string{
+		{"Files:", fmt.Sprintf("%d", r.Files)},
+		{"Size:", fileview.HumanizeSize(r.Bytes)},
+		{"Method:", r.MethodSummary()},
+	}
+	if r.Verified > 0 {
+		details = append(details, [2]string{"Verified:", fmt.Sprintf("%d of %d", r.Verified, r.Files)})
+	}
+	return details
+}
+
+// copyFileData copies the contents of src into dst using the cheapest
+// method the filesystems support: a reflink clone, a sparse-aware copy,
+// an in-kernel copy_file_range, and finally a buffered copy. With verify
+// the result is checked against the source afterwards.
+func copyFileData(src, dst *os.File, size int64, opts CopyOptions) (CopyMethod, bool, error) {
+	method, err := transferFileData(src, dst, size, opts)
+	if err != nil || !opts.Verify {
+		return method, false, err
+	}
+	if err := verifyCopy(src, dst); err != nil {
+		return method, false, err
+	}
+	return method, true, nil
+}
+
+func transferFileData(src, dst *os.File, size int64, opts CopyOptions) (CopyMethod, error) {
+	if err := opts.step(0); err != nil {
+		return MethodBuffered, err
+	}
+
+	if err := unix.IoctlFileClone(int(dst.Fd()), int(src.Fd())); err == nil {
+		return MethodReflink, opts.step(size)
+	}
+
+	if isSparse(src, size) {
+		if ok, err := copySparse(src, dst, size, opts); ok {
+			return MethodSparse, err
+		}
+	}
+
+	if ok, err := copyRange(src, dst, 0, size, opts); ok {
+		return MethodCopyFileRange, err
+	}
+
+	return MethodBuffered, copyBuffered(src, dst, opts)
+}
+
+// isSparse reports whether fewer blocks are allocated than the size needs
+func isSparse(f *os.File, size int64) bool {
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return false
+	}
+	return st.Blocks*512 < size
+}
+
+// copySparse copies only the data segments of src and leaves holes in dst.
+// It returns false if the filesystem cannot report holes.
+func copySparse(src, dst *os.File, size int64, opts CopyOptions) (bool, error) {
+	fd := int(src.Fd())
+
+	var offset int64
+	for offset < size {
+		start, err := unix.Seek(fd, offset, unix.SEEK_DATA)
+		if err == unix.ENXIO {
+			// No more data: the rest of the file is a hole
+			if err := opts.step(size - offset); err != nil {
+				return true, err
+			}
+			break
+		}
+		if err != nil {
+			if offset == 0 {
+				return false, nil
+			}
+			return true, err
+		}
+
+		// Holes count as copied
+		if err := opts.step(start - offset); err != nil {
+			return true, err
+		}
+
+		end, err := unix.Seek(fd, start, unix.SEEK_HOLE)
+		if err != nil {
+			return true, err
+		}
+
+		if ok, err := copyRange(src, dst, start, end-start, opts); !ok || err != nil {
+			if err == nil {
+				err = copySegment(src, dst, start, end-start, opts)
+			}
+			if err != nil {
+				return true, err
+			}
+		}
+		offset = end
+	}
+
+	return true, dst.Truncate(size)
+}
+
+// copyRange copies length bytes at offset using copy_file_range. It returns
+// false if the kernel cannot copy between these files so the caller can
+// fall back to another method.
+func copyRange(src, dst *os.File, offset, length int64, opts CopyOptions) (bool, error) {
+	maxChunk := int64(copyChunkSize)
+	if opts.tracked() {
+		maxChunk = trackedChunkSize
+	}
+
+	inOff, outOff := offset, offset
+	remaining := length
+	for remaining > 0 {
+		chunk := remaining
+		if chunk > maxChunk {
+			chunk = maxChunk
+		}
+
+		n, err := unix.CopyFileRange(int(src.Fd()), &inOff, int(dst.Fd()), &outOff, int(chunk), 0)
+		if err != nil {
+			if remaining == length {
+				switch err {
+				case unix.ENOSYS, unix.EXDEV, unix.EINVAL, unix.EOPNOTSUPP, unix.EPERM:
+					return false, nil
+				}
+			}
+			return true, err
+		}
+		if n == 0 {
+			return true, fmt.Errorf("short copy of %s: source ended %d bytes early", src.Name(), remaining)
+		}
+		remaining -= int64(n)
+		if err := opts.step(int64(n)); err != nil {
+			return true, err
+		}
+	}
+
+	return true, nil
+}
+
+// copySegment copies a byte range through userspace
+func copySegment(src, dst *os.File, offset, length int64, opts CopyOptions) error {
+	reader := io.NewSectionReader(src, offset, length)
+	writer := io.NewOffsetWriter(dst, offset)
+	_, err := io.Copy(stepWriter{writer, opts}, reader)
+	return err
+}
+
+// copyBuffered copies through userspace
+func copyBuffered(src, dst *os.File, opts CopyOptions) error {
+	if _, err := src.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	if _, err := dst.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	if err := dst.Truncate(0); err != nil {
+		return err
+	}
+
+	// Hide the *os.File types so io.Copy doesn't try copy_file_range again
+	_, err := io.Copy(stepWriter{dst, opts}, struct{ io.Reader }{src})
+	return err
+}
+
+// verifyCopy re-reads both files from disk and compares their SHA-256
+func verifyCopy(src, dst *os.File) error {
+	if err := dst.Sync(); err != nil {
+		return err
+	}
+
+	srcSum, err := fileChecksum(src.Name())
+	if err != nil {
+		return err
+	}
+	dstSum, err := fileChecksum(dst.Name())
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(srcSum, dstSum) {
+		return fmt.Errorf("checksum mismatch after copying %s", src.Name())
+	}
+	return nil
+}
+
+func fileChecksum(path string) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, f); err != nil {
+		return nil, err
+	}
+	return hash.Sum(nil), nil
+}
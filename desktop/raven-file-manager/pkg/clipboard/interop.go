@@ -0,0 +1,75 @@
+package clipboard
+
+import (
+	"bufio"
+	"bytes"
+	"strings"
+
+	"raven-file-manager/pkg/fileview"
+)
+
+// Formats copied files are shared with other apps in. GNOME's format
+// carries whether they were cut; KDE's apps mark a cut with their own.
+const (
+	CopiedFilesType = "x-special/gnome-copied-files"
+	KDECutType      = "application/x-kde-cutselection"
+	URIListType     = "text/uri-list"
+)
+
+// EncodeCopiedFiles returns files as x-special/gnome-copied-files: "copy"
+// or "cut", then the URI of each file on its own line
+func EncodeCopiedFiles(files []string, op Operation) []byte {
+	var b strings.Builder
+	if op == OpCut {
+		b.WriteString("cut")
+	} else {
+		b.WriteString("copy")
+	}
+	for _, file := range files {
+		b.WriteString("\n" + fileview.URIFromPath(file))
+	}
+	return []byte(b.String())
+}
+
+// EncodeURIList returns files as a text/uri-list
+func EncodeURIList(files []string) []byte {
+	var b strings.Builder
+	for _, file := range files {
+		b.WriteString(fileview.URIFromPath(file) + "\r\n")
+	}
+	return []byte(b.String())
+}
+
+// DecodeCopiedFiles reads files copied or cut in another app from
+// x-special/gnome-copied-files. The operation is OpNone if data isn't in
+// that format.
+func DecodeCopiedFiles(data []byte) ([]string, Operation) {
+	action, list, _ := bytes.Cut(data, []byte("\n"))
+	var op Operation
+	switch string(bytes.TrimSpace(action)) {
+	case "copy":
+		op = OpCopy
+	case "cut":
+		op = OpCut
+	default:
+		return nil, OpNone
+	}
+	return DecodeURIList(list), op
+}
+
+// DecodeURIList returns the paths of the files in a text/uri-list, leaving
+// out comments and URIs the file manager can't open
+func DecodeURIList(data []byte) []string {
+	var files []string
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if path, ok := fileview.PathFromURI(line); ok {
+			files = append(files, path)
+		}
+	}
+	return files
+}
@@ -0,0 +1,80 @@
+package clipboard
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+
+	"raven-file-manager/pkg/fileview"
+)
+
+// copyRemote copies a file or folder to, from or between network
+// locations, streaming the data through their file systems. Copies over
+// the network are not verified, as that would read everything back.
+// Links can't be made through every file system, so links in folders are
+// copied as the files they point at, and links to folders are left out
+// rather than followed into a possible loop.
+func copyRemote(src, dst string, opts CopyOptions) error {
+	info, err := fileview.Stat(src)
+	if err != nil {
+		return err
+	}
+	return copyRemoteTree(src, dst, info, opts)
+}
+
+func copyRemoteTree(src, dst string, info fs.FileInfo, opts CopyOptions) error {
+	if !info.IsDir() {
+		return copyRemoteFile(src, dst, info.Size(), opts)
+	}
+
+	if err := fileview.Mkdir(dst, info.Mode().Perm()); err != nil && !fileview.IsDirectory(dst) {
+		return err
+	}
+	children, err := fileview.ReadDir(src)
+	if err != nil {
+		return err
+	}
+	for _, child := range children {
+		name := child.Name()
+		if child.Mode()&fs.ModeSymlink != 0 {
+			target, err := fileview.Stat(fileview.Join(src, name))
+			if err != nil || target.IsDir() {
+				continue
+			}
+			child = target
+		}
+		if err := copyRemoteTree(fileview.Join(src, name), fileview.Join(dst, name), child, opts); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func copyRemoteFile(src, dst string, size int64, opts CopyOptions) error {
+	if err := opts.step(0); err != nil {
+		return err
+	}
+
+	in, err := fileview.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := fileview.Create(dst)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(stepWriter{out, opts}, in)
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if errors.Is(err, ErrCanceled) {
+		fileview.RemoveAll(dst)
+	}
+	if err != nil {
+		return err
+	}
+	opts.Report.add(MethodNetwork, size, false)
+	return nil
+}
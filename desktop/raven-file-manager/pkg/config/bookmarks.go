@@ -0,0 +1,184 @@
+package config
+
+import (
+	"bufio"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"raven-file-manager/pkg/fileview"
+)
+
+// Icons of the folders bookmarked by default, by folder name under $HOME
+var folderIcons = map[string]string{
+	"Desktop":   "user-desktop",
+	"Documents": "folder-documents",
+	"Downloads": "folder-download",
+	"Pictures":  "folder-pictures",
+	"Videos":    "folder-videos",
+	"Music":     "folder-music",
+}
+
+// GTKBookmarksPath is the bookmarks file shared by GTK apps, shown in their
+// file choosers and in other file managers
+func GTKBookmarksPath() string {
+	return filepath.Join(os.Getenv("HOME"), ".config", "gtk-3.0", "bookmarks")
+}
+
+// GTKBookmarksModTime returns when the GTK bookmarks file last changed, or
+// the zero time if there is none
+func GTKBookmarksModTime() time.Time {
+	info, err := os.Stat(GTKBookmarksPath())
+	if err != nil {
+		return time.Time{}
+	}
+	return info.ModTime()
+}
+
+// NewBookmark returns a bookmark for path, named after the folder
+func NewBookmark(path string) Bookmark {
+	return Bookmark{Name: bookmarkName(path), Path: path, Icon: bookmarkIcon(path)}
+}
+
+func bookmarkName(path string) string {
+	if strings.Contains(path, "://") {
+		if u, err := url.Parse(path); err == nil && strings.Trim(u.Path, "/") == "" {
+			return u.Host
+		}
+	}
+	return filepath.Base(path)
+}
+
+func bookmarkIcon(path string) string {
+	home := os.Getenv("HOME")
+	switch {
+	case strings.Contains(path, "://"):
+		return "folder-remote"
+	case path == home:
+		return "user-home"
+	case filepath.Dir(path) == home && folderIcons[filepath.Base(path)] != "":
+		return folderIcons[filepath.Base(path)]
+	}
+	return "folder"
+}
+
+// ReadGTKBookmarks reads the GTK bookmarks file. Each line is a URI,
+// optionally followed by a name. Local folders become paths; other
+// locations, such as sftp:// and smb:// ones, are kept as addresses.
+func ReadGTKBookmarks() ([]Bookmark, error) {
+	file, err := os.Open(GTKBookmarksPath())
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var bookmarks []Bookmark
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		uri, name, _ := strings.Cut(line, " ")
+		path, ok := fileview.PathFromURI(uri)
+		if !ok {
+			continue
+		}
+		bookmark := NewBookmark(path)
+		if name = strings.TrimSpace(name); name != "" {
+			bookmark.Name = name
+		}
+		bookmarks = append(bookmarks, bookmark)
+	}
+	return bookmarks, scanner.Err()
+}
+
+// WriteGTKBookmarks replaces the GTK bookmarks file with bookmarks. Home
+// is left out, as GTK apps always list it.
+func WriteGTKBookmarks(bookmarks []Bookmark) error {
+	var b strings.Builder
+	for _, bookmark := range bookmarks {
+		if bookmark.Path == os.Getenv("HOME") {
+			continue
+		}
+		b.WriteString(fileview.URIFromPath(bookmark.Path))
+		if bookmark.Name != bookmarkName(bookmark.Path) {
+			b.WriteString(" " + bookmark.Name)
+		}
+		b.WriteString("\n")
+	}
+
+	path := GTKBookmarksPath()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, []byte(b.String()), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// SyncGTKBookmarks brings in changes other apps made to the GTK bookmarks
+// file. The file decides which bookmarks there are and their order, while
+// names and icons already set here are kept; only Home, which the file
+// never holds, stays without being listed. If there is no file yet, it is
+// created from settings' bookmarks. It reports whether they changed.
+func SyncGTKBookmarks(settings *Settings) bool {
+	shared, err := ReadGTKBookmarks()
+	if os.IsNotExist(err) {
+		WriteGTKBookmarks(settings.Bookmarks)
+		return false
+	}
+	if err != nil {
+		return false
+	}
+
+	known := make(map[string]Bookmark, len(settings.Bookmarks))
+	var synced []Bookmark
+	for _, bookmark := range settings.Bookmarks {
+		known[bookmark.Path] = bookmark
+		if bookmark.Path == os.Getenv("HOME") {
+			synced = append(synced, bookmark)
+		}
+	}
+	for _, bookmark := range shared {
+		if k, ok := known[bookmark.Path]; ok && bookmark.Name == bookmarkName(bookmark.Path) {
+			bookmark = k
+		} else if ok {
+			bookmark.Icon = k.Icon
+		}
+		synced = append(synced, bookmark)
+	}
+	if synced == nil {
+		synced = []Bookmark{}
+	}
+
+	if sameBookmarks(settings.Bookmarks, synced) {
+		return false
+	}
+	settings.Bookmarks = synced
+	SaveSettings(*settings)
+	return true
+}
+
+func sameBookmarks(a, b []Bookmark) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// SaveBookmarks saves settings after its bookmarks were changed, writing
+// them to the GTK bookmarks file too
+func SaveBookmarks(settings *Settings) error {
+	SaveSettings(*settings)
+	return WriteGTKBookmarks(settings.Bookmarks)
+}
@@ -11,9 +11,10 @@ import (
 type Settings struct {
 	ViewMode         string     `json:"view_mode"`
 	ShowHidden       bool       `json:"show_hidden"`
-	SortBy           string     `json:"sort_by"`
-	SortDescending   bool       `json:"sort_descending"`
+	SortBy           string     `json:"sort_by"`         // Order of folders not sorted by a column header
+	SortDescending   bool       `json:"sort_descending"` // With SortBy
 	ShowPreview      bool       `json:"show_preview"`
+	ShowTerminal     bool       `json:"show_terminal"` // Terminal panel below the files
 	PreviewSize      int        `json:"preview_size"`
 	SidebarWidth     int        `json:"sidebar_width"`
 	DefaultPath      string     `json:"default_path"`
@@ -25,6 +26,33 @@ type Settings struct {
 	SearchContentMax int64      `json:"search_content_max"`
 	WindowWidth      int        `json:"window_width"`
 	WindowHeight     int        `json:"window_height"`
+	VerifyCopies     bool       `json:"verify_copies"`     // Checksum every pasted file against its source; set in settings.json
+	TrashPurgeDays   int        `json:"trash_purge_days"`  // 0 keeps trashed items forever
+	TrashMaxSizeMB   int64      `json:"trash_max_size_mb"` // 0 means no size cap
+	ShowOwner        bool       `json:"show_owner"`        // Owner column in list view
+	ShowPermissions  bool       `json:"show_permissions"`  // Permissions column in list view
+	Editor           string     `json:"editor"`            // Opens content search results; {file} and {line} are filled in
+	HidePatterns     []string   `json:"hide_patterns"`     // Glob patterns hidden like dotfiles, e.g. "*.o", "node_modules"
+
+	// Searches under $HOME walk the disk instead of using the index in
+	// ~/.cache/raven/files-index
+	DisableSearchIndex bool `json:"disable_search_index"`
+
+	// Desktop IDs of the apps chosen in Open With, by MIME type, most recent first
+	RecentHandlers map[string][]string `json:"recent_handlers"`
+
+	// Orders chosen with the list view's column headers, by folder. Folders
+	// in the default order aren't listed.
+	FolderSorts map[string]Sort `json:"folder_sorts"`
+
+	// Addresses connected to with Connect to Server, most recent first.
+	// Passwords are never saved.
+	RecentServers []string `json:"recent_servers"`
+
+	// Folders of the tabs open when the window was last closed, and which
+	// one was showing
+	Tabs      []string `json:"tabs"`
+	ActiveTab int      `json:"active_tab"`
 }
 
 // Bookmark represents a saved location
@@ -34,6 +62,13 @@ type Bookmark struct {
 	Icon string `json:"icon"`
 }
 
+// Sort is the order of a folder's files: by "name", "size", "type" or
+// "date", folders always first
+type Sort struct {
+	By         string `json:"by"`
+	Descending bool   `json:"descending"`
+}
+
 var (
 	settingsMu   sync.RWMutex
 	settingsPath string
@@ -106,6 +141,7 @@ func LoadSettings() Settings {
 	}
 	settings.SortDescending = loaded.SortDescending
 	settings.ShowPreview = loaded.ShowPreview
+	settings.ShowTerminal = loaded.ShowTerminal
 	if loaded.PreviewSize > 0 {
 		settings.PreviewSize = loaded.PreviewSize
 	}
@@ -121,7 +157,8 @@ func LoadSettings() Settings {
 	if len(loaded.RecentFiles) > 0 {
 		settings.RecentFiles = loaded.RecentFiles
 	}
-	if len(loaded.Bookmarks) > 0 {
+	// An empty list means every bookmark was removed
+	if loaded.Bookmarks != nil {
 		settings.Bookmarks = loaded.Bookmarks
 	}
 	if loaded.SearchContentMax > 0 {
@@ -133,6 +170,19 @@ func LoadSettings() Settings {
 	if loaded.WindowHeight > 0 {
 		settings.WindowHeight = loaded.WindowHeight
 	}
+	settings.VerifyCopies = loaded.VerifyCopies
+	settings.TrashPurgeDays = loaded.TrashPurgeDays
+	settings.TrashMaxSizeMB = loaded.TrashMaxSizeMB
+	settings.ShowOwner = loaded.ShowOwner
+	settings.ShowPermissions = loaded.ShowPermissions
+	settings.Editor = loaded.Editor
+	settings.DisableSearchIndex = loaded.DisableSearchIndex
+	settings.HidePatterns = loaded.HidePatterns
+	settings.RecentHandlers = loaded.RecentHandlers
+	settings.RecentServers = loaded.RecentServers
+	settings.FolderSorts = loaded.FolderSorts
+	settings.Tabs = loaded.Tabs
+	settings.ActiveTab = loaded.ActiveTab
 
 	return settings
 }
@@ -169,3 +219,66 @@ func AddRecentFile(settings *Settings, path string) {
 	settings.RecentFiles = recent
 	SaveSettings(*settings)
 }
+
+// Handlers remembered for each MIME type
+const maxRecentHandlers = 5
+
+// AddRecentHandler records that files of mimeType were opened with the app
+// appID
+func AddRecentHandler(settings *Settings, mimeType, appID string) {
+	recent := []string{appID}
+	for _, id := range settings.RecentHandlers[mimeType] {
+		if id != appID {
+			recent = append(recent, id)
+		}
+	}
+	if len(recent) > maxRecentHandlers {
+		recent = recent[:maxRecentHandlers]
+	}
+
+	if settings.RecentHandlers == nil {
+		settings.RecentHandlers = make(map[string][]string)
+	}
+	settings.RecentHandlers[mimeType] = recent
+	SaveSettings(*settings)
+}
+
+// Server addresses remembered by Connect to Server
+const maxRecentServers = 10
+
+// AddRecentServer records a server address that was connected to
+func AddRecentServer(settings *Settings, address string) {
+	recent := []string{address}
+	for _, a := range settings.RecentServers {
+		if a != address {
+			recent = append(recent, a)
+		}
+	}
+	if len(recent) > maxRecentServers {
+		recent = recent[:maxRecentServers]
+	}
+
+	settings.RecentServers = recent
+	SaveSettings(*settings)
+}
+
+// FolderSort returns the order dir's files are shown in
+func FolderSort(settings *Settings, dir string) Sort {
+	if sort, ok := settings.FolderSorts[dir]; ok {
+		return sort
+	}
+	return Sort{By: settings.SortBy, Descending: settings.SortDescending}
+}
+
+// SetFolderSort records the order chosen for dir's files
+func SetFolderSort(settings *Settings, dir string, sort Sort) {
+	if sort == (Sort{By: settings.SortBy, Descending: settings.SortDescending}) {
+		delete(settings.FolderSorts, dir)
+	} else {
+		if settings.FolderSorts == nil {
+			settings.FolderSorts = make(map[string]Sort)
+		}
+		settings.FolderSorts[dir] = sort
+	}
+	SaveSettings(*settings)
+}
@@ -32,6 +32,12 @@ const FileManagerCSS = `
 		background: rgba(255, 255, 255, 0.15);
 	}
 
+	.nav-button:checked,
+	.nav-button.filter-active {
+		background: rgba(0, 150, 136, 0.25);
+		color: #009688;
+	}
+
 	.location-bar {
 		background-color: #1a2332;
 		border: 1px solid #333;
@@ -103,6 +109,30 @@ const FileManagerCSS = `
 		margin-right: 8px;
 	}
 
+	.device-usage trough {
+		min-height: 4px;
+		background-color: #333;
+		border-radius: 2px;
+	}
+
+	.device-usage block.filled {
+		background-color: #009688;
+		border-radius: 2px;
+	}
+
+	.device-eject {
+		background: transparent;
+		border: none;
+		padding: 2px;
+		min-width: 24px;
+		min-height: 24px;
+		color: #888;
+	}
+
+	.device-eject:hover {
+		color: #e0e0e0;
+	}
+
 	.file-list {
 		background-color: #0f1720;
 	}
@@ -143,6 +173,17 @@ const FileManagerCSS = `
 		font-weight: 500;
 	}
 
+	.file-link-emblem {
+		background-color: #0f1419;
+		border-radius: 3px;
+		color: #e0e0e0;
+	}
+
+	.file-link-broken {
+		color: #e57373;
+		text-decoration: line-through;
+	}
+
 	.file-name-hidden {
 		color: #888;
 	}
@@ -159,6 +200,127 @@ const FileManagerCSS = `
 		min-width: 120px;
 	}
 
+	.git-badge {
+		font-family: monospace;
+		font-size: 11px;
+		font-weight: bold;
+		padding: 0 4px;
+		border-radius: 3px;
+	}
+
+	.git-modified {
+		color: #ffb74d;
+		background-color: rgba(255, 183, 77, 0.15);
+	}
+
+	.git-staged {
+		color: #81c784;
+		background-color: rgba(129, 199, 132, 0.15);
+	}
+
+	.git-untracked {
+		color: #90a4ae;
+		background-color: rgba(144, 164, 174, 0.15);
+	}
+
+	.git-conflicted {
+		color: #e57373;
+		background-color: rgba(229, 115, 115, 0.15);
+	}
+
+	.file-type {
+		color: #888;
+		font-size: 12px;
+		min-width: 140px;
+	}
+
+	.column-header {
+		background-color: #0f1720;
+		border-bottom: 1px solid rgba(255, 255, 255, 0.08);
+		padding: 6px 24px;
+	}
+
+	.column-header-cell {
+		color: #aaa;
+		font-size: 12px;
+		font-weight: 500;
+	}
+
+	.column-header-cell:hover {
+		color: #e0e0e0;
+	}
+
+	.column-header-sorted {
+		color: #4db6ac;
+	}
+
+	.file-origin {
+		color: #888;
+		font-size: 12px;
+		min-width: 160px;
+	}
+
+	.trash-bar {
+		background-color: #1a2332;
+		border-bottom: 1px solid #333;
+		padding: 8px 12px;
+	}
+
+	.trash-bar-text {
+		color: #888;
+		font-size: 12px;
+	}
+
+	.trash-bar button.destructive {
+		background-color: #f44336;
+		color: #fff;
+	}
+
+	.trash-bar button.destructive:hover {
+		background-color: #e53935;
+	}
+
+	.connect-error {
+		color: #f44336;
+		font-size: 12px;
+	}
+
+	.host-fingerprint {
+		color: #e0e0e0;
+		font-family: monospace;
+		font-size: 12px;
+	}
+
+	.recent-servers {
+		background-color: #1a2332;
+		border: 1px solid #2a3a50;
+		border-radius: 4px;
+	}
+
+	.rename-preview {
+		background-color: #1a2332;
+		border: 1px solid #2a3a50;
+		border-radius: 4px;
+	}
+
+	.rename-problem {
+		color: #f44336;
+	}
+
+	.file-owner {
+		color: #888;
+		font-size: 12px;
+		min-width: 80px;
+	}
+
+	.file-permissions {
+		color: #888;
+		font-family: monospace;
+		font-size: 12px;
+		min-height: 0;
+		padding: 0 4px;
+	}
+
 	.file-grid {
 		background-color: #0f1720;
 	}
@@ -193,11 +355,53 @@ const FileManagerCSS = `
 		text-align: center;
 	}
 
+	.file-tabs > header {
+		background-color: #1a2332;
+		border-bottom: 1px solid #333;
+	}
+
+	.file-tabs > header tab {
+		padding: 4px 8px;
+		color: #888;
+	}
+
+	.file-tabs > header tab:checked {
+		color: #e0e0e0;
+		box-shadow: inset 0 -2px #009688;
+	}
+
+	.tab-close {
+		background: transparent;
+		border: none;
+		padding: 0;
+		min-width: 20px;
+		min-height: 20px;
+		color: #888;
+	}
+
+	.tab-close:hover {
+		color: #e0e0e0;
+	}
+
+	.file-pane {
+		border: 1px solid transparent;
+	}
+
+	.file-pane-active {
+		border-color: #009688;
+	}
+
 	.preview-pane {
 		background-color: #151d28;
 		border-left: 1px solid #333;
 	}
 
+	.terminal-panel {
+		background-color: #0f1419;
+		border-top: 1px solid #333;
+		padding: 4px 8px;
+	}
+
 	.preview-header {
 		background-color: #1a2332;
 		padding: 12px 16px;
@@ -247,6 +451,19 @@ const FileManagerCSS = `
 		font-size: 12px;
 	}
 
+	.preview-archive {
+		background-color: #0f1720;
+		border-radius: 6px;
+		margin: 16px 16px 0 16px;
+		font-size: 12px;
+	}
+
+	.preview-media-controls {
+		background-color: #1a2332;
+		border-radius: 6px;
+		padding: 4px;
+	}
+
 	.status-bar {
 		background-color: #1a2332;
 		border-top: 1px solid #333;
@@ -258,6 +475,11 @@ const FileManagerCSS = `
 		font-size: 12px;
 	}
 
+	.status-branch {
+		color: #4db6ac;
+		font-size: 12px;
+	}
+
 	.status-text-right {
 		color: #888;
 		font-size: 12px;
@@ -288,6 +510,26 @@ const FileManagerCSS = `
 		margin-right: 8px;
 	}
 
+	.filter-chip {
+		background: transparent;
+		border: 1px solid #333;
+		border-radius: 12px;
+		padding: 2px 10px;
+		color: #e0e0e0;
+		font-size: 12px;
+	}
+
+	.filter-chip:hover {
+		border-color: #444;
+		background: rgba(255, 255, 255, 0.05);
+	}
+
+	.filter-chip-active {
+		background: rgba(0, 150, 136, 0.25);
+		border-color: #009688;
+		color: #009688;
+	}
+
 	.filter-clear {
 		background: transparent;
 		border: none;
@@ -574,4 +816,22 @@ const FileManagerCSS = `
 	.dragging {
 		opacity: 0.5;
 	}
+
+	.content-line-number {
+		color: #009688;
+		font-family: monospace;
+		font-size: 12px;
+	}
+
+	.content-match {
+		color: #e0e0e0;
+		font-family: monospace;
+		font-size: 12px;
+	}
+
+	.content-context {
+		color: #666;
+		font-family: monospace;
+		font-size: 12px;
+	}
 `
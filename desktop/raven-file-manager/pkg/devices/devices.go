@@ -0,0 +1,314 @@
+// Package devices finds removable drives and their partitions in sysfs and
+// mounts, unmounts and ejects them through UDisks2's udisksctl.
+package devices
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"raven-file-manager/pkg/fileview"
+)
+
+// ErrNoUDisks is returned by the actions when udisksctl is not installed
+var ErrNoUDisks = errors.New("udisksctl is not installed")
+
+// Partitions smaller than this, such as extended partition headers, hold
+// no filesystem worth showing
+const minPartitionSize = 1 << 20
+
+// Block devices that are never removable drives
+var skipPrefixes = []string{"loop", "ram", "zram", "sr", "dm-", "md", "nvme"}
+
+// Device is a partition of a removable drive, or the whole drive when it
+// has no partition table
+type Device struct {
+	Name       string // Kernel name, e.g. "sdb1"
+	Path       string // Device node, e.g. "/dev/sdb1"
+	Drive      string // Device node of the whole drive, e.g. "/dev/sdb"
+	Model      string // Vendor and model of the drive
+	Label      string // Filesystem label, if any
+	FSType     string // Filesystem type as udev detected it, if known
+	Size       int64
+	MountPoint string // Empty while not mounted
+}
+
+// Title names the device in the sidebar: its label, or its size and model
+func (d Device) Title() string {
+	if d.Label != "" {
+		return d.Label
+	}
+	if d.Model != "" {
+		return fileview.HumanizeSize(d.Size) + " " + d.Model
+	}
+	return fileview.HumanizeSize(d.Size) + " Volume"
+}
+
+// Mounted reports whether the device is mounted
+func (d Device) Mounted() bool {
+	return d.MountPoint != ""
+}
+
+// Usage returns the free and total space of a mounted device
+func (d Device) Usage() (free, total int64) {
+	if !d.Mounted() {
+		return 0, 0
+	}
+	return fileview.GetDiskSpace(d.MountPoint)
+}
+
+// Scan returns the partitions of the removable and USB drives plugged in,
+// in device order
+func Scan() []Device {
+	entries, err := os.ReadDir("/sys/block")
+	if err != nil {
+		return nil
+	}
+	mounts := mountPoints()
+
+	var devices []Device
+	for _, entry := range entries {
+		name := entry.Name()
+		if skipped(name) {
+			continue
+		}
+		sysPath := filepath.Join("/sys/block", name)
+		if !removable(sysPath) {
+			continue
+		}
+
+		// Card readers without a card report no size
+		size := readSize(sysPath)
+		if size == 0 {
+			continue
+		}
+		drive := Device{
+			Name:  name,
+			Path:  "/dev/" + name,
+			Drive: "/dev/" + name,
+			Model: driveModel(sysPath),
+			Size:  size,
+		}
+
+		partitions := drivePartitions(sysPath, drive)
+		if len(partitions) == 0 {
+			partitions = []Device{drive}
+		}
+		for _, d := range partitions {
+			devPath := filepath.Join(sysPath, d.Name)
+			if d.Name == drive.Name {
+				devPath = sysPath
+			}
+			d.Label, d.FSType = udevFilesystem(devPath)
+			d.MountPoint = mounts[d.Path]
+			devices = append(devices, d)
+		}
+	}
+
+	sort.Slice(devices, func(i, j int) bool {
+		return devices[i].Path < devices[j].Path
+	})
+	return devices
+}
+
+func skipped(name string) bool {
+	for _, prefix := range skipPrefixes {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// removable reports whether the drive is marked removable or hangs off a
+// USB bus, as USB hard drives are not marked removable
+func removable(sysPath string) bool {
+	data, err := os.ReadFile(filepath.Join(sysPath, "removable"))
+	if err == nil && strings.TrimSpace(string(data)) == "1" {
+		return true
+	}
+	link, err := os.Readlink(sysPath)
+	return err == nil && strings.Contains(link, "/usb")
+}
+
+// readSize returns the size in bytes of a drive or partition in sysfs
+func readSize(sysPath string) int64 {
+	data, err := os.ReadFile(filepath.Join(sysPath, "size"))
+	if err != nil {
+		return 0
+	}
+	sectors, _ := strconv.ParseInt(strings.TrimSpace(string(data)), 10, 64)
+	return sectors * 512
+}
+
+func driveModel(sysPath string) string {
+	var parts []string
+	for _, file := range []string{"device/vendor", "device/model"} {
+		data, err := os.ReadFile(filepath.Join(sysPath, file))
+		if err != nil {
+			continue
+		}
+		if part := strings.TrimSpace(string(data)); part != "" {
+			parts = append(parts, part)
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// drivePartitions lists the partitions of drive, which sysfs shows as
+// subdirectories with a partition file
+func drivePartitions(sysPath string, drive Device) []Device {
+	entries, err := os.ReadDir(sysPath)
+	if err != nil {
+		return nil
+	}
+
+	var partitions []Device
+	for _, entry := range entries {
+		name := entry.Name()
+		if !strings.HasPrefix(name, drive.Name) {
+			continue
+		}
+		partPath := filepath.Join(sysPath, name)
+		if _, err := os.Stat(filepath.Join(partPath, "partition")); err != nil {
+			continue
+		}
+		size := readSize(partPath)
+		if size < minPartitionSize {
+			continue
+		}
+
+		d := drive
+		d.Name = name
+		d.Path = "/dev/" + name
+		d.Size = size
+		partitions = append(partitions, d)
+	}
+	return partitions
+}
+
+// udevFilesystem reads the filesystem label and type udev probed for the
+// block device at sysPath from its database in /run/udev/data
+func udevFilesystem(sysPath string) (label, fstype string) {
+	dev, err := os.ReadFile(filepath.Join(sysPath, "dev"))
+	if err != nil {
+		return "", ""
+	}
+	file, err := os.Open("/run/udev/data/b" + strings.TrimSpace(string(dev)))
+	if err != nil {
+		return "", ""
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if value, ok := strings.CutPrefix(line, "E:ID_FS_LABEL="); ok {
+			label = value
+		} else if value, ok := strings.CutPrefix(line, "E:ID_FS_TYPE="); ok {
+			fstype = value
+		}
+	}
+	return label, fstype
+}
+
+// mountPoints maps device nodes to where they are mounted, from
+// /proc/self/mountinfo. A device mounted twice keeps its first mount.
+func mountPoints() map[string]string {
+	points := make(map[string]string)
+
+	file, err := os.Open("/proc/self/mountinfo")
+	if err != nil {
+		return points
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		// 96 29 8:17 / /run/media/user/USB rw,nosuid - vfat /dev/sdb1 rw
+		before, after, ok := strings.Cut(scanner.Text(), " - ")
+		if !ok {
+			continue
+		}
+		fields, rest := strings.Fields(before), strings.Fields(after)
+		if len(fields) < 5 || len(rest) < 2 {
+			continue
+		}
+		if _, seen := points[rest[1]]; !seen {
+			points[rest[1]] = unescapeMountPath(fields[4])
+		}
+	}
+	return points
+}
+
+// unescapeMountPath undoes mountinfo's octal escapes, such as \040 for a
+// space
+func unescapeMountPath(path string) string {
+	if !strings.Contains(path, `\`) {
+		return path
+	}
+	var b strings.Builder
+	for i := 0; i < len(path); i++ {
+		if path[i] == '\\' && i+4 <= len(path) {
+			if c, err := strconv.ParseUint(path[i+1:i+4], 8, 8); err == nil {
+				b.WriteByte(byte(c))
+				i += 3
+				continue
+			}
+		}
+		b.WriteByte(path[i])
+	}
+	return b.String()
+}
+
+// Mount mounts d under /run/media/$USER and returns where. Polkit asks for
+// a password when the user may not mount it unprompted.
+func Mount(d Device) (string, error) {
+	if err := udisksctl("mount", "-b", d.Path); err != nil {
+		return "", err
+	}
+	if point := mountPoints()[d.Path]; point != "" {
+		return point, nil
+	}
+	return "", fmt.Errorf("%s was not mounted", d.Path)
+}
+
+// Unmount unmounts d
+func Unmount(d Device) error {
+	return udisksctl("unmount", "-b", d.Path)
+}
+
+// Eject unmounts every mounted partition of d's drive and powers the drive
+// off, so it can be pulled out safely
+func Eject(d Device) error {
+	for _, other := range Scan() {
+		if other.Drive == d.Drive && other.Mounted() {
+			if err := Unmount(other); err != nil {
+				return err
+			}
+		}
+	}
+	return udisksctl("power-off", "-b", d.Drive)
+}
+
+// udisksctl runs udisksctl, turning its complaints into the error
+func udisksctl(args ...string) error {
+	path, err := exec.LookPath("udisksctl")
+	if err != nil {
+		return ErrNoUDisks
+	}
+	output, err := exec.Command(path, args...).CombinedOutput()
+	if err != nil {
+		if msg := strings.TrimSpace(string(output)); msg != "" {
+			return errors.New(msg)
+		}
+		return err
+	}
+	return nil
+}
@@ -25,17 +25,24 @@ type FileEntry struct {
 	MimeType   string
 	Icon       string
 	IsSymlink  bool
-	LinkTarget string
+	LinkTarget string // As stored in the link, possibly relative
+	LinkBroken bool   // The link points at nothing
+	UID        uint32 // Owner, for the Owner column and permission editing
 }
 
 // ReadDirectory reads all entries from a directory
 func ReadDirectory(path string) ([]FileEntry, error) {
+	if IsRemote(path) {
+		return readRemoteDirectory(path)
+	}
+
 	entries, err := os.ReadDir(path)
 	if err != nil {
 		return nil, err
 	}
 
 	var files []FileEntry
+	hidden := ReadHiddenList(path)
 
 	for _, entry := range entries {
 		info, err := entry.Info()
@@ -46,37 +53,70 @@ func ReadDirectory(path string) ([]FileEntry, error) {
 		name := entry.Name()
 		fullPath := filepath.Join(path, name)
 
-		isHidden := strings.HasPrefix(name, ".")
+		isHidden := strings.HasPrefix(name, ".") || hidden[name]
 
-		var isSymlink bool
+		// Links show what they point at, so folders behind them open as
+		// folders; their Mode stays the link's
+		isDir := entry.IsDir()
+		size := info.Size()
+		var isSymlink, linkBroken bool
 		var linkTarget string
 		if info.Mode()&os.ModeSymlink != 0 {
 			isSymlink = true
 			linkTarget, _ = os.Readlink(fullPath)
+			if target, err := os.Stat(fullPath); err == nil {
+				isDir = target.IsDir()
+				size = target.Size()
+			} else {
+				linkBroken = true
+			}
+		}
+
+		var uid uint32
+		if stat, ok := info.Sys().(*syscall.Stat_t); ok {
+			uid = stat.Uid
 		}
 
 		mimeType := ""
-		if !entry.IsDir() {
+		if !isDir && !linkBroken {
 			mimeType = GetMimeType(fullPath)
 		}
 
 		files = append(files, FileEntry{
 			Name:       name,
 			Path:       fullPath,
-			Size:       info.Size(),
+			Size:       size,
 			ModTime:    info.ModTime(),
 			Mode:       info.Mode(),
-			IsDir:      entry.IsDir(),
+			IsDir:      isDir,
 			IsHidden:   isHidden,
 			MimeType:   mimeType,
 			IsSymlink:  isSymlink,
 			LinkTarget: linkTarget,
+			LinkBroken: linkBroken,
+			UID:        uid,
 		})
 	}
 
 	return files, nil
 }
 
+// ReadHiddenList returns the names listed in a directory's .hidden file,
+// one per line, which file managers hide like dotfiles
+func ReadHiddenList(dir string) map[string]bool {
+	data, err := os.ReadFile(filepath.Join(dir, ".hidden"))
+	if err != nil {
+		return nil
+	}
+	hidden := make(map[string]bool)
+	for _, line := range strings.Split(string(data), "\n") {
+		if name := strings.TrimRight(line, "\r"); name != "" {
+			hidden[name] = true
+		}
+	}
+	return hidden
+}
+
 // SortEntries sorts file entries by the given criteria
 func SortEntries(entries []FileEntry, sortBy string, descending bool) []FileEntry {
 	sorted := make([]FileEntry, len(entries))
@@ -97,12 +137,12 @@ func SortEntries(entries []FileEntry, sortBy string, descending bool) []FileEntr
 		case "date":
 			less = sorted[i].ModTime.Before(sorted[j].ModTime)
 		case "type":
-			extI := filepath.Ext(sorted[i].Name)
-			extJ := filepath.Ext(sorted[j].Name)
-			if extI == extJ {
+			typeI := GetFileTypeDescription(sorted[i])
+			typeJ := GetFileTypeDescription(sorted[j])
+			if typeI == typeJ {
 				less = strings.ToLower(sorted[i].Name) < strings.ToLower(sorted[j].Name)
 			} else {
-				less = extI < extJ
+				less = typeI < typeJ
 			}
 		default:
 			less = strings.ToLower(sorted[i].Name) < strings.ToLower(sorted[j].Name)
@@ -119,12 +159,8 @@ func SortEntries(entries []FileEntry, sortBy string, descending bool) []FileEntr
 
 // GetMimeType returns the MIME type of a file
 func GetMimeType(path string) string {
-	ext := filepath.Ext(path)
-	if ext != "" {
-		mimeType := mime.TypeByExtension(ext)
-		if mimeType != "" {
-			return strings.Split(mimeType, ";")[0]
-		}
+	if mimeType := mimeTypeByExtension(path); mimeType != "" {
+		return mimeType
 	}
 
 	file, err := os.Open(path)
@@ -142,6 +178,16 @@ func GetMimeType(path string) string {
 	return http.DetectContentType(buffer[:n])
 }
 
+// mimeTypeByExtension returns the MIME type registered for path's
+// extension, or "" if there is none
+func mimeTypeByExtension(path string) string {
+	ext := filepath.Ext(path)
+	if ext == "" {
+		return ""
+	}
+	return strings.Split(mime.TypeByExtension(ext), ";")[0]
+}
+
 // GetFileIcon returns the appropriate icon name for a file
 func GetFileIcon(entry FileEntry) string {
 	if entry.IsDir {
@@ -251,8 +297,12 @@ func FormatDate(t time.Time) string {
 	return t.Format("Jan 2, 2006")
 }
 
-// GetParentPath returns the parent directory path
+// GetParentPath returns the parent directory path. A network location's
+// root is its own parent.
 func GetParentPath(path string) string {
+	if IsRemote(path) {
+		return remoteParent(path)
+	}
 	parent := filepath.Dir(path)
 	if parent == path {
 		return "/"
@@ -262,6 +312,9 @@ func GetParentPath(path string) string {
 
 // GetDiskSpace returns free and total disk space for a path
 func GetDiskSpace(path string) (free, total int64) {
+	if IsRemote(path) {
+		return 0, 0
+	}
 	var stat syscall.Statfs_t
 	err := syscall.Statfs(path, &stat)
 	if err != nil {
@@ -332,24 +385,35 @@ func IsBinaryFile(path string) bool {
 
 // FileExists checks if a file exists
 func FileExists(path string) bool {
-	_, err := os.Stat(path)
+	_, err := Stat(path)
 	return err == nil
 }
 
 // IsDirectory checks if a path is a directory
 func IsDirectory(path string) bool {
-	info, err := os.Stat(path)
+	info, err := Stat(path)
 	if err != nil {
 		return false
 	}
 	return info.IsDir()
 }
 
-// GetFileTypeDescription returns a human-readable file type
+// GetFileTypeDescription returns a human-readable file type, as described
+// for its MIME type by shared-mime-info when it is known there
 func GetFileTypeDescription(entry FileEntry) string {
+	if entry.LinkBroken {
+		return "Broken Link"
+	}
 	if entry.IsDir {
 		return "Folder"
 	}
+	// Unrecognized files are octet-stream, described as "Unknown"; the
+	// extension says more
+	if entry.MimeType != "application/octet-stream" {
+		if description := MimeDescription(entry.MimeType); description != "" {
+			return description
+		}
+	}
 
 	ext := filepath.Ext(entry.Name)
 	if ext == "" {
@@ -0,0 +1,125 @@
+package fileview
+
+import (
+	"bufio"
+	"encoding/xml"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Descriptions of MIME types from the shared-mime-info database, the same
+// ones GTK apps show
+var (
+	mimeInfoMu      sync.Mutex
+	mimeAliases     map[string]string
+	mimeDescription = make(map[string]string)
+)
+
+// mimeDirs returns the shared-mime-info folders, most important first
+func mimeDirs() []string {
+	dataHome := os.Getenv("XDG_DATA_HOME")
+	if dataHome == "" {
+		dataHome = filepath.Join(os.Getenv("HOME"), ".local", "share")
+	}
+	dataDirs := os.Getenv("XDG_DATA_DIRS")
+	if dataDirs == "" {
+		dataDirs = "/usr/local/share:/usr/share"
+	}
+
+	var dirs []string
+	for _, dir := range append([]string{dataHome}, strings.Split(dataDirs, ":")...) {
+		if dir != "" {
+			dirs = append(dirs, filepath.Join(dir, "mime"))
+		}
+	}
+	return dirs
+}
+
+// loadMimeAliases reads the alternative names of MIME types, such as
+// application/x-pdf for application/pdf. mimeInfoMu must be held.
+func loadMimeAliases() {
+	mimeAliases = make(map[string]string)
+	dirs := mimeDirs()
+	// Earlier folders override later ones
+	for i := len(dirs) - 1; i >= 0; i-- {
+		file, err := os.Open(filepath.Join(dirs[i], "aliases"))
+		if err != nil {
+			continue
+		}
+		scanner := bufio.NewScanner(file)
+		for scanner.Scan() {
+			if alias, canonical, ok := strings.Cut(scanner.Text(), " "); ok {
+				mimeAliases[alias] = canonical
+			}
+		}
+		file.Close()
+	}
+}
+
+// MimeDescription returns the description of mimeType, such as "PNG image",
+// or "" if shared-mime-info doesn't know it
+func MimeDescription(mimeType string) string {
+	mimeType = strings.TrimSpace(strings.Split(mimeType, ";")[0])
+	if mimeType == "" {
+		return ""
+	}
+
+	mimeInfoMu.Lock()
+	defer mimeInfoMu.Unlock()
+
+	if description, ok := mimeDescription[mimeType]; ok {
+		return description
+	}
+	if mimeAliases == nil {
+		loadMimeAliases()
+	}
+
+	name := mimeType
+	if canonical, ok := mimeAliases[name]; ok {
+		name = canonical
+	}
+	description := ""
+	for _, dir := range mimeDirs() {
+		if description = readMimeComment(filepath.Join(dir, name+".xml")); description != "" {
+			break
+		}
+	}
+	mimeDescription[mimeType] = description
+	return description
+}
+
+// readMimeComment returns the untranslated comment of a MIME type file
+func readMimeComment(path string) string {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+
+	var info struct {
+		Comments []struct {
+			Lang string `xml:"http://www.w3.org/XML/1998/namespace lang,attr"`
+			Text string `xml:",chardata"`
+		} `xml:"comment"`
+	}
+	if xml.Unmarshal(data, &info) != nil {
+		return ""
+	}
+	for _, c := range info.Comments {
+		if c.Lang == "" {
+			return upperFirst(strings.TrimSpace(c.Text))
+		}
+	}
+	return ""
+}
+
+func upperFirst(s string) string {
+	r, size := utf8.DecodeRuneInString(s)
+	if r == utf8.RuneError {
+		return s
+	}
+	return string(unicode.ToUpper(r)) + s[size:]
+}
@@ -0,0 +1,395 @@
+package fileview
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Network locations are addressed by URI, such as sftp://user@host/home/user
+// or smb://server/share/folder. Once connected, the location's root URI is
+// mounted here and paths under it are read and written through its FS.
+
+// FS is a file system the file manager reads and copies files through.
+// Paths given to it are absolute within the file system, with forward
+// slashes.
+type FS interface {
+	ReadDir(path string) ([]fs.FileInfo, error)
+	Stat(path string) (fs.FileInfo, error)
+	Open(path string) (io.ReadCloser, error)
+	Create(path string) (io.WriteCloser, error)
+	Mkdir(path string, perm fs.FileMode) error
+	Remove(path string) error // A file or an empty folder
+	Rename(oldpath, newpath string) error
+}
+
+// LinkFS is an FS with symbolic links, which can describe a link itself
+// rather than what it points at
+type LinkFS interface {
+	FS
+	Lstat(path string) (fs.FileInfo, error)
+}
+
+// lstat describes p without following it if it is a link
+func lstat(fsys FS, p string) (fs.FileInfo, error) {
+	if links, ok := fsys.(LinkFS); ok {
+		return links.Lstat(p)
+	}
+	return fsys.Stat(p)
+}
+
+// ErrNotConnected is returned for a network path whose location is not
+// mounted
+var ErrNotConnected = errors.New("not connected to this server")
+
+var (
+	mountsMu sync.RWMutex
+	mounts   = make(map[string]FS) // By root URI
+)
+
+// Mount makes the paths under root, a URI without a trailing slash, go
+// through fsys
+func Mount(root string, fsys FS) {
+	mountsMu.Lock()
+	defer mountsMu.Unlock()
+	mounts[root] = fsys
+}
+
+// Unmount forgets the file system mounted at root
+func Unmount(root string) {
+	mountsMu.Lock()
+	defer mountsMu.Unlock()
+	delete(mounts, root)
+}
+
+// IsRemote reports whether path is a network URI rather than a local path
+func IsRemote(path string) bool {
+	return strings.Contains(path, "://")
+}
+
+// PathFromURI returns the path of a file:// URI, or the location of a
+// network URI as written in the file manager, reporting false for URIs it
+// can't address
+func PathFromURI(uri string) (string, bool) {
+	u, err := url.Parse(uri)
+	if err != nil || u.Scheme == "" {
+		return "", false
+	}
+	if u.Scheme == "file" {
+		return filepath.Clean(u.Path), u.Path != ""
+	}
+	// Remote paths are shown unescaped, as typed in the location bar
+	address := u.Scheme + "://"
+	if u.User != nil {
+		address += u.User.String() + "@"
+	}
+	return strings.TrimSuffix(address+u.Host+u.Path, "/"), u.Host != ""
+}
+
+// URIFromPath returns the URI of a local path or network location, with
+// special characters escaped
+func URIFromPath(path string) string {
+	if !strings.Contains(path, "://") {
+		return (&url.URL{Scheme: "file", Path: path}).String()
+	}
+	scheme, rest, _ := strings.Cut(path, "://")
+	host, p, _ := strings.Cut(rest, "/")
+	u := &url.URL{Scheme: scheme, Path: "/" + p}
+	if user, h, ok := strings.Cut(host, "@"); ok {
+		u.User = url.User(user)
+		host = h
+	}
+	u.Host = host
+	return u.String()
+}
+
+// Resolve returns the file system path is on, the root it is mounted at
+// and path within it. Local paths resolve to the local file system with
+// an empty root.
+func Resolve(p string) (FS, string, string, error) {
+	if !IsRemote(p) {
+		return localFS{}, "", p, nil
+	}
+
+	mountsMu.RLock()
+	defer mountsMu.RUnlock()
+
+	// The longest root wins, so smb://host/a/b beats smb://host/a
+	roots := make([]string, 0, len(mounts))
+	for root := range mounts {
+		roots = append(roots, root)
+	}
+	sort.Slice(roots, func(i, j int) bool { return len(roots[i]) > len(roots[j]) })
+
+	for _, root := range roots {
+		if p == root || strings.HasPrefix(p, root+"/") {
+			inner := path.Clean("/" + strings.TrimPrefix(p, root))
+			return mounts[root], root, inner, nil
+		}
+	}
+	return nil, "", "", ErrNotConnected
+}
+
+// Join returns name inside dir, for local and network paths alike
+func Join(dir, name string) string {
+	if !IsRemote(dir) {
+		return filepath.Join(dir, name)
+	}
+	return strings.TrimSuffix(dir, "/") + "/" + name
+}
+
+// Clean returns the shortest form of p. Network paths only lose a trailing
+// slash, as cleaning would merge the slashes after the scheme.
+func Clean(p string) string {
+	if !IsRemote(p) {
+		return filepath.Clean(p)
+	}
+	return strings.TrimSuffix(p, "/")
+}
+
+// Contains reports whether p is dir or inside it. Local paths are compared
+// where they really are, so a folder reached through a link is found
+// inside; dir itself is not followed, as a link to a folder is not in it.
+func Contains(dir, p string) bool {
+	dir, p = Clean(dir), Clean(p)
+	if !IsRemote(dir) {
+		if parent, err := filepath.EvalSymlinks(filepath.Dir(dir)); err == nil {
+			dir = filepath.Join(parent, filepath.Base(dir))
+		}
+		if real, err := filepath.EvalSymlinks(p); err == nil {
+			p = real
+		}
+	}
+	return p == dir || strings.HasPrefix(p, strings.TrimSuffix(dir, "/")+"/")
+}
+
+// remoteParent returns the folder above p, stopping at the root of its
+// location
+func remoteParent(p string) string {
+	_, root, inner, err := Resolve(p)
+	if err != nil || inner == "/" {
+		return Clean(p)
+	}
+	if dir := path.Dir(inner); dir != "/" {
+		return root + dir
+	}
+	return root
+}
+
+// Stat returns the FileInfo of a local or network path, following symlinks
+func Stat(p string) (fs.FileInfo, error) {
+	fsys, _, inner, err := Resolve(p)
+	if err != nil {
+		return nil, err
+	}
+	return fsys.Stat(inner)
+}
+
+// Lstat returns the FileInfo of a local or network path, describing a
+// symlink itself
+func Lstat(p string) (fs.FileInfo, error) {
+	fsys, _, inner, err := Resolve(p)
+	if err != nil {
+		return nil, err
+	}
+	return lstat(fsys, inner)
+}
+
+// ReadDir returns the FileInfo of everything in a local or network folder
+func ReadDir(p string) ([]fs.FileInfo, error) {
+	fsys, _, inner, err := Resolve(p)
+	if err != nil {
+		return nil, err
+	}
+	return fsys.ReadDir(inner)
+}
+
+// Open opens a local or network file for reading
+func Open(p string) (io.ReadCloser, error) {
+	fsys, _, inner, err := Resolve(p)
+	if err != nil {
+		return nil, err
+	}
+	return fsys.Open(inner)
+}
+
+// Create creates or truncates a local or network file for writing
+func Create(p string) (io.WriteCloser, error) {
+	fsys, _, inner, err := Resolve(p)
+	if err != nil {
+		return nil, err
+	}
+	return fsys.Create(inner)
+}
+
+// Mkdir creates a local or network folder
+func Mkdir(p string, perm fs.FileMode) error {
+	fsys, _, inner, err := Resolve(p)
+	if err != nil {
+		return err
+	}
+	return fsys.Mkdir(inner, perm)
+}
+
+// Rename renames oldpath to newpath, which must be on the same file system
+func Rename(oldpath, newpath string) error {
+	oldFS, oldRoot, oldInner, err := Resolve(oldpath)
+	if err != nil {
+		return err
+	}
+	_, newRoot, newInner, err := Resolve(newpath)
+	if err != nil {
+		return err
+	}
+	if oldRoot != newRoot {
+		return &os.LinkError{Op: "rename", Old: oldpath, New: newpath, Err: errors.New("not on the same server")}
+	}
+	return oldFS.Rename(oldInner, newInner)
+}
+
+// RemoveAll removes a local or network path and everything in it
+func RemoveAll(p string) error {
+	if !IsRemote(p) {
+		return os.RemoveAll(p)
+	}
+	fsys, _, inner, err := Resolve(p)
+	if err != nil {
+		return err
+	}
+	return removeAll(fsys, inner)
+}
+
+func removeAll(fsys FS, p string) error {
+	// Links are removed, never what they point at
+	info, err := lstat(fsys, p)
+	if err != nil {
+		return err
+	}
+	if info.IsDir() {
+		children, err := fsys.ReadDir(p)
+		if err != nil {
+			return err
+		}
+		for _, child := range children {
+			if err := removeAll(fsys, path.Join(p, child.Name())); err != nil {
+				return err
+			}
+		}
+	}
+	return fsys.Remove(p)
+}
+
+// Walk calls fn for p and, if it is a folder, everything inside it, on a
+// local or network file system. Links are described by what they point at
+// but not walked into, so a link to a folder above can't loop.
+func Walk(p string, fn func(p string, info fs.FileInfo) error) error {
+	fsys, root, inner, err := Resolve(p)
+	if err != nil {
+		return err
+	}
+	return walk(fsys, root, inner, fn)
+}
+
+func walk(fsys FS, root, p string, fn func(p string, info fs.FileInfo) error) error {
+	info, err := lstat(fsys, p)
+	if err != nil {
+		return err
+	}
+	descend := info.IsDir()
+	if info.Mode()&fs.ModeSymlink != 0 {
+		if target, err := fsys.Stat(p); err == nil {
+			info = target
+		}
+	}
+	if err := fn(root+p, info); err != nil || !descend {
+		return err
+	}
+	children, err := fsys.ReadDir(p)
+	if err != nil {
+		return err
+	}
+	for _, child := range children {
+		if err := walk(fsys, root, path.Join(p, child.Name()), fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readRemoteDirectory lists a folder on a network location. MIME types come
+// from the extension only, as sniffing would read every file.
+func readRemoteDirectory(p string) ([]FileEntry, error) {
+	fsys, _, inner, err := Resolve(p)
+	if err != nil {
+		return nil, err
+	}
+	infos, err := fsys.ReadDir(inner)
+	if err != nil {
+		return nil, err
+	}
+
+	files := make([]FileEntry, 0, len(infos))
+	for _, info := range infos {
+		name := info.Name()
+		entry := FileEntry{
+			Name:      name,
+			Path:      Join(p, name),
+			Size:      info.Size(),
+			ModTime:   info.ModTime(),
+			Mode:      info.Mode(),
+			IsDir:     info.IsDir(),
+			IsHidden:  strings.HasPrefix(name, "."),
+			IsSymlink: info.Mode()&fs.ModeSymlink != 0,
+		}
+		// Listings describe links themselves; folders behind them open as
+		// folders
+		if entry.IsSymlink {
+			if target, err := fsys.Stat(path.Join(inner, name)); err == nil {
+				entry.IsDir = target.IsDir()
+				entry.Size = target.Size()
+			} else {
+				entry.LinkBroken = true
+			}
+		}
+		if !entry.IsDir {
+			entry.MimeType = "application/octet-stream"
+			if typ := mimeTypeByExtension(name); typ != "" {
+				entry.MimeType = typ
+			}
+		}
+		files = append(files, entry)
+	}
+	return files, nil
+}
+
+// localFS is the FS of local paths
+type localFS struct{}
+
+func (localFS) ReadDir(p string) ([]fs.FileInfo, error) {
+	entries, err := os.ReadDir(p)
+	if err != nil {
+		return nil, err
+	}
+	infos := make([]fs.FileInfo, 0, len(entries))
+	for _, entry := range entries {
+		if info, err := entry.Info(); err == nil {
+			infos = append(infos, info)
+		}
+	}
+	return infos, nil
+}
+
+func (localFS) Stat(p string) (fs.FileInfo, error)      { return os.Stat(p) }
+func (localFS) Lstat(p string) (fs.FileInfo, error)     { return os.Lstat(p) }
+func (localFS) Open(p string) (io.ReadCloser, error)    { return os.Open(p) }
+func (localFS) Create(p string) (io.WriteCloser, error) { return os.Create(p) }
+func (localFS) Mkdir(p string, perm fs.FileMode) error  { return os.Mkdir(p, perm) }
+func (localFS) Remove(p string) error                   { return os.Remove(p) }
+func (localFS) Rename(oldpath, newpath string) error    { return os.Rename(oldpath, newpath) }
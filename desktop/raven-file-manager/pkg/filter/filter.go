@@ -10,13 +10,35 @@ import (
 
 // State holds the current filter configuration
 type State struct {
-	ShowHidden  bool
-	FileTypes   []string
-	SizeMin     int64
-	SizeMax     int64
-	DateAfter   time.Time
-	DateBefore  time.Time
-	NamePattern string
+	ShowHidden   bool
+	HidePatterns []string // Glob patterns hidden like dotfiles, e.g. "*.o"
+	FileTypes    []string
+	SizeMin      int64
+	SizeMax      int64
+	DateAfter    time.Time
+	DateBefore   time.Time
+	NamePattern  string
+
+	DateRange int // Selection given to SetDateFilter
+	SizeRange int // Selection given to SetSizeFilter
+}
+
+// DateRanges names the ranges SetDateFilter selects, in order
+var DateRanges = []string{"Any Time", "Today", "This Week", "This Month", "This Year", "Before This Year"}
+
+// SizeRange is a range of file sizes, without an upper bound when Max is 0
+type SizeRange struct {
+	Name     string
+	Min, Max int64
+}
+
+// SizeRanges lists the ranges SetSizeFilter selects, in order
+var SizeRanges = []SizeRange{
+	{Name: "Any Size"},
+	{Name: "Under 1 MB", Max: 1<<20 - 1},
+	{Name: "1 MB – 100 MB", Min: 1 << 20, Max: 100<<20 - 1},
+	{Name: "100 MB – 1 GB", Min: 100 << 20, Max: 1<<30 - 1},
+	{Name: "Over 1 GB", Min: 1 << 30},
 }
 
 // File type filter definitions
@@ -66,9 +88,10 @@ func NewState() *State {
 	}
 }
 
-// SetDateFilter sets the date filter based on selection index
+// SetDateFilter sets the date filter to one of DateRanges
 func (fs *State) SetDateFilter(selection int) {
 	now := time.Now()
+	fs.DateRange = selection
 	fs.DateBefore = time.Time{}
 
 	switch selection {
@@ -87,12 +110,22 @@ func (fs *State) SetDateFilter(selection int) {
 		fs.DateAfter = time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
 	case 4:
 		fs.DateAfter = time.Date(now.Year(), 1, 1, 0, 0, 0, 0, now.Location())
+	case 5:
+		fs.DateAfter = time.Time{}
+		fs.DateBefore = time.Date(now.Year(), 1, 1, 0, 0, 0, 0, now.Location())
 	}
 }
 
+// SetSizeFilter sets the size filter to one of SizeRanges
+func (fs *State) SetSizeFilter(selection int) {
+	fs.SizeRange = selection
+	fs.SizeMin = SizeRanges[selection].Min
+	fs.SizeMax = SizeRanges[selection].Max
+}
+
 // Matches checks if a file entry matches the current filters
 func (fs *State) Matches(entry fileview.FileEntry) bool {
-	if entry.IsHidden && !fs.ShowHidden {
+	if !fs.ShowHidden && (entry.IsHidden || fs.matchesHidePatterns(entry.Name)) {
 		return false
 	}
 
@@ -127,6 +160,15 @@ func (fs *State) Matches(entry fileview.FileEntry) bool {
 	return true
 }
 
+func (fs *State) matchesHidePatterns(name string) bool {
+	for _, pattern := range fs.HidePatterns {
+		if ok, _ := filepath.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
 func (fs *State) matchesFileType(entry fileview.FileEntry) bool {
 	ext := strings.ToLower(filepath.Ext(entry.Name))
 
@@ -181,7 +223,8 @@ func (fs *State) IsActive() bool {
 		fs.NamePattern != ""
 }
 
-// Reset clears all filters
+// Reset clears all filters. Whether hidden files are shown, and the
+// patterns that hide them, are kept.
 func (fs *State) Reset() {
 	fs.FileTypes = nil
 	fs.SizeMin = 0
@@ -189,6 +232,8 @@ func (fs *State) Reset() {
 	fs.DateAfter = time.Time{}
 	fs.DateBefore = time.Time{}
 	fs.NamePattern = ""
+	fs.DateRange = 0
+	fs.SizeRange = 0
 }
 
 // ApplyFilters filters entries based on the current state
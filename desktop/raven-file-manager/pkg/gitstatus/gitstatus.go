@@ -0,0 +1,238 @@
+// Package gitstatus reads the state of files in git work trees for the file
+// list's badges, from `git status --porcelain`
+package gitstatus
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// State is what git reports for a file. Folders take the highest state of
+// anything inside them.
+type State int
+
+const (
+	Clean State = iota
+	Untracked
+	Staged
+	Modified
+	Conflicted
+)
+
+// Labels for the badges and their tooltips
+var stateNames = map[State][2]string{
+	Untracked:  {"U", "Untracked"},
+	Staged:     {"S", "Staged"},
+	Modified:   {"M", "Modified"},
+	Conflicted: {"C", "Conflicted"},
+}
+
+// Badge returns the letter shown for s, or "" when clean
+func (s State) Badge() string { return stateNames[s][0] }
+
+// String describes s
+func (s State) String() string {
+	if s == Clean {
+		return "Unmodified"
+	}
+	return stateNames[s][1]
+}
+
+const (
+	cacheTTL      = 3 * time.Second // Status is rerun at most this often per repository
+	statusTimeout = 5 * time.Second // Giving up on very large repositories
+)
+
+// Repo is the status of a work tree
+type Repo struct {
+	Root   string
+	Branch string // Empty for a detached HEAD
+
+	files map[string]State // By path relative to Root
+	dirs  map[string]State // Folders holding changed files, relative to Root
+
+	fetched  time.Time
+	indexMod time.Time
+}
+
+var (
+	cacheMu sync.Mutex
+	cache   = make(map[string]*Repo)
+)
+
+// FindRoot returns the top of the work tree dir is in
+func FindRoot(dir string) (string, bool) {
+	for d := filepath.Clean(dir); ; d = filepath.Dir(d) {
+		if _, err := os.Lstat(filepath.Join(d, ".git")); err == nil {
+			return d, true
+		}
+		if filepath.Dir(d) == d {
+			return "", false
+		}
+	}
+}
+
+// Status returns the status of the work tree dir is in, or nil if it isn't
+// in one or git can't be run. Results are reused for a few seconds unless
+// the index or dir itself changes.
+func Status(dir string) *Repo {
+	root, ok := FindRoot(dir)
+	if !ok {
+		return nil
+	}
+
+	cacheMu.Lock()
+	cached := cache[root]
+	cacheMu.Unlock()
+	if cached != nil && time.Since(cached.fetched) < cacheTTL &&
+		cached.indexMod.Equal(indexModTime(root)) && !modTime(dir).After(cached.fetched) {
+		return cached
+	}
+
+	repo, err := readStatus(root)
+	if err != nil {
+		return nil
+	}
+	// Taken after git status, which may refresh the index itself
+	repo.indexMod = indexModTime(root)
+
+	cacheMu.Lock()
+	cache[root] = repo
+	cacheMu.Unlock()
+	return repo
+}
+
+// indexModTime returns when root's index last changed, following the .git
+// file of linked work trees and submodules
+func indexModTime(root string) time.Time {
+	gitDir := filepath.Join(root, ".git")
+	if data, err := os.ReadFile(gitDir); err == nil {
+		if dir, ok := strings.CutPrefix(strings.TrimSpace(string(data)), "gitdir: "); ok {
+			if !filepath.IsAbs(dir) {
+				dir = filepath.Join(root, dir)
+			}
+			gitDir = dir
+		}
+	}
+	return modTime(filepath.Join(gitDir, "index"))
+}
+
+func modTime(path string) time.Time {
+	info, err := os.Stat(path)
+	if err != nil {
+		return time.Time{}
+	}
+	return info.ModTime()
+}
+
+func readStatus(root string) (*Repo, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), statusTimeout)
+	defer cancel()
+	out, err := exec.CommandContext(ctx, "git", "-C", root, "status", "--porcelain=v1", "-z", "--branch").Output()
+	if err != nil {
+		return nil, err
+	}
+
+	repo := &Repo{
+		Root:    root,
+		files:   make(map[string]State),
+		dirs:    make(map[string]State),
+		fetched: time.Now(),
+	}
+	records := bytes.Split(out, []byte{0})
+	for i := 0; i < len(records); i++ {
+		record := string(records[i])
+		if len(record) < 3 {
+			continue
+		}
+		if strings.HasPrefix(record, "## ") {
+			repo.Branch = parseBranch(record[3:])
+			continue
+		}
+
+		x, y, path := record[0], record[1], record[3:]
+		// Renames and copies are followed by the old path
+		if x == 'R' || x == 'C' {
+			i++
+		}
+		repo.add(path, parseState(x, y))
+	}
+	return repo, nil
+}
+
+// parseBranch reads the branch from the header of the status, such as
+// "main...origin/main [ahead 1]" or "No commits yet on main"
+func parseBranch(header string) string {
+	if name, ok := strings.CutPrefix(header, "No commits yet on "); ok {
+		return name
+	}
+	if strings.HasPrefix(header, "HEAD (no branch)") {
+		return ""
+	}
+	name, _, _ := strings.Cut(header, "...")
+	name, _, _ = strings.Cut(name, " ")
+	return name
+}
+
+// parseState turns the two status letters of a file, for the index and
+// the work tree, into a State
+func parseState(x, y byte) State {
+	switch {
+	case x == '?' && y == '?':
+		return Untracked
+	case x == 'U' || y == 'U' || (x == 'A' && y == 'A') || (x == 'D' && y == 'D'):
+		return Conflicted
+	case y != ' ':
+		return Modified
+	default:
+		return Staged
+	}
+}
+
+// add records path's state and raises the state of the folders above it
+func (r *Repo) add(path string, state State) {
+	if state > r.files[path] {
+		r.files[path] = state
+	}
+	// Untracked folders are listed as "dir/"
+	for dir := filepath.Dir(strings.TrimSuffix(path, "/")); dir != "."; dir = filepath.Dir(dir) {
+		if state > r.dirs[dir] {
+			r.dirs[dir] = state
+		}
+	}
+	if strings.HasSuffix(path, "/") {
+		dir := strings.TrimSuffix(path, "/")
+		if state > r.dirs[dir] {
+			r.dirs[dir] = state
+		}
+	}
+}
+
+// State returns the state of the file or folder at path
+func (r *Repo) State(path string, isDir bool) State {
+	rel, err := filepath.Rel(r.Root, path)
+	if err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+		return Clean
+	}
+	if isDir {
+		if state, ok := r.dirs[rel]; ok {
+			return state
+		}
+	} else if state, ok := r.files[rel]; ok {
+		return state
+	}
+
+	// Everything in an untracked folder is untracked
+	for dir := filepath.Dir(rel); dir != "."; dir = filepath.Dir(dir) {
+		if r.files[dir+"/"] == Untracked {
+			return Untracked
+		}
+	}
+	return Clean
+}
@@ -0,0 +1,118 @@
+package icons
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/diamondburned/gotk4/pkg/gdkpixbuf/v2"
+	"github.com/diamondburned/gotk4/pkg/gtk/v4"
+)
+
+var (
+	defaultMu       sync.Mutex
+	defaultResolver *Resolver
+)
+
+// Default returns the resolver for the current Raven icon theme
+func Default() *Resolver {
+	defaultMu.Lock()
+	defer defaultMu.Unlock()
+	if defaultResolver == nil {
+		defaultResolver = NewResolver(CurrentTheme())
+	}
+	return defaultResolver
+}
+
+// Reload drops cached lookups, for when the icon theme changes
+func Reload() {
+	defaultMu.Lock()
+	defaultResolver = nil
+	defaultMu.Unlock()
+}
+
+// CacheDir returns ~/.cache/raven/icons, where rasterized SVGs are kept
+func CacheDir() string {
+	cacheHome := os.Getenv("XDG_CACHE_HOME")
+	if cacheHome == "" {
+		cacheHome = filepath.Join(os.Getenv("HOME"), ".cache")
+	}
+	return filepath.Join(cacheHome, "raven", "icons")
+}
+
+// Path returns a PNG or other bitmap for an icon at size pixels, or "" if
+// no theme has it. SVG icons are rasterized once and served from the cache.
+func Path(name string, size int) string {
+	path := Default().Lookup(name, size)
+	if !strings.HasSuffix(path, ".svg") {
+		return path
+	}
+	if png, err := Rasterize(path, size); err == nil {
+		return png
+	}
+	return path
+}
+
+// Rasterize renders an SVG at size pixels into the icon cache and returns
+// the PNG. A cached PNG is reused until the SVG changes.
+func Rasterize(svgPath string, size int) (string, error) {
+	src, err := os.Stat(svgPath)
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha1.Sum([]byte(svgPath))
+	base := strings.TrimSuffix(filepath.Base(svgPath), ".svg")
+	png := filepath.Join(CacheDir(), strconv.Itoa(size), base+"-"+hex.EncodeToString(sum[:4])+".png")
+	if cached, err := os.Stat(png); err == nil && !cached.ModTime().Before(src.ModTime()) {
+		return png, nil
+	}
+
+	pixbuf, err := gdkpixbuf.NewPixbufFromFileAtSize(svgPath, size, size)
+	if err != nil {
+		return "", fmt.Errorf("rasterize %s: %w", svgPath, err)
+	}
+	if err := os.MkdirAll(filepath.Dir(png), 0755); err != nil {
+		return "", err
+	}
+
+	// Rename into place so other components never load a partial PNG
+	tmp := png + ".tmp" + strconv.Itoa(os.Getpid())
+	if err := pixbuf.Savev(tmp, "png", nil, nil); err != nil {
+		os.Remove(tmp)
+		return "", err
+	}
+	if err := os.Rename(tmp, png); err != nil {
+		os.Remove(tmp)
+		return "", err
+	}
+	return png, nil
+}
+
+// NewImage returns an image of the named icon at size pixels
+func NewImage(name string, size int) *gtk.Image {
+	image := gtk.NewImage()
+	SetImage(image, name, size)
+	return image
+}
+
+// SetImage shows the named icon in image at size pixels. Symbolic icons
+// and names no theme has are left to GTK, which recolors symbolic icons
+// and shows its missing-image icon for the rest.
+func SetImage(image *gtk.Image, name string, size int) {
+	image.SetPixelSize(size)
+	if strings.HasSuffix(name, "-symbolic") {
+		image.SetFromIconName(name)
+		return
+	}
+	if path := Path(name, size); path != "" {
+		image.SetFromFile(path)
+		return
+	}
+	image.SetFromIconName(name)
+}
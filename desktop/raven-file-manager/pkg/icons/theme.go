@@ -0,0 +1,329 @@
+package icons
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Extensions tried for every icon, in order of preference
+var iconExtensions = []string{".png", ".svg"}
+
+// fallbackThemes end every theme chain, after the theme's own parents
+var fallbackThemes = []string{"hicolor", "Adwaita"}
+
+// themeDir is one subdirectory of an icon theme, from index.theme
+type themeDir struct {
+	path      string // Relative to the theme root
+	size      int
+	scale     int
+	typ       string // Fixed, Scalable or Threshold
+	minSize   int
+	maxSize   int
+	threshold int
+}
+
+// theme is a parsed icon theme
+type theme struct {
+	name    string
+	roots   []string // Every base directory holding this theme
+	dirs    []themeDir
+	parents []string // Inherits=
+}
+
+// Resolver finds icon files by name for a theme. Lookups are cached, so
+// each name and size is resolved once.
+type Resolver struct {
+	mu      sync.Mutex
+	chain   []*theme // Theme, its parents, then the fallbacks
+	lookups map[string]string
+}
+
+// NewResolver returns a resolver for the named theme
+func NewResolver(themeName string) *Resolver {
+	r := &Resolver{lookups: make(map[string]string)}
+
+	seen := make(map[string]bool)
+	queue := []string{themeName}
+	for i := 0; i < len(queue); i++ {
+		name := queue[i]
+		if name == "" || seen[name] {
+			continue
+		}
+		seen[name] = true
+		if t := loadTheme(name); t != nil {
+			r.chain = append(r.chain, t)
+			queue = append(queue, t.parents...)
+		}
+	}
+	for _, name := range fallbackThemes {
+		if !seen[name] {
+			seen[name] = true
+			if t := loadTheme(name); t != nil {
+				r.chain = append(r.chain, t)
+			}
+		}
+	}
+	return r
+}
+
+// BaseDirs are searched for icon themes, as in the icon theme spec
+func BaseDirs() []string {
+	home := os.Getenv("HOME")
+	dirs := []string{filepath.Join(home, ".icons")}
+
+	dataHome := os.Getenv("XDG_DATA_HOME")
+	if dataHome == "" {
+		dataHome = filepath.Join(home, ".local", "share")
+	}
+	dirs = append(dirs, filepath.Join(dataHome, "icons"))
+
+	dataDirs := os.Getenv("XDG_DATA_DIRS")
+	if dataDirs == "" {
+		dataDirs = "/usr/local/share:/usr/share"
+	}
+	for _, dir := range strings.Split(dataDirs, ":") {
+		if dir != "" {
+			dirs = append(dirs, filepath.Join(dir, "icons"))
+		}
+	}
+	return append(dirs, "/usr/share/pixmaps")
+}
+
+// CurrentTheme returns the icon theme chosen in Raven Settings
+func CurrentTheme() string {
+	data, err := os.ReadFile(filepath.Join(os.Getenv("HOME"), ".config", "raven", "settings.json"))
+	if err == nil {
+		var settings struct {
+			IconTheme string `json:"icon_theme"`
+		}
+		if json.Unmarshal(data, &settings) == nil && settings.IconTheme != "" {
+			return settings.IconTheme
+		}
+	}
+	return "hicolor"
+}
+
+// loadTheme reads index.theme of the named theme from the first base
+// directory that has one. Returns nil if the theme isn't installed.
+func loadTheme(name string) *theme {
+	t := &theme{name: name}
+	for _, base := range BaseDirs() {
+		root := filepath.Join(base, name)
+		if info, err := os.Stat(root); err == nil && info.IsDir() {
+			t.roots = append(t.roots, root)
+		}
+	}
+	for _, root := range t.roots {
+		if err := t.parseIndex(filepath.Join(root, "index.theme")); err == nil {
+			return t
+		}
+	}
+	return nil
+}
+
+// parseIndex fills in the directories and parents from an index.theme
+func (t *theme) parseIndex(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	sections := make(map[string]map[string]string)
+	var current map[string]string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			current = make(map[string]string)
+			sections[line[1:len(line)-1]] = current
+			continue
+		}
+		if key, value, ok := strings.Cut(line, "="); ok && current != nil {
+			current[strings.TrimSpace(key)] = strings.TrimSpace(value)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	header, ok := sections["Icon Theme"]
+	if !ok {
+		return fmt.Errorf("%s: no [Icon Theme] section", path)
+	}
+	t.parents = splitList(header["Inherits"])
+
+	for _, dirName := range append(splitList(header["Directories"]), splitList(header["ScaledDirectories"])...) {
+		section, ok := sections[dirName]
+		if !ok {
+			continue
+		}
+		dir := themeDir{
+			path:      dirName,
+			size:      atoi(section["Size"], 0),
+			scale:     atoi(section["Scale"], 1),
+			typ:       section["Type"],
+			threshold: atoi(section["Threshold"], 2),
+		}
+		if dir.typ == "" {
+			dir.typ = "Threshold"
+		}
+		dir.minSize = atoi(section["MinSize"], dir.size)
+		dir.maxSize = atoi(section["MaxSize"], dir.size)
+		t.dirs = append(t.dirs, dir)
+	}
+	return nil
+}
+
+// matchesSize reports whether dir holds icons meant for size
+func (d themeDir) matchesSize(size int) bool {
+	if d.scale != 1 {
+		return false
+	}
+	switch d.typ {
+	case "Fixed":
+		return d.size == size
+	case "Scalable":
+		return d.minSize <= size && size <= d.maxSize
+	default:
+		return d.size-d.threshold <= size && size <= d.size+d.threshold
+	}
+}
+
+// sizeDistance is how far dir's icons are from size, for the closest match
+func (d themeDir) sizeDistance(size int) int {
+	switch d.typ {
+	case "Scalable":
+		if size < d.minSize {
+			return d.minSize - size
+		}
+		if size > d.maxSize {
+			return size - d.maxSize
+		}
+		return 0
+	case "Threshold":
+		if size < d.size-d.threshold {
+			return d.size - d.threshold - size
+		}
+		if size > d.size+d.threshold {
+			return size - d.size - d.threshold
+		}
+		return 0
+	default:
+		return int(math.Abs(float64(d.size - size)))
+	}
+}
+
+// lookup finds name in the theme: an exact size match first, then the
+// closest size available
+func (t *theme) lookup(name string, size int) string {
+	for _, dir := range t.dirs {
+		if !dir.matchesSize(size) {
+			continue
+		}
+		if path := t.find(dir, name); path != "" {
+			return path
+		}
+	}
+
+	best, bestDistance := "", math.MaxInt
+	for _, dir := range t.dirs {
+		if distance := dir.sizeDistance(size); distance < bestDistance {
+			if path := t.find(dir, name); path != "" {
+				best, bestDistance = path, distance
+			}
+		}
+	}
+	return best
+}
+
+// find returns the file for name in dir under any of the theme roots
+func (t *theme) find(dir themeDir, name string) string {
+	for _, root := range t.roots {
+		for _, ext := range iconExtensions {
+			path := filepath.Join(root, dir.path, name+ext)
+			if _, err := os.Stat(path); err == nil {
+				return path
+			}
+		}
+	}
+	return ""
+}
+
+// Lookup returns the file for an icon name at size pixels, or "" if no
+// theme has it. Absolute paths, as some .desktop files use, are returned
+// as they are when they exist.
+func (r *Resolver) Lookup(name string, size int) string {
+	if name == "" {
+		return ""
+	}
+	if filepath.IsAbs(name) {
+		if _, err := os.Stat(name); err == nil {
+			return name
+		}
+		return ""
+	}
+
+	key := name + "@" + strconv.Itoa(size)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if path, ok := r.lookups[key]; ok {
+		return path
+	}
+
+	path := ""
+	for _, t := range r.chain {
+		if path = t.lookup(name, size); path != "" {
+			break
+		}
+	}
+	// Unthemed icons, like /usr/share/pixmaps
+	if path == "" {
+		for _, base := range BaseDirs() {
+			for _, ext := range iconExtensions {
+				if candidate := filepath.Join(base, name+ext); fileExists(candidate) {
+					path = candidate
+					break
+				}
+			}
+			if path != "" {
+				break
+			}
+		}
+	}
+
+	r.lookups[key] = path
+	return path
+}
+
+func splitList(value string) []string {
+	var list []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+func atoi(value string, fallback int) int {
+	if n, err := strconv.Atoi(value); err == nil {
+		return n
+	}
+	return fallback
+}
+
+func fileExists(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && !info.IsDir()
+}
@@ -0,0 +1,622 @@
+package index
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+	"unicode/utf8"
+
+	"raven-file-manager/pkg/fileview"
+	"raven-file-manager/pkg/search"
+
+	"github.com/fsnotify/fsnotify"
+	_ "modernc.org/sqlite"
+)
+
+// The index keeps the name of everything below the root, except inside
+// hidden folders, and the text of the files ContentSearch reads, so
+// searches don't walk the disk. Text goes in an FTS5 table with the
+// trigram tokenizer, which finds any substring of three or more
+// characters. One raven-files process keeps the index up to date,
+// watching every folder with inotify; the others only read it.
+
+const (
+	schemaVersion  = "1"
+	flushDelay     = 2 * time.Second  // Changes are indexed once the folder has been quiet this long
+	retryInterval  = time.Minute      // How often a reader tries to take over updating
+	rescanInterval = 10 * time.Minute // Full rescans when some folders couldn't be watched
+	batchSize      = 500              // Changes per transaction
+	maxIndexedText = 1 << 20          // Longer files are read at search time
+	maxCandidates  = 50000            // Names returned for one filename search
+	binarySniffLen = 8192
+)
+
+// What is known of a file's text, in the files table's content column
+const (
+	contentNone     = 0 // Not read by ContentSearch: hidden, ignored, too big or not a regular file
+	contentText     = 1 // In the bodies table
+	contentBinary   = 2 // Skipped by ContentSearch
+	contentUnlisted = 3 // Read by ContentSearch but too long to index
+)
+
+const schema = `
+CREATE TABLE IF NOT EXISTS meta (
+	key   TEXT PRIMARY KEY,
+	value TEXT NOT NULL
+);
+CREATE TABLE IF NOT EXISTS files (
+	id         INTEGER PRIMARY KEY,
+	path       TEXT NOT NULL UNIQUE,
+	dir        TEXT NOT NULL,
+	name       TEXT NOT NULL,
+	size       INTEGER NOT NULL,
+	mtime      INTEGER NOT NULL,
+	mode       INTEGER NOT NULL,
+	is_dir     INTEGER NOT NULL,
+	searchable INTEGER NOT NULL,
+	content    INTEGER NOT NULL
+);
+CREATE INDEX IF NOT EXISTS files_dir ON files (dir);
+CREATE VIRTUAL TABLE IF NOT EXISTS bodies USING fts5 (
+	body, content='', contentless_delete=1, tokenize='trigram'
+);
+`
+
+// Index is the search index of one root folder. It implements
+// search.Index.
+type Index struct {
+	root        string
+	maxFileSize int64
+	db          *sql.DB
+
+	cancel context.CancelFunc
+	done   chan struct{}
+
+	// Only used by the updating process
+	lock         *os.File
+	watcher      *fsnotify.Watcher
+	watchFailed  bool
+	watchWarning sync.Once
+}
+
+// Dir returns where the index is kept
+func Dir() string {
+	cache, err := os.UserCacheDir()
+	if err != nil {
+		cache = filepath.Join(os.Getenv("HOME"), ".cache")
+	}
+	return filepath.Join(cache, "raven", "files-index")
+}
+
+// Open opens the index of root, creating it if needed, and starts keeping
+// it up to date in the background unless another process already does.
+// Files longer than maxFileSize are left out, as in ContentSearch.
+func Open(root string, maxFileSize int64) (*Index, error) {
+	dir := Dir()
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+
+	dsn := "file:" + filepath.Join(dir, "index.db") +
+		"?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)&_pragma=synchronous(NORMAL)"
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, err
+	}
+	ix := &Index{
+		root:        filepath.Clean(root),
+		maxFileSize: maxFileSize,
+		db:          db,
+		done:        make(chan struct{}),
+	}
+	if err := ix.prepare(); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	ix.cancel = cancel
+	go ix.run(ctx)
+	return ix, nil
+}
+
+// prepare creates the tables, starting over when they were made by
+// another version or for another root
+func (ix *Index) prepare() error {
+	if _, err := ix.db.Exec(schema); err != nil {
+		return err
+	}
+	if ix.meta("version") == schemaVersion && ix.meta("root") == ix.root {
+		return nil
+	}
+	_, err := ix.db.Exec(`
+		DELETE FROM files;
+		DELETE FROM bodies;
+		DELETE FROM meta;
+		INSERT INTO meta (key, value) VALUES ('version', ?), ('root', ?);`,
+		schemaVersion, ix.root)
+	return err
+}
+
+func (ix *Index) meta(key string) string {
+	var value string
+	ix.db.QueryRow(`SELECT value FROM meta WHERE key = ?`, key).Scan(&value)
+	return value
+}
+
+// Close stops updating the index and closes it
+func (ix *Index) Close() error {
+	ix.cancel()
+	<-ix.done
+	return ix.db.Close()
+}
+
+// Covers reports whether the index has been built and holds dir
+func (ix *Index) Covers(dir string) bool {
+	dir = filepath.Clean(dir)
+	if dir != ix.root && !strings.HasPrefix(dir, ix.root+"/") {
+		return false
+	}
+	// Hidden folders aren't indexed
+	for _, part := range strings.Split(strings.TrimPrefix(dir, ix.root), "/") {
+		if strings.HasPrefix(part, ".") {
+			return false
+		}
+	}
+	return ix.meta("scanned") == "1"
+}
+
+// below returns the bounds of the paths under dir, for path >= ? AND
+// path < ?
+func below(dir string) (string, string) {
+	dir = strings.TrimSuffix(dir, "/")
+	return dir + "/", dir + "0" // '0' follows '/'
+}
+
+// Files implements search.Index. Whether the characters are in order is
+// left to SQLite's LIKE, which ignores the case of ASCII letters only;
+// the search matches the names again itself.
+func (ix *Index) Files(ctx context.Context, dir, pattern string) ([]fileview.FileEntry, bool) {
+	if !ix.Covers(dir) {
+		return nil, false
+	}
+
+	var like strings.Builder
+	like.WriteString("%")
+	for _, r := range pattern {
+		if r == '%' || r == '_' || r == '\\' {
+			like.WriteRune('\\')
+		}
+		like.WriteRune(r)
+		like.WriteString("%")
+	}
+
+	from, to := below(dir)
+	rows, err := ix.db.QueryContext(ctx, `
+		SELECT path, name, size, mtime, mode, is_dir FROM files
+		WHERE path >= ? AND path < ? AND substr(path, ?) LIKE ? ESCAPE '\'
+		LIMIT ?`,
+		from, to, utf8.RuneCountInString(from)+1, like.String(), maxCandidates)
+	if err != nil {
+		return nil, false
+	}
+	defer rows.Close()
+
+	var entries []fileview.FileEntry
+	for rows.Next() {
+		var entry fileview.FileEntry
+		var mtime int64
+		var mode uint32
+		if err := rows.Scan(&entry.Path, &entry.Name, &entry.Size, &mtime, &mode, &entry.IsDir); err != nil {
+			return nil, false
+		}
+		entry.ModTime = time.Unix(0, mtime)
+		entry.Mode = fs.FileMode(mode)
+		entry.IsHidden = strings.HasPrefix(entry.Name, ".")
+		entries = append(entries, entry)
+	}
+	return entries, rows.Err() == nil
+}
+
+// ContentCandidates implements search.Index. Text shorter than three
+// characters can't be looked up in the trigram index.
+func (ix *Index) ContentCandidates(ctx context.Context, dir, text string) ([]string, bool) {
+	if utf8.RuneCountInString(text) < 3 || !ix.Covers(dir) {
+		return nil, false
+	}
+
+	phrase := `"` + strings.ReplaceAll(text, `"`, `""`) + `"`
+	from, to := below(dir)
+	rows, err := ix.db.QueryContext(ctx, `
+		SELECT path FROM files
+		WHERE path >= ? AND path < ? AND (content = ? OR
+			(content = ? AND id IN (SELECT rowid FROM bodies WHERE bodies MATCH ?)))
+		ORDER BY path`,
+		from, to, contentUnlisted, contentText, phrase)
+	if err != nil {
+		return nil, false
+	}
+	defer rows.Close()
+
+	var paths []string
+	for rows.Next() {
+		var path string
+		if err := rows.Scan(&path); err != nil {
+			return nil, false
+		}
+		paths = append(paths, path)
+	}
+	return paths, rows.Err() == nil
+}
+
+// run keeps the index up to date while this process holds the lock,
+// trying again to take it every retryInterval
+func (ix *Index) run(ctx context.Context) {
+	defer close(ix.done)
+
+	retry := time.NewTicker(retryInterval)
+	defer retry.Stop()
+	for !ix.takeLock() {
+		select {
+		case <-retry.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+	defer ix.lock.Close()
+
+	var err error
+	if ix.watcher, err = fsnotify.NewWatcher(); err != nil {
+		fmt.Fprintf(os.Stderr, "raven-files: search index: %v\n", err)
+	} else {
+		defer ix.watcher.Close()
+	}
+
+	if err := ix.update(ctx, ix.root, true); err != nil {
+		if ctx.Err() == nil {
+			fmt.Fprintf(os.Stderr, "raven-files: search index: %v\n", err)
+		}
+		return
+	}
+	ix.db.Exec(`INSERT OR REPLACE INTO meta (key, value) VALUES ('scanned', '1')`)
+
+	var events chan fsnotify.Event
+	var watchErrors chan error
+	if ix.watcher != nil {
+		events, watchErrors = ix.watcher.Events, ix.watcher.Errors
+	}
+
+	// Folders with changes, and whether to rescan everything below them
+	dirty := make(map[string]bool)
+	flush := time.NewTimer(flushDelay)
+	flush.Stop()
+	rescan := time.NewTicker(rescanInterval)
+	defer rescan.Stop()
+
+	for {
+		select {
+		case event, ok := <-events:
+			if !ok {
+				events = nil
+				continue
+			}
+			if event.Op == fsnotify.Chmod {
+				continue
+			}
+			// A folder made with files already in it, such as by a move,
+			// gets scanned as a whole by its parent
+			dir := filepath.Dir(event.Name)
+			dirty[dir] = dirty[dir] || filepath.Base(event.Name) == ".gitignore"
+			flush.Reset(flushDelay)
+
+		case err, ok := <-watchErrors:
+			if !ok {
+				watchErrors = nil
+				continue
+			}
+			// The kernel dropped events, so they have to be found by
+			// rescanning
+			fmt.Fprintf(os.Stderr, "raven-files: search index: %v\n", err)
+			dirty[ix.root] = true
+			flush.Reset(flushDelay)
+
+		case <-flush.C:
+			for dir, recursive := range dirty {
+				if err := ix.update(ctx, dir, recursive); err != nil && ctx.Err() == nil {
+					fmt.Fprintf(os.Stderr, "raven-files: search index: %v\n", err)
+				}
+			}
+			clear(dirty)
+
+		case <-rescan.C:
+			if ix.watcher == nil || ix.watchFailed {
+				ix.update(ctx, ix.root, true)
+			}
+
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// takeLock reports whether this process is now the one updating the index
+func (ix *Index) takeLock() bool {
+	file, err := os.OpenFile(filepath.Join(Dir(), "index.lock"), os.O_CREATE|os.O_RDWR, 0600)
+	if err != nil {
+		return false
+	}
+	if err := syscall.Flock(int(file.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+		file.Close()
+		return false
+	}
+	ix.lock = file
+	return true
+}
+
+// watch asks inotify for the changes in dir. Once the kernel's watch
+// limit is reached the index falls back to periodic rescans.
+func (ix *Index) watch(dir string) {
+	if ix.watcher == nil {
+		return
+	}
+	if err := ix.watcher.Add(dir); err != nil {
+		ix.watchFailed = true
+		ix.watchWarning.Do(func() {
+			fmt.Fprintf(os.Stderr, "raven-files: search index: watching %s: %v; "+
+				"rescanning every %v instead (raise fs.inotify.max_user_watches to avoid this)\n",
+				dir, err, rescanInterval)
+		})
+	}
+}
+
+// update brings the index of dir's contents in line with the disk, and
+// of the folders below it when recursive is set. New folders are always
+// scanned whole.
+func (ix *Index) update(ctx context.Context, dir string, recursive bool) error {
+	tx := &batch{db: ix.db}
+	defer tx.rollback()
+
+	ig := search.NewIgnorer(ix.root)
+	if err := ix.updateDir(ctx, tx, ig, dir, recursive); err != nil {
+		return err
+	}
+	return tx.commit()
+}
+
+// stored is what the index holds about a file
+type stored struct {
+	size       int64
+	mtime      int64
+	isDir      bool
+	searchable bool
+	content    int
+}
+
+func (ix *Index) updateDir(ctx context.Context, tx *batch, ig *search.Ignorer, dir string, recursive bool) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	known, err := ix.children(dir)
+	if err != nil {
+		return err
+	}
+
+	// A folder that is gone or unreadable keeps nothing; its parent
+	// removes the folder itself
+	entries, err := os.ReadDir(dir)
+	if err == nil {
+		ix.watch(dir)
+	}
+
+	ignoreChanged := false
+	for _, d := range entries {
+		path := filepath.Join(dir, d.Name())
+		if d.IsDir() && strings.HasPrefix(d.Name(), ".") {
+			continue
+		}
+		info, err := d.Info()
+		if err != nil {
+			continue
+		}
+
+		searchable := ig.Searchable(path, d.IsDir())
+		old, ok := known[d.Name()]
+		delete(known, d.Name())
+
+		now := stored{
+			size:       info.Size(),
+			mtime:      info.ModTime().UnixNano(),
+			isDir:      d.IsDir(),
+			searchable: searchable,
+			content:    contentNone,
+		}
+		read := searchable && info.Mode().IsRegular() && info.Size() <= ix.maxFileSize
+		changed := !ok || old.size != now.size || old.mtime != now.mtime || old.isDir != now.isDir ||
+			old.searchable != now.searchable || (old.content != contentNone) != read
+
+		if changed {
+			var text string
+			if read {
+				now.content, text = readContent(path)
+			}
+			if err := tx.put(path, dir, d.Name(), info.Mode(), now, text); err != nil {
+				return err
+			}
+			if d.Name() == ".gitignore" {
+				ignoreChanged = true
+			}
+		}
+
+		// Folders whose .gitignore status changed have to be gone through
+		// for the files in them
+		if d.IsDir() && (recursive || !ok || old.searchable != searchable) {
+			if err := ix.updateDir(ctx, tx, ig, path, true); err != nil {
+				return err
+			}
+		}
+	}
+
+	for name := range known {
+		if err := tx.removeTree(filepath.Join(dir, name)); err != nil {
+			return err
+		}
+	}
+
+	// A changed .gitignore can change what is read anywhere below it
+	if ignoreChanged && !recursive {
+		for _, d := range entries {
+			if d.IsDir() && !strings.HasPrefix(d.Name(), ".") {
+				if err := ix.updateDir(ctx, tx, ig, filepath.Join(dir, d.Name()), true); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// children returns what the index holds about the contents of dir, by
+// name
+func (ix *Index) children(dir string) (map[string]stored, error) {
+	rows, err := ix.db.Query(`
+		SELECT name, size, mtime, is_dir, searchable, content FROM files WHERE dir = ?`, dir)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	known := make(map[string]stored)
+	for rows.Next() {
+		var name string
+		var s stored
+		if err := rows.Scan(&name, &s.size, &s.mtime, &s.isDir, &s.searchable, &s.content); err != nil {
+			return nil, err
+		}
+		known[name] = s
+	}
+	return known, rows.Err()
+}
+
+// readContent returns what ContentSearch will find in the file at path,
+// as one of the content values, and the text to index. Files with a NUL
+// byte near the start are binary, as in ContentSearch.
+func readContent(path string) (int, string) {
+	file, err := os.Open(path)
+	if err != nil {
+		return contentNone, ""
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(io.LimitReader(file, maxIndexedText+1))
+	if err != nil {
+		return contentNone, ""
+	}
+	if bytes.IndexByte(data[:min(len(data), binarySniffLen)], 0) >= 0 {
+		return contentBinary, ""
+	}
+	if len(data) > maxIndexedText {
+		return contentUnlisted, ""
+	}
+	return contentText, strings.ToValidUTF8(string(data), "\uFFFD")
+}
+
+// batch writes changes to the index in transactions of batchSize
+type batch struct {
+	db      *sql.DB
+	tx      *sql.Tx
+	pending int
+}
+
+func (b *batch) begin() error {
+	if b.tx != nil {
+		return nil
+	}
+	var err error
+	b.tx, err = b.db.Begin()
+	return err
+}
+
+// step commits once batchSize changes have been made, so searches see
+// progress and the write lock isn't held for long
+func (b *batch) step() error {
+	b.pending++
+	if b.pending < batchSize {
+		return nil
+	}
+	return b.commit()
+}
+
+func (b *batch) commit() error {
+	if b.tx == nil {
+		return nil
+	}
+	err := b.tx.Commit()
+	b.tx, b.pending = nil, 0
+	return err
+}
+
+func (b *batch) rollback() {
+	if b.tx != nil {
+		b.tx.Rollback()
+		b.tx = nil
+	}
+}
+
+// put stores a file, and its text when s.content is contentText
+func (b *batch) put(path, dir, name string, mode fs.FileMode, s stored, text string) error {
+	if err := b.begin(); err != nil {
+		return err
+	}
+
+	var id int64
+	err := b.tx.QueryRow(`
+		INSERT INTO files (path, dir, name, size, mtime, mode, is_dir, searchable, content)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (path) DO UPDATE SET
+			size = excluded.size, mtime = excluded.mtime, mode = excluded.mode,
+			is_dir = excluded.is_dir, searchable = excluded.searchable, content = excluded.content
+		RETURNING id`,
+		path, dir, name, s.size, s.mtime, uint32(mode), s.isDir, s.searchable, s.content).Scan(&id)
+	if err != nil {
+		return err
+	}
+
+	if _, err := b.tx.Exec(`DELETE FROM bodies WHERE rowid = ?`, id); err != nil {
+		return err
+	}
+	if s.content == contentText {
+		if _, err := b.tx.Exec(`INSERT INTO bodies (rowid, body) VALUES (?, ?)`, id, text); err != nil {
+			return err
+		}
+	}
+	return b.step()
+}
+
+// removeTree forgets path and everything below it
+func (b *batch) removeTree(path string) error {
+	if err := b.begin(); err != nil {
+		return err
+	}
+
+	from, to := below(path)
+	if _, err := b.tx.Exec(`
+		DELETE FROM bodies WHERE rowid IN (
+			SELECT id FROM files WHERE path = ? OR (path >= ? AND path < ?))`,
+		path, from, to); err != nil {
+		return err
+	}
+	if _, err := b.tx.Exec(`DELETE FROM files WHERE path = ? OR (path >= ? AND path < ?)`, path, from, to); err != nil {
+		return err
+	}
+	return b.step()
+}
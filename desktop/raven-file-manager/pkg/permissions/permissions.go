@@ -0,0 +1,107 @@
+package permissions
+
+import (
+	"io/fs"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"sync"
+)
+
+// Classes are the permission classes in the order ls prints them
+var Classes = []string{"Owner", "Group", "Others"}
+
+// Bits are the permissions each class can have, in ls order
+var Bits = []string{"Read", "Write", "Execute"}
+
+var (
+	ownerMu    sync.Mutex
+	ownerNames = map[uint32]string{}
+)
+
+// Bit returns the mode bit for a class and permission index, e.g.
+// Bit(0, 1) is the owner write bit
+func Bit(class, bit int) fs.FileMode {
+	return 1 << uint(8-(class*3+bit))
+}
+
+// Format prints the permission bits like ls, without the type character
+func Format(mode fs.FileMode) string {
+	return mode.Perm().String()[1:]
+}
+
+// OwnerName returns the user name for uid, falling back to the number
+func OwnerName(uid uint32) string {
+	ownerMu.Lock()
+	defer ownerMu.Unlock()
+
+	if name, ok := ownerNames[uid]; ok {
+		return name
+	}
+	id := strconv.FormatUint(uint64(uid), 10)
+	name := id
+	if u, err := user.LookupId(id); err == nil {
+		name = u.Username
+	}
+	ownerNames[uid] = name
+	return name
+}
+
+// IsOwner reports whether the current user owns files with this uid.
+// Only the owner can chmod a file.
+func IsOwner(uid uint32) bool {
+	return uid == uint32(os.Getuid())
+}
+
+// Apply sets the permission bits of path. With recursive, everything
+// inside a directory is changed too: directories get perm as is, while
+// files only get execute bits if they already had one, like chmod's X,
+// so making a folder tree readable doesn't make every file executable.
+func Apply(path string, perm fs.FileMode, recursive bool) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if !recursive || !info.IsDir() {
+		return os.Chmod(path, perm)
+	}
+
+	// Directories are changed last, deepest first, so removing our own
+	// read or search permission doesn't stop the walk
+	var dirs []string
+	var lastErr error
+	filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			lastErr = err
+			return nil
+		}
+		switch {
+		case d.IsDir():
+			dirs = append(dirs, p)
+		case d.Type()&fs.ModeSymlink != 0:
+			// chmod would follow the link out of the tree
+		default:
+			info, err := d.Info()
+			if err != nil {
+				lastErr = err
+				return nil
+			}
+			mode := perm
+			if info.Mode().Perm()&0111 == 0 {
+				mode &^= 0111
+			}
+			if err := os.Chmod(p, mode); err != nil {
+				lastErr = err
+			}
+		}
+		return nil
+	})
+
+	for i := len(dirs) - 1; i >= 0; i-- {
+		if err := os.Chmod(dirs[i], perm); err != nil {
+			lastErr = err
+		}
+	}
+	return lastErr
+}
@@ -0,0 +1,224 @@
+package preview
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"compress/bzip2"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"raven-file-manager/pkg/fileview"
+
+	"github.com/diamondburned/gotk4/pkg/gtk/v4"
+)
+
+const maxArchiveEntries = 500 // Entries listed in the preview; the rest are only counted
+
+// ArchiveEntry is a file or folder inside an archive
+type ArchiveEntry struct {
+	Name  string
+	Size  int64
+	IsDir bool
+}
+
+// ArchiveListing is what an archive holds
+type ArchiveListing struct {
+	Entries   []ArchiveEntry // The first maxArchiveEntries
+	Count     int
+	Size      int64 // Uncompressed, when SizeKnown
+	SizeKnown bool
+}
+
+func (l *ArchiveListing) add(e ArchiveEntry) {
+	l.Count++
+	l.Size += e.Size
+	if len(l.Entries) < maxArchiveEntries {
+		l.Entries = append(l.Entries, e)
+	}
+}
+
+// archiveKind returns how an archive is read: "zip", "tar", "tar.gz",
+// "tar.bz2", or "other" for formats left to bsdtar. It is empty for files
+// that aren't archives.
+func archiveKind(path string) string {
+	name := strings.ToLower(filepath.Base(path))
+	switch {
+	case strings.HasSuffix(name, ".tar.gz"), strings.HasSuffix(name, ".tgz"):
+		return "tar.gz"
+	case strings.HasSuffix(name, ".tar.bz2"), strings.HasSuffix(name, ".tbz2"):
+		return "tar.bz2"
+	case strings.HasSuffix(name, ".tar.xz"), strings.HasSuffix(name, ".txz"),
+		strings.HasSuffix(name, ".tar.zst"), strings.HasSuffix(name, ".tzst"),
+		strings.HasSuffix(name, ".7z"), strings.HasSuffix(name, ".rar"):
+		return "other"
+	}
+	switch filepath.Ext(name) {
+	case ".zip", ".jar", ".apk", ".epub", ".whl":
+		return "zip"
+	case ".tar":
+		return "tar"
+	}
+	return ""
+}
+
+// ListArchive reads the list of files in an archive. Zip and tar, plain
+// or compressed with gzip or bzip2, are read directly; other formats need
+// bsdtar (libarchive), which doesn't give sizes.
+func ListArchive(ctx context.Context, path string) (ArchiveListing, error) {
+	switch kind := archiveKind(path); kind {
+	case "zip":
+		return listZip(path)
+	case "tar", "tar.gz", "tar.bz2":
+		return listTar(ctx, path, kind)
+	case "other":
+		return listWithBsdtar(ctx, path)
+	}
+	return ArchiveListing{}, errors.New("not an archive")
+}
+
+func listZip(path string) (ArchiveListing, error) {
+	r, err := zip.OpenReader(path)
+	if err != nil {
+		return ArchiveListing{}, err
+	}
+	defer r.Close()
+
+	listing := ArchiveListing{SizeKnown: true}
+	for _, f := range r.File {
+		listing.add(ArchiveEntry{
+			Name:  f.Name,
+			Size:  int64(f.UncompressedSize64),
+			IsDir: f.FileInfo().IsDir(),
+		})
+	}
+	return listing, nil
+}
+
+// listTar has to read through the whole archive, so it stops as soon as
+// ctx is done
+func listTar(ctx context.Context, path, kind string) (ArchiveListing, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return ArchiveListing{}, err
+	}
+	defer file.Close()
+
+	var stream io.Reader = bufio.NewReader(file)
+	switch kind {
+	case "tar.gz":
+		gz, err := gzip.NewReader(stream)
+		if err != nil {
+			return ArchiveListing{}, err
+		}
+		defer gz.Close()
+		stream = gz
+	case "tar.bz2":
+		stream = bzip2.NewReader(stream)
+	}
+
+	listing := ArchiveListing{SizeKnown: true}
+	tr := tar.NewReader(stream)
+	for {
+		if ctx.Err() != nil {
+			return ArchiveListing{}, ctx.Err()
+		}
+		header, err := tr.Next()
+		if err == io.EOF {
+			return listing, nil
+		}
+		if err != nil {
+			return ArchiveListing{}, err
+		}
+		listing.add(ArchiveEntry{
+			Name:  header.Name,
+			Size:  header.Size,
+			IsDir: header.Typeflag == tar.TypeDir,
+		})
+	}
+}
+
+func listWithBsdtar(ctx context.Context, path string) (ArchiveListing, error) {
+	if _, err := exec.LookPath("bsdtar"); err != nil {
+		return ArchiveListing{}, errors.New("listing this archive needs bsdtar (libarchive)")
+	}
+	out, err := exec.CommandContext(ctx, "bsdtar", "-tf", path).Output()
+	if err != nil {
+		return ArchiveListing{}, fmt.Errorf("bsdtar: %w", err)
+	}
+
+	var listing ArchiveListing
+	for _, name := range strings.Split(strings.TrimRight(string(out), "\n"), "\n") {
+		if name != "" {
+			listing.add(ArchiveEntry{Name: name, IsDir: strings.HasSuffix(name, "/")})
+		}
+	}
+	return listing, nil
+}
+
+func (pp *Panel) showArchivePreview(path string, entry fileview.FileEntry) {
+	pp.showAsync(func(ctx context.Context) func() {
+		listing, err := ListArchive(ctx, path)
+		return func() {
+			if err != nil {
+				pp.showError("Cannot read archive: " + err.Error())
+				return
+			}
+			pp.showArchiveListing(listing, entry)
+		}
+	})
+}
+
+func (pp *Panel) showArchiveListing(listing ArchiveListing, entry fileview.FileEntry) {
+	list := gtk.NewListBox()
+	list.SetSelectionMode(gtk.SelectionNone)
+	list.AddCSSClass("preview-archive")
+	for _, e := range listing.Entries {
+		row := gtk.NewBox(gtk.OrientationHorizontal, 8)
+		row.SetMarginStart(8)
+		row.SetMarginEnd(8)
+		row.SetMarginTop(2)
+		row.SetMarginBottom(2)
+
+		icon := "text-x-generic-symbolic"
+		if e.IsDir {
+			icon = "folder-symbolic"
+		}
+		row.Append(gtk.NewImageFromIconName(icon))
+
+		name := gtk.NewLabel(strings.TrimSuffix(e.Name, "/"))
+		name.SetHAlign(gtk.AlignStart)
+		name.SetHExpand(true)
+		name.SetEllipsize(1) // Start, so the file name stays visible
+		row.Append(name)
+
+		if listing.SizeKnown && !e.IsDir {
+			size := gtk.NewLabel(fileview.HumanizeSize(e.Size))
+			size.AddCSSClass("preview-info-label")
+			row.Append(size)
+		}
+		list.Append(row)
+	}
+
+	scroll := gtk.NewScrolledWindow()
+	scroll.SetPolicy(gtk.PolicyNever, gtk.PolicyAutomatic)
+	scroll.SetVExpand(true)
+	scroll.SetChild(list)
+	pp.ContentBox.Append(scroll)
+
+	details := [][2]string{{"Contents:", fileview.Pluralize(listing.Count, "item", "items")}}
+	if len(listing.Entries) < listing.Count {
+		details[0][1] += fmt.Sprintf(" (first %d shown)", len(listing.Entries))
+	}
+	if listing.SizeKnown {
+		details = append(details, [2]string{"Unpacked:", fileview.HumanizeSize(listing.Size)})
+	}
+	pp.showFileInfo(entry, details...)
+}
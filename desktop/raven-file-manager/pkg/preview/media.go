@@ -0,0 +1,419 @@
+package preview
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"raven-file-manager/pkg/fileview"
+	"raven-file-manager/pkg/icons"
+
+	"github.com/diamondburned/gotk4/pkg/gtk/v4"
+)
+
+const thumbnailWidth = 600 // Pixels; the preview pane scales it down
+
+// MediaInfo is what ffprobe reports about an audio or video file
+type MediaInfo struct {
+	Format   string // Container, such as "Matroska / WebM"
+	Duration time.Duration
+	Bitrate  int64             // Bits per second
+	Tags     map[string]string // Lowercased keys: title, artist, album, date, ...
+
+	VideoCodec string
+	Width      int
+	Height     int
+	FrameRate  float64
+
+	AudioCodec string
+	SampleRate int
+	Channels   int
+	CoverArt   bool // An audio file with an embedded picture
+}
+
+// ProbeMedia reads the metadata of an audio or video file with ffprobe
+func ProbeMedia(ctx context.Context, path string) (MediaInfo, error) {
+	if _, err := exec.LookPath("ffprobe"); err != nil {
+		return MediaInfo{}, errors.New("reading media details needs ffprobe (ffmpeg)")
+	}
+	out, err := exec.CommandContext(ctx, "ffprobe", "-v", "quiet", "-print_format", "json",
+		"-show_format", "-show_streams", path).Output()
+	if err != nil {
+		return MediaInfo{}, fmt.Errorf("ffprobe: %w", err)
+	}
+
+	var probe struct {
+		Format struct {
+			LongName string            `json:"format_long_name"`
+			Duration string            `json:"duration"`
+			BitRate  string            `json:"bit_rate"`
+			Tags     map[string]string `json:"tags"`
+		} `json:"format"`
+		Streams []struct {
+			CodecType   string            `json:"codec_type"`
+			CodecName   string            `json:"codec_long_name"`
+			Width       int               `json:"width"`
+			Height      int               `json:"height"`
+			FrameRate   string            `json:"avg_frame_rate"`
+			SampleRate  string            `json:"sample_rate"`
+			Channels    int               `json:"channels"`
+			Disposition map[string]int    `json:"disposition"`
+			Tags        map[string]string `json:"tags"`
+		} `json:"streams"`
+	}
+	if err := json.Unmarshal(out, &probe); err != nil {
+		return MediaInfo{}, err
+	}
+
+	info := MediaInfo{
+		Format: probe.Format.LongName,
+		Tags:   make(map[string]string),
+	}
+	if seconds, err := strconv.ParseFloat(probe.Format.Duration, 64); err == nil {
+		info.Duration = time.Duration(seconds * float64(time.Second))
+	}
+	info.Bitrate, _ = strconv.ParseInt(probe.Format.BitRate, 10, 64)
+
+	for key, value := range probe.Format.Tags {
+		info.Tags[strings.ToLower(key)] = value
+	}
+	for _, s := range probe.Streams {
+		switch s.CodecType {
+		case "video":
+			if s.Disposition["attached_pic"] == 1 {
+				info.CoverArt = true
+				continue
+			}
+			if info.VideoCodec == "" {
+				info.VideoCodec = s.CodecName
+				info.Width, info.Height = s.Width, s.Height
+				info.FrameRate = parseRate(s.FrameRate)
+			}
+		case "audio":
+			if info.AudioCodec == "" {
+				info.AudioCodec = s.CodecName
+				info.SampleRate, _ = strconv.Atoi(s.SampleRate)
+				info.Channels = s.Channels
+				// Ogg and Opus keep their tags on the stream
+				for key, value := range s.Tags {
+					if _, ok := info.Tags[strings.ToLower(key)]; !ok {
+						info.Tags[strings.ToLower(key)] = value
+					}
+				}
+			}
+		}
+	}
+	return info, nil
+}
+
+// parseRate reads a frame rate like "30000/1001"
+func parseRate(rate string) float64 {
+	num, den, ok := strings.Cut(rate, "/")
+	n, err := strconv.ParseFloat(num, 64)
+	if err != nil {
+		return 0
+	}
+	if !ok {
+		return n
+	}
+	d, err := strconv.ParseFloat(den, 64)
+	if err != nil || d == 0 {
+		return 0
+	}
+	return n / d
+}
+
+// thumbnailPath returns where the thumbnail of a version of a file is
+// cached, in ~/.cache/raven/previews
+func thumbnailPath(entry fileview.FileEntry) (string, error) {
+	cache, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	dir := filepath.Join(cache, "raven", "previews")
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", err
+	}
+	key := fmt.Sprintf("%s\x00%d\x00%d", entry.Path, entry.Size, entry.ModTime.UnixNano())
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(dir, hex.EncodeToString(sum[:16])+".png"), nil
+}
+
+// makeThumbnail returns the cached thumbnail of entry, running render to
+// write it to a temporary path first when there is none
+func makeThumbnail(entry fileview.FileEntry, render func(out string) error) (string, error) {
+	path, err := thumbnailPath(entry)
+	if err != nil {
+		return "", err
+	}
+	if _, err := os.Stat(path); err == nil {
+		return path, nil
+	}
+
+	tmp := strings.TrimSuffix(path, ".png") + fmt.Sprintf(".%d.tmp.png", os.Getpid())
+	if err := render(tmp); err != nil {
+		os.Remove(tmp)
+		return "", err
+	}
+	return path, os.Rename(tmp, path)
+}
+
+// VideoThumbnail returns a frame from a tenth of the way into a video, or
+// the cover art of an audio file, rendered by ffmpeg
+func VideoThumbnail(ctx context.Context, entry fileview.FileEntry, info MediaInfo) (string, error) {
+	if _, err := exec.LookPath("ffmpeg"); err != nil {
+		return "", errors.New("thumbnails need ffmpeg")
+	}
+	return makeThumbnail(entry, func(out string) error {
+		args := []string{"-v", "error", "-y"}
+		if info.VideoCodec != "" {
+			at := min(info.Duration/10, 30*time.Second)
+			args = append(args, "-ss", fmt.Sprintf("%.3f", at.Seconds()))
+		}
+		args = append(args, "-i", entry.Path, "-an", "-frames:v", "1",
+			"-vf", fmt.Sprintf("scale=%d:-2", thumbnailWidth), out)
+		if msg, err := exec.CommandContext(ctx, "ffmpeg", args...).CombinedOutput(); err != nil {
+			return fmt.Errorf("ffmpeg: %s", strings.TrimSpace(string(msg)))
+		}
+		return nil
+	})
+}
+
+// PDFInfo is what pdfinfo reports about a PDF
+type PDFInfo struct {
+	Pages  int
+	Title  string
+	Author string
+}
+
+// ProbePDF reads a PDF's page count, title and author with pdfinfo
+func ProbePDF(ctx context.Context, path string) (PDFInfo, error) {
+	out, err := exec.CommandContext(ctx, "pdfinfo", path).Output()
+	if err != nil {
+		return PDFInfo{}, fmt.Errorf("pdfinfo: %w", err)
+	}
+	var info PDFInfo
+	for _, line := range strings.Split(string(out), "\n") {
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		value = strings.TrimSpace(value)
+		switch key {
+		case "Pages":
+			info.Pages, _ = strconv.Atoi(value)
+		case "Title":
+			info.Title = value
+		case "Author":
+			info.Author = value
+		}
+	}
+	return info, nil
+}
+
+// PDFFirstPage renders the first page of a PDF with pdftoppm (poppler)
+func PDFFirstPage(ctx context.Context, entry fileview.FileEntry) (string, error) {
+	if _, err := exec.LookPath("pdftoppm"); err != nil {
+		return "", errors.New("PDF previews need pdftoppm (poppler-utils)")
+	}
+	return makeThumbnail(entry, func(out string) error {
+		// pdftoppm adds the .png itself
+		prefix := strings.TrimSuffix(out, ".png")
+		cmd := exec.CommandContext(ctx, "pdftoppm", "-png", "-f", "1", "-l", "1", "-singlefile",
+			"-scale-to-x", strconv.Itoa(thumbnailWidth), "-scale-to-y", "-1", entry.Path, prefix)
+		if msg, err := cmd.CombinedOutput(); err != nil {
+			return fmt.Errorf("pdftoppm: %s", strings.TrimSpace(string(msg)))
+		}
+		return nil
+	})
+}
+
+func (pp *Panel) showPDFPreview(path string, entry fileview.FileEntry) {
+	pp.showAsync(func(ctx context.Context) func() {
+		page, err := PDFFirstPage(ctx, entry)
+		info, _ := ProbePDF(ctx, path)
+		return func() {
+			var details [][2]string
+			if info.Pages > 0 {
+				details = append(details, [2]string{"Pages:", strconv.Itoa(info.Pages)})
+			}
+			if info.Title != "" {
+				details = append(details, [2]string{"Title:", info.Title})
+			}
+			if info.Author != "" {
+				details = append(details, [2]string{"Author:", info.Author})
+			}
+			pp.showThumbnail(page, err, entry)
+			pp.showFileInfo(entry, details...)
+		}
+	})
+}
+
+func (pp *Panel) showVideoPreview(path string, entry fileview.FileEntry) {
+	pp.showAsync(func(ctx context.Context) func() {
+		info, err := ProbeMedia(ctx, path)
+		var thumbnail string
+		var thumbErr error
+		if err == nil {
+			thumbnail, thumbErr = VideoThumbnail(ctx, entry, info)
+		} else {
+			thumbErr = err
+		}
+		return func() {
+			pp.showThumbnail(thumbnail, thumbErr, entry)
+			pp.showFileInfo(entry, mediaDetails(info)...)
+		}
+	})
+}
+
+func (pp *Panel) showAudioPreview(path string, entry fileview.FileEntry) {
+	pp.showAsync(func(ctx context.Context) func() {
+		info, err := ProbeMedia(ctx, path)
+		var cover string
+		if err == nil && info.CoverArt {
+			cover, _ = VideoThumbnail(ctx, entry, info)
+		}
+		return func() {
+			box := gtk.NewBox(gtk.OrientationVertical, 8)
+			box.SetMarginStart(16)
+			box.SetMarginEnd(16)
+			box.SetMarginTop(16)
+
+			if cover != "" {
+				picture := gtk.NewPictureForFilename(cover)
+				picture.SetContentFit(gtk.ContentFitContain)
+				picture.SetCanShrink(true)
+				picture.AddCSSClass("preview-image")
+				picture.SetVExpand(true)
+				box.Append(picture)
+			} else {
+				icon := icons.NewImage(fileview.GetFileIcon(entry), 64)
+				icon.SetMarginBottom(8)
+				box.Append(icon)
+			}
+
+			title := info.Tags["title"]
+			if title == "" {
+				title = entry.Name
+			}
+			titleLabel := gtk.NewLabel(title)
+			titleLabel.AddCSSClass("preview-title")
+			titleLabel.SetWrap(true)
+			box.Append(titleLabel)
+			if artist := info.Tags["artist"]; artist != "" {
+				artistLabel := gtk.NewLabel(artist)
+				artistLabel.AddCSSClass("preview-info-value")
+				artistLabel.SetWrap(true)
+				box.Append(artistLabel)
+			}
+
+			// GTK plays it with its GStreamer backend; playback stops when
+			// the preview changes
+			pp.media = gtk.NewMediaFileForFilename(path)
+			controls := gtk.NewMediaControls(pp.media)
+			controls.AddCSSClass("preview-media-controls")
+			box.Append(controls)
+
+			pp.ContentBox.Append(box)
+
+			details := mediaDetails(info)
+			if err != nil {
+				details = append(details, [2]string{"Details:", err.Error()})
+			}
+			pp.showFileInfo(entry, details...)
+		}
+	})
+}
+
+// showThumbnail shows the picture rendered at path, or why there is none
+func (pp *Panel) showThumbnail(path string, err error, entry fileview.FileEntry) {
+	box := gtk.NewBox(gtk.OrientationVertical, 8)
+	box.SetMarginStart(16)
+	box.SetMarginEnd(16)
+	box.SetMarginTop(16)
+	box.SetVExpand(true)
+
+	if err != nil {
+		icon := icons.NewImage(fileview.GetFileIcon(entry), 64)
+		icon.SetMarginBottom(8)
+		box.Append(icon)
+
+		label := gtk.NewLabel(err.Error())
+		label.AddCSSClass("status-text")
+		label.SetWrap(true)
+		box.Append(label)
+	} else {
+		picture := gtk.NewPictureForFilename(path)
+		picture.SetContentFit(gtk.ContentFitContain)
+		picture.SetCanShrink(true)
+		picture.AddCSSClass("preview-image")
+		picture.SetVExpand(true)
+		box.Append(picture)
+	}
+	pp.ContentBox.Append(box)
+}
+
+// mediaDetails lists the metadata of an audio or video file for the info
+// grid
+func mediaDetails(info MediaInfo) [][2]string {
+	var details [][2]string
+	add := func(label, value string) {
+		if value != "" {
+			details = append(details, [2]string{label, value})
+		}
+	}
+
+	add("Album:", info.Tags["album"])
+	add("Year:", info.Tags["date"])
+	add("Genre:", info.Tags["genre"])
+	if info.Duration > 0 {
+		add("Duration:", formatDuration(info.Duration))
+	}
+	if info.Width > 0 {
+		resolution := fmt.Sprintf("%d x %d", info.Width, info.Height)
+		if info.FrameRate > 0 {
+			resolution += fmt.Sprintf(", %.3g fps", info.FrameRate)
+		}
+		add("Video:", resolution)
+		add("Video codec:", info.VideoCodec)
+	}
+	if info.AudioCodec != "" {
+		audio := info.AudioCodec
+		if info.SampleRate > 0 {
+			audio += fmt.Sprintf(", %.1f kHz", float64(info.SampleRate)/1000)
+		}
+		switch info.Channels {
+		case 1:
+			audio += ", mono"
+		case 2:
+			audio += ", stereo"
+		case 0:
+		default:
+			audio += fmt.Sprintf(", %d channels", info.Channels)
+		}
+		add("Audio:", audio)
+	}
+	if info.Bitrate > 0 {
+		add("Bitrate:", fmt.Sprintf("%d kb/s", info.Bitrate/1000))
+	}
+	add("Format:", info.Format)
+	return details
+}
+
+// formatDuration formats d as 3:05 or 1:02:03
+func formatDuration(d time.Duration) string {
+	s := int(d.Round(time.Second).Seconds())
+	if s >= 3600 {
+		return fmt.Sprintf("%d:%02d:%02d", s/3600, s/60%60, s%60)
+	}
+	return fmt.Sprintf("%d:%02d", s/60, s%60)
+}
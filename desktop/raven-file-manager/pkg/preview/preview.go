@@ -1,6 +1,7 @@
 package preview
 
 import (
+	"context"
 	"fmt"
 	"image"
 	_ "image/gif"
@@ -11,7 +12,9 @@ import (
 	"strings"
 
 	"raven-file-manager/pkg/fileview"
+	"raven-file-manager/pkg/icons"
 
+	"github.com/diamondburned/gotk4/pkg/glib/v2"
 	"github.com/diamondburned/gotk4/pkg/gtk/v4"
 )
 
@@ -25,6 +28,10 @@ const (
 	TypeImage
 	TypeDirectory
 	TypeBinary
+	TypePDF
+	TypeArchive
+	TypeAudio
+	TypeVideo
 )
 
 // Panel manages the file preview
@@ -32,6 +39,9 @@ type Panel struct {
 	ContentBox  *gtk.Box
 	currentPath string
 	Highlighter *SyntaxHighlighter
+
+	cancel context.CancelFunc // Stops the preview still loading
+	media  *gtk.MediaFile     // Audio playing in the preview
 }
 
 // NewPanel creates a new preview panel
@@ -57,6 +67,29 @@ func DetermineType(path string, entry fileview.FileEntry) Type {
 		return TypeImage
 	}
 
+	if ext == ".pdf" {
+		return TypePDF
+	}
+	if archiveKind(path) != "" {
+		return TypeArchive
+	}
+
+	audioExts := map[string]bool{
+		".mp3": true, ".flac": true, ".ogg": true, ".oga": true, ".opus": true,
+		".m4a": true, ".aac": true, ".wav": true, ".wma": true,
+	}
+	if audioExts[ext] {
+		return TypeAudio
+	}
+
+	videoExts := map[string]bool{
+		".mp4": true, ".mkv": true, ".webm": true, ".avi": true, ".mov": true,
+		".m4v": true, ".flv": true, ".wmv": true, ".mpg": true, ".mpeg": true, ".ogv": true,
+	}
+	if videoExts[ext] {
+		return TypeVideo
+	}
+
 	if fileview.IsCodeFile(path) {
 		return TypeCode
 	}
@@ -72,15 +105,24 @@ func DetermineType(path string, entry fileview.FileEntry) Type {
 	return TypeText
 }
 
-// ShowPreview displays a preview for the given file
+// ShowPreview displays a preview for the given file. PDFs, archives,
+// audio and video load in the background, and are dropped if another file
+// is previewed first.
 func (pp *Panel) ShowPreview(path string, entry fileview.FileEntry) {
 	pp.currentPath = path
+	pp.cancelLoading()
 	pp.Clear()
 
 	if pp.ContentBox == nil {
 		return
 	}
 
+	// Files on servers would have to be downloaded to preview
+	if fileview.IsRemote(path) {
+		pp.showNoPreview(entry)
+		return
+	}
+
 	previewType := DetermineType(path, entry)
 
 	switch previewType {
@@ -94,13 +136,27 @@ func (pp *Panel) ShowPreview(path string, entry fileview.FileEntry) {
 		pp.showDirectoryPreview(path, entry)
 	case TypeBinary:
 		pp.showBinaryPreview(path, entry)
+	case TypePDF:
+		pp.showPDFPreview(path, entry)
+	case TypeArchive:
+		pp.showArchivePreview(path, entry)
+	case TypeAudio:
+		pp.showAudioPreview(path, entry)
+	case TypeVideo:
+		pp.showVideoPreview(path, entry)
 	default:
 		pp.showNoPreview(entry)
 	}
 }
 
-// Clear clears the current preview
+// Clear clears the current preview, stopping any audio it plays
 func (pp *Panel) Clear() {
+	if pp.media != nil {
+		pp.media.SetPlaying(false)
+		pp.media.Clear()
+		pp.media = nil
+	}
+
 	if pp.ContentBox == nil {
 		return
 	}
@@ -114,6 +170,40 @@ func (pp *Panel) Clear() {
 	}
 }
 
+func (pp *Panel) cancelLoading() {
+	if pp.cancel != nil {
+		pp.cancel()
+		pp.cancel = nil
+	}
+}
+
+// showAsync shows a spinner while load runs off the main loop, then the
+// widgets built by the function it returns. load should give up once its
+// context is done, which happens when something else is previewed.
+func (pp *Panel) showAsync(load func(ctx context.Context) func()) {
+	ctx, cancel := context.WithCancel(context.Background())
+	pp.cancel = cancel
+
+	spinner := gtk.NewSpinner()
+	spinner.SetSizeRequest(32, 32)
+	spinner.SetMarginTop(32)
+	spinner.Start()
+	pp.ContentBox.Append(spinner)
+
+	go func() {
+		show := load(ctx)
+		glib.IdleAdd(func() {
+			if ctx.Err() != nil {
+				return
+			}
+			pp.cancel = nil
+			cancel()
+			pp.Clear()
+			show()
+		})
+	}()
+}
+
 func (pp *Panel) showImagePreview(path string, entry fileview.FileEntry) {
 	file, err := os.Open(path)
 	if err != nil {
@@ -318,8 +408,7 @@ func (pp *Panel) showBinaryPreview(path string, entry fileview.FileEntry) {
 	infoBox.SetMarginEnd(16)
 	infoBox.SetMarginTop(16)
 
-	icon := gtk.NewImageFromIconName(fileview.GetFileIcon(entry))
-	icon.SetPixelSize(64)
+	icon := icons.NewImage(fileview.GetFileIcon(entry), 64)
 	icon.SetMarginBottom(16)
 	infoBox.Append(icon)
 
@@ -339,8 +428,7 @@ func (pp *Panel) showNoPreview(entry fileview.FileEntry) {
 	infoBox.SetMarginEnd(16)
 	infoBox.SetMarginTop(16)
 
-	icon := gtk.NewImageFromIconName(fileview.GetFileIcon(entry))
-	icon.SetPixelSize(64)
+	icon := icons.NewImage(fileview.GetFileIcon(entry), 64)
 	icon.SetMarginBottom(16)
 	infoBox.Append(icon)
 
@@ -375,7 +463,55 @@ func (pp *Panel) showError(message string) {
 	pp.ContentBox.Append(errorBox)
 }
 
-func (pp *Panel) showFileInfo(entry fileview.FileEntry) {
+// ShowDetails replaces the preview with a summary of a finished operation
+func (pp *Panel) ShowDetails(title, iconName string, details [][2]string) {
+	pp.currentPath = ""
+	pp.cancelLoading()
+	pp.Clear()
+
+	if pp.ContentBox == nil {
+		return
+	}
+
+	infoBox := gtk.NewBox(gtk.OrientationVertical, 8)
+	infoBox.AddCSSClass("preview-info")
+	infoBox.SetMarginStart(16)
+	infoBox.SetMarginEnd(16)
+	infoBox.SetMarginTop(16)
+
+	icon := gtk.NewImageFromIconName(iconName)
+	icon.SetPixelSize(48)
+	infoBox.Append(icon)
+
+	titleLabel := gtk.NewLabel(title)
+	titleLabel.AddCSSClass("preview-title")
+	titleLabel.SetMarginBottom(8)
+	infoBox.Append(titleLabel)
+
+	grid := gtk.NewGrid()
+	grid.SetColumnSpacing(12)
+	grid.SetRowSpacing(4)
+
+	for row, detail := range details {
+		label := gtk.NewLabel(detail[0])
+		label.AddCSSClass("preview-info-label")
+		label.SetHAlign(gtk.AlignEnd)
+		grid.Attach(label, 0, row, 1, 1)
+
+		value := gtk.NewLabel(detail[1])
+		value.AddCSSClass("preview-info-value")
+		value.SetHAlign(gtk.AlignStart)
+		value.SetWrap(true)
+		grid.Attach(value, 1, row, 1, 1)
+	}
+
+	infoBox.Append(grid)
+	pp.ContentBox.Append(infoBox)
+}
+
+// showFileInfo shows the size, type and modification time of entry,
+// followed by extra label and value pairs
+func (pp *Panel) showFileInfo(entry fileview.FileEntry, extra ...[2]string) {
 	if pp.ContentBox == nil {
 		return
 	}
@@ -386,12 +522,12 @@ func (pp *Panel) showFileInfo(entry fileview.FileEntry) {
 	infoBox.SetMarginEnd(16)
 	infoBox.SetMarginTop(8)
 
-	pp.showFileInfoIn(entry, infoBox)
+	pp.showFileInfoIn(entry, infoBox, extra...)
 
 	pp.ContentBox.Append(infoBox)
 }
 
-func (pp *Panel) showFileInfoIn(entry fileview.FileEntry, box *gtk.Box) {
+func (pp *Panel) showFileInfoIn(entry fileview.FileEntry, box *gtk.Box, extra ...[2]string) {
 	grid := gtk.NewGrid()
 	grid.SetColumnSpacing(12)
 	grid.SetRowSpacing(4)
@@ -429,6 +565,21 @@ func (pp *Panel) showFileInfoIn(entry fileview.FileEntry, box *gtk.Box) {
 	modValue.AddCSSClass("preview-info-value")
 	modValue.SetHAlign(gtk.AlignStart)
 	grid.Attach(modValue, 1, row, 1, 1)
+	row++
+
+	for _, detail := range extra {
+		label := gtk.NewLabel(detail[0])
+		label.AddCSSClass("preview-info-label")
+		label.SetHAlign(gtk.AlignEnd)
+		grid.Attach(label, 0, row, 1, 1)
+
+		value := gtk.NewLabel(detail[1])
+		value.AddCSSClass("preview-info-value")
+		value.SetHAlign(gtk.AlignStart)
+		value.SetWrap(true)
+		grid.Attach(value, 1, row, 1, 1)
+		row++
+	}
 
 	box.Append(grid)
 }
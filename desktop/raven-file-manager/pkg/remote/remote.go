@@ -0,0 +1,422 @@
+// Package remote connects to SFTP and SMB servers and mounts them in
+// fileview as network locations, so the file manager browses and copies
+// their files like local ones.
+package remote
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hirochachacha/go-smb2"
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/crypto/ssh/knownhosts"
+
+	"raven-file-manager/pkg/fileview"
+)
+
+// How long connecting to a server may take
+const dialTimeout = 15 * time.Second
+
+// Location is a server parsed from an sftp:// or smb:// URI
+type Location struct {
+	Scheme string // "sftp" or "smb"
+	User   string
+	Domain string // SMB domain, written as smb://DOMAIN;user@host
+	Host   string
+	Port   int    // 0 for the scheme's default
+	Share  string // SMB share
+	Path   string // Folder to open, within the server or share
+}
+
+// Parse reads a location from uri, e.g. sftp://bob@example.com:2222/srv
+// or smb://nas/media/Music
+func Parse(uri string) (Location, error) {
+	u, err := url.Parse(strings.TrimSpace(uri))
+	if err != nil {
+		return Location{}, err
+	}
+	if u.Scheme != "sftp" && u.Scheme != "smb" {
+		return Location{}, fmt.Errorf("unsupported address %q: use sftp:// or smb://", uri)
+	}
+	if u.Hostname() == "" {
+		return Location{}, fmt.Errorf("no server in %q", uri)
+	}
+
+	loc := Location{Scheme: u.Scheme, Host: u.Hostname(), Path: path.Clean("/" + u.Path)}
+	if u.User != nil {
+		loc.User = u.User.Username()
+		if domain, user, ok := strings.Cut(loc.User, ";"); ok {
+			loc.Domain, loc.User = domain, user
+		}
+	}
+	if port := u.Port(); port != "" {
+		if loc.Port, err = strconv.Atoi(port); err != nil {
+			return Location{}, fmt.Errorf("bad port in %q", uri)
+		}
+	}
+	if loc.Scheme == "smb" {
+		share, rest, _ := strings.Cut(strings.TrimPrefix(loc.Path, "/"), "/")
+		loc.Share = share
+		loc.Path = path.Clean("/" + rest)
+	}
+	return loc, nil
+}
+
+// Root returns the URI the location is mounted at in fileview: the server,
+// or the share on an SMB server
+func (l Location) Root() string {
+	u := url.URL{Scheme: l.Scheme, Host: l.Host}
+	if l.Port != 0 {
+		u.Host = net.JoinHostPort(l.Host, strconv.Itoa(l.Port))
+	}
+	if l.User != "" {
+		user := l.User
+		if l.Domain != "" {
+			user = l.Domain + ";" + user
+		}
+		u.User = url.User(user)
+	}
+	root := u.String()
+	if l.Share != "" {
+		root += "/" + l.Share
+	}
+	return root
+}
+
+// Title names the location in the sidebar
+func (l Location) Title() string {
+	if l.Scheme == "smb" {
+		return l.Share + " on " + l.Host
+	}
+	if l.User != "" {
+		return l.User + "@" + l.Host
+	}
+	return l.Host
+}
+
+// Conn is a connected location, mounted in fileview until Disconnect
+type Conn struct {
+	Location
+	Start string // URI of the folder to open first
+
+	close func() error
+}
+
+// Disconnect unmounts the location and closes its connection
+func (c *Conn) Disconnect() error {
+	fileview.Unmount(c.Root())
+	return c.close()
+}
+
+// Connect logs in to the server at loc and mounts it. SFTP tries the SSH
+// agent and the unencrypted keys in ~/.ssh before password; SMB logs in
+// as guest without a user. An SFTP server whose host key isn't in
+// ~/.ssh/known_hosts fails with an *UnknownHostError.
+func Connect(loc Location, password string) (*Conn, error) {
+	switch loc.Scheme {
+	case "sftp":
+		return connectSFTP(loc, password)
+	case "smb":
+		return connectSMB(loc, password)
+	}
+	return nil, fmt.Errorf("unsupported scheme %q", loc.Scheme)
+}
+
+func connectSFTP(loc Location, password string) (*Conn, error) {
+	user := loc.User
+	if user == "" {
+		user = os.Getenv("USER")
+	}
+	port := loc.Port
+	if port == 0 {
+		port = 22
+	}
+
+	auth, agentConn := sshAuth(password)
+	if agentConn != nil {
+		defer agentConn.Close()
+	}
+	config := &ssh.ClientConfig{
+		User:            user,
+		Auth:            auth,
+		HostKeyCallback: checkHostKey,
+		Timeout:         dialTimeout,
+	}
+	client, err := ssh.Dial("tcp", net.JoinHostPort(loc.Host, strconv.Itoa(port)), config)
+	if err != nil {
+		var unknown *UnknownHostError
+		if errors.As(err, &unknown) {
+			return nil, unknown
+		}
+		return nil, err
+	}
+	files, err := sftp.NewClient(client)
+	if err != nil {
+		client.Close()
+		return nil, fmt.Errorf("no SFTP on %s: %w", loc.Host, err)
+	}
+
+	// Without a folder in the address, start in the user's home
+	start := loc.Path
+	if start == "/" {
+		if home, err := files.Getwd(); err == nil {
+			start = home
+		}
+	}
+
+	fileview.Mount(loc.Root(), sftpFS{files})
+	return &Conn{
+		Location: loc,
+		Start:    loc.Root() + strings.TrimSuffix(start, "/"),
+		close: func() error {
+			files.Close()
+			return client.Close()
+		},
+	}, nil
+}
+
+// sshAuth returns the ways to log in: the SSH agent, unencrypted keys in
+// ~/.ssh and password. The agent's connection must be closed after the
+// login.
+func sshAuth(password string) ([]ssh.AuthMethod, net.Conn) {
+	var methods []ssh.AuthMethod
+
+	var agentConn net.Conn
+	if sock := os.Getenv("SSH_AUTH_SOCK"); sock != "" {
+		if conn, err := net.Dial("unix", sock); err == nil {
+			agentConn = conn
+			methods = append(methods, ssh.PublicKeysCallback(agent.NewClient(conn).Signers))
+		}
+	}
+
+	var signers []ssh.Signer
+	for _, name := range []string{"id_ed25519", "id_ecdsa", "id_rsa"} {
+		key, err := os.ReadFile(filepath.Join(os.Getenv("HOME"), ".ssh", name))
+		if err != nil {
+			continue
+		}
+		// Keys with a passphrase are left to the agent
+		if signer, err := ssh.ParsePrivateKey(key); err == nil {
+			signers = append(signers, signer)
+		}
+	}
+	if len(signers) > 0 {
+		methods = append(methods, ssh.PublicKeys(signers...))
+	}
+
+	if password != "" {
+		methods = append(methods, ssh.Password(password),
+			ssh.KeyboardInteractive(func(user, instruction string, questions []string, echos []bool) ([]string, error) {
+				answers := make([]string, len(questions))
+				for i := range answers {
+					answers[i] = password
+				}
+				return answers, nil
+			}))
+	}
+	return methods, agentConn
+}
+
+// UnknownHostError is returned when connecting to an SFTP server whose
+// host key has not been seen before. TrustHost accepts the key.
+type UnknownHostError struct {
+	Host string
+	Key  ssh.PublicKey
+}
+
+func (e *UnknownHostError) Error() string {
+	return fmt.Sprintf("the authenticity of host %s can't be established", e.Host)
+}
+
+// Fingerprint returns the key's SHA256 fingerprint, as ssh shows it
+func (e *UnknownHostError) Fingerprint() string {
+	return e.Key.Type() + " " + ssh.FingerprintSHA256(e.Key)
+}
+
+// TrustHost adds the host key of e to ~/.ssh/known_hosts, so the next
+// connection accepts it
+func TrustHost(e *UnknownHostError) error {
+	file := knownHostsPath()
+	if err := os.MkdirAll(filepath.Dir(file), 0700); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(file, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = fmt.Fprintln(f, knownhosts.Line([]string{knownhosts.Normalize(e.Host)}, e.Key))
+	return err
+}
+
+func knownHostsPath() string {
+	return filepath.Join(os.Getenv("HOME"), ".ssh", "known_hosts")
+}
+
+// checkHostKey accepts host keys listed in ~/.ssh/known_hosts. A key that
+// differs from the listed one is refused; a host not listed at all is
+// reported as an *UnknownHostError.
+func checkHostKey(host string, remote net.Addr, key ssh.PublicKey) error {
+	if check, err := knownhosts.New(knownHostsPath()); err == nil {
+		err := check(host, remote, key)
+		if err == nil {
+			return nil
+		}
+		var keyErr *knownhosts.KeyError
+		if !errors.As(err, &keyErr) {
+			return err
+		}
+		if len(keyErr.Want) > 0 {
+			return fmt.Errorf("the host key of %s has changed; if that is expected, remove its old key from %s", host, knownHostsPath())
+		}
+	}
+	return &UnknownHostError{Host: host, Key: key}
+}
+
+func connectSMB(loc Location, password string) (*Conn, error) {
+	port := loc.Port
+	if port == 0 {
+		port = 445
+	}
+	tcp, err := net.DialTimeout("tcp", net.JoinHostPort(loc.Host, strconv.Itoa(port)), dialTimeout)
+	if err != nil {
+		return nil, err
+	}
+
+	user := loc.User
+	if user == "" {
+		user = "guest"
+	}
+	dialer := &smb2.Dialer{
+		Initiator: &smb2.NTLMInitiator{User: user, Password: password, Domain: loc.Domain},
+	}
+	session, err := dialer.Dial(tcp)
+	if err != nil {
+		tcp.Close()
+		return nil, err
+	}
+
+	if loc.Share == "" {
+		names, err := session.ListSharenames()
+		session.Logoff()
+		tcp.Close()
+		if err != nil {
+			return nil, fmt.Errorf("no share in the address: use smb://%s/<share>", loc.Host)
+		}
+		return nil, fmt.Errorf("no share in the address; %s shares %s", loc.Host, strings.Join(visibleShares(names), ", "))
+	}
+
+	share, err := session.Mount(loc.Share)
+	if err != nil {
+		session.Logoff()
+		tcp.Close()
+		return nil, err
+	}
+
+	fileview.Mount(loc.Root(), smbFS{share})
+	start := loc.Root()
+	if loc.Path != "/" {
+		start += loc.Path
+	}
+	return &Conn{
+		Location: loc,
+		Start:    start,
+		close: func() error {
+			share.Umount()
+			session.Logoff()
+			return tcp.Close()
+		},
+	}, nil
+}
+
+// visibleShares leaves out administrative shares such as IPC$ and C$
+func visibleShares(names []string) []string {
+	var visible []string
+	for _, name := range names {
+		if !strings.HasSuffix(name, "$") {
+			visible = append(visible, name)
+		}
+	}
+	return visible
+}
+
+// sftpFS reads and writes files on an SFTP server
+type sftpFS struct {
+	client *sftp.Client
+}
+
+func (s sftpFS) ReadDir(p string) ([]fs.FileInfo, error) { return s.client.ReadDir(p) }
+func (s sftpFS) Stat(p string) (fs.FileInfo, error)      { return s.client.Stat(p) }
+func (s sftpFS) Lstat(p string) (fs.FileInfo, error)     { return s.client.Lstat(p) }
+func (s sftpFS) Remove(p string) error                   { return s.client.Remove(p) }
+func (s sftpFS) Rename(oldpath, newpath string) error    { return s.client.Rename(oldpath, newpath) }
+
+func (s sftpFS) Open(p string) (io.ReadCloser, error) {
+	f, err := s.client.Open(p)
+	if err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func (s sftpFS) Create(p string) (io.WriteCloser, error) {
+	f, err := s.client.Create(p)
+	if err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func (s sftpFS) Mkdir(p string, perm fs.FileMode) error {
+	if err := s.client.Mkdir(p); err != nil {
+		return err
+	}
+	return s.client.Chmod(p, perm)
+}
+
+// smbFS reads and writes files on an SMB share. go-smb2 takes paths
+// relative to the share, with / or \ between names.
+type smbFS struct {
+	share *smb2.Share
+}
+
+func smbPath(p string) string {
+	return strings.TrimPrefix(p, "/")
+}
+
+func (s smbFS) ReadDir(p string) ([]fs.FileInfo, error) { return s.share.ReadDir(smbPath(p)) }
+func (s smbFS) Stat(p string) (fs.FileInfo, error)      { return s.share.Stat(smbPath(p)) }
+func (s smbFS) Mkdir(p string, perm fs.FileMode) error  { return s.share.Mkdir(smbPath(p), perm) }
+func (s smbFS) Remove(p string) error                   { return s.share.Remove(smbPath(p)) }
+
+func (s smbFS) Rename(oldpath, newpath string) error {
+	return s.share.Rename(smbPath(oldpath), smbPath(newpath))
+}
+
+func (s smbFS) Open(p string) (io.ReadCloser, error) {
+	f, err := s.share.Open(smbPath(p))
+	if err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func (s smbFS) Create(p string) (io.WriteCloser, error) {
+	f, err := s.share.Create(smbPath(p))
+	if err != nil {
+		return nil, err
+	}
+	return f, nil
+}
@@ -0,0 +1,267 @@
+package rename
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"raven-file-manager/pkg/fileview"
+)
+
+// Case is a case transform applied to new names
+type Case int
+
+const (
+	KeepCase Case = iota
+	LowerCase
+	UpperCase
+	TitleCase
+)
+
+// Cases names each Case, in order, for the dialog's dropdown
+var Cases = []string{"Keep case", "lowercase", "UPPERCASE", "Title Case"}
+
+// Rules describe how a batch of files is renamed. Each name goes through
+// find and replace, then the pattern, then the case transform.
+type Rules struct {
+	Find       string
+	Replace    string // $1 and ${name} refer to groups when Regex is set
+	Regex      bool
+	IgnoreCase bool
+
+	// Pattern builds the name before the extension, which is kept. {name}
+	// is the name after find and replace, {n} the file's number (zero
+	// padded with {n:3}), {date} its modification date and {ext} its
+	// extension. Empty means {name}.
+	Pattern string
+	Start   int // Number of the first file
+
+	Case Case
+}
+
+// Change is the new name planned for one file
+type Change struct {
+	Entry   fileview.FileEntry
+	NewName string
+	Problem string // Why the rename can't go ahead, empty if it can
+}
+
+// Renamed reports whether the change gives the file a different name
+func (c Change) Renamed() bool {
+	return c.NewName != c.Entry.Name
+}
+
+// numberToken matches {n} and {n:width}
+var numberToken = regexp.MustCompile(`\{n(?::(\d+))?\}`)
+
+// Plan works out the new name of each file, numbering them in the order
+// given. It fails only when Find is not a valid regular expression;
+// names that can't be used are reported in each Change's Problem.
+func Plan(entries []fileview.FileEntry, rules Rules) ([]Change, error) {
+	var find *regexp.Regexp
+	if rules.Find != "" {
+		expr := rules.Find
+		if !rules.Regex {
+			expr = regexp.QuoteMeta(expr)
+		}
+		if rules.IgnoreCase {
+			expr = "(?i)" + expr
+		}
+		var err error
+		if find, err = regexp.Compile(expr); err != nil {
+			return nil, fmt.Errorf("invalid regular expression: %w", err)
+		}
+	}
+
+	changes := make([]Change, len(entries))
+	for i, entry := range entries {
+		name := entry.Name
+		if find != nil {
+			if rules.Regex {
+				name = find.ReplaceAllString(name, rules.Replace)
+			} else {
+				name = find.ReplaceAllLiteralString(name, rules.Replace)
+			}
+		}
+		name = applyPattern(name, entry, rules.Pattern, rules.Start+i)
+		changes[i] = Change{Entry: entry, NewName: applyCase(name, entry.IsDir, rules.Case)}
+	}
+	checkProblems(changes)
+	return changes, nil
+}
+
+// splitExt splits name into the part before its extension and the
+// extension, with its dot. Folders and dotfiles have no extension.
+func splitExt(name string, isDir bool) (string, string) {
+	ext := filepath.Ext(name)
+	if isDir || ext == name {
+		return name, ""
+	}
+	return strings.TrimSuffix(name, ext), ext
+}
+
+func applyPattern(name string, entry fileview.FileEntry, pattern string, number int) string {
+	if pattern == "" {
+		return name
+	}
+	stem, ext := splitExt(name, entry.IsDir)
+
+	result := numberToken.ReplaceAllStringFunc(pattern, func(token string) string {
+		width := 0
+		if m := numberToken.FindStringSubmatch(token); m[1] != "" {
+			width, _ = strconv.Atoi(m[1])
+		}
+		return fmt.Sprintf("%0*d", width, number)
+	})
+	result = strings.NewReplacer(
+		"{name}", stem,
+		"{date}", entry.ModTime.Format(time.DateOnly),
+		"{ext}", strings.TrimPrefix(ext, "."),
+	).Replace(result)
+	return result + ext
+}
+
+func applyCase(name string, isDir bool, c Case) string {
+	switch c {
+	case LowerCase:
+		return strings.ToLower(name)
+	case UpperCase:
+		return strings.ToUpper(name)
+	case TitleCase:
+		// Capitalize the first letter of each word, lowering the rest and
+		// the extension
+		stem, ext := splitExt(name, isDir)
+		runes := []rune(strings.ToLower(stem))
+		start := true
+		for i, r := range runes {
+			if start && unicode.IsLetter(r) {
+				runes[i] = unicode.ToUpper(r)
+			}
+			start = !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '\''
+		}
+		return string(runes) + strings.ToLower(ext)
+	}
+	return name
+}
+
+// checkProblems fills in the Problem of changes whose new name is invalid,
+// given to more than one file, or taken by a file outside the batch
+func checkProblems(changes []Change) {
+	// Names are per folder; search results can span several
+	key := func(dir, name string) string { return dir + "\x00" + name }
+
+	leaving := make(map[string]bool)
+	count := make(map[string]int)
+	for _, c := range changes {
+		dir := fileview.GetParentPath(c.Entry.Path)
+		if c.Renamed() {
+			leaving[key(dir, c.Entry.Name)] = true
+		}
+		count[key(dir, c.NewName)]++
+	}
+
+	for i := range changes {
+		c := &changes[i]
+		dir := fileview.GetParentPath(c.Entry.Path)
+		switch {
+		case c.NewName == "" || c.NewName == "." || c.NewName == "..":
+			c.Problem = "Invalid name"
+		case strings.Contains(c.NewName, "/"):
+			c.Problem = "Names can't contain /"
+		case count[key(dir, c.NewName)] > 1:
+			c.Problem = "Same name as another file"
+		case c.Renamed() && !leaving[key(dir, c.NewName)] && takenBy(c.Entry.Path, fileview.Join(dir, c.NewName)):
+			c.Problem = "Name already taken"
+		}
+	}
+}
+
+// takenBy reports whether a file other than the one at path exists at
+// newPath. On case-insensitive filesystems a change of case finds the
+// file itself.
+func takenBy(path, newPath string) bool {
+	info, err := fileview.Stat(newPath)
+	if err != nil {
+		return false
+	}
+	if !strings.EqualFold(path, newPath) {
+		return true
+	}
+	// Files on servers can't be compared, but differing only in case they
+	// are almost certainly the same
+	if fileview.IsRemote(path) {
+		return false
+	}
+	self, err := fileview.Stat(path)
+	return err != nil || !os.SameFile(info, self)
+}
+
+// HasProblems reports whether any change has a Problem
+func HasProblems(changes []Change) bool {
+	for _, c := range changes {
+		if c.Problem != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// Apply renames the files, skipping those whose name stays the same. Files
+// taking a name another file in the batch gives up, such as when two are
+// swapped, are renamed through a temporary name first. It stops at the
+// first failure, leaving the files renamed so far.
+func Apply(changes []Change) error {
+	if HasProblems(changes) {
+		return errors.New("some names can't be used")
+	}
+
+	type move struct{ from, to string }
+	var direct, staged []move
+	leaving := make(map[string]bool)
+	for _, c := range changes {
+		if c.Renamed() {
+			leaving[c.Entry.Path] = true
+		}
+	}
+	for _, c := range changes {
+		if !c.Renamed() {
+			continue
+		}
+		to := fileview.Join(fileview.GetParentPath(c.Entry.Path), c.NewName)
+		if leaving[to] || strings.EqualFold(to, c.Entry.Path) {
+			staged = append(staged, move{c.Entry.Path, to})
+		} else {
+			direct = append(direct, move{c.Entry.Path, to})
+		}
+	}
+
+	for _, m := range direct {
+		if err := fileview.Rename(m.from, m.to); err != nil {
+			return err
+		}
+	}
+
+	temps := make([]string, len(staged))
+	for i, m := range staged {
+		temps[i] = fileview.Join(fileview.GetParentPath(m.from), fmt.Sprintf(".raven-rename-%d-%d", os.Getpid(), i))
+		if err := fileview.Rename(m.from, temps[i]); err != nil {
+			// Put back the ones already moved aside
+			for j := 0; j < i; j++ {
+				fileview.Rename(temps[j], staged[j].from)
+			}
+			return err
+		}
+	}
+	for i, m := range staged {
+		if err := fileview.Rename(temps[i], m.to); err != nil {
+			return fmt.Errorf("%w (%s was left as %s)", err, filepath.Base(m.from), filepath.Base(temps[i]))
+		}
+	}
+	return nil
+}
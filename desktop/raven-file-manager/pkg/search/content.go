@@ -0,0 +1,281 @@
+package search
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+
+	"raven-file-manager/pkg/fileview"
+)
+
+const (
+	contentContextLines = 2    // Lines kept above and below a match
+	maxMatchesPerFile   = 20   // Further matches in a file are dropped
+	maxContextLen       = 160  // Longer lines are cut around the match
+	binarySniffLen      = 8192 // Bytes checked for NUL to spot binary files
+	maxLineLen          = 1 << 20
+)
+
+// ContentSearch searches the text files below root for pattern, in the
+// manner of ripgrep: files are read by one worker per CPU, binary and
+// hidden files are skipped, and so is everything .gitignore excludes. The
+// pattern is literal and case-insensitive unless it contains an uppercase
+// letter. Each result is one matching line with the lines around it, and
+// Indices holds start/end pairs of the matches in Context. Results are
+// sorted by path and line.
+func (se *Engine) ContentSearch(ctx context.Context, root, pattern string, maxFileSize int64, maxResults int) []Result {
+	se.mu.Lock()
+	if se.searching && se.cancelFunc != nil {
+		se.cancelFunc()
+	}
+	ctx, se.cancelFunc = context.WithCancel(ctx)
+	se.searching = true
+	se.mu.Unlock()
+
+	defer func() {
+		se.mu.Lock()
+		se.searching = false
+		se.mu.Unlock()
+	}()
+
+	results := make([]Result, 0)
+	if pattern == "" {
+		return results
+	}
+
+	expr := regexp.QuoteMeta(pattern)
+	if !strings.ContainsFunc(pattern, unicode.IsUpper) {
+		expr = "(?i)" + expr
+	}
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return results
+	}
+
+	// Stops the walk and the workers once there are enough results
+	ctx, stop := context.WithCancel(ctx)
+	defer stop()
+
+	var resultMu sync.Mutex
+	fileChan := make(chan string, 100)
+	var wg sync.WaitGroup
+
+	for i := 0; i < runtime.NumCPU(); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for path := range fileChan {
+				if ctx.Err() != nil {
+					continue
+				}
+				matches := searchFileContent(ctx, path, re)
+				if len(matches) == 0 {
+					continue
+				}
+				resultMu.Lock()
+				results = append(results, matches...)
+				if len(results) >= maxResults {
+					stop()
+				}
+				resultMu.Unlock()
+			}
+		}()
+	}
+
+	if candidates, ok := se.indexedCandidates(ctx, root, pattern); ok {
+		// The index has narrowed the search to the files that may match
+	feed:
+		for _, path := range candidates {
+			select {
+			case fileChan <- path:
+			case <-ctx.Done():
+				break feed
+			}
+		}
+	} else {
+		walkSearchable(ctx, root, maxFileSize, fileChan)
+	}
+
+	close(fileChan)
+	wg.Wait()
+
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Entry.Path != results[j].Entry.Path {
+			return results[i].Entry.Path < results[j].Entry.Path
+		}
+		return results[i].LineNum < results[j].LineNum
+	})
+
+	if len(results) > maxResults {
+		results = results[:maxResults]
+	}
+
+	return results
+}
+
+// walkSearchable sends the files below root that ContentSearch reads to
+// files, until ctx is done
+func walkSearchable(ctx context.Context, root string, maxFileSize int64, files chan<- string) {
+	ig := NewIgnorer(root)
+	filepath.WalkDir(root, func(path string, d os.DirEntry, err error) error {
+		if ctx.Err() != nil {
+			return filepath.SkipAll
+		}
+		if err != nil || path == root {
+			return nil
+		}
+
+		if !ig.Searchable(path, d.IsDir()) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() || !d.Type().IsRegular() {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil || info.Size() > maxFileSize {
+			return nil
+		}
+
+		select {
+		case files <- path:
+		case <-ctx.Done():
+			return filepath.SkipAll
+		}
+		return nil
+	})
+}
+
+// searchFileContent returns the lines of path matching re, with their
+// context. Files with a NUL byte near the start are taken as binary.
+func searchFileContent(ctx context.Context, path string, re *regexp.Regexp) []Result {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return nil
+	}
+
+	reader := bufio.NewReaderSize(file, binarySniffLen)
+	if head, _ := reader.Peek(binarySniffLen); bytes.IndexByte(head, 0) >= 0 {
+		return nil
+	}
+
+	entry := fileview.FileEntry{
+		Name:    filepath.Base(path),
+		Path:    path,
+		Size:    info.Size(),
+		ModTime: info.ModTime(),
+		Mode:    info.Mode(),
+	}
+
+	var results []Result
+	var before []string
+	var waiting []int // Results still collecting lines below them
+
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxLineLen)
+	lineNum := 0
+
+	for scanner.Scan() {
+		lineNum++
+		if lineNum%4096 == 0 && ctx.Err() != nil {
+			return nil
+		}
+		line := scanner.Text()
+
+		still := waiting[:0]
+		for _, i := range waiting {
+			results[i].After = append(results[i].After, clipLine(line))
+			if len(results[i].After) < contentContextLines {
+				still = append(still, i)
+			}
+		}
+		waiting = still
+
+		if len(results) < maxMatchesPerFile && re.MatchString(line) {
+			context, indices := snippet(line, re)
+			results = append(results, Result{
+				Entry:     entry,
+				Score:     100 - lineNum,
+				Indices:   indices,
+				MatchType: "content",
+				Context:   context,
+				LineNum:   lineNum,
+				Before:    slices.Clone(before),
+			})
+			waiting = append(waiting, len(results)-1)
+		}
+
+		before = append(before, clipLine(line))
+		if len(before) > contentContextLines {
+			before = before[1:]
+		}
+
+		if len(results) >= maxMatchesPerFile && len(waiting) == 0 {
+			break
+		}
+	}
+
+	return results
+}
+
+// snippet cuts line to maxContextLen around its first match and returns it
+// with the start/end offsets of every match in it
+func snippet(line string, re *regexp.Regexp) (string, []int) {
+	if len(line) > maxContextLen {
+		loc := re.FindStringIndex(line)
+		start := max(0, loc[0]-maxContextLen/4)
+		end := min(len(line), start+maxContextLen)
+		for start > 0 && !utf8.RuneStart(line[start]) {
+			start--
+		}
+		for end < len(line) && !utf8.RuneStart(line[end]) {
+			end--
+		}
+
+		cut := line[start:end]
+		if start > 0 {
+			cut = "…" + cut
+		}
+		if end < len(line) {
+			cut += "…"
+		}
+		line = cut
+	}
+
+	var indices []int
+	for _, loc := range re.FindAllStringIndex(line, -1) {
+		indices = append(indices, loc[0], loc[1])
+	}
+	return line, indices
+}
+
+// clipLine shortens a context line to maxContextLen
+func clipLine(line string) string {
+	if len(line) <= maxContextLen {
+		return line
+	}
+	end := maxContextLen
+	for end > 0 && !utf8.RuneStart(line[end]) {
+		end--
+	}
+	return line[:end] + "…"
+}
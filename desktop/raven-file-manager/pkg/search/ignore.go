@@ -0,0 +1,193 @@
+package search
+
+import (
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// ignoreRule is one pattern from a .gitignore file
+type ignoreRule struct {
+	base     string // Directory holding the .gitignore
+	pattern  string
+	negate   bool // "!pattern" re-includes what earlier rules excluded
+	dirOnly  bool // "pattern/" only matches directories
+	anchored bool // Has a slash, so it is matched against the path below base
+}
+
+// loadIgnoreRules reads dir/.gitignore. A missing file has no rules.
+func loadIgnoreRules(dir string) []ignoreRule {
+	data, err := os.ReadFile(filepath.Join(dir, ".gitignore"))
+	if err != nil {
+		return nil
+	}
+
+	var rules []ignoreRule
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimRight(line, " \r")
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		rule := ignoreRule{base: dir}
+		if strings.HasPrefix(line, "!") {
+			rule.negate = true
+			line = line[1:]
+		}
+		line = strings.TrimPrefix(line, `\`)
+		if strings.HasSuffix(line, "/") {
+			rule.dirOnly = true
+			line = strings.TrimRight(line, "/")
+		}
+		if strings.Contains(line, "/") {
+			rule.anchored = true
+			line = strings.TrimPrefix(line, "/")
+		}
+		if line == "" {
+			continue
+		}
+		rule.pattern = line
+		rules = append(rules, rule)
+	}
+	return rules
+}
+
+// parentIgnoreRules returns the .gitignore rules of the directories above
+// root, when root is inside a git repository, outermost first
+func parentIgnoreRules(root string) []ignoreRule {
+	if _, err := os.Stat(filepath.Join(root, ".git")); err == nil {
+		return nil
+	}
+
+	var dirs []string
+	for dir := filepath.Dir(root); ; dir = filepath.Dir(dir) {
+		dirs = append(dirs, dir)
+		if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+			break
+		}
+		if dir == filepath.Dir(dir) {
+			// Not in a repository
+			return nil
+		}
+	}
+
+	var rules []ignoreRule
+	for i := len(dirs) - 1; i >= 0; i-- {
+		rules = append(rules, loadIgnoreRules(dirs[i])...)
+	}
+	return rules
+}
+
+// isIgnored reports whether rules exclude p. As in git, the last rule that
+// matches decides.
+func isIgnored(rules []ignoreRule, p string, isDir bool) bool {
+	ignored := false
+	for _, rule := range rules {
+		if rule.dirOnly && !isDir {
+			continue
+		}
+
+		var matched bool
+		if rule.anchored {
+			rel, err := filepath.Rel(rule.base, p)
+			if err != nil || strings.HasPrefix(rel, "..") {
+				continue
+			}
+			matched = matchGlobPath(rule.pattern, filepath.ToSlash(rel))
+		} else {
+			matched, _ = path.Match(rule.pattern, filepath.Base(p))
+		}
+
+		if matched {
+			ignored = !rule.negate
+		}
+	}
+	return ignored
+}
+
+// Ignorer tells which files below a root folder ContentSearch reads,
+// loading each folder's .gitignore once
+type Ignorer struct {
+	root  string
+	rules map[string][]ignoreRule // Rules in effect in each folder
+	dirs  map[string]bool         // Whether each folder is looked inside
+}
+
+// NewIgnorer returns an Ignorer for searches from root
+func NewIgnorer(root string) *Ignorer {
+	root = filepath.Clean(root)
+	return &Ignorer{
+		root:  root,
+		rules: map[string][]ignoreRule{root: append(parentIgnoreRules(root), loadIgnoreRules(root)...)},
+		dirs:  map[string]bool{root: true},
+	}
+}
+
+// Searchable reports whether ContentSearch from the root would read p, or
+// look inside it if it is a folder: neither it nor a folder above it is
+// hidden or excluded by .gitignore
+func (ig *Ignorer) Searchable(p string, isDir bool) bool {
+	if p == ig.root {
+		return true
+	}
+	if isDir {
+		if searchable, ok := ig.dirs[p]; ok {
+			return searchable
+		}
+	}
+
+	dir := filepath.Dir(p)
+	searchable := strings.HasPrefix(p, strings.TrimSuffix(ig.root, "/")+"/") &&
+		!strings.HasPrefix(filepath.Base(p), ".") &&
+		ig.Searchable(dir, true) &&
+		!isIgnored(ig.rulesIn(dir), p, isDir)
+	if isDir {
+		ig.dirs[p] = searchable
+	}
+	return searchable
+}
+
+// rulesIn returns the rules in effect in dir, a searchable folder below
+// the root
+func (ig *Ignorer) rulesIn(dir string) []ignoreRule {
+	if rules, ok := ig.rules[dir]; ok {
+		return rules
+	}
+	rules := append(slices.Clip(ig.rulesIn(filepath.Dir(dir))), loadIgnoreRules(dir)...)
+	ig.rules[dir] = rules
+	return rules
+}
+
+// matchGlobPath matches a slash-separated path against pattern, where a
+// "**" segment stands for any number of directories
+func matchGlobPath(pattern, name string) bool {
+	return matchSegments(strings.Split(pattern, "/"), strings.Split(name, "/"))
+}
+
+func matchSegments(pattern, name []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			rest := pattern[1:]
+			if len(rest) == 0 {
+				return true
+			}
+			for i := 0; i <= len(name); i++ {
+				if matchSegments(rest, name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+
+		if len(name) == 0 {
+			return false
+		}
+		if matched, _ := path.Match(pattern[0], name[0]); !matched {
+			return false
+		}
+		pattern, name = pattern[1:], name[1:]
+	}
+	return len(name) == 0
+}
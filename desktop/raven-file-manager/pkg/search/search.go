@@ -1,11 +1,9 @@
 package search
 
 import (
-	"bufio"
 	"context"
 	"os"
 	"path/filepath"
-	"regexp"
 	"sort"
 	"strings"
 	"sync"
@@ -19,6 +17,20 @@ type Engine struct {
 	mu         sync.RWMutex
 	searching  bool
 	cancelFunc context.CancelFunc
+	index      Index
+}
+
+// Index answers searches in the folders it covers without walking them.
+// Both methods return false when dir isn't covered, and the search walks
+// it instead.
+type Index interface {
+	// Files returns what is below dir and has the characters of pattern,
+	// in order, in its path relative to dir
+	Files(ctx context.Context, dir, pattern string) ([]fileview.FileEntry, bool)
+
+	// ContentCandidates returns, sorted, the files below dir that
+	// ContentSearch reads and that may contain text
+	ContentCandidates(ctx context.Context, dir, text string) ([]string, bool)
 }
 
 // Result represents a search match
@@ -29,6 +41,8 @@ type Result struct {
 	MatchType string
 	Context   string
 	LineNum   int
+	Before    []string // Content matches: lines above the match
+	After     []string // Content matches: lines below the match
 }
 
 // FuzzyMatcher implements fzf-style fuzzy matching
@@ -54,6 +68,34 @@ func NewEngine() *Engine {
 	return &Engine{}
 }
 
+// SetIndex makes searches use ix where it covers them; nil walks every
+// search
+func (se *Engine) SetIndex(ix Index) {
+	se.mu.Lock()
+	defer se.mu.Unlock()
+	se.index = ix
+}
+
+func (se *Engine) indexedFiles(ctx context.Context, root, pattern string) ([]fileview.FileEntry, bool) {
+	se.mu.RLock()
+	ix := se.index
+	se.mu.RUnlock()
+	if ix == nil {
+		return nil, false
+	}
+	return ix.Files(ctx, root, pattern)
+}
+
+func (se *Engine) indexedCandidates(ctx context.Context, root, pattern string) ([]string, bool) {
+	se.mu.RLock()
+	ix := se.index
+	se.mu.RUnlock()
+	if ix == nil {
+		return nil, false
+	}
+	return ix.ContentCandidates(ctx, root, pattern)
+}
+
 // NewFuzzyMatcher creates a new fuzzy matcher
 func NewFuzzyMatcher(pattern string) *FuzzyMatcher {
 	caseSensitive := false
@@ -187,6 +229,26 @@ func (se *Engine) Search(ctx context.Context, root, query string, maxResults int
 	results := make([]Result, 0, maxResults)
 	matcher := NewFuzzyMatcher(parsed.pattern)
 
+	if entries, ok := se.indexedFiles(ctx, root, parsed.pattern); ok {
+		for _, entry := range entries {
+			if score, indices, matched := matchPath(matcher, parsed, root, entry.Path, entry.Name); matched {
+				results = append(results, Result{
+					Entry:     entry,
+					Score:     score,
+					Indices:   indices,
+					MatchType: "filename",
+				})
+			}
+		}
+		sort.Slice(results, func(i, j int) bool {
+			return results[i].Score > results[j].Score
+		})
+		if len(results) > maxResults {
+			results = results[:maxResults]
+		}
+		return results
+	}
+
 	filepath.WalkDir(root, func(path string, d os.DirEntry, err error) error {
 		select {
 		case <-ctx.Done():
@@ -202,17 +264,11 @@ func (se *Engine) Search(ctx context.Context, root, query string, maxResults int
 			return filepath.SkipDir
 		}
 
-		relPath, _ := filepath.Rel(root, path)
-		if relPath == "." {
+		if path == root {
 			return nil
 		}
 
-		score, indices, matched := matcher.Match(d.Name())
-		if !matched {
-			score, indices, matched = matcher.Match(relPath)
-		}
-
-		if matched && score > 0 {
+		if score, indices, matched := matchPath(matcher, parsed, root, path, d.Name()); matched {
 			info, err := d.Info()
 			if err != nil {
 				return nil
@@ -228,14 +284,6 @@ func (se *Engine) Search(ctx context.Context, root, query string, maxResults int
 				IsHidden: strings.HasPrefix(d.Name(), "."),
 			}
 
-			if parsed.exclude != "" {
-				excludeMatcher := NewFuzzyMatcher(parsed.exclude)
-				_, _, excludeMatched := excludeMatcher.Match(d.Name())
-				if excludeMatched {
-					return nil
-				}
-			}
-
 			results = append(results, Result{
 				Entry:     entry,
 				Score:     score,
@@ -258,155 +306,24 @@ func (se *Engine) Search(ctx context.Context, root, query string, maxResults int
 	return results
 }
 
-// ContentSearch searches file contents for a pattern
-func (se *Engine) ContentSearch(ctx context.Context, root, pattern string, maxFileSize int64, maxResults int) []Result {
-	se.mu.Lock()
-	if se.searching && se.cancelFunc != nil {
-		se.cancelFunc()
-	}
-	ctx, se.cancelFunc = context.WithCancel(ctx)
-	se.searching = true
-	se.mu.Unlock()
-
-	defer func() {
-		se.mu.Lock()
-		se.searching = false
-		se.mu.Unlock()
-	}()
-
-	results := make([]Result, 0)
-	resultMu := sync.Mutex{}
-
-	re, err := regexp.Compile("(?i)" + regexp.QuoteMeta(pattern))
-	if err != nil {
-		return results
-	}
-
-	fileChan := make(chan string, 100)
-	var wg sync.WaitGroup
-
-	numWorkers := 4
-	for i := 0; i < numWorkers; i++ {
-		wg.Add(1)
-		go func() {
-			defer wg.Done()
-			for path := range fileChan {
-				select {
-				case <-ctx.Done():
-					return
-				default:
-				}
-
-				matches := searchFileContent(path, re, pattern)
-				if len(matches) > 0 {
-					resultMu.Lock()
-					results = append(results, matches...)
-					resultMu.Unlock()
-				}
-			}
-		}()
-	}
-
-	filepath.WalkDir(root, func(path string, d os.DirEntry, err error) error {
-		select {
-		case <-ctx.Done():
-			return ctx.Err()
-		default:
-		}
-
-		if err != nil || d.IsDir() {
-			return nil
-		}
-
-		if strings.HasPrefix(d.Name(), ".") {
-			return nil
-		}
-
-		if fileview.IsBinaryFile(path) {
-			return nil
-		}
-
-		info, err := d.Info()
-		if err != nil || info.Size() > maxFileSize {
-			return nil
-		}
-
-		resultMu.Lock()
-		count := len(results)
-		resultMu.Unlock()
-		if count >= maxResults {
-			return filepath.SkipAll
-		}
-
-		fileChan <- path
-		return nil
-	})
-
-	close(fileChan)
-	wg.Wait()
-
-	sort.Slice(results, func(i, j int) bool {
-		return results[i].Score > results[j].Score
-	})
-
-	if len(results) > maxResults {
-		results = results[:maxResults]
+// matchPath scores the file at path, named name, against the query. The
+// name is tried before the path relative to root.
+func matchPath(matcher *FuzzyMatcher, parsed parsedQuery, root, path, name string) (int, []int, bool) {
+	score, indices, matched := matcher.Match(name)
+	if !matched {
+		relPath, _ := filepath.Rel(root, path)
+		score, indices, matched = matcher.Match(relPath)
 	}
-
-	return results
-}
-
-func searchFileContent(path string, re *regexp.Regexp, pattern string) []Result {
-	file, err := os.Open(path)
-	if err != nil {
-		return nil
+	if !matched || score <= 0 {
+		return 0, nil, false
 	}
-	defer file.Close()
-
-	info, _ := file.Stat()
-
-	var results []Result
-	scanner := bufio.NewScanner(file)
-	lineNum := 0
-
-	for scanner.Scan() {
-		lineNum++
-		line := scanner.Text()
-
-		if re.MatchString(line) {
-			entry := fileview.FileEntry{
-				Name:    filepath.Base(path),
-				Path:    path,
-				Size:    info.Size(),
-				ModTime: info.ModTime(),
-				IsDir:   false,
-			}
-
-			indices := re.FindStringIndex(line)
 
-			results = append(results, Result{
-				Entry:     entry,
-				Score:     100 - lineNum,
-				Indices:   []int{indices[0], indices[1]},
-				MatchType: "content",
-				Context:   truncateLine(line, 100),
-				LineNum:   lineNum,
-			})
-
-			if len(results) >= 5 {
-				break
-			}
+	if parsed.exclude != "" {
+		if _, _, excluded := NewFuzzyMatcher(parsed.exclude).Match(name); excluded {
+			return 0, nil, false
 		}
 	}
-
-	return results
-}
-
-func truncateLine(line string, maxLen int) string {
-	if len(line) <= maxLen {
-		return line
-	}
-	return line[:maxLen-3] + "..."
+	return score, indices, true
 }
 
 type parsedQuery struct {
@@ -0,0 +1,378 @@
+// Package snapshots finds older copies of a file in filesystem snapshots:
+// Snapper snapshots on btrfs, ZFS snapshots and Timeshift backups.
+package snapshots
+
+import (
+	"bufio"
+	"encoding/xml"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"raven-file-manager/pkg/clipboard"
+	"raven-file-manager/pkg/fileview"
+	"raven-file-manager/pkg/trash"
+)
+
+// Timeshift names snapshots after the time they were taken
+const timeshiftLayout = "2006-01-02_15-04-05"
+
+// Where Timeshift keeps its snapshots: rsync snapshots on the root file
+// system, and the backup device while Timeshift has it mounted
+var timeshiftBases = []string{
+	"/",
+	"/run/timeshift/backup",
+	"/run/timeshift/*/backup",
+}
+
+// Version is a copy of a file in a snapshot
+type Version struct {
+	Path     string    // The copy inside the snapshot
+	Source   string    // "Snapper", "ZFS" or "Timeshift"
+	Snapshot string    // Snapshot name, number or description
+	Taken    time.Time // When the snapshot was taken
+	Size     int64
+	ModTime  time.Time
+	IsDir    bool
+}
+
+// Title names the snapshot the version is from
+func (v Version) Title() string {
+	return fmt.Sprintf("%s %s", v.Source, v.Snapshot)
+}
+
+// SizeChange describes how the version's size differs from current
+func (v Version) SizeChange(current int64) string {
+	switch diff := v.Size - current; {
+	case v.IsDir:
+		return ""
+	case diff > 0:
+		return fileview.HumanizeSize(diff) + " larger"
+	case diff < 0:
+		return fileview.HumanizeSize(-diff) + " smaller"
+	default:
+		return "same size"
+	}
+}
+
+// Find returns the copies of path in snapshots, newest snapshot first.
+// Files identical in size and modification time to the copy in a newer
+// snapshot, or to path itself, are left out. Snapshots the user can't
+// read are skipped.
+func Find(path string) ([]Version, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	real, err := filepath.EvalSymlinks(abs)
+	if err != nil {
+		return nil, err
+	}
+	current, err := os.Lstat(real)
+	if err != nil {
+		return nil, err
+	}
+
+	var candidates []Version
+	if m, ok := mountOf(real); ok {
+		rel, _ := filepath.Rel(m.point, real)
+		switch m.fstype {
+		case "btrfs":
+			candidates = append(candidates, snapperVersions(m.point, rel)...)
+		case "zfs":
+			candidates = append(candidates, zfsVersions(m, rel)...)
+		}
+	}
+	candidates = append(candidates, timeshiftVersions(real)...)
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].Taken.After(candidates[j].Taken)
+	})
+
+	var versions []Version
+	seen := make(map[string]bool)
+	newerSize, newerMod := current.Size(), current.ModTime()
+	for _, v := range candidates {
+		// A Timeshift device can be mounted at more than one base
+		key := fmt.Sprint(v.Source, v.Snapshot, v.Taken.Unix())
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+
+		if !v.IsDir && v.Size == newerSize && v.ModTime.Equal(newerMod) {
+			continue
+		}
+		newerSize, newerMod = v.Size, v.ModTime
+		versions = append(versions, v)
+	}
+	return versions, nil
+}
+
+// statVersion fills in a version from its copy, reporting false when the
+// snapshot has no copy or it can't be read
+func statVersion(v Version) (Version, bool) {
+	info, err := os.Lstat(v.Path)
+	if err != nil {
+		return v, false
+	}
+	v.Size = info.Size()
+	v.ModTime = info.ModTime()
+	v.IsDir = info.IsDir()
+	return v, true
+}
+
+// snapperInfo is the part of a Snapper snapshot's info.xml used here
+type snapperInfo struct {
+	Num         int    `xml:"num"`
+	Date        string `xml:"date"` // UTC, "2024-01-15 10:00:01"
+	Description string `xml:"description"`
+}
+
+// snapperVersions looks in <subvolume>/.snapshots/<n>/snapshot
+func snapperVersions(mount, rel string) []Version {
+	root := filepath.Join(mount, ".snapshots")
+	dirs, err := os.ReadDir(root)
+	if err != nil {
+		return nil
+	}
+
+	var versions []Version
+	for _, dir := range dirs {
+		if _, err := strconv.Atoi(dir.Name()); err != nil {
+			continue
+		}
+		v := Version{
+			Path:     filepath.Join(root, dir.Name(), "snapshot", rel),
+			Source:   "Snapper",
+			Snapshot: "#" + dir.Name(),
+		}
+
+		var info snapperInfo
+		if data, err := os.ReadFile(filepath.Join(root, dir.Name(), "info.xml")); err == nil && xml.Unmarshal(data, &info) == nil {
+			if taken, err := time.Parse("2006-01-02 15:04:05", info.Date); err == nil {
+				v.Taken = taken.Local()
+			}
+			if info.Description != "" {
+				v.Snapshot += " (" + info.Description + ")"
+			}
+		}
+		if v.Taken.IsZero() {
+			if st, err := os.Stat(filepath.Join(root, dir.Name())); err == nil {
+				v.Taken = st.ModTime()
+			}
+		}
+
+		if v, ok := statVersion(v); ok {
+			versions = append(versions, v)
+		}
+	}
+	return versions
+}
+
+// zfsVersions looks in <dataset mount>/.zfs/snapshot/<name>. ZFS mounts a
+// snapshot when it is first looked into.
+func zfsVersions(m mount, rel string) []Version {
+	root := filepath.Join(m.point, ".zfs", "snapshot")
+	dirs, err := os.ReadDir(root)
+	if err != nil {
+		return nil
+	}
+	created := zfsCreationTimes(m.source)
+
+	var versions []Version
+	for _, dir := range dirs {
+		v := Version{
+			Path:     filepath.Join(root, dir.Name(), rel),
+			Source:   "ZFS",
+			Snapshot: dir.Name(),
+			Taken:    created[dir.Name()],
+		}
+		if v.Taken.IsZero() {
+			if info, err := dir.Info(); err == nil {
+				v.Taken = info.ModTime()
+			}
+		}
+		if v, ok := statVersion(v); ok {
+			versions = append(versions, v)
+		}
+	}
+	return versions
+}
+
+// zfsCreationTimes asks zfs when each snapshot of dataset was taken. It
+// returns nothing when the zfs tool isn't installed.
+func zfsCreationTimes(dataset string) map[string]time.Time {
+	times := make(map[string]time.Time)
+	out, err := exec.Command("zfs", "list", "-H", "-p", "-t", "snapshot", "-o", "name,creation", "-d", "1", dataset).Output()
+	if err != nil {
+		return times
+	}
+	for _, line := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+		// tank/home@daily-2024-01-15	1705312801
+		name, created, ok := strings.Cut(line, "\t")
+		if !ok {
+			continue
+		}
+		_, snapshot, _ := strings.Cut(name, "@")
+		if seconds, err := strconv.ParseInt(created, 10, 64); err == nil {
+			times[snapshot] = time.Unix(seconds, 0)
+		}
+	}
+	return times
+}
+
+// timeshiftVersions looks in Timeshift's rsync snapshots, which hold the
+// whole system under localhost/, and its btrfs snapshots, which hold the @
+// and @home subvolumes
+func timeshiftVersions(real string) []Version {
+	var versions []Version
+	add := func(snapshots string, inside func(snapshot string) string) {
+		dirs, _ := filepath.Glob(snapshots)
+		for _, dir := range dirs {
+			taken, err := time.ParseInLocation(timeshiftLayout, filepath.Base(dir), time.Local)
+			if err != nil {
+				continue
+			}
+			v := Version{
+				Path:     inside(dir),
+				Source:   "Timeshift",
+				Snapshot: taken.Format("2006-01-02 15:04"),
+				Taken:    taken,
+			}
+			if v, ok := statVersion(v); ok {
+				versions = append(versions, v)
+			}
+		}
+	}
+
+	for _, base := range timeshiftBases {
+		add(filepath.Join(base, "timeshift", "snapshots", "*"), func(snapshot string) string {
+			return filepath.Join(snapshot, "localhost", real)
+		})
+		if base == "/" {
+			continue
+		}
+		add(filepath.Join(base, "timeshift-btrfs", "snapshots", "*"), func(snapshot string) string {
+			if rel, err := filepath.Rel("/home", real); err == nil && !strings.HasPrefix(rel, "..") {
+				return filepath.Join(snapshot, "@home", rel)
+			}
+			return filepath.Join(snapshot, "@", real)
+		})
+	}
+	return versions
+}
+
+// mount is a line of /proc/self/mountinfo
+type mount struct {
+	point  string
+	fstype string
+	source string // Device, or the dataset for ZFS
+}
+
+// mountOf returns the mount path is on
+func mountOf(path string) (mount, bool) {
+	file, err := os.Open("/proc/self/mountinfo")
+	if err != nil {
+		return mount{}, false
+	}
+	defer file.Close()
+
+	var best mount
+	found := false
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		// 36 35 0:32 / /home rw,relatime shared:1 - btrfs /dev/sda2 rw,subvol=/@home
+		before, after, ok := strings.Cut(scanner.Text(), " - ")
+		if !ok {
+			continue
+		}
+		fields, rest := strings.Fields(before), strings.Fields(after)
+		if len(fields) < 5 || len(rest) < 2 {
+			continue
+		}
+		point := unescapeMountPath(fields[4])
+		if !within(path, point) || (found && len(point) < len(best.point)) {
+			continue
+		}
+		best = mount{point: point, fstype: rest[0], source: rest[1]}
+		found = true
+	}
+	return best, found
+}
+
+// within reports whether path is dir or inside it
+func within(path, dir string) bool {
+	return dir == "/" || path == dir || strings.HasPrefix(path, dir+"/")
+}
+
+// unescapeMountPath undoes mountinfo's octal escapes, such as \040 for a
+// space
+func unescapeMountPath(path string) string {
+	if !strings.Contains(path, `\`) {
+		return path
+	}
+	var b strings.Builder
+	for i := 0; i < len(path); i++ {
+		if path[i] == '\\' && i+3 < len(path) {
+			if c, err := strconv.ParseUint(path[i+1:i+4], 8, 8); err == nil {
+				b.WriteByte(byte(c))
+				i += 3
+				continue
+			}
+		}
+		b.WriteByte(path[i])
+	}
+	return b.String()
+}
+
+// Restore puts the version back at path. What is at path now is moved to
+// the trash first, so the restore can be undone from there.
+func Restore(v Version, path string) error {
+	tmp := clipboard.ResolveConflict(filepath.Join(filepath.Dir(path), "."+filepath.Base(path)+".restore"))
+	if err := copyVersion(v, tmp); err != nil {
+		os.RemoveAll(tmp)
+		return err
+	}
+	if _, err := os.Lstat(path); err == nil {
+		if err := trash.Move(path); err != nil {
+			os.RemoveAll(tmp)
+			return fmt.Errorf("move the current version to the trash: %w", err)
+		}
+	}
+	return os.Rename(tmp, path)
+}
+
+// CopyOut copies the version next to path, named after the snapshot's
+// date, and returns where the copy went
+func CopyOut(v Version, path string) (string, error) {
+	ext := ""
+	if !v.IsDir {
+		ext = filepath.Ext(path)
+	}
+	name := strings.TrimSuffix(filepath.Base(path), ext)
+	dst := filepath.Join(filepath.Dir(path), fmt.Sprintf("%s (%s)%s", name, v.Taken.Format("2006-01-02 15-04"), ext))
+	dst = clipboard.ResolveConflict(dst)
+	if err := copyVersion(v, dst); err != nil {
+		os.RemoveAll(dst)
+		return "", err
+	}
+	return dst, nil
+}
+
+// copyVersion copies the version to dst, keeping a file's modification
+// time so the copy still shows when it was last changed
+func copyVersion(v Version, dst string) error {
+	if err := clipboard.CopyFile(v.Path, dst); err != nil {
+		return err
+	}
+	if !v.IsDir {
+		os.Chtimes(dst, time.Now(), v.ModTime)
+	}
+	return nil
+}
@@ -0,0 +1,369 @@
+package trash
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"raven-file-manager/pkg/fileview"
+)
+
+// Item is an entry in the trash
+type Item struct {
+	Name      string
+	Path      string    // Location under Trash/files
+	InfoPath  string    // Matching .trashinfo, empty if missing
+	Original  string    // Where the item was trashed from, from .trashinfo
+	DeletedAt time.Time // From .trashinfo, or the file's mtime if missing
+	Dated     bool      // DeletedAt came from .trashinfo
+	Size      int64
+}
+
+// Policy controls automatic trash cleanup. Zero values disable each rule.
+type Policy struct {
+	MaxAge  time.Duration // Remove items trashed longer ago than this
+	MaxSize int64         // Remove oldest items until the trash fits in this many bytes
+}
+
+// Enabled reports whether the policy would ever remove anything
+func (p Policy) Enabled() bool {
+	return p.MaxAge > 0 || p.MaxSize > 0
+}
+
+// PurgeResult summarizes a purge run
+type PurgeResult struct {
+	Expired int   // Removed for exceeding MaxAge
+	Evicted int   // Removed to stay under MaxSize
+	Freed   int64 // Bytes reclaimed
+}
+
+// Removed returns the total number of items removed
+func (r PurgeResult) Removed() int {
+	return r.Expired + r.Evicted
+}
+
+// Summary describes the purge for a notification
+func (r PurgeResult) Summary() string {
+	var parts []string
+	if r.Expired > 0 {
+		parts = append(parts, fileview.Pluralize(r.Expired, "old item", "old items"))
+	}
+	if r.Evicted > 0 {
+		parts = append(parts, fileview.Pluralize(r.Evicted, "item", "items")+" over the size limit")
+	}
+	return fmt.Sprintf("Removed %s, freeing %s", strings.Join(parts, " and "), fileview.HumanizeSize(r.Freed))
+}
+
+// Dir returns the user's trash directory
+func Dir() string {
+	dataHome := os.Getenv("XDG_DATA_HOME")
+	if dataHome == "" {
+		dataHome = filepath.Join(os.Getenv("HOME"), ".local", "share")
+	}
+	return filepath.Join(dataHome, "Trash")
+}
+
+// List returns the items in the trash, oldest first
+func List() ([]Item, error) {
+	filesDir := filepath.Join(Dir(), "files")
+	infoDir := filepath.Join(Dir(), "info")
+
+	entries, err := os.ReadDir(filesDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	items := make([]Item, 0, len(entries))
+	for _, entry := range entries {
+		item := Item{
+			Name: entry.Name(),
+			Path: filepath.Join(filesDir, entry.Name()),
+		}
+
+		infoPath := filepath.Join(infoDir, entry.Name()+".trashinfo")
+		if original, deleted, err := readInfo(infoPath); err == nil {
+			item.InfoPath = infoPath
+			item.Original = original
+			item.DeletedAt = deleted
+			item.Dated = true
+		} else if info, err := entry.Info(); err == nil {
+			// No usable .trashinfo; the file's own time is only good enough
+			// for ordering, not for deciding the item has expired
+			item.DeletedAt = info.ModTime()
+		}
+
+		item.Size = diskUsage(item.Path)
+		items = append(items, item)
+	}
+
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].DeletedAt.Before(items[j].DeletedAt)
+	})
+	return items, nil
+}
+
+// Purge removes trash items according to the policy: first everything older
+// than MaxAge, then the oldest remaining items until the total is under
+// MaxSize. Items without a deletion date are never expired by age.
+func Purge(policy Policy, now time.Time) (PurgeResult, error) {
+	var result PurgeResult
+	if !policy.Enabled() {
+		return result, nil
+	}
+
+	items, err := List()
+	if err != nil {
+		return result, err
+	}
+
+	var total int64
+	for _, item := range items {
+		total += item.Size
+	}
+
+	var lastErr error
+	var kept []Item
+	for _, item := range items {
+		if policy.MaxAge <= 0 || !item.Dated || now.Sub(item.DeletedAt) <= policy.MaxAge {
+			kept = append(kept, item)
+			continue
+		}
+		if err := remove(item); err != nil {
+			lastErr = err
+			continue
+		}
+		total -= item.Size
+		result.Freed += item.Size
+		result.Expired++
+	}
+
+	// kept is still oldest first
+	for _, item := range kept {
+		if policy.MaxSize <= 0 || total <= policy.MaxSize {
+			break
+		}
+		if err := remove(item); err != nil {
+			lastErr = err
+			continue
+		}
+		total -= item.Size
+		result.Freed += item.Size
+		result.Evicted++
+	}
+
+	return result, lastErr
+}
+
+// IsEmpty reports whether the trash holds no items
+func IsEmpty() bool {
+	dir, err := os.Open(filepath.Join(Dir(), "files"))
+	if err != nil {
+		return true
+	}
+	defer dir.Close()
+	names, _ := dir.Readdirnames(1)
+	return len(names) == 0
+}
+
+// Move puts path in the home trash following the XDG trash spec: the
+// .trashinfo is reserved first with O_EXCL, then the file is renamed into
+// Trash/files. Files on other file systems can't be renamed there, so
+// those are handed to gio, which uses the per-mount .Trash-$UID.
+func Move(path string) error {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+	if _, err := os.Lstat(abs); err != nil {
+		return err
+	}
+
+	filesDir := filepath.Join(Dir(), "files")
+	infoDir := filepath.Join(Dir(), "info")
+	if err := os.MkdirAll(filesDir, 0700); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(infoDir, 0700); err != nil {
+		return err
+	}
+
+	info := fmt.Sprintf("[Trash Info]\nPath=%s\nDeletionDate=%s\n",
+		(&url.URL{Path: abs}).EscapedPath(), time.Now().Format("2006-01-02T15:04:05"))
+
+	base := filepath.Base(abs)
+	for n := 1; ; n++ {
+		name := base
+		if n > 1 {
+			ext := filepath.Ext(base)
+			name = strings.TrimSuffix(base, ext) + "." + strconv.Itoa(n) + ext
+		}
+
+		infoPath := filepath.Join(infoDir, name+".trashinfo")
+		f, err := os.OpenFile(infoPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+		if errors.Is(err, fs.ErrExist) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		_, werr := f.WriteString(info)
+		if cerr := f.Close(); werr == nil {
+			werr = cerr
+		}
+		if werr != nil {
+			os.Remove(infoPath)
+			return werr
+		}
+
+		target := filepath.Join(filesDir, name)
+		if _, err := os.Lstat(target); err == nil {
+			// A file without its .trashinfo is squatting on the name
+			os.Remove(infoPath)
+			continue
+		}
+		if err := os.Rename(abs, target); err != nil {
+			os.Remove(infoPath)
+			if errors.Is(err, syscall.EXDEV) {
+				return moveWithGio(abs)
+			}
+			return err
+		}
+		return nil
+	}
+}
+
+func moveWithGio(path string) error {
+	if output, err := exec.Command("gio", "trash", "--", path).CombinedOutput(); err != nil {
+		msg := strings.TrimSpace(string(output))
+		if strings.Contains(msg, "Permission denied") {
+			return fmt.Errorf("gio trash: %s: %w", msg, fs.ErrPermission)
+		}
+		return fmt.Errorf("gio trash: %s", msg)
+	}
+	return nil
+}
+
+// Empty permanently removes everything in the trash
+func Empty() error {
+	items, err := List()
+	if err != nil {
+		return err
+	}
+
+	var lastErr error
+	for _, item := range items {
+		if err := remove(item); err != nil {
+			lastErr = err
+		}
+	}
+
+	// .trashinfo files whose item is already gone
+	infos, _ := filepath.Glob(filepath.Join(Dir(), "info", "*.trashinfo"))
+	for _, info := range infos {
+		os.Remove(info)
+	}
+	os.Remove(filepath.Join(Dir(), "directorysizes"))
+	return lastErr
+}
+
+// Restore moves item out of the trash to dst, normally its Original path,
+// recreating the folders above it. An existing file at dst is never
+// replaced.
+func Restore(item Item, dst string) error {
+	if _, err := os.Lstat(dst); err == nil {
+		return fmt.Errorf("%s already exists", dst)
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+
+	if err := os.Rename(item.Path, dst); err != nil {
+		if !errors.Is(err, syscall.EXDEV) {
+			return err
+		}
+		// The original location is on another file system now
+		if output, err := exec.Command("mv", "--", item.Path, dst).CombinedOutput(); err != nil {
+			return fmt.Errorf("mv: %s", strings.TrimSpace(string(output)))
+		}
+	}
+	if item.InfoPath != "" {
+		os.Remove(item.InfoPath)
+	}
+	return nil
+}
+
+// Delete permanently removes item and its .trashinfo
+func Delete(item Item) error {
+	return remove(item)
+}
+
+func remove(item Item) error {
+	if err := os.RemoveAll(item.Path); err != nil {
+		return err
+	}
+	if item.InfoPath != "" {
+		os.Remove(item.InfoPath)
+	}
+	return nil
+}
+
+// readInfo parses the original path and DeletionDate from a .trashinfo
+// file. Path is URL-escaped; a relative one is taken from the root.
+func readInfo(infoPath string) (string, time.Time, error) {
+	f, err := os.Open(infoPath)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	defer f.Close()
+
+	var original string
+	var deleted time.Time
+	dated := false
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if value, ok := strings.CutPrefix(line, "Path="); ok {
+			if unescaped, err := url.PathUnescape(value); err == nil {
+				value = unescaped
+			}
+			original = filepath.Join("/", value)
+		} else if value, ok := strings.CutPrefix(line, "DeletionDate="); ok {
+			if deleted, err = time.ParseInLocation("2006-01-02T15:04:05", value, time.Local); err != nil {
+				return "", time.Time{}, err
+			}
+			dated = true
+		}
+	}
+	if !dated {
+		return "", time.Time{}, fmt.Errorf("no DeletionDate in %s", infoPath)
+	}
+	return original, deleted, nil
+}
+
+// diskUsage returns the total size of a file or directory tree
+func diskUsage(path string) int64 {
+	var size int64
+	filepath.WalkDir(path, func(_ string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if info, err := d.Info(); err == nil && !d.IsDir() {
+			size += info.Size()
+		}
+		return nil
+	})
+	return size
+}
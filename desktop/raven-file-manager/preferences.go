@@ -0,0 +1,202 @@
+package main
+
+import (
+	"strings"
+
+	"raven-file-manager/pkg/config"
+
+	"github.com/diamondburned/gotk4/pkg/glib/v2"
+	"github.com/diamondburned/gotk4/pkg/gtk/v4"
+)
+
+// showPreferences opens the preferences dialog. Changes are applied and
+// saved as soon as they are made.
+func (fm *FileManager) showPreferences() {
+	dialog := gtk.NewDialog()
+	dialog.SetTitle("Preferences")
+	dialog.SetTransientFor(fm.window)
+	dialog.SetModal(true)
+	dialog.SetDefaultSize(420, -1)
+
+	content := dialog.ContentArea()
+	content.SetMarginTop(16)
+	content.SetMarginBottom(16)
+	content.SetMarginStart(16)
+	content.SetMarginEnd(16)
+	content.SetSpacing(12)
+
+	content.Append(preferencesHeading("Copying"))
+
+	verifySwitch := gtk.NewSwitch()
+	verifySwitch.SetActive(fm.settings.VerifyCopies)
+	verifySwitch.ConnectStateSet(func(state bool) bool {
+		fm.settings.VerifyCopies = state
+		fm.clipboard.SetVerify(state)
+		config.SaveSettings(fm.settings)
+		return false
+	})
+	content.Append(preferencesRow("Verify copies",
+		"Compare checksums of every pasted file with its source. Slower, but catches bad media.",
+		verifySwitch))
+
+	content.Append(preferencesHeading("List View"))
+
+	ownerSwitch := gtk.NewSwitch()
+	ownerSwitch.SetActive(fm.settings.ShowOwner)
+	ownerSwitch.ConnectStateSet(func(state bool) bool {
+		fm.settings.ShowOwner = state
+		config.SaveSettings(fm.settings)
+		fm.refresh()
+		return false
+	})
+	content.Append(preferencesRow("Show owner",
+		"Add a column with the user that owns each file.",
+		ownerSwitch))
+
+	permsSwitch := gtk.NewSwitch()
+	permsSwitch.SetActive(fm.settings.ShowPermissions)
+	permsSwitch.ConnectStateSet(func(state bool) bool {
+		fm.settings.ShowPermissions = state
+		config.SaveSettings(fm.settings)
+		fm.refresh()
+		return false
+	})
+	content.Append(preferencesRow("Show permissions",
+		"Add a column with the permissions of each file. Click it to change them on files you own.",
+		permsSwitch))
+
+	content.Append(preferencesHeading("Hidden Files"))
+
+	hiddenSwitch := gtk.NewSwitch()
+	hiddenSwitch.SetActive(fm.settings.ShowHidden)
+	hiddenSwitch.ConnectStateSet(func(state bool) bool {
+		fm.settings.ShowHidden = state
+		config.SaveSettings(fm.settings)
+		fm.setShowHidden(state)
+		return false
+	})
+	content.Append(preferencesRow("Show hidden files",
+		"Show dotfiles, files listed in a folder's .hidden file and files matching the patterns below. Ctrl+H switches this until the window closes.",
+		hiddenSwitch))
+
+	patternsEntry := gtk.NewEntry()
+	patternsEntry.SetText(strings.Join(fm.settings.HidePatterns, ", "))
+	patternsEntry.SetPlaceholderText("*.o, node_modules")
+	patternsEntry.SetWidthChars(18)
+	patternsEntry.ConnectChanged(func() {
+		var patterns []string
+		for _, pattern := range strings.Split(patternsEntry.Text(), ",") {
+			if pattern = strings.TrimSpace(pattern); pattern != "" {
+				patterns = append(patterns, pattern)
+			}
+		}
+		fm.settings.HidePatterns = patterns
+		fm.filterState.HidePatterns = patterns
+		config.SaveSettings(fm.settings)
+		fm.refresh()
+	})
+	content.Append(preferencesRow("Hide patterns",
+		"Names to hide like dotfiles, separated by commas. * and ? match any characters.",
+		patternsEntry))
+
+	content.Append(preferencesHeading("Search"))
+
+	editorEntry := gtk.NewEntry()
+	editorEntry.SetText(fm.settings.Editor)
+	editorEntry.SetPlaceholderText("$VISUAL / $EDITOR")
+	editorEntry.SetWidthChars(18)
+	editorEntry.ConnectChanged(func() {
+		fm.settings.Editor = strings.TrimSpace(editorEntry.Text())
+		config.SaveSettings(fm.settings)
+	})
+	content.Append(preferencesRow("Editor",
+		"Opens content search results at the matching line. {file} and {line} are filled in, e.g. code --goto {file}:{line}.",
+		editorEntry))
+
+	content.Append(preferencesHeading("Trash"))
+
+	// Policy changes are debounced so typing a number doesn't purge
+	// against every intermediate value
+	var purgeRestart glib.SourceHandle
+	restartPurge := func() {
+		config.SaveSettings(fm.settings)
+		if purgeRestart != 0 {
+			glib.SourceRemove(purgeRestart)
+		}
+		purgeRestart = glib.TimeoutSecondsAdd(2, func() bool {
+			purgeRestart = 0
+			fm.startTrashPurge()
+			return false
+		})
+	}
+
+	daysSpin := gtk.NewSpinButtonWithRange(0, 3650, 1)
+	daysSpin.SetValue(float64(fm.settings.TrashPurgeDays))
+	daysSpin.ConnectValueChanged(func() {
+		fm.settings.TrashPurgeDays = daysSpin.ValueAsInt()
+		restartPurge()
+	})
+	content.Append(preferencesRow("Delete items older than (days)",
+		"Trashed items are removed after this many days. 0 keeps them until you empty the trash.",
+		daysSpin))
+
+	sizeSpin := gtk.NewSpinButtonWithRange(0, 1024*1024, 100)
+	sizeSpin.SetValue(float64(fm.settings.TrashMaxSizeMB))
+	sizeSpin.ConnectValueChanged(func() {
+		fm.settings.TrashMaxSizeMB = int64(sizeSpin.ValueAsInt())
+		restartPurge()
+	})
+	content.Append(preferencesRow("Maximum trash size (MB)",
+		"The oldest items are removed once the trash grows past this size. 0 means no limit.",
+		sizeSpin))
+
+	note := gtk.NewLabel("Cleanup runs hourly while Raven Files is open.")
+	note.AddCSSClass("dim-label")
+	note.SetHAlign(gtk.AlignStart)
+	content.Append(note)
+
+	buttonBox := gtk.NewBox(gtk.OrientationHorizontal, 8)
+	buttonBox.SetHAlign(gtk.AlignEnd)
+	buttonBox.SetMarginTop(16)
+
+	closeBtn := gtk.NewButton()
+	closeBtn.SetLabel("Close")
+	closeBtn.ConnectClicked(func() { dialog.Destroy() })
+	buttonBox.Append(closeBtn)
+
+	content.Append(buttonBox)
+	dialog.Present()
+}
+
+// preferencesHeading returns a section title for the preferences dialog
+func preferencesHeading(title string) *gtk.Label {
+	label := gtk.NewLabel(title)
+	label.AddCSSClass("heading")
+	label.SetHAlign(gtk.AlignStart)
+	return label
+}
+
+// preferencesRow lays out a setting with its description and control
+func preferencesRow(title, description string, control gtk.Widgetter) *gtk.Box {
+	row := gtk.NewBox(gtk.OrientationHorizontal, 12)
+
+	text := gtk.NewBox(gtk.OrientationVertical, 2)
+	text.SetHExpand(true)
+
+	titleLabel := gtk.NewLabel(title)
+	titleLabel.SetHAlign(gtk.AlignStart)
+	text.Append(titleLabel)
+
+	descLabel := gtk.NewLabel(description)
+	descLabel.AddCSSClass("dim-label")
+	descLabel.SetHAlign(gtk.AlignStart)
+	descLabel.SetWrap(true)
+	descLabel.SetXAlign(0)
+	text.Append(descLabel)
+
+	row.Append(text)
+
+	gtk.BaseWidget(control).SetVAlign(gtk.AlignCenter)
+	row.Append(control)
+	return row
+}
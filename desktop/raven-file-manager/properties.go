@@ -0,0 +1,229 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"raven-file-manager/pkg/fileview"
+	"raven-file-manager/pkg/permissions"
+	"raven-file-manager/pkg/snapshots"
+
+	"github.com/diamondburned/gotk4/pkg/glib/v2"
+	"github.com/diamondburned/gotk4/pkg/gtk/v4"
+)
+
+// Date format of the Properties dialog, where there is room for all of it
+const propertiesDateFormat = "Jan 2, 2006 15:04"
+
+// showSelectedProperties opens the Properties dialog for the one selected
+// file
+func (fm *FileManager) showSelectedProperties() {
+	fm.mu.RLock()
+	if len(fm.selectedFiles) != 1 {
+		fm.mu.RUnlock()
+		return
+	}
+	file := fm.selectedFiles[0]
+	fm.mu.RUnlock()
+
+	fm.showProperties(file)
+}
+
+// showProperties opens the Properties dialog for entry, with its details
+// and the older copies of it found in snapshots
+func (fm *FileManager) showProperties(entry fileview.FileEntry) {
+	dialog := gtk.NewDialog()
+	dialog.SetTitle(entry.Name + " Properties")
+	dialog.SetTransientFor(fm.window)
+	dialog.SetModal(true)
+	dialog.SetDefaultSize(520, 440)
+
+	content := dialog.ContentArea()
+	content.SetMarginTop(16)
+	content.SetMarginBottom(16)
+	content.SetMarginStart(16)
+	content.SetMarginEnd(16)
+	content.SetSpacing(12)
+
+	notebook := gtk.NewNotebook()
+	notebook.SetVExpand(true)
+	notebook.AppendPage(createGeneralTab(entry), gtk.NewLabel("General"))
+	notebook.AppendPage(fm.createVersionsTab(entry, dialog), gtk.NewLabel("Previous Versions"))
+	content.Append(notebook)
+
+	buttonBox := gtk.NewBox(gtk.OrientationHorizontal, 8)
+	buttonBox.SetHAlign(gtk.AlignEnd)
+
+	closeBtn := gtk.NewButton()
+	closeBtn.SetLabel("Close")
+	closeBtn.ConnectClicked(func() { dialog.Destroy() })
+	buttonBox.Append(closeBtn)
+
+	content.Append(buttonBox)
+	dialog.Present()
+}
+
+// createGeneralTab lists the name, type, size, location, modification time
+// and permissions of entry
+func createGeneralTab(entry fileview.FileEntry) *gtk.Grid {
+	grid := gtk.NewGrid()
+	grid.SetRowSpacing(8)
+	grid.SetColumnSpacing(16)
+	grid.SetMarginTop(12)
+	grid.SetMarginBottom(12)
+	grid.SetMarginStart(12)
+	grid.SetMarginEnd(12)
+
+	row := 0
+	addRow := func(label, value string) {
+		name := gtk.NewLabel(label + ":")
+		name.AddCSSClass("dim-label")
+		name.SetHAlign(gtk.AlignEnd)
+		name.SetVAlign(gtk.AlignStart)
+		grid.Attach(name, 0, row, 1, 1)
+
+		text := gtk.NewLabel(value)
+		text.SetHAlign(gtk.AlignStart)
+		text.SetSelectable(true)
+		text.SetWrap(true)
+		grid.Attach(text, 1, row, 1, 1)
+		row++
+	}
+
+	addRow("Name", entry.Name)
+	addRow("Type", fileview.GetFileTypeDescription(entry))
+	if !entry.IsDir {
+		addRow("Size", fmt.Sprintf("%s (%d bytes)", fileview.HumanizeSize(entry.Size), entry.Size))
+	}
+	addRow("Location", filepath.Dir(entry.Path))
+	if entry.IsSymlink {
+		addRow("Link to", entry.LinkTarget)
+	}
+	addRow("Modified", entry.ModTime.Format(propertiesDateFormat))
+	addRow("Permissions", fmt.Sprintf("%s (%04o)", permissions.Format(entry.Mode), uint32(entry.Mode.Perm())))
+	return grid
+}
+
+// createVersionsTab lists the copies of entry in Snapper, ZFS and Timeshift
+// snapshots, each of which can be restored or copied out next to it.
+// Snapshots are looked through off the main loop, since ZFS mounts each
+// one on first access.
+func (fm *FileManager) createVersionsTab(entry fileview.FileEntry, dialog *gtk.Dialog) *gtk.ScrolledWindow {
+	scroll := gtk.NewScrolledWindow()
+	scroll.SetPolicy(gtk.PolicyNever, gtk.PolicyAutomatic)
+
+	box := gtk.NewBox(gtk.OrientationVertical, 8)
+	box.SetMarginTop(12)
+	box.SetMarginBottom(12)
+	box.SetMarginStart(12)
+	box.SetMarginEnd(12)
+	scroll.SetChild(box)
+
+	status := gtk.NewLabel("Looking for snapshots...")
+	status.AddCSSClass("dim-label")
+	status.SetHAlign(gtk.AlignStart)
+	status.SetWrap(true)
+	box.Append(status)
+
+	go func() {
+		versions, err := snapshots.Find(entry.Path)
+		glib.IdleAdd(func() {
+			switch {
+			case err != nil:
+				status.SetText("Could not look for snapshots: " + err.Error())
+				return
+			case len(versions) == 0:
+				status.SetText("No previous versions. Older copies are found in Snapper snapshots on btrfs, ZFS snapshots and Timeshift backups you can read.")
+				return
+			}
+			status.SetText(fileview.Pluralize(len(versions), "older copy", "older copies") +
+				" in snapshots. Restoring moves the current version to the trash.")
+
+			list := gtk.NewListBox()
+			list.SetSelectionMode(gtk.SelectionNone)
+			for _, v := range versions {
+				list.Append(fm.createVersionRow(entry, v, status, dialog))
+			}
+			box.Append(list)
+		})
+	}()
+	return scroll
+}
+
+// createVersionRow shows when a snapshot was taken, how its copy differs
+// from the current file, and its Copy Out and Restore buttons
+func (fm *FileManager) createVersionRow(entry fileview.FileEntry, v snapshots.Version, status *gtk.Label, dialog *gtk.Dialog) *gtk.Box {
+	row := gtk.NewBox(gtk.OrientationHorizontal, 12)
+	row.SetMarginTop(6)
+	row.SetMarginBottom(6)
+
+	info := gtk.NewBox(gtk.OrientationVertical, 2)
+	info.SetHExpand(true)
+
+	title := gtk.NewLabel(fmt.Sprintf("%s  -  %s", v.Taken.Format(propertiesDateFormat), v.Title()))
+	title.SetHAlign(gtk.AlignStart)
+	title.SetEllipsize(3) // PANGO_ELLIPSIZE_END
+	info.Append(title)
+
+	details := "Modified " + v.ModTime.Format(propertiesDateFormat)
+	if !v.IsDir {
+		details += fmt.Sprintf(", %s (%s)", fileview.HumanizeSize(v.Size), v.SizeChange(entry.Size))
+	}
+	detailLabel := gtk.NewLabel(details)
+	detailLabel.AddCSSClass("dim-label")
+	detailLabel.SetHAlign(gtk.AlignStart)
+	info.Append(detailLabel)
+	row.Append(info)
+
+	copyBtn := gtk.NewButton()
+	copyBtn.SetLabel("Copy Out")
+	copyBtn.SetTooltipText("Copy this version next to the current one")
+	copyBtn.SetVAlign(gtk.AlignCenter)
+	row.Append(copyBtn)
+
+	restoreBtn := gtk.NewButton()
+	restoreBtn.SetLabel("Restore")
+	restoreBtn.SetTooltipText("Replace the current version, which is moved to the trash")
+	restoreBtn.SetVAlign(gtk.AlignCenter)
+	row.Append(restoreBtn)
+
+	// Copies of large files or folders take a while. A restore closes the
+	// dialog, since the versions are compared with the file it replaced.
+	run := func(restore bool, failure string, action func() (string, error)) {
+		copyBtn.SetSensitive(false)
+		restoreBtn.SetSensitive(false)
+		go func() {
+			message, err := action()
+			glib.IdleAdd(func() {
+				copyBtn.SetSensitive(true)
+				restoreBtn.SetSensitive(true)
+				if err != nil {
+					fm.showError(failure + ": " + err.Error())
+					return
+				}
+				fm.refresh()
+				if restore {
+					dialog.Destroy()
+					return
+				}
+				status.SetText(message)
+			})
+		}()
+	}
+
+	copyBtn.ConnectClicked(func() {
+		run(false, "Failed to copy out the previous version", func() (string, error) {
+			dst, err := snapshots.CopyOut(v, entry.Path)
+			if err != nil {
+				return "", err
+			}
+			return "Copied to " + filepath.Base(dst), nil
+		})
+	})
+	restoreBtn.ConnectClicked(func() {
+		run(true, "Failed to restore the previous version", func() (string, error) {
+			return "", snapshots.Restore(v, entry.Path)
+		})
+	})
+	return row
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"io"
+	"strings"
+
+	"raven-file-manager/pkg/clipboard"
+
+	"github.com/diamondburned/gotk4/pkg/core/gioutil"
+	"github.com/diamondburned/gotk4/pkg/gdk/v4"
+	"github.com/diamondburned/gotk4/pkg/gio/v2"
+	"github.com/diamondburned/gotk4/pkg/glib/v2"
+)
+
+// Copied and cut files are also put on the desktop clipboard, so they can
+// be pasted into other file managers, attached in browsers or pasted as
+// paths into editors and terminals. Files copied in other apps are pasted
+// from it, as anything copied later replaces what was copied here.
+
+// Largest clipboard content read when pasting
+const maxClipboardRead = 4 << 20
+
+// shareClipboard offers files on the desktop clipboard, as the
+// clipboard.Manager holds them
+func (fm *FileManager) shareClipboard(files []string, op clipboard.Operation) {
+	offer := func(mimeType string, data []byte) *gdk.ContentProvider {
+		return gdk.NewContentProviderForBytes(mimeType, glib.NewBytes(data))
+	}
+	providers := []*gdk.ContentProvider{
+		offer(clipboard.CopiedFilesType, clipboard.EncodeCopiedFiles(files, op)),
+		offer(clipboard.URIListType, clipboard.EncodeURIList(files)),
+		offer("text/plain;charset=utf-8", []byte(strings.Join(files, "\n"))),
+	}
+	if op == clipboard.OpCut {
+		providers = append(providers, offer(clipboard.KDECutType, []byte("1")))
+	}
+	fm.window.Clipboard().SetContent(gdk.NewContentProviderUnion(providers))
+}
+
+// clipboardFiles passes the files on the clipboard to use: those copied or
+// cut here, or else those another app put on the desktop clipboard, which
+// are read in the background. Files offered only as a list count as copied.
+func (fm *FileManager) clipboardFiles(use func(files []string, op clipboard.Operation)) {
+	cb := fm.window.Clipboard()
+	if cb.IsLocal() {
+		if fm.clipboard.HasFiles() {
+			use(fm.clipboard.GetFiles(), fm.clipboard.GetOperation())
+		}
+		return
+	}
+
+	formats := cb.Formats()
+
+	switch {
+	case formats.ContainMIMEType(clipboard.CopiedFilesType):
+		cb.ReadAsync(context.Background(), []string{clipboard.CopiedFilesType}, int(glib.PriorityDefault), func(res gio.AsyncResulter) {
+			_, stream, err := cb.ReadFinish(res)
+			if err != nil {
+				fm.showError("Failed to read the clipboard: " + err.Error())
+				return
+			}
+			// The other app may take a while to write it all
+			go func() {
+				reader := gioutil.Reader(context.Background(), stream)
+				data, err := io.ReadAll(io.LimitReader(reader, maxClipboardRead))
+				reader.Close()
+				glib.IdleAdd(func() {
+					if err != nil {
+						fm.showError("Failed to read the clipboard: " + err.Error())
+						return
+					}
+					if files, op := clipboard.DecodeCopiedFiles(data); len(files) > 0 {
+						use(files, op)
+					}
+				})
+			}()
+		})
+	case formats.ContainGType(gdk.GTypeFileList):
+		cb.ReadValueAsync(context.Background(), gdk.GTypeFileList, int(glib.PriorityDefault), func(res gio.AsyncResulter) {
+			value, err := cb.ReadValueFinish(res)
+			if err != nil {
+				fm.showError("Failed to read the clipboard: " + err.Error())
+				return
+			}
+			if files := droppedFiles(value); len(files) > 0 {
+				use(files, clipboard.OpCopy)
+			}
+		})
+	}
+}
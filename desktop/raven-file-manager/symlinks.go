@@ -0,0 +1,61 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+
+	"raven-file-manager/pkg/fileview"
+	"raven-file-manager/pkg/icons"
+
+	"github.com/diamondburned/gotk4/pkg/gtk/v4"
+)
+
+// Symlinks open as what they point at, keeping the link's path, so a
+// linked folder is browsed under the link. Go to Link Target shows where
+// the link really leads.
+
+// linkIcon returns icon with the link emblem over its corner
+func linkIcon(icon *gtk.Image) gtk.Widgetter {
+	overlay := gtk.NewOverlay()
+	overlay.SetChild(icon)
+
+	emblem := icons.NewImage("emblem-symbolic-link", 10)
+	emblem.AddCSSClass("file-link-emblem")
+	emblem.SetHAlign(gtk.AlignEnd)
+	emblem.SetVAlign(gtk.AlignEnd)
+	overlay.AddOverlay(emblem)
+	return overlay
+}
+
+// linkDescription says where the link entry points
+func linkDescription(entry fileview.FileEntry) string {
+	target := entry.LinkTarget
+	if target == "" {
+		target = "an unknown location"
+	}
+	if entry.LinkBroken {
+		return "Broken link to " + target
+	}
+	return "Link to " + target
+}
+
+// goToLinkTarget opens the folder a link really leads to, or for a link to
+// a file, the folder holding the file with it selected
+func (fm *FileManager) goToLinkTarget(entry fileview.FileEntry) {
+	target, err := filepath.EvalSymlinks(entry.Path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			fm.showError(entry.Name + " links to " + entry.LinkTarget + ", which doesn't exist")
+		} else {
+			fm.showError("Failed to follow the link: " + err.Error())
+		}
+		return
+	}
+
+	if entry.IsDir {
+		fm.navigateTo(target)
+		return
+	}
+	fm.selectOnLoad = target
+	fm.navigateTo(filepath.Dir(target))
+}
@@ -0,0 +1,328 @@
+package main
+
+import (
+	"path/filepath"
+
+	"raven-file-manager/pkg/clipboard"
+	"raven-file-manager/pkg/config"
+	"raven-file-manager/pkg/fileview"
+	"raven-file-manager/pkg/gitstatus"
+	"raven-file-manager/pkg/navigation"
+	"raven-file-manager/pkg/trash"
+
+	"github.com/diamondburned/gotk4/pkg/gtk/v4"
+)
+
+// pane is one directory view with its own folder, history and selection.
+// The FileManager embeds the pane the user is working in, so the rest of
+// the file manager acts on it.
+type pane struct {
+	currentPath   string
+	history       *navigation.History
+	currentFiles  []fileview.FileEntry
+	selectedFiles []fileview.FileEntry
+	trashItems    []trash.Item    // The items of currentFiles while showing the Trash
+	git           *gitstatus.Repo // Work tree currentPath is in, if any
+	selectOnLoad  string          // File selected once the folder has loaded
+
+	root          *gtk.Box
+	trashBar      *gtk.Box
+	emptyTrashBtn *gtk.Button
+	fileScroll    *gtk.ScrolledWindow
+	columnHeader  *gtk.Box // Hidden in the Trash and search results
+	fileListBox   *gtk.ListBox
+	tab           *tab
+}
+
+// tab is a notebook page showing one pane, or two side by side in split
+// view
+type tab struct {
+	paned  *gtk.Paned
+	panes  []*pane
+	active *pane // The pane last worked in
+	label  *gtk.Label
+}
+
+func (fm *FileManager) createNotebook() *gtk.Notebook {
+	fm.notebook = gtk.NewNotebook()
+	fm.notebook.AddCSSClass("file-tabs")
+	fm.notebook.SetScrollable(true)
+	fm.notebook.SetShowTabs(false)
+	fm.notebook.SetHExpand(true)
+	fm.notebook.SetVExpand(true)
+
+	fm.notebook.ConnectSwitchPage(func(page gtk.Widgetter, pageNum uint) {
+		if t := fm.tabAt(int(pageNum)); t != nil {
+			fm.activatePane(t.active)
+		}
+	})
+	return fm.notebook
+}
+
+// newPane creates an empty pane in t. Clicking or focusing it makes it the
+// active pane before the click reaches the file list.
+func (fm *FileManager) newPane(t *tab) *pane {
+	p := &pane{
+		history: navigation.NewHistory(),
+		tab:     t,
+	}
+
+	p.root = gtk.NewBox(gtk.OrientationVertical, 0)
+	p.root.AddCSSClass("file-pane")
+	p.root.SetHExpand(true)
+	p.root.SetVExpand(true)
+
+	p.trashBar = fm.createTrashBar(p)
+	p.root.Append(p.trashBar)
+
+	p.columnHeader = gtk.NewBox(gtk.OrientationHorizontal, 8)
+	p.columnHeader.AddCSSClass("column-header")
+	p.root.Append(p.columnHeader)
+
+	p.fileScroll = gtk.NewScrolledWindow()
+	p.fileScroll.SetPolicy(gtk.PolicyAutomatic, gtk.PolicyAutomatic)
+	p.fileScroll.SetVExpand(true)
+	p.fileListBox = gtk.NewListBox()
+	p.fileListBox.AddCSSClass("file-list")
+	p.fileScroll.SetChild(p.fileListBox)
+	p.root.Append(p.fileScroll)
+
+	click := gtk.NewGestureClick()
+	click.SetButton(0)
+	click.SetPropagationPhase(gtk.PhaseCapture)
+	click.ConnectPressed(func(nPress int, x, y float64) {
+		fm.activatePane(p)
+	})
+	p.root.AddController(click)
+
+	focus := gtk.NewEventControllerFocus()
+	focus.ConnectEnter(func() {
+		fm.activatePane(p)
+	})
+	p.root.AddController(focus)
+
+	return p
+}
+
+// activatePane makes p the pane the header, status bar, preview and
+// keyboard shortcuts work on
+func (fm *FileManager) activatePane(p *pane) {
+	if fm.pane == p {
+		return
+	}
+	if fm.pane != nil {
+		fm.pane.root.RemoveCSSClass("file-pane-active")
+	}
+
+	fm.pane = p
+	p.tab.active = p
+	if len(p.tab.panes) > 1 {
+		p.root.AddCSSClass("file-pane-active")
+	}
+
+	fm.updateLocationBar()
+	fm.onSelectionChanged()
+}
+
+// inPane runs f with p as the active pane, for work such as a directory
+// load that finishes after the user has moved to another pane
+func (fm *FileManager) inPane(p *pane, f func()) {
+	active := fm.pane
+	fm.pane = p
+	f()
+	fm.pane = active
+}
+
+// refreshAll reloads every pane, after an operation that may have changed
+// folders other than the active one
+func (fm *FileManager) refreshAll() {
+	for _, t := range fm.tabs {
+		for _, p := range t.panes {
+			fm.inPane(p, fm.refresh)
+		}
+	}
+}
+
+// openTab opens path in a new tab and switches to it
+func (fm *FileManager) openTab(path string) {
+	t := &tab{
+		paned: gtk.NewPaned(gtk.OrientationHorizontal),
+		label: gtk.NewLabel(""),
+	}
+	p := fm.newPane(t)
+	t.panes = []*pane{p}
+	t.active = p
+	t.paned.SetStartChild(p.root)
+	fm.tabs = append(fm.tabs, t)
+
+	header := gtk.NewBox(gtk.OrientationHorizontal, 4)
+	t.label.SetEllipsize(3)
+	t.label.SetMaxWidthChars(20)
+	header.Append(t.label)
+
+	closeBtn := gtk.NewButtonFromIconName("window-close-symbolic")
+	closeBtn.AddCSSClass("tab-close")
+	closeBtn.SetHasFrame(false)
+	closeBtn.SetTooltipText("Close Tab (Ctrl+W)")
+	closeBtn.ConnectClicked(func() { fm.closeTab(t) })
+	header.Append(closeBtn)
+
+	// Files dropped on the tab go into the folder it shows
+	fm.addDropTarget(header, func(x, y float64) (string, gtk.Widgetter) {
+		return t.active.currentPath, header
+	})
+
+	page := fm.notebook.AppendPage(t.paned, header)
+	fm.notebook.SetTabReorderable(t.paned, true)
+	fm.notebook.SetShowTabs(len(fm.tabs) > 1)
+	fm.notebook.SetCurrentPage(page)
+
+	fm.activatePane(p)
+	fm.navigateTo(path)
+}
+
+// closeTab closes t unless it is the last tab
+func (fm *FileManager) closeTab(t *tab) {
+	if len(fm.tabs) < 2 {
+		return
+	}
+	for i, open := range fm.tabs {
+		if open == t {
+			fm.tabs = append(fm.tabs[:i], fm.tabs[i+1:]...)
+			break
+		}
+	}
+
+	// Removing the current page switches to another one, which becomes
+	// the active pane
+	fm.notebook.RemovePage(fm.notebook.PageNum(t.paned))
+	fm.notebook.SetShowTabs(len(fm.tabs) > 1)
+}
+
+// switchTab moves by offset tabs, wrapping around at either end
+func (fm *FileManager) switchTab(offset int) {
+	n := fm.notebook.NPages()
+	if n < 2 {
+		return
+	}
+	fm.notebook.SetCurrentPage(((fm.notebook.CurrentPage()+offset)%n + n) % n)
+}
+
+// tabAt returns the tab shown as page n of the notebook, which changes as
+// tabs are dragged around
+func (fm *FileManager) tabAt(n int) *tab {
+	for _, t := range fm.tabs {
+		if fm.notebook.PageNum(t.paned) == n {
+			return t
+		}
+	}
+	return nil
+}
+
+// updateTabLabel names t after the folder of its active pane
+func (fm *FileManager) updateTabLabel(t *tab) {
+	if t == nil || t.active == nil {
+		return
+	}
+	name := filepath.Base(t.active.currentPath)
+	switch t.active.currentPath {
+	case "/":
+		name = "/"
+	case trashFilesDir():
+		name = "Trash"
+	}
+	t.label.SetText(name)
+	t.label.SetTooltipText(t.active.currentPath)
+}
+
+// toggleSplit shows a second pane next to the active one, opened in the
+// same folder, or closes the other pane when there are two
+func (fm *FileManager) toggleSplit() {
+	t := fm.pane.tab
+	if other := fm.otherPane(); other != nil {
+		keep := fm.pane
+		t.paned.SetStartChild(nil)
+		t.paned.SetEndChild(nil)
+		t.paned.SetStartChild(keep.root)
+		t.panes = []*pane{keep}
+		keep.root.RemoveCSSClass("file-pane-active")
+		return
+	}
+
+	p := fm.newPane(t)
+	t.panes = append(t.panes, p)
+	t.paned.SetEndChild(p.root)
+	t.paned.SetPosition(t.paned.Width() / 2)
+
+	path := fm.currentPath
+	fm.activatePane(p)
+	fm.navigateTo(path)
+}
+
+// otherPane returns the pane next to the active one in split view, or nil
+func (fm *FileManager) otherPane() *pane {
+	for _, p := range fm.pane.tab.panes {
+		if p != fm.pane {
+			return p
+		}
+	}
+	return nil
+}
+
+// transferToOtherPane copies or moves the selection into the folder of the
+// other pane
+func (fm *FileManager) transferToOtherPane(op clipboard.Operation) {
+	other := fm.otherPane()
+	if other == nil {
+		return
+	}
+
+	fm.mu.RLock()
+	files := make([]string, len(fm.selectedFiles))
+	for i, f := range fm.selectedFiles {
+		files[i] = f.Path
+	}
+	fm.mu.RUnlock()
+
+	if len(files) > 0 {
+		fm.queueTransfer(files, op, other.currentPath, false)
+	}
+}
+
+// restoreTabs opens the directory given on the command line, or else the
+// tabs open when the window was last closed
+func (fm *FileManager) restoreTabs() {
+	if fileview.IsDirectory(fm.startPath) {
+		fm.openTab(fm.startPath)
+		return
+	}
+
+	active := 0
+	for i, path := range fm.settings.Tabs {
+		if !fileview.IsDirectory(path) {
+			continue
+		}
+		fm.openTab(path)
+		if i == fm.settings.ActiveTab {
+			active = len(fm.tabs) - 1
+		}
+	}
+	if len(fm.tabs) == 0 {
+		fm.openTab(homeDir())
+		return
+	}
+	fm.notebook.SetCurrentPage(fm.notebook.PageNum(fm.tabs[active].paned))
+}
+
+// saveTabs records the open tabs, in notebook order, for the next start
+func (fm *FileManager) saveTabs() {
+	paths := make([]string, 0, len(fm.tabs))
+	for i := 0; i < fm.notebook.NPages(); i++ {
+		if t := fm.tabAt(i); t != nil {
+			paths = append(paths, t.active.currentPath)
+		}
+	}
+	fm.settings.Tabs = paths
+	fm.settings.ActiveTab = fm.notebook.CurrentPage()
+	config.SaveSettings(fm.settings)
+}
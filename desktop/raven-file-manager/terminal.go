@@ -0,0 +1,228 @@
+package main
+
+/*
+#cgo pkg-config: gtk4
+#cgo LDFLAGS: -ldl
+#include <dlfcn.h>
+#include <stdlib.h>
+#include <unistd.h>
+#include <gtk/gtk.h>
+
+// VTE is opened at run time, so the file manager works without it and only
+// the terminal panel needs it
+typedef GtkWidget *(*vte_terminal_new_fn)(void);
+typedef void (*vte_spawn_callback)(GtkWidget *, GPid, GError *, gpointer);
+typedef void (*vte_terminal_spawn_async_fn)(GtkWidget *, int, const char *, char **, char **,
+    GSpawnFlags, GSpawnChildSetupFunc, gpointer, GDestroyNotify, int, GCancellable *,
+    vte_spawn_callback, gpointer);
+typedef void (*vte_terminal_feed_child_fn)(GtkWidget *, const char *, gssize);
+typedef gpointer (*vte_terminal_get_pty_fn)(GtkWidget *);
+typedef int (*vte_pty_get_fd_fn)(gpointer);
+
+static vte_terminal_new_fn vte_terminal_new;
+static vte_terminal_spawn_async_fn vte_terminal_spawn_async;
+static vte_terminal_feed_child_fn vte_terminal_feed_child;
+static vte_terminal_get_pty_fn vte_terminal_get_pty;
+static vte_pty_get_fd_fn vte_pty_get_fd;
+
+// load_vte returns 1 once VTE's GTK 4 library is loaded
+static int load_vte(void) {
+    if (vte_terminal_new) {
+        return 1;
+    }
+    void *lib = dlopen("libvte-2.91-gtk4.so.0", RTLD_NOW | RTLD_GLOBAL);
+    if (!lib) {
+        return 0;
+    }
+    vte_terminal_spawn_async = (vte_terminal_spawn_async_fn)dlsym(lib, "vte_terminal_spawn_async");
+    vte_terminal_feed_child = (vte_terminal_feed_child_fn)dlsym(lib, "vte_terminal_feed_child");
+    vte_terminal_get_pty = (vte_terminal_get_pty_fn)dlsym(lib, "vte_terminal_get_pty");
+    vte_pty_get_fd = (vte_pty_get_fd_fn)dlsym(lib, "vte_pty_get_fd");
+    if (!vte_terminal_spawn_async || !vte_terminal_feed_child || !vte_terminal_get_pty || !vte_pty_get_fd) {
+        return 0;
+    }
+    vte_terminal_new = (vte_terminal_new_fn)dlsym(lib, "vte_terminal_new");
+    return vte_terminal_new != NULL;
+}
+
+static void shell_spawned(GtkWidget *term, GPid pid, GError *error, gpointer data) {
+    if (!error) {
+        g_object_set_data(G_OBJECT(term), "raven-shell-pid", GINT_TO_POINTER(pid));
+    }
+}
+
+// terminal_new returns a terminal running shell in dir
+static GtkWidget *terminal_new(char *shell, const char *dir) {
+    GtkWidget *term = vte_terminal_new();
+    char *argv[] = {shell, NULL};
+    vte_terminal_spawn_async(term, 0, dir, argv, NULL, G_SPAWN_SEARCH_PATH, NULL, NULL, NULL,
+        -1, NULL, shell_spawned, NULL);
+    return term;
+}
+
+// terminal_shell_idle reports whether the shell itself, rather than a
+// program it started, is reading the terminal's input
+static int terminal_shell_idle(GtkWidget *term) {
+    GPid pid = GPOINTER_TO_INT(g_object_get_data(G_OBJECT(term), "raven-shell-pid"));
+    gpointer pty = vte_terminal_get_pty(term);
+    return pid > 0 && pty && tcgetpgrp(vte_pty_get_fd(pty)) == pid;
+}
+
+static void terminal_feed(GtkWidget *term, const char *text) {
+    vte_terminal_feed_child(term, text, -1);
+}
+*/
+import "C"
+
+import (
+	"os"
+	"os/exec"
+	"unsafe"
+
+	"raven-file-manager/pkg/config"
+	"raven-file-manager/pkg/fileview"
+
+	coreglib "github.com/diamondburned/gotk4/pkg/core/glib"
+	"github.com/diamondburned/gotk4/pkg/gdk/v4"
+	"github.com/diamondburned/gotk4/pkg/gtk/v4"
+)
+
+// Height of the terminal panel when first shown
+const terminalPanelHeight = 220
+
+// openTerminal opens raven-terminal in dir
+func (fm *FileManager) openTerminal(dir string) {
+	if fileview.IsRemote(dir) {
+		fm.showError("Terminals can only be opened in local folders")
+		return
+	}
+	cmd := exec.Command("raven-terminal", "--working-directory", dir)
+	cmd.Dir = dir
+	if err := cmd.Start(); err != nil {
+		fm.showError("Could not start raven-terminal: " + err.Error())
+		return
+	}
+	go cmd.Wait()
+}
+
+// createTerminalPanel returns the panel below the files holding the
+// embedded terminal, which is started when the panel is first shown
+func (fm *FileManager) createTerminalPanel() *gtk.Box {
+	fm.terminalPanel = gtk.NewBox(gtk.OrientationVertical, 0)
+	fm.terminalPanel.AddCSSClass("terminal-panel")
+	fm.terminalPanel.SetSizeRequest(-1, 80)
+	fm.terminalPanel.SetVisible(false)
+
+	// The terminal takes F4 for itself, so it is caught on the way in to
+	// close the panel from inside it too
+	keys := gtk.NewEventControllerKey()
+	keys.SetPropagationPhase(gtk.PhaseCapture)
+	keys.ConnectKeyPressed(func(keyval, keycode uint, state gdk.ModifierType) bool {
+		if keyval != gdk.KEY_F4 || state&gdk.ShiftMask != 0 {
+			return false
+		}
+		fm.toggleTerminal()
+		return true
+	})
+	fm.terminalPanel.AddController(keys)
+	return fm.terminalPanel
+}
+
+// toggleTerminal shows the terminal panel and moves the focus into it, or
+// hides it and returns the focus to the files
+func (fm *FileManager) toggleTerminal() {
+	fm.setTerminalVisible(!fm.terminalPanel.Visible())
+	if fm.terminalPanel.Visible() {
+		fm.terminal.GrabFocus()
+	} else {
+		fm.fileScroll.GrabFocus()
+	}
+}
+
+// setTerminalVisible shows or hides the terminal panel. Its shell keeps
+// running while hidden.
+func (fm *FileManager) setTerminalVisible(show bool) {
+	if show && fm.terminal == nil && !fm.startTerminal() {
+		show = false
+	}
+	fm.terminalPanel.SetVisible(show)
+	if show {
+		// Before the window is shown the paned has no height yet
+		height := fm.terminalPaned.Height()
+		if height == 0 {
+			height = fm.settings.WindowHeight
+		}
+		if pos := fm.terminalPaned.Position(); pos == 0 || pos > height-80 {
+			fm.terminalPaned.SetPosition(height - terminalPanelHeight)
+		}
+		fm.followTerminal()
+	}
+
+	if fm.settings.ShowTerminal != show {
+		fm.settings.ShowTerminal = show
+		config.SaveSettings(fm.settings)
+	}
+}
+
+// startTerminal creates the embedded terminal in the current folder
+func (fm *FileManager) startTerminal() bool {
+	if C.load_vte() == 0 {
+		fm.showError("The terminal panel needs VTE for GTK 4 (libvte-2.91-gtk4)")
+		return false
+	}
+
+	dir := fm.currentPath
+	if fileview.IsRemote(dir) || fm.inTrash() {
+		dir = os.Getenv("HOME")
+	}
+	shell := os.Getenv("SHELL")
+	if shell == "" {
+		shell = "/bin/sh"
+	}
+
+	cShell := C.CString(shell)
+	defer C.free(unsafe.Pointer(cShell))
+	cDir := C.CString(dir)
+	defer C.free(unsafe.Pointer(cDir))
+
+	ptr := C.terminal_new(cShell, cDir)
+	obj := coreglib.Take(unsafe.Pointer(ptr))
+	fm.terminal = obj.CastType(gtk.GTypeWidget).(*gtk.Widget)
+	fm.terminal.SetVExpand(true)
+	fm.terminal.SetHExpand(true)
+	fm.terminalDir = dir
+
+	// Exiting the shell closes the panel; F4 starts a new one
+	obj.Connect("child-exited", func() {
+		fm.terminalPanel.Remove(fm.terminal)
+		fm.terminal = nil
+		fm.setTerminalVisible(false)
+	})
+
+	fm.terminalPanel.Append(fm.terminal)
+	return true
+}
+
+// followTerminal changes the embedded terminal's folder to the current one
+// while its shell is waiting for a command, so nothing running in it gets
+// the input
+func (fm *FileManager) followTerminal() {
+	if fm.terminal == nil || !fm.terminalPanel.Visible() {
+		return
+	}
+	dir := fm.currentPath
+	if dir == fm.terminalDir || fileview.IsRemote(dir) || fm.inTrash() || fm.searchActive {
+		return
+	}
+
+	ptr := (*C.GtkWidget)(unsafe.Pointer(coreglib.InternObject(fm.terminal).Native()))
+	if C.terminal_shell_idle(ptr) == 0 {
+		return
+	}
+	// Ctrl+U clears anything typed; the leading space keeps the command out
+	// of the history
+	cmd := C.CString("\x15 cd -- " + shellQuote(dir) + "\r")
+	defer C.free(unsafe.Pointer(cmd))
+	C.terminal_feed(ptr, cmd)
+	fm.terminalDir = dir
+}
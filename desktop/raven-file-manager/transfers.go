@@ -0,0 +1,472 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"time"
+
+	"raven-file-manager/pkg/admin"
+	"raven-file-manager/pkg/clipboard"
+	"raven-file-manager/pkg/fileview"
+
+	"github.com/diamondburned/gotk4/pkg/glib/v2"
+	"github.com/diamondburned/gotk4/pkg/gtk/v4"
+	"github.com/diamondburned/gotk4/pkg/pango"
+)
+
+// The progress window opens once a transfer has run this long, so quick
+// pastes don't flash it
+const transferWindowDelay = time.Second
+
+// How often the progress window is updated, in milliseconds
+const transferUpdateInterval = 500
+
+// transferJob is a paste in the transfer queue
+type transferJob struct {
+	files   []string
+	op      clipboard.Operation
+	target  string
+	notify  bool // Always announce the result, as for retries
+	control *clipboard.Control
+
+	// Written by the transfer goroutine
+	total atomic.Int64
+	done  atomic.Int64
+
+	// Main loop only
+	running  bool
+	started  time.Time
+	lastDone int64
+	lastTick time.Time
+	speed    float64                   // Bytes per second, smoothed
+	applyAll *clipboard.ConflictAction // Answer to the remaining conflicts
+	conflict *gtk.Dialog               // Conflict dialog waiting for an answer
+
+	row      *gtk.Box
+	status   *gtk.Label
+	bar      *gtk.ProgressBar
+	pauseBtn *gtk.Button
+	stopBtn  *gtk.Button
+}
+
+// transferQueue runs pastes one at a time and shows them in the progress
+// window
+type transferQueue struct {
+	jobs      []*transferJob // The running job first
+	window    *gtk.Window
+	list      *gtk.Box
+	timer     glib.SourceHandle
+	dismissed bool // Window closed by the user; shown again for the next paste
+}
+
+// queueTransfer copies or moves files into target after the transfers
+// already queued. With notify the result is always announced.
+func (fm *FileManager) queueTransfer(files []string, op clipboard.Operation, target string, notify bool) {
+	q := &fm.transfers
+	q.dismissed = false
+	job := &transferJob{
+		files:   files,
+		op:      op,
+		target:  target,
+		notify:  notify,
+		control: clipboard.NewControl(),
+	}
+	q.jobs = append(q.jobs, job)
+	fm.createTransferRow(job)
+
+	if len(q.jobs) == 1 {
+		fm.runTransfer(job)
+	}
+	if q.timer == 0 {
+		q.timer = glib.TimeoutAdd(transferUpdateInterval, func() bool {
+			fm.updateTransfers()
+			return true
+		})
+	}
+	fm.updateTransfers()
+}
+
+// runTransfer starts job in the background
+func (fm *FileManager) runTransfer(job *transferJob) {
+	job.running = true
+	job.started = time.Now()
+	job.lastTick = job.started
+
+	opts := clipboard.CopyOptions{
+		Verify:   fm.settings.VerifyCopies,
+		Progress: func(n int64) { job.done.Add(n) },
+		Control:  job.control,
+		Conflict: func(src, dst string) clipboard.ConflictAction {
+			return fm.askConflict(job, src, dst)
+		},
+	}
+	go func() {
+		total, _ := clipboard.Measure(job.files)
+		job.total.Store(total)
+		report, err := clipboard.Transfer(job.files, job.op, job.target, opts)
+		glib.IdleAdd(func() {
+			fm.finishTransfer(job, report, err)
+		})
+	}()
+}
+
+// finishTransfer reports the end of job and starts the next one. Long
+// transfers are announced, so the user learns of them from any window; a
+// canceled one is not.
+func (fm *FileManager) finishTransfer(job *transferJob, report *clipboard.CopyReport, err error) {
+	fm.removeTransfer(job)
+
+	// The helper takes the target, then the sources
+	args := append([]string{job.target}, report.Failed...)
+
+	switch {
+	case errors.Is(err, clipboard.ErrCanceled):
+	case len(report.Failed) > 0 && canRetryAsAdmin(err, args...):
+		op := admin.OpCopy
+		if job.op == clipboard.OpCut {
+			op = admin.OpMove
+		}
+		fm.showAdminRetry(fmt.Sprintf("Not allowed to paste %s into %s.",
+			fileview.Pluralize(len(report.Failed), "item", "items"), filepath.Base(job.target)),
+			"Paste as Administrator", func() error {
+				return admin.Run(op, args...)
+			})
+	case job.notify || fm.shouldNotify(time.Since(job.started)):
+		fm.notifyTransfer(job.files, job.op, job.target, report, err)
+	case err != nil:
+		fm.showError("Paste failed: " + err.Error())
+	}
+	if report.Files > 0 {
+		fm.previewPanel.ShowDetails("Paste complete", "edit-paste-symbolic", report.Details())
+	}
+	fm.refreshAll()
+}
+
+// removeTransfer takes job out of the queue and its row out of the window.
+// The window closes once the queue is empty.
+func (fm *FileManager) removeTransfer(job *transferJob) {
+	q := &fm.transfers
+	for i, queued := range q.jobs {
+		if queued == job {
+			q.jobs = append(q.jobs[:i], q.jobs[i+1:]...)
+			break
+		}
+	}
+	if q.list != nil {
+		q.list.Remove(job.row)
+	}
+
+	if len(q.jobs) > 0 {
+		if !q.jobs[0].running {
+			fm.runTransfer(q.jobs[0])
+		}
+		return
+	}
+	if q.timer != 0 {
+		glib.SourceRemove(q.timer)
+		q.timer = 0
+	}
+	if q.window != nil {
+		q.window.SetVisible(false)
+	}
+	q.dismissed = false
+}
+
+// cancelTransfer stops job, or drops it from the queue if it hasn't
+// started. A conflict it is asking about is skipped.
+func (fm *FileManager) cancelTransfer(job *transferJob) {
+	job.control.Cancel()
+	if !job.running {
+		fm.removeTransfer(job)
+		return
+	}
+	job.stopBtn.SetSensitive(false)
+	job.pauseBtn.SetSensitive(false)
+	job.status.SetText("Canceling...")
+	if job.conflict != nil {
+		job.conflict.Close()
+	}
+}
+
+// createTransferRow adds job's row to the progress window
+func (fm *FileManager) createTransferRow(job *transferJob) {
+	q := &fm.transfers
+	if q.window == nil {
+		fm.createTransferWindow()
+	}
+
+	job.row = gtk.NewBox(gtk.OrientationVertical, 6)
+
+	verb := "Copying"
+	if job.op == clipboard.OpCut {
+		verb = "Moving"
+	}
+	what := fileview.Pluralize(len(job.files), "item", "items")
+	if len(job.files) == 1 {
+		what = "“" + filepath.Base(job.files[0]) + "”"
+	}
+	title := gtk.NewLabel(fmt.Sprintf("%s %s to %s", verb, what, filepath.Base(job.target)))
+	title.SetHAlign(gtk.AlignStart)
+	title.SetEllipsize(pango.EllipsizeEnd)
+	job.row.Append(title)
+
+	controls := gtk.NewBox(gtk.OrientationHorizontal, 8)
+	job.bar = gtk.NewProgressBar()
+	job.bar.SetHExpand(true)
+	job.bar.SetVAlign(gtk.AlignCenter)
+	controls.Append(job.bar)
+
+	job.pauseBtn = gtk.NewButtonFromIconName("media-playback-pause-symbolic")
+	job.pauseBtn.SetTooltipText("Pause")
+	job.pauseBtn.ConnectClicked(func() {
+		if job.control.Paused() {
+			job.control.Resume()
+		} else {
+			job.control.Pause()
+		}
+		fm.updateTransfers()
+	})
+	controls.Append(job.pauseBtn)
+
+	job.stopBtn = gtk.NewButtonFromIconName("process-stop-symbolic")
+	job.stopBtn.SetTooltipText("Cancel")
+	job.stopBtn.ConnectClicked(func() {
+		fm.cancelTransfer(job)
+	})
+	controls.Append(job.stopBtn)
+	job.row.Append(controls)
+
+	job.status = gtk.NewLabel("Waiting")
+	job.status.AddCSSClass("dim-label")
+	job.status.SetHAlign(gtk.AlignStart)
+	job.row.Append(job.status)
+
+	q.list.Append(job.row)
+}
+
+// createTransferWindow creates the progress window. It is shown by
+// updateTransfers.
+func (fm *FileManager) createTransferWindow() {
+	q := &fm.transfers
+	q.window = gtk.NewWindow()
+	q.window.SetTitle("File Operations")
+	q.window.SetTransientFor(fm.window)
+	q.window.SetDefaultSize(460, -1)
+	q.window.SetHideOnClose(true)
+	q.window.ConnectCloseRequest(func() bool {
+		q.dismissed = true
+		return false
+	})
+
+	q.list = gtk.NewBox(gtk.OrientationVertical, 16)
+	q.list.SetMarginTop(16)
+	q.list.SetMarginBottom(16)
+	q.list.SetMarginStart(16)
+	q.list.SetMarginEnd(16)
+	q.window.SetChild(q.list)
+}
+
+// updateTransfers refreshes the rows of the progress window, and shows it
+// once a transfer takes a while or others wait behind it
+func (fm *FileManager) updateTransfers() {
+	q := &fm.transfers
+	for _, job := range q.jobs {
+		updateTransferRow(job)
+	}
+
+	if len(q.jobs) == 0 || q.dismissed || q.window.IsVisible() {
+		return
+	}
+	if len(q.jobs) > 1 || time.Since(q.jobs[0].started) >= transferWindowDelay {
+		q.window.Present()
+	}
+}
+
+// updateTransferRow shows the progress, speed and time left of job
+func updateTransferRow(job *transferJob) {
+	paused := job.control.Paused()
+	if paused {
+		job.pauseBtn.SetIconName("media-playback-start-symbolic")
+		job.pauseBtn.SetTooltipText("Resume")
+	} else {
+		job.pauseBtn.SetIconName("media-playback-pause-symbolic")
+		job.pauseBtn.SetTooltipText("Pause")
+	}
+	if !job.running || job.control.Canceled() {
+		return
+	}
+
+	done, total := job.done.Load(), job.total.Load()
+	now := time.Now()
+	if elapsed := now.Sub(job.lastTick).Seconds(); !paused && elapsed > 0 {
+		current := float64(done-job.lastDone) / elapsed
+		if job.speed == 0 {
+			job.speed = current
+		} else {
+			job.speed = 0.7*job.speed + 0.3*current
+		}
+	}
+	job.lastDone, job.lastTick = done, now
+
+	if total == 0 {
+		job.bar.Pulse()
+		job.status.SetText("Preparing...")
+		return
+	}
+	job.bar.SetFraction(min(float64(done)/float64(total), 1))
+
+	status := fmt.Sprintf("%s of %s", fileview.HumanizeSize(done), fileview.HumanizeSize(total))
+	switch {
+	case job.conflict != nil:
+		status += " · Waiting for an answer"
+	case paused:
+		status = "Paused · " + status
+	case job.speed > 0:
+		left := time.Duration(float64(total-done)/job.speed) * time.Second
+		status += fmt.Sprintf(" · %s/s · %s left", fileview.HumanizeSize(int64(job.speed)), formatTimeLeft(left))
+	}
+	job.status.SetText(status)
+}
+
+// formatTimeLeft rounds d for the progress window, e.g. "40 s" or "3 min"
+func formatTimeLeft(d time.Duration) string {
+	switch {
+	case d < time.Minute:
+		return fmt.Sprintf("%d s", int(d.Seconds()))
+	case d < time.Hour:
+		return fmt.Sprintf("%d min", int(d.Minutes()+0.5))
+	default:
+		return fmt.Sprintf("%d h %d min", int(d.Hours()), int(d.Minutes())%60)
+	}
+}
+
+// askConflict asks what to do with src, whose name dst already has. It is
+// called on the transfer goroutine and waits for the answer, unless the
+// user already chose one for every conflict of the job.
+func (fm *FileManager) askConflict(job *transferJob, src, dst string) clipboard.ConflictAction {
+	answer := make(chan clipboard.ConflictAction, 1)
+	glib.IdleAdd(func() {
+		if job.applyAll != nil {
+			answer <- *job.applyAll
+			return
+		}
+		fm.showConflictDialog(job, src, dst, answer)
+	})
+	return <-answer
+}
+
+// showConflictDialog offers to replace, skip or keep both of src and dst.
+// Closing the dialog skips src.
+func (fm *FileManager) showConflictDialog(job *transferJob, src, dst string, answer chan<- clipboard.ConflictAction) {
+	srcInfo, _ := os.Stat(src)
+	dstInfo, _ := os.Stat(dst)
+	merge := srcInfo != nil && dstInfo != nil && srcInfo.IsDir() && dstInfo.IsDir()
+
+	dialog := gtk.NewDialog()
+	dialog.SetTitle("Name Conflict")
+	dialog.SetTransientFor(fm.window)
+	dialog.SetModal(true)
+	dialog.SetDefaultSize(440, -1)
+	job.conflict = dialog
+
+	content := dialog.ContentArea()
+	content.SetMarginTop(16)
+	content.SetMarginBottom(16)
+	content.SetMarginStart(16)
+	content.SetMarginEnd(16)
+	content.SetSpacing(12)
+
+	icon := gtk.NewImageFromIconName("dialog-warning-symbolic")
+	icon.SetPixelSize(48)
+	content.Append(icon)
+
+	question := fmt.Sprintf("“%s” already exists in %s.", filepath.Base(dst), filepath.Base(filepath.Dir(dst)))
+	if merge {
+		question += " Merging replaces the files in it that have the same names."
+	}
+	label := gtk.NewLabel(question)
+	label.SetWrap(true)
+	content.Append(label)
+
+	grid := gtk.NewGrid()
+	grid.SetRowSpacing(8)
+	grid.SetColumnSpacing(16)
+	for row, item := range []struct {
+		name string
+		info os.FileInfo
+	}{{"Existing", dstInfo}, {"New", srcInfo}} {
+		name := gtk.NewLabel(item.name + ":")
+		name.AddCSSClass("dim-label")
+		name.SetHAlign(gtk.AlignEnd)
+		grid.Attach(name, 0, row, 1, 1)
+
+		text := gtk.NewLabel(describeConflictItem(item.info))
+		text.SetHAlign(gtk.AlignStart)
+		grid.Attach(text, 1, row, 1, 1)
+	}
+	content.Append(grid)
+
+	applyAll := gtk.NewCheckButtonWithLabel("Apply to all conflicts")
+	content.Append(applyAll)
+
+	answered := false
+	reply := func(action clipboard.ConflictAction) {
+		if answered {
+			return
+		}
+		answered = true
+		if applyAll.Active() {
+			job.applyAll = &action
+		}
+		job.conflict = nil
+		answer <- action
+		dialog.Destroy()
+	}
+	dialog.ConnectCloseRequest(func() bool {
+		reply(clipboard.ConflictSkip)
+		return false
+	})
+
+	buttonBox := gtk.NewBox(gtk.OrientationHorizontal, 8)
+	buttonBox.SetHAlign(gtk.AlignEnd)
+	buttonBox.SetMarginTop(16)
+
+	skipBtn := gtk.NewButton()
+	skipBtn.SetLabel("Skip")
+	skipBtn.AddCSSClass("cancel")
+	skipBtn.ConnectClicked(func() { reply(clipboard.ConflictSkip) })
+	buttonBox.Append(skipBtn)
+
+	renameBtn := gtk.NewButton()
+	renameBtn.SetLabel("Keep Both")
+	renameBtn.ConnectClicked(func() { reply(clipboard.ConflictRename) })
+	buttonBox.Append(renameBtn)
+
+	replaceBtn := gtk.NewButton()
+	replaceBtn.SetLabel("Replace")
+	if merge {
+		replaceBtn.SetLabel("Merge")
+	}
+	replaceBtn.AddCSSClass("destructive")
+	replaceBtn.ConnectClicked(func() { reply(clipboard.ConflictOverwrite) })
+	buttonBox.Append(replaceBtn)
+
+	content.Append(buttonBox)
+	dialog.Present()
+}
+
+// describeConflictItem returns the size and modification time shown for
+// each side of a conflict
+func describeConflictItem(info os.FileInfo) string {
+	if info == nil {
+		return "Unreadable"
+	}
+	size := fileview.HumanizeSize(info.Size())
+	if info.IsDir() {
+		size = "Folder"
+	}
+	return fmt.Sprintf("%s, modified %s", size, info.ModTime().Format(propertiesDateFormat))
+}
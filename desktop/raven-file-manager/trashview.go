@@ -0,0 +1,396 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"raven-file-manager/pkg/clipboard"
+	"raven-file-manager/pkg/fileview"
+	"raven-file-manager/pkg/icons"
+	"raven-file-manager/pkg/trash"
+
+	"github.com/diamondburned/gotk4/pkg/gio/v2"
+	"github.com/diamondburned/gotk4/pkg/glib/v2"
+	"github.com/diamondburned/gotk4/pkg/gtk/v4"
+)
+
+// The Trash is the folder trashed files are kept in, Trash/files, shown
+// with the names and folders they were trashed from instead of the names
+// they have in there.
+
+// How often, in seconds, the sidebar checks whether the trash is empty
+const trashPollSeconds = 2
+
+// trashFilesDir returns the folder shown as the Trash
+func trashFilesDir() string {
+	return filepath.Join(trash.Dir(), "files")
+}
+
+// inTrash reports whether the active pane shows the Trash
+func (fm *FileManager) inTrash() bool {
+	return fm.currentPath == trashFilesDir()
+}
+
+// createTrashPlace adds the Trash to the end of the Places list, its icon
+// showing whether anything is in it
+func (fm *FileManager) createTrashPlace() {
+	row := gtk.NewListBoxRow()
+
+	box := gtk.NewBox(gtk.OrientationHorizontal, 8)
+	box.SetMarginStart(8)
+	box.SetMarginEnd(8)
+	box.SetMarginTop(4)
+	box.SetMarginBottom(4)
+
+	fm.trashIcon = gtk.NewImageFromIconName("user-trash")
+	fm.trashIcon.AddCSSClass("sidebar-item-icon")
+	box.Append(fm.trashIcon)
+
+	label := gtk.NewLabel("Trash")
+	label.AddCSSClass("sidebar-item")
+	label.SetHAlign(gtk.AlignStart)
+	box.Append(label)
+
+	row.SetChild(box)
+	fm.sidebarList.Append(row)
+	fm.trashRow = row
+
+	fm.updateTrashIcon()
+	glib.TimeoutSecondsAdd(trashPollSeconds, func() bool {
+		fm.updateTrashIcon()
+		return true
+	})
+}
+
+func (fm *FileManager) updateTrashIcon() {
+	if fm.trashIcon == nil {
+		return
+	}
+	if trash.IsEmpty() {
+		fm.trashIcon.SetFromIconName("user-trash")
+	} else {
+		fm.trashIcon.SetFromIconName("user-trash-full")
+	}
+}
+
+// createTrashBar creates the bar shown above p's files while p shows the
+// Trash
+func (fm *FileManager) createTrashBar(p *pane) *gtk.Box {
+	bar := gtk.NewBox(gtk.OrientationHorizontal, 8)
+	bar.AddCSSClass("trash-bar")
+	bar.SetVisible(false)
+
+	label := gtk.NewLabel("Restore items to put them back where they were trashed from.")
+	label.AddCSSClass("trash-bar-text")
+	label.SetHAlign(gtk.AlignStart)
+	label.SetHExpand(true)
+	label.SetWrap(true)
+	bar.Append(label)
+
+	p.emptyTrashBtn = gtk.NewButton()
+	p.emptyTrashBtn.SetLabel("Empty Trash")
+	p.emptyTrashBtn.AddCSSClass("destructive")
+	p.emptyTrashBtn.ConnectClicked(fm.confirmEmptyTrash)
+	bar.Append(p.emptyTrashBtn)
+
+	return bar
+}
+
+// loadTrash lists the items in the trash, newest first
+func (fm *FileManager) loadTrash() {
+	p := fm.pane
+	go func() {
+		items, err := trash.List()
+		if err != nil {
+			glib.IdleAdd(func() {
+				fm.showError("Failed to read the trash: " + err.Error())
+			})
+			return
+		}
+		// Entries for the items' current names, with file types and modes
+		listed, _ := fileview.ReadDirectory(trashFilesDir())
+		byPath := make(map[string]fileview.FileEntry, len(listed))
+		for _, entry := range listed {
+			byPath[entry.Path] = entry
+		}
+
+		var shown []trash.Item
+		var entries []fileview.FileEntry
+		for i := len(items) - 1; i >= 0; i-- {
+			entry, ok := byPath[items[i].Path]
+			if !ok {
+				continue
+			}
+			shown = append(shown, items[i])
+			entries = append(entries, trashEntry(entry, items[i]))
+		}
+
+		glib.IdleAdd(func() {
+			fm.inPane(p, func() {
+				fm.showTrash(shown, entries)
+			})
+			if p == fm.pane {
+				fm.updateStatusBar()
+			}
+			fm.updateTrashIcon()
+		})
+	}()
+}
+
+// trashEntry shows item under the name it was trashed with, dated when it
+// was trashed
+func trashEntry(entry fileview.FileEntry, item trash.Item) fileview.FileEntry {
+	if item.Original != "" {
+		entry.Name = filepath.Base(item.Original)
+	}
+	entry.ModTime = item.DeletedAt
+	entry.Size = item.Size
+	return entry
+}
+
+// showTrash lists items, whose entries are entries, in the active pane
+func (fm *FileManager) showTrash(items []trash.Item, entries []fileview.FileEntry) {
+	rows := make([]*gtk.ListBoxRow, len(entries))
+	for i, entry := range entries {
+		rows[i] = fm.createTrashRow(entry, items[i])
+	}
+
+	fm.mu.Lock()
+	fm.trashItems = items
+	fm.mu.Unlock()
+
+	fm.setFileList(entries, rows, func(idx int) {
+		fm.openFile(fm.currentFiles[idx])
+	})
+	fm.emptyTrashBtn.SetSensitive(len(items) > 0)
+}
+
+// createTrashRow shows a trashed item with the folder it came from and when
+// it was trashed
+func (fm *FileManager) createTrashRow(entry fileview.FileEntry, item trash.Item) *gtk.ListBoxRow {
+	row := gtk.NewListBoxRow()
+	row.AddCSSClass("file-row")
+	row.SetActivatable(true)
+
+	box := gtk.NewBox(gtk.OrientationHorizontal, 8)
+	box.SetMarginStart(8)
+	box.SetMarginEnd(8)
+	box.SetMarginTop(6)
+	box.SetMarginBottom(6)
+
+	icon := icons.NewImage(fileview.GetFileIcon(entry), 20)
+	if entry.IsDir {
+		icon.AddCSSClass("file-icon-folder")
+	}
+	box.Append(icon)
+
+	nameLabel := gtk.NewLabel(entry.Name)
+	nameLabel.AddCSSClass("file-name")
+	if entry.IsDir {
+		nameLabel.AddCSSClass("file-name-folder")
+	}
+	nameLabel.SetHAlign(gtk.AlignStart)
+	nameLabel.SetHExpand(true)
+	nameLabel.SetEllipsize(3)
+	nameLabel.SetMaxWidthChars(40)
+	box.Append(nameLabel)
+
+	origin := "Unknown location"
+	if item.Original != "" {
+		origin = filepath.Dir(item.Original)
+	}
+	originLabel := gtk.NewLabel(origin)
+	originLabel.AddCSSClass("file-origin")
+	originLabel.SetEllipsize(1) // PANGO_ELLIPSIZE_START
+	originLabel.SetMaxWidthChars(30)
+	box.Append(originLabel)
+
+	sizeLabel := gtk.NewLabel(fileview.HumanizeSize(entry.Size))
+	sizeLabel.AddCSSClass("file-size")
+	sizeLabel.SetWidthChars(10)
+	box.Append(sizeLabel)
+
+	date := "Unknown"
+	if item.Dated {
+		date = fileview.FormatDate(item.DeletedAt)
+	}
+	dateLabel := gtk.NewLabel(date)
+	dateLabel.AddCSSClass("file-date")
+	dateLabel.SetWidthChars(12)
+	box.Append(dateLabel)
+
+	if item.Original != "" {
+		row.SetTooltipText("Trashed from " + item.Original)
+	}
+	row.SetChild(box)
+	return row
+}
+
+// selectedTrashItems returns the trash items of the selected rows
+func (fm *FileManager) selectedTrashItems() []trash.Item {
+	fm.mu.RLock()
+	defer fm.mu.RUnlock()
+
+	var items []trash.Item
+	for _, f := range fm.selectedFiles {
+		for _, item := range fm.trashItems {
+			if item.Path == f.Path {
+				items = append(items, item)
+				break
+			}
+		}
+	}
+	return items
+}
+
+// showTrashContextMenu pops up the actions for the selected trash items
+func (fm *FileManager) showTrashContextMenu(row *gtk.ListBoxRow) {
+	menu := gio.NewMenu()
+	menu.Append("Restore", "app.trash-restore")
+	menu.Append("Delete Permanently", "app.trash-delete")
+
+	fm.addMenuAction("trash-restore", fm.restoreSelected)
+	fm.addMenuAction("trash-delete", fm.confirmDeleteFromTrash)
+
+	popover := gtk.NewPopoverMenuFromModel(menu)
+	popover.SetParent(row)
+	popover.SetHasArrow(false)
+	popover.Popup()
+}
+
+// restoreSelected puts the selected items back where they were trashed
+// from. An item whose name has been taken there since is restored next to
+// it under a new name.
+func (fm *FileManager) restoreSelected() {
+	items := fm.selectedTrashItems()
+	if len(items) == 0 {
+		return
+	}
+
+	go func() {
+		var lastErr error
+		for _, item := range items {
+			if item.Original == "" {
+				lastErr = fmt.Errorf("%s has no original location", item.Name)
+				continue
+			}
+			if err := trash.Restore(item, clipboard.ResolveConflict(item.Original)); err != nil {
+				lastErr = err
+			}
+		}
+		glib.IdleAdd(func() {
+			if lastErr != nil {
+				fm.showError("Restore failed: " + lastErr.Error())
+			}
+			fm.refreshAll()
+		})
+	}()
+}
+
+// confirmDeleteFromTrash asks before removing the selected items for good
+func (fm *FileManager) confirmDeleteFromTrash() {
+	items := fm.selectedTrashItems()
+	if len(items) == 0 {
+		return
+	}
+
+	message := fmt.Sprintf("Permanently delete %s?", fileview.Pluralize(len(items), "item", "items"))
+	if len(items) == 1 {
+		message = fmt.Sprintf("Permanently delete “%s”?", filepath.Base(items[0].Original))
+	}
+	fm.confirm("Delete Permanently", message, "Delete", func() {
+		fm.removeFromTrash(items)
+	})
+}
+
+// confirmEmptyTrash asks before removing everything in the trash
+func (fm *FileManager) confirmEmptyTrash() {
+	fm.confirm("Empty Trash", "Permanently delete all items in the trash?", "Empty Trash", func() {
+		go func() {
+			err := trash.Empty()
+			glib.IdleAdd(func() {
+				if err != nil {
+					fm.showError("Emptying the trash failed: " + err.Error())
+				}
+				fm.refreshAll()
+			})
+		}()
+	})
+}
+
+// removeFromTrash deletes items and their .trashinfo files
+func (fm *FileManager) removeFromTrash(items []trash.Item) {
+	go func() {
+		var lastErr error
+		for _, item := range items {
+			if err := trash.Delete(item); err != nil {
+				lastErr = err
+			}
+		}
+		glib.IdleAdd(func() {
+			if lastErr != nil {
+				fm.showError("Delete failed: " + lastErr.Error())
+			}
+			fm.refreshAll()
+		})
+	}()
+}
+
+// trashPaths moves files dropped on the Trash into it
+func (fm *FileManager) trashPaths(paths []string) {
+	files := make([]fileview.FileEntry, len(paths))
+	for i, path := range paths {
+		files[i] = fileview.FileEntry{Name: filepath.Base(path), Path: path}
+	}
+	fm.startRemoval(files, false, filepath.Dir(paths[0]), false)
+}
+
+// confirm asks message in a dialog titled title, running run when the user
+// clicks the destructive button labeled action
+func (fm *FileManager) confirm(title, message, action string, run func()) {
+	dialog := gtk.NewDialog()
+	dialog.SetTitle(title)
+	dialog.SetTransientFor(fm.window)
+	dialog.SetModal(true)
+	dialog.SetDefaultSize(360, -1)
+
+	content := dialog.ContentArea()
+	content.SetMarginTop(16)
+	content.SetMarginBottom(16)
+	content.SetMarginStart(16)
+	content.SetMarginEnd(16)
+	content.SetSpacing(12)
+
+	label := gtk.NewLabel(message)
+	label.SetWrap(true)
+	label.SetHAlign(gtk.AlignStart)
+	content.Append(label)
+
+	note := gtk.NewLabel("This cannot be undone.")
+	note.AddCSSClass("dim-label")
+	note.SetHAlign(gtk.AlignStart)
+	content.Append(note)
+
+	buttonBox := gtk.NewBox(gtk.OrientationHorizontal, 8)
+	buttonBox.SetHAlign(gtk.AlignEnd)
+	buttonBox.SetMarginTop(16)
+
+	cancelBtn := gtk.NewButton()
+	cancelBtn.SetLabel("Cancel")
+	cancelBtn.AddCSSClass("cancel")
+	cancelBtn.ConnectClicked(func() { dialog.Destroy() })
+	buttonBox.Append(cancelBtn)
+
+	actionBtn := gtk.NewButton()
+	actionBtn.SetLabel(action)
+	actionBtn.AddCSSClass("destructive")
+	actionBtn.ConnectClicked(func() {
+		dialog.Destroy()
+		run()
+	})
+	buttonBox.Append(actionBtn)
+
+	content.Append(buttonBox)
+	dialog.Present()
+	cancelBtn.GrabFocus()
+}
@@ -105,6 +105,7 @@ func (kb *RavenKeybindings) initBindings() {
 	kb.bindings = []KeyBinding{
 		// Applications
 		{Keys: "Super + T", Description: "Open Terminal", Category: "Applications"},
+		{Keys: "Super", Description: "Open Menu (tap)", Category: "Applications"},
 		{Keys: "Super + M", Description: "Open Menu", Category: "Applications"},
 		{Keys: "Super + S", Description: "Open Settings", Category: "Applications"},
 		{Keys: "Super + F", Description: "Fuzzy Finder / Launcher", Category: "Applications"},
@@ -115,7 +116,7 @@ func (kb *RavenKeybindings) initBindings() {
 
 		// Window Management
 		{Keys: "Super + Q", Description: "Close Window", Category: "Windows"},
-		{Keys: "Super + V", Description: "Toggle Floating", Category: "Windows"},
+		{Keys: "Super + Shift + V", Description: "Toggle Floating", Category: "Windows"},
 		{Keys: "Super + Shift + F", Description: "Fullscreen", Category: "Windows"},
 		{Keys: "Super + J", Description: "Toggle Split", Category: "Windows"},
 		{Keys: "Super + R", Description: "Enter Resize Mode", Category: "Windows"},
@@ -152,6 +153,7 @@ func (kb *RavenKeybindings) initBindings() {
 
 		// System
 		{Keys: "Super + Escape", Description: "Lock Screen", Category: "System"},
+		{Keys: "Super + V", Description: "Clipboard History", Category: "System"},
 		{Keys: "Super + Shift + Q", Description: "Exit Hyprland", Category: "System"},
 	}
 }
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/diamondburned/gotk4/pkg/gio/v2"
+	"github.com/diamondburned/gotk4/pkg/glib/v2"
+	"github.com/diamondburned/gotk4/pkg/gtk/v4"
+)
+
+// How long rvn gets to name the package owning a .desktop file
+const ownerLookupTimeout = 2 * time.Second
+
+// showAppContextMenu offers launch, pinning and package actions for an
+// app row or favorites strip button
+func (m *RavenMenu) showAppContextMenu(parent gtk.Widgetter, app Application) {
+	// Favorites only carry a command; find their .desktop file
+	app = m.resolveApp(app)
+
+	menu := gio.NewMenu()
+
+	launch := gio.NewMenu()
+	launch.Append("Launch", "app.app-launch")
+	launch.Append("Launch in Terminal", "app.app-launch-terminal")
+	menu.AppendSection("", launch)
+
+	// While the favorites are the dock's pins, pinning is how an app
+	// becomes a favorite
+	pin := gio.NewMenu()
+	if m.state.independentFavorites() {
+		if m.isFavorite(app) {
+			pin.Append("Remove from Favorites", "app.favorite-remove")
+		} else {
+			pin.Append("Add to Favorites", "app.favorite-add")
+		}
+	}
+	if isDockPinned(app) {
+		pin.Append("Unpin from Dock", "app.dock-unpin")
+	} else {
+		pin.Append("Pin to Dock", "app.dock-pin")
+	}
+	pin.Append("Pin to Desktop", "app.desktop-pin")
+	menu.AppendSection("", pin)
+
+	favorites := gio.NewMenu()
+	if m.state.independentFavorites() {
+		favorites.Append("Use Dock Pins as Favorites", "app.favorites-dock")
+	} else {
+		favorites.Append("Keep Favorites Separate from Dock", "app.favorites-own")
+	}
+	menu.AppendSection("", favorites)
+
+	m.addMenuAction("app-launch", func() { m.launchApp(app) })
+	m.addMenuAction("app-launch-terminal", func() { m.launchInTerminal(app) })
+	m.addMenuAction("favorite-add", func() { m.setFavorite(app, true) })
+	m.addMenuAction("favorite-remove", func() { m.setFavorite(app, false) })
+	m.addMenuAction("dock-pin", func() { m.setDockPinned(app, true) })
+	m.addMenuAction("dock-unpin", func() { m.setDockPinned(app, false) })
+	m.addMenuAction("desktop-pin", func() { m.pinToDesktop(app) })
+	m.addMenuAction("favorites-dock", func() { m.setFavoritesIndependent(false) })
+	m.addMenuAction("favorites-own", func() { m.setFavoritesIndependent(true) })
+
+	if app.Path != "" {
+		file := gio.NewMenu()
+		file.Append("Show .desktop File", "app.app-show-file")
+		menu.AppendSection("", file)
+		m.addMenuAction("app-show-file", func() { m.showDesktopFile(app) })
+
+		// rvn may take a moment; the entry is added to the open menu
+		// once it names the package
+		go func() {
+			pkg := packageOwner(app.Path)
+			if pkg == "" {
+				return
+			}
+			glib.IdleAdd(func() {
+				m.addMenuAction("app-uninstall", func() { m.uninstallPackage(pkg) })
+				file.Append(fmt.Sprintf("Uninstall %s...", pkg), "app.app-uninstall")
+			})
+		}()
+	}
+
+	popover := gtk.NewPopoverMenuFromModel(menu)
+	popover.SetParent(parent)
+	popover.SetPosition(gtk.PosBottom)
+	popover.Popup()
+}
+
+// addMenuAction (re)registers an app action run by a context menu entry
+func (m *RavenMenu) addMenuAction(name string, run func()) {
+	action := gio.NewSimpleAction(name, nil)
+	action.ConnectActivate(func(v *glib.Variant) {
+		run()
+	})
+	m.app.AddAction(action)
+}
+
+// showDesktopFile opens the directory holding the app's .desktop file in
+// the file manager
+func (m *RavenMenu) showDesktopFile(app Application) {
+	cmd := exec.Command("raven-file-manager", filepath.Dir(app.Path))
+	if err := cmd.Start(); err != nil {
+		fmt.Fprintf(os.Stderr, "raven-menu: failed to start raven-file-manager: %v\n", err)
+		return
+	}
+	go cmd.Wait()
+	m.window.Close()
+}
+
+// packageOwner returns the rvn package that installed path, or "" when rvn
+// is missing or no package owns it
+func packageOwner(path string) string {
+	ctx, cancel := context.WithTimeout(context.Background(), ownerLookupTimeout)
+	defer cancel()
+
+	out, err := exec.CommandContext(ctx, "rvn", "owns", path).Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// uninstallPackage removes pkg in a terminal, where sudo can ask for the
+// password and the output stays readable
+func (m *RavenMenu) uninstallPackage(pkg string) {
+	cmd := exec.Command("raven-terminal", "-e", "sudo rvn remove "+shellQuote(pkg))
+	if err := cmd.Start(); err != nil {
+		fmt.Fprintf(os.Stderr, "raven-menu: failed to start raven-terminal: %v\n", err)
+		return
+	}
+	go cmd.Wait()
+	m.window.Close()
+}
+
+// shellQuote quotes s as a single sh word
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
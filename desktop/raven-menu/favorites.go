@@ -0,0 +1,333 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/diamondburned/gotk4/pkg/glib/v2"
+	"github.com/diamondburned/gotk4/pkg/gtk/v4"
+
+	"raven-file-manager/pkg/icons"
+)
+
+// favoritesCategory lists the favorites, which are the apps pinned to the
+// shell dock unless the menu keeps its own
+const favoritesCategory = "Favorites"
+
+// Size of the icons in the favorites strip
+const favoriteIconSize = 32
+
+// dockEntry matches a pinned app in raven-shell's dock.json
+type dockEntry struct {
+	ID      string `json:"id"`
+	Name    string `json:"name"`
+	Command string `json:"command"`
+	Icon    string `json:"icon"`
+	Pinned  bool   `json:"pinned"`
+}
+
+// desktopEntry matches a pinned app in raven-desktop's pinned-apps.json
+type desktopEntry struct {
+	Name string `json:"name"`
+	Exec string `json:"exec"`
+	Icon string `json:"icon"`
+	X    int    `json:"x"`
+	Y    int    `json:"y"`
+}
+
+func dockConfigPath() string {
+	configDir, _ := os.UserConfigDir()
+	return filepath.Join(configDir, "raven-shell", "dock.json")
+}
+
+func desktopPinnedPath() string {
+	return filepath.Join(os.Getenv("HOME"), ".config", "raven", "pinned-apps.json")
+}
+
+// readPinnedList decodes the pinned_apps array of a shared config file
+// into list and returns every key, so keys this menu doesn't know about
+// are written back untouched
+func readPinnedList(path string, list any) map[string]json.RawMessage {
+	config := make(map[string]json.RawMessage)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return config
+	}
+	if err := json.Unmarshal(data, &config); err != nil {
+		fmt.Fprintf(os.Stderr, "raven-menu: invalid %s: %v\n", path, err)
+		return config
+	}
+	if raw, ok := config["pinned_apps"]; ok {
+		json.Unmarshal(raw, list)
+	}
+	return config
+}
+
+func writePinnedList(path string, config map[string]json.RawMessage, list any) error {
+	raw, err := json.Marshal(list)
+	if err != nil {
+		return err
+	}
+	config["pinned_apps"] = raw
+
+	data, err := json.MarshalIndent(config, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// loadFavorites returns the menu's own favorites when state keeps them,
+// otherwise the apps pinned to the dock
+func loadFavorites(state menuState) []Application {
+	if state.independentFavorites() {
+		favorites := make([]Application, 0, len(state.Favorites))
+		for _, e := range state.Favorites {
+			favorites = append(favorites, Application{
+				Name:     e.Name,
+				Exec:     e.Command,
+				Icon:     e.Icon,
+				Category: favoritesCategory,
+			})
+		}
+		return favorites
+	}
+	return loadDockPins()
+}
+
+// loadDockPins returns the apps pinned to the dock
+func loadDockPins() []Application {
+	var entries []dockEntry
+	readPinnedList(dockConfigPath(), &entries)
+
+	pins := make([]Application, 0, len(entries))
+	for _, e := range entries {
+		pins = append(pins, Application{
+			Name:     e.Name,
+			Exec:     e.Command,
+			Icon:     e.Icon,
+			Category: favoritesCategory,
+		})
+	}
+	return pins
+}
+
+// isFavorite reports whether app is one of the favorites
+func (m *RavenMenu) isFavorite(app Application) bool {
+	return containsApp(m.favoritesApps(), app)
+}
+
+// isDockPinned reports whether app is pinned to the dock
+func isDockPinned(app Application) bool {
+	return containsApp(loadDockPins(), app)
+}
+
+// containsApp reports whether apps has one with app's command
+func containsApp(apps []Application, app Application) bool {
+	for _, a := range apps {
+		if cleanExec(a.Exec) == cleanExec(app.Exec) {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *RavenMenu) favoritesApps() []Application {
+	for _, cat := range m.categories {
+		if cat.Name == favoritesCategory {
+			return cat.Apps
+		}
+	}
+	return nil
+}
+
+// setDockPinned pins app to the dock or unpins it. raven-shell watches
+// dock.json and updates the dock on its own.
+func (m *RavenMenu) setDockPinned(app Application, pinned bool) {
+	path := dockConfigPath()
+	var entries []dockEntry
+	config := readPinnedList(path, &entries)
+
+	command := cleanExec(app.Exec)
+	kept := entries[:0]
+	for _, e := range entries {
+		if e.Command != command {
+			kept = append(kept, e)
+		}
+	}
+	entries = kept
+	if pinned {
+		entries = append(entries, dockEntry{
+			ID:      "menu-" + strings.ToLower(strings.ReplaceAll(app.Name, " ", "-")),
+			Name:    app.Name,
+			Command: command,
+			Icon:    app.Icon,
+			Pinned:  true,
+		})
+	}
+
+	if err := writePinnedList(path, config, entries); err != nil {
+		fmt.Fprintf(os.Stderr, "raven-menu: failed to update %s: %v\n", path, err)
+		return
+	}
+	m.refreshFavorites()
+}
+
+// setFavorite adds app to the favorites or removes it. Unless the menu
+// keeps its own favorites, that pins or unpins it on the dock.
+func (m *RavenMenu) setFavorite(app Application, favorite bool) {
+	if !m.state.independentFavorites() {
+		m.setDockPinned(app, favorite)
+		return
+	}
+
+	command := cleanExec(app.Exec)
+	kept := m.state.Favorites[:0]
+	for _, e := range m.state.Favorites {
+		if e.Command != command {
+			kept = append(kept, e)
+		}
+	}
+	m.state.Favorites = kept
+	if favorite {
+		m.state.Favorites = append(m.state.Favorites, favoriteEntry{Name: app.Name, Command: command, Icon: app.Icon})
+	}
+	m.saveState()
+	m.refreshFavorites()
+}
+
+// setFavoritesIndependent switches between the dock's pins and the menu's
+// own favorites. The own list starts as a copy of the dock's pins.
+func (m *RavenMenu) setFavoritesIndependent(independent bool) {
+	if independent {
+		m.state.FavoritesSource = favoritesFromMenu
+		if len(m.state.Favorites) == 0 {
+			for _, app := range loadDockPins() {
+				m.state.Favorites = append(m.state.Favorites, favoriteEntry{Name: app.Name, Command: app.Exec, Icon: app.Icon})
+			}
+		}
+	} else {
+		m.state.FavoritesSource = favoritesFromDock
+	}
+	m.saveState()
+	m.refreshFavorites()
+}
+
+// pinToDesktop adds app to the desktop icons and asks raven-desktop to
+// reload them
+func (m *RavenMenu) pinToDesktop(app Application) {
+	path := desktopPinnedPath()
+	var entries []desktopEntry
+	config := readPinnedList(path, &entries)
+
+	for _, e := range entries {
+		if e.Name == app.Name {
+			return
+		}
+	}
+	entries = append(entries, desktopEntry{Name: app.Name, Exec: cleanExec(app.Exec), Icon: app.Icon})
+
+	if err := writePinnedList(path, config, entries); err != nil {
+		fmt.Fprintf(os.Stderr, "raven-menu: failed to update %s: %v\n", path, err)
+		return
+	}
+	go exec.Command("raven-ctl", "desktop", "refresh").Run()
+}
+
+// refreshFavorites reloads the Favorites category and strip, redrawing the
+// category if shown
+func (m *RavenMenu) refreshFavorites() {
+	favorites := loadFavorites(m.state)
+	if m.kiosk {
+		favorites = m.kioskFilter(favorites)
+	}
+	for i := range m.categories {
+		if m.categories[i].Name == favoritesCategory {
+			m.categories[i].Apps = favorites
+		}
+	}
+	m.fillFavoritesStrip()
+	if m.currentCat == favoritesCategory && m.searchEntry.Text() == "" {
+		m.showCategory(favoritesCategory)
+	}
+}
+
+// createFavoritesStrip returns the row of favorite app icons shown above
+// the categories. It is hidden while there are no favorites.
+func (m *RavenMenu) createFavoritesStrip() *gtk.FlowBox {
+	m.favoritesStrip = gtk.NewFlowBox()
+	m.favoritesStrip.AddCSSClass("favorites-strip")
+	m.favoritesStrip.SetSelectionMode(gtk.SelectionNone)
+	m.favoritesStrip.SetHomogeneous(true)
+	m.favoritesStrip.SetMaxChildrenPerLine(8)
+	m.favoritesStrip.SetMarginStart(8)
+	m.favoritesStrip.SetMarginEnd(8)
+	m.favoritesStrip.SetMarginBottom(4)
+	m.fillFavoritesStrip()
+	return m.favoritesStrip
+}
+
+// fillFavoritesStrip shows the current favorites in the strip
+func (m *RavenMenu) fillFavoritesStrip() {
+	if m.favoritesStrip == nil {
+		return
+	}
+	for {
+		child := m.favoritesStrip.ChildAtIndex(0)
+		if child == nil {
+			break
+		}
+		m.favoritesStrip.Remove(child)
+	}
+
+	favorites := m.favoritesApps()
+	for _, app := range favorites {
+		m.favoritesStrip.Append(m.createFavoriteButton(app))
+	}
+	m.favoritesStrip.SetVisible(len(favorites) > 0)
+}
+
+// createFavoriteButton returns the strip's launcher for app
+func (m *RavenMenu) createFavoriteButton(app Application) *gtk.Button {
+	iconName := app.Icon
+	if iconName == "" {
+		iconName = "application-x-executable"
+	}
+	button := gtk.NewButton()
+	button.AddCSSClass("favorite-button")
+	button.SetChild(icons.NewImage(iconName, favoriteIconSize))
+	button.SetTooltipText(app.Name)
+	button.UpdateProperty(
+		[]gtk.AccessibleProperty{gtk.AccessiblePropertyLabel},
+		[]glib.Value{*glib.NewValue(app.Name)},
+	)
+	button.ConnectClicked(func() {
+		m.launchApp(app)
+	})
+
+	if m.kiosk {
+		return button
+	}
+	rightClick := gtk.NewGestureClick()
+	rightClick.SetButton(3)
+	rightClick.ConnectPressed(func(nPress int, x, y float64) {
+		m.showAppContextMenu(button, app)
+	})
+	button.AddController(rightClick)
+	return button
+}
+
+// cleanExec strips .desktop field codes from an Exec line
+func cleanExec(cmd string) string {
+	for _, code := range []string{"%f", "%F", "%u", "%U"} {
+		cmd = strings.ReplaceAll(cmd, code, "")
+	}
+	return strings.TrimSpace(cmd)
+}
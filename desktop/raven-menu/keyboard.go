@@ -0,0 +1,151 @@
+package main
+
+import (
+	"github.com/diamondburned/gotk4/pkg/gdk/v4"
+	"github.com/diamondburned/gotk4/pkg/gtk/v4"
+)
+
+// Panes cycled with Tab, in order
+const (
+	paneSearch = iota
+	paneCategories
+	paneApps
+	paneCount
+)
+
+// setupKeyboardNavigation makes the menu usable without a mouse: Enter in
+// the search entry launches the selected (by default the top) result, Up
+// and Down move through the results while typing, Left and Right move
+// between the category and app lists, and Tab cycles the panes.
+func (m *RavenMenu) setupKeyboardNavigation() {
+	m.searchEntry.ConnectActivate(func() {
+		m.launchSelected()
+	})
+
+	// Capture phase, so the entry's text widget doesn't eat Up/Down
+	entryKeys := gtk.NewEventControllerKey()
+	entryKeys.SetPropagationPhase(gtk.PhaseCapture)
+	entryKeys.ConnectKeyPressed(func(keyval, keycode uint, state gdk.ModifierType) bool {
+		switch keyval {
+		case gdk.KEY_Down:
+			m.moveAppSelection(1)
+			return true
+		case gdk.KEY_Up:
+			m.moveAppSelection(-1)
+			return true
+		}
+		return false
+	})
+	m.searchEntry.AddController(entryKeys)
+
+	appKeys := gtk.NewEventControllerKey()
+	appKeys.ConnectKeyPressed(func(keyval, keycode uint, state gdk.ModifierType) bool {
+		if keyval == gdk.KEY_Left {
+			m.focusPane(paneCategories)
+			return true
+		}
+		return false
+	})
+	m.appList.AddController(appKeys)
+
+	categoryKeys := gtk.NewEventControllerKey()
+	categoryKeys.ConnectKeyPressed(func(keyval, keycode uint, state gdk.ModifierType) bool {
+		if keyval == gdk.KEY_Right {
+			m.focusPane(paneApps)
+			return true
+		}
+		return false
+	})
+	m.categoryList.AddController(categoryKeys)
+
+	tabKeys := gtk.NewEventControllerKey()
+	tabKeys.SetPropagationPhase(gtk.PhaseCapture)
+	tabKeys.ConnectKeyPressed(func(keyval, keycode uint, state gdk.ModifierType) bool {
+		switch keyval {
+		case gdk.KEY_Tab:
+			m.focusPane((m.currentPane() + 1) % paneCount)
+			return true
+		case gdk.KEY_ISO_Left_Tab:
+			m.focusPane((m.currentPane() + paneCount - 1) % paneCount)
+			return true
+		}
+		return false
+	})
+	m.window.AddController(tabKeys)
+}
+
+// currentPane returns the pane holding keyboard focus
+func (m *RavenMenu) currentPane() int {
+	focus := m.window.Focus()
+	if focus == nil {
+		return paneSearch
+	}
+	widget := gtk.BaseWidget(focus)
+	switch {
+	case widget.IsAncestor(m.categoryList):
+		return paneCategories
+	case widget.IsAncestor(m.appList):
+		return paneApps
+	}
+	return paneSearch
+}
+
+// focusPane moves keyboard focus to a pane, landing on its selected row
+func (m *RavenMenu) focusPane(pane int) {
+	switch pane {
+	case paneSearch:
+		m.searchEntry.GrabFocus()
+	case paneCategories:
+		if row := m.categoryList.SelectedRow(); row != nil {
+			row.GrabFocus()
+		} else {
+			m.categoryList.GrabFocus()
+		}
+	case paneApps:
+		row := m.appList.SelectedRow()
+		if row == nil {
+			row = m.appList.RowAtIndex(0)
+		}
+		if row != nil {
+			m.appList.SelectRow(row)
+			row.GrabFocus()
+		}
+	}
+}
+
+// selectFirstApp highlights the top result, which Enter launches
+func (m *RavenMenu) selectFirstApp() {
+	if row := m.appList.RowAtIndex(0); row != nil {
+		m.appList.SelectRow(row)
+	}
+}
+
+// moveAppSelection moves the app list selection by delta rows while focus
+// stays in the search entry
+func (m *RavenMenu) moveAppSelection(delta int) {
+	idx := -1
+	if row := m.appList.SelectedRow(); row != nil {
+		idx = row.Index()
+	}
+	next := m.appList.RowAtIndex(idx + delta)
+	if next == nil {
+		return
+	}
+	m.appList.SelectRow(next)
+
+	// The row doesn't have focus, so scroll it into view by hand
+	if _, y, ok := next.TranslateCoordinates(m.appList, 0, 0); ok {
+		m.appScroll.VAdjustment().ClampPage(y, y+float64(next.Height()))
+	}
+}
+
+// launchSelected launches the selected result, or the top one
+func (m *RavenMenu) launchSelected() {
+	idx := 0
+	if row := m.appList.SelectedRow(); row != nil {
+		idx = row.Index()
+	}
+	if idx >= 0 && idx < len(m.shownApps) {
+		m.launchApp(m.shownApps[idx])
+	}
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// loadKiosk reads the kiosk settings from settings.json: whether kiosk
+// mode is on and the apps it allows
+func loadKiosk() (bool, []string) {
+	settings := struct {
+		KioskMode bool     `json:"kiosk_mode"`
+		KioskApps []string `json:"kiosk_apps"`
+	}{}
+
+	data, err := os.ReadFile(filepath.Join(os.Getenv("HOME"), ".config", "raven", "settings.json"))
+	if err == nil {
+		json.Unmarshal(data, &settings)
+	}
+	return settings.KioskMode, settings.KioskApps
+}
+
+// kioskAllows reports whether app is in the kiosk allow-list, which names
+// apps by .desktop file or program, as the dock does
+func (m *RavenMenu) kioskAllows(app Application) bool {
+	desktopID := strings.TrimSuffix(filepath.Base(app.Path), ".desktop")
+	program := ""
+	if fields := strings.Fields(cleanExec(app.Exec)); len(fields) > 0 {
+		program = filepath.Base(fields[0])
+	}
+	for _, allowed := range m.kioskApps {
+		allowed = strings.TrimSuffix(allowed, ".desktop")
+		if allowed == "" {
+			continue
+		}
+		if (app.Path != "" && allowed == desktopID) || strings.EqualFold(allowed, program) {
+			return true
+		}
+	}
+	return false
+}
+
+// kioskFilter returns the apps kiosk mode allows
+func (m *RavenMenu) kioskFilter(apps []Application) []Application {
+	var allowed []Application
+	for _, app := range apps {
+		if m.kioskAllows(app) {
+			allowed = append(allowed, app)
+		}
+	}
+	return allowed
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"os"
+	"strings"
+)
+
+// messageLocales are the locales tried for Name[xx] and Comment[xx] keys,
+// most specific first
+var messageLocales = localeVariants()
+
+// currentLocale returns the locale used for messages, as set by the
+// environment
+func currentLocale() string {
+	for _, env := range []string{"LC_ALL", "LC_MESSAGES", "LANG"} {
+		if value := os.Getenv(env); value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+// localeVariants expands LANGUAGE (a gettext priority list) and the
+// message locale into the keys .desktop files use
+func localeVariants() []string {
+	locale := currentLocale()
+	if locale == "" || locale == "C" || locale == "POSIX" || strings.HasPrefix(locale, "C.") {
+		return nil
+	}
+
+	candidates := append(strings.Split(os.Getenv("LANGUAGE"), ":"), locale)
+	seen := make(map[string]bool)
+	var variants []string
+	for _, candidate := range candidates {
+		for _, variant := range expandLocale(candidate) {
+			if !seen[variant] {
+				seen[variant] = true
+				variants = append(variants, variant)
+			}
+		}
+	}
+	return variants
+}
+
+// expandLocale turns lang_COUNTRY.ENCODING@MODIFIER into the match order
+// of the desktop entry spec: lang_COUNTRY@MODIFIER, lang_COUNTRY,
+// lang@MODIFIER, lang. The encoding is never part of a key.
+func expandLocale(locale string) []string {
+	locale, modifier, hasModifier := strings.Cut(locale, "@")
+	locale, _, _ = strings.Cut(locale, ".")
+	lang, country, hasCountry := strings.Cut(locale, "_")
+	if lang == "" {
+		return nil
+	}
+
+	var variants []string
+	if hasCountry && hasModifier {
+		variants = append(variants, lang+"_"+country+"@"+modifier)
+	}
+	if hasCountry {
+		variants = append(variants, lang+"_"+country)
+	}
+	if hasModifier {
+		variants = append(variants, lang+"@"+modifier)
+	}
+	return append(variants, lang)
+}
+
+// localizedValue picks the best value of key for the current locale from
+// values, which holds both "Key" and "Key[locale]" entries
+func localizedValue(values map[string]string, key string) string {
+	for _, locale := range messageLocales {
+		if value, ok := values[key+"["+locale+"]"]; ok && value != "" {
+			return value
+		}
+	}
+	return values[key]
+}
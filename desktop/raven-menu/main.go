@@ -2,6 +2,8 @@ package main
 
 import (
 	"bufio"
+	"encoding/json"
+	"fmt"
 	"os"
 	"os/exec"
 	"path/filepath"
@@ -12,6 +14,8 @@ import (
 	"github.com/diamondburned/gotk4/pkg/gdk/v4"
 	"github.com/diamondburned/gotk4/pkg/gio/v2"
 	"github.com/diamondburned/gotk4/pkg/gtk/v4"
+
+	"raven-file-manager/pkg/icons"
 )
 
 /*
@@ -19,20 +23,37 @@ import (
 #include <gtk4-layer-shell.h>
 #include <gtk/gtk.h>
 
-void init_menu_layer_shell(GtkWidget *window) {
+// position: 0=top, 1=bottom, 2=left, 3=right (where the panel is)
+// The menu is a full-height column beside the panel's start button, kept
+// clear of the panel by panel_size.
+void init_menu_layer_shell(GtkWidget *window, int position, int panel_size) {
     gtk_layer_init_for_window(GTK_WINDOW(window));
     gtk_layer_set_layer(GTK_WINDOW(window), GTK_LAYER_SHELL_LAYER_OVERLAY);
     gtk_layer_set_keyboard_mode(GTK_WINDOW(window), GTK_LAYER_SHELL_KEYBOARD_MODE_EXCLUSIVE);
+
+    // The start button is on the right of a right panel, else the left
+    GtkLayerShellEdge side = position == 3 ? GTK_LAYER_SHELL_EDGE_RIGHT : GTK_LAYER_SHELL_EDGE_LEFT;
     gtk_layer_set_anchor(GTK_WINDOW(window), GTK_LAYER_SHELL_EDGE_TOP, TRUE);
-    gtk_layer_set_anchor(GTK_WINDOW(window), GTK_LAYER_SHELL_EDGE_LEFT, TRUE);
     gtk_layer_set_anchor(GTK_WINDOW(window), GTK_LAYER_SHELL_EDGE_BOTTOM, TRUE);
-    gtk_layer_set_margin(GTK_WINDOW(window), GTK_LAYER_SHELL_EDGE_TOP, 36);
-    gtk_layer_set_margin(GTK_WINDOW(window), GTK_LAYER_SHELL_EDGE_LEFT, 0);
-    gtk_layer_set_margin(GTK_WINDOW(window), GTK_LAYER_SHELL_EDGE_BOTTOM, 0);
+    gtk_layer_set_anchor(GTK_WINDOW(window), side, TRUE);
+
+    gtk_layer_set_margin(GTK_WINDOW(window), GTK_LAYER_SHELL_EDGE_TOP, position == 0 ? panel_size : 0);
+    gtk_layer_set_margin(GTK_WINDOW(window), GTK_LAYER_SHELL_EDGE_BOTTOM, position == 1 ? panel_size : 0);
+    gtk_layer_set_margin(GTK_WINDOW(window), side, position >= 2 ? panel_size : 0);
+}
+
+// The window switcher is unanchored, so it floats in the middle of the screen
+void init_switcher_layer_shell(GtkWidget *window) {
+    gtk_layer_init_for_window(GTK_WINDOW(window));
+    gtk_layer_set_layer(GTK_WINDOW(window), GTK_LAYER_SHELL_LAYER_OVERLAY);
+    gtk_layer_set_keyboard_mode(GTK_WINDOW(window), GTK_LAYER_SHELL_KEYBOARD_MODE_EXCLUSIVE);
 }
 */
 import "C"
 
+// Panel size used when settings.json doesn't set panel_height
+const defaultPanelHeight = 36
+
 // Application represents a launchable app
 type Application struct {
 	Name     string
@@ -40,6 +61,7 @@ type Application struct {
 	Icon     string
 	Comment  string
 	Category string
+	Path     string // .desktop file, empty for built-in apps
 }
 
 // Category represents an app category
@@ -51,28 +73,59 @@ type Category struct {
 
 // RavenMenu is the start menu
 type RavenMenu struct {
-	app          *gtk.Application
-	window       *gtk.Window
-	searchEntry  *gtk.Entry
-	categoryList *gtk.ListBox
-	appList      *gtk.ListBox
-	categories   []Category
-	allApps      []Application
-	currentCat   string
+	app            *gtk.Application
+	window         *gtk.Window
+	searchEntry    *gtk.Entry
+	categoryList   *gtk.ListBox
+	appList        *gtk.ListBox
+	favoritesStrip *gtk.FlowBox
+	appScroll      *gtk.ScrolledWindow
+	shownApps      []Application // Apps in appList, in row order
+	categories     []Category
+	allApps        []Application
+	currentCat     string
+	windowMode     bool         // Opened as the window switcher (--windows)
+	allWindows     []hyprClient // Open windows, most recently focused first
+	shownWindows   []hyprClient // Windows in appList, in row order
+	kiosk          bool         // Kiosk mode: allowed apps only, nothing else runs
+	kioskApps      []string     // Apps kiosk mode allows
+	state          menuState    // Remembered between launches
 }
 
 func main() {
-	app := gtk.NewApplication("org.ravenlinux.menu", gio.ApplicationFlagsNone)
+	// --windows opens the window switcher instead of the app menu. It is
+	// taken out before GTK parses the arguments, and has its own app ID so
+	// it doesn't hand over to an open app menu.
+	windowMode := false
+	args := os.Args[:1]
+	for _, arg := range os.Args[1:] {
+		if arg == "--windows" {
+			windowMode = true
+			continue
+		}
+		args = append(args, arg)
+	}
+
+	appID := "org.ravenlinux.menu"
+	if windowMode {
+		appID = "org.ravenlinux.menu.windows"
+	}
+	app := gtk.NewApplication(appID, gio.ApplicationFlagsNone)
 
 	menu := &RavenMenu{
-		app: app,
+		app:        app,
+		windowMode: windowMode,
 	}
 
 	app.ConnectActivate(func() {
+		if menu.windowMode {
+			menu.activateSwitcher()
+			return
+		}
 		menu.activate()
 	})
 
-	if code := app.Run(os.Args); code > 0 {
+	if code := app.Run(args); code > 0 {
 		os.Exit(code)
 	}
 }
@@ -84,6 +137,8 @@ func (m *RavenMenu) activate() {
 	m.window.SetDecorated(false)
 
 	// Load applications
+	m.kiosk, m.kioskApps = loadKiosk()
+	m.state = loadMenuState()
 	m.loadApplications()
 
 	// Apply CSS
@@ -92,6 +147,7 @@ func (m *RavenMenu) activate() {
 	// Create UI
 	content := m.createUI()
 	m.window.SetChild(content)
+	m.setupKeyboardNavigation()
 
 	// Initialize layer shell
 	m.initLayerShell()
@@ -125,10 +181,39 @@ func (m *RavenMenu) initLayerShell() {
 	obj := m.window.Object
 	if obj != nil {
 		ptr := obj.Native()
-		C.init_menu_layer_shell((*C.GtkWidget)(unsafe.Pointer(ptr)))
+		position, size := panelPlacement()
+		C.init_menu_layer_shell((*C.GtkWidget)(unsafe.Pointer(ptr)), C.int(position), C.int(size))
+	}
+}
+
+func (m *RavenMenu) initSwitcherLayerShell() {
+	obj := m.window.Object
+	if obj != nil {
+		ptr := obj.Native()
+		C.init_switcher_layer_shell((*C.GtkWidget)(unsafe.Pointer(ptr)))
 	}
 }
 
+// panelPlacement returns the panel position (0=top, 1=bottom, 2=left,
+// 3=right) and size from settings.json, so the menu opens beside it
+func panelPlacement() (int, int) {
+	settings := struct {
+		PanelPosition string `json:"panel_position"`
+		PanelHeight   int    `json:"panel_height"`
+	}{PanelPosition: "top", PanelHeight: defaultPanelHeight}
+
+	data, err := os.ReadFile(filepath.Join(os.Getenv("HOME"), ".config", "raven", "settings.json"))
+	if err == nil {
+		json.Unmarshal(data, &settings)
+	}
+	if settings.PanelHeight <= 0 {
+		settings.PanelHeight = defaultPanelHeight
+	}
+
+	positions := map[string]int{"top": 0, "bottom": 1, "left": 2, "right": 3}
+	return positions[settings.PanelPosition], settings.PanelHeight
+}
+
 func (m *RavenMenu) applyCSS() {
 	css := `
 		window {
@@ -173,6 +258,15 @@ func (m *RavenMenu) applyCSS() {
 			color: #e0e0e0;
 			font-size: 13px;
 		}
+		.favorite-button {
+			background-color: transparent;
+			border: none;
+			border-radius: 6px;
+			padding: 6px;
+		}
+		.favorite-button:hover {
+			background-color: rgba(255, 255, 255, 0.1);
+		}
 		.app-list {
 			background-color: transparent;
 		}
@@ -254,6 +348,9 @@ func (m *RavenMenu) createUI() *gtk.Box {
 	searchBox.Append(m.searchEntry)
 	mainBox.Append(searchBox)
 
+	// Favorites strip
+	mainBox.Append(m.createFavoritesStrip())
+
 	// Main content area with categories and apps
 	contentBox := gtk.NewBox(gtk.OrientationHorizontal, 0)
 	contentBox.SetVExpand(true)
@@ -272,6 +369,7 @@ func (m *RavenMenu) createUI() *gtk.Box {
 			if idx >= 0 && idx < len(m.categories) {
 				m.currentCat = m.categories[idx].Name
 				m.showCategory(m.currentCat)
+				m.rememberCategory(m.currentCat)
 			}
 		}
 	})
@@ -279,9 +377,12 @@ func (m *RavenMenu) createUI() *gtk.Box {
 	// Add categories
 	for _, cat := range m.categories {
 		row := gtk.NewListBoxRow()
+		rowBox := gtk.NewBox(gtk.OrientationHorizontal, 8)
+		rowBox.Append(icons.NewImage(cat.Icon, 16))
 		label := gtk.NewLabel(cat.Name)
 		label.SetHAlign(gtk.AlignStart)
-		row.SetChild(label)
+		rowBox.Append(label)
+		row.SetChild(rowBox)
 		m.categoryList.Append(row)
 	}
 
@@ -293,23 +394,22 @@ func (m *RavenMenu) createUI() *gtk.Box {
 	contentBox.Append(sep)
 
 	// Apps list
-	appScroll := gtk.NewScrolledWindow()
-	appScroll.SetPolicy(gtk.PolicyNever, gtk.PolicyAutomatic)
-	appScroll.SetHExpand(true)
+	m.appScroll = gtk.NewScrolledWindow()
+	m.appScroll.SetPolicy(gtk.PolicyNever, gtk.PolicyAutomatic)
+	m.appScroll.SetHExpand(true)
 
 	m.appList = gtk.NewListBox()
 	m.appList.AddCSSClass("app-list")
 	m.appList.SetSelectionMode(gtk.SelectionSingle)
 	m.appList.ConnectRowActivated(func(row *gtk.ListBoxRow) {
 		idx := row.Index()
-		apps := m.getVisibleApps()
-		if idx >= 0 && idx < len(apps) {
-			m.launchApp(apps[idx].Exec)
+		if idx >= 0 && idx < len(m.shownApps) {
+			m.launchApp(m.shownApps[idx])
 		}
 	})
 
-	appScroll.SetChild(m.appList)
-	contentBox.Append(appScroll)
+	m.appScroll.SetChild(m.appList)
+	contentBox.Append(m.appScroll)
 
 	mainBox.Append(contentBox)
 
@@ -347,29 +447,24 @@ func (m *RavenMenu) createUI() *gtk.Box {
 	})
 	powerBox.Append(shutdownBtn)
 
-	mainBox.Append(powerBox)
-
-	// Show all apps initially
-	m.showCategory("All")
-
-	// Select first category
-	if firstRow := m.categoryList.RowAtIndex(0); firstRow != nil {
-		m.categoryList.SelectRow(firstRow)
+	if !m.kiosk {
+		mainBox.Append(powerBox)
 	}
 
-	return mainBox
-}
-
-func (m *RavenMenu) getVisibleApps() []Application {
-	if m.currentCat == "All" || m.currentCat == "" {
-		return m.allApps
-	}
-	for _, cat := range m.categories {
-		if cat.Name == m.currentCat {
-			return cat.Apps
+	// Select the category selected last time, or else the first one
+	selected := 0
+	for i, cat := range m.categories {
+		if cat.Name == m.state.LastCategory {
+			selected = i
 		}
 	}
-	return m.allApps
+	if row := m.categoryList.RowAtIndex(selected); row != nil {
+		m.categoryList.SelectRow(row)
+	} else {
+		m.showCategory("All")
+	}
+
+	return mainBox
 }
 
 func (m *RavenMenu) showCategory(name string) {
@@ -394,18 +489,30 @@ func (m *RavenMenu) showCategory(name string) {
 		}
 	}
 
+	m.shownApps = apps
 	for _, app := range apps {
 		row := m.createAppRow(app)
 		m.appList.Append(row)
 	}
+	m.selectFirstApp()
 }
 
 func (m *RavenMenu) createAppRow(app Application) *gtk.ListBoxRow {
 	row := gtk.NewListBoxRow()
 
+	rowBox := gtk.NewBox(gtk.OrientationHorizontal, 8)
+	iconName := app.Icon
+	if iconName == "" {
+		iconName = "application-x-executable"
+	}
+	image := icons.NewImage(iconName, 24)
+	image.AddCSSClass("app-icon")
+	rowBox.Append(image)
+
 	box := gtk.NewBox(gtk.OrientationVertical, 2)
 	box.SetMarginTop(4)
 	box.SetMarginBottom(4)
+	rowBox.Append(box)
 
 	nameLabel := gtk.NewLabel(app.Name)
 	nameLabel.AddCSSClass("app-name")
@@ -420,7 +527,21 @@ func (m *RavenMenu) createAppRow(app Application) *gtk.ListBoxRow {
 		box.Append(descLabel)
 	}
 
-	row.SetChild(box)
+	row.SetChild(rowBox)
+
+	// The context menu can launch in a terminal and uninstall, which
+	// kiosk mode doesn't allow
+	if m.kiosk {
+		return row
+	}
+
+	rightClick := gtk.NewGestureClick()
+	rightClick.SetButton(3)
+	rightClick.ConnectPressed(func(nPress int, x, y float64) {
+		m.showAppContextMenu(row, app)
+	})
+	row.AddController(rightClick)
+
 	return row
 }
 
@@ -442,27 +563,41 @@ func (m *RavenMenu) filterApps(query string) {
 	}
 
 	// Filter all apps
+	m.shownApps = nil
 	for _, app := range m.allApps {
 		if strings.Contains(strings.ToLower(app.Name), query) ||
 			strings.Contains(strings.ToLower(app.Comment), query) {
+			m.shownApps = append(m.shownApps, app)
 			row := m.createAppRow(app)
 			m.appList.Append(row)
 		}
 	}
+	m.selectFirstApp()
 }
 
-func (m *RavenMenu) launchApp(cmd string) {
-	// Clean exec command
-	cmd = strings.ReplaceAll(cmd, "%f", "")
-	cmd = strings.ReplaceAll(cmd, "%F", "")
-	cmd = strings.ReplaceAll(cmd, "%u", "")
-	cmd = strings.ReplaceAll(cmd, "%U", "")
-	cmd = strings.TrimSpace(cmd)
+func (m *RavenMenu) launchApp(app Application) {
+	app = m.resolveApp(app)
+	m.startApp(app, cleanExec(app.Exec))
+}
+
+// launchInTerminal runs the app inside raven-terminal, for console apps
+// and to see what a GUI app prints
+func (m *RavenMenu) launchInTerminal(app Application) {
+	app = m.resolveApp(app)
+	m.startApp(app, "raven-terminal -e "+shellQuote(cleanExec(app.Exec)))
+}
 
+// startApp runs cmd for app, records the launch and closes the menu
+func (m *RavenMenu) startApp(app Application, cmd string) {
 	go func() {
 		exec.Command("sh", "-c", cmd).Start()
 	}()
 
+	// Saved before closing, as the menu exits with its window
+	if err := recordLaunch(app); err != nil {
+		fmt.Fprintf(os.Stderr, "raven-menu: failed to save launch history: %v\n", err)
+	}
+
 	m.window.Close()
 }
 
@@ -478,6 +613,11 @@ func (m *RavenMenu) loadApplications() {
 		"Multimedia":  {Name: "Multimedia", Apps: []Application{}},
 		"Office":      {Name: "Office", Apps: []Application{}},
 		"Other":       {Name: "Other", Apps: []Application{}},
+
+		favoritesCategory: {Name: favoritesCategory, Apps: loadFavorites(m.state)},
+	}
+	for name, cat := range categoryMap {
+		cat.Icon = categoryIcons[name]
 	}
 
 	// Add built-in Raven apps
@@ -529,16 +669,32 @@ func (m *RavenMenu) loadApplications() {
 		}
 	}
 
+	// Kiosk mode lists only the allowed apps
+	if m.kiosk {
+		m.allApps = m.kioskFilter(m.allApps)
+		for _, cat := range categoryMap {
+			cat.Apps = m.kioskFilter(cat.Apps)
+		}
+	}
+
 	// Sort apps
 	sort.Slice(m.allApps, func(i, j int) bool {
 		return strings.ToLower(m.allApps[i].Name) < strings.ToLower(m.allApps[j].Name)
 	})
 
+	// Recent is at the top of the list, when anything was launched yet
+	if recent := loadRecent(m.allApps); len(recent) > 0 {
+		m.categories = append(m.categories, Category{Name: recentCategory, Icon: categoryIcons[recentCategory], Apps: recent})
+	}
+
 	// Build categories list
-	categoryOrder := []string{"All", "System", "Utilities", "Network", "Development", "Graphics", "Multimedia", "Office", "Other"}
+	categoryOrder := []string{"All", favoritesCategory, "System", "Utilities", "Network", "Development", "Graphics", "Multimedia", "Office", "Other"}
 	for _, name := range categoryOrder {
 		if cat, ok := categoryMap[name]; ok {
-			if name == "All" || len(cat.Apps) > 0 {
+			// Favorites is listed even when empty and keeps the dock order
+			if name == favoritesCategory {
+				m.categories = append(m.categories, *cat)
+			} else if name == "All" || len(cat.Apps) > 0 {
 				sort.Slice(cat.Apps, func(i, j int) bool {
 					return strings.ToLower(cat.Apps[i].Name) < strings.ToLower(cat.Apps[j].Name)
 				})
@@ -551,6 +707,21 @@ func (m *RavenMenu) loadApplications() {
 	categoryMap["All"].Apps = m.allApps
 }
 
+// categoryIcons are shown beside the category names
+var categoryIcons = map[string]string{
+	"All":             "view-app-grid",
+	recentCategory:    "document-open-recent",
+	favoritesCategory: "starred",
+	"System":          "applications-system",
+	"Utilities":       "applications-utilities",
+	"Development":     "applications-development",
+	"Network":         "applications-internet",
+	"Graphics":        "applications-graphics",
+	"Multimedia":      "applications-multimedia",
+	"Office":          "applications-office",
+	"Other":           "applications-other",
+}
+
 func mapCategory(cat string) string {
 	cat = strings.ToLower(cat)
 	switch {
@@ -580,9 +751,12 @@ func parseDesktopFile(path string) *Application {
 	}
 	defer file.Close()
 
-	app := &Application{}
+	app := &Application{Path: path}
 	inDesktopEntry := false
 
+	// Name and Comment in every language, picked once the file is read
+	translated := make(map[string]string)
+
 	scanner := bufio.NewScanner(file)
 	for scanner.Scan() {
 		line := strings.TrimSpace(scanner.Text())
@@ -599,14 +773,17 @@ func parseDesktopFile(path string) *Application {
 			continue
 		}
 
-		if strings.HasPrefix(line, "Name=") && app.Name == "" {
-			app.Name = strings.TrimPrefix(line, "Name=")
+		if strings.HasPrefix(line, "Name") || strings.HasPrefix(line, "Comment") {
+			key, value, ok := strings.Cut(line, "=")
+			key = strings.TrimSpace(key)
+			base, _, _ := strings.Cut(key, "[")
+			if _, seen := translated[key]; ok && !seen && (base == "Name" || base == "Comment") {
+				translated[key] = strings.TrimSpace(value)
+			}
 		} else if strings.HasPrefix(line, "Exec=") {
 			app.Exec = strings.TrimPrefix(line, "Exec=")
 		} else if strings.HasPrefix(line, "Icon=") {
 			app.Icon = strings.TrimPrefix(line, "Icon=")
-		} else if strings.HasPrefix(line, "Comment=") && app.Comment == "" {
-			app.Comment = strings.TrimPrefix(line, "Comment=")
 		} else if strings.HasPrefix(line, "Categories=") {
 			app.Category = strings.TrimPrefix(line, "Categories=")
 		} else if line == "NoDisplay=true" || line == "Hidden=true" {
@@ -616,6 +793,8 @@ func parseDesktopFile(path string) *Application {
 		}
 	}
 
+	app.Name = localizedValue(translated, "Name")
+	app.Comment = localizedValue(translated, "Comment")
 	if app.Name == "" || app.Exec == "" {
 		return nil
 	}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// Where the favorites come from
+const (
+	favoritesFromDock = "dock" // The apps pinned to the dock
+	favoritesFromMenu = "menu" // The menu's own list
+)
+
+// menuState is what the menu remembers between launches, kept in
+// ~/.config/raven/menu.json
+type menuState struct {
+	LastCategory    string          `json:"last_category,omitempty"`
+	FavoritesSource string          `json:"favorites_source,omitempty"` // favoritesFromDock (default) or favoritesFromMenu
+	Favorites       []favoriteEntry `json:"favorites,omitempty"`        // Used with favoritesFromMenu
+}
+
+// favoriteEntry is an app in the menu's own favorites
+type favoriteEntry struct {
+	Name    string `json:"name"`
+	Command string `json:"command"`
+	Icon    string `json:"icon"`
+}
+
+func menuStatePath() string {
+	return filepath.Join(os.Getenv("HOME"), ".config", "raven", "menu.json")
+}
+
+// loadMenuState reads menu.json. A missing file is the default state.
+func loadMenuState() menuState {
+	var state menuState
+	data, err := os.ReadFile(menuStatePath())
+	if err != nil {
+		return state
+	}
+	if err := json.Unmarshal(data, &state); err != nil {
+		fmt.Fprintf(os.Stderr, "raven-menu: invalid %s: %v\n", menuStatePath(), err)
+	}
+	return state
+}
+
+// independentFavorites reports whether the favorites are the menu's own
+// rather than the dock's
+func (s menuState) independentFavorites() bool {
+	return s.FavoritesSource == favoritesFromMenu
+}
+
+// saveState writes m.state to menu.json
+func (m *RavenMenu) saveState() {
+	path := menuStatePath()
+	data, err := json.MarshalIndent(m.state, "", "  ")
+	if err == nil {
+		err = os.MkdirAll(filepath.Dir(path), 0755)
+	}
+	if err == nil {
+		err = os.WriteFile(path, data, 0644)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "raven-menu: failed to save %s: %v\n", path, err)
+	}
+}
+
+// rememberCategory records the selected category, which is selected again
+// the next time the menu opens
+func (m *RavenMenu) rememberCategory(name string) {
+	if m.state.LastCategory == name {
+		return
+	}
+	m.state.LastCategory = name
+	m.saveState()
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// recentCategory lists the last launched apps, newest first
+const recentCategory = "Recent"
+
+// How many apps the Recent category shows
+const maxRecentApps = 10
+
+// historyEntry matches an entry in the launch history kept by the
+// raven-desktop fuzzy finder, so both rank apps from the same launches
+type historyEntry struct {
+	Count    int   `json:"count"`
+	LastUsed int64 `json:"last_used"` // Unix seconds
+}
+
+// launchHistoryPath returns ~/.local/share/raven/launch-history.json
+func launchHistoryPath() string {
+	dataHome := os.Getenv("XDG_DATA_HOME")
+	if dataHome == "" {
+		dataHome = filepath.Join(os.Getenv("HOME"), ".local", "share")
+	}
+	return filepath.Join(dataHome, "raven", "launch-history.json")
+}
+
+// readLaunchHistory returns the history entries and every top-level key of
+// the file, so keys the menu doesn't know about are written back untouched
+func readLaunchHistory() (map[string]*historyEntry, map[string]json.RawMessage) {
+	entries := make(map[string]*historyEntry)
+	file := make(map[string]json.RawMessage)
+
+	data, err := os.ReadFile(launchHistoryPath())
+	if err != nil {
+		return entries, file
+	}
+	if err := json.Unmarshal(data, &file); err != nil {
+		fmt.Fprintf(os.Stderr, "raven-menu: invalid launch history: %v\n", err)
+		return entries, make(map[string]json.RawMessage)
+	}
+	if raw, ok := file["entries"]; ok {
+		json.Unmarshal(raw, &entries)
+	}
+	return entries, file
+}
+
+// historyKey identifies an app the same way the fuzzy finder does: by its
+// .desktop file, or by command for apps without one
+func historyKey(app Application) string {
+	if app.Path != "" {
+		return "app:" + app.Path
+	}
+	return "cmd:" + cleanExec(app.Exec)
+}
+
+// recordLaunch adds a launch of app to the shared history
+func recordLaunch(app Application) error {
+	entries, file := readLaunchHistory()
+
+	key := historyKey(app)
+	entry, ok := entries[key]
+	if !ok {
+		entry = &historyEntry{}
+		entries[key] = entry
+	}
+	entry.Count++
+	entry.LastUsed = time.Now().Unix()
+
+	raw, err := json.Marshal(entries)
+	if err != nil {
+		return err
+	}
+	file["entries"] = raw
+	data, err := json.MarshalIndent(file, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	// Write a temp file and rename it, like the finder, so neither ever
+	// reads a half-written history
+	path := launchHistoryPath()
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(dir, ".launch-history-*.json")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return nil
+}
+
+// loadRecent returns the most recently launched of apps, newest first
+func loadRecent(apps []Application) []Application {
+	entries, _ := readLaunchHistory()
+
+	byKey := make(map[string]Application, len(apps))
+	for _, app := range apps {
+		byKey[historyKey(app)] = app
+	}
+
+	keys := make([]string, 0, len(entries))
+	for key := range entries {
+		if _, ok := byKey[key]; ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return entries[keys[i]].LastUsed > entries[keys[j]].LastUsed
+	})
+	if len(keys) > maxRecentApps {
+		keys = keys[:maxRecentApps]
+	}
+
+	recent := make([]Application, 0, len(keys))
+	for _, key := range keys {
+		app := byKey[key]
+		app.Category = recentCategory
+		recent = append(recent, app)
+	}
+	return recent
+}
+
+// resolveApp fills in the .desktop path of apps that only carry a command,
+// like dock favorites, so their launches count for the right history entry
+func (m *RavenMenu) resolveApp(app Application) Application {
+	if app.Path != "" {
+		return app
+	}
+	command := cleanExec(app.Exec)
+	for _, known := range m.allApps {
+		if cleanExec(known.Exec) == command {
+			return known
+		}
+	}
+	return app
+}
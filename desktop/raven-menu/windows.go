@@ -0,0 +1,278 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/diamondburned/gotk4/pkg/gdk/v4"
+	"github.com/diamondburned/gotk4/pkg/gtk/v4"
+
+	"raven-file-manager/pkg/icons"
+	"raven-file-manager/pkg/search"
+)
+
+// hyprClient is a window as reported by hyprctl clients -j
+type hyprClient struct {
+	Address   string `json:"address"`
+	Mapped    bool   `json:"mapped"`
+	Hidden    bool   `json:"hidden"`
+	Workspace struct {
+		ID   int    `json:"id"`
+		Name string `json:"name"`
+	} `json:"workspace"`
+	Class          string `json:"class"`
+	Title          string `json:"title"`
+	PID            int    `json:"pid"`
+	FocusHistoryID int    `json:"focusHistoryID"`
+}
+
+// minimized reports whether the window was minimized by the dock, which
+// parks windows on a special workspace
+func (c hyprClient) minimized() bool {
+	return strings.HasPrefix(c.Workspace.Name, "special:")
+}
+
+// listWindows returns the open windows, most recently focused first
+func listWindows() ([]hyprClient, error) {
+	output, err := exec.Command("hyprctl", "clients", "-j").Output()
+	if err != nil {
+		return nil, fmt.Errorf("hyprctl clients: %w", err)
+	}
+	var clients []hyprClient
+	if err := json.Unmarshal(output, &clients); err != nil {
+		return nil, err
+	}
+
+	var windows []hyprClient
+	for _, c := range clients {
+		if !c.Mapped || c.Hidden || c.Class == "raven-menu" {
+			continue
+		}
+		windows = append(windows, c)
+	}
+	sort.SliceStable(windows, func(i, j int) bool {
+		return windows[i].FocusHistoryID < windows[j].FocusHistoryID
+	})
+	return windows, nil
+}
+
+// activateSwitcher opens the window switcher: the open windows with a
+// fuzzy search, where Enter focuses the selected one
+func (m *RavenMenu) activateSwitcher() {
+	m.window = gtk.NewWindow()
+	m.window.SetTitle("Raven Windows")
+	m.window.SetDefaultSize(520, 420)
+	m.window.SetDecorated(false)
+
+	// The apps are only used to find window icons
+	m.loadApplications()
+	m.applyCSS()
+
+	windows, err := listWindows()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "raven-menu: %v\n", err)
+	}
+	m.allWindows = windows
+
+	m.window.SetChild(m.createSwitcherUI())
+	m.setupSwitcherKeys()
+	m.initSwitcherLayerShell()
+
+	m.window.SetApplication(m.app)
+	m.window.Present()
+	m.searchEntry.GrabFocus()
+}
+
+func (m *RavenMenu) createSwitcherUI() *gtk.Box {
+	mainBox := gtk.NewBox(gtk.OrientationVertical, 0)
+
+	header := gtk.NewBox(gtk.OrientationHorizontal, 8)
+	header.AddCSSClass("menu-header")
+	header.Append(gtk.NewLabel("Windows"))
+	mainBox.Append(header)
+
+	searchBox := gtk.NewBox(gtk.OrientationHorizontal, 0)
+	searchBox.SetMarginStart(8)
+	searchBox.SetMarginEnd(8)
+	searchBox.SetMarginTop(8)
+	searchBox.SetMarginBottom(8)
+
+	m.searchEntry = gtk.NewEntry()
+	m.searchEntry.SetPlaceholderText("Search windows...")
+	m.searchEntry.SetHExpand(true)
+	m.searchEntry.ConnectChanged(func() {
+		m.filterWindows(m.searchEntry.Text())
+	})
+	searchBox.Append(m.searchEntry)
+	mainBox.Append(searchBox)
+
+	m.appScroll = gtk.NewScrolledWindow()
+	m.appScroll.SetPolicy(gtk.PolicyNever, gtk.PolicyAutomatic)
+	m.appScroll.SetVExpand(true)
+
+	m.appList = gtk.NewListBox()
+	m.appList.AddCSSClass("app-list")
+	m.appList.SetSelectionMode(gtk.SelectionSingle)
+	m.appList.ConnectRowActivated(func(row *gtk.ListBoxRow) {
+		idx := row.Index()
+		if idx >= 0 && idx < len(m.shownWindows) {
+			m.focusWindow(m.shownWindows[idx])
+		}
+	})
+	m.appScroll.SetChild(m.appList)
+	mainBox.Append(m.appScroll)
+
+	m.filterWindows("")
+	return mainBox
+}
+
+// setupSwitcherKeys lets the switcher be driven from the search entry:
+// Enter focuses the selected window, Up/Down and Tab/Shift+Tab move the
+// selection
+func (m *RavenMenu) setupSwitcherKeys() {
+	m.searchEntry.ConnectActivate(func() {
+		idx := 0
+		if row := m.appList.SelectedRow(); row != nil {
+			idx = row.Index()
+		}
+		if idx >= 0 && idx < len(m.shownWindows) {
+			m.focusWindow(m.shownWindows[idx])
+		}
+	})
+
+	keys := gtk.NewEventControllerKey()
+	keys.SetPropagationPhase(gtk.PhaseCapture)
+	keys.ConnectKeyPressed(func(keyval, keycode uint, state gdk.ModifierType) bool {
+		switch keyval {
+		case gdk.KEY_Down, gdk.KEY_Tab:
+			m.moveAppSelection(1)
+			return true
+		case gdk.KEY_Up, gdk.KEY_ISO_Left_Tab:
+			m.moveAppSelection(-1)
+			return true
+		case gdk.KEY_Escape:
+			m.window.Close()
+			return true
+		}
+		return false
+	})
+	m.window.AddController(keys)
+}
+
+// filterWindows lists the windows matching query, best match first. With
+// no query they stay in focus order and the previous window is selected,
+// so Enter switches back to it like Alt+Tab.
+func (m *RavenMenu) filterWindows(query string) {
+	for row := m.appList.RowAtIndex(0); row != nil; row = m.appList.RowAtIndex(0) {
+		m.appList.Remove(row)
+	}
+
+	query = strings.TrimSpace(query)
+	m.shownWindows = nil
+	if query == "" {
+		m.shownWindows = m.allWindows
+	} else {
+		matcher := search.NewFuzzyMatcher(query)
+		scores := make(map[string]int)
+		for _, w := range m.allWindows {
+			score, _, ok := matcher.Match(w.Title + " " + w.Class)
+			if ok {
+				scores[w.Address] = score
+				m.shownWindows = append(m.shownWindows, w)
+			}
+		}
+		sort.SliceStable(m.shownWindows, func(i, j int) bool {
+			return scores[m.shownWindows[i].Address] > scores[m.shownWindows[j].Address]
+		})
+	}
+
+	for _, w := range m.shownWindows {
+		m.appList.Append(m.createWindowRow(w))
+	}
+
+	selected := 0
+	if query == "" && len(m.shownWindows) > 1 {
+		selected = 1
+	}
+	if row := m.appList.RowAtIndex(selected); row != nil {
+		m.appList.SelectRow(row)
+	}
+}
+
+func (m *RavenMenu) createWindowRow(w hyprClient) *gtk.ListBoxRow {
+	row := gtk.NewListBoxRow()
+
+	rowBox := gtk.NewBox(gtk.OrientationHorizontal, 8)
+	image := icons.NewImage(m.windowIcon(w.Class), 24)
+	image.AddCSSClass("app-icon")
+	rowBox.Append(image)
+
+	box := gtk.NewBox(gtk.OrientationVertical, 2)
+	box.SetMarginTop(4)
+	box.SetMarginBottom(4)
+	rowBox.Append(box)
+
+	title := w.Title
+	if title == "" {
+		title = w.Class
+	}
+	nameLabel := gtk.NewLabel(title)
+	nameLabel.AddCSSClass("app-name")
+	nameLabel.SetHAlign(gtk.AlignStart)
+	nameLabel.SetEllipsize(3) // PANGO_ELLIPSIZE_END
+	box.Append(nameLabel)
+
+	workspace := "Workspace " + w.Workspace.Name
+	if w.minimized() {
+		workspace = "Minimized"
+	}
+	descLabel := gtk.NewLabel(w.Class + " · " + workspace)
+	descLabel.AddCSSClass("app-desc")
+	descLabel.SetHAlign(gtk.AlignStart)
+	descLabel.SetEllipsize(3) // PANGO_ELLIPSIZE_END
+	box.Append(descLabel)
+
+	row.SetChild(rowBox)
+	return row
+}
+
+// windowIcon returns the icon of the app a window class belongs to: the
+// app whose .desktop file or program is named after the class, or else
+// the class itself
+func (m *RavenMenu) windowIcon(class string) string {
+	for _, app := range m.allApps {
+		if app.Icon == "" {
+			continue
+		}
+		desktopID := strings.TrimSuffix(filepath.Base(app.Path), ".desktop")
+		program := ""
+		if fields := strings.Fields(cleanExec(app.Exec)); len(fields) > 0 {
+			program = filepath.Base(fields[0])
+		}
+		if strings.EqualFold(desktopID, class) || strings.EqualFold(program, class) {
+			return app.Icon
+		}
+	}
+	if class == "" {
+		return "application-x-executable"
+	}
+	return strings.ToLower(class)
+}
+
+// focusWindow brings a window forward, first restoring it from the dock's
+// minimized workspace, and closes the switcher
+func (m *RavenMenu) focusWindow(w hyprClient) {
+	batch := "dispatch focuswindow address:" + w.Address
+	if w.minimized() {
+		batch = "dispatch movetoworkspacesilent e+0,address:" + w.Address + " ; " + batch
+	}
+	if err := exec.Command("hyprctl", "--batch", batch).Run(); err != nil {
+		fmt.Fprintf(os.Stderr, "raven-menu: failed to focus %s: %v\n", w.Address, err)
+	}
+	m.window.Close()
+}
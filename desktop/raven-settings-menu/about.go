@@ -0,0 +1,165 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"slices"
+	"strings"
+
+	"github.com/diamondburned/gotk4/pkg/glib/v2"
+	"github.com/diamondburned/gotk4/pkg/gtk/v4"
+)
+
+func (m *RavenSettingsMenu) createAboutPage() *gtk.ScrolledWindow {
+	scroll := gtk.NewScrolledWindow()
+	scroll.SetPolicy(gtk.PolicyNever, gtk.PolicyAutomatic)
+
+	content := gtk.NewBox(gtk.OrientationVertical, 16)
+	content.SetMarginStart(40)
+	content.SetMarginEnd(40)
+	content.SetMarginTop(40)
+	content.SetMarginBottom(40)
+	content.SetHAlign(gtk.AlignCenter)
+
+	// Logo placeholder
+	logo := gtk.NewLabel("RAVEN")
+	logo.AddCSSClass("about-logo")
+	content.Append(logo)
+
+	// Title
+	title := gtk.NewLabel("Raven Linux")
+	title.AddCSSClass("about-title")
+	title.SetMarginTop(16)
+	content.Append(title)
+
+	// Version
+	version := gtk.NewLabel("Version 1.0.0")
+	version.AddCSSClass("about-version")
+	content.Append(version)
+
+	// Description
+	desc := gtk.NewLabel("A modern, lightweight Linux desktop environment\nbuilt with simplicity and performance in mind.")
+	desc.AddCSSClass("about-description")
+	desc.SetJustify(gtk.JustifyCenter)
+	content.Append(desc)
+
+	// System info section
+	infoBox := gtk.NewBox(gtk.OrientationVertical, 8)
+	infoBox.SetMarginTop(32)
+
+	// Get system info
+	hostname, _ := os.Hostname()
+	kernel, _ := exec.Command("uname", "-r").Output()
+
+	addInfoRow := func(box *gtk.Box, label, value string) {
+		row := gtk.NewBox(gtk.OrientationHorizontal, 12)
+		row.SetHAlign(gtk.AlignCenter)
+
+		labelWidget := gtk.NewLabel(label + ":")
+		labelWidget.AddCSSClass("setting-description")
+		row.Append(labelWidget)
+
+		valueWidget := gtk.NewLabel(value)
+		valueWidget.AddCSSClass("setting-label")
+		valueWidget.SetSelectable(true)
+		valueWidget.SetWrap(true)
+		row.Append(valueWidget)
+
+		box.Append(row)
+	}
+
+	addInfoRow(infoBox, "Hostname", hostname)
+	addInfoRow(infoBox, "Kernel", strings.TrimSpace(string(kernel)))
+	addInfoRow(infoBox, "Desktop", "Raven Shell")
+
+	content.Append(infoBox)
+
+	// Hardware, filled in once it is read
+	hardwareTitle := gtk.NewLabel("Hardware")
+	hardwareTitle.AddCSSClass("section-title")
+	hardwareTitle.SetMarginTop(24)
+	content.Append(hardwareTitle)
+
+	loading := gtk.NewLabel("Reading hardware information...")
+	loading.AddCSSClass("setting-description")
+	content.Append(loading)
+
+	go func() {
+		info := readSystemInfo()
+		glib.IdleAdd(func() {
+			content.Remove(loading)
+			hardwareBox := gtk.NewBox(gtk.OrientationVertical, 8)
+			addInfoRow(hardwareBox, "System", info.OS)
+			addInfoRow(hardwareBox, "Processor", info.CPU)
+			addInfoRow(hardwareBox, "Memory", info.Memory)
+			for _, gpu := range info.GPUs {
+				addInfoRow(hardwareBox, "Graphics", gpu)
+			}
+			for _, disk := range info.Disks {
+				addInfoRow(hardwareBox, "Disk", disk)
+			}
+			for _, battery := range info.Batteries {
+				addInfoRow(hardwareBox, "Battery", battery)
+			}
+			addInfoRow(hardwareBox, "Uptime", info.Uptime)
+			content.InsertChildAfter(hardwareBox, hardwareTitle)
+		})
+	}()
+
+	// Diagnostics
+	reportBox := gtk.NewBox(gtk.OrientationVertical, 8)
+	reportBox.SetHAlign(gtk.AlignCenter)
+	reportBox.SetMarginTop(24)
+
+	reportBtn := gtk.NewButton()
+	reportBtn.SetLabel("Copy Diagnostic Report")
+	reportBtn.SetTooltipText("Hardware, service status and recent logs of Raven components, for bug reports. Leaves out the hostname and location.")
+	reportBox.Append(reportBtn)
+
+	reportStatus := gtk.NewLabel("")
+	reportStatus.AddCSSClass("setting-description")
+	reportBox.Append(reportStatus)
+
+	reportBtn.ConnectClicked(func() {
+		reportBtn.SetSensitive(false)
+		reportStatus.SetText("Collecting...")
+
+		// settings.json is read here; the rest runs commands and reads logs
+		m.flushSettings()
+		config := m.readSettingsFile()
+		disabled := slices.Clone(m.settings.DisabledServices)
+		go func() {
+			report := diagnosticReport(readSystemInfo(), config, disabled)
+			glib.IdleAdd(func() {
+				reportBtn.Clipboard().SetText(report)
+				reportBtn.SetSensitive(true)
+				reportStatus.SetText("Copied to the clipboard. Paste it into your bug report.")
+			})
+		}()
+	})
+	content.Append(reportBox)
+
+	// Links section
+	linksBox := gtk.NewBox(gtk.OrientationHorizontal, 16)
+	linksBox.SetHAlign(gtk.AlignCenter)
+	linksBox.SetMarginTop(32)
+
+	websiteBtn := gtk.NewButton()
+	websiteBtn.SetLabel("Website")
+	websiteBtn.ConnectClicked(func() {
+		exec.Command("xdg-open", "https://ravenlinux.org").Start()
+	})
+	linksBox.Append(websiteBtn)
+
+	docsBtn := gtk.NewButton()
+	docsBtn.SetLabel("Documentation")
+	docsBtn.ConnectClicked(func() {
+		exec.Command("xdg-open", "https://docs.ravenlinux.org").Start()
+	})
+	linksBox.Append(docsBtn)
+
+	content.Append(linksBox)
+
+	scroll.SetChild(content)
+	return scroll
+}
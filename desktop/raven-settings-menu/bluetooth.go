@@ -0,0 +1,579 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/diamondburned/gotk4/pkg/glib/v2"
+	"github.com/diamondburned/gotk4/pkg/gtk/v4"
+	"github.com/godbus/dbus/v5"
+)
+
+// How often the Bluetooth page rereads BlueZ while it is shown
+const bluetoothPollSeconds = 2
+
+// bluetoothPage holds the device list and the pairing wizard, which
+// replaces the list while a device is added
+type bluetoothPage struct {
+	m       *RavenSettingsMenu
+	bz      *bluez
+	adapter *btAdapter
+	devices []btDevice
+
+	stack        *gtk.Stack // "devices" or "wizard"
+	message      *gtk.Label
+	power        *gtk.Switch
+	settingPower bool // The switch is being set from BlueZ
+	paired       *gtk.Box
+	pairedKey    string // What the paired list was built from
+	addBtn       *gtk.Button
+	wizard       *gtk.Stack // "scan", "pair" or "done"
+	found        *gtk.ListBox
+	foundKey     string
+	foundDevices []btDevice
+	pairTitle    *gtk.Label
+	pairPrompt   *gtk.Label
+	pairEntry    *gtk.Entry
+	pairConfirm  *gtk.Button
+	pairReject   *gtk.Button
+	doneLabel    *gtk.Label
+	pairing      dbus.ObjectPath   // Device being paired
+	pendingReply chan pairingReply // Answer to the agent's question
+}
+
+// pairingReply answers a pairingRequest
+type pairingReply struct {
+	answer string
+	ok     bool
+}
+
+func (m *RavenSettingsMenu) createBluetoothPage() *gtk.ScrolledWindow {
+	scroll := gtk.NewScrolledWindow()
+	scroll.SetPolicy(gtk.PolicyNever, gtk.PolicyAutomatic)
+
+	content := gtk.NewBox(gtk.OrientationVertical, 16)
+	content.SetMarginStart(20)
+	content.SetMarginEnd(20)
+	content.SetMarginTop(20)
+	content.SetMarginBottom(20)
+	scroll.SetChild(content)
+
+	sectionTitle := gtk.NewLabel("Bluetooth")
+	sectionTitle.AddCSSClass("section-title")
+	sectionTitle.SetHAlign(gtk.AlignStart)
+	content.Append(sectionTitle)
+
+	bz, err := connectBluez()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "raven-settings-menu: bluetooth: %v\n", err)
+		label := gtk.NewLabel("Bluetooth settings need the BlueZ daemon (bluetoothd)")
+		label.AddCSSClass("setting-description")
+		label.SetHAlign(gtk.AlignStart)
+		content.Append(label)
+		return scroll
+	}
+
+	p := &bluetoothPage{m: m, bz: bz}
+
+	p.message = gtk.NewLabel("")
+	p.message.AddCSSClass("setting-description")
+	p.message.SetHAlign(gtk.AlignStart)
+	p.message.SetWrap(true)
+	p.message.SetVisible(false)
+	content.Append(p.message)
+
+	p.stack = gtk.NewStack()
+	p.stack.SetTransitionType(gtk.StackTransitionTypeCrossfade)
+	p.stack.AddNamed(p.createDeviceList(), "devices")
+	p.stack.AddNamed(p.createWizard(), "wizard")
+	content.Append(p.stack)
+
+	p.refresh()
+
+	// Only polled while the page is on screen
+	glib.TimeoutSecondsAdd(bluetoothPollSeconds, func() bool {
+		if scroll.Mapped() {
+			p.refresh()
+		}
+		return true
+	})
+	// Leaving the page ends a scan, but not a pairing in progress
+	scroll.ConnectUnmap(func() {
+		if p.stack.VisibleChildName() == "wizard" && p.wizard.VisibleChildName() == "scan" {
+			p.closeWizard()
+		}
+	})
+
+	return scroll
+}
+
+func (p *bluetoothPage) createDeviceList() *gtk.Box {
+	box := gtk.NewBox(gtk.OrientationVertical, 16)
+
+	p.power = gtk.NewSwitch()
+	p.power.SetVAlign(gtk.AlignCenter)
+	p.power.ConnectStateSet(func(state bool) bool {
+		if p.settingPower || p.adapter == nil {
+			return false
+		}
+		adapter := p.adapter.Path
+		p.do(p.power, func() error {
+			return p.bz.setPowered(adapter, state)
+		})
+		return false
+	})
+	box.Append(p.m.createSettingRow("Bluetooth", "Turn the Bluetooth adapter on or off", p.power))
+
+	devicesTitle := gtk.NewLabel("Devices")
+	devicesTitle.AddCSSClass("section-title")
+	devicesTitle.SetHAlign(gtk.AlignStart)
+	box.Append(devicesTitle)
+
+	p.paired = gtk.NewBox(gtk.OrientationVertical, 0)
+	box.Append(p.paired)
+
+	p.addBtn = gtk.NewButtonWithLabel("Add Device")
+	p.addBtn.ConnectClicked(p.openWizard)
+	box.Append(p.m.createSettingRow("Add Device", "Pair a keyboard, mouse, headset or phone", p.addBtn))
+
+	return box
+}
+
+// createWizard builds the steps of adding a device: pick it from a scan,
+// answer the pairing questions, and see the result
+func (p *bluetoothPage) createWizard() *gtk.Stack {
+	p.wizard = gtk.NewStack()
+	p.wizard.SetTransitionType(gtk.StackTransitionTypeSlideLeftRight)
+
+	// Scan
+	scan := gtk.NewBox(gtk.OrientationVertical, 12)
+	scanTitle := gtk.NewLabel("Add a Device")
+	scanTitle.AddCSSClass("setting-label")
+	scanTitle.SetHAlign(gtk.AlignStart)
+	scan.Append(scanTitle)
+	scanHint := gtk.NewBox(gtk.OrientationHorizontal, 8)
+	spinner := gtk.NewSpinner()
+	spinner.Start()
+	scanHint.Append(spinner)
+	hint := gtk.NewLabel("Put the device in pairing mode. Devices nearby appear below.")
+	hint.AddCSSClass("setting-description")
+	scanHint.Append(hint)
+	scan.Append(scanHint)
+
+	p.found = gtk.NewListBox()
+	p.found.AddCSSClass("bluetooth-found")
+	p.found.SetSelectionMode(gtk.SelectionNone)
+	p.found.ConnectRowActivated(func(row *gtk.ListBoxRow) {
+		if idx := row.Index(); idx >= 0 && idx < len(p.foundDevices) {
+			p.startPairing(p.foundDevices[idx])
+		}
+	})
+	scan.Append(p.found)
+
+	cancelBtn := gtk.NewButtonWithLabel("Cancel")
+	cancelBtn.SetHAlign(gtk.AlignEnd)
+	cancelBtn.ConnectClicked(p.closeWizard)
+	scan.Append(cancelBtn)
+	p.wizard.AddNamed(scan, "scan")
+
+	// Pair
+	pair := gtk.NewBox(gtk.OrientationVertical, 12)
+	pair.AddCSSClass("setting-row")
+	p.pairTitle = gtk.NewLabel("")
+	p.pairTitle.AddCSSClass("setting-label")
+	p.pairTitle.SetHAlign(gtk.AlignStart)
+	pair.Append(p.pairTitle)
+	p.pairPrompt = gtk.NewLabel("")
+	p.pairPrompt.SetHAlign(gtk.AlignStart)
+	p.pairPrompt.SetWrap(true)
+	pair.Append(p.pairPrompt)
+	p.pairEntry = gtk.NewEntry()
+	p.pairEntry.ConnectActivate(func() {
+		p.answer(true)
+	})
+	pair.Append(p.pairEntry)
+	buttons := gtk.NewBox(gtk.OrientationHorizontal, 8)
+	buttons.SetHAlign(gtk.AlignEnd)
+	p.pairReject = gtk.NewButtonWithLabel("Cancel")
+	p.pairReject.ConnectClicked(func() {
+		p.answer(false)
+	})
+	buttons.Append(p.pairReject)
+	p.pairConfirm = gtk.NewButtonWithLabel("Pair")
+	p.pairConfirm.ConnectClicked(func() {
+		p.answer(true)
+	})
+	buttons.Append(p.pairConfirm)
+	pair.Append(buttons)
+	p.wizard.AddNamed(pair, "pair")
+
+	// Done
+	done := gtk.NewBox(gtk.OrientationVertical, 12)
+	done.AddCSSClass("setting-row")
+	p.doneLabel = gtk.NewLabel("")
+	p.doneLabel.SetHAlign(gtk.AlignStart)
+	p.doneLabel.SetWrap(true)
+	done.Append(p.doneLabel)
+	doneBtn := gtk.NewButtonWithLabel("Done")
+	doneBtn.SetHAlign(gtk.AlignEnd)
+	doneBtn.ConnectClicked(p.closeWizard)
+	done.Append(doneBtn)
+	p.wizard.AddNamed(done, "done")
+
+	return p.wizard
+}
+
+// refresh rereads the adapter and devices off the main loop
+func (p *bluetoothPage) refresh() {
+	go func() {
+		adapter, devices, err := p.bz.state()
+		glib.IdleAdd(func() {
+			p.render(adapter, devices, err)
+		})
+	}()
+}
+
+func (p *bluetoothPage) render(adapter *btAdapter, devices []btDevice, err error) {
+	p.adapter = adapter
+	p.devices = devices
+	if err != nil {
+		p.showError(err)
+		return
+	}
+	if adapter == nil {
+		p.message.SetText("No Bluetooth adapter found")
+		p.message.SetVisible(true)
+		p.power.SetSensitive(false)
+		p.addBtn.SetSensitive(false)
+		return
+	}
+
+	p.settingPower = true
+	p.power.SetActive(adapter.Powered)
+	p.settingPower = false
+	p.power.SetSensitive(true)
+	p.addBtn.SetSensitive(adapter.Powered)
+
+	var paired, found []btDevice
+	for _, device := range devices {
+		switch {
+		case device.Paired:
+			paired = append(paired, device)
+		case device.HasRSSI:
+			// Unpaired devices BlueZ remembers are only listed once the
+			// scan sees them again
+			found = append(found, device)
+		}
+	}
+	sort.SliceStable(found, func(i, j int) bool {
+		return deviceName(found[i]) < deviceName(found[j])
+	})
+
+	// Lists are only rebuilt when they change, so rows don't vanish under
+	// the pointer every poll
+	if key := fmt.Sprint(paired); key != p.pairedKey {
+		p.pairedKey = key
+		p.renderPaired(paired)
+	}
+	key := ""
+	for _, device := range found {
+		key += string(device.Path) + deviceName(device) + "\n"
+	}
+	if key != p.foundKey {
+		p.foundKey = key
+		p.renderFound(found)
+	}
+}
+
+// deviceName is the alias of a device, or its address without one
+func deviceName(device btDevice) string {
+	if device.Name != "" {
+		return device.Name
+	}
+	return device.Address
+}
+
+func (p *bluetoothPage) renderPaired(paired []btDevice) {
+	for child := p.paired.FirstChild(); child != nil; child = p.paired.FirstChild() {
+		p.paired.Remove(child)
+	}
+	if len(paired) == 0 {
+		label := gtk.NewLabel("No paired devices")
+		label.AddCSSClass("setting-description")
+		label.SetHAlign(gtk.AlignStart)
+		label.SetMarginBottom(8)
+		p.paired.Append(label)
+		return
+	}
+
+	for _, device := range paired {
+		controls := gtk.NewBox(gtk.OrientationHorizontal, 8)
+
+		connectBtn := gtk.NewButtonWithLabel("Connect")
+		if device.Connected {
+			connectBtn.SetLabel("Disconnect")
+		}
+		connectBtn.SetVAlign(gtk.AlignCenter)
+		connectBtn.ConnectClicked(func() {
+			p.do(connectBtn, func() error {
+				if device.Connected {
+					return p.bz.disconnect(device.Path)
+				}
+				return p.bz.connect(device.Path)
+			})
+		})
+		controls.Append(connectBtn)
+
+		trust := gtk.NewCheckButtonWithLabel("Trusted")
+		trust.SetActive(device.Trusted)
+		trust.SetVAlign(gtk.AlignCenter)
+		trust.SetTooltipText("Trusted devices may connect by themselves")
+		trust.ConnectToggled(func() {
+			trusted := trust.Active()
+			p.do(trust, func() error {
+				return p.bz.setTrusted(device.Path, trusted)
+			})
+		})
+		controls.Append(trust)
+
+		removeBtn := gtk.NewButtonWithLabel("Remove")
+		removeBtn.SetVAlign(gtk.AlignCenter)
+		removeBtn.ConnectClicked(func() {
+			if p.adapter == nil {
+				return
+			}
+			adapter := p.adapter.Path
+			p.do(removeBtn, func() error {
+				return p.bz.removeDevice(adapter, device.Path)
+			})
+		})
+		controls.Append(removeBtn)
+
+		p.paired.Append(p.m.createSettingRow(deviceName(device), deviceSummary(device), controls))
+	}
+}
+
+// deviceSummary is the line under a paired device, e.g.
+// "Connected · Battery 80%"
+func deviceSummary(device btDevice) string {
+	parts := []string{"Not connected"}
+	if device.Connected {
+		parts[0] = "Connected"
+	}
+	if device.Battery >= 0 {
+		parts = append(parts, fmt.Sprintf("Battery %d%%", device.Battery))
+	}
+	return strings.Join(parts, " · ")
+}
+
+func (p *bluetoothPage) renderFound(found []btDevice) {
+	p.foundDevices = found
+	for row := p.found.RowAtIndex(0); row != nil; row = p.found.RowAtIndex(0) {
+		p.found.Remove(row)
+	}
+
+	for _, device := range found {
+		row := gtk.NewBox(gtk.OrientationHorizontal, 12)
+		row.SetMarginTop(6)
+		row.SetMarginBottom(6)
+		icon := device.Icon
+		if icon == "" {
+			icon = "bluetooth"
+		}
+		image := gtk.NewImageFromIconName(icon)
+		image.SetPixelSize(24)
+		row.Append(image)
+
+		labels := gtk.NewBox(gtk.OrientationVertical, 2)
+		name := gtk.NewLabel(deviceName(device))
+		name.AddCSSClass("setting-label")
+		name.SetHAlign(gtk.AlignStart)
+		labels.Append(name)
+		address := gtk.NewLabel(device.Address)
+		address.AddCSSClass("setting-description")
+		address.SetHAlign(gtk.AlignStart)
+		labels.Append(address)
+		row.Append(labels)
+
+		p.found.Append(row)
+	}
+}
+
+// showError reports a failed request at the top of the page
+func (p *bluetoothPage) showError(err error) {
+	fmt.Fprintf(os.Stderr, "raven-settings-menu: bluetooth: %v\n", err)
+	p.message.SetText(bluezError(err))
+	p.message.SetVisible(true)
+}
+
+// do runs a BlueZ request off the main loop with widget disabled, then
+// rereads the devices
+func (p *bluetoothPage) do(widget gtk.Widgetter, request func() error) {
+	gtk.BaseWidget(widget).SetSensitive(false)
+	p.message.SetVisible(false)
+	go func() {
+		err := request()
+		glib.IdleAdd(func() {
+			gtk.BaseWidget(widget).SetSensitive(true)
+			if err != nil {
+				p.showError(err)
+			}
+			p.refresh()
+		})
+	}()
+}
+
+// openWizard shows the wizard and starts scanning. The agent answers
+// pairing requests through the wizard while it is open.
+func (p *bluetoothPage) openWizard() {
+	if p.adapter == nil {
+		return
+	}
+	p.foundKey = ""
+	p.renderFound(nil)
+	p.wizard.SetVisibleChildName("scan")
+	p.stack.SetVisibleChildName("wizard")
+	p.bz.agent.setPrompt(p.promptPairing, p.cancelPrompt)
+
+	adapter := p.adapter.Path
+	go func() {
+		if err := p.bz.startDiscovery(adapter); err != nil {
+			glib.IdleAdd(func() {
+				p.showError(err)
+			})
+		}
+	}()
+}
+
+// closeWizard stops the scan and returns to the device list
+func (p *bluetoothPage) closeWizard() {
+	p.bz.agent.setPrompt(nil, nil)
+	p.stack.SetVisibleChildName("devices")
+	if p.adapter != nil {
+		adapter := p.adapter.Path
+		go p.bz.stopDiscovery(adapter)
+	}
+	p.refresh()
+}
+
+// startPairing pairs with the picked device, then trusts and connects it
+func (p *bluetoothPage) startPairing(device btDevice) {
+	p.pairing = device.Path
+	p.pairTitle.SetText("Pairing with " + deviceName(device))
+	p.showWaiting()
+	p.wizard.SetVisibleChildName("pair")
+
+	adapter := p.adapter.Path
+	go func() {
+		// Pairing is more reliable once the scan has stopped
+		p.bz.stopDiscovery(adapter)
+
+		err := p.bz.pair(device.Path)
+		message := deviceName(device) + " is paired and connected."
+		if err != nil {
+			message = "Pairing with " + deviceName(device) + " failed: " + bluezError(err)
+		} else {
+			p.bz.setTrusted(device.Path, true)
+			if err := p.bz.connect(device.Path); err != nil {
+				message = deviceName(device) + " is paired, but could not connect: " + bluezError(err)
+			}
+		}
+		glib.IdleAdd(func() {
+			p.pairing = ""
+			p.doneLabel.SetText(message)
+			p.wizard.SetVisibleChildName("done")
+		})
+	}()
+}
+
+// showWaiting resets the pair step to waiting on the device
+func (p *bluetoothPage) showWaiting() {
+	p.pairPrompt.SetText("Waiting for the device...")
+	p.pairEntry.SetVisible(false)
+	p.pairConfirm.SetVisible(false)
+	p.pairReject.SetLabel("Cancel")
+}
+
+// promptPairing asks the user a pairing question from the agent. It is
+// called on the D-Bus goroutine and waits for the answer.
+func (p *bluetoothPage) promptPairing(req pairingRequest) (string, bool) {
+	reply := make(chan pairingReply, 1)
+	glib.IdleAdd(func() {
+		p.showPrompt(req, reply)
+	})
+	if req.Kind == "display" {
+		return "", true
+	}
+	r := <-reply
+	return r.answer, r.ok
+}
+
+// cancelPrompt withdraws the question BlueZ asked, e.g. after a timeout
+func (p *bluetoothPage) cancelPrompt() {
+	glib.IdleAdd(func() {
+		if p.pendingReply != nil {
+			p.pendingReply <- pairingReply{}
+			p.pendingReply = nil
+		}
+		p.showWaiting()
+	})
+}
+
+func (p *bluetoothPage) showPrompt(req pairingRequest, reply chan pairingReply) {
+	name := "the device"
+	for _, device := range p.devices {
+		if device.Path == req.Device {
+			name = deviceName(device)
+		}
+	}
+
+	p.pairEntry.SetText("")
+	p.pairEntry.SetVisible(req.Kind == "pin" || req.Kind == "passkey")
+	p.pairConfirm.SetVisible(req.Kind != "display")
+	p.pairReject.SetLabel("Cancel")
+	switch req.Kind {
+	case "pin":
+		p.pairPrompt.SetText("Enter the PIN for " + name + ". Many devices use 0000 or 1234.")
+		p.pairEntry.SetPlaceholderText("PIN")
+		p.pairConfirm.SetLabel("Pair")
+	case "passkey":
+		p.pairPrompt.SetText("Enter the 6-digit passkey shown on " + name + ".")
+		p.pairEntry.SetPlaceholderText("Passkey")
+		p.pairConfirm.SetLabel("Pair")
+	case "confirm":
+		p.pairPrompt.SetText("Does " + name + " show the passkey " + req.Code + "?")
+		p.pairConfirm.SetLabel("Yes")
+		p.pairReject.SetLabel("No")
+	case "display":
+		p.pairPrompt.SetText("Type " + req.Code + " on " + name + ", then press Enter.")
+	default:
+		p.pairPrompt.SetText("Allow " + name + " to pair?")
+		p.pairConfirm.SetLabel("Allow")
+		p.pairReject.SetLabel("Deny")
+	}
+
+	if req.Kind == "display" {
+		return
+	}
+	p.pendingReply = reply
+	if p.pairEntry.Visible() {
+		p.pairEntry.GrabFocus()
+	}
+}
+
+// answer replies to the agent's question, or cancels the pairing when
+// nothing was asked
+func (p *bluetoothPage) answer(ok bool) {
+	if p.pendingReply == nil {
+		if !ok && p.pairing != "" {
+			device := p.pairing
+			go p.bz.cancelPairing(device)
+		}
+		return
+	}
+	p.pendingReply <- pairingReply{answer: strings.TrimSpace(p.pairEntry.Text()), ok: ok}
+	p.pendingReply = nil
+	p.showWaiting()
+}
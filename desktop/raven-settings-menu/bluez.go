@@ -0,0 +1,307 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/godbus/dbus/v5"
+)
+
+// BlueZ D-Bus names
+const (
+	bluezService      = "org.bluez"
+	bluezAdapter      = "org.bluez.Adapter1"
+	bluezDevice       = "org.bluez.Device1"
+	bluezBattery      = "org.bluez.Battery1"
+	bluezAgentManager = "org.bluez.AgentManager1"
+	bluezAgent        = "org.bluez.Agent1"
+)
+
+// Where the pairing agent is exported on the system bus
+const bluezAgentPath = dbus.ObjectPath("/org/ravenlinux/settings/agent")
+
+// btAdapter is a Bluetooth controller
+type btAdapter struct {
+	Path        dbus.ObjectPath
+	Name        string
+	Address     string
+	Powered     bool
+	Discovering bool
+}
+
+// btDevice is a device BlueZ knows, paired or seen during a scan
+type btDevice struct {
+	Path      dbus.ObjectPath
+	Name      string
+	Address   string
+	Icon      string // freedesktop icon name, e.g. "audio-headset"
+	Paired    bool
+	Trusted   bool
+	Connected bool
+	Battery   int // Percent, or -1 when the device doesn't report it
+	RSSI      int16
+	HasRSSI   bool // Seen in the current scan
+}
+
+// bluez talks to the BlueZ daemon on the system bus
+type bluez struct {
+	conn  *dbus.Conn
+	agent *btAgent
+}
+
+// connectBluez connects to the system bus and registers the pairing agent
+func connectBluez() (*bluez, error) {
+	conn, err := dbus.ConnectSystemBus()
+	if err != nil {
+		return nil, err
+	}
+	b := &bluez{conn: conn, agent: &btAgent{}}
+
+	if err := conn.Export(b.agent, bluezAgentPath, bluezAgent); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	manager := conn.Object(bluezService, "/org/bluez")
+	if err := manager.Call(bluezAgentManager+".RegisterAgent", 0, bluezAgentPath, "KeyboardDisplay").Err; err != nil {
+		conn.Close()
+		return nil, err
+	}
+	manager.Call(bluezAgentManager+".RequestDefaultAgent", 0, bluezAgentPath)
+	return b, nil
+}
+
+// state returns the first adapter and the devices it knows. The adapter
+// is nil when there is no Bluetooth controller.
+func (b *bluez) state() (*btAdapter, []btDevice, error) {
+	var objects map[dbus.ObjectPath]map[string]map[string]dbus.Variant
+	err := b.conn.Object(bluezService, "/").Call("org.freedesktop.DBus.ObjectManager.GetManagedObjects", 0).Store(&objects)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var adapter *btAdapter
+	var paths []dbus.ObjectPath
+	for path := range objects {
+		paths = append(paths, path)
+	}
+	sort.Slice(paths, func(i, j int) bool { return paths[i] < paths[j] })
+	for _, path := range paths {
+		props, ok := objects[path][bluezAdapter]
+		if !ok {
+			continue
+		}
+		adapter = &btAdapter{
+			Path:        path,
+			Name:        variantString(props["Alias"]),
+			Address:     variantString(props["Address"]),
+			Powered:     variantBool(props["Powered"]),
+			Discovering: variantBool(props["Discovering"]),
+		}
+		break
+	}
+	if adapter == nil {
+		return nil, nil, nil
+	}
+
+	var devices []btDevice
+	for _, path := range paths {
+		props, ok := objects[path][bluezDevice]
+		if !ok {
+			continue
+		}
+		if adapterPath, _ := props["Adapter"].Value().(dbus.ObjectPath); adapterPath != adapter.Path {
+			continue
+		}
+		device := btDevice{
+			Path:      path,
+			Name:      variantString(props["Alias"]),
+			Address:   variantString(props["Address"]),
+			Icon:      variantString(props["Icon"]),
+			Paired:    variantBool(props["Paired"]),
+			Trusted:   variantBool(props["Trusted"]),
+			Connected: variantBool(props["Connected"]),
+			Battery:   -1,
+		}
+		if rssi, ok := props["RSSI"].Value().(int16); ok {
+			device.RSSI = rssi
+			device.HasRSSI = true
+		}
+		if battery, ok := objects[path][bluezBattery]; ok {
+			if percent, ok := battery["Percentage"].Value().(byte); ok {
+				device.Battery = int(percent)
+			}
+		}
+		devices = append(devices, device)
+	}
+	return adapter, devices, nil
+}
+
+func variantString(v dbus.Variant) string {
+	s, _ := v.Value().(string)
+	return s
+}
+
+func variantBool(v dbus.Variant) bool {
+	b, _ := v.Value().(bool)
+	return b
+}
+
+func (b *bluez) setPowered(adapter dbus.ObjectPath, on bool) error {
+	return b.conn.Object(bluezService, adapter).SetProperty(bluezAdapter+".Powered", dbus.MakeVariant(on))
+}
+
+func (b *bluez) startDiscovery(adapter dbus.ObjectPath) error {
+	return b.conn.Object(bluezService, adapter).Call(bluezAdapter+".StartDiscovery", 0).Err
+}
+
+func (b *bluez) stopDiscovery(adapter dbus.ObjectPath) error {
+	return b.conn.Object(bluezService, adapter).Call(bluezAdapter+".StopDiscovery", 0).Err
+}
+
+// removeDevice unpairs a device and forgets it
+func (b *bluez) removeDevice(adapter, device dbus.ObjectPath) error {
+	return b.conn.Object(bluezService, adapter).Call(bluezAdapter+".RemoveDevice", 0, device).Err
+}
+
+// pair pairs with a device; the agent is asked for any PIN or passkey
+func (b *bluez) pair(device dbus.ObjectPath) error {
+	return b.conn.Object(bluezService, device).Call(bluezDevice+".Pair", 0).Err
+}
+
+func (b *bluez) cancelPairing(device dbus.ObjectPath) error {
+	return b.conn.Object(bluezService, device).Call(bluezDevice+".CancelPairing", 0).Err
+}
+
+func (b *bluez) connect(device dbus.ObjectPath) error {
+	return b.conn.Object(bluezService, device).Call(bluezDevice+".Connect", 0).Err
+}
+
+func (b *bluez) disconnect(device dbus.ObjectPath) error {
+	return b.conn.Object(bluezService, device).Call(bluezDevice+".Disconnect", 0).Err
+}
+
+func (b *bluez) setTrusted(device dbus.ObjectPath, trusted bool) error {
+	return b.conn.Object(bluezService, device).SetProperty(bluezDevice+".Trusted", dbus.MakeVariant(trusted))
+}
+
+// pairingRequest is a question or notice from BlueZ while pairing
+type pairingRequest struct {
+	Kind   string // "pin", "passkey", "confirm", "display" or "authorize"
+	Device dbus.ObjectPath
+	Code   string // Passkey or PIN to confirm or display
+}
+
+// btAgent answers BlueZ's pairing requests (org.bluez.Agent1). Requests
+// are passed to prompt, which blocks until the user answers; without a
+// prompt, pairing is refused. BlueZ calls the agent on the D-Bus
+// goroutine.
+type btAgent struct {
+	mu     sync.Mutex
+	prompt func(req pairingRequest) (string, bool)
+	cancel func()
+}
+
+var errRejected = dbus.NewError("org.bluez.Error.Rejected", nil)
+
+// setPrompt sets the function asked for PINs and confirmations, and the
+// one called when BlueZ cancels a request
+func (a *btAgent) setPrompt(prompt func(req pairingRequest) (string, bool), cancel func()) {
+	a.mu.Lock()
+	a.prompt = prompt
+	a.cancel = cancel
+	a.mu.Unlock()
+}
+
+func (a *btAgent) ask(req pairingRequest) (string, bool) {
+	a.mu.Lock()
+	prompt := a.prompt
+	a.mu.Unlock()
+	if prompt == nil {
+		return "", false
+	}
+	return prompt(req)
+}
+
+func (a *btAgent) Release() *dbus.Error {
+	return nil
+}
+
+func (a *btAgent) RequestPinCode(device dbus.ObjectPath) (string, *dbus.Error) {
+	pin, ok := a.ask(pairingRequest{Kind: "pin", Device: device})
+	if !ok {
+		return "", errRejected
+	}
+	return pin, nil
+}
+
+func (a *btAgent) DisplayPinCode(device dbus.ObjectPath, pincode string) *dbus.Error {
+	a.ask(pairingRequest{Kind: "display", Device: device, Code: pincode})
+	return nil
+}
+
+func (a *btAgent) RequestPasskey(device dbus.ObjectPath) (uint32, *dbus.Error) {
+	answer, ok := a.ask(pairingRequest{Kind: "passkey", Device: device})
+	if !ok {
+		return 0, errRejected
+	}
+	passkey, err := strconv.ParseUint(answer, 10, 32)
+	if err != nil || passkey > 999999 {
+		return 0, errRejected
+	}
+	return uint32(passkey), nil
+}
+
+func (a *btAgent) DisplayPasskey(device dbus.ObjectPath, passkey uint32, entered uint16) *dbus.Error {
+	a.ask(pairingRequest{Kind: "display", Device: device, Code: formatPasskey(passkey)})
+	return nil
+}
+
+func (a *btAgent) RequestConfirmation(device dbus.ObjectPath, passkey uint32) *dbus.Error {
+	if _, ok := a.ask(pairingRequest{Kind: "confirm", Device: device, Code: formatPasskey(passkey)}); !ok {
+		return errRejected
+	}
+	return nil
+}
+
+func (a *btAgent) RequestAuthorization(device dbus.ObjectPath) *dbus.Error {
+	if _, ok := a.ask(pairingRequest{Kind: "authorize", Device: device}); !ok {
+		return errRejected
+	}
+	return nil
+}
+
+// AuthorizeService is asked when a paired device that isn't trusted
+// connects. Only trusted devices may, so it is refused.
+func (a *btAgent) AuthorizeService(device dbus.ObjectPath, uuid string) *dbus.Error {
+	return errRejected
+}
+
+func (a *btAgent) Cancel() *dbus.Error {
+	a.mu.Lock()
+	cancel := a.cancel
+	a.mu.Unlock()
+	if cancel != nil {
+		cancel()
+	}
+	return nil
+}
+
+// formatPasskey shows a passkey as the six digits devices display
+func formatPasskey(passkey uint32) string {
+	return fmt.Sprintf("%06d", passkey)
+}
+
+// bluezError returns the message of a BlueZ error, e.g. "Page Timeout"
+// for org.bluez.Error.Failed
+func bluezError(err error) string {
+	var dbusErr dbus.Error
+	if errors.As(err, &dbusErr) && len(dbusErr.Body) > 0 {
+		if msg, ok := dbusErr.Body[0].(string); ok && msg != "" {
+			return msg
+		}
+	}
+	return err.Error()
+}
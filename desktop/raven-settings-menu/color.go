@@ -0,0 +1,424 @@
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/diamondburned/gotk4/pkg/glib/v2"
+	"github.com/diamondburned/gotk4/pkg/gtk/v4"
+)
+
+// Range of the night light temperature, in Kelvin; 6500K is daylight
+const (
+	minNightLightTemperature = 2500
+	maxNightLightTemperature = 6500
+)
+
+// Seconds a temperature stays on screen after dragging the slider while
+// night light is off
+const nightLightPreviewSeconds = 3
+
+// Directories searched for ICC color profiles, besides ~/.local/share/icc
+var iccDirs = []string{"/usr/share/color/icc", "/usr/local/share/color/icc", "/var/lib/color/icc"}
+
+// hyprsunsetPath returns hyprsunset's config, which it reads at start
+func hyprsunsetPath() string {
+	return filepath.Join(configHome(), "hypr", "hyprsunset.conf")
+}
+
+// writeNightLightConfig writes the night light for hyprsunset: one profile
+// from midnight, so it holds all day
+func writeNightLightConfig(enabled bool, temperature int) error {
+	var b strings.Builder
+	b.WriteString("# Written by raven-settings-menu (Displays)\nprofile {\n    time = 00:00\n")
+	if enabled {
+		fmt.Fprintf(&b, "    temperature = %d\n", temperature)
+	} else {
+		b.WriteString("    identity = true\n")
+	}
+	b.WriteString("}\n")
+
+	path := hyprsunsetPath()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, []byte(b.String()), 0644)
+}
+
+// setScreenTemperature tints the screen now through hyprsunset, which is
+// started if it isn't running. 0 turns the tint off.
+func setScreenTemperature(temperature int) error {
+	args := []string{"hyprsunset", "identity"}
+	if temperature > 0 {
+		args = []string{"hyprsunset", "temperature", strconv.Itoa(temperature)}
+	}
+	err := runQuiet("hyprctl", args...)
+	if err == nil {
+		return nil
+	}
+
+	s, ok := findService("night-light")
+	if !ok || !s.installed() {
+		return fmt.Errorf("night light needs hyprsunset")
+	}
+	if len(s.pids()) > 0 {
+		return err
+	}
+	if err := s.restart(); err != nil {
+		return err
+	}
+	// Give it time to open its socket
+	time.Sleep(500 * time.Millisecond)
+	return runQuiet("hyprctl", args...)
+}
+
+// listICCProfiles returns the ICC profiles installed for the system and
+// the user, sorted by file name
+func listICCProfiles() []string {
+	dirs := append([]string{filepath.Join(os.Getenv("HOME"), ".local", "share", "icc")}, iccDirs...)
+	var profiles []string
+	for _, dir := range dirs {
+		filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return nil
+			}
+			ext := strings.ToLower(filepath.Ext(path))
+			if !d.IsDir() && (ext == ".icc" || ext == ".icm") {
+				profiles = append(profiles, path)
+			}
+			return nil
+		})
+	}
+	slices.SortFunc(profiles, func(a, b string) int {
+		return strings.Compare(strings.ToLower(filepath.Base(a)), strings.ToLower(filepath.Base(b)))
+	})
+	return profiles
+}
+
+// createColorView builds the color sub-page: night light, a color profile
+// for each display and the brightness of external monitors
+func (p *displaysPage) createColorView() *gtk.Box {
+	box := gtk.NewBox(gtk.OrientationVertical, 16)
+
+	header := gtk.NewBox(gtk.OrientationHorizontal, 8)
+	backBtn := gtk.NewButtonFromIconName("go-previous-symbolic")
+	backBtn.SetTooltipText("Back to Layout")
+	backBtn.ConnectClicked(func() {
+		p.stack.SetVisibleChildName("layout")
+	})
+	header.Append(backBtn)
+	title := gtk.NewLabel("Color and Brightness")
+	title.AddCSSClass("setting-label")
+	header.Append(title)
+	box.Append(header)
+
+	p.colorMessage = gtk.NewLabel("")
+	p.colorMessage.AddCSSClass("setting-description")
+	p.colorMessage.SetHAlign(gtk.AlignStart)
+	p.colorMessage.SetWrap(true)
+	p.colorMessage.SetVisible(false)
+	box.Append(p.colorMessage)
+
+	p.appendNightLight(box)
+
+	profilesTitle := gtk.NewLabel("Color Profiles")
+	profilesTitle.AddCSSClass("section-title")
+	profilesTitle.SetHAlign(gtk.AlignStart)
+	box.Append(profilesTitle)
+	profiles := listICCProfiles()
+	for i := range p.monitors {
+		box.Append(p.createICCRow(p.monitors[i].Name, p.monitors[i].Description, profiles))
+	}
+
+	brightnessTitle := gtk.NewLabel("Brightness")
+	brightnessTitle.AddCSSClass("section-title")
+	brightnessTitle.SetHAlign(gtk.AlignStart)
+	box.Append(brightnessTitle)
+	brightness := gtk.NewBox(gtk.OrientationVertical, 0)
+	box.Append(brightness)
+	p.loadBrightness(brightness)
+
+	return box
+}
+
+// appendNightLight adds the night light switch and its temperature.
+// Dragging the temperature shows it on screen, briefly when night light is
+// off.
+func (p *displaysPage) appendNightLight(box *gtk.Box) {
+	m := p.m
+	temperature := func() int {
+		if m.settings.NightLightTemperature <= 0 {
+			return 4500
+		}
+		return m.settings.NightLightTemperature
+	}
+	screen := &coalescer{send: setScreenTemperature, failed: p.showColorError}
+	apply := func(shown int) {
+		if err := writeNightLightConfig(m.settings.NightLight, temperature()); err != nil {
+			p.showColorError(err)
+		}
+		screen.set(shown)
+	}
+	// current is what night light shows when not previewing
+	current := func() int {
+		if m.settings.NightLight {
+			return temperature()
+		}
+		return 0
+	}
+
+	nightSwitch := gtk.NewSwitch()
+	nightSwitch.SetActive(m.settings.NightLight)
+	nightSwitch.ConnectStateSet(func(state bool) bool {
+		m.settings.NightLight = state
+		m.saveSettings()
+		p.endPreview()
+		apply(current())
+		return false
+	})
+	box.Append(m.createSettingRow("Night Light", "Warm the screen's colors to reduce blue light", nightSwitch))
+
+	tempScale := gtk.NewScaleWithRange(gtk.OrientationHorizontal, minNightLightTemperature, maxNightLightTemperature, 100)
+	tempScale.SetSizeRequest(200, -1)
+	tempScale.SetDrawValue(true)
+	tempScale.SetValue(float64(temperature()))
+	tempScale.ConnectValueChanged(func() {
+		m.settings.NightLightTemperature = int(tempScale.Value())
+		m.saveSettings()
+		apply(temperature())
+
+		if m.settings.NightLight {
+			return
+		}
+		p.endPreview()
+		p.previewEnd = glib.TimeoutSecondsAdd(nightLightPreviewSeconds, func() bool {
+			p.previewEnd = 0
+			apply(current())
+			return false
+		})
+	})
+	box.Append(m.createSettingRow("Color Temperature", "Lower is warmer; 6500K leaves colors as they are", tempScale))
+}
+
+func (p *displaysPage) endPreview() {
+	if p.previewEnd != 0 {
+		glib.SourceRemove(p.previewEnd)
+		p.previewEnd = 0
+	}
+}
+
+// createICCRow lets a profile be picked for an output, from the installed
+// ones or a file
+func (p *displaysPage) createICCRow(output, description string, profiles []string) *gtk.Box {
+	assigned := p.m.settings.ICCProfiles[output]
+	if assigned != "" && !slices.Contains(profiles, assigned) {
+		profiles = append([]string{assigned}, profiles...)
+	}
+	labels := []string{"None"}
+	for _, profile := range profiles {
+		labels = append(labels, filepath.Base(profile))
+	}
+
+	controls := gtk.NewBox(gtk.OrientationHorizontal, 8)
+	dropdown := gtk.NewDropDown(gtk.NewStringList(labels), nil)
+	if i := slices.Index(profiles, assigned); i >= 0 {
+		dropdown.SetSelected(uint(i + 1))
+	}
+	dropdown.Connect("notify::selected", func() {
+		idx := int(dropdown.Selected())
+		if idx == 0 {
+			p.setICCProfile(output, "")
+		} else if idx <= len(profiles) {
+			p.setICCProfile(output, profiles[idx-1])
+		}
+	})
+	controls.Append(dropdown)
+
+	browseBtn := gtk.NewButtonWithLabel("Browse...")
+	browseBtn.ConnectClicked(func() {
+		dialog := gtk.NewFileChooserNative(
+			"Choose Color Profile",
+			p.m.window,
+			gtk.FileChooserActionOpen,
+			"Choose",
+			"Cancel",
+		)
+		filter := gtk.NewFileFilter()
+		filter.SetName("ICC Profiles")
+		filter.AddPattern("*.icc")
+		filter.AddPattern("*.icm")
+		dialog.AddFilter(filter)
+		dialog.ConnectResponse(func(response int) {
+			if response != int(gtk.ResponseAccept) {
+				return
+			}
+			if file := dialog.File(); file != nil && file.Path() != "" {
+				p.setICCProfile(output, file.Path())
+				// Rebuild the row so the dropdown lists the file
+				row := controls.Parent()
+				if parent, ok := gtk.BaseWidget(row).Parent().(*gtk.Box); ok {
+					parent.InsertChildAfter(p.createICCRow(output, description, listICCProfiles()), row)
+					parent.Remove(row)
+				}
+			}
+		})
+		dialog.Show()
+	})
+	controls.Append(browseBtn)
+
+	title := output
+	if description != "" {
+		title = output + " — " + description
+	}
+	return p.m.createSettingRow(title, "Color profile (ICC) to correct the display's colors", controls)
+}
+
+// setICCProfile saves the profile of an output and applies it with the
+// output's confirmed mode and position
+func (p *displaysPage) setICCProfile(output, profile string) {
+	m := p.m
+	if m.settings.ICCProfiles == nil {
+		m.settings.ICCProfiles = make(map[string]string)
+	}
+	if profile == "" {
+		delete(m.settings.ICCProfiles, output)
+	} else {
+		m.settings.ICCProfiles[output] = profile
+	}
+	m.saveSettings()
+
+	for i := range p.monitors {
+		if p.monitors[i].Name == output {
+			p.monitors[i].ICC = profile
+		}
+	}
+	for i := range p.applied {
+		if p.applied[i].Name != output {
+			continue
+		}
+		p.applied[i].ICC = profile
+		if out, err := exec.Command("hyprctl", "keyword", "monitor", p.applied[i].rule()).CombinedOutput(); err != nil {
+			p.showColorError(fmt.Errorf("hyprctl failed: %v: %s", err, strings.TrimSpace(string(out))))
+		}
+	}
+	if err := writeMonitorFragment(p.applied); err != nil {
+		p.showColorError(fmt.Errorf("failed to write %s: %w", hyprMonitorsPath(), err))
+	}
+}
+
+// loadBrightness lists the external monitors that take DDC/CI commands,
+// each with a brightness slider. Asking the monitors takes a few seconds.
+func (p *displaysPage) loadBrightness(box *gtk.Box) {
+	if _, err := exec.LookPath("ddcutil"); err != nil {
+		box.Append(emptyLabel("External monitor brightness needs ddcutil"))
+		return
+	}
+	loading := emptyLabel("Looking for external monitors...")
+	box.Append(loading)
+
+	type result struct {
+		display ddcDisplay
+		value   int
+		maximum int
+	}
+	go func() {
+		displays, err := listDDCDisplays()
+		var results []result
+		for _, display := range displays {
+			value, maximum, err := ddcGetBrightness(display.Bus)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "raven-settings-menu: %v\n", err)
+				continue
+			}
+			results = append(results, result{display, value, maximum})
+		}
+		glib.IdleAdd(func() {
+			box.Remove(loading)
+			if err != nil {
+				p.showColorError(err)
+			}
+			if len(results) == 0 {
+				box.Append(emptyLabel("No external monitors answer DDC/CI"))
+				return
+			}
+			for _, r := range results {
+				box.Append(p.createBrightnessRow(r.display, r.value, r.maximum))
+			}
+		})
+	}()
+}
+
+// createBrightnessRow builds the brightness slider of an external monitor
+func (p *displaysPage) createBrightnessRow(display ddcDisplay, value, maximum int) *gtk.Box {
+	scale := gtk.NewScaleWithRange(gtk.OrientationHorizontal, 0, float64(maximum), 1)
+	scale.SetSizeRequest(200, -1)
+	scale.SetDrawValue(true)
+	scale.SetValue(float64(value))
+
+	// Monitors take a while for each command
+	monitor := &coalescer{
+		send:   func(value int) error { return ddcSetBrightness(display.Bus, value) },
+		failed: p.showColorError,
+	}
+	scale.ConnectValueChanged(func() {
+		monitor.set(int(scale.Value()))
+	})
+
+	title := display.Output
+	if title == "" {
+		title = "I2C bus " + display.Bus
+	}
+	if display.Model != "" {
+		title += " — " + display.Model
+	}
+	return p.m.createSettingRow(title, "Brightness, set on the monitor over DDC/CI", scale)
+}
+
+// coalescer sends values from a slider off the main loop, one at a time.
+// Values set while one is sent are held, and only the last is sent next.
+type coalescer struct {
+	send    func(int) error
+	failed  func(error)
+	pending int
+	busy    bool
+}
+
+func (c *coalescer) set(value int) {
+	c.pending = value
+	if c.busy {
+		return
+	}
+	c.busy = true
+	c.next()
+}
+
+func (c *coalescer) next() {
+	value := c.pending
+	go func() {
+		err := c.send(value)
+		glib.IdleAdd(func() {
+			if err != nil {
+				c.failed(err)
+			}
+			if c.pending != value {
+				c.next()
+			} else {
+				c.busy = false
+			}
+		})
+	}()
+}
+
+// showColorError reports a failed change at the top of the color sub-page
+func (p *displaysPage) showColorError(err error) {
+	fmt.Fprintf(os.Stderr, "raven-settings-menu: %v\n", err)
+	p.colorMessage.SetText(err.Error())
+	p.colorMessage.SetVisible(true)
+}
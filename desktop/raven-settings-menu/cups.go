@@ -0,0 +1,270 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// The page CUPS prints for a test
+const cupsTestPage = "/usr/share/cups/data/testprint"
+
+// How long the printer search listens for mDNS announcements
+const printerDiscoverySeconds = 5
+
+// printer is a CUPS queue
+type printer struct {
+	Name        string
+	Description string
+	Location    string
+	URI         string
+	State       string // "idle", "printing" or "disabled"
+	Default     bool
+}
+
+// printJob is a CUPS job that hasn't completed
+type printJob struct {
+	ID       string // e.g. "LaserJet-42"
+	Printer  string
+	User     string
+	Size     int64
+	Printing bool
+}
+
+// foundPrinter is a printer announced over mDNS (DNS-SD)
+type foundPrinter struct {
+	URI   string
+	Name  string // Service name, e.g. "HP LaserJet M110w"
+	Model string
+}
+
+// lpstat runs lpstat with the C locale, so its output can be parsed
+func lpstat(args ...string) (string, error) {
+	cmd := exec.Command("lpstat", args...)
+	cmd.Env = append(os.Environ(), "LC_ALL=C")
+	out, err := cmd.CombinedOutput()
+	return string(out), err
+}
+
+// cupsRunning reports whether the CUPS scheduler answers
+func cupsRunning() bool {
+	out, err := lpstat("-r")
+	return err == nil && strings.Contains(out, "scheduler is running")
+}
+
+// listPrinters returns the CUPS queues sorted by name
+func listPrinters() ([]printer, error) {
+	out, err := lpstat("-l", "-p")
+	if err != nil {
+		// lpstat fails when there is nothing to list
+		if strings.Contains(out, "No destinations added") {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("lpstat: %s", strings.TrimSpace(out))
+	}
+
+	var printers []printer
+	for _, line := range strings.Split(out, "\n") {
+		if rest, ok := strings.CutPrefix(line, "printer "); ok {
+			fields := strings.Fields(rest)
+			if len(fields) < 2 {
+				continue
+			}
+			p := printer{Name: fields[0], State: "idle"}
+			switch {
+			case strings.Contains(rest, " disabled"):
+				p.State = "disabled"
+			case strings.Contains(rest, " now printing"):
+				p.State = "printing"
+			}
+			printers = append(printers, p)
+			continue
+		}
+		if len(printers) == 0 {
+			continue
+		}
+		last := &printers[len(printers)-1]
+		key, value, ok := strings.Cut(strings.TrimSpace(line), ":")
+		if !ok {
+			continue
+		}
+		switch key {
+		case "Description":
+			last.Description = strings.TrimSpace(value)
+		case "Location":
+			last.Location = strings.TrimSpace(value)
+		}
+	}
+
+	if out, err := lpstat("-v"); err == nil {
+		for _, line := range strings.Split(out, "\n") {
+			rest, ok := strings.CutPrefix(line, "device for ")
+			if !ok {
+				continue
+			}
+			name, uri, _ := strings.Cut(rest, ": ")
+			for i := range printers {
+				if printers[i].Name == name {
+					printers[i].URI = strings.TrimSpace(uri)
+				}
+			}
+		}
+	}
+
+	// lpstat -d includes the user's default from ~/.cups/lpoptions
+	if out, err := lpstat("-d"); err == nil {
+		if _, name, ok := strings.Cut(out, "default destination: "); ok {
+			name = strings.TrimSpace(name)
+			for i := range printers {
+				printers[i].Default = printers[i].Name == name
+			}
+		}
+	}
+
+	sort.Slice(printers, func(i, j int) bool {
+		return strings.ToLower(printers[i].Name) < strings.ToLower(printers[j].Name)
+	})
+	return printers, nil
+}
+
+// listJobs returns the jobs of all printers that haven't completed
+func listJobs() ([]printJob, error) {
+	out, err := lpstat("-o")
+	if err != nil {
+		return nil, fmt.Errorf("lpstat: %s", strings.TrimSpace(out))
+	}
+
+	// lpstat -p names the job each printer is working on
+	printing := make(map[string]bool)
+	if status, err := lpstat("-p"); err == nil {
+		for _, line := range strings.Split(status, "\n") {
+			_, rest, ok := strings.Cut(line, "now printing ")
+			if !ok {
+				continue
+			}
+			job, _, _ := strings.Cut(rest, ".")
+			printing[strings.TrimSpace(job)] = true
+		}
+	}
+
+	var jobs []printJob
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 3 {
+			continue
+		}
+		job := printJob{ID: fields[0], User: fields[1], Printing: printing[fields[0]]}
+		job.Size, _ = strconv.ParseInt(fields[2], 10, 64)
+		if i := strings.LastIndexByte(job.ID, '-'); i > 0 {
+			job.Printer = job.ID[:i]
+		}
+		jobs = append(jobs, job)
+	}
+	return jobs, nil
+}
+
+// discoverPrinters searches the network for IPP printers. IPP Everywhere
+// printers need no driver, so any of them can be added as found.
+func discoverPrinters() ([]foundPrinter, error) {
+	if _, err := exec.LookPath("ippfind"); err != nil {
+		return nil, errors.New("searching for printers needs ippfind (cups)")
+	}
+	out, err := exec.Command("ippfind", "-T", strconv.Itoa(printerDiscoverySeconds),
+		"_ipp._tcp", "_ipps._tcp",
+		"--exec", "printf", `%s\t%s\t%s\n`, "{service_uri}", "{service_name}", "{txt_ty}", ";").Output()
+	// ippfind exits 1 when nothing was found
+	if err != nil && len(out) == 0 {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("ippfind: %w", err)
+	}
+
+	// Printers announce themselves over both ipp and ipps
+	seen := make(map[string]bool)
+	var found []foundPrinter
+	for _, line := range strings.Split(string(out), "\n") {
+		fields := strings.Split(line, "\t")
+		if len(fields) < 2 || fields[0] == "" || seen[fields[1]] {
+			continue
+		}
+		seen[fields[1]] = true
+		p := foundPrinter{URI: fields[0], Name: fields[1]}
+		if len(fields) > 2 {
+			p.Model = fields[2]
+		}
+		found = append(found, p)
+	}
+	sort.Slice(found, func(i, j int) bool { return found[i].Name < found[j].Name })
+	return found, nil
+}
+
+// queueName turns a printer's name into a CUPS queue name, which can't
+// have spaces, slashes, # or quotes
+func queueName(name string) string {
+	var b strings.Builder
+	for _, r := range name {
+		switch {
+		case r <= ' ' || r == 127 || strings.ContainsRune(`/\#'"`, r):
+			b.WriteByte('_')
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return strings.Trim(b.String(), "_")
+}
+
+// lpadmin runs lpadmin. CUPS lets members of its admin group (lpadmin or
+// wheel) change queues; anyone else is asked for the root password
+// through pkexec.
+func lpadmin(args ...string) error {
+	err := runQuiet("lpadmin", args...)
+	if err == nil || !strings.Contains(strings.ToLower(err.Error()), "forbidden") {
+		return err
+	}
+	pkexec, lookErr := exec.LookPath("pkexec")
+	if lookErr != nil {
+		return err
+	}
+	return runQuiet(pkexec, append([]string{"lpadmin"}, args...)...)
+}
+
+// addPrinter adds an enabled queue for an IPP Everywhere printer. CUPS
+// asks the printer for its capabilities, which can take a few seconds.
+func addPrinter(name, uri, description string) error {
+	if name == "" {
+		return errors.New("the printer needs a name")
+	}
+	args := []string{"-p", name, "-E", "-v", uri, "-m", "everywhere"}
+	if description != "" {
+		args = append(args, "-D", description)
+	}
+	return lpadmin(args...)
+}
+
+func removePrinter(name string) error {
+	return lpadmin("-x", name)
+}
+
+// setDefaultPrinter makes name the user's default printer
+func setDefaultPrinter(name string) error {
+	return runQuiet("lpoptions", "-d", name)
+}
+
+// printTestPage sends the CUPS test page to a printer
+func printTestPage(name string) error {
+	if _, err := os.Stat(cupsTestPage); err != nil {
+		return fmt.Errorf("the CUPS test page %s is missing", cupsTestPage)
+	}
+	return runQuiet("lp", "-d", name, "-t", "Test Page", cupsTestPage)
+}
+
+// cancelJob removes a job from its queue
+func cancelJob(id string) error {
+	return runQuiet("cancel", id)
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// VCP feature code of the brightness (luminance) control
+const ddcBrightness = "10"
+
+// ddcDisplay is an external monitor that takes DDC/CI commands
+type ddcDisplay struct {
+	Bus    string // I2C bus number, e.g. "4" for /dev/i2c-4
+	Output string // Hyprland's name for the output, e.g. "DP-1"
+	Model  string
+}
+
+// listDDCDisplays asks ddcutil for the monitors that answer DDC/CI. Laptop
+// panels don't, and are left out.
+func listDDCDisplays() ([]ddcDisplay, error) {
+	out, err := exec.Command("ddcutil", "detect", "--terse").CombinedOutput()
+	if err != nil {
+		return nil, fmt.Errorf("ddcutil detect: %s", strings.TrimSpace(string(out)))
+	}
+
+	var displays []ddcDisplay
+	valid := false
+	for _, line := range strings.Split(string(out), "\n") {
+		if !strings.HasPrefix(line, " ") {
+			// "Display 1" starts a monitor; "Invalid display" one that
+			// doesn't answer
+			valid = strings.HasPrefix(line, "Display ")
+			if valid {
+				displays = append(displays, ddcDisplay{})
+			}
+			continue
+		}
+		if !valid {
+			continue
+		}
+		key, value, ok := strings.Cut(strings.TrimSpace(line), ":")
+		if !ok {
+			continue
+		}
+		value = strings.TrimSpace(value)
+		last := &displays[len(displays)-1]
+		switch key {
+		case "I2C bus":
+			last.Bus = strings.TrimPrefix(value, "/dev/i2c-")
+		case "DRM connector", "DRM_connector":
+			// card0-DP-1 is DP-1 to Hyprland
+			if _, name, ok := strings.Cut(value, "-"); ok {
+				last.Output = name
+			}
+		case "Monitor":
+			// MFG:model:serial
+			fields := strings.Split(value, ":")
+			if len(fields) > 1 {
+				last.Model = fields[1]
+			}
+		}
+	}
+	return displays, nil
+}
+
+// ddcGetBrightness returns a monitor's brightness and the most it goes to,
+// usually 100
+func ddcGetBrightness(bus string) (int, int, error) {
+	out, err := exec.Command("ddcutil", "--bus", bus, "--brief", "getvcp", ddcBrightness).CombinedOutput()
+	if err != nil {
+		return 0, 0, fmt.Errorf("ddcutil getvcp: %s", strings.TrimSpace(string(out)))
+	}
+	// VCP 10 C 50 100
+	fields := strings.Fields(string(out))
+	if len(fields) >= 5 && fields[0] == "VCP" {
+		current, errC := strconv.Atoi(fields[3])
+		maximum, errM := strconv.Atoi(fields[4])
+		if errC == nil && errM == nil && maximum > 0 {
+			return current, maximum, nil
+		}
+	}
+	return 0, 0, fmt.Errorf("ddcutil getvcp: unexpected output %q", strings.TrimSpace(string(out)))
+}
+
+// ddcSetBrightness sets a monitor's brightness, from 0 to the maximum
+// ddcGetBrightness returned
+func ddcSetBrightness(bus string, value int) error {
+	return runQuiet("ddcutil", "--bus", bus, "--noverify", "setvcp", ddcBrightness, strconv.Itoa(value))
+}
@@ -0,0 +1,552 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/diamondburned/gotk4/pkg/glib/v2"
+	"github.com/diamondburned/gotk4/pkg/gtk/v4"
+)
+
+// Seconds before unconfirmed display settings are reverted
+const displayRevertSeconds = 15
+
+// Size of the monitor layout canvas
+const (
+	canvasWidth  = 520
+	canvasHeight = 220
+)
+
+// Monitors closer than this many logical pixels are snapped together
+const snapDistance = 64
+
+var displayScales = []float64{1, 1.25, 1.5, 1.75, 2, 3}
+
+var displayTransforms = []string{
+	"Normal", "90°", "180°", "270°",
+	"Flipped", "Flipped 90°", "Flipped 180°", "Flipped 270°",
+}
+
+// displayMonitor is an output as reported by hyprctl monitors -j
+type displayMonitor struct {
+	Name           string   `json:"name"`
+	Description    string   `json:"description"`
+	Width          int      `json:"width"`
+	Height         int      `json:"height"`
+	RefreshRate    float64  `json:"refreshRate"`
+	X              int      `json:"x"`
+	Y              int      `json:"y"`
+	Scale          float64  `json:"scale"`
+	Transform      int      `json:"transform"`
+	AvailableModes []string `json:"availableModes"`
+	ICC            string   `json:"-"` // Color profile, from the settings
+}
+
+// mode returns the mode as a monitor rule takes it, e.g. 1920x1080@60.00
+func (d displayMonitor) mode() string {
+	return fmt.Sprintf("%dx%d@%.2f", d.Width, d.Height, d.RefreshRate)
+}
+
+// logicalSize is the area the monitor covers in the layout, after scale
+// and rotation
+func (d displayMonitor) logicalSize() (int, int) {
+	scale := d.Scale
+	if scale <= 0 {
+		scale = 1
+	}
+	w := int(math.Round(float64(d.Width) / scale))
+	h := int(math.Round(float64(d.Height) / scale))
+	if d.Transform%2 == 1 {
+		return h, w
+	}
+	return w, h
+}
+
+// rule returns the Hyprland monitor rule for the current settings
+func (d displayMonitor) rule() string {
+	rule := fmt.Sprintf("%s,%s,%dx%d,%s,transform,%d",
+		d.Name, d.mode(), d.X, d.Y, strconv.FormatFloat(d.Scale, 'f', -1, 64), d.Transform)
+	if d.ICC != "" {
+		rule += ",icc," + d.ICC
+	}
+	return rule
+}
+
+// parseMode reads an availableModes entry like 1920x1080@60.00Hz
+func parseMode(mode string) (int, int, float64, bool) {
+	size, rate, _ := strings.Cut(strings.TrimSuffix(mode, "Hz"), "@")
+	w, h, ok := strings.Cut(size, "x")
+	width, errW := strconv.Atoi(w)
+	height, errH := strconv.Atoi(h)
+	refresh, errR := strconv.ParseFloat(rate, 64)
+	if !ok || errW != nil || errH != nil || errR != nil {
+		return 0, 0, 0, false
+	}
+	return width, height, refresh, true
+}
+
+// loadMonitors asks Hyprland for the enabled outputs
+func loadMonitors() ([]displayMonitor, error) {
+	output, err := exec.Command("hyprctl", "monitors", "-j").Output()
+	if err != nil {
+		return nil, fmt.Errorf("hyprctl monitors: %w", err)
+	}
+	var monitors []displayMonitor
+	if err := json.Unmarshal(output, &monitors); err != nil {
+		return nil, err
+	}
+	return monitors, nil
+}
+
+// applyMonitorRules sets every monitor's rule in the running compositor
+func applyMonitorRules(monitors []displayMonitor) error {
+	keywords := make([]string, len(monitors))
+	for i, mon := range monitors {
+		keywords[i] = "keyword monitor " + mon.rule()
+	}
+	output, err := exec.Command("hyprctl", "--batch", strings.Join(keywords, " ; ")).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("hyprctl failed: %v: %s", err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// hyprMonitorsPath returns the Hyprland config fragment holding the
+// confirmed display layout, sourced by hyprland.conf
+func hyprMonitorsPath() string {
+	return filepath.Join(filepath.Dir(hyprInputPath()), "raven-monitors.conf")
+}
+
+func writeMonitorFragment(monitors []displayMonitor) error {
+	var b strings.Builder
+	b.WriteString("# Written by raven-settings-menu (Displays)\n")
+	for _, mon := range monitors {
+		b.WriteString("monitor = " + mon.rule() + "\n")
+	}
+
+	path := hyprMonitorsPath()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, []byte(b.String()), 0644)
+}
+
+// displaysPage is the Displays page: a layout canvas, the selected
+// monitor's settings and the confirmation banner, with the color settings
+// on a sub-page
+type displaysPage struct {
+	m        *RavenSettingsMenu
+	monitors []displayMonitor
+	applied  []displayMonitor // The layout to revert to
+	selected int
+
+	canvas   *gtk.Fixed
+	boxes    []*gtk.Box
+	ratio    float64 // Canvas pixels per logical pixel
+	originX  int     // Logical position of the canvas origin
+	originY  int
+	controls *gtk.Box
+	stack    *gtk.Stack // "layout" or "color"
+
+	colorMessage *gtk.Label
+	previewEnd   glib.SourceHandle // Ends a night light preview
+
+	banner      *gtk.Box
+	bannerLabel *gtk.Label
+	countdown   glib.SourceHandle
+	remaining   int
+
+	dragIndex  int
+	dragStartX float64
+	dragStartY float64
+}
+
+func (m *RavenSettingsMenu) createDisplaysPage() *gtk.ScrolledWindow {
+	scroll := gtk.NewScrolledWindow()
+	scroll.SetPolicy(gtk.PolicyNever, gtk.PolicyAutomatic)
+
+	content := gtk.NewBox(gtk.OrientationVertical, 16)
+	content.SetMarginStart(20)
+	content.SetMarginEnd(20)
+	content.SetMarginTop(20)
+	content.SetMarginBottom(20)
+
+	sectionTitle := gtk.NewLabel("Displays")
+	sectionTitle.AddCSSClass("section-title")
+	sectionTitle.SetHAlign(gtk.AlignStart)
+	content.Append(sectionTitle)
+	scroll.SetChild(content)
+
+	monitors, err := loadMonitors()
+	if err != nil || len(monitors) == 0 {
+		message := "No displays found"
+		if err != nil {
+			message = "Display settings need a running Hyprland session"
+			fmt.Fprintf(os.Stderr, "raven-settings-menu: %v\n", err)
+		}
+		label := gtk.NewLabel(message)
+		label.AddCSSClass("setting-description")
+		label.SetHAlign(gtk.AlignStart)
+		content.Append(label)
+		return scroll
+	}
+
+	for i := range monitors {
+		monitors[i].ICC = m.settings.ICCProfiles[monitors[i].Name]
+	}
+	p := &displaysPage{m: m, monitors: monitors, dragIndex: -1}
+	p.applied = append([]displayMonitor(nil), monitors...)
+
+	// The layout, and the color settings as a sub-page
+	p.stack = gtk.NewStack()
+	p.stack.SetTransitionType(gtk.StackTransitionTypeSlideLeftRight)
+	layout := gtk.NewBox(gtk.OrientationVertical, 16)
+	p.stack.AddNamed(layout, "layout")
+	p.stack.AddNamed(p.createColorView(), "color")
+	content.Append(p.stack)
+
+	// Confirmation banner, shown while a change can still be reverted
+	p.banner = gtk.NewBox(gtk.OrientationHorizontal, 12)
+	p.banner.AddCSSClass("setting-row")
+	p.bannerLabel = gtk.NewLabel("")
+	p.bannerLabel.AddCSSClass("setting-label")
+	p.bannerLabel.SetHExpand(true)
+	p.bannerLabel.SetHAlign(gtk.AlignStart)
+	p.banner.Append(p.bannerLabel)
+	revertBtn := gtk.NewButtonWithLabel("Revert")
+	revertBtn.ConnectClicked(p.revert)
+	p.banner.Append(revertBtn)
+	keepBtn := gtk.NewButtonWithLabel("Keep Changes")
+	keepBtn.ConnectClicked(p.keep)
+	p.banner.Append(keepBtn)
+	p.banner.SetVisible(false)
+	layout.Append(p.banner)
+
+	// Layout canvas
+	frame := gtk.NewBox(gtk.OrientationVertical, 8)
+	frame.AddCSSClass("setting-row")
+	hint := gtk.NewLabel("Drag the displays to arrange them")
+	hint.AddCSSClass("setting-description")
+	hint.SetHAlign(gtk.AlignStart)
+	frame.Append(hint)
+	p.canvas = gtk.NewFixed()
+	p.canvas.SetSizeRequest(canvasWidth, canvasHeight)
+	p.canvas.SetHAlign(gtk.AlignCenter)
+	p.canvas.AddCSSClass("display-canvas")
+	p.attachCanvasDrag()
+	frame.Append(p.canvas)
+	layout.Append(frame)
+
+	p.controls = gtk.NewBox(gtk.OrientationVertical, 0)
+	layout.Append(p.controls)
+
+	applyBtn := gtk.NewButtonWithLabel("Apply")
+	applyBtn.SetHAlign(gtk.AlignEnd)
+	applyBtn.ConnectClicked(p.apply)
+	layout.Append(applyBtn)
+
+	colorBtn := gtk.NewButtonFromIconName("go-next-symbolic")
+	colorBtn.SetVAlign(gtk.AlignCenter)
+	colorBtn.ConnectClicked(func() {
+		p.stack.SetVisibleChildName("color")
+	})
+	layout.Append(m.createSettingRow("Color and Brightness", "Night light, color profiles and external monitor brightness", colorBtn))
+
+	p.drawCanvas()
+	p.buildControls()
+	return scroll
+}
+
+// drawCanvas lays the monitors out on the canvas, scaled to fit
+func (p *displaysPage) drawCanvas() {
+	for _, box := range p.boxes {
+		p.canvas.Remove(box)
+	}
+	p.boxes = nil
+
+	minX, minY := math.MaxInt, math.MaxInt
+	maxX, maxY := math.MinInt, math.MinInt
+	for _, mon := range p.monitors {
+		w, h := mon.logicalSize()
+		minX, minY = min(minX, mon.X), min(minY, mon.Y)
+		maxX, maxY = max(maxX, mon.X+w), max(maxY, mon.Y+h)
+	}
+	p.originX, p.originY = minX, minY
+	p.ratio = min(float64(canvasWidth)/float64(maxX-minX), float64(canvasHeight)/float64(maxY-minY)) * 0.9
+
+	for i, mon := range p.monitors {
+		w, h := mon.logicalSize()
+		box := gtk.NewBox(gtk.OrientationVertical, 2)
+		box.AddCSSClass("display-monitor")
+		if i == p.selected {
+			box.AddCSSClass("selected")
+		}
+		box.SetSizeRequest(int(float64(w)*p.ratio), int(float64(h)*p.ratio))
+		box.SetVAlign(gtk.AlignCenter)
+
+		name := gtk.NewLabel(mon.Name)
+		name.AddCSSClass("setting-label")
+		name.SetVExpand(true)
+		name.SetVAlign(gtk.AlignEnd)
+		box.Append(name)
+		size := gtk.NewLabel(fmt.Sprintf("%dx%d", mon.Width, mon.Height))
+		size.AddCSSClass("setting-description")
+		size.SetVExpand(true)
+		size.SetVAlign(gtk.AlignStart)
+		box.Append(size)
+
+		x, y := p.toCanvas(mon.X, mon.Y)
+		p.canvas.Put(box, x, y)
+		p.boxes = append(p.boxes, box)
+	}
+}
+
+func (p *displaysPage) toCanvas(x, y int) (float64, float64) {
+	return float64(x-p.originX) * p.ratio, float64(y-p.originY) * p.ratio
+}
+
+// monitorAt returns the monitor drawn at canvas point x, y, or -1
+func (p *displaysPage) monitorAt(x, y float64) int {
+	for i, mon := range p.monitors {
+		w, h := mon.logicalSize()
+		left, top := p.toCanvas(mon.X, mon.Y)
+		if x >= left && y >= top && x < left+float64(w)*p.ratio && y < top+float64(h)*p.ratio {
+			return i
+		}
+	}
+	return -1
+}
+
+// attachCanvasDrag selects a monitor on press and moves it while dragged.
+// The gesture is on the canvas, so moving a monitor doesn't move the
+// coordinates the drag is measured in.
+func (p *displaysPage) attachCanvasDrag() {
+	drag := gtk.NewGestureDrag()
+	drag.ConnectDragBegin(func(startX, startY float64) {
+		p.dragIndex = p.monitorAt(startX, startY)
+		if p.dragIndex < 0 {
+			return
+		}
+		p.dragStartX, p.dragStartY = p.canvas.ChildPosition(p.boxes[p.dragIndex])
+		if p.dragIndex != p.selected {
+			p.boxes[p.selected].RemoveCSSClass("selected")
+			p.selected = p.dragIndex
+			p.boxes[p.selected].AddCSSClass("selected")
+			p.buildControls()
+		}
+	})
+	drag.ConnectDragUpdate(func(offsetX, offsetY float64) {
+		if p.dragIndex >= 0 {
+			p.canvas.Move(p.boxes[p.dragIndex], p.dragStartX+offsetX, p.dragStartY+offsetY)
+		}
+	})
+	drag.ConnectDragEnd(func(offsetX, offsetY float64) {
+		if p.dragIndex < 0 || (offsetX == 0 && offsetY == 0) {
+			return
+		}
+		mon := &p.monitors[p.dragIndex]
+		mon.X = p.originX + int((p.dragStartX+offsetX)/p.ratio)
+		mon.Y = p.originY + int((p.dragStartY+offsetY)/p.ratio)
+		p.snap(p.dragIndex)
+		p.dragIndex = -1
+		p.drawCanvas()
+	})
+	p.canvas.AddController(drag)
+}
+
+// snap lines the moved monitor up with the edges of the others, then
+// shifts the layout so it starts at 0,0
+func (p *displaysPage) snap(moved int) {
+	mon := &p.monitors[moved]
+	w, h := mon.logicalSize()
+
+	bestX, bestY := snapDistance+1, snapDistance+1
+	snapX, snapY := mon.X, mon.Y
+	for i, other := range p.monitors {
+		if i == moved {
+			continue
+		}
+		ow, oh := other.logicalSize()
+		// Side by side, or left edges aligned
+		for _, x := range []int{other.X + ow, other.X - w, other.X, other.X + ow - w} {
+			if d := abs(mon.X - x); d < bestX {
+				bestX, snapX = d, x
+			}
+		}
+		for _, y := range []int{other.Y + oh, other.Y - h, other.Y, other.Y + oh - h} {
+			if d := abs(mon.Y - y); d < bestY {
+				bestY, snapY = d, y
+			}
+		}
+	}
+	if bestX <= snapDistance {
+		mon.X = snapX
+	}
+	if bestY <= snapDistance {
+		mon.Y = snapY
+	}
+
+	minX, minY := math.MaxInt, math.MaxInt
+	for _, other := range p.monitors {
+		minX, minY = min(minX, other.X), min(minY, other.Y)
+	}
+	for i := range p.monitors {
+		p.monitors[i].X -= minX
+		p.monitors[i].Y -= minY
+	}
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+// buildControls shows the mode, scale and rotation of the selected monitor
+func (p *displaysPage) buildControls() {
+	for child := p.controls.FirstChild(); child != nil; child = p.controls.FirstChild() {
+		p.controls.Remove(child)
+	}
+	mon := &p.monitors[p.selected]
+
+	title := gtk.NewLabel(mon.Name)
+	if mon.Description != "" {
+		title.SetText(mon.Name + " — " + mon.Description)
+	}
+	title.AddCSSClass("setting-label")
+	title.SetHAlign(gtk.AlignStart)
+	title.SetMarginBottom(8)
+	p.controls.Append(title)
+
+	// Resolution and refresh rate
+	modes := append([]string(nil), mon.AvailableModes...)
+	current := mon.mode() + "Hz"
+	selected := -1
+	for i, mode := range modes {
+		if w, h, r, ok := parseMode(mode); ok && w == mon.Width && h == mon.Height && math.Abs(r-mon.RefreshRate) < 0.01 {
+			selected = i
+		}
+	}
+	if selected < 0 {
+		modes = append([]string{current}, modes...)
+		selected = 0
+	}
+	modeDropdown := gtk.NewDropDown(gtk.NewStringList(modes), nil)
+	modeDropdown.SetSelected(uint(selected))
+	modeDropdown.Connect("notify::selected", func() {
+		idx := modeDropdown.Selected()
+		if idx < uint(len(modes)) {
+			if w, h, r, ok := parseMode(modes[idx]); ok {
+				mon.Width, mon.Height, mon.RefreshRate = w, h, r
+				p.drawCanvas()
+			}
+		}
+	})
+	p.controls.Append(p.m.createSettingRow("Resolution", "Size and refresh rate", modeDropdown))
+
+	// Scale
+	scales := append([]float64(nil), displayScales...)
+	selected = -1
+	for i, scale := range scales {
+		if math.Abs(scale-mon.Scale) < 0.001 {
+			selected = i
+		}
+	}
+	if selected < 0 {
+		scales = append([]float64{mon.Scale}, scales...)
+		selected = 0
+	}
+	labels := make([]string, len(scales))
+	for i, scale := range scales {
+		labels[i] = fmt.Sprintf("%g%%", scale*100)
+	}
+	scaleDropdown := gtk.NewDropDown(gtk.NewStringList(labels), nil)
+	scaleDropdown.SetSelected(uint(selected))
+	scaleDropdown.Connect("notify::selected", func() {
+		idx := scaleDropdown.Selected()
+		if idx < uint(len(scales)) {
+			mon.Scale = scales[idx]
+			p.drawCanvas()
+		}
+	})
+	p.controls.Append(p.m.createSettingRow("Scale", "Size of text and windows", scaleDropdown))
+
+	// Rotation
+	rotationDropdown := gtk.NewDropDown(gtk.NewStringList(displayTransforms), nil)
+	if mon.Transform >= 0 && mon.Transform < len(displayTransforms) {
+		rotationDropdown.SetSelected(uint(mon.Transform))
+	}
+	rotationDropdown.Connect("notify::selected", func() {
+		idx := rotationDropdown.Selected()
+		if idx < uint(len(displayTransforms)) {
+			mon.Transform = int(idx)
+			p.drawCanvas()
+		}
+	})
+	p.controls.Append(p.m.createSettingRow("Rotation", "Orientation of the display", rotationDropdown))
+}
+
+// apply sets the new layout and asks for confirmation, reverting if none
+// comes in time
+func (p *displaysPage) apply() {
+	if err := applyMonitorRules(p.monitors); err != nil {
+		fmt.Fprintf(os.Stderr, "raven-settings-menu: %v\n", err)
+		p.revert()
+		return
+	}
+
+	p.stopCountdown()
+	p.remaining = displayRevertSeconds
+	p.updateBanner()
+	p.banner.SetVisible(true)
+	p.countdown = glib.TimeoutSecondsAdd(1, func() bool {
+		p.remaining--
+		if p.remaining <= 0 {
+			p.countdown = 0
+			p.revert()
+			return false
+		}
+		p.updateBanner()
+		return true
+	})
+}
+
+func (p *displaysPage) updateBanner() {
+	p.bannerLabel.SetText(fmt.Sprintf("Keep these display settings? Reverting in %d seconds", p.remaining))
+}
+
+func (p *displaysPage) stopCountdown() {
+	if p.countdown != 0 {
+		glib.SourceRemove(p.countdown)
+		p.countdown = 0
+	}
+}
+
+// keep saves the applied layout to the Hyprland fragment
+func (p *displaysPage) keep() {
+	p.stopCountdown()
+	p.banner.SetVisible(false)
+	p.applied = append([]displayMonitor(nil), p.monitors...)
+	if err := writeMonitorFragment(p.monitors); err != nil {
+		fmt.Fprintf(os.Stderr, "raven-settings-menu: failed to write %s: %v\n", hyprMonitorsPath(), err)
+	}
+}
+
+// revert goes back to the last confirmed layout
+func (p *displaysPage) revert() {
+	p.stopCountdown()
+	p.banner.SetVisible(false)
+	p.monitors = append([]displayMonitor(nil), p.applied...)
+	if err := applyMonitorRules(p.monitors); err != nil {
+		fmt.Fprintf(os.Stderr, "raven-settings-menu: %v\n", err)
+	}
+	p.drawCanvas()
+	p.buildControls()
+}
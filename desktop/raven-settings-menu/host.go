@@ -0,0 +1,51 @@
+package main
+
+import (
+	"encoding/json"
+
+	"github.com/diamondburned/gotk4/pkg/gtk/v4"
+
+	"raven-settings-menu/pages"
+)
+
+// The settings menu is the host every page is built with
+var _ pages.Host = (*RavenSettingsMenu)(nil)
+
+// Setting decodes the settings.json value for key into value
+func (m *RavenSettingsMenu) Setting(key string, value any) bool {
+	m.flushSettings()
+	raw, ok := m.readSettingsFile()[key]
+	if !ok {
+		return false
+	}
+	return json.Unmarshal(raw, value) == nil
+}
+
+// SetSetting stores value under key in settings.json. Keys that belong to
+// our own fields are reloaded so the built-in pages stay in sync.
+func (m *RavenSettingsMenu) SetSetting(key string, value any) error {
+	raw, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	m.flushSettings()
+	config := m.readSettingsFile()
+	config[key] = raw
+	if err := m.writeSettingsFile(config); err != nil {
+		return err
+	}
+	if own, err := json.Marshal(map[string]json.RawMessage{key: raw}); err == nil {
+		json.Unmarshal(own, &m.settings)
+	}
+	return nil
+}
+
+// SettingRow lays out a plugin control like the built-in rows
+func (m *RavenSettingsMenu) SettingRow(title, description string, control gtk.Widgetter) *gtk.Box {
+	return m.createSettingRow(title, description, control)
+}
+
+// Window is the settings window, for dialogs
+func (m *RavenSettingsMenu) Window() *gtk.Window {
+	return m.window
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// configHome returns $XDG_CONFIG_HOME, ~/.config by default
+func configHome() string {
+	if dir := os.Getenv("XDG_CONFIG_HOME"); dir != "" {
+		return dir
+	}
+	return filepath.Join(os.Getenv("HOME"), ".config")
+}
+
+// hyprInputPath returns the Hyprland config fragment holding the Input
+// page settings. hyprland.conf sources it after its own input block, so
+// the settings survive a restart of the compositor.
+func hyprInputPath() string {
+	return filepath.Join(configHome(), "hypr", "raven-input.conf")
+}
+
+// hyprSensitivity maps the 0-1 mouse speed to Hyprland's -1 to 1
+// sensitivity, with 0.5 leaving the pointer speed unchanged
+func hyprSensitivity(speed float64) string {
+	return fmt.Sprintf("%.2f", speed*2-1)
+}
+
+func hyprBool(value bool) string {
+	if value {
+		return "true"
+	}
+	return "false"
+}
+
+// applyInputSettings writes the Input page settings to the Hyprland
+// fragment and sets them in the running compositor
+func (m *RavenSettingsMenu) applyInputSettings() {
+	s := m.settings
+	layout := s.KeyboardLayout
+	if layout == "" {
+		layout = "us"
+	}
+
+	fragment := fmt.Sprintf(`# Written by raven-settings-menu from ~/.config/raven/settings.json
+input {
+    kb_layout = %s
+    sensitivity = %s
+
+    touchpad {
+        natural_scroll = %s
+        tap-to-click = %s
+    }
+}
+`, layout, hyprSensitivity(s.MouseSpeed), hyprBool(s.TouchpadNaturalScroll), hyprBool(s.TouchpadTapToClick))
+
+	path := hyprInputPath()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err == nil {
+		err = os.WriteFile(path, []byte(fragment), 0644)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "raven-settings-menu: failed to write %s: %v\n", path, err)
+		}
+	}
+
+	keywords := []string{
+		"keyword input:kb_layout " + layout,
+		"keyword input:sensitivity " + hyprSensitivity(s.MouseSpeed),
+		"keyword input:touchpad:natural_scroll " + hyprBool(s.TouchpadNaturalScroll),
+		"keyword input:touchpad:tap-to-click " + hyprBool(s.TouchpadTapToClick),
+	}
+	go func() {
+		// Outside a Hyprland session the fragment is applied at next login
+		if os.Getenv("HYPRLAND_INSTANCE_SIGNATURE") == "" {
+			return
+		}
+		output, err := exec.Command("hyprctl", "--batch", strings.Join(keywords, " ; ")).CombinedOutput()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "raven-settings-menu: hyprctl failed: %v: %s\n", err, strings.TrimSpace(string(output)))
+		}
+	}()
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"github.com/diamondburned/gotk4/pkg/gtk/v4"
+)
+
+// featureFlag is an in-development subsystem users can opt into. Enabled
+// flags are kept in feature_flags in settings.json, by key, and read by the
+// component that owns the subsystem when it starts.
+type featureFlag struct {
+	Key         string
+	Title       string
+	Description string
+}
+
+// featureFlags are the flags the Labs page offers
+var featureFlags = []featureFlag{
+	{
+		Key: "new_control_center", Title: "New Control Center",
+		Description: "Replace the panel's settings menu with the new control center",
+	},
+	{
+		Key: "animated_wallpapers", Title: "Animated Wallpapers",
+		Description: "Video and shader wallpapers on the desktop",
+	},
+	{
+		Key: "tray_protocol_v2", Title: "Tray Protocol v2",
+		Description: "Show tray icons through the new StatusNotifierItem host",
+	},
+}
+
+func (m *RavenSettingsMenu) createLabsPage() *gtk.ScrolledWindow {
+	scroll := gtk.NewScrolledWindow()
+	scroll.SetPolicy(gtk.PolicyNever, gtk.PolicyAutomatic)
+
+	content := gtk.NewBox(gtk.OrientationVertical, 16)
+	content.SetMarginStart(20)
+	content.SetMarginEnd(20)
+	content.SetMarginTop(20)
+	content.SetMarginBottom(20)
+
+	sectionTitle := gtk.NewLabel("Labs")
+	sectionTitle.AddCSSClass("section-title")
+	sectionTitle.SetHAlign(gtk.AlignStart)
+	content.Append(sectionTitle)
+
+	// Warning banner, with the reset
+	banner := gtk.NewBox(gtk.OrientationHorizontal, 12)
+	banner.AddCSSClass("setting-row")
+	banner.AddCSSClass("labs-warning")
+	warning := gtk.NewLabel("These features are still in development. They may be unstable, change or go away. Restart the session after changing them.")
+	warning.AddCSSClass("setting-label")
+	warning.SetWrap(true)
+	warning.SetXAlign(0)
+	warning.SetHExpand(true)
+	banner.Append(warning)
+	resetBtn := gtk.NewButtonWithLabel("Reset All")
+	resetBtn.SetVAlign(gtk.AlignCenter)
+	banner.Append(resetBtn)
+	content.Append(banner)
+
+	switches := make([]*gtk.Switch, len(featureFlags))
+	updateReset := func() {
+		resetBtn.SetSensitive(len(m.settings.FeatureFlags) > 0)
+	}
+	for i, flag := range featureFlags {
+		flagSwitch := gtk.NewSwitch()
+		flagSwitch.SetVAlign(gtk.AlignCenter)
+		flagSwitch.SetActive(m.settings.FeatureFlags[flag.Key])
+		flagSwitch.ConnectStateSet(func(state bool) bool {
+			m.setFeatureFlag(flag.Key, state)
+			updateReset()
+			return false
+		})
+		switches[i] = flagSwitch
+		content.Append(m.createSettingRow(flag.Title, flag.Description, flagSwitch))
+	}
+
+	// Turn every flag off, including ones this version doesn't know
+	resetBtn.ConnectClicked(func() {
+		for _, flagSwitch := range switches {
+			flagSwitch.SetActive(false)
+		}
+		m.settings.FeatureFlags = map[string]bool{}
+		m.saveSettings()
+		updateReset()
+	})
+	updateReset()
+
+	scroll.SetChild(content)
+	return scroll
+}
+
+// setFeatureFlag enables or disables a flag. Only enabled flags are kept.
+func (m *RavenSettingsMenu) setFeatureFlag(key string, enabled bool) {
+	if m.settings.FeatureFlags == nil {
+		m.settings.FeatureFlags = map[string]bool{}
+	}
+	if enabled == m.settings.FeatureFlags[key] {
+		return
+	}
+	if enabled {
+		m.settings.FeatureFlags[key] = true
+	} else {
+		delete(m.settings.FeatureFlags, key)
+	}
+	m.saveSettings()
+}
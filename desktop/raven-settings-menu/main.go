@@ -2,10 +2,10 @@ package main
 
 import (
 	"encoding/json"
+	"fmt"
 	"os"
 	"os/exec"
 	"path/filepath"
-	"strconv"
 	"strings"
 	"unsafe"
 
@@ -13,6 +13,8 @@ import (
 	"github.com/diamondburned/gotk4/pkg/gio/v2"
 	"github.com/diamondburned/gotk4/pkg/glib/v2"
 	"github.com/diamondburned/gotk4/pkg/gtk/v4"
+
+	"raven-settings-menu/pages"
 )
 
 /*
@@ -28,13 +30,6 @@ void init_settings_layer_shell(GtkWidget *window) {
 */
 import "C"
 
-// SettingsCategory represents a settings category
-type SettingsCategory struct {
-	Name        string
-	Icon        string
-	Description string
-}
-
 // RavenSettings holds the application settings
 type RavenSettings struct {
 	// Appearance
@@ -45,11 +40,17 @@ type RavenSettings struct {
 	CursorTheme      string  `json:"cursor_theme"`
 	PanelOpacity     float64 `json:"panel_opacity"`
 	EnableAnimations bool    `json:"enable_animations"`
+	ThemeSchedule    string  `json:"theme_schedule"` // "off", "fixed" or "sun"
+	LightThemeStart  string  `json:"light_theme_start"`
+	DarkThemeStart   string  `json:"dark_theme_start"`
+	Latitude         float64 `json:"latitude"`
+	Longitude        float64 `json:"longitude"`
 
 	// Desktop
-	WallpaperPath    string `json:"wallpaper_path"`
-	WallpaperMode    string `json:"wallpaper_mode"`
-	ShowDesktopIcons bool   `json:"show_desktop_icons"`
+	WallpaperPath    string   `json:"wallpaper_path"`
+	WallpaperMode    string   `json:"wallpaper_mode"`
+	RecentWallpapers []string `json:"recent_wallpapers"`
+	ShowDesktopIcons bool     `json:"show_desktop_icons"`
 
 	// Panel
 	PanelPosition  string `json:"panel_position"`
@@ -75,9 +76,29 @@ type RavenSettings struct {
 	SuspendTimeout int    `json:"suspend_timeout"`
 	LidCloseAction string `json:"lid_close_action"`
 
+	// Displays: color
+	NightLight            bool              `json:"night_light"`
+	NightLightTemperature int               `json:"night_light_temperature"` // Kelvin
+	ICCProfiles           map[string]string `json:"icc_profiles,omitempty"`  // Profile path by output name
+
 	// Sound
 	MasterVolume int  `json:"master_volume"`
 	MuteOnLock   bool `json:"mute_on_lock"`
+
+	// Network: WireGuard tunnels imported into /etc/wireguard
+	WireGuardTunnels []string `json:"wireguard_tunnels,omitempty"`
+
+	// Services left out of raven-autostart.conf
+	DisabledServices []string `json:"disabled_services"`
+
+	// hyprland.conf binds moved to other keys, written to raven-binds.conf
+	KeybindOverrides []keybindOverride `json:"keybind_overrides"`
+
+	// Labs: enabled in-development features, by flag key
+	FeatureFlags map[string]bool `json:"feature_flags"`
+
+	// Named profile the settings are saved to when switching away
+	Profile string `json:"profile,omitempty"`
 }
 
 // RavenSettingsMenu is the settings application
@@ -86,9 +107,15 @@ type RavenSettingsMenu struct {
 	window       *gtk.Window
 	categoryList *gtk.ListBox
 	contentStack *gtk.Stack
-	categories   []SettingsCategory
+	pages        []pages.Page
 	settings     RavenSettings
 	settingsPath string
+	saveTimer    glib.SourceHandle // Pending write of settings.json
+	wallpaper    *wallpaperPicker
+	startPage    string // Page shown first, from --page
+
+	pageWidgets    []gtk.Widgetter // Built pages, in m.pages order
+	settingEntries []settingEntry  // Rows search can find
 }
 
 func main() {
@@ -98,9 +125,45 @@ func main() {
 		app: app,
 	}
 
+	// Bundles can be exported and imported without the window, to
+	// provision other machines from a script
+	if len(os.Args) == 3 && (os.Args[1] == "--export" || os.Args[1] == "--import") {
+		menu.loadSettings()
+		var err error
+		if os.Args[1] == "--export" {
+			err = menu.exportProfile(os.Args[2])
+		} else {
+			err = menu.importFile(os.Args[2])
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "raven-settings-menu: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	// Network and power changes run as root through pkexec, which starts
+	// this program again with --network or --power
+	if len(os.Args) >= 3 {
+		if run, ok := rootCommands[os.Args[1]]; ok {
+			if err := run(os.Args[2:]); err != nil {
+				fmt.Fprintf(os.Stderr, "raven-settings-menu: %v\n", err)
+				os.Exit(1)
+			}
+			return
+		}
+	}
+
+	// --page NAME opens the window on a page, e.g. --page Network
+	if len(os.Args) == 3 && os.Args[1] == "--page" {
+		menu.startPage = os.Args[2]
+		os.Args = os.Args[:1]
+	}
+
 	app.ConnectActivate(func() {
 		menu.activate()
 	})
+	app.ConnectShutdown(menu.flushSettings)
 
 	if code := app.Run(os.Args); code > 0 {
 		os.Exit(code)
@@ -113,12 +176,17 @@ func (m *RavenSettingsMenu) activate() {
 	m.window.SetDefaultSize(800, 600)
 	m.window.SetDecorated(false)
 
-	// Initialize categories
-	m.initCategories()
+	// Collect built-in, registered and external pages
+	m.initPages()
 
 	// Load settings
 	m.loadSettings()
 
+	// Take in settings other components change while the window is open
+	if err := watchSettingsChanges(m.settingsChanged); err != nil {
+		fmt.Fprintf(os.Stderr, "raven-settings-menu: not following settings changes: %v\n", err)
+	}
+
 	// Apply CSS
 	m.applyCSS()
 
@@ -152,17 +220,42 @@ func (m *RavenSettingsMenu) initLayerShell() {
 	}
 }
 
-func (m *RavenSettingsMenu) initCategories() {
-	m.categories = []SettingsCategory{
-		{Name: "Appearance", Icon: "preferences-desktop-theme", Description: "Theme, colors, and fonts"},
-		{Name: "Desktop", Icon: "preferences-desktop-wallpaper", Description: "Wallpaper and desktop icons"},
-		{Name: "Panel", Icon: "preferences-desktop-display", Description: "Panel position and widgets"},
-		{Name: "Windows", Icon: "preferences-system-windows", Description: "Window behavior and borders"},
-		{Name: "Input", Icon: "input-keyboard", Description: "Keyboard and mouse settings"},
-		{Name: "Power", Icon: "preferences-system-power", Description: "Power management options"},
-		{Name: "Sound", Icon: "audio-volume-high", Description: "Audio settings"},
-		{Name: "About", Icon: "help-about", Description: "System information"},
+func (m *RavenSettingsMenu) initPages() {
+	builtin := func(info pages.Info, create func() *gtk.ScrolledWindow) pages.Page {
+		return pages.New(info, func(pages.Host) gtk.Widgetter { return create() })
+	}
+
+	m.pages = []pages.Page{
+		builtin(pages.Info{Name: "Appearance", Icon: "preferences-desktop-theme", Description: "Theme, colors, and fonts", Order: 10}, m.createAppearancePage),
+		builtin(pages.Info{Name: "Desktop", Icon: "preferences-desktop-wallpaper", Description: "Wallpaper and desktop icons", Order: 20}, m.createDesktopPage),
+		builtin(pages.Info{Name: "Displays", Icon: "video-display", Description: "Resolution, scale and layout", Order: 25}, m.createDisplaysPage),
+		builtin(pages.Info{Name: "Panel", Icon: "preferences-desktop-display", Description: "Panel position and widgets", Order: 30}, m.createPanelPage),
+		builtin(pages.Info{Name: "Network", Icon: "network-wired", Description: "Connections, WiFi and VPN", Order: 35}, m.createNetworkPage),
+		builtin(pages.Info{Name: "Bluetooth", Icon: "bluetooth", Description: "Adapter and paired devices", Order: 37}, m.createBluetoothPage),
+		builtin(pages.Info{Name: "Printers", Icon: "printer", Description: "Printers and print jobs", Order: 38}, m.createPrintersPage),
+		builtin(pages.Info{Name: "Windows", Icon: "preferences-system-windows", Description: "Window behavior and borders", Order: 40}, m.createWindowsPage),
+		builtin(pages.Info{Name: "Input", Icon: "input-keyboard", Description: "Keyboard and mouse settings", Order: 50}, m.createInputPage),
+		builtin(pages.Info{Name: "Shortcuts", Icon: "preferences-desktop-keyboard-shortcuts", Description: "Hyprland key bindings", Order: 55}, m.createShortcutsPage),
+		builtin(pages.Info{Name: "Power", Icon: "preferences-system-power", Description: "Power management options", Order: 60}, m.createPowerPage),
+		builtin(pages.Info{Name: "Sound", Icon: "audio-volume-high", Description: "Audio settings", Order: 70}, m.createSoundPage),
+		builtin(pages.Info{Name: "Services", Icon: "system-run", Description: "Background services of the session", Order: 80}, m.createServicesPage),
+		builtin(pages.Info{Name: "Labs", Icon: "applications-science", Description: "Experimental features", Order: 900}, m.createLabsPage),
+		builtin(pages.Info{Name: "About", Icon: "help-about", Description: "System information", Order: 1000}, m.createAboutPage),
 	}
+
+	// Pages from other packages and external processes; the first page
+	// with a name wins, so nothing can replace a built-in page
+	seen := make(map[string]bool)
+	for _, page := range m.pages {
+		seen[page.Info().Name] = true
+	}
+	for _, page := range append(pages.Registered(), pages.LoadManifests()...) {
+		if name := page.Info().Name; !seen[name] {
+			seen[name] = true
+			m.pages = append(m.pages, page)
+		}
+	}
+	pages.Sort(m.pages)
 }
 
 func (m *RavenSettingsMenu) loadSettings() {
@@ -178,9 +271,12 @@ func (m *RavenSettingsMenu) loadSettings() {
 		CursorTheme:           "Adwaita",
 		PanelOpacity:          0.95,
 		EnableAnimations:      true,
+		ThemeSchedule:         "off",
+		LightThemeStart:       "07:00",
+		DarkThemeStart:        "19:00",
 		WallpaperPath:         "",
 		WallpaperMode:         "fill",
-		ShowDesktopIcons:      false,
+		ShowDesktopIcons:      true,
 		PanelPosition:         "top",
 		PanelHeight:           36,
 		ShowClock:             true,
@@ -197,24 +293,62 @@ func (m *RavenSettingsMenu) loadSettings() {
 		ScreenTimeout:         300,
 		SuspendTimeout:        900,
 		LidCloseAction:        "suspend",
+		NightLightTemperature: 4500,
 		MasterVolume:          80,
 		MuteOnLock:            false,
+		FeatureFlags:          map[string]bool{},
 	}
 
-	// Try to load existing settings
-	data, err := os.ReadFile(m.settingsPath)
-	if err == nil {
-		json.Unmarshal(data, &m.settings)
+	// Load existing settings over the defaults. A value of the wrong type
+	// keeps its default, as does one no page would set.
+	defaults := m.settings
+	if fields, err := json.Marshal(m.readSettingsFile()); err == nil {
+		json.Unmarshal(fields, &m.settings)
+	}
+	for _, key := range m.settings.validate(defaults) {
+		fmt.Fprintf(os.Stderr, "raven-settings-menu: invalid %s in %s, using the default\n", key, m.settingsPath)
 	}
 }
 
+// saveSettings writes our fields into settings.json. Changes are held back
+// for a moment, so dragging a slider writes the file once rather than at
+// every step.
 func (m *RavenSettingsMenu) saveSettings() {
-	configDir := filepath.Dir(m.settingsPath)
-	os.MkdirAll(configDir, 0755)
+	// Without a window (--import) there is no main loop to wait in
+	if m.window == nil {
+		m.writeSettings()
+		return
+	}
+	if m.saveTimer != 0 {
+		glib.SourceRemove(m.saveTimer)
+	}
+	m.saveTimer = glib.TimeoutAdd(settingsSaveDelay, func() bool {
+		m.saveTimer = 0
+		m.writeSettings()
+		return false
+	})
+}
+
+// flushSettings writes changes saveSettings is still holding back
+func (m *RavenSettingsMenu) flushSettings() {
+	if m.saveTimer != 0 {
+		glib.SourceRemove(m.saveTimer)
+		m.saveTimer = 0
+		m.writeSettings()
+	}
+}
 
-	data, err := json.MarshalIndent(m.settings, "", "  ")
-	if err == nil {
-		os.WriteFile(m.settingsPath, data, 0644)
+// writeSettings writes our fields into settings.json now. The file is
+// shared with other components and plugin pages, so their keys are kept.
+func (m *RavenSettingsMenu) writeSettings() {
+	fields, err := json.Marshal(m.settings)
+	if err != nil {
+		return
+	}
+	config := m.readSettingsFile()
+	json.Unmarshal(fields, &config)
+	if err := m.writeSettingsFile(config); err != nil {
+		fmt.Fprintf(os.Stderr, "raven-settings-menu: failed to write %s: %v\n", m.settingsPath, err)
 	}
 }
 
@@ -279,6 +413,30 @@ func (m *RavenSettingsMenu) applyCSS() {
 			border-radius: 8px;
 			padding: 12px 16px;
 			margin-bottom: 8px;
+			transition: background-color 300ms;
+		}
+		.setting-row.setting-highlight {
+			background-color: rgba(0, 150, 136, 0.35);
+		}
+		.search-results {
+			background-color: transparent;
+		}
+		.bluetooth-found {
+			background-color: #1a2332;
+			border-radius: 8px;
+			padding: 4px 12px;
+		}
+		.printers-found {
+			background-color: #1a2332;
+			border-radius: 8px;
+			padding: 4px 12px;
+		}
+		.search-results row {
+			padding: 4px 8px;
+			border-radius: 6px;
+		}
+		.search-results row:selected {
+			background-color: #009688;
 		}
 		.setting-label {
 			color: #e0e0e0;
@@ -365,6 +523,10 @@ func (m *RavenSettingsMenu) applyCSS() {
 		button.primary:hover {
 			background-color: #00796b;
 		}
+		.labs-warning {
+			background-color: rgba(255, 160, 0, 0.15);
+			border: 1px solid rgba(255, 160, 0, 0.5);
+		}
 		button.destructive {
 			background-color: #b71c1c;
 			border-color: #b71c1c;
@@ -414,6 +576,45 @@ func (m *RavenSettingsMenu) applyCSS() {
 		spinbutton button:hover {
 			background-color: #252f3f;
 		}
+		.wallpaper-preview, .wallpaper-thumb {
+			border-radius: 6px;
+			background-color: #0f1720;
+		}
+		flowboxchild {
+			padding: 2px;
+			border-radius: 8px;
+		}
+		flowboxchild:hover .wallpaper-thumb {
+			opacity: 0.8;
+		}
+		.display-canvas {
+			background-color: #0f1720;
+			border-radius: 6px;
+		}
+		.display-monitor {
+			background-color: #252f3f;
+			border: 2px solid #333;
+			border-radius: 4px;
+		}
+		.display-monitor.selected {
+			border-color: #009688;
+		}
+		.shortcut-keys {
+			font-family: monospace;
+			min-width: 160px;
+		}
+		.shortcut-conflict {
+			border: 1px solid #ff9800;
+			color: #ff9800;
+		}
+		.service-log {
+			font-family: monospace;
+			font-size: 11px;
+			color: #aaa;
+			padding: 8px;
+			background-color: #0f1720;
+			border-radius: 6px;
+		}
 		.color-button {
 			min-width: 48px;
 			min-height: 32px;
@@ -450,22 +651,27 @@ func (m *RavenSettingsMenu) createUI() *gtk.Box {
 	m.contentStack.AddCSSClass("content-area")
 	m.contentStack.SetHExpand(true)
 
-	// Create pages for each category
-	m.contentStack.AddNamed(m.createAppearancePage(), "Appearance")
-	m.contentStack.AddNamed(m.createDesktopPage(), "Desktop")
-	m.contentStack.AddNamed(m.createPanelPage(), "Panel")
-	m.contentStack.AddNamed(m.createWindowsPage(), "Windows")
-	m.contentStack.AddNamed(m.createInputPage(), "Input")
-	m.contentStack.AddNamed(m.createPowerPage(), "Power")
-	m.contentStack.AddNamed(m.createSoundPage(), "Sound")
-	m.contentStack.AddNamed(m.createAboutPage(), "About")
+	// Create the content of each page
+	m.pageWidgets = nil
+	m.settingEntries = nil
+	for _, page := range m.pages {
+		widget := page.Build(m)
+		m.pageWidgets = append(m.pageWidgets, widget)
+		m.contentStack.AddNamed(widget, page.Info().Name)
+	}
 
 	contentBox.Append(m.contentStack)
 	mainBox.Append(contentBox)
 
-	// Select first category
+	// Select the --page category, or else the first
+	start := 0
+	for i, page := range m.pages {
+		if strings.EqualFold(page.Info().Name, m.startPage) {
+			start = i
+		}
+	}
 	glib.IdleAdd(func() {
-		if firstRow := m.categoryList.RowAtIndex(0); firstRow != nil {
+		if firstRow := m.categoryList.RowAtIndex(start); firstRow != nil {
 			m.categoryList.SelectRow(firstRow)
 		}
 	})
@@ -493,6 +699,12 @@ func (m *RavenSettingsMenu) createHeader() *gtk.Box {
 
 	header.Append(titleBox)
 
+	// Search across every page
+	header.Append(m.createSearchEntry())
+
+	// Profiles, export and import
+	header.Append(m.createProfileControls())
+
 	// Close button
 	closeBtn := gtk.NewButton()
 	closeBtn.SetLabel("X")
@@ -520,14 +732,14 @@ func (m *RavenSettingsMenu) createSidebar() *gtk.Box {
 	m.categoryList.ConnectRowSelected(func(row *gtk.ListBoxRow) {
 		if row != nil {
 			idx := row.Index()
-			if idx >= 0 && idx < len(m.categories) {
-				m.contentStack.SetVisibleChildName(m.categories[idx].Name)
+			if idx >= 0 && idx < len(m.pages) {
+				m.contentStack.SetVisibleChildName(m.pages[idx].Info().Name)
 			}
 		}
 	})
 
-	for _, cat := range m.categories {
-		row := m.createCategoryRow(cat)
+	for _, page := range m.pages {
+		row := m.createCategoryRow(page.Info())
 		m.categoryList.Append(row)
 	}
 
@@ -537,7 +749,7 @@ func (m *RavenSettingsMenu) createSidebar() *gtk.Box {
 	return sidebar
 }
 
-func (m *RavenSettingsMenu) createCategoryRow(cat SettingsCategory) *gtk.ListBoxRow {
+func (m *RavenSettingsMenu) createCategoryRow(cat pages.Info) *gtk.ListBoxRow {
 	row := gtk.NewListBoxRow()
 
 	box := gtk.NewBox(gtk.OrientationVertical, 4)
@@ -581,6 +793,7 @@ func (m *RavenSettingsMenu) createSettingRow(title, description string, control
 	row.Append(labelBox)
 	row.Append(control)
 
+	m.settingEntries = append(m.settingEntries, settingEntry{title: title, description: description, row: row})
 	return row
 }
 
@@ -620,6 +833,9 @@ func (m *RavenSettingsMenu) createAppearancePage() *gtk.ScrolledWindow {
 	})
 	content.Append(m.createSettingRow("Theme", "Choose your preferred color theme", themeDropdown))
 
+	// Day/night switching
+	m.appendThemeScheduleRows(content)
+
 	// Accent color
 	accentColors := []string{"#009688", "#2196F3", "#9C27B0", "#FF5722", "#4CAF50", "#FFC107"}
 	accentBox := gtk.NewBox(gtk.OrientationHorizontal, 8)
@@ -692,51 +908,8 @@ func (m *RavenSettingsMenu) createDesktopPage() *gtk.ScrolledWindow {
 	sectionTitle.SetHAlign(gtk.AlignStart)
 	content.Append(sectionTitle)
 
-	// Wallpaper path with browse button
-	wallpaperBox := gtk.NewBox(gtk.OrientationHorizontal, 8)
-	wallpaperEntry := gtk.NewEntry()
-	wallpaperEntry.SetText(m.settings.WallpaperPath)
-	wallpaperEntry.SetHExpand(true)
-	wallpaperEntry.ConnectChanged(func() {
-		m.settings.WallpaperPath = wallpaperEntry.Text()
-		m.saveSettings()
-	})
-	wallpaperBox.Append(wallpaperEntry)
-
-	browseBtn := gtk.NewButton()
-	browseBtn.SetLabel("Browse")
-	browseBtn.ConnectClicked(func() {
-		dialog := gtk.NewFileChooserNative(
-			"Select Wallpaper",
-			m.window,
-			gtk.FileChooserActionOpen,
-			"Select",
-			"Cancel",
-		)
-
-		filter := gtk.NewFileFilter()
-		filter.SetName("Images")
-		filter.AddMIMEType("image/png")
-		filter.AddMIMEType("image/jpeg")
-		filter.AddMIMEType("image/webp")
-		dialog.AddFilter(filter)
-
-		dialog.ConnectResponse(func(response int) {
-			if response == int(gtk.ResponseAccept) {
-				file := dialog.File()
-				if file != nil {
-					path := file.Path()
-					wallpaperEntry.SetText(path)
-					m.settings.WallpaperPath = path
-					m.saveSettings()
-					m.applyWallpaper()
-				}
-			}
-		})
-		dialog.Show()
-	})
-	wallpaperBox.Append(browseBtn)
-	content.Append(m.createSettingRow("Wallpaper", "Background image path", wallpaperBox))
+	// Wallpaper preview, recent wallpapers and gallery
+	content.Append(m.createWallpaperSection())
 
 	// Wallpaper mode
 	modes := []string{"fill", "fit", "stretch", "center", "tile"}
@@ -768,6 +941,7 @@ func (m *RavenSettingsMenu) createDesktopPage() *gtk.ScrolledWindow {
 	content.Append(m.createSettingRow("Show Desktop Icons", "Display icons on the desktop", iconsSwitch))
 
 	scroll.SetChild(content)
+	m.attachWallpaperDrop(scroll)
 	return scroll
 }
 
@@ -937,6 +1111,7 @@ func (m *RavenSettingsMenu) createInputPage() *gtk.ScrolledWindow {
 		if idx < uint(len(layouts)) {
 			m.settings.KeyboardLayout = layouts[idx]
 			m.saveSettings()
+			m.applyInputSettings()
 		}
 	})
 	content.Append(m.createSettingRow("Keyboard Layout", "Keyboard language layout", layoutDropdown))
@@ -949,6 +1124,7 @@ func (m *RavenSettingsMenu) createInputPage() *gtk.ScrolledWindow {
 	speedScale.ConnectValueChanged(func() {
 		m.settings.MouseSpeed = speedScale.Value() / 100
 		m.saveSettings()
+		m.applyInputSettings()
 	})
 	content.Append(m.createSettingRow("Mouse Speed", "Pointer acceleration", speedScale))
 
@@ -958,6 +1134,7 @@ func (m *RavenSettingsMenu) createInputPage() *gtk.ScrolledWindow {
 	naturalSwitch.ConnectStateSet(func(state bool) bool {
 		m.settings.TouchpadNaturalScroll = state
 		m.saveSettings()
+		m.applyInputSettings()
 		return false
 	})
 	content.Append(m.createSettingRow("Natural Scrolling", "Reverse scroll direction on touchpad", naturalSwitch))
@@ -968,6 +1145,7 @@ func (m *RavenSettingsMenu) createInputPage() *gtk.ScrolledWindow {
 	tapSwitch.ConnectStateSet(func(state bool) bool {
 		m.settings.TouchpadTapToClick = state
 		m.saveSettings()
+		m.applyInputSettings()
 		return false
 	})
 	content.Append(m.createSettingRow("Tap to Click", "Tap touchpad to click", tapSwitch))
@@ -976,217 +1154,6 @@ func (m *RavenSettingsMenu) createInputPage() *gtk.ScrolledWindow {
 	return scroll
 }
 
-func (m *RavenSettingsMenu) createPowerPage() *gtk.ScrolledWindow {
-	scroll := gtk.NewScrolledWindow()
-	scroll.SetPolicy(gtk.PolicyNever, gtk.PolicyAutomatic)
-
-	content := gtk.NewBox(gtk.OrientationVertical, 16)
-	content.SetMarginStart(20)
-	content.SetMarginEnd(20)
-	content.SetMarginTop(20)
-	content.SetMarginBottom(20)
-
-	sectionTitle := gtk.NewLabel("Power")
-	sectionTitle.AddCSSClass("section-title")
-	sectionTitle.SetHAlign(gtk.AlignStart)
-	content.Append(sectionTitle)
-
-	// Screen timeout
-	screenTimeouts := []int{0, 60, 300, 600, 900, 1800}
-	screenDropdown := gtk.NewDropDown(gtk.NewStringList([]string{"Never", "1 minute", "5 minutes", "10 minutes", "15 minutes", "30 minutes"}), nil)
-	for i, timeout := range screenTimeouts {
-		if timeout == m.settings.ScreenTimeout {
-			screenDropdown.SetSelected(uint(i))
-			break
-		}
-	}
-	screenDropdown.Connect("notify::selected", func() {
-		idx := screenDropdown.Selected()
-		if idx < uint(len(screenTimeouts)) {
-			m.settings.ScreenTimeout = screenTimeouts[idx]
-			m.saveSettings()
-		}
-	})
-	content.Append(m.createSettingRow("Screen Timeout", "Turn off display after inactivity", screenDropdown))
-
-	// Suspend timeout
-	suspendTimeouts := []int{0, 300, 900, 1800, 3600, 7200}
-	suspendDropdown := gtk.NewDropDown(gtk.NewStringList([]string{"Never", "5 minutes", "15 minutes", "30 minutes", "1 hour", "2 hours"}), nil)
-	for i, timeout := range suspendTimeouts {
-		if timeout == m.settings.SuspendTimeout {
-			suspendDropdown.SetSelected(uint(i))
-			break
-		}
-	}
-	suspendDropdown.Connect("notify::selected", func() {
-		idx := suspendDropdown.Selected()
-		if idx < uint(len(suspendTimeouts)) {
-			m.settings.SuspendTimeout = suspendTimeouts[idx]
-			m.saveSettings()
-		}
-	})
-	content.Append(m.createSettingRow("Suspend Timeout", "Suspend system after inactivity", suspendDropdown))
-
-	// Lid close action
-	lidActions := []string{"suspend", "hibernate", "poweroff", "nothing"}
-	lidDropdown := gtk.NewDropDown(gtk.NewStringList([]string{"Suspend", "Hibernate", "Power Off", "Do Nothing"}), nil)
-	for i, action := range lidActions {
-		if action == m.settings.LidCloseAction {
-			lidDropdown.SetSelected(uint(i))
-			break
-		}
-	}
-	lidDropdown.Connect("notify::selected", func() {
-		idx := lidDropdown.Selected()
-		if idx < uint(len(lidActions)) {
-			m.settings.LidCloseAction = lidActions[idx]
-			m.saveSettings()
-		}
-	})
-	content.Append(m.createSettingRow("Lid Close Action", "Action when laptop lid is closed", lidDropdown))
-
-	scroll.SetChild(content)
-	return scroll
-}
-
-func (m *RavenSettingsMenu) createSoundPage() *gtk.ScrolledWindow {
-	scroll := gtk.NewScrolledWindow()
-	scroll.SetPolicy(gtk.PolicyNever, gtk.PolicyAutomatic)
-
-	content := gtk.NewBox(gtk.OrientationVertical, 16)
-	content.SetMarginStart(20)
-	content.SetMarginEnd(20)
-	content.SetMarginTop(20)
-	content.SetMarginBottom(20)
-
-	sectionTitle := gtk.NewLabel("Sound")
-	sectionTitle.AddCSSClass("section-title")
-	sectionTitle.SetHAlign(gtk.AlignStart)
-	content.Append(sectionTitle)
-
-	// Master volume
-	volumeAdj := gtk.NewAdjustment(float64(m.settings.MasterVolume), 0, 100, 5, 10, 0)
-	volumeScale := gtk.NewScale(gtk.OrientationHorizontal, volumeAdj)
-	volumeScale.SetSizeRequest(200, -1)
-	volumeScale.SetDrawValue(true)
-	volumeScale.ConnectValueChanged(func() {
-		m.settings.MasterVolume = int(volumeScale.Value())
-		m.saveSettings()
-		// Apply volume immediately
-		volume := strconv.Itoa(m.settings.MasterVolume)
-		exec.Command("wpctl", "set-volume", "@DEFAULT_AUDIO_SINK@", volume+"%").Start()
-	})
-	content.Append(m.createSettingRow("Master Volume", "System audio volume", volumeScale))
-
-	// Mute on lock
-	muteSwitch := gtk.NewSwitch()
-	muteSwitch.SetActive(m.settings.MuteOnLock)
-	muteSwitch.ConnectStateSet(func(state bool) bool {
-		m.settings.MuteOnLock = state
-		m.saveSettings()
-		return false
-	})
-	content.Append(m.createSettingRow("Mute on Lock", "Mute audio when screen is locked", muteSwitch))
-
-	// Audio output test button
-	testBtn := gtk.NewButton()
-	testBtn.SetLabel("Test Audio")
-	testBtn.ConnectClicked(func() {
-		exec.Command("paplay", "/usr/share/sounds/freedesktop/stereo/bell.oga").Start()
-	})
-	content.Append(m.createSettingRow("Test Audio Output", "Play a test sound", testBtn))
-
-	scroll.SetChild(content)
-	return scroll
-}
-
-func (m *RavenSettingsMenu) createAboutPage() *gtk.ScrolledWindow {
-	scroll := gtk.NewScrolledWindow()
-	scroll.SetPolicy(gtk.PolicyNever, gtk.PolicyAutomatic)
-
-	content := gtk.NewBox(gtk.OrientationVertical, 16)
-	content.SetMarginStart(40)
-	content.SetMarginEnd(40)
-	content.SetMarginTop(40)
-	content.SetMarginBottom(40)
-	content.SetHAlign(gtk.AlignCenter)
-
-	// Logo placeholder
-	logo := gtk.NewLabel("RAVEN")
-	logo.AddCSSClass("about-logo")
-	content.Append(logo)
-
-	// Title
-	title := gtk.NewLabel("Raven Linux")
-	title.AddCSSClass("about-title")
-	title.SetMarginTop(16)
-	content.Append(title)
-
-	// Version
-	version := gtk.NewLabel("Version 1.0.0")
-	version.AddCSSClass("about-version")
-	content.Append(version)
-
-	// Description
-	desc := gtk.NewLabel("A modern, lightweight Linux desktop environment\nbuilt with simplicity and performance in mind.")
-	desc.AddCSSClass("about-description")
-	desc.SetJustify(gtk.JustifyCenter)
-	content.Append(desc)
-
-	// System info section
-	infoBox := gtk.NewBox(gtk.OrientationVertical, 8)
-	infoBox.SetMarginTop(32)
-
-	// Get system info
-	hostname, _ := os.Hostname()
-	kernel, _ := exec.Command("uname", "-r").Output()
-
-	addInfoRow := func(label, value string) {
-		row := gtk.NewBox(gtk.OrientationHorizontal, 12)
-		row.SetHAlign(gtk.AlignCenter)
-
-		labelWidget := gtk.NewLabel(label + ":")
-		labelWidget.AddCSSClass("setting-description")
-		row.Append(labelWidget)
-
-		valueWidget := gtk.NewLabel(value)
-		valueWidget.AddCSSClass("setting-label")
-		row.Append(valueWidget)
-
-		infoBox.Append(row)
-	}
-
-	addInfoRow("Hostname", hostname)
-	addInfoRow("Kernel", strings.TrimSpace(string(kernel)))
-	addInfoRow("Desktop", "Raven Shell")
-
-	content.Append(infoBox)
-
-	// Links section
-	linksBox := gtk.NewBox(gtk.OrientationHorizontal, 16)
-	linksBox.SetHAlign(gtk.AlignCenter)
-	linksBox.SetMarginTop(32)
-
-	websiteBtn := gtk.NewButton()
-	websiteBtn.SetLabel("Website")
-	websiteBtn.ConnectClicked(func() {
-		exec.Command("xdg-open", "https://ravenlinux.org").Start()
-	})
-	linksBox.Append(websiteBtn)
-
-	docsBtn := gtk.NewButton()
-	docsBtn.SetLabel("Documentation")
-	docsBtn.ConnectClicked(func() {
-		exec.Command("xdg-open", "https://docs.ravenlinux.org").Start()
-	})
-	linksBox.Append(docsBtn)
-
-	content.Append(linksBox)
-
-	scroll.SetChild(content)
-	return scroll
-}
-
 func (m *RavenSettingsMenu) applyWallpaper() {
 	if m.settings.WallpaperPath == "" {
 		return
@@ -0,0 +1,405 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"raven-wifi/wifi"
+)
+
+// Interfaces set to a static address, which raven-dhcp applies at boot
+// instead of asking a DHCP server
+const networkConfigPath = "/etc/raven/network.json"
+
+// Tunnels wg-quick can bring up by name
+const wireguardDir = "/etc/wireguard"
+
+// netInterface is a network interface shown on the Network page
+type netInterface struct {
+	Name      string
+	Kind      string // "Wired", "Wireless", "WireGuard" or "Other"
+	State     string // Operational state, e.g. "UP"
+	MAC       string
+	Addresses []string // IPv4 addresses in CIDR form
+	Dynamic   bool     // An address was leased by DHCP
+	Gateway   string
+}
+
+// configurable reports whether the page offers DHCP and static settings
+func (ifc netInterface) configurable() bool {
+	return ifc.Kind == "Wired" || ifc.Kind == "Wireless"
+}
+
+// interfaceConfig is an interface in network.json. The format is shared
+// with raven-dhcp.
+type interfaceConfig struct {
+	Method  string   `json:"method"`  // "dhcp" or "static"
+	Address string   `json:"address"` // CIDR, e.g. 192.168.1.10/24
+	Gateway string   `json:"gateway"`
+	DNS     []string `json:"dns"`
+}
+
+type networkConfig struct {
+	Interfaces map[string]interfaceConfig `json:"interfaces"`
+}
+
+// validate checks a static config before it is applied
+func (c interfaceConfig) validate() error {
+	if c.Method != "static" {
+		return nil
+	}
+	if ip, _, err := net.ParseCIDR(c.Address); err != nil || ip.To4() == nil {
+		return fmt.Errorf("the address must be IPv4 with a prefix, e.g. 192.168.1.10/24")
+	}
+	if c.Gateway != "" && net.ParseIP(c.Gateway).To4() == nil {
+		return fmt.Errorf("invalid gateway %q", c.Gateway)
+	}
+	for _, server := range c.DNS {
+		if net.ParseIP(server).To4() == nil {
+			return fmt.Errorf("invalid DNS server %q", server)
+		}
+	}
+	return nil
+}
+
+// listInterfaces returns every interface but loopback, with its IPv4
+// addresses and default gateway
+func listInterfaces() ([]netInterface, error) {
+	out, err := exec.Command("ip", "-d", "-j", "addr", "show").Output()
+	if err != nil {
+		return nil, fmt.Errorf("ip addr: %w", err)
+	}
+
+	var links []struct {
+		IfName    string `json:"ifname"`
+		OperState string `json:"operstate"`
+		LinkType  string `json:"link_type"`
+		Address   string `json:"address"`
+		LinkInfo  struct {
+			InfoKind string `json:"info_kind"`
+		} `json:"linkinfo"`
+		AddrInfo []struct {
+			Family    string `json:"family"`
+			Local     string `json:"local"`
+			PrefixLen int    `json:"prefixlen"`
+			Dynamic   bool   `json:"dynamic"`
+		} `json:"addr_info"`
+	}
+	if err := json.Unmarshal(out, &links); err != nil {
+		return nil, fmt.Errorf("ip addr: %w", err)
+	}
+
+	gateways := defaultGateways()
+	var interfaces []netInterface
+	for _, link := range links {
+		if link.LinkType == "loopback" {
+			continue
+		}
+		ifc := netInterface{
+			Name:    link.IfName,
+			State:   link.OperState,
+			MAC:     link.Address,
+			Gateway: gateways[link.IfName],
+		}
+		switch {
+		case link.LinkInfo.InfoKind == "wireguard":
+			ifc.Kind = "WireGuard"
+		case isWireless(link.IfName):
+			ifc.Kind = "Wireless"
+		case link.LinkType == "ether" && link.LinkInfo.InfoKind == "":
+			ifc.Kind = "Wired"
+		default:
+			ifc.Kind = "Other"
+		}
+		for _, addr := range link.AddrInfo {
+			if addr.Family != "inet" {
+				continue
+			}
+			ifc.Addresses = append(ifc.Addresses, fmt.Sprintf("%s/%d", addr.Local, addr.PrefixLen))
+			ifc.Dynamic = ifc.Dynamic || addr.Dynamic
+		}
+		interfaces = append(interfaces, ifc)
+	}
+	return interfaces, nil
+}
+
+func isWireless(name string) bool {
+	_, err := os.Stat(filepath.Join("/sys/class/net", name, "wireless"))
+	return err == nil
+}
+
+// defaultGateways maps interfaces to the gateway of their default route
+func defaultGateways() map[string]string {
+	gateways := make(map[string]string)
+	out, err := exec.Command("ip", "-j", "-4", "route", "show", "default").Output()
+	if err != nil {
+		return gateways
+	}
+	var routes []struct {
+		Gateway string `json:"gateway"`
+		Dev     string `json:"dev"`
+	}
+	if json.Unmarshal(out, &routes) == nil {
+		for _, route := range routes {
+			if _, ok := gateways[route.Dev]; !ok {
+				gateways[route.Dev] = route.Gateway
+			}
+		}
+	}
+	return gateways
+}
+
+// nameservers returns the DNS servers in /etc/resolv.conf
+func nameservers() []string {
+	data, err := os.ReadFile("/etc/resolv.conf")
+	if err != nil {
+		return nil
+	}
+	var servers []string
+	for _, line := range strings.Split(string(data), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) >= 2 && fields[0] == "nameserver" {
+			servers = append(servers, fields[1])
+		}
+	}
+	return servers
+}
+
+// loadNetworkConfig reads network.json. It is world-readable, so the
+// page can show static settings without root.
+func loadNetworkConfig() networkConfig {
+	config := networkConfig{Interfaces: make(map[string]interfaceConfig)}
+	if data, err := os.ReadFile(networkConfigPath); err == nil {
+		json.Unmarshal(data, &config)
+	}
+	if config.Interfaces == nil {
+		config.Interfaces = make(map[string]interfaceConfig)
+	}
+	return config
+}
+
+// setInterfaceConfig applies an interface's DHCP or static settings and
+// records them for raven-dhcp. Needs root.
+func setInterfaceConfig(iface string, c interfaceConfig) error {
+	if err := c.validate(); err != nil {
+		return err
+	}
+
+	config := loadNetworkConfig()
+	if c.Method == "static" {
+		config.Interfaces[iface] = c
+	} else {
+		delete(config.Interfaces, iface)
+	}
+	data, err := json.MarshalIndent(config, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(networkConfigPath), 0755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(networkConfigPath, data, 0644); err != nil {
+		return err
+	}
+
+	// Same steps raven-dhcp takes for a lease
+	exec.Command("ip", "addr", "flush", "dev", iface).Run()
+	if err := runQuiet("ip", "link", "set", "dev", iface, "up"); err != nil {
+		return err
+	}
+	if c.Method != "static" {
+		wifi.RequestDHCP(iface)
+		return nil
+	}
+	if err := runQuiet("ip", "addr", "add", c.Address, "dev", iface); err != nil {
+		return err
+	}
+	if c.Gateway != "" {
+		if err := runQuiet("ip", "route", "replace", "default", "via", c.Gateway, "dev", iface); err != nil {
+			return err
+		}
+	}
+	if len(c.DNS) > 0 {
+		var b strings.Builder
+		b.WriteString("# Generated by raven-settings-menu\n")
+		for _, server := range c.DNS {
+			b.WriteString("nameserver " + server + "\n")
+		}
+		return os.WriteFile("/etc/resolv.conf", []byte(b.String()), 0644)
+	}
+	return nil
+}
+
+// runQuiet runs a command, returning its output as the error
+func runQuiet(name string, args ...string) error {
+	out, err := exec.Command(name, args...).CombinedOutput()
+	if err != nil {
+		if msg := strings.TrimSpace(string(out)); msg != "" {
+			return fmt.Errorf("%s: %s", name, msg)
+		}
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	return nil
+}
+
+// wireguardName matches the names wg-quick accepts for an interface
+var wireguardName = regexp.MustCompile(`^[a-zA-Z0-9_=+.-]{1,15}$`)
+
+// tunnelName returns the tunnel name of a WireGuard config file
+func tunnelName(path string) (string, error) {
+	name := strings.TrimSuffix(filepath.Base(path), ".conf")
+	if !wireguardName.MatchString(name) {
+		return "", fmt.Errorf("%q can't name a tunnel: use up to 15 letters, digits or _=+.-", name)
+	}
+	return name, nil
+}
+
+// importWireGuard copies a WireGuard config to /etc/wireguard. Needs root.
+func importWireGuard(src, name string) error {
+	if !wireguardName.MatchString(name) {
+		return fmt.Errorf("invalid tunnel name %q", name)
+	}
+	data, err := os.ReadFile(src)
+	if err != nil {
+		return err
+	}
+	if !strings.Contains(string(data), "[Interface]") || !strings.Contains(string(data), "[Peer]") {
+		return fmt.Errorf("%s is not a WireGuard config", filepath.Base(src))
+	}
+	if err := os.MkdirAll(wireguardDir, 0700); err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(wireguardDir, name+".conf"), data, 0600)
+}
+
+// removeWireGuard stops a tunnel and deletes its config. Needs root.
+func removeWireGuard(name string) error {
+	if !wireguardName.MatchString(name) {
+		return fmt.Errorf("invalid tunnel name %q", name)
+	}
+	exec.Command("wg-quick", "down", name).Run()
+	err := os.Remove(filepath.Join(wireguardDir, name+".conf"))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+// runNetworkCommand carries out a --network command, each a change to the
+// system's network that needs root:
+//
+//	forget SSID
+//	priority SSID N
+//	dhcp IFACE
+//	static IFACE ADDRESS GATEWAY [DNS...]
+//	wireguard-import FILE NAME
+//	wireguard-up NAME
+//	wireguard-down NAME
+//	wireguard-remove NAME
+func runNetworkCommand(args []string) error {
+	if len(args) == 0 {
+		return errors.New("missing network command")
+	}
+	want := map[string]int{
+		"forget": 2, "priority": 3, "dhcp": 2, "static": 4,
+		"wireguard-import": 3, "wireguard-up": 2, "wireguard-down": 2, "wireguard-remove": 2,
+	}
+	n, ok := want[args[0]]
+	if !ok {
+		return fmt.Errorf("unknown network command %q", args[0])
+	}
+	if len(args) < n {
+		return fmt.Errorf("%s: missing arguments", args[0])
+	}
+
+	switch args[0] {
+	case "forget":
+		return wifi.NewManager().ForgetNetwork(args[1])
+	case "priority":
+		priority, err := strconv.Atoi(args[2])
+		if err != nil {
+			return fmt.Errorf("invalid priority %q", args[2])
+		}
+		return wifi.NewManager().SetPriority(args[1], priority)
+	case "dhcp":
+		return setInterfaceConfig(args[1], interfaceConfig{Method: "dhcp"})
+	case "static":
+		return setInterfaceConfig(args[1], interfaceConfig{
+			Method:  "static",
+			Address: args[2],
+			Gateway: args[3],
+			DNS:     args[4:],
+		})
+	case "wireguard-import":
+		return importWireGuard(args[1], args[2])
+	case "wireguard-up":
+		return runQuiet("wg-quick", "up", args[1])
+	case "wireguard-down":
+		return runQuiet("wg-quick", "down", args[1])
+	default:
+		return removeWireGuard(args[1])
+	}
+}
+
+// rootCommands are the changes pkexec starts this program again for, by
+// their flag
+var rootCommands = map[string]func(args []string) error{
+	"--network": runNetworkCommand,
+	"--power":   runPowerCommand,
+}
+
+// asRoot runs a --network or --power command: in this process when
+// already root, otherwise through pkexec, which asks for the password
+func asRoot(flag string, args ...string) error {
+	if os.Geteuid() == 0 {
+		return rootCommands[flag](args)
+	}
+	pkexec, err := exec.LookPath("pkexec")
+	if err != nil {
+		return errors.New("this change needs root, and pkexec is not installed")
+	}
+	self, err := os.Executable()
+	if err != nil {
+		return err
+	}
+
+	out, err := exec.Command(pkexec, append([]string{self, flag}, args...)...).CombinedOutput()
+	if err != nil {
+		if msg := strings.TrimSpace(string(out)); msg != "" {
+			return errors.New(strings.TrimPrefix(msg, "raven-settings-menu: "))
+		}
+		return err
+	}
+	return nil
+}
+
+// savedNetwork is a saved WiFi network and its priority
+type savedNetwork struct {
+	SSID     string
+	Priority int
+}
+
+// loadSavedNetworks returns the saved WiFi networks, highest priority
+// first
+func loadSavedNetworks(wm *wifi.Manager) []savedNetwork {
+	ssids, _ := wm.GetSavedNetworks()
+	priorities := wm.GetPriorities()
+
+	var saved []savedNetwork
+	for _, ssid := range ssids {
+		saved = append(saved, savedNetwork{SSID: ssid, Priority: priorities[ssid]})
+	}
+	sort.SliceStable(saved, func(i, j int) bool {
+		return saved[i].Priority > saved[j].Priority
+	})
+	return saved
+}
@@ -0,0 +1,497 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/diamondburned/gotk4/pkg/glib/v2"
+	"github.com/diamondburned/gotk4/pkg/gtk/v4"
+
+	"raven-wifi/wifi"
+)
+
+// How long a priority must stay unchanged before it is saved, so a run
+// of clicks on the spin button asks for the password once
+const priorityDelay = 800
+
+// networkPage holds the sections of the Network page, which are refilled
+// from the system on every refresh
+type networkPage struct {
+	m          *RavenSettingsMenu
+	message    *gtk.Label
+	interfaces *gtk.Box
+	saved      *gtk.Box
+	tunnels    *gtk.Box
+}
+
+// networkState is what the Network page shows, read off the main loop
+type networkState struct {
+	interfaces       []netInterface
+	dns              []string
+	config           networkConfig
+	wifiBackend      string
+	supportsPriority bool
+	connectedSSID    string
+	saved            []savedNetwork
+	err              error
+}
+
+func loadNetworkState() networkState {
+	var state networkState
+	state.interfaces, state.err = listInterfaces()
+	state.dns = nameservers()
+	state.config = loadNetworkConfig()
+
+	wm := wifi.NewManager()
+	state.wifiBackend = wm.Backend()
+	state.supportsPriority = wm.SupportsPriority()
+	if status, err := wm.GetStatus(); err == nil && status.Connected {
+		state.connectedSSID = status.SSID
+	}
+	state.saved = loadSavedNetworks(wm)
+	return state
+}
+
+func (m *RavenSettingsMenu) createNetworkPage() *gtk.ScrolledWindow {
+	scroll := gtk.NewScrolledWindow()
+	scroll.SetPolicy(gtk.PolicyNever, gtk.PolicyAutomatic)
+
+	content := gtk.NewBox(gtk.OrientationVertical, 16)
+	content.SetMarginStart(20)
+	content.SetMarginEnd(20)
+	content.SetMarginTop(20)
+	content.SetMarginBottom(20)
+
+	p := &networkPage{m: m}
+
+	titleBox := gtk.NewBox(gtk.OrientationHorizontal, 12)
+	sectionTitle := gtk.NewLabel("Network")
+	sectionTitle.AddCSSClass("section-title")
+	sectionTitle.SetHAlign(gtk.AlignStart)
+	sectionTitle.SetHExpand(true)
+	titleBox.Append(sectionTitle)
+	refreshBtn := gtk.NewButtonWithLabel("Refresh")
+	refreshBtn.SetVAlign(gtk.AlignCenter)
+	refreshBtn.ConnectClicked(p.refresh)
+	titleBox.Append(refreshBtn)
+	content.Append(titleBox)
+
+	p.message = gtk.NewLabel("")
+	p.message.AddCSSClass("setting-description")
+	p.message.SetHAlign(gtk.AlignStart)
+	p.message.SetWrap(true)
+	p.message.SetVisible(false)
+	content.Append(p.message)
+
+	p.interfaces = gtk.NewBox(gtk.OrientationVertical, 0)
+	content.Append(p.interfaces)
+
+	wifiTitle := gtk.NewLabel("WiFi")
+	wifiTitle.AddCSSClass("section-title")
+	wifiTitle.SetHAlign(gtk.AlignStart)
+	content.Append(wifiTitle)
+
+	connectBtn := gtk.NewButtonWithLabel("Open")
+	connectBtn.ConnectClicked(func() {
+		if err := exec.Command("raven-wifi").Start(); err != nil {
+			p.showError(fmt.Errorf("could not start raven-wifi: %w", err))
+		}
+	})
+	content.Append(m.createSettingRow("Connect to a Network", "Scan for networks and join one in raven-wifi", connectBtn))
+
+	p.saved = gtk.NewBox(gtk.OrientationVertical, 0)
+	content.Append(p.saved)
+
+	vpnTitle := gtk.NewLabel("VPN")
+	vpnTitle.AddCSSClass("section-title")
+	vpnTitle.SetHAlign(gtk.AlignStart)
+	content.Append(vpnTitle)
+
+	importBtn := gtk.NewButtonWithLabel("Import")
+	importBtn.ConnectClicked(func() {
+		p.chooseWireGuard(importBtn)
+	})
+	content.Append(m.createSettingRow("WireGuard", "Import a WireGuard .conf file as a tunnel", importBtn))
+
+	p.tunnels = gtk.NewBox(gtk.OrientationVertical, 0)
+	content.Append(p.tunnels)
+
+	p.refresh()
+
+	scroll.SetChild(content)
+	return scroll
+}
+
+// refresh reads the network state and rebuilds the sections
+func (p *networkPage) refresh() {
+	go func() {
+		state := loadNetworkState()
+		glib.IdleAdd(func() {
+			p.render(state)
+		})
+	}()
+}
+
+func (p *networkPage) render(state networkState) {
+	for _, box := range []*gtk.Box{p.interfaces, p.saved, p.tunnels} {
+		for child := box.FirstChild(); child != nil; child = box.FirstChild() {
+			box.Remove(child)
+		}
+	}
+	p.message.SetVisible(false)
+	if state.err != nil {
+		p.showError(state.err)
+	}
+
+	for _, ifc := range state.interfaces {
+		p.interfaces.Append(p.createInterfaceRow(ifc, state))
+	}
+
+	switch {
+	case state.wifiBackend == "none":
+		p.saved.Append(p.note("No WiFi service is running (iwd or wpa_supplicant)"))
+	case len(state.saved) == 0:
+		p.saved.Append(p.note("No saved networks"))
+	}
+	if state.wifiBackend != "none" {
+		for _, network := range state.saved {
+			p.saved.Append(p.createSavedNetworkRow(network, state))
+		}
+	}
+
+	p.renderTunnels(state)
+}
+
+// note is a line of muted text in a section
+func (p *networkPage) note(text string) *gtk.Label {
+	label := gtk.NewLabel(text)
+	label.AddCSSClass("setting-description")
+	label.SetHAlign(gtk.AlignStart)
+	label.SetMarginBottom(8)
+	return label
+}
+
+// showError reports a failed change at the top of the page
+func (p *networkPage) showError(err error) {
+	fmt.Fprintf(os.Stderr, "raven-settings-menu: %v\n", err)
+	p.message.SetText(err.Error())
+	p.message.SetVisible(true)
+}
+
+// run carries out a --network command as root off the main loop. widget
+// is disabled meanwhile; done is called if the command succeeded.
+func (p *networkPage) run(widget gtk.Widgetter, done func(), args ...string) {
+	gtk.BaseWidget(widget).SetSensitive(false)
+	go func() {
+		err := asRoot("--network", args...)
+		glib.IdleAdd(func() {
+			gtk.BaseWidget(widget).SetSensitive(true)
+			if err != nil {
+				p.showError(err)
+			} else if done != nil {
+				done()
+			}
+			p.refresh()
+		})
+	}()
+}
+
+// createInterfaceRow shows an interface's addresses and, for wired and
+// wireless ones, lets it switch between DHCP and a static address
+func (p *networkPage) createInterfaceRow(ifc netInterface, state networkState) *gtk.Box {
+	box := gtk.NewBox(gtk.OrientationVertical, 8)
+	box.AddCSSClass("setting-row")
+
+	header := gtk.NewBox(gtk.OrientationHorizontal, 12)
+	labels := gtk.NewBox(gtk.OrientationVertical, 4)
+	labels.SetHExpand(true)
+	title := gtk.NewLabel(ifc.Name)
+	title.AddCSSClass("setting-label")
+	title.SetHAlign(gtk.AlignStart)
+	labels.Append(title)
+	desc := gtk.NewLabel(interfaceSummary(ifc))
+	desc.AddCSSClass("setting-description")
+	desc.SetHAlign(gtk.AlignStart)
+	labels.Append(desc)
+	header.Append(labels)
+	box.Append(header)
+
+	details := gtk.NewGrid()
+	details.SetColumnSpacing(16)
+	details.SetRowSpacing(4)
+	dns := "None"
+	if len(state.dns) > 0 {
+		dns = strings.Join(state.dns, ", ")
+	}
+	for i, field := range [][2]string{
+		{"Hardware Address", orNone(ifc.MAC)},
+		{"IPv4 Address", orNone(strings.Join(ifc.Addresses, ", "))},
+		{"Gateway", orNone(ifc.Gateway)},
+		{"DNS", dns},
+	} {
+		name := gtk.NewLabel(field[0])
+		name.AddCSSClass("setting-description")
+		name.SetHAlign(gtk.AlignStart)
+		value := gtk.NewLabel(field[1])
+		value.SetHAlign(gtk.AlignStart)
+		value.SetSelectable(true)
+		details.Attach(name, 0, i, 1, 1)
+		details.Attach(value, 1, i, 1, 1)
+	}
+	expander := gtk.NewExpander("Details")
+	expander.SetChild(details)
+	box.Append(expander)
+
+	if !ifc.configurable() {
+		return box
+	}
+
+	// The form starts from the saved static settings, or else from what
+	// the interface has now
+	config, static := state.config.Interfaces[ifc.Name]
+	if !static {
+		config = interfaceConfig{Gateway: ifc.Gateway, DNS: state.dns}
+		if len(ifc.Addresses) > 0 {
+			config.Address = ifc.Addresses[0]
+		}
+	}
+
+	method := gtk.NewDropDown(gtk.NewStringList([]string{"DHCP", "Static"}), nil)
+	method.SetVAlign(gtk.AlignCenter)
+	if static {
+		method.SetSelected(1)
+	}
+	header.Append(method)
+
+	applyBtn := gtk.NewButtonWithLabel("Apply")
+	applyBtn.SetVAlign(gtk.AlignCenter)
+	header.Append(applyBtn)
+
+	form := gtk.NewGrid()
+	form.SetColumnSpacing(12)
+	form.SetRowSpacing(8)
+	addressEntry := gtk.NewEntry()
+	addressEntry.SetText(config.Address)
+	addressEntry.SetPlaceholderText("192.168.1.10/24")
+	addressEntry.SetHExpand(true)
+	gatewayEntry := gtk.NewEntry()
+	gatewayEntry.SetText(config.Gateway)
+	gatewayEntry.SetPlaceholderText("192.168.1.1")
+	dnsEntry := gtk.NewEntry()
+	dnsEntry.SetText(strings.Join(config.DNS, ", "))
+	dnsEntry.SetPlaceholderText("1.1.1.1, 9.9.9.9")
+	for i, field := range []struct {
+		label string
+		entry *gtk.Entry
+	}{
+		{"Address", addressEntry},
+		{"Gateway", gatewayEntry},
+		{"DNS Servers", dnsEntry},
+	} {
+		label := gtk.NewLabel(field.label)
+		label.AddCSSClass("setting-description")
+		label.SetHAlign(gtk.AlignStart)
+		form.Attach(label, 0, i, 1, 1)
+		form.Attach(field.entry, 1, i, 1, 1)
+	}
+	form.SetVisible(static)
+	box.Append(form)
+
+	method.Connect("notify::selected", func() {
+		form.SetVisible(method.Selected() == 1)
+	})
+
+	applyBtn.ConnectClicked(func() {
+		if method.Selected() == 0 {
+			p.run(applyBtn, nil, "dhcp", ifc.Name)
+			return
+		}
+		c := interfaceConfig{
+			Method:  "static",
+			Address: strings.TrimSpace(addressEntry.Text()),
+			Gateway: strings.TrimSpace(gatewayEntry.Text()),
+			DNS: strings.FieldsFunc(dnsEntry.Text(), func(r rune) bool {
+				return r == ',' || unicode.IsSpace(r)
+			}),
+		}
+		if err := c.validate(); err != nil {
+			p.showError(err)
+			return
+		}
+		p.run(applyBtn, nil, append([]string{"static", ifc.Name, c.Address, c.Gateway}, c.DNS...)...)
+	})
+
+	return box
+}
+
+// interfaceSummary is the line under an interface's name, e.g.
+// "Wired · Connected · 192.168.1.5/24"
+func interfaceSummary(ifc netInterface) string {
+	parts := []string{ifc.Kind}
+	switch {
+	case ifc.State == "UP" || (ifc.Kind == "WireGuard" && len(ifc.Addresses) > 0):
+		parts = append(parts, "Connected")
+	case ifc.State == "DOWN":
+		parts = append(parts, "Disconnected")
+	}
+	if len(ifc.Addresses) > 0 {
+		parts = append(parts, ifc.Addresses[0])
+		if ifc.Dynamic {
+			parts = append(parts, "DHCP")
+		}
+	}
+	return strings.Join(parts, " · ")
+}
+
+func orNone(value string) string {
+	if value == "" {
+		return "None"
+	}
+	return value
+}
+
+// createSavedNetworkRow shows a saved WiFi network with its priority and
+// a button to forget it
+func (p *networkPage) createSavedNetworkRow(network savedNetwork, state networkState) *gtk.Box {
+	controls := gtk.NewBox(gtk.OrientationHorizontal, 8)
+
+	priority := gtk.NewSpinButtonWithRange(0, 100, 1)
+	priority.SetValue(float64(network.Priority))
+	priority.SetVAlign(gtk.AlignCenter)
+	if state.supportsPriority {
+		priority.SetTooltipText("Networks with a higher priority are joined first")
+	} else {
+		priority.SetSensitive(false)
+		priority.SetTooltipText("iwd picks known networks by signal and last use")
+	}
+	var pending glib.SourceHandle
+	priority.ConnectValueChanged(func() {
+		if pending != 0 {
+			glib.SourceRemove(pending)
+		}
+		pending = glib.TimeoutAdd(priorityDelay, func() bool {
+			pending = 0
+			p.run(priority, nil, "priority", network.SSID, strconv.Itoa(priority.ValueAsInt()))
+			return false
+		})
+	})
+	controls.Append(priority)
+
+	forgetBtn := gtk.NewButtonWithLabel("Forget")
+	forgetBtn.SetVAlign(gtk.AlignCenter)
+	forgetBtn.ConnectClicked(func() {
+		p.run(forgetBtn, nil, "forget", network.SSID)
+	})
+	controls.Append(forgetBtn)
+
+	desc := "Saved"
+	if network.SSID == state.connectedSSID {
+		desc = "Connected"
+	}
+	return p.m.createSettingRow(network.SSID, desc, controls)
+}
+
+// renderTunnels lists the imported WireGuard tunnels and any other
+// WireGuard interface that is up
+func (p *networkPage) renderTunnels(state networkState) {
+	active := make(map[string]netInterface)
+	names := slices.Clone(p.m.settings.WireGuardTunnels)
+	for _, ifc := range state.interfaces {
+		if ifc.Kind != "WireGuard" {
+			continue
+		}
+		active[ifc.Name] = ifc
+		if !slices.Contains(names, ifc.Name) {
+			names = append(names, ifc.Name)
+		}
+	}
+	sort.Strings(names)
+	if len(names) == 0 {
+		p.tunnels.Append(p.note("No tunnels imported"))
+		return
+	}
+
+	for _, name := range names {
+		ifc, up := active[name]
+		desc := "Inactive"
+		if up {
+			desc = "Active"
+			if len(ifc.Addresses) > 0 {
+				desc += " · " + strings.Join(ifc.Addresses, ", ")
+			}
+		}
+
+		controls := gtk.NewBox(gtk.OrientationHorizontal, 8)
+		toggle := gtk.NewSwitch()
+		toggle.SetActive(up)
+		toggle.SetVAlign(gtk.AlignCenter)
+		toggle.ConnectStateSet(func(state bool) bool {
+			command := "wireguard-down"
+			if state {
+				command = "wireguard-up"
+			}
+			p.run(toggle, nil, command, name)
+			return false
+		})
+		controls.Append(toggle)
+
+		removeBtn := gtk.NewButtonWithLabel("Remove")
+		removeBtn.SetVAlign(gtk.AlignCenter)
+		removeBtn.ConnectClicked(func() {
+			p.run(removeBtn, func() {
+				p.m.settings.WireGuardTunnels = slices.DeleteFunc(p.m.settings.WireGuardTunnels, func(s string) bool {
+					return s == name
+				})
+				p.m.saveSettings()
+			}, "wireguard-remove", name)
+		})
+		controls.Append(removeBtn)
+
+		p.tunnels.Append(p.m.createSettingRow(name, desc, controls))
+	}
+}
+
+// chooseWireGuard picks a WireGuard config and imports it as a tunnel
+// named after the file
+func (p *networkPage) chooseWireGuard(button *gtk.Button) {
+	dialog := gtk.NewFileChooserNative(
+		"Import WireGuard Config",
+		p.m.window,
+		gtk.FileChooserActionOpen,
+		"Import",
+		"Cancel",
+	)
+
+	filter := gtk.NewFileFilter()
+	filter.SetName("WireGuard Configs")
+	filter.AddPattern("*.conf")
+	dialog.AddFilter(filter)
+
+	dialog.ConnectResponse(func(response int) {
+		if response != int(gtk.ResponseAccept) {
+			return
+		}
+		file := dialog.File()
+		if file == nil || file.Path() == "" {
+			return
+		}
+		name, err := tunnelName(file.Path())
+		if err != nil {
+			p.showError(err)
+			return
+		}
+		p.run(button, func() {
+			if !slices.Contains(p.m.settings.WireGuardTunnels, name) {
+				p.m.settings.WireGuardTunnels = append(p.m.settings.WireGuardTunnels, name)
+				p.m.saveSettings()
+			}
+		}, "wireguard-import", file.Path(), name)
+	})
+	dialog.Show()
+}
@@ -0,0 +1,323 @@
+package pages
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/diamondburned/gotk4/pkg/glib/v2"
+	"github.com/diamondburned/gotk4/pkg/gtk/v4"
+)
+
+// External pages are separate programs. Each installs a manifest, a JSON
+// file in one of ManifestDirs:
+//
+//	{"name": "Firewall", "icon": "security-high", "description": "Allowed ports",
+//	 "order": 55, "exec": "raven-firewall --settings-socket", "socket": "raven-firewall.sock"}
+//
+// and answers requests on a unix socket. Every connection carries one JSON
+// Request and one JSON Response, like the raven-desktop control socket. The
+// page is described as rows, which the settings menu turns into widgets,
+// so external pages look like the built-in ones.
+
+const (
+	// How long to wait for a page process that was just started
+	externalStartTimeout = 3 * time.Second
+	externalCallTimeout  = 5 * time.Second
+)
+
+// Row types an external page can use
+const (
+	RowSection = "section" // Heading
+	RowLabel   = "label"   // Read-only text, Value is a string
+	RowSwitch  = "switch"  // Value is a bool
+	RowEntry   = "entry"   // Value is a string, sent on Enter
+	RowChoice  = "choice"  // Value is one of Options
+	RowSpin    = "spin"    // Value is a number between Min and Max
+	RowButton  = "button"  // Value is the button label; set is sent on click
+)
+
+// Manifest describes a page served by an external process
+type Manifest struct {
+	Info
+	Exec   string `json:"exec"`   // Started when nothing answers on Socket
+	Socket string `json:"socket"` // Name in $XDG_RUNTIME_DIR, or an absolute path
+}
+
+// Request is sent to an external page. Method is "describe" or "set".
+type Request struct {
+	Method string `json:"method"`
+	ID     string `json:"id,omitempty"`
+	Value  any    `json:"value,omitempty"`
+}
+
+// Response is the page's reply: its rows after the request was handled
+type Response struct {
+	Rows  []Row  `json:"rows"`
+	Error string `json:"error,omitempty"`
+}
+
+// Row is one line of an external page
+type Row struct {
+	ID          string          `json:"id"`
+	Type        string          `json:"type"`
+	Title       string          `json:"title"`
+	Description string          `json:"description,omitempty"`
+	Value       json.RawMessage `json:"value,omitempty"`
+	Options     []string        `json:"options,omitempty"`
+	Min         float64         `json:"min,omitempty"`
+	Max         float64         `json:"max,omitempty"`
+	Step        float64         `json:"step,omitempty"`
+}
+
+// ManifestDirs are searched for *.json manifests. The user directory comes
+// first, so a user manifest overrides a system one with the same name.
+func ManifestDirs() []string {
+	return []string{
+		filepath.Join(os.Getenv("HOME"), ".config", "raven", "settings-pages"),
+		"/usr/share/raven/settings-pages",
+	}
+}
+
+// LoadManifests returns a page for every valid manifest
+func LoadManifests() []Page {
+	seen := make(map[string]bool)
+	var pages []Page
+	for _, dir := range ManifestDirs() {
+		files, _ := filepath.Glob(filepath.Join(dir, "*.json"))
+		for _, file := range files {
+			data, err := os.ReadFile(file)
+			if err != nil {
+				continue
+			}
+			var manifest Manifest
+			if err := json.Unmarshal(data, &manifest); err != nil {
+				fmt.Fprintf(os.Stderr, "raven-settings-menu: invalid page manifest %s: %v\n", file, err)
+				continue
+			}
+			if manifest.Name == "" || manifest.Socket == "" {
+				fmt.Fprintf(os.Stderr, "raven-settings-menu: page manifest %s needs a name and socket\n", file)
+				continue
+			}
+			if seen[manifest.Name] {
+				continue
+			}
+			seen[manifest.Name] = true
+			pages = append(pages, &externalPage{manifest: manifest})
+		}
+	}
+	return pages
+}
+
+// externalPage renders the rows of an external page process
+type externalPage struct {
+	manifest Manifest
+
+	startOnce sync.Once
+	host      Host
+	rowsBox   *gtk.Box
+	rendering bool // Widgets are being filled in; don't echo their signals
+}
+
+func (p *externalPage) Info() Info {
+	return p.manifest.Info
+}
+
+func (p *externalPage) Build(host Host) gtk.Widgetter {
+	p.host = host
+	scroll, content := NewContent(p.manifest.Name)
+
+	p.rowsBox = gtk.NewBox(gtk.OrientationVertical, 16)
+	content.Append(p.rowsBox)
+	p.showMessage("Loading...")
+
+	p.send(Request{Method: "describe"})
+	return scroll
+}
+
+// send makes a request off the main loop and renders the reply
+func (p *externalPage) send(req Request) {
+	go func() {
+		resp := p.call(req)
+		glib.IdleAdd(func() {
+			p.render(resp)
+		})
+	}()
+}
+
+func (p *externalPage) socketPath() string {
+	if filepath.IsAbs(p.manifest.Socket) {
+		return p.manifest.Socket
+	}
+	runtimeDir := os.Getenv("XDG_RUNTIME_DIR")
+	if runtimeDir == "" {
+		runtimeDir = fmt.Sprintf("/run/user/%d", os.Getuid())
+	}
+	return filepath.Join(runtimeDir, p.manifest.Socket)
+}
+
+// dial connects to the page socket, starting the page process the first
+// time nothing answers
+func (p *externalPage) dial() (net.Conn, error) {
+	conn, err := net.DialTimeout("unix", p.socketPath(), time.Second)
+	if err == nil || p.manifest.Exec == "" {
+		return conn, err
+	}
+
+	p.startOnce.Do(func() {
+		if err := exec.Command("sh", "-c", p.manifest.Exec).Start(); err != nil {
+			fmt.Fprintf(os.Stderr, "raven-settings-menu: failed to start %s page: %v\n", p.manifest.Name, err)
+		}
+	})
+
+	deadline := time.Now().Add(externalStartTimeout)
+	for time.Now().Before(deadline) {
+		time.Sleep(100 * time.Millisecond)
+		if conn, err = net.DialTimeout("unix", p.socketPath(), time.Second); err == nil {
+			return conn, nil
+		}
+	}
+	return nil, err
+}
+
+func (p *externalPage) call(req Request) Response {
+	conn, err := p.dial()
+	if err != nil {
+		return Response{Error: fmt.Sprintf("%s is not available: %v", p.manifest.Name, err)}
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(externalCallTimeout))
+
+	if err := json.NewEncoder(conn).Encode(req); err != nil {
+		return Response{Error: err.Error()}
+	}
+	var resp Response
+	if err := json.NewDecoder(conn).Decode(&resp); err != nil {
+		return Response{Error: "invalid reply: " + err.Error()}
+	}
+	return resp
+}
+
+func (p *externalPage) showMessage(text string) {
+	label := gtk.NewLabel(text)
+	label.AddCSSClass("setting-description")
+	label.SetHAlign(gtk.AlignStart)
+	label.SetWrap(true)
+	p.rowsBox.Append(label)
+}
+
+// render replaces the page content with the rows in resp
+func (p *externalPage) render(resp Response) {
+	for child := p.rowsBox.FirstChild(); child != nil; child = p.rowsBox.FirstChild() {
+		p.rowsBox.Remove(child)
+	}
+	if resp.Error != "" {
+		p.showMessage(resp.Error)
+	}
+
+	p.rendering = true
+	defer func() { p.rendering = false }()
+	for _, row := range resp.Rows {
+		if widget := p.createRow(row); widget != nil {
+			p.rowsBox.Append(widget)
+		}
+	}
+}
+
+func (p *externalPage) set(id string, value any) {
+	if !p.rendering {
+		p.send(Request{Method: "set", ID: id, Value: value})
+	}
+}
+
+// createRow turns a row into widgets, or nil for an unknown type
+func (p *externalPage) createRow(row Row) gtk.Widgetter {
+	switch row.Type {
+	case RowSection:
+		label := gtk.NewLabel(row.Title)
+		label.AddCSSClass("section-title")
+		label.SetHAlign(gtk.AlignStart)
+		return label
+
+	case RowLabel:
+		var text string
+		json.Unmarshal(row.Value, &text)
+		value := gtk.NewLabel(text)
+		value.AddCSSClass("setting-label")
+		value.SetSelectable(true)
+		return p.host.SettingRow(row.Title, row.Description, value)
+
+	case RowSwitch:
+		var active bool
+		json.Unmarshal(row.Value, &active)
+		sw := gtk.NewSwitch()
+		sw.SetActive(active)
+		sw.SetVAlign(gtk.AlignCenter)
+		sw.ConnectStateSet(func(state bool) bool {
+			p.set(row.ID, state)
+			return false
+		})
+		return p.host.SettingRow(row.Title, row.Description, sw)
+
+	case RowEntry:
+		var text string
+		json.Unmarshal(row.Value, &text)
+		entry := gtk.NewEntry()
+		entry.SetText(text)
+		entry.ConnectActivate(func() {
+			p.set(row.ID, entry.Text())
+		})
+		return p.host.SettingRow(row.Title, row.Description, entry)
+
+	case RowChoice:
+		var selected string
+		json.Unmarshal(row.Value, &selected)
+		dropdown := gtk.NewDropDown(gtk.NewStringList(row.Options), nil)
+		for i, option := range row.Options {
+			if option == selected {
+				dropdown.SetSelected(uint(i))
+			}
+		}
+		dropdown.Connect("notify::selected", func() {
+			if idx := dropdown.Selected(); idx < uint(len(row.Options)) {
+				p.set(row.ID, row.Options[idx])
+			}
+		})
+		return p.host.SettingRow(row.Title, row.Description, dropdown)
+
+	case RowSpin:
+		var value float64
+		json.Unmarshal(row.Value, &value)
+		step := row.Step
+		if step <= 0 {
+			step = 1
+		}
+		spin := gtk.NewSpinButtonWithRange(row.Min, row.Max, step)
+		spin.SetValue(value)
+		spin.ConnectValueChanged(func() {
+			p.set(row.ID, spin.Value())
+		})
+		return p.host.SettingRow(row.Title, row.Description, spin)
+
+	case RowButton:
+		var label string
+		json.Unmarshal(row.Value, &label)
+		if label == "" {
+			label = row.Title
+		}
+		btn := gtk.NewButtonWithLabel(label)
+		btn.ConnectClicked(func() {
+			p.set(row.ID, nil)
+		})
+		return p.host.SettingRow(row.Title, row.Description, btn)
+	}
+
+	fmt.Fprintf(os.Stderr, "raven-settings-menu: %s page: unknown row type %q\n", p.manifest.Name, strings.TrimSpace(row.Type))
+	return nil
+}
@@ -0,0 +1,106 @@
+// Package pages is the plugin API for raven-settings-menu. A page is
+// anything that implements Page: built-in pages, pages in other Go
+// packages that call Register from init, and external processes that
+// describe themselves with a manifest (see external.go).
+package pages
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/diamondburned/gotk4/pkg/gtk/v4"
+)
+
+// Info is what the sidebar shows for a page
+type Info struct {
+	Name        string `json:"name"` // Also the page's stack name, so must be unique
+	Icon        string `json:"icon"`
+	Description string `json:"description"`
+	Order       int    `json:"order"` // Sidebar position, lowest first
+}
+
+// Page is a settings page
+type Page interface {
+	Info() Info
+	// Build creates the page content. It runs once, on the main loop.
+	Build(host Host) gtk.Widgetter
+}
+
+// Host is the settings app as seen by a page
+type Host interface {
+	// Setting decodes the settings.json value for key into value and
+	// reports whether the key was set
+	Setting(key string, value any) bool
+	// SetSetting stores value under key in settings.json, keeping every
+	// other key as it is
+	SetSetting(key string, value any) error
+	// SettingRow lays out a control with its title and description in the
+	// style of the built-in pages
+	SettingRow(title, description string, control gtk.Widgetter) *gtk.Box
+	Window() *gtk.Window
+}
+
+var (
+	registryMu sync.Mutex
+	registry   []Page
+)
+
+// Register adds a page. Packages call it from init.
+func Register(page Page) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	registry = append(registry, page)
+}
+
+// Registered returns the registered pages
+func Registered() []Page {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	return append([]Page(nil), registry...)
+}
+
+// Sort orders pages by Order, then by name
+func Sort(pages []Page) {
+	sort.SliceStable(pages, func(i, j int) bool {
+		a, b := pages[i].Info(), pages[j].Info()
+		if a.Order != b.Order {
+			return a.Order < b.Order
+		}
+		return a.Name < b.Name
+	})
+}
+
+// funcPage adapts a build function to Page
+type funcPage struct {
+	info  Info
+	build func(host Host) gtk.Widgetter
+}
+
+func (p funcPage) Info() Info                    { return p.info }
+func (p funcPage) Build(host Host) gtk.Widgetter { return p.build(host) }
+
+// New returns a Page built by build
+func New(info Info, build func(host Host) gtk.Widgetter) Page {
+	return funcPage{info: info, build: build}
+}
+
+// NewContent returns the scrolled container and titled content box every
+// page starts from
+func NewContent(title string) (*gtk.ScrolledWindow, *gtk.Box) {
+	scroll := gtk.NewScrolledWindow()
+	scroll.SetPolicy(gtk.PolicyNever, gtk.PolicyAutomatic)
+
+	content := gtk.NewBox(gtk.OrientationVertical, 16)
+	content.SetMarginStart(20)
+	content.SetMarginEnd(20)
+	content.SetMarginTop(20)
+	content.SetMarginBottom(20)
+
+	sectionTitle := gtk.NewLabel(title)
+	sectionTitle.AddCSSClass("section-title")
+	sectionTitle.SetHAlign(gtk.AlignStart)
+	content.Append(sectionTitle)
+
+	scroll.SetChild(content)
+	return scroll, content
+}
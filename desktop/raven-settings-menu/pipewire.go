@@ -0,0 +1,208 @@
+package main
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os/exec"
+	"sort"
+	"strconv"
+)
+
+// audioNode is a PipeWire sink, source or application stream
+type audioNode struct {
+	ID      int
+	Name    string // node.name, what the default metadata refers to
+	Label   string // Description or application name, for display
+	Icon    string
+	Volume  float64 // 0-1.5 on the same scale as wpctl (cubic)
+	Muted   bool
+	Default bool
+}
+
+// audioState is what the Sound page shows
+type audioState struct {
+	Sinks   []audioNode
+	Sources []audioNode
+	Streams []audioNode // Applications playing audio
+}
+
+// loadAudio reads the devices and playback streams from pw-dump
+func loadAudio() (audioState, error) {
+	var state audioState
+	out, err := exec.Command("pw-dump").Output()
+	if err != nil {
+		return state, fmt.Errorf("pw-dump: %w", err)
+	}
+
+	var objects []struct {
+		ID    int            `json:"id"`
+		Type  string         `json:"type"`
+		Props map[string]any `json:"props"`
+		Info  struct {
+			Props  map[string]any `json:"props"`
+			Params struct {
+				Props []struct {
+					Mute           *bool     `json:"mute"`
+					ChannelVolumes []float64 `json:"channelVolumes"`
+				} `json:"Props"`
+			} `json:"params"`
+		} `json:"info"`
+		Metadata []struct {
+			Key   string          `json:"key"`
+			Value json.RawMessage `json:"value"`
+		} `json:"metadata"`
+	}
+	if err := json.Unmarshal(out, &objects); err != nil {
+		return state, fmt.Errorf("pw-dump: %w", err)
+	}
+
+	// The default devices are named in the "default" metadata
+	defaults := make(map[string]bool)
+	for _, obj := range objects {
+		if obj.Type != "PipeWire:Interface:Metadata" || obj.Props["metadata.name"] != "default" {
+			continue
+		}
+		for _, entry := range obj.Metadata {
+			if entry.Key != "default.audio.sink" && entry.Key != "default.audio.source" {
+				continue
+			}
+			var value struct {
+				Name string `json:"name"`
+			}
+			if json.Unmarshal(entry.Value, &value) == nil && value.Name != "" {
+				defaults[value.Name] = true
+			}
+		}
+	}
+
+	for _, obj := range objects {
+		if obj.Type != "PipeWire:Interface:Node" {
+			continue
+		}
+		props := obj.Info.Props
+		node := audioNode{
+			ID:    obj.ID,
+			Name:  propString(props, "node.name"),
+			Label: propString(props, "node.description"),
+		}
+		for _, p := range obj.Info.Params.Props {
+			if p.Mute != nil {
+				node.Muted = *p.Mute
+			}
+			if len(p.ChannelVolumes) > 0 {
+				node.Volume = nodeVolume(p.ChannelVolumes)
+			}
+		}
+		node.Default = defaults[node.Name]
+
+		switch props["media.class"] {
+		case "Audio/Sink":
+			state.Sinks = append(state.Sinks, node)
+		case "Audio/Source":
+			state.Sources = append(state.Sources, node)
+		case "Stream/Output/Audio":
+			node.Label = propString(props, "application.name")
+			if media := propString(props, "media.name"); media != "" && node.Label == "" {
+				node.Label = media
+			}
+			node.Icon = propString(props, "application.icon-name")
+			if node.Icon == "" {
+				node.Icon = propString(props, "application.process.binary")
+			}
+			state.Streams = append(state.Streams, node)
+		}
+	}
+
+	for _, nodes := range [][]audioNode{state.Sinks, state.Sources, state.Streams} {
+		sort.SliceStable(nodes, func(i, j int) bool { return nodes[i].Label < nodes[j].Label })
+	}
+	return state, nil
+}
+
+func propString(props map[string]any, key string) string {
+	if value, ok := props[key]; ok {
+		return fmt.Sprint(value)
+	}
+	return ""
+}
+
+// nodeVolume turns PipeWire's linear channel volumes into the cubic volume
+// wpctl and the panel use, averaged over the channels
+func nodeVolume(channels []float64) float64 {
+	sum := 0.0
+	for _, v := range channels {
+		sum += v
+	}
+	return math.Cbrt(sum / float64(len(channels)))
+}
+
+// setNodeVolume sets a node's volume, 1.0 being 100%
+func setNodeVolume(id int, volume float64) error {
+	return runQuiet("wpctl", "set-volume", strconv.Itoa(id), strconv.FormatFloat(volume, 'f', 2, 64))
+}
+
+func setNodeMuted(id int, muted bool) error {
+	value := "0"
+	if muted {
+		value = "1"
+	}
+	return runQuiet("wpctl", "set-mute", strconv.Itoa(id), value)
+}
+
+// setDefaultNode makes a sink the default output or a source the default
+// input
+func setDefaultNode(id int) error {
+	return runQuiet("wpctl", "set-default", strconv.Itoa(id))
+}
+
+// levelMeter records from a source and reports its peak level
+type levelMeter struct {
+	cmd *exec.Cmd
+}
+
+// Samples per level reading: 50ms at the meter's 16kHz
+const levelMeterSamples = 800
+
+// startLevelMeter records mono 16-bit audio from a source and calls level
+// with the peak of every 50ms, from 0 to 1, on its own goroutine
+func startLevelMeter(source string, level func(float64)) (*levelMeter, error) {
+	cmd := exec.Command("pw-record", "--target", source,
+		"--rate", "16000", "--channels", "1", "--format", "s16",
+		"-P", `{ media.name = "Input level" }`, "-")
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("pw-record: %w", err)
+	}
+
+	go func() {
+		buf := make([]byte, levelMeterSamples*2)
+		for {
+			if _, err := io.ReadFull(stdout, buf); err != nil {
+				cmd.Wait()
+				return
+			}
+			peak := 0
+			for i := 0; i < len(buf); i += 2 {
+				sample := int(int16(binary.LittleEndian.Uint16(buf[i:])))
+				if sample < 0 {
+					sample = -sample
+				}
+				peak = max(peak, sample)
+			}
+			level(float64(peak) / 32768)
+		}
+	}()
+	return &levelMeter{cmd: cmd}, nil
+}
+
+func (l *levelMeter) stop() {
+	if l != nil && l.cmd.Process != nil {
+		l.cmd.Process.Kill()
+	}
+}
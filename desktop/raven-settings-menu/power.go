@@ -0,0 +1,243 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+
+	"github.com/diamondburned/gotk4/pkg/glib/v2"
+	"github.com/diamondburned/gotk4/pkg/gtk/v4"
+)
+
+// Charge limits offered on the Power page, in percent
+var chargeLimits = []int{100, 95, 90, 85, 80, 75, 70, 60, minChargeLimit}
+
+// powerPage holds the widgets of the Power page changed after it is built
+type powerPage struct {
+	m        *RavenSettingsMenu
+	message  *gtk.Label
+	updating bool // Dropdowns are being set, not changed by the user
+}
+
+func (m *RavenSettingsMenu) createPowerPage() *gtk.ScrolledWindow {
+	scroll := gtk.NewScrolledWindow()
+	scroll.SetPolicy(gtk.PolicyNever, gtk.PolicyAutomatic)
+
+	content := gtk.NewBox(gtk.OrientationVertical, 16)
+	content.SetMarginStart(20)
+	content.SetMarginEnd(20)
+	content.SetMarginTop(20)
+	content.SetMarginBottom(20)
+
+	sectionTitle := gtk.NewLabel("Power")
+	sectionTitle.AddCSSClass("section-title")
+	sectionTitle.SetHAlign(gtk.AlignStart)
+	content.Append(sectionTitle)
+
+	p := &powerPage{m: m}
+
+	p.message = gtk.NewLabel("")
+	p.message.AddCSSClass("setting-description")
+	p.message.SetHAlign(gtk.AlignStart)
+	p.message.SetWrap(true)
+	p.message.SetVisible(false)
+	content.Append(p.message)
+
+	// Screen timeout
+	screenTimeouts := []int{0, 60, 300, 600, 900, 1800}
+	screenDropdown := gtk.NewDropDown(gtk.NewStringList([]string{"Never", "1 minute", "5 minutes", "10 minutes", "15 minutes", "30 minutes"}), nil)
+	for i, timeout := range screenTimeouts {
+		if timeout == m.settings.ScreenTimeout {
+			screenDropdown.SetSelected(uint(i))
+			break
+		}
+	}
+	screenDropdown.Connect("notify::selected", func() {
+		idx := screenDropdown.Selected()
+		if idx < uint(len(screenTimeouts)) {
+			m.settings.ScreenTimeout = screenTimeouts[idx]
+			m.saveSettings()
+			p.applyIdle()
+		}
+	})
+	content.Append(m.createSettingRow("Screen Timeout", "Turn off display after inactivity", screenDropdown))
+
+	// Suspend timeout
+	suspendTimeouts := []int{0, 300, 900, 1800, 3600, 7200}
+	suspendDropdown := gtk.NewDropDown(gtk.NewStringList([]string{"Never", "5 minutes", "15 minutes", "30 minutes", "1 hour", "2 hours"}), nil)
+	for i, timeout := range suspendTimeouts {
+		if timeout == m.settings.SuspendTimeout {
+			suspendDropdown.SetSelected(uint(i))
+			break
+		}
+	}
+	suspendDropdown.Connect("notify::selected", func() {
+		idx := suspendDropdown.Selected()
+		if idx < uint(len(suspendTimeouts)) {
+			m.settings.SuspendTimeout = suspendTimeouts[idx]
+			m.saveSettings()
+			p.applyIdle()
+		}
+	})
+	content.Append(m.createSettingRow("Suspend Timeout", "Suspend system after inactivity", suspendDropdown))
+
+	// Lid close action
+	lidActions := []string{"suspend", "hibernate", "poweroff", "nothing"}
+	lidDropdown := gtk.NewDropDown(gtk.NewStringList([]string{"Suspend", "Hibernate", "Power Off", "Do Nothing"}), nil)
+	for i, action := range lidActions {
+		if action == m.settings.LidCloseAction {
+			lidDropdown.SetSelected(uint(i))
+			break
+		}
+	}
+	lidDropdown.Connect("notify::selected", func() {
+		idx := lidDropdown.Selected()
+		if idx < uint(len(lidActions)) {
+			m.settings.LidCloseAction = lidActions[idx]
+			m.saveSettings()
+			p.run(lidDropdown, nil, "lid", lidActions[idx])
+		}
+	})
+	content.Append(m.createSettingRow("Lid Close Action", "Action when laptop lid is closed", lidDropdown))
+
+	if limit, ok := readChargeLimit(); ok {
+		p.appendChargeLimitRow(content, limit)
+	}
+	p.appendPowerProfileRow(content)
+
+	scroll.SetChild(content)
+	return scroll
+}
+
+// applyIdle writes the timeouts for the idle daemon and restarts it, off
+// the main loop since the restart waits for it to stop
+func (p *powerPage) applyIdle() {
+	screen, suspend := p.m.settings.ScreenTimeout, p.m.settings.SuspendTimeout
+	go func() {
+		err := writeIdleConfig(screen, suspend)
+		if err == nil {
+			err = restartIdleDaemon()
+		}
+		if err != nil {
+			glib.IdleAdd(func() {
+				p.showError(err)
+			})
+		}
+	}()
+}
+
+// appendChargeLimitRow adds the battery charge limit, which only some
+// laptops' batteries have
+func (p *powerPage) appendChargeLimitRow(content *gtk.Box, limit int) {
+	labels := make([]string, len(chargeLimits))
+	for i, percent := range chargeLimits {
+		labels[i] = strconv.Itoa(percent) + "%"
+	}
+	labels[0] = "100% (Full)"
+
+	dropdown := gtk.NewDropDown(gtk.NewStringList(labels), nil)
+	selectLimit := func(limit int) {
+		p.updating = true
+		defer func() { p.updating = false }()
+		// A limit set elsewhere shows as the next one up
+		for i := len(chargeLimits) - 1; i >= 0; i-- {
+			if chargeLimits[i] >= limit {
+				dropdown.SetSelected(uint(i))
+				return
+			}
+		}
+	}
+	selectLimit(limit)
+
+	dropdown.Connect("notify::selected", func() {
+		idx := dropdown.Selected()
+		if p.updating || idx >= uint(len(chargeLimits)) {
+			return
+		}
+		// Show the limit the battery has if it couldn't be changed
+		p.run(dropdown, func() {
+			if limit, ok := readChargeLimit(); ok {
+				selectLimit(limit)
+			}
+		}, "charge-limit", strconv.Itoa(chargeLimits[idx]))
+	})
+	content.Append(p.m.createSettingRow("Battery Charge Limit", "Stop charging here to keep the battery healthy", dropdown))
+}
+
+// appendPowerProfileRow adds the power-profiles-daemon profiles once they
+// are read
+func (p *powerPage) appendPowerProfileRow(content *gtk.Box) {
+	dropdown := gtk.NewDropDown(gtk.NewStringList(nil), nil)
+	dropdown.SetSensitive(false)
+	row := p.m.createSettingRow("Power Profile", "Trade performance for battery life", dropdown)
+	content.Append(row)
+
+	var profiles []string
+	dropdown.Connect("notify::selected", func() {
+		idx := dropdown.Selected()
+		if p.updating || idx >= uint(len(profiles)) {
+			return
+		}
+		profile := profiles[idx]
+		dropdown.SetSensitive(false)
+		go func() {
+			err := setPowerProfile(profile)
+			glib.IdleAdd(func() {
+				dropdown.SetSensitive(true)
+				if err != nil {
+					p.showError(err)
+				}
+			})
+		}()
+	})
+
+	go func() {
+		list, active, err := powerProfiles()
+		glib.IdleAdd(func() {
+			if err != nil || len(list) == 0 {
+				// Without power-profiles-daemon there is nothing to choose
+				row.SetTooltipText("Needs power-profiles-daemon (powerprofilesctl)")
+				return
+			}
+			profiles = list
+			model := gtk.NewStringList(nil)
+			for _, profile := range profiles {
+				model.Append(powerProfileLabel(profile))
+			}
+			p.updating = true
+			dropdown.SetModel(model)
+			if i := slices.Index(profiles, active); i >= 0 {
+				dropdown.SetSelected(uint(i))
+			}
+			p.updating = false
+			dropdown.SetSensitive(true)
+		})
+	}()
+}
+
+// run carries out a --power command as root off the main loop. widget is
+// disabled meanwhile; done is called afterwards either way.
+func (p *powerPage) run(widget gtk.Widgetter, done func(), args ...string) {
+	gtk.BaseWidget(widget).SetSensitive(false)
+	p.message.SetVisible(false)
+	go func() {
+		err := asRoot("--power", args...)
+		glib.IdleAdd(func() {
+			gtk.BaseWidget(widget).SetSensitive(true)
+			if err != nil {
+				p.showError(err)
+			}
+			if done != nil {
+				done()
+			}
+		})
+	}()
+}
+
+// showError reports a failed change at the top of the page
+func (p *powerPage) showError(err error) {
+	fmt.Fprintf(os.Stderr, "raven-settings-menu: power: %v\n", err)
+	p.message.SetText(err.Error())
+	p.message.SetVisible(true)
+}
@@ -0,0 +1,232 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// The udev rule that sets the battery charge limit again at boot, since
+// the kernel forgets it
+const chargeLimitRulePath = "/etc/udev/rules.d/90-raven-charge-limit.rules"
+
+// Lowest charge limit offered; below it the battery is barely used
+const minChargeLimit = 50
+
+// Lock command for the idle daemons, which lock before suspending
+const idleLockCommand = "pidof hyprlock swaylock || hyprlock || swaylock"
+
+// logindLidActions maps the Lid Close Action setting to logind's
+// HandleLidSwitch
+var logindLidActions = map[string]string{
+	"suspend":   "suspend",
+	"hibernate": "hibernate",
+	"poweroff":  "poweroff",
+	"nothing":   "ignore",
+}
+
+// hypridlePath returns hypridle's config, which it reads at start
+func hypridlePath() string {
+	return filepath.Join(configHome(), "hypr", "hypridle.conf")
+}
+
+// swayidlePath returns the config swayidle reads when started without
+// commands
+func swayidlePath() string {
+	return filepath.Join(configHome(), "swayidle", "config")
+}
+
+// writeIdleConfig writes the screen and suspend timeouts, in seconds with
+// 0 for never, to the configs of hypridle and swayidle. The Services page
+// starts whichever of them is installed.
+func writeIdleConfig(screen, suspend int) error {
+	var hypr, sway bytes.Buffer
+	hypr.WriteString("# Written by raven-settings-menu (Power)\n")
+	fmt.Fprintf(&hypr, "general {\n    lock_cmd = %s\n    before_sleep_cmd = loginctl lock-session\n    after_sleep_cmd = hyprctl dispatch dpms on\n}\n", idleLockCommand)
+	sway.WriteString("# Written by raven-settings-menu (Power)\n")
+	fmt.Fprintf(&sway, "lock '%s'\nbefore-sleep 'loginctl lock-session'\nafter-resume 'hyprctl dispatch dpms on'\n", idleLockCommand)
+
+	if screen > 0 {
+		fmt.Fprintf(&hypr, "\nlistener {\n    timeout = %d\n    on-timeout = hyprctl dispatch dpms off\n    on-resume = hyprctl dispatch dpms on\n}\n", screen)
+		fmt.Fprintf(&sway, "timeout %d 'hyprctl dispatch dpms off' resume 'hyprctl dispatch dpms on'\n", screen)
+	}
+	if suspend > 0 {
+		fmt.Fprintf(&hypr, "\nlistener {\n    timeout = %d\n    on-timeout = loginctl suspend\n}\n", suspend)
+		fmt.Fprintf(&sway, "timeout %d 'loginctl suspend'\n", suspend)
+	}
+
+	for path, data := range map[string][]byte{hypridlePath(): hypr.Bytes(), swayidlePath(): sway.Bytes()} {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(path, data, 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// restartIdleDaemon restarts the idle service, if it is running, so it
+// reads the new timeouts
+func restartIdleDaemon() error {
+	if s, ok := findService("idle"); ok && len(s.pids()) > 0 {
+		return s.restart()
+	}
+	return nil
+}
+
+// logindDropIn returns the logind config file the lid action is written
+// to: elogind's when it is installed, systemd-logind's otherwise
+func logindDropIn() string {
+	if _, err := os.Stat("/etc/elogind"); err == nil {
+		return "/etc/elogind/logind.conf.d/90-raven-lid.conf"
+	}
+	return "/etc/systemd/logind.conf.d/90-raven-lid.conf"
+}
+
+// setLidAction makes logind carry out action when the lid is closed, on
+// battery and on external power. Needs root.
+func setLidAction(action string) error {
+	handle, ok := logindLidActions[action]
+	if !ok {
+		return fmt.Errorf("unknown lid action %q", action)
+	}
+	path := logindDropIn()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	conf := fmt.Sprintf("# Written by raven-settings-menu (Power)\n[Login]\nHandleLidSwitch=%s\nHandleLidSwitchExternalPower=%s\n", handle, handle)
+	if err := os.WriteFile(path, []byte(conf), 0644); err != nil {
+		return err
+	}
+	// logind rereads its config on SIGHUP; an older one applies it at
+	// next boot
+	exec.Command("pkill", "-HUP", "-x", "elogind|systemd-logind").Run()
+	return nil
+}
+
+// chargeLimitFiles returns the charge limit of each battery that has one
+func chargeLimitFiles() []string {
+	files, _ := filepath.Glob("/sys/class/power_supply/BAT*/charge_control_end_threshold")
+	return files
+}
+
+// readChargeLimit returns the charge limit of the first battery, in percent
+func readChargeLimit() (int, bool) {
+	files := chargeLimitFiles()
+	if len(files) == 0 {
+		return 0, false
+	}
+	data, err := os.ReadFile(files[0])
+	if err != nil {
+		return 0, false
+	}
+	limit, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	return limit, err == nil
+}
+
+// setChargeLimit stops the batteries charging past percent, now and, by a
+// udev rule, after reboots. Needs root.
+func setChargeLimit(percent int) error {
+	if percent < minChargeLimit || percent > 100 {
+		return fmt.Errorf("charge limit %d%% is not between %d%% and 100%%", percent, minChargeLimit)
+	}
+	files := chargeLimitFiles()
+	if len(files) == 0 {
+		return errors.New("no battery with a charge limit")
+	}
+	for _, file := range files {
+		if err := os.WriteFile(file, []byte(strconv.Itoa(percent)), 0644); err != nil {
+			return err
+		}
+	}
+
+	if percent == 100 {
+		if err := os.Remove(chargeLimitRulePath); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+	rule := fmt.Sprintf("# Written by raven-settings-menu (Power)\nSUBSYSTEM==\"power_supply\", KERNEL==\"BAT*\", ATTR{charge_control_end_threshold}=\"%d\"\n", percent)
+	if err := os.MkdirAll(filepath.Dir(chargeLimitRulePath), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(chargeLimitRulePath, []byte(rule), 0644)
+}
+
+// runPowerCommand carries out a --power command, each a change that needs
+// root:
+//
+//	lid ACTION
+//	charge-limit PERCENT
+func runPowerCommand(args []string) error {
+	if len(args) != 2 {
+		return errors.New("usage: --power lid ACTION | charge-limit PERCENT")
+	}
+	switch args[0] {
+	case "lid":
+		return setLidAction(args[1])
+	case "charge-limit":
+		percent, err := strconv.Atoi(args[1])
+		if err != nil {
+			return fmt.Errorf("invalid charge limit %q", args[1])
+		}
+		return setChargeLimit(percent)
+	default:
+		return fmt.Errorf("unknown power command %q", args[0])
+	}
+}
+
+// powerProfileOrder is the order profiles are offered in, from least to
+// most power
+var powerProfileOrder = []string{"power-saver", "balanced", "performance"}
+
+// powerProfiles returns the profiles power-profiles-daemon offers and the
+// active one
+func powerProfiles() ([]string, string, error) {
+	out, err := exec.Command("powerprofilesctl", "list").Output()
+	if err != nil {
+		return nil, "", fmt.Errorf("powerprofilesctl: %w", err)
+	}
+
+	// Profiles are "* name:" when active and "  name:" otherwise, each
+	// followed by indented details
+	var profiles []string
+	active := ""
+	for _, line := range strings.Split(string(out), "\n") {
+		if len(line) < 3 || line[2] == ' ' || line[2] == '\t' || !strings.HasSuffix(line, ":") {
+			continue
+		}
+		name := strings.TrimSuffix(strings.TrimSpace(line[2:]), ":")
+		profiles = append(profiles, name)
+		if line[0] == '*' {
+			active = name
+		}
+	}
+	sort.SliceStable(profiles, func(i, j int) bool {
+		return slices.Index(powerProfileOrder, profiles[i]) < slices.Index(powerProfileOrder, profiles[j])
+	})
+	return profiles, active, nil
+}
+
+func setPowerProfile(name string) error {
+	return runQuiet("powerprofilesctl", "set", name)
+}
+
+// powerProfileLabel returns how a profile is shown, e.g. "Power Saver"
+func powerProfileLabel(name string) string {
+	words := strings.Split(name, "-")
+	for i, word := range words {
+		if word != "" {
+			words[i] = strings.ToUpper(word[:1]) + word[1:]
+		}
+	}
+	return strings.Join(words, " ")
+}
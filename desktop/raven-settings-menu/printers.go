@@ -0,0 +1,473 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/diamondburned/gotk4/pkg/glib/v2"
+	"github.com/diamondburned/gotk4/pkg/gtk/v4"
+)
+
+// How often the Printers page rereads CUPS while it is shown
+const printersPollSeconds = 3
+
+const cupsStoppedMessage = "The CUPS scheduler is not running (start the cups service)"
+
+// printersPage holds the printer and job lists, and the add-printer view
+// that replaces them while a printer is added
+type printersPage struct {
+	m *RavenSettingsMenu
+
+	stack       *gtk.Stack // "printers" or "add"
+	message     *gtk.Label
+	printers    *gtk.Box
+	printersKey string // What the printer list was built from
+	jobs        *gtk.Box
+	jobsKey     string
+
+	found        *gtk.ListBox
+	foundList    []foundPrinter
+	searching    *gtk.Spinner
+	searchStatus *gtk.Label
+	searchBtn    *gtk.Button
+	nameEntry    *gtk.Entry
+	uriEntry     *gtk.Entry
+	descEntry    *gtk.Entry
+	addBtn       *gtk.Button
+}
+
+func (m *RavenSettingsMenu) createPrintersPage() *gtk.ScrolledWindow {
+	scroll := gtk.NewScrolledWindow()
+	scroll.SetPolicy(gtk.PolicyNever, gtk.PolicyAutomatic)
+
+	content := gtk.NewBox(gtk.OrientationVertical, 16)
+	content.SetMarginStart(20)
+	content.SetMarginEnd(20)
+	content.SetMarginTop(20)
+	content.SetMarginBottom(20)
+	scroll.SetChild(content)
+
+	sectionTitle := gtk.NewLabel("Printers")
+	sectionTitle.AddCSSClass("section-title")
+	sectionTitle.SetHAlign(gtk.AlignStart)
+	content.Append(sectionTitle)
+
+	if _, err := exec.LookPath("lpstat"); err != nil {
+		label := gtk.NewLabel("Printer settings need CUPS (cups and cups-client)")
+		label.AddCSSClass("setting-description")
+		label.SetHAlign(gtk.AlignStart)
+		content.Append(label)
+		return scroll
+	}
+
+	p := &printersPage{m: m}
+
+	p.message = gtk.NewLabel("")
+	p.message.AddCSSClass("setting-description")
+	p.message.SetHAlign(gtk.AlignStart)
+	p.message.SetWrap(true)
+	p.message.SetVisible(false)
+	content.Append(p.message)
+
+	p.stack = gtk.NewStack()
+	p.stack.SetTransitionType(gtk.StackTransitionTypeCrossfade)
+	p.stack.AddNamed(p.createPrinterList(), "printers")
+	p.stack.AddNamed(p.createAddView(), "add")
+	content.Append(p.stack)
+
+	p.refresh()
+
+	// Only polled while the page is on screen
+	glib.TimeoutSecondsAdd(printersPollSeconds, func() bool {
+		if scroll.Mapped() {
+			p.refresh()
+		}
+		return true
+	})
+
+	return scroll
+}
+
+func (p *printersPage) createPrinterList() *gtk.Box {
+	box := gtk.NewBox(gtk.OrientationVertical, 16)
+
+	p.printers = gtk.NewBox(gtk.OrientationVertical, 0)
+	box.Append(p.printers)
+
+	addBtn := gtk.NewButtonWithLabel("Add Printer")
+	addBtn.ConnectClicked(p.openAddView)
+	box.Append(p.m.createSettingRow("Add Printer", "Find IPP Everywhere printers on the network", addBtn))
+
+	jobsTitle := gtk.NewLabel("Print Jobs")
+	jobsTitle.AddCSSClass("section-title")
+	jobsTitle.SetHAlign(gtk.AlignStart)
+	box.Append(jobsTitle)
+
+	p.jobs = gtk.NewBox(gtk.OrientationVertical, 0)
+	box.Append(p.jobs)
+
+	return box
+}
+
+// createAddView builds the add-printer view: the printers found on the
+// network, and the name and address of the one to add, which can also be
+// typed in
+func (p *printersPage) createAddView() *gtk.Box {
+	box := gtk.NewBox(gtk.OrientationVertical, 12)
+
+	title := gtk.NewLabel("Add a Printer")
+	title.AddCSSClass("setting-label")
+	title.SetHAlign(gtk.AlignStart)
+	box.Append(title)
+
+	status := gtk.NewBox(gtk.OrientationHorizontal, 8)
+	p.searching = gtk.NewSpinner()
+	status.Append(p.searching)
+	p.searchStatus = gtk.NewLabel("")
+	p.searchStatus.AddCSSClass("setting-description")
+	p.searchStatus.SetHExpand(true)
+	p.searchStatus.SetHAlign(gtk.AlignStart)
+	status.Append(p.searchStatus)
+	p.searchBtn = gtk.NewButtonWithLabel("Search Again")
+	p.searchBtn.ConnectClicked(p.search)
+	status.Append(p.searchBtn)
+	box.Append(status)
+
+	p.found = gtk.NewListBox()
+	p.found.AddCSSClass("printers-found")
+	p.found.SetSelectionMode(gtk.SelectionSingle)
+	p.found.ConnectRowSelected(func(row *gtk.ListBoxRow) {
+		if row == nil {
+			return
+		}
+		if idx := row.Index(); idx >= 0 && idx < len(p.foundList) {
+			found := p.foundList[idx]
+			p.nameEntry.SetText(queueName(found.Name))
+			p.uriEntry.SetText(found.URI)
+			p.descEntry.SetText(found.Name)
+		}
+	})
+	p.found.SetVisible(false)
+	box.Append(p.found)
+
+	p.nameEntry = gtk.NewEntry()
+	p.nameEntry.SetPlaceholderText("Office_Printer")
+	p.nameEntry.SetHExpand(true)
+	box.Append(p.m.createSettingRow("Name", "Queue name, without spaces", p.nameEntry))
+
+	p.descEntry = gtk.NewEntry()
+	p.descEntry.SetPlaceholderText("Office Printer")
+	p.descEntry.SetHExpand(true)
+	box.Append(p.m.createSettingRow("Description", "Name shown in print dialogs", p.descEntry))
+
+	p.uriEntry = gtk.NewEntry()
+	p.uriEntry.SetPlaceholderText("ipp://printer.local/ipp/print")
+	p.uriEntry.SetHExpand(true)
+	box.Append(p.m.createSettingRow("Address", "IPP address, for printers that aren't found", p.uriEntry))
+
+	buttons := gtk.NewBox(gtk.OrientationHorizontal, 8)
+	buttons.SetHAlign(gtk.AlignEnd)
+	cancelBtn := gtk.NewButtonWithLabel("Cancel")
+	cancelBtn.ConnectClicked(p.closeAddView)
+	buttons.Append(cancelBtn)
+	p.addBtn = gtk.NewButtonWithLabel("Add")
+	p.addBtn.ConnectClicked(p.add)
+	buttons.Append(p.addBtn)
+	box.Append(buttons)
+
+	return box
+}
+
+// refresh rereads the printers and jobs off the main loop
+func (p *printersPage) refresh() {
+	go func() {
+		if !cupsRunning() {
+			glib.IdleAdd(func() {
+				p.message.SetText(cupsStoppedMessage)
+				p.message.SetVisible(true)
+			})
+			return
+		}
+		printers, err := listPrinters()
+		jobs, jobsErr := listJobs()
+		if err == nil {
+			err = jobsErr
+		}
+		glib.IdleAdd(func() {
+			p.render(printers, jobs, err)
+		})
+	}()
+}
+
+func (p *printersPage) render(printers []printer, jobs []printJob, err error) {
+	if err != nil {
+		p.showError(err)
+		return
+	}
+	if p.message.Text() == cupsStoppedMessage {
+		p.message.SetVisible(false)
+	}
+
+	// Lists are only rebuilt when they change, so rows don't vanish under
+	// the pointer every poll
+	if key := fmt.Sprint(printers); key != p.printersKey {
+		p.printersKey = key
+		p.renderPrinters(printers)
+	}
+	if key := fmt.Sprint(jobs); key != p.jobsKey {
+		p.jobsKey = key
+		p.renderJobs(jobs)
+	}
+}
+
+func (p *printersPage) renderPrinters(printers []printer) {
+	for child := p.printers.FirstChild(); child != nil; child = p.printers.FirstChild() {
+		p.printers.Remove(child)
+	}
+	if len(printers) == 0 {
+		label := gtk.NewLabel("No printers added")
+		label.AddCSSClass("setting-description")
+		label.SetHAlign(gtk.AlignStart)
+		label.SetMarginBottom(8)
+		p.printers.Append(label)
+		return
+	}
+
+	for _, pr := range printers {
+		controls := gtk.NewBox(gtk.OrientationHorizontal, 8)
+
+		defaultBtn := gtk.NewButtonWithLabel("Set Default")
+		defaultBtn.SetVAlign(gtk.AlignCenter)
+		defaultBtn.SetSensitive(!pr.Default)
+		defaultBtn.ConnectClicked(func() {
+			p.do(defaultBtn, func() error {
+				return setDefaultPrinter(pr.Name)
+			})
+		})
+		controls.Append(defaultBtn)
+
+		testBtn := gtk.NewButtonWithLabel("Test Page")
+		testBtn.SetVAlign(gtk.AlignCenter)
+		testBtn.SetSensitive(pr.State != "disabled")
+		testBtn.ConnectClicked(func() {
+			p.do(testBtn, func() error {
+				return printTestPage(pr.Name)
+			})
+		})
+		controls.Append(testBtn)
+
+		removeBtn := gtk.NewButtonWithLabel("Remove")
+		removeBtn.SetVAlign(gtk.AlignCenter)
+		removeBtn.ConnectClicked(func() {
+			p.do(removeBtn, func() error {
+				return removePrinter(pr.Name)
+			})
+		})
+		controls.Append(removeBtn)
+
+		title := pr.Name
+		if pr.Description != "" {
+			title = pr.Description
+		}
+		row := p.m.createSettingRow(title, printerSummary(pr), controls)
+		row.SetTooltipText(pr.URI)
+		p.printers.Append(row)
+	}
+}
+
+// printerSummary is the line under a printer, e.g.
+// "Default · Idle · Office"
+func printerSummary(pr printer) string {
+	var parts []string
+	if pr.Default {
+		parts = append(parts, "Default")
+	}
+	parts = append(parts, capitalize(pr.State))
+	if pr.Location != "" {
+		parts = append(parts, pr.Location)
+	}
+	return strings.Join(parts, " · ")
+}
+
+func capitalize(s string) string {
+	if s == "" {
+		return s
+	}
+	return strings.ToUpper(s[:1]) + s[1:]
+}
+
+func (p *printersPage) renderJobs(jobs []printJob) {
+	for child := p.jobs.FirstChild(); child != nil; child = p.jobs.FirstChild() {
+		p.jobs.Remove(child)
+	}
+	if len(jobs) == 0 {
+		label := gtk.NewLabel("No print jobs")
+		label.AddCSSClass("setting-description")
+		label.SetHAlign(gtk.AlignStart)
+		p.jobs.Append(label)
+		return
+	}
+
+	for _, job := range jobs {
+		cancelBtn := gtk.NewButtonWithLabel("Cancel")
+		cancelBtn.SetVAlign(gtk.AlignCenter)
+		cancelBtn.ConnectClicked(func() {
+			p.do(cancelBtn, func() error {
+				return cancelJob(job.ID)
+			})
+		})
+
+		state := "Queued"
+		if job.Printing {
+			state = "Printing"
+		}
+		description := fmt.Sprintf("%s · %s · %s", state, job.User, glib.FormatSize(uint64(job.Size)))
+		title := job.ID
+		if number, ok := strings.CutPrefix(job.ID, job.Printer+"-"); ok {
+			title = fmt.Sprintf("Job %s on %s", number, job.Printer)
+		}
+		p.jobs.Append(p.m.createSettingRow(title, description, cancelBtn))
+	}
+}
+
+// showError reports a failed request at the top of the page
+func (p *printersPage) showError(err error) {
+	fmt.Fprintf(os.Stderr, "raven-settings-menu: printers: %v\n", err)
+	p.message.SetText(err.Error())
+	p.message.SetVisible(true)
+}
+
+// do runs a CUPS request off the main loop with widget disabled, then
+// rereads the printers
+func (p *printersPage) do(widget gtk.Widgetter, request func() error) {
+	gtk.BaseWidget(widget).SetSensitive(false)
+	p.message.SetVisible(false)
+	go func() {
+		err := request()
+		glib.IdleAdd(func() {
+			gtk.BaseWidget(widget).SetSensitive(true)
+			if err != nil {
+				p.showError(err)
+			}
+			p.refresh()
+		})
+	}()
+}
+
+// openAddView shows the add-printer view and searches the network
+func (p *printersPage) openAddView() {
+	p.nameEntry.SetText("")
+	p.descEntry.SetText("")
+	p.uriEntry.SetText("")
+	p.message.SetVisible(false)
+	p.stack.SetVisibleChildName("add")
+	p.search()
+}
+
+func (p *printersPage) closeAddView() {
+	p.stack.SetVisibleChildName("printers")
+	p.refresh()
+}
+
+// search lists the IPP printers announced on the network that haven't
+// been added
+func (p *printersPage) search() {
+	p.searching.Start()
+	p.searchBtn.SetSensitive(false)
+	p.searchStatus.SetText("Searching the network...")
+	p.renderFound(nil)
+
+	go func() {
+		found, err := discoverPrinters()
+		added := make(map[string]bool)
+		if printers, err := listPrinters(); err == nil {
+			for _, pr := range printers {
+				added[pr.URI] = true
+			}
+		}
+		var fresh []foundPrinter
+		for _, f := range found {
+			if !added[f.URI] {
+				fresh = append(fresh, f)
+			}
+		}
+
+		glib.IdleAdd(func() {
+			p.searching.Stop()
+			p.searchBtn.SetSensitive(true)
+			switch {
+			case err != nil:
+				p.searchStatus.SetText(err.Error())
+			case len(fresh) == 0:
+				p.searchStatus.SetText("No new printers found. Enter the printer's address below.")
+			default:
+				p.searchStatus.SetText("Pick a printer, or enter its address below")
+			}
+			p.renderFound(fresh)
+		})
+	}()
+}
+
+func (p *printersPage) renderFound(found []foundPrinter) {
+	p.foundList = found
+	for row := p.found.RowAtIndex(0); row != nil; row = p.found.RowAtIndex(0) {
+		p.found.Remove(row)
+	}
+	p.found.SetVisible(len(found) > 0)
+
+	for _, f := range found {
+		row := gtk.NewBox(gtk.OrientationHorizontal, 12)
+		row.SetMarginTop(6)
+		row.SetMarginBottom(6)
+		image := gtk.NewImageFromIconName("printer-network")
+		image.SetPixelSize(24)
+		row.Append(image)
+
+		labels := gtk.NewBox(gtk.OrientationVertical, 2)
+		name := gtk.NewLabel(f.Name)
+		name.AddCSSClass("setting-label")
+		name.SetHAlign(gtk.AlignStart)
+		labels.Append(name)
+		detail := f.URI
+		if f.Model != "" {
+			detail = f.Model + " · " + f.URI
+		}
+		address := gtk.NewLabel(detail)
+		address.AddCSSClass("setting-description")
+		address.SetHAlign(gtk.AlignStart)
+		labels.Append(address)
+		row.Append(labels)
+
+		p.found.Append(row)
+	}
+}
+
+// add creates the queue. CUPS contacts the printer for its capabilities,
+// so a wrong address fails here.
+func (p *printersPage) add() {
+	name := queueName(strings.TrimSpace(p.nameEntry.Text()))
+	uri := strings.TrimSpace(p.uriEntry.Text())
+	description := strings.TrimSpace(p.descEntry.Text())
+	if name == "" || uri == "" {
+		p.showError(fmt.Errorf("enter a name and address for the printer"))
+		return
+	}
+
+	p.addBtn.SetLabel("Adding...")
+	p.addBtn.SetSensitive(false)
+	p.message.SetVisible(false)
+	go func() {
+		err := addPrinter(name, uri, description)
+		glib.IdleAdd(func() {
+			p.addBtn.SetLabel("Add")
+			p.addBtn.SetSensitive(true)
+			if err != nil {
+				p.showError(err)
+				return
+			}
+			p.closeAddView()
+		})
+	}()
+}
@@ -0,0 +1,523 @@
+package main
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/diamondburned/gotk4/pkg/gtk/v4"
+)
+
+// Extension of exported settings bundles
+const profileExt = ".ravenprofile"
+
+// Files in a profile besides the wallpapers, by name in the archive
+var profileConfigs = []string{"settings.json", "dock.json", "pinned-apps.json"}
+
+// Wallpapers are kept in the archive below this directory, which is also
+// how settings.json refers to them there
+const profileWallpaperDir = "wallpapers/"
+
+// profileConfigPath returns where a file of a profile lives on disk
+func profileConfigPath(name string) string {
+	home := os.Getenv("HOME")
+	switch name {
+	case "dock.json":
+		configDir, _ := os.UserConfigDir()
+		return filepath.Join(configDir, "raven-shell", "dock.json")
+	case "pinned-apps.json":
+		return filepath.Join(home, ".config", "raven", "pinned-apps.json")
+	}
+	return filepath.Join(home, ".config", "raven", "settings.json")
+}
+
+// profilesDir holds the named profiles, one archive each
+func profilesDir() string {
+	return filepath.Join(os.Getenv("HOME"), ".config", "raven", "profiles")
+}
+
+// listProfiles returns the names of the saved profiles, sorted
+func listProfiles() []string {
+	matches, _ := filepath.Glob(filepath.Join(profilesDir(), "*"+profileExt))
+	var names []string
+	for _, match := range matches {
+		names = append(names, strings.TrimSuffix(filepath.Base(match), profileExt))
+	}
+	sort.Strings(names)
+	return names
+}
+
+// validProfileName reports whether name can be used as a profile file name
+func validProfileName(name string) bool {
+	return name != "" && !strings.HasPrefix(name, ".") && !strings.ContainsAny(name, `/\`)
+}
+
+// exportProfile bundles settings.json, dock.json, pinned-apps.json and the
+// wallpapers settings.json refers to into a gzipped tar at dest
+func (m *RavenSettingsMenu) exportProfile(dest string) error {
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return err
+	}
+	tmp := dest + ".tmp"
+	file, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp)
+
+	gz := gzip.NewWriter(file)
+	tw := tar.NewWriter(gz)
+
+	if err := m.writeProfileArchive(tw); err != nil {
+		file.Close()
+		return err
+	}
+	if err := tw.Close(); err != nil {
+		file.Close()
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp, dest)
+}
+
+func (m *RavenSettingsMenu) writeProfileArchive(tw *tar.Writer) error {
+	m.flushSettings()
+	config := m.readSettingsFile()
+	delete(config, "profile")
+
+	// Wallpapers from one directory share one in the archive, so light and
+	// dark variants still sit next to each other
+	wallpapers := make(map[string]string) // archive name -> path on disk
+	dirs := make(map[string]string)
+	bundle := func(p string) string {
+		if p == "" || strings.HasPrefix(p, profileWallpaperDir) {
+			return p
+		}
+		if _, err := os.Stat(p); err != nil {
+			return p
+		}
+		dir, ok := dirs[filepath.Dir(p)]
+		if !ok {
+			dir = strconv.Itoa(len(dirs))
+			dirs[filepath.Dir(p)] = dir
+		}
+		name := profileWallpaperDir + dir + "/" + filepath.Base(p)
+		wallpapers[name] = p
+		for _, variant := range wallpaperVariants(p) {
+			wallpapers[profileWallpaperDir+dir+"/"+filepath.Base(variant)] = variant
+		}
+		return name
+	}
+
+	var wallpaper string
+	if json.Unmarshal(config["wallpaper_path"], &wallpaper) == nil && wallpaper != "" {
+		config["wallpaper_path"], _ = json.Marshal(bundle(wallpaper))
+	}
+	var recent []string
+	if json.Unmarshal(config["recent_wallpapers"], &recent) == nil && len(recent) > 0 {
+		for i, p := range recent {
+			recent[i] = bundle(p)
+		}
+		config["recent_wallpapers"], _ = json.Marshal(recent)
+	}
+
+	settings, err := json.MarshalIndent(config, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := writeTarFile(tw, "settings.json", settings); err != nil {
+		return err
+	}
+
+	for _, name := range profileConfigs[1:] {
+		data, err := os.ReadFile(profileConfigPath(name))
+		if err != nil {
+			continue
+		}
+		if err := writeTarFile(tw, name, data); err != nil {
+			return err
+		}
+	}
+
+	names := make([]string, 0, len(wallpapers))
+	for name := range wallpapers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		data, err := os.ReadFile(wallpapers[name])
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "raven-settings-menu: skipping wallpaper %s: %v\n", wallpapers[name], err)
+			continue
+		}
+		if err := writeTarFile(tw, name, data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// wallpaperVariants returns the -light and -dark versions of a wallpaper
+// that exist next to it, as raven-shell's automatic theme uses them
+func wallpaperVariants(wallpaper string) []string {
+	ext := filepath.Ext(wallpaper)
+	base := strings.TrimSuffix(wallpaper, ext)
+	base = strings.TrimSuffix(strings.TrimSuffix(base, "-light"), "-dark")
+
+	var variants []string
+	for _, variant := range []string{base + ext, base + "-light" + ext, base + "-dark" + ext} {
+		if variant == wallpaper {
+			continue
+		}
+		if _, err := os.Stat(variant); err == nil {
+			variants = append(variants, variant)
+		}
+	}
+	return variants
+}
+
+func writeTarFile(tw *tar.Writer, name string, data []byte) error {
+	header := &tar.Header{
+		Name: name,
+		Mode: 0644,
+		Size: int64(len(data)),
+	}
+	if err := tw.WriteHeader(header); err != nil {
+		return err
+	}
+	_, err := tw.Write(data)
+	return err
+}
+
+// importProfile replaces settings.json, dock.json and pinned-apps.json
+// with those in the archive at src. Its wallpapers are unpacked below
+// ~/.local/share/raven/wallpapers/<profile>, where profile names the
+// import, and settings.json is pointed at them.
+func (m *RavenSettingsMenu) importProfile(src, profile string) error {
+	file, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	gz, err := gzip.NewReader(file)
+	if err != nil {
+		return fmt.Errorf("%s is not a Raven profile: %w", src, err)
+	}
+	defer gz.Close()
+
+	wallpaperDir := filepath.Join(os.Getenv("HOME"), ".local", "share", "raven", "wallpapers", profile)
+	configs := make(map[string][]byte)
+
+	tr := tar.NewReader(gz)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("reading %s: %w", src, err)
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		name := path.Clean(header.Name)
+		if strings.HasPrefix(name, profileWallpaperDir) {
+			dest := filepath.Join(wallpaperDir, filepath.FromSlash(strings.TrimPrefix(name, profileWallpaperDir)))
+			if !strings.HasPrefix(dest, wallpaperDir+string(filepath.Separator)) {
+				continue
+			}
+			if err := writeFileFrom(dest, tr); err != nil {
+				return err
+			}
+			continue
+		}
+		for _, config := range profileConfigs {
+			if name == config {
+				data, err := io.ReadAll(tr)
+				if err != nil {
+					return err
+				}
+				configs[name] = data
+			}
+		}
+	}
+
+	data, ok := configs["settings.json"]
+	if !ok {
+		return fmt.Errorf("%s has no settings.json", src)
+	}
+	config := make(map[string]json.RawMessage)
+	if err := json.Unmarshal(data, &config); err != nil {
+		return fmt.Errorf("invalid settings.json in %s: %w", src, err)
+	}
+
+	unbundle := func(p string) string {
+		if !strings.HasPrefix(p, profileWallpaperDir) {
+			return p
+		}
+		return filepath.Join(wallpaperDir, filepath.FromSlash(strings.TrimPrefix(p, profileWallpaperDir)))
+	}
+	var wallpaper string
+	if json.Unmarshal(config["wallpaper_path"], &wallpaper) == nil && wallpaper != "" {
+		config["wallpaper_path"], _ = json.Marshal(unbundle(wallpaper))
+	}
+	var recent []string
+	if json.Unmarshal(config["recent_wallpapers"], &recent) == nil && len(recent) > 0 {
+		for i, p := range recent {
+			recent[i] = unbundle(p)
+		}
+		config["recent_wallpapers"], _ = json.Marshal(recent)
+	}
+	config["profile"], _ = json.Marshal(profile)
+
+	for _, name := range profileConfigs[1:] {
+		if data, ok := configs[name]; ok {
+			dest := profileConfigPath(name)
+			if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+				return err
+			}
+			if err := os.WriteFile(dest, data, 0644); err != nil {
+				return err
+			}
+		}
+	}
+	if err := m.writeSettingsFile(config); err != nil {
+		return err
+	}
+
+	m.loadSettings()
+	return nil
+}
+
+func writeFileFrom(dest string, r io.Reader) error {
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return err
+	}
+	file, err := os.Create(dest)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(file, r); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// switchProfile saves the current settings to the active profile and
+// loads the profile called name
+func (m *RavenSettingsMenu) switchProfile(name string) error {
+	if m.settings.Profile == name {
+		return nil
+	}
+	if m.settings.Profile != "" {
+		if err := m.exportProfile(filepath.Join(profilesDir(), m.settings.Profile+profileExt)); err != nil {
+			return fmt.Errorf("saving profile %s: %w", m.settings.Profile, err)
+		}
+	}
+	return m.importProfile(filepath.Join(profilesDir(), name+profileExt), name)
+}
+
+// leaveProfile saves the active profile and stops tracking it, so later
+// changes belong to no profile
+func (m *RavenSettingsMenu) leaveProfile() {
+	if m.settings.Profile == "" {
+		return
+	}
+	if err := m.exportProfile(filepath.Join(profilesDir(), m.settings.Profile+profileExt)); err != nil {
+		fmt.Fprintf(os.Stderr, "raven-settings-menu: saving profile %s: %v\n", m.settings.Profile, err)
+	}
+	m.settings.Profile = ""
+	m.saveSettings()
+}
+
+// saveProfileAs stores the current settings as a new profile and makes it
+// the active one
+func (m *RavenSettingsMenu) saveProfileAs(name string) error {
+	if err := m.exportProfile(filepath.Join(profilesDir(), name+profileExt)); err != nil {
+		return err
+	}
+	m.settings.Profile = name
+	m.saveSettings()
+	return nil
+}
+
+// reloadUI rebuilds every page after the settings were replaced, and
+// applies the new wallpaper
+func (m *RavenSettingsMenu) reloadUI() {
+	m.window.SetChild(m.createUI())
+	m.applyWallpaper()
+}
+
+// createProfileControls returns the header's profile switcher with the
+// Save As, Export and Import buttons
+func (m *RavenSettingsMenu) createProfileControls() *gtk.Box {
+	box := gtk.NewBox(gtk.OrientationHorizontal, 6)
+	box.SetVAlign(gtk.AlignCenter)
+
+	profiles := listProfiles()
+	labels := append([]string{"No Profile"}, profiles...)
+	dropdown := gtk.NewDropDown(gtk.NewStringList(labels), nil)
+	dropdown.SetTooltipText("Settings profile")
+	for i, name := range profiles {
+		if name == m.settings.Profile {
+			dropdown.SetSelected(uint(i + 1))
+		}
+	}
+	dropdown.Connect("notify::selected", func() {
+		idx := dropdown.Selected()
+		if idx == 0 {
+			m.leaveProfile()
+			return
+		}
+		if idx > uint(len(profiles)) {
+			return
+		}
+		if err := m.switchProfile(profiles[idx-1]); err != nil {
+			fmt.Fprintf(os.Stderr, "raven-settings-menu: %v\n", err)
+			return
+		}
+		m.reloadUI()
+	})
+	box.Append(dropdown)
+
+	// Save As asks for the name in a popover
+	nameEntry := gtk.NewEntry()
+	nameEntry.SetPlaceholderText("Profile name")
+	popover := gtk.NewPopover()
+	popover.SetChild(nameEntry)
+
+	saveAsBtn := gtk.NewMenuButton()
+	saveAsBtn.SetLabel("Save As")
+	saveAsBtn.SetTooltipText("Save the current settings as a new profile")
+	saveAsBtn.SetPopover(popover)
+	nameEntry.ConnectActivate(func() {
+		name := strings.TrimSpace(nameEntry.Text())
+		if !validProfileName(name) {
+			nameEntry.AddCSSClass("error")
+			return
+		}
+		popover.Popdown()
+		if err := m.saveProfileAs(name); err != nil {
+			fmt.Fprintf(os.Stderr, "raven-settings-menu: failed to save profile %s: %v\n", name, err)
+			return
+		}
+		m.reloadUI()
+	})
+	nameEntry.ConnectChanged(func() {
+		nameEntry.RemoveCSSClass("error")
+	})
+	box.Append(saveAsBtn)
+
+	exportBtn := gtk.NewButton()
+	exportBtn.SetLabel("Export")
+	exportBtn.SetTooltipText("Save settings, dock, pinned apps and wallpapers to a " + profileExt + " file")
+	exportBtn.ConnectClicked(m.chooseExport)
+	box.Append(exportBtn)
+
+	importBtn := gtk.NewButton()
+	importBtn.SetLabel("Import")
+	importBtn.SetTooltipText("Load a " + profileExt + " file")
+	importBtn.ConnectClicked(m.chooseImport)
+	box.Append(importBtn)
+
+	return box
+}
+
+func (m *RavenSettingsMenu) chooseExport() {
+	dialog := gtk.NewFileChooserNative(
+		"Export Settings",
+		m.window,
+		gtk.FileChooserActionSave,
+		"Export",
+		"Cancel",
+	)
+	name := m.settings.Profile
+	if name == "" {
+		name = "raven"
+	}
+	dialog.SetCurrentName(name + profileExt)
+
+	dialog.ConnectResponse(func(response int) {
+		if response != int(gtk.ResponseAccept) {
+			return
+		}
+		file := dialog.File()
+		if file == nil || file.Path() == "" {
+			return
+		}
+		dest := file.Path()
+		if !strings.HasSuffix(dest, profileExt) {
+			dest += profileExt
+		}
+		if err := m.exportProfile(dest); err != nil {
+			fmt.Fprintf(os.Stderr, "raven-settings-menu: failed to export %s: %v\n", dest, err)
+		}
+	})
+	dialog.Show()
+}
+
+func (m *RavenSettingsMenu) chooseImport() {
+	dialog := gtk.NewFileChooserNative(
+		"Import Settings",
+		m.window,
+		gtk.FileChooserActionOpen,
+		"Import",
+		"Cancel",
+	)
+
+	filter := gtk.NewFileFilter()
+	filter.SetName("Raven Profiles")
+	filter.AddPattern("*" + profileExt)
+	dialog.AddFilter(filter)
+
+	dialog.ConnectResponse(func(response int) {
+		if response != int(gtk.ResponseAccept) {
+			return
+		}
+		file := dialog.File()
+		if file == nil || file.Path() == "" {
+			return
+		}
+		if err := m.importFile(file.Path()); err != nil {
+			fmt.Fprintf(os.Stderr, "raven-settings-menu: failed to import %s: %v\n", file.Path(), err)
+			return
+		}
+		m.reloadUI()
+	})
+	dialog.Show()
+}
+
+// importFile loads an exported profile and keeps it as a named profile,
+// called after the file, so it can be switched back to
+func (m *RavenSettingsMenu) importFile(src string) error {
+	name := strings.TrimSuffix(filepath.Base(src), profileExt)
+	if !validProfileName(name) {
+		name = "imported"
+	}
+	if m.settings.Profile != "" && m.settings.Profile != name {
+		if err := m.exportProfile(filepath.Join(profilesDir(), m.settings.Profile+profileExt)); err != nil {
+			return fmt.Errorf("saving profile %s: %w", m.settings.Profile, err)
+		}
+	}
+	if err := m.importProfile(src, name); err != nil {
+		return err
+	}
+	return m.exportProfile(filepath.Join(profilesDir(), name+profileExt))
+}
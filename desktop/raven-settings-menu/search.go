@@ -0,0 +1,262 @@
+package main
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/diamondburned/gotk4/pkg/gdk/v4"
+	"github.com/diamondburned/gotk4/pkg/glib/v2"
+	"github.com/diamondburned/gotk4/pkg/gtk/v4"
+)
+
+// Most matches listed under the search field
+const maxSearchResults = 8
+
+// How long a row found by search stays highlighted, in milliseconds
+const searchHighlightTime = 2000
+
+// settingEntry is a row made by createSettingRow, which search can find
+type settingEntry struct {
+	title       string
+	description string
+	row         *gtk.Box
+}
+
+// searchMatch is a row matching the search, on the page at index page
+type searchMatch struct {
+	entry settingEntry
+	page  int
+	score int
+}
+
+// createSearchEntry returns the header's search field. Matching rows of
+// every page are listed below it; picking one shows its page and
+// highlights the row.
+func (m *RavenSettingsMenu) createSearchEntry() *gtk.SearchEntry {
+	entry := gtk.NewSearchEntry()
+	entry.SetPlaceholderText("Search settings")
+	entry.SetVAlign(gtk.AlignCenter)
+	entry.SetSizeRequest(220, -1)
+
+	results := gtk.NewListBox()
+	results.AddCSSClass("search-results")
+	results.SetSelectionMode(gtk.SelectionBrowse)
+
+	// The popover must not take focus from the entry while typing
+	popover := gtk.NewPopover()
+	popover.SetParent(entry)
+	popover.SetAutohide(false)
+	popover.SetHasArrow(false)
+	popover.SetPosition(gtk.PosBottom)
+	popover.SetChild(results)
+
+	var matches []searchMatch
+	jump := func(idx int) {
+		if idx < 0 || idx >= len(matches) {
+			return
+		}
+		popover.Popdown()
+		m.showSettingRow(matches[idx])
+	}
+
+	entry.ConnectSearchChanged(func() {
+		for row := results.RowAtIndex(0); row != nil; row = results.RowAtIndex(0) {
+			results.Remove(row)
+		}
+		matches = m.searchSettings(entry.Text())
+		if len(matches) == 0 {
+			popover.Popdown()
+			return
+		}
+		for _, match := range matches {
+			results.Append(m.createSearchResultRow(match))
+		}
+		results.SelectRow(results.RowAtIndex(0))
+		popover.Popup()
+	})
+	results.ConnectRowActivated(func(row *gtk.ListBoxRow) {
+		jump(row.Index())
+	})
+	entry.ConnectActivate(func() {
+		idx := 0
+		if row := results.SelectedRow(); row != nil {
+			idx = row.Index()
+		}
+		jump(idx)
+	})
+	entry.ConnectStopSearch(func() {
+		entry.SetText("")
+		popover.Popdown()
+	})
+
+	// Up and Down move through the results without leaving the entry
+	keys := gtk.NewEventControllerKey()
+	keys.ConnectKeyPressed(func(keyval, keycode uint, state gdk.ModifierType) bool {
+		step := 0
+		switch keyval {
+		case gdk.KEY_Down:
+			step = 1
+		case gdk.KEY_Up:
+			step = -1
+		default:
+			return false
+		}
+		idx := 0
+		if row := results.SelectedRow(); row != nil {
+			idx = row.Index() + step
+		}
+		if row := results.RowAtIndex(idx); row != nil {
+			results.SelectRow(row)
+		}
+		return true
+	})
+	entry.AddController(keys)
+
+	return entry
+}
+
+func (m *RavenSettingsMenu) createSearchResultRow(match searchMatch) *gtk.ListBoxRow {
+	row := gtk.NewListBoxRow()
+
+	box := gtk.NewBox(gtk.OrientationVertical, 2)
+	box.SetMarginTop(4)
+	box.SetMarginBottom(4)
+
+	titleLabel := gtk.NewLabel(match.entry.title)
+	titleLabel.AddCSSClass("category-name")
+	titleLabel.SetHAlign(gtk.AlignStart)
+	box.Append(titleLabel)
+
+	pageLabel := gtk.NewLabel(m.pages[match.page].Info().Name)
+	pageLabel.AddCSSClass("category-desc")
+	pageLabel.SetHAlign(gtk.AlignStart)
+	box.Append(pageLabel)
+
+	row.SetChild(box)
+	return row
+}
+
+// searchSettings returns the rows whose title or description fuzzy-match
+// query, best first. Rows that were replaced since are dropped from the
+// index.
+func (m *RavenSettingsMenu) searchSettings(query string) []searchMatch {
+	query = strings.TrimSpace(query)
+	if query == "" {
+		return nil
+	}
+
+	var matches []searchMatch
+	live := m.settingEntries[:0]
+	for _, entry := range m.settingEntries {
+		page := m.pageOf(entry.row)
+		if page < 0 {
+			continue
+		}
+		live = append(live, entry)
+
+		// Titles count for more than descriptions
+		score := fuzzyScore(query, entry.title) * 2
+		if s := fuzzyScore(query, entry.description); s > score {
+			score = s
+		}
+		if score > 0 {
+			matches = append(matches, searchMatch{entry: entry, page: page, score: score})
+		}
+	}
+	m.settingEntries = live
+
+	sort.SliceStable(matches, func(i, j int) bool {
+		return matches[i].score > matches[j].score
+	})
+	if len(matches) > maxSearchResults {
+		matches = matches[:maxSearchResults]
+	}
+	return matches
+}
+
+// pageOf returns the index of the page holding row, or -1 when it is no
+// longer on any page
+func (m *RavenSettingsMenu) pageOf(row *gtk.Box) int {
+	for i, widget := range m.pageWidgets {
+		if row.IsAncestor(widget) {
+			return i
+		}
+	}
+	return -1
+}
+
+// showSettingRow selects the page of a search match, scrolls its row into
+// view and highlights it for a moment
+func (m *RavenSettingsMenu) showSettingRow(match searchMatch) {
+	if row := m.categoryList.RowAtIndex(match.page); row != nil {
+		m.categoryList.SelectRow(row)
+	}
+
+	row := match.entry.row
+	// Rows in a sub-page, such as the Displays page's color settings, are
+	// in a stack that is switched to them
+	for child := gtk.Widgetter(row); child != nil; {
+		parent := gtk.BaseWidget(child).Parent()
+		if stack, ok := parent.(*gtk.Stack); ok {
+			stack.SetVisibleChild(child)
+		}
+		child = parent
+	}
+
+	// The page is only laid out once it is shown
+	glib.TimeoutAdd(100, func() bool {
+		if scroll, ok := row.Ancestor(gtk.GTypeScrolledWindow).(*gtk.ScrolledWindow); ok {
+			if _, y, ok := row.TranslateCoordinates(scroll.Child(), 0, 0); ok {
+				scroll.VAdjustment().SetValue(y - 20)
+			}
+		}
+		row.AddCSSClass("setting-highlight")
+		glib.TimeoutAdd(searchHighlightTime, func() bool {
+			row.RemoveCSSClass("setting-highlight")
+			return false
+		})
+		return false
+	})
+}
+
+// fuzzyScore rates how well query matches text, ignoring case: 0 when the
+// letters of query don't all appear in text in order. Substrings, runs of
+// consecutive letters and matches at word starts score higher.
+func fuzzyScore(query, text string) int {
+	query = strings.ToLower(query)
+	lower := strings.ToLower(text)
+	if query == "" || lower == "" {
+		return 0
+	}
+
+	score := 0
+	if idx := strings.Index(lower, query); idx >= 0 {
+		score += 100
+		if idx == 0 || !unicode.IsLetter(rune(lower[idx-1])) {
+			score += 50
+		}
+	}
+
+	q := []rune(query)
+	qi := 0
+	run := 0
+	prev := ' '
+	for _, r := range lower {
+		if qi < len(q) && r == q[qi] {
+			score += 1 + run*2
+			if !unicode.IsLetter(prev) && !unicode.IsDigit(prev) {
+				score += 5
+			}
+			run++
+			qi++
+		} else {
+			run = 0
+		}
+		prev = r
+	}
+	if qi < len(q) {
+		return 0
+	}
+	return score
+}
@@ -0,0 +1,386 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/diamondburned/gotk4/pkg/glib/v2"
+	"github.com/diamondburned/gotk4/pkg/gtk/v4"
+)
+
+// How often the Services page rechecks what is running
+const servicesPollSeconds = 2
+
+// Log lines shown for each service
+const serviceLogLines = 12
+
+// sessionService is a background program started with the Hyprland session
+type sessionService struct {
+	Name        string // Also the log file name
+	Title       string
+	Description string
+	Commands    []string // Each one is run by sh; alternatives use ||
+	Binaries    []string // Any of these installed means the service is
+	match       func(args []string) bool
+}
+
+// sessionServices are the services the Services page manages. Their
+// exec-once lines are written to raven-autostart.conf.
+var sessionServices = []sessionService{
+	{
+		Name: "raven-settingsd", Title: "Settings Events", Description: "Tells the shell and desktop when settings change",
+		Commands: []string{"raven-settingsd"},
+		Binaries: []string{"raven-settingsd"},
+		match:    matchProgram("raven-settingsd"),
+	},
+	{
+		Name: "raven-shell", Title: "Shell", Description: "Panel and dock",
+		Commands: []string{"raven-shell"},
+		Binaries: []string{"raven-shell"},
+		match:    matchProgram("raven-shell"),
+	},
+	{
+		Name: "raven-desktop", Title: "Desktop", Description: "Wallpaper and desktop icons",
+		Commands: []string{"raven-desktop"},
+		Binaries: []string{"raven-desktop"},
+		match:    matchProgram("raven-desktop"),
+	},
+	{
+		Name: "notifications", Title: "Notifications", Description: "Notification daemon (mako, dunst or swaync)",
+		Commands: []string{"mako || dunst || swaync"},
+		Binaries: []string{"mako", "dunst", "swaync"},
+		match:    matchProgram("mako", "dunst", "swaync"),
+	},
+	{
+		Name: "raven-powerd", Title: "Power", Description: "Power management daemon",
+		Commands: []string{"raven-powerd"},
+		Binaries: []string{"raven-powerd"},
+		match:    matchProgram("raven-powerd"),
+	},
+	{
+		Name: "idle", Title: "Idle", Description: "Turns off the screen and suspends after inactivity (hypridle or swayidle)",
+		Commands: []string{"hypridle || swayidle -w"},
+		Binaries: []string{"hypridle", "swayidle"},
+		match:    matchProgram("hypridle", "swayidle"),
+	},
+	{
+		Name: "night-light", Title: "Night Light", Description: "Screen color temperature (hyprsunset)",
+		Commands: []string{"hyprsunset"},
+		Binaries: []string{"hyprsunset"},
+		match:    matchProgram("hyprsunset"),
+	},
+	{
+		Name: "clipboard", Title: "Clipboard History", Description: "Stores copied text and images with cliphist",
+		Commands: []string{
+			"wl-paste --type text --watch cliphist store",
+			"wl-paste --type image --watch cliphist store",
+		},
+		Binaries: []string{"cliphist"},
+		match: func(args []string) bool {
+			return len(args) > 0 && filepath.Base(args[0]) == "wl-paste" && slices.Contains(args, "cliphist")
+		},
+	},
+	{
+		// kdeconnectd isn't in PATH; kdeconnect-cli comes with it
+		Name: "kdeconnect", Title: "Phone (KDE Connect)", Description: "Connects paired phones for notifications, battery and file sharing",
+		Commands: []string{"/usr/lib/kdeconnectd || /usr/libexec/kdeconnectd"},
+		Binaries: []string{"kdeconnect-cli"},
+		match:    matchProgram("kdeconnectd"),
+	},
+}
+
+// matchProgram matches processes running one of names
+func matchProgram(names ...string) func(args []string) bool {
+	return func(args []string) bool {
+		return len(args) > 0 && slices.Contains(names, filepath.Base(args[0]))
+	}
+}
+
+// findService returns the session service called name
+func findService(name string) (sessionService, bool) {
+	for _, s := range sessionServices {
+		if s.Name == name {
+			return s, true
+		}
+	}
+	return sessionService{}, false
+}
+
+// installed reports whether one of the service's programs is in PATH
+func (s sessionService) installed() bool {
+	for _, bin := range s.Binaries {
+		if _, err := exec.LookPath(bin); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// logPath returns the service's log, rewritten on every start
+func (s sessionService) logPath() string {
+	return filepath.Join(runtimeDir(), s.Name+".log")
+}
+
+// runtimeDir returns $XDG_RUNTIME_DIR, where session logs are kept
+func runtimeDir() string {
+	if dir := os.Getenv("XDG_RUNTIME_DIR"); dir != "" {
+		return dir
+	}
+	return fmt.Sprintf("/run/user/%d", os.Getuid())
+}
+
+// shellLines returns the service's commands with output sent to its log.
+// The log path is left to the shell, which expands $XDG_RUNTIME_DIR.
+func (s sessionService) shellLines() []string {
+	lines := make([]string, len(s.Commands))
+	for i, command := range s.Commands {
+		redirect := ">"
+		if i > 0 {
+			redirect = ">>"
+		}
+		lines[i] = fmt.Sprintf("(%s) %s \"$XDG_RUNTIME_DIR/%s.log\" 2>&1", command, redirect, s.Name)
+	}
+	return lines
+}
+
+// pids returns the processes belonging to the service
+func (s sessionService) pids() []int {
+	entries, err := os.ReadDir("/proc")
+	if err != nil {
+		return nil
+	}
+
+	var pids []int
+	for _, entry := range entries {
+		pid, err := strconv.Atoi(entry.Name())
+		if err != nil {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join("/proc", entry.Name(), "cmdline"))
+		if err != nil || len(data) == 0 {
+			continue
+		}
+		args := strings.Split(strings.TrimRight(string(data), "\x00"), "\x00")
+		if s.match(args) {
+			pids = append(pids, pid)
+		}
+	}
+	return pids
+}
+
+// restart stops the service's processes and starts it again in its own
+// session, so it outlives the settings window
+func (s sessionService) restart() error {
+	for _, pid := range s.pids() {
+		syscall.Kill(pid, syscall.SIGTERM)
+	}
+	for i := 0; i < 20 && len(s.pids()) > 0; i++ {
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	for _, line := range s.shellLines() {
+		cmd := exec.Command("sh", "-c", line)
+		cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+		if err := cmd.Start(); err != nil {
+			return err
+		}
+		go cmd.Wait()
+	}
+	return nil
+}
+
+// recentLog returns the last lines of the service's log
+func (s sessionService) recentLog() string {
+	data, err := os.ReadFile(s.logPath())
+	if err != nil {
+		return "No log yet. Logs are kept from the service's last start."
+	}
+	lines := strings.Split(strings.TrimRight(string(data), "\n"), "\n")
+	if len(lines) > serviceLogLines {
+		lines = lines[len(lines)-serviceLogLines:]
+	}
+	if len(lines) == 1 && lines[0] == "" {
+		return "Log is empty"
+	}
+	return strings.Join(lines, "\n")
+}
+
+// hyprAutostartPath returns the Hyprland config fragment that starts the
+// session services, sourced by hyprland.conf
+func hyprAutostartPath() string {
+	return filepath.Join(filepath.Dir(hyprInputPath()), "raven-autostart.conf")
+}
+
+// writeAutostart writes exec-once lines for the services that aren't in
+// disabled
+func writeAutostart(disabled []string) error {
+	var b bytes.Buffer
+	b.WriteString("# Written by raven-settings-menu (Services)\n")
+	for _, s := range sessionServices {
+		if slices.Contains(disabled, s.Name) {
+			b.WriteString("# " + s.Name + " is disabled\n")
+			continue
+		}
+		for _, line := range s.shellLines() {
+			b.WriteString("exec-once = " + line + "\n")
+		}
+	}
+
+	path := hyprAutostartPath()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, b.Bytes(), 0644)
+}
+
+// serviceRow holds the widgets updated while the page is shown
+type serviceRow struct {
+	service sessionService
+	status  *gtk.Label
+	log     *gtk.Label
+}
+
+func (m *RavenSettingsMenu) createServicesPage() *gtk.ScrolledWindow {
+	scroll := gtk.NewScrolledWindow()
+	scroll.SetPolicy(gtk.PolicyNever, gtk.PolicyAutomatic)
+
+	content := gtk.NewBox(gtk.OrientationVertical, 16)
+	content.SetMarginStart(20)
+	content.SetMarginEnd(20)
+	content.SetMarginTop(20)
+	content.SetMarginBottom(20)
+
+	sectionTitle := gtk.NewLabel("Services")
+	sectionTitle.AddCSSClass("section-title")
+	sectionTitle.SetHAlign(gtk.AlignStart)
+	content.Append(sectionTitle)
+
+	var rows []*serviceRow
+	for _, service := range sessionServices {
+		box, row := m.createServiceRow(service)
+		rows = append(rows, row)
+		content.Append(box)
+	}
+
+	refresh := func() {
+		for _, row := range rows {
+			updateServiceRow(row)
+		}
+	}
+	refresh()
+
+	// Only polled while the page is on screen
+	glib.TimeoutSecondsAdd(servicesPollSeconds, func() bool {
+		if scroll.Mapped() {
+			refresh()
+		}
+		return true
+	})
+
+	scroll.SetChild(content)
+	return scroll
+}
+
+// createServiceRow builds the status, controls and log of a service
+func (m *RavenSettingsMenu) createServiceRow(service sessionService) (*gtk.Box, *serviceRow) {
+	box := gtk.NewBox(gtk.OrientationVertical, 8)
+	box.AddCSSClass("setting-row")
+
+	header := gtk.NewBox(gtk.OrientationHorizontal, 12)
+	labels := gtk.NewBox(gtk.OrientationVertical, 4)
+	labels.SetHExpand(true)
+	title := gtk.NewLabel(service.Title)
+	title.AddCSSClass("setting-label")
+	title.SetHAlign(gtk.AlignStart)
+	labels.Append(title)
+	desc := gtk.NewLabel(service.Description)
+	desc.AddCSSClass("setting-description")
+	desc.SetHAlign(gtk.AlignStart)
+	labels.Append(desc)
+	header.Append(labels)
+
+	row := &serviceRow{service: service}
+	row.status = gtk.NewLabel("")
+	row.status.AddCSSClass("setting-description")
+	header.Append(row.status)
+
+	installed := service.installed()
+
+	restartBtn := gtk.NewButtonWithLabel("Restart")
+	restartBtn.SetSensitive(installed)
+	restartBtn.SetVAlign(gtk.AlignCenter)
+	restartBtn.ConnectClicked(func() {
+		restartBtn.SetSensitive(false)
+		go func() {
+			err := service.restart()
+			glib.IdleAdd(func() {
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "raven-settings-menu: failed to restart %s: %v\n", service.Name, err)
+				}
+				restartBtn.SetSensitive(true)
+				updateServiceRow(row)
+			})
+		}()
+	})
+	header.Append(restartBtn)
+
+	autostart := gtk.NewSwitch()
+	autostart.SetActive(!slices.Contains(m.settings.DisabledServices, service.Name))
+	autostart.SetSensitive(installed)
+	autostart.SetVAlign(gtk.AlignCenter)
+	autostart.SetTooltipText("Start with the session")
+	autostart.ConnectStateSet(func(state bool) bool {
+		m.setServiceAutostart(service.Name, state)
+		return false
+	})
+	header.Append(autostart)
+	box.Append(header)
+
+	expander := gtk.NewExpander("Recent log")
+	row.log = gtk.NewLabel("")
+	row.log.AddCSSClass("service-log")
+	row.log.SetHAlign(gtk.AlignStart)
+	row.log.SetXAlign(0)
+	row.log.SetSelectable(true)
+	row.log.SetWrap(true)
+	expander.SetChild(row.log)
+	box.Append(expander)
+
+	return box, row
+}
+
+// updateServiceRow shows whether the service is running and its log
+func updateServiceRow(row *serviceRow) {
+	switch pids := row.service.pids(); {
+	case len(pids) > 0:
+		row.status.SetText(fmt.Sprintf("Running (PID %d)", pids[0]))
+	case !row.service.installed():
+		row.status.SetText("Not installed")
+	default:
+		row.status.SetText("Stopped")
+	}
+	row.log.SetText(row.service.recentLog())
+}
+
+// setServiceAutostart saves whether name starts with the session and
+// rewrites the autostart fragment
+func (m *RavenSettingsMenu) setServiceAutostart(name string, enabled bool) {
+	disabled := slices.DeleteFunc(m.settings.DisabledServices, func(s string) bool { return s == name })
+	if !enabled {
+		disabled = append(disabled, name)
+	}
+	m.settings.DisabledServices = disabled
+	m.saveSettings()
+
+	if err := writeAutostart(disabled); err != nil {
+		fmt.Fprintf(os.Stderr, "raven-settings-menu: failed to write %s: %v\n", hyprAutostartPath(), err)
+	}
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"encoding/json"
+
+	"github.com/diamondburned/gotk4/pkg/glib/v2"
+	"github.com/godbus/dbus/v5"
+)
+
+// Where raven-settingsd announces changes to settings.json
+const (
+	settingsdPath  = dbus.ObjectPath("/org/ravenlinux/Settings")
+	settingsdIface = "org.ravenlinux.Settings"
+)
+
+// watchSettingsChanges calls changed on the main loop with the keys of
+// settings.json that raven-settingsd reports changed
+func watchSettingsChanges(changed func(keys []string)) error {
+	conn, err := dbus.ConnectSessionBus()
+	if err != nil {
+		return err
+	}
+	err = conn.AddMatchSignal(
+		dbus.WithMatchObjectPath(settingsdPath),
+		dbus.WithMatchInterface(settingsdIface),
+		dbus.WithMatchMember("Changed"),
+	)
+	if err != nil {
+		return err
+	}
+
+	signals := make(chan *dbus.Signal, 16)
+	conn.Signal(signals)
+	go func() {
+		for sig := range signals {
+			if sig.Name != settingsdIface+".Changed" || len(sig.Body) == 0 {
+				continue
+			}
+			if keys, ok := sig.Body[0].([]string); ok {
+				glib.IdleAdd(func() {
+					changed(keys)
+				})
+			}
+		}
+	}()
+	return nil
+}
+
+// settingsChanged takes in keys another component changed, such as the
+// theme switched by the panel's schedule, so saving doesn't put the old
+// values back. Open pages show the new values once they are reopened.
+func (m *RavenSettingsMenu) settingsChanged(keys []string) {
+	config := m.readSettingsFile()
+	changed := make(map[string]json.RawMessage)
+	for _, key := range keys {
+		if value, ok := config[key]; ok {
+			changed[key] = value
+		}
+	}
+	// Maps would be merged, keeping flags that were turned off
+	if _, ok := changed["feature_flags"]; ok {
+		m.settings.FeatureFlags = nil
+	}
+	if data, err := json.Marshal(changed); err == nil {
+		json.Unmarshal(data, &m.settings)
+	}
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"time"
+)
+
+// How long saveSettings waits for further changes before writing, in
+// milliseconds
+const settingsSaveDelay = 400
+
+var hexColor = regexp.MustCompile(`^#[0-9A-Fa-f]{6}$`)
+
+// readSettingsFile returns every key in settings.json. A file that isn't
+// valid JSON, such as one cut short by a crash, is moved aside and the
+// backup of the last good version is restored in its place.
+func (m *RavenSettingsMenu) readSettingsFile() map[string]json.RawMessage {
+	config := make(map[string]json.RawMessage)
+	data, err := os.ReadFile(m.settingsPath)
+	if err != nil {
+		return config
+	}
+	if err = json.Unmarshal(data, &config); err == nil {
+		return config
+	}
+	fmt.Fprintf(os.Stderr, "raven-settings-menu: invalid %s: %v\n", m.settingsPath, err)
+
+	corrupt := m.settingsPath + ".corrupt"
+	if err := os.Rename(m.settingsPath, corrupt); err == nil {
+		fmt.Fprintf(os.Stderr, "raven-settings-menu: moved it to %s\n", corrupt)
+	}
+
+	backup := m.settingsPath + ".bak"
+	config = make(map[string]json.RawMessage)
+	data, err = os.ReadFile(backup)
+	if err == nil {
+		err = json.Unmarshal(data, &config)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "raven-settings-menu: no usable %s, using defaults\n", backup)
+		return make(map[string]json.RawMessage)
+	}
+	if err := writeFileAtomic(m.settingsPath, data); err != nil {
+		fmt.Fprintf(os.Stderr, "raven-settings-menu: failed to restore %s: %v\n", m.settingsPath, err)
+	} else {
+		fmt.Fprintf(os.Stderr, "raven-settings-menu: restored %s from %s\n", m.settingsPath, backup)
+	}
+	return config
+}
+
+// writeSettingsFile replaces settings.json with config, keeping the version
+// it replaces as settings.json.bak
+func (m *RavenSettingsMenu) writeSettingsFile(config map[string]json.RawMessage) error {
+	data, err := json.MarshalIndent(config, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(m.settingsPath), 0755); err != nil {
+		return err
+	}
+	// Only a good version is worth going back to
+	if old, err := os.ReadFile(m.settingsPath); err == nil && json.Valid(old) {
+		if err := writeFileAtomic(m.settingsPath+".bak", old); err != nil {
+			fmt.Fprintf(os.Stderr, "raven-settings-menu: failed to back up %s: %v\n", m.settingsPath, err)
+		}
+	}
+	return writeFileAtomic(m.settingsPath, data)
+}
+
+// writeFileAtomic writes a temp file next to path and renames it over path,
+// so a crash leaves either the old contents or the new, never half of them
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	// The data must be on disk before the rename is
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return nil
+}
+
+// validate puts back the default of every setting with a value no page
+// would set, such as a font size of 500 edited in by hand, and returns
+// their keys
+func (s *RavenSettings) validate(defaults RavenSettings) []string {
+	var invalid []string
+	oneOf := func(key string, value *string, def string, allowed ...string) {
+		if !slices.Contains(allowed, *value) {
+			*value = def
+			invalid = append(invalid, key)
+		}
+	}
+	intIn := func(key string, value *int, def, min, max int) {
+		if *value < min || *value > max {
+			*value = def
+			invalid = append(invalid, key)
+		}
+	}
+	floatIn := func(key string, value *float64, def, min, max float64) {
+		if !(*value >= min && *value <= max) {
+			*value = def
+			invalid = append(invalid, key)
+		}
+	}
+	clockTime := func(key string, value *string, def string) {
+		if _, err := time.Parse("15:04", *value); err != nil {
+			*value = def
+			invalid = append(invalid, key)
+		}
+	}
+
+	oneOf("theme", &s.Theme, defaults.Theme, "dark", "light", "system")
+	if !hexColor.MatchString(s.AccentColor) {
+		s.AccentColor = defaults.AccentColor
+		invalid = append(invalid, "accent_color")
+	}
+	intIn("font_size", &s.FontSize, defaults.FontSize, 10, 24)
+	floatIn("panel_opacity", &s.PanelOpacity, defaults.PanelOpacity, 0, 1)
+	oneOf("theme_schedule", &s.ThemeSchedule, defaults.ThemeSchedule, "off", "fixed", "sun")
+	clockTime("light_theme_start", &s.LightThemeStart, defaults.LightThemeStart)
+	clockTime("dark_theme_start", &s.DarkThemeStart, defaults.DarkThemeStart)
+	floatIn("latitude", &s.Latitude, defaults.Latitude, -90, 90)
+	floatIn("longitude", &s.Longitude, defaults.Longitude, -180, 180)
+
+	oneOf("wallpaper_mode", &s.WallpaperMode, defaults.WallpaperMode, "fill", "fit", "stretch", "center", "tile")
+
+	oneOf("panel_position", &s.PanelPosition, defaults.PanelPosition, "top", "bottom", "left", "right")
+	intIn("panel_height", &s.PanelHeight, defaults.PanelHeight, 24, 64)
+	oneOf("clock_format", &s.ClockFormat, defaults.ClockFormat, "24h", "12h")
+
+	intIn("border_width", &s.BorderWidth, defaults.BorderWidth, 0, 10)
+	intIn("gap_size", &s.GapSize, defaults.GapSize, 0, 32)
+
+	floatIn("mouse_speed", &s.MouseSpeed, defaults.MouseSpeed, 0, 1)
+
+	// Timeouts of 0 mean never
+	intIn("screen_timeout", &s.ScreenTimeout, defaults.ScreenTimeout, 0, 24*3600)
+	intIn("suspend_timeout", &s.SuspendTimeout, defaults.SuspendTimeout, 0, 24*3600)
+	oneOf("lid_close_action", &s.LidCloseAction, defaults.LidCloseAction, "suspend", "hibernate", "poweroff", "nothing")
+
+	intIn("night_light_temperature", &s.NightLightTemperature, defaults.NightLightTemperature, minNightLightTemperature, maxNightLightTemperature)
+
+	intIn("master_volume", &s.MasterVolume, defaults.MasterVolume, 0, 100)
+	return invalid
+}
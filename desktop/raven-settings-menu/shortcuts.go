@@ -0,0 +1,533 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/diamondburned/gotk4/pkg/gdk/v4"
+	"github.com/diamondburned/gotk4/pkg/glib/v2"
+	"github.com/diamondburned/gotk4/pkg/gtk/v4"
+)
+
+// Seconds a key capture waits for a shortcut before giving up
+const captureTimeoutSeconds = 10
+
+// Submap without binds, entered while capturing so Hyprland doesn't act on
+// the shortcut being recorded
+const captureSubmap = "raven-capture"
+
+// Hyprland modifiers in the order they are written, with their aliases
+var hyprModOrder = []string{"SUPER", "SHIFT", "CTRL", "ALT"}
+
+var hyprModAliases = map[string]string{
+	"SUPER": "SUPER", "WIN": "SUPER", "LOGO": "SUPER", "MOD4": "SUPER",
+	"SHIFT": "SHIFT",
+	"CTRL":  "CTRL", "CONTROL": "CTRL",
+	"ALT": "ALT", "MOD1": "ALT",
+}
+
+// hyprBind is a bind line from hyprland.conf
+type hyprBind struct {
+	Flags      string // Suffix of the keyword: "e" for binde, "l" for bindl...
+	Mods       string // Normalized, e.g. "SUPER SHIFT"
+	Key        string
+	Dispatcher string
+	Args       string
+	Category   string // The comment above the bind
+	Submap     string
+}
+
+// editable reports whether the page can rebind b. Mouse binds and binds
+// inside a submap are only shown.
+func (b hyprBind) editable() bool {
+	return b.Submap == "" && !strings.Contains(b.Flags, "m")
+}
+
+// action describes what the bind does
+func (b hyprBind) action() string {
+	if b.Dispatcher == "exec" {
+		return b.Args
+	}
+	return strings.TrimSpace(b.Dispatcher + " " + b.Args)
+}
+
+// keybindOverride moves a hyprland.conf bind to another key combination.
+// The bind is identified by its original mods, key and action.
+type keybindOverride struct {
+	Mods       string `json:"mods"`
+	Key        string `json:"key"`
+	Dispatcher string `json:"dispatcher"`
+	Args       string `json:"args"`
+	Flags      string `json:"flags,omitempty"`
+	NewMods    string `json:"new_mods"`
+	NewKey     string `json:"new_key"`
+}
+
+func (o keybindOverride) matches(b hyprBind) bool {
+	return o.Mods == b.Mods && strings.EqualFold(o.Key, b.Key) && o.Dispatcher == b.Dispatcher && o.Args == b.Args
+}
+
+// normalizeMods writes Hyprland modifiers in one form, so "$mainMod_SHIFT"
+// (once expanded) and "SHIFT SUPER" compare equal
+func normalizeMods(mods string) string {
+	found := make(map[string]bool)
+	for _, field := range strings.FieldsFunc(strings.ToUpper(mods), func(r rune) bool {
+		return r == ' ' || r == '_' || r == '+'
+	}) {
+		if mod, ok := hyprModAliases[field]; ok {
+			found[mod] = true
+		}
+	}
+	var out []string
+	for _, mod := range hyprModOrder {
+		if found[mod] {
+			out = append(out, mod)
+		}
+	}
+	return strings.Join(out, " ")
+}
+
+// comboID identifies a key combination within a submap
+func comboID(submap, mods, key string) string {
+	return submap + "|" + mods + "|" + strings.ToLower(key)
+}
+
+// formatCombo shows mods and key the way the keybindings overlay does,
+// e.g. "Super + Shift + T"
+func formatCombo(mods, key string) string {
+	var parts []string
+	for _, mod := range strings.Fields(mods) {
+		parts = append(parts, mod[:1]+strings.ToLower(mod[1:]))
+	}
+	if len(key) == 1 {
+		key = strings.ToUpper(key)
+	}
+	return strings.Join(append(parts, key), " + ")
+}
+
+// hyprConfigPath returns the main Hyprland config
+func hyprConfigPath() string {
+	return filepath.Join(filepath.Dir(hyprInputPath()), "hyprland.conf")
+}
+
+// hyprBindsPath returns the Hyprland config fragment holding rebound
+// shortcuts, sourced at the end of hyprland.conf
+func hyprBindsPath() string {
+	return filepath.Join(filepath.Dir(hyprInputPath()), "raven-binds.conf")
+}
+
+// parseHyprBinds reads the binds in hyprland.conf with the comment each
+// one sits under as its category
+func parseHyprBinds(path string) ([]hyprBind, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	vars := make(map[string]string)
+	category, submap := "Other", ""
+	var binds []hyprBind
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		if strings.HasPrefix(line, "#") {
+			if text := strings.TrimSpace(strings.Trim(line, "#=")); text != "" {
+				category = text
+			}
+			continue
+		}
+
+		name, value, ok := strings.Cut(line, "=")
+		if !ok {
+			continue
+		}
+		name, value = strings.TrimSpace(name), strings.TrimSpace(value)
+
+		switch {
+		case strings.HasPrefix(name, "$"):
+			vars[name] = value
+		case name == "submap":
+			submap = value
+			if value == "reset" {
+				submap = ""
+			}
+		case strings.HasPrefix(name, "bind"):
+			for v, expansion := range vars {
+				value = strings.ReplaceAll(value, v, expansion)
+			}
+			fields := strings.SplitN(value, ",", 4)
+			if len(fields) < 3 {
+				continue
+			}
+			bind := hyprBind{
+				Flags:      strings.TrimPrefix(name, "bind"),
+				Mods:       normalizeMods(fields[0]),
+				Key:        strings.TrimSpace(fields[1]),
+				Dispatcher: strings.TrimSpace(fields[2]),
+				Category:   category,
+				Submap:     submap,
+			}
+			if len(fields) == 4 {
+				bind.Args = strings.TrimSpace(fields[3])
+			}
+			binds = append(binds, bind)
+		}
+	}
+	return binds, scanner.Err()
+}
+
+// writeBindOverrides writes the unbind/bind pairs for overrides. An unbind
+// drops every bind on its combination, so binds of hyprland.conf that
+// shared one with a moved bind are bound again.
+func writeBindOverrides(binds []hyprBind, overrides []keybindOverride) error {
+	var b bytes.Buffer
+	b.WriteString("# Written by raven-settings-menu (Shortcuts)\n")
+	unbound := make(map[string]bool)
+	for _, o := range overrides {
+		fmt.Fprintf(&b, "unbind = %s, %s\n", o.Mods, o.Key)
+		unbound[comboID("", o.Mods, o.Key)] = true
+	}
+	for _, o := range overrides {
+		fmt.Fprintf(&b, "bind%s = %s, %s, %s, %s\n", o.Flags, o.NewMods, o.NewKey, o.Dispatcher, o.Args)
+	}
+	for _, bind := range binds {
+		if bind.Submap != "" || !unbound[comboID("", bind.Mods, bind.Key)] {
+			continue
+		}
+		if slices.ContainsFunc(overrides, func(o keybindOverride) bool { return o.matches(bind) }) {
+			continue
+		}
+		fmt.Fprintf(&b, "bind%s = %s, %s, %s, %s\n", bind.Flags, bind.Mods, bind.Key, bind.Dispatcher, bind.Args)
+	}
+
+	path := hyprBindsPath()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, b.Bytes(), 0644)
+}
+
+// rebindLive moves a bind in the running Hyprland from one combination to
+// another, binding keep again since the unbind drops them too. Actions
+// containing ";" can't go through --batch and wait for the next login.
+func rebindLive(b hyprBind, fromMods, fromKey, toMods, toKey string, keep []hyprBind) {
+	if os.Getenv("HYPRLAND_INSTANCE_SIGNATURE") == "" || strings.Contains(b.Args, ";") {
+		return
+	}
+	keywords := []string{
+		fmt.Sprintf("keyword unbind %s,%s", fromMods, fromKey),
+		fmt.Sprintf("keyword bind%s %s,%s,%s,%s", b.Flags, toMods, toKey, b.Dispatcher, b.Args),
+	}
+	for _, k := range keep {
+		keywords = append(keywords, fmt.Sprintf("keyword bind%s %s,%s,%s,%s", k.Flags, fromMods, fromKey, k.Dispatcher, k.Args))
+	}
+	batch := strings.Join(keywords, " ; ")
+	if output, err := exec.Command("hyprctl", "--batch", batch).CombinedOutput(); err != nil {
+		fmt.Fprintf(os.Stderr, "raven-settings-menu: hyprctl failed: %v: %s\n", err, strings.TrimSpace(string(output)))
+	}
+}
+
+// setCaptureSubmap enters or leaves the empty capture submap
+func setCaptureSubmap(on bool) {
+	if os.Getenv("HYPRLAND_INSTANCE_SIGNATURE") == "" {
+		return
+	}
+	submap := "reset"
+	if on {
+		submap = captureSubmap
+	}
+	exec.Command("hyprctl", "dispatch", "submap", submap).Run()
+}
+
+// capturedCombo turns a key press into Hyprland mods and key. The key is
+// read without Shift applied, so Shift+1 is "SHIFT, 1" and not "exclam".
+func capturedCombo(keyval, keycode uint, state gdk.ModifierType) (string, string) {
+	if keys, keyvals, ok := gdk.DisplayGetDefault().MapKeycode(keycode); ok {
+		for i, key := range keys {
+			if key.Group() == 0 && key.Level() == 0 && i < len(keyvals) {
+				keyval = keyvals[i]
+				break
+			}
+		}
+	}
+
+	var mods []string
+	if state&gdk.SuperMask != 0 {
+		mods = append(mods, "SUPER")
+	}
+	if state&gdk.ShiftMask != 0 {
+		mods = append(mods, "SHIFT")
+	}
+	if state&gdk.ControlMask != 0 {
+		mods = append(mods, "CTRL")
+	}
+	if state&gdk.AltMask != 0 {
+		mods = append(mods, "ALT")
+	}
+
+	key := gdk.KeyvalName(gdk.KeyvalToLower(keyval))
+	if len(key) == 1 {
+		key = strings.ToUpper(key)
+	}
+	return strings.Join(mods, " "), key
+}
+
+// isModifierKey reports whether keyval is a modifier pressed on its own
+func isModifierKey(keyval uint) bool {
+	switch keyval {
+	case gdk.KEY_Shift_L, gdk.KEY_Shift_R, gdk.KEY_Control_L, gdk.KEY_Control_R,
+		gdk.KEY_Alt_L, gdk.KEY_Alt_R, gdk.KEY_Super_L, gdk.KEY_Super_R,
+		gdk.KEY_Meta_L, gdk.KEY_Meta_R, gdk.KEY_ISO_Level3_Shift, gdk.KEY_Caps_Lock:
+		return true
+	}
+	return false
+}
+
+// shortcutRow is one bind on the Shortcuts page
+type shortcutRow struct {
+	bind   hyprBind
+	mods   string // Current combination, after overrides
+	key    string
+	button *gtk.Button
+	reset  *gtk.Button
+}
+
+// shortcutsPage holds the Shortcuts page state
+type shortcutsPage struct {
+	binds     []hyprBind // As written in hyprland.conf
+	rows      []*shortcutRow
+	status    *gtk.Label
+	capturing *shortcutRow
+	timeout   glib.SourceHandle
+}
+
+func (m *RavenSettingsMenu) createShortcutsPage() *gtk.ScrolledWindow {
+	scroll := gtk.NewScrolledWindow()
+	scroll.SetPolicy(gtk.PolicyNever, gtk.PolicyAutomatic)
+
+	content := gtk.NewBox(gtk.OrientationVertical, 16)
+	content.SetMarginStart(20)
+	content.SetMarginEnd(20)
+	content.SetMarginTop(20)
+	content.SetMarginBottom(20)
+
+	sectionTitle := gtk.NewLabel("Shortcuts")
+	sectionTitle.AddCSSClass("section-title")
+	sectionTitle.SetHAlign(gtk.AlignStart)
+	content.Append(sectionTitle)
+
+	page := &shortcutsPage{}
+	page.status = gtk.NewLabel("Click a shortcut, then press the new key combination. Escape cancels.")
+	page.status.AddCSSClass("setting-description")
+	page.status.SetHAlign(gtk.AlignStart)
+	page.status.SetWrap(true)
+	content.Append(page.status)
+
+	binds, err := parseHyprBinds(hyprConfigPath())
+	page.binds = binds
+	if err != nil {
+		page.status.SetText("Could not read " + hyprConfigPath() + ": " + err.Error())
+		scroll.SetChild(content)
+		return scroll
+	}
+
+	category := ""
+	for _, bind := range binds {
+		if bind.Category != category {
+			category = bind.Category
+			title := gtk.NewLabel(category)
+			title.AddCSSClass("setting-label")
+			title.SetHAlign(gtk.AlignStart)
+			title.SetMarginTop(8)
+			content.Append(title)
+		}
+		row := m.newShortcutRow(page, bind)
+		page.rows = append(page.rows, row)
+
+		control := gtk.NewBox(gtk.OrientationHorizontal, 8)
+		control.Append(row.button)
+		control.Append(row.reset)
+		description := ""
+		if bind.Submap != "" {
+			description = "In the " + bind.Submap + " submap"
+		}
+		content.Append(m.createSettingRow(bind.action(), description, control))
+	}
+	page.markConflicts()
+
+	// Never leave Hyprland in the capture submap
+	scroll.ConnectUnmap(func() {
+		page.stopCapture("")
+	})
+
+	scroll.SetChild(content)
+	return scroll
+}
+
+// newShortcutRow builds the key capture button and reset button of a bind
+func (m *RavenSettingsMenu) newShortcutRow(page *shortcutsPage, bind hyprBind) *shortcutRow {
+	row := &shortcutRow{bind: bind, mods: bind.Mods, key: bind.Key}
+	for _, o := range m.settings.KeybindOverrides {
+		if o.matches(bind) {
+			row.mods, row.key = o.NewMods, o.NewKey
+		}
+	}
+
+	row.button = gtk.NewButtonWithLabel(formatCombo(row.mods, row.key))
+	row.button.AddCSSClass("shortcut-keys")
+	row.button.SetVAlign(gtk.AlignCenter)
+	row.button.SetSensitive(bind.editable())
+	row.button.ConnectClicked(func() {
+		page.startCapture(row)
+	})
+
+	keys := gtk.NewEventControllerKey()
+	keys.ConnectKeyPressed(func(keyval, keycode uint, state gdk.ModifierType) bool {
+		if page.capturing != row {
+			return false
+		}
+		if keyval == gdk.KEY_Escape {
+			page.stopCapture("Cancelled")
+			return true
+		}
+		if isModifierKey(keyval) {
+			return true
+		}
+		mods, key := capturedCombo(keyval, keycode, state)
+		m.rebind(page, row, mods, key)
+		return true
+	})
+	row.button.AddController(keys)
+
+	row.reset = gtk.NewButtonFromIconName("edit-undo-symbolic")
+	row.reset.SetTooltipText("Restore " + formatCombo(bind.Mods, bind.Key))
+	row.reset.SetVAlign(gtk.AlignCenter)
+	row.reset.SetVisible(row.mods != bind.Mods || !strings.EqualFold(row.key, bind.Key))
+	row.reset.ConnectClicked(func() {
+		m.rebind(page, row, bind.Mods, bind.Key)
+	})
+
+	return row
+}
+
+// startCapture waits for a key combination for row
+func (p *shortcutsPage) startCapture(row *shortcutRow) {
+	if p.capturing != nil {
+		p.stopCapture("")
+	}
+	p.capturing = row
+	row.button.SetLabel("Press a shortcut…")
+	row.button.GrabFocus()
+	setCaptureSubmap(true)
+
+	p.timeout = glib.TimeoutSecondsAdd(captureTimeoutSeconds, func() bool {
+		p.timeout = 0
+		p.stopCapture("No shortcut pressed")
+		return false
+	})
+}
+
+// stopCapture ends a capture, showing message when it isn't empty
+func (p *shortcutsPage) stopCapture(message string) {
+	row := p.capturing
+	if row == nil {
+		return
+	}
+	p.capturing = nil
+	if p.timeout != 0 {
+		glib.SourceRemove(p.timeout)
+		p.timeout = 0
+	}
+	setCaptureSubmap(false)
+
+	row.button.SetLabel(formatCombo(row.mods, row.key))
+	if message != "" {
+		p.status.SetText(message)
+	}
+}
+
+// conflict returns the row other than row that uses mods and key
+func (p *shortcutsPage) conflict(row *shortcutRow, mods, key string) *shortcutRow {
+	id := comboID(row.bind.Submap, mods, key)
+	for _, other := range p.rows {
+		if other != row && comboID(other.bind.Submap, other.mods, other.key) == id {
+			return other
+		}
+	}
+	return nil
+}
+
+// markConflicts highlights binds that share their combination with another
+func (p *shortcutsPage) markConflicts() {
+	for _, row := range p.rows {
+		if other := p.conflict(row, row.mods, row.key); other != nil {
+			row.button.AddCSSClass("shortcut-conflict")
+			row.button.SetTooltipText("Also bound to " + other.bind.action())
+		} else {
+			row.button.RemoveCSSClass("shortcut-conflict")
+			row.button.SetTooltipText("")
+		}
+	}
+}
+
+// rebind moves row to mods and key, refusing combinations already in use,
+// then saves the overrides and applies them to the running Hyprland
+func (m *RavenSettingsMenu) rebind(page *shortcutsPage, row *shortcutRow, mods, key string) {
+	combo := formatCombo(mods, key)
+	if other := page.conflict(row, mods, key); other != nil {
+		page.stopCapture(combo + " is already used by " + other.bind.action())
+		return
+	}
+
+	fromMods, fromKey := row.mods, row.key
+	row.mods, row.key = mods, key
+	page.stopCapture("")
+	if fromMods == mods && strings.EqualFold(fromKey, key) {
+		return
+	}
+
+	bind := row.bind
+	overrides := slices.DeleteFunc(m.settings.KeybindOverrides, func(o keybindOverride) bool {
+		return o.matches(bind)
+	})
+	restored := mods == bind.Mods && strings.EqualFold(key, bind.Key)
+	if !restored {
+		overrides = append(overrides, keybindOverride{
+			Mods:       bind.Mods,
+			Key:        bind.Key,
+			Dispatcher: bind.Dispatcher,
+			Args:       bind.Args,
+			Flags:      bind.Flags,
+			NewMods:    mods,
+			NewKey:     key,
+		})
+	}
+	m.settings.KeybindOverrides = overrides
+	m.saveSettings()
+
+	if err := writeBindOverrides(page.binds, overrides); err != nil {
+		fmt.Fprintf(os.Stderr, "raven-settings-menu: failed to write %s: %v\n", hyprBindsPath(), err)
+	}
+	var keep []hyprBind
+	for _, other := range page.rows {
+		if other != row && comboID(other.bind.Submap, other.mods, other.key) == comboID(bind.Submap, fromMods, fromKey) {
+			keep = append(keep, other.bind)
+		}
+	}
+	rebindLive(bind, fromMods, fromKey, mods, key, keep)
+
+	row.reset.SetVisible(!restored)
+	page.markConflicts()
+	page.status.SetText(bind.action() + " is now " + combo)
+}
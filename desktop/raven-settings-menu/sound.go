@@ -0,0 +1,390 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"os/exec"
+	"strconv"
+
+	"github.com/diamondburned/gotk4/pkg/glib/v2"
+	"github.com/diamondburned/gotk4/pkg/gtk/v4"
+)
+
+// How often the Sound page rereads PipeWire while it is shown
+const soundPollSeconds = 2
+
+// soundPage holds the device and application lists of the Sound page
+type soundPage struct {
+	m *RavenSettingsMenu
+
+	message    *gtk.Label
+	outputs    *gtk.Box
+	inputs     *gtk.Box
+	apps       *gtk.Box
+	outputsKey string // What each list was built from
+	inputsKey  string
+	appsKey    string
+	scales     map[int]*gtk.Scale        // Volume slider of each node, by ID
+	mutes      map[int]*gtk.ToggleButton // Mute button of each node
+	updating   bool                      // Controls are being set from PipeWire
+
+	level       *gtk.LevelBar
+	meter       *levelMeter
+	meterSource string // Source the meter records from
+	peak        float64
+}
+
+func (m *RavenSettingsMenu) createSoundPage() *gtk.ScrolledWindow {
+	scroll := gtk.NewScrolledWindow()
+	scroll.SetPolicy(gtk.PolicyNever, gtk.PolicyAutomatic)
+
+	content := gtk.NewBox(gtk.OrientationVertical, 16)
+	content.SetMarginStart(20)
+	content.SetMarginEnd(20)
+	content.SetMarginTop(20)
+	content.SetMarginBottom(20)
+
+	sectionTitle := gtk.NewLabel("Sound")
+	sectionTitle.AddCSSClass("section-title")
+	sectionTitle.SetHAlign(gtk.AlignStart)
+	content.Append(sectionTitle)
+
+	// Master volume
+	volumeAdj := gtk.NewAdjustment(float64(m.settings.MasterVolume), 0, 100, 5, 10, 0)
+	volumeScale := gtk.NewScale(gtk.OrientationHorizontal, volumeAdj)
+	volumeScale.SetSizeRequest(200, -1)
+	volumeScale.SetDrawValue(true)
+	volumeScale.ConnectValueChanged(func() {
+		m.settings.MasterVolume = int(volumeScale.Value())
+		m.saveSettings()
+		// Apply volume immediately
+		volume := strconv.Itoa(m.settings.MasterVolume)
+		exec.Command("wpctl", "set-volume", "@DEFAULT_AUDIO_SINK@", volume+"%").Start()
+	})
+	content.Append(m.createSettingRow("Master Volume", "System audio volume", volumeScale))
+
+	// Mute on lock
+	muteSwitch := gtk.NewSwitch()
+	muteSwitch.SetActive(m.settings.MuteOnLock)
+	muteSwitch.ConnectStateSet(func(state bool) bool {
+		m.settings.MuteOnLock = state
+		m.saveSettings()
+		return false
+	})
+	content.Append(m.createSettingRow("Mute on Lock", "Mute audio when screen is locked", muteSwitch))
+
+	// Audio output test button
+	testBtn := gtk.NewButton()
+	testBtn.SetLabel("Test Audio")
+	testBtn.ConnectClicked(func() {
+		exec.Command("paplay", "/usr/share/sounds/freedesktop/stereo/bell.oga").Start()
+	})
+	content.Append(m.createSettingRow("Test Audio Output", "Play a test sound", testBtn))
+
+	scroll.SetChild(content)
+
+	if _, err := exec.LookPath("pw-dump"); err != nil {
+		label := gtk.NewLabel("Devices and applications need PipeWire (pw-dump and wpctl)")
+		label.AddCSSClass("setting-description")
+		label.SetHAlign(gtk.AlignStart)
+		content.Append(label)
+		return scroll
+	}
+
+	p := &soundPage{m: m}
+
+	p.message = gtk.NewLabel("")
+	p.message.AddCSSClass("setting-description")
+	p.message.SetHAlign(gtk.AlignStart)
+	p.message.SetWrap(true)
+	p.message.SetVisible(false)
+	content.Append(p.message)
+
+	p.outputs = p.addSection(content, "Output Devices")
+	p.inputs = p.addSection(content, "Input Devices")
+
+	p.level = gtk.NewLevelBar()
+	p.level.SetSizeRequest(200, -1)
+	p.level.SetVAlign(gtk.AlignCenter)
+	content.Append(m.createSettingRow("Input Level", "Speak to test the default input", p.level))
+
+	p.apps = p.addSection(content, "Applications")
+
+	p.refresh()
+
+	// Only polled, and the microphone only recorded, while the page is
+	// on screen
+	glib.TimeoutSecondsAdd(soundPollSeconds, func() bool {
+		if scroll.Mapped() {
+			p.refresh()
+		}
+		return true
+	})
+	scroll.ConnectMap(p.refresh)
+	scroll.ConnectUnmap(p.stopMeter)
+
+	return scroll
+}
+
+func (p *soundPage) addSection(content *gtk.Box, title string) *gtk.Box {
+	label := gtk.NewLabel(title)
+	label.AddCSSClass("section-title")
+	label.SetHAlign(gtk.AlignStart)
+	content.Append(label)
+
+	box := gtk.NewBox(gtk.OrientationVertical, 0)
+	content.Append(box)
+	return box
+}
+
+// refresh rereads PipeWire off the main loop
+func (p *soundPage) refresh() {
+	go func() {
+		state, err := loadAudio()
+		glib.IdleAdd(func() {
+			p.render(state, err)
+		})
+	}()
+}
+
+func (p *soundPage) render(state audioState, err error) {
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "raven-settings-menu: sound: %v\n", err)
+		p.message.SetText(err.Error())
+		p.message.SetVisible(true)
+		return
+	}
+	p.message.SetVisible(false)
+
+	// Lists are only rebuilt when devices or applications come or go;
+	// otherwise their sliders are moved to the current volumes
+	if key := nodesKey(state.Sinks); key != p.outputsKey {
+		p.outputsKey = key
+		p.renderDevices(p.outputs, state.Sinks, "No output devices")
+	}
+	if key := nodesKey(state.Sources); key != p.inputsKey {
+		p.inputsKey = key
+		p.renderDevices(p.inputs, state.Sources, "No input devices")
+	}
+	if key := nodesKey(state.Streams); key != p.appsKey {
+		p.appsKey = key
+		p.renderApps(state.Streams)
+	}
+	p.updateControls(state)
+
+	source := ""
+	for _, node := range state.Sources {
+		if node.Default {
+			source = node.Name
+		}
+	}
+	if source != p.meterSource && p.level.Mapped() {
+		p.startMeter(source)
+	}
+}
+
+// nodesKey identifies a list of nodes by what its rows show besides volume
+func nodesKey(nodes []audioNode) string {
+	key := ""
+	for _, node := range nodes {
+		key += fmt.Sprintf("%d %s %s %t\n", node.ID, node.Label, node.Icon, node.Default)
+	}
+	return key
+}
+
+// renderDevices lists sinks or sources, each with a Default button, a
+// volume slider and a mute button
+func (p *soundPage) renderDevices(box *gtk.Box, nodes []audioNode, empty string) {
+	clearBox(box)
+	if len(nodes) == 0 {
+		box.Append(emptyLabel(empty))
+		return
+	}
+
+	var group *gtk.CheckButton
+	for _, node := range nodes {
+		controls := gtk.NewBox(gtk.OrientationHorizontal, 8)
+
+		defaultBtn := gtk.NewCheckButtonWithLabel("Default")
+		defaultBtn.SetVAlign(gtk.AlignCenter)
+		if group == nil {
+			group = defaultBtn
+		} else {
+			defaultBtn.SetGroup(group)
+		}
+		defaultBtn.SetActive(node.Default)
+		defaultBtn.ConnectToggled(func() {
+			if p.updating || !defaultBtn.Active() {
+				return
+			}
+			p.do(func() error {
+				return setDefaultNode(node.ID)
+			})
+		})
+		controls.Append(defaultBtn)
+
+		controls.Append(p.volumeControls(node))
+
+		description := ""
+		if node.Default {
+			description = "Default"
+		}
+		box.Append(p.m.createSettingRow(node.Label, description, controls))
+	}
+}
+
+// renderApps lists the applications playing audio
+func (p *soundPage) renderApps(nodes []audioNode) {
+	clearBox(p.apps)
+	if len(nodes) == 0 {
+		p.apps.Append(emptyLabel("No applications are playing audio"))
+		return
+	}
+
+	for _, node := range nodes {
+		row := p.m.createSettingRow(node.Label, "", p.volumeControls(node))
+		if node.Icon != "" {
+			image := gtk.NewImageFromIconName(node.Icon)
+			image.SetPixelSize(24)
+			row.Prepend(image)
+		}
+		p.apps.Append(row)
+	}
+}
+
+// volumeControls builds the volume slider and mute button of a node
+func (p *soundPage) volumeControls(node audioNode) *gtk.Box {
+	box := gtk.NewBox(gtk.OrientationHorizontal, 8)
+
+	scale := gtk.NewScaleWithRange(gtk.OrientationHorizontal, 0, 100, 5)
+	scale.SetSizeRequest(160, -1)
+	scale.SetDrawValue(true)
+	scale.SetVAlign(gtk.AlignCenter)
+	scale.SetValue(math.Round(node.Volume * 100))
+	scale.ConnectValueChanged(func() {
+		if p.updating {
+			return
+		}
+		volume := scale.Value() / 100
+		go func() {
+			if err := setNodeVolume(node.ID, volume); err != nil {
+				fmt.Fprintf(os.Stderr, "raven-settings-menu: sound: %v\n", err)
+			}
+		}()
+	})
+	box.Append(scale)
+
+	mute := gtk.NewToggleButton()
+	mute.SetIconName("audio-volume-muted-symbolic")
+	mute.SetTooltipText("Mute")
+	mute.SetVAlign(gtk.AlignCenter)
+	mute.SetActive(node.Muted)
+	mute.ConnectToggled(func() {
+		if p.updating {
+			return
+		}
+		muted := mute.Active()
+		p.do(func() error {
+			return setNodeMuted(node.ID, muted)
+		})
+	})
+	box.Append(mute)
+
+	if p.scales == nil {
+		p.scales = make(map[int]*gtk.Scale)
+		p.mutes = make(map[int]*gtk.ToggleButton)
+	}
+	p.scales[node.ID] = scale
+	p.mutes[node.ID] = mute
+	return box
+}
+
+// updateControls moves sliders and mute buttons to the volumes PipeWire
+// reports, which other programs may have changed
+func (p *soundPage) updateControls(state audioState) {
+	p.updating = true
+	defer func() { p.updating = false }()
+
+	seen := make(map[int]bool)
+	for _, nodes := range [][]audioNode{state.Sinks, state.Sources, state.Streams} {
+		for _, node := range nodes {
+			seen[node.ID] = true
+			if scale, ok := p.scales[node.ID]; ok {
+				// Leave the slider alone while it is being dragged
+				if !scale.HasFocus() {
+					scale.SetValue(math.Round(node.Volume * 100))
+				}
+			}
+			if mute, ok := p.mutes[node.ID]; ok {
+				mute.SetActive(node.Muted)
+			}
+		}
+	}
+	for id := range p.scales {
+		if !seen[id] {
+			delete(p.scales, id)
+			delete(p.mutes, id)
+		}
+	}
+}
+
+// do runs a wpctl command off the main loop, then rereads PipeWire
+func (p *soundPage) do(request func() error) {
+	go func() {
+		err := request()
+		glib.IdleAdd(func() {
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "raven-settings-menu: sound: %v\n", err)
+				p.message.SetText(err.Error())
+				p.message.SetVisible(true)
+			}
+			p.refresh()
+		})
+	}()
+}
+
+// startMeter records the default input for the level meter, replacing any
+// recording of another source
+func (p *soundPage) startMeter(source string) {
+	p.stopMeter()
+	p.meterSource = source
+	if source == "" {
+		return
+	}
+
+	meter, err := startLevelMeter(source, func(level float64) {
+		glib.IdleAdd(func() {
+			// Fall back slowly so peaks stay visible
+			p.peak = math.Max(level, p.peak*0.8)
+			p.level.SetValue(p.peak)
+		})
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "raven-settings-menu: sound: %v\n", err)
+		return
+	}
+	p.meter = meter
+}
+
+func (p *soundPage) stopMeter() {
+	p.meter.stop()
+	p.meter = nil
+	p.meterSource = ""
+	p.peak = 0
+	p.level.SetValue(0)
+}
+
+func clearBox(box *gtk.Box) {
+	for child := box.FirstChild(); child != nil; child = box.FirstChild() {
+		box.Remove(child)
+	}
+}
+
+func emptyLabel(text string) *gtk.Label {
+	label := gtk.NewLabel(text)
+	label.AddCSSClass("setting-description")
+	label.SetHAlign(gtk.AlignStart)
+	label.SetMarginBottom(8)
+	return label
+}
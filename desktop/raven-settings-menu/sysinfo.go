@@ -0,0 +1,371 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Log lines of each component included in a diagnostic report
+const diagnosticLogLines = 50
+
+// Settings left out of diagnostic reports, since they say where the user is
+var privateSettings = []string{"latitude", "longitude"}
+
+// systemInfo is the hardware summary on the About page
+type systemInfo struct {
+	OS        string
+	Kernel    string
+	CPU       string
+	Memory    string
+	GPUs      []string
+	Disks     []string
+	Batteries []string
+	Uptime    string
+}
+
+// readSystemInfo collects the hardware summary. lspci is run for GPU
+// names, so it is called off the main loop.
+func readSystemInfo() systemInfo {
+	kernel, _ := exec.Command("uname", "-r").Output()
+	return systemInfo{
+		OS:        osName(),
+		Kernel:    strings.TrimSpace(string(kernel)),
+		CPU:       cpuModel(),
+		Memory:    memoryInfo(),
+		GPUs:      gpuList(),
+		Disks:     diskUsage(),
+		Batteries: batteryHealth(),
+		Uptime:    uptime(),
+	}
+}
+
+// osName returns PRETTY_NAME from os-release
+func osName() string {
+	data, err := os.ReadFile("/etc/os-release")
+	if err != nil {
+		return "Raven Linux"
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if value, ok := strings.CutPrefix(line, "PRETTY_NAME="); ok {
+			return strings.Trim(value, `"`)
+		}
+	}
+	return "Raven Linux"
+}
+
+// cpuModel returns the processor's name and thread count
+func cpuModel() string {
+	file, err := os.Open("/proc/cpuinfo")
+	if err != nil {
+		return "Unknown"
+	}
+	defer file.Close()
+
+	model := ""
+	threads := 0
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(scanner.Text(), ":")
+		if !ok {
+			continue
+		}
+		switch strings.TrimSpace(key) {
+		case "processor":
+			threads++
+		case "model name", "Model":
+			if model == "" {
+				model = strings.Join(strings.Fields(value), " ")
+			}
+		}
+	}
+	if model == "" {
+		model = "Unknown"
+	}
+	if threads > 1 {
+		return fmt.Sprintf("%s × %d", model, threads)
+	}
+	return model
+}
+
+// memoryInfo returns the installed RAM and how much of it is free
+func memoryInfo() string {
+	data, err := os.ReadFile("/proc/meminfo")
+	if err != nil {
+		return "Unknown"
+	}
+	fields := make(map[string]uint64)
+	for _, line := range strings.Split(string(data), "\n") {
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		// "MemTotal:       16123456 kB"
+		if kb, err := strconv.ParseUint(strings.TrimSuffix(strings.TrimSpace(value), " kB"), 10, 64); err == nil {
+			fields[key] = kb * 1024
+		}
+	}
+	return fmt.Sprintf("%s (%s available)", formatBytes(fields["MemTotal"]), formatBytes(fields["MemAvailable"]))
+}
+
+// gpuList returns each graphics card's name and kernel driver
+func gpuList() []string {
+	cards, _ := filepath.Glob("/sys/class/drm/card[0-9]*")
+	var gpus []string
+	for _, card := range cards {
+		// card0-DP-1 and the like are the card's outputs
+		if strings.Contains(filepath.Base(card), "-") {
+			continue
+		}
+		device := filepath.Join(card, "device")
+		name := ""
+		if path, err := filepath.EvalSymlinks(device); err == nil {
+			name = pciName(filepath.Base(path))
+		}
+		if name == "" {
+			vendor, _ := os.ReadFile(filepath.Join(device, "vendor"))
+			name = pciVendors[strings.TrimSpace(string(vendor))]
+		}
+		if name == "" {
+			name = "Unknown GPU"
+		}
+		if driver, err := os.Readlink(filepath.Join(device, "driver")); err == nil {
+			name += " (" + filepath.Base(driver) + ")"
+		}
+		gpus = append(gpus, name)
+	}
+	return gpus
+}
+
+// Names of GPU vendors, for when lspci isn't installed
+var pciVendors = map[string]string{
+	"0x8086": "Intel",
+	"0x1002": "AMD",
+	"0x10de": "NVIDIA",
+	"0x1af4": "Virtio",
+	"0x15ad": "VMware",
+	"0x1234": "QEMU",
+}
+
+// pciName returns lspci's name for the device in slot, e.g. 0000:00:02.0
+func pciName(slot string) string {
+	out, err := exec.Command("lspci", "-s", slot).Output()
+	if err != nil {
+		return ""
+	}
+	// 00:02.0 VGA compatible controller: Intel Corporation UHD Graphics 620 (rev 07)
+	_, name, ok := strings.Cut(strings.TrimSpace(string(out)), ": ")
+	if !ok {
+		return ""
+	}
+	if i := strings.LastIndex(name, " (rev "); i >= 0 {
+		name = name[:i]
+	}
+	return name
+}
+
+// diskUsage returns how full the root and home file systems are
+func diskUsage() []string {
+	var disks []string
+	var seen []uint64
+	for _, mount := range []string{"/", "/home"} {
+		var st syscall.Stat_t
+		if err := syscall.Stat(mount, &st); err != nil || slices.Contains(seen, uint64(st.Dev)) {
+			continue
+		}
+		seen = append(seen, uint64(st.Dev))
+
+		var fs syscall.Statfs_t
+		if err := syscall.Statfs(mount, &fs); err != nil {
+			continue
+		}
+		total := fs.Blocks * uint64(fs.Bsize)
+		free := fs.Bavail * uint64(fs.Bsize)
+		disks = append(disks, fmt.Sprintf("%s: %s of %s used", mount, formatBytes(total-free), formatBytes(total)))
+	}
+	return disks
+}
+
+// batteryHealth returns how much of its design capacity each laptop
+// battery still holds. Batteries of mice and the like are left out.
+func batteryHealth() []string {
+	supplies, _ := filepath.Glob("/sys/class/power_supply/*")
+	var batteries []string
+	for _, supply := range supplies {
+		if readSysfs(supply, "type") != "Battery" || readSysfs(supply, "scope") == "Device" {
+			continue
+		}
+		info := filepath.Base(supply) + ": "
+		full, design := readSysfsInt(supply, "energy_full"), readSysfsInt(supply, "energy_full_design")
+		if design == 0 {
+			full, design = readSysfsInt(supply, "charge_full"), readSysfsInt(supply, "charge_full_design")
+		}
+		if design > 0 {
+			info += fmt.Sprintf("%d%% of design capacity", full*100/design)
+		} else {
+			info += "capacity unknown"
+		}
+		if cycles := readSysfsInt(supply, "cycle_count"); cycles > 0 {
+			info += fmt.Sprintf(", %d charge cycles", cycles)
+		}
+		batteries = append(batteries, info)
+	}
+	return batteries
+}
+
+func readSysfs(dir, name string) string {
+	data, _ := os.ReadFile(filepath.Join(dir, name))
+	return strings.TrimSpace(string(data))
+}
+
+func readSysfsInt(dir, name string) int {
+	n, _ := strconv.Atoi(readSysfs(dir, name))
+	return n
+}
+
+// uptime returns how long the system has been running
+func uptime() string {
+	data, err := os.ReadFile("/proc/uptime")
+	if err != nil {
+		return "Unknown"
+	}
+	fields := strings.Fields(string(data))
+	if len(fields) == 0 {
+		return "Unknown"
+	}
+	seconds, err := strconv.ParseFloat(fields[0], 64)
+	if err != nil {
+		return "Unknown"
+	}
+	return formatUptime(time.Duration(seconds) * time.Second)
+}
+
+func formatUptime(d time.Duration) string {
+	days := int(d.Hours()) / 24
+	hours := int(d.Hours()) % 24
+	minutes := int(d.Minutes()) % 60
+	switch {
+	case days > 0:
+		return plural(days, "day") + ", " + plural(hours, "hour")
+	case hours > 0:
+		return plural(hours, "hour") + ", " + plural(minutes, "minute")
+	}
+	return plural(minutes, "minute")
+}
+
+func plural(n int, unit string) string {
+	if n == 1 {
+		return "1 " + unit
+	}
+	return fmt.Sprintf("%d %ss", n, unit)
+}
+
+func formatBytes(bytes uint64) string {
+	const unit = 1024
+	if bytes < unit {
+		return fmt.Sprintf("%d B", bytes)
+	}
+	div, exp := uint64(unit), 0
+	for n := bytes / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(bytes)/float64(div), "KMGTPE"[exp])
+}
+
+// diagnosticReport collects the hardware summary, the state and recent
+// logs of the session services, the Hyprland log and settings.json (config)
+// into text to attach to a bug report. The hostname and location are left
+// out.
+func diagnosticReport(info systemInfo, config map[string]json.RawMessage, disabled []string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Raven Linux diagnostic report\n")
+	fmt.Fprintf(&b, "Generated: %s\n", time.Now().Format("2006-01-02 15:04:05 -0700"))
+
+	section := func(title string) {
+		fmt.Fprintf(&b, "\n== %s ==\n", title)
+	}
+
+	section("System")
+	fmt.Fprintf(&b, "OS: %s\n", info.OS)
+	fmt.Fprintf(&b, "Kernel: %s\n", info.Kernel)
+	fmt.Fprintf(&b, "CPU: %s\n", info.CPU)
+	fmt.Fprintf(&b, "Memory: %s\n", info.Memory)
+	for _, gpu := range info.GPUs {
+		fmt.Fprintf(&b, "GPU: %s\n", gpu)
+	}
+	for _, disk := range info.Disks {
+		fmt.Fprintf(&b, "Disk: %s\n", disk)
+	}
+	for _, battery := range info.Batteries {
+		fmt.Fprintf(&b, "Battery: %s\n", battery)
+	}
+	fmt.Fprintf(&b, "Uptime: %s\n", info.Uptime)
+	if out, err := exec.Command("hyprctl", "version").Output(); err == nil {
+		first, _, _ := strings.Cut(string(out), "\n")
+		fmt.Fprintf(&b, "Compositor: %s\n", strings.TrimSpace(first))
+	} else {
+		fmt.Fprintf(&b, "Compositor: hyprctl unavailable (%v)\n", err)
+	}
+
+	section("Services")
+	for _, s := range sessionServices {
+		state := "stopped"
+		switch {
+		case !s.installed():
+			state = "not installed"
+		case len(s.pids()) > 0:
+			state = "running"
+		}
+		if slices.Contains(disabled, s.Name) {
+			state += ", not started with the session"
+		}
+		fmt.Fprintf(&b, "%s: %s\n", s.Name, state)
+	}
+
+	for _, s := range sessionServices {
+		if lines := lastLines(s.logPath(), diagnosticLogLines); lines != "" {
+			section("Log: " + s.Name)
+			b.WriteString(lines + "\n")
+		}
+	}
+	if signature := os.Getenv("HYPRLAND_INSTANCE_SIGNATURE"); signature != "" {
+		path := filepath.Join(runtimeDir(), "hypr", signature, "hyprland.log")
+		if lines := lastLines(path, diagnosticLogLines); lines != "" {
+			section("Log: Hyprland")
+			b.WriteString(lines + "\n")
+		}
+	}
+
+	section("settings.json")
+	for _, key := range privateSettings {
+		delete(config, key)
+	}
+	if data, err := json.MarshalIndent(config, "", "  "); err == nil {
+		b.Write(data)
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// lastLines returns the last n lines of the file at path, or "" when it is
+// missing or empty
+func lastLines(path string, n int) string {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	lines := strings.Split(strings.TrimRight(string(data), "\n"), "\n")
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return strings.TrimRight(strings.Join(lines, "\n"), "\n")
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"time"
+
+	"github.com/diamondburned/gotk4/pkg/gtk/v4"
+)
+
+// Automatic theme schedules; raven-shell does the switching
+var themeSchedules = []string{"off", "fixed", "sun"}
+
+// appendThemeScheduleRows adds the automatic light/dark switching settings
+// to the Appearance page
+func (m *RavenSettingsMenu) appendThemeScheduleRows(content *gtk.Box) {
+	scheduleDropdown := gtk.NewDropDown(gtk.NewStringList([]string{"Off", "Fixed Hours", "Sunrise and Sunset"}), nil)
+	for i, schedule := range themeSchedules {
+		if schedule == m.settings.ThemeSchedule {
+			scheduleDropdown.SetSelected(uint(i))
+			break
+		}
+	}
+	content.Append(m.createSettingRow("Automatic Theme", "Switch between light and dark during the day", scheduleDropdown))
+
+	lightEntry := newClockEntry(m.settings.LightThemeStart, func(value string) {
+		m.settings.LightThemeStart = value
+		m.saveSettings()
+	})
+	darkEntry := newClockEntry(m.settings.DarkThemeStart, func(value string) {
+		m.settings.DarkThemeStart = value
+		m.saveSettings()
+	})
+	hoursBox := gtk.NewBox(gtk.OrientationHorizontal, 8)
+	hoursBox.Append(gtk.NewLabel("Light"))
+	hoursBox.Append(lightEntry)
+	hoursBox.Append(gtk.NewLabel("Dark"))
+	hoursBox.Append(darkEntry)
+	hoursRow := m.createSettingRow("Switch Times", "When the light and dark themes start (HH:MM)", hoursBox)
+	content.Append(hoursRow)
+
+	latitude := gtk.NewSpinButton(gtk.NewAdjustment(m.settings.Latitude, -90, 90, 0.1, 1, 0), 0.1, 2)
+	latitude.SetTooltipText("Latitude, north positive")
+	latitude.ConnectValueChanged(func() {
+		m.settings.Latitude = latitude.Value()
+		m.saveSettings()
+	})
+	longitude := gtk.NewSpinButton(gtk.NewAdjustment(m.settings.Longitude, -180, 180, 0.1, 1, 0), 0.1, 2)
+	longitude.SetTooltipText("Longitude, east positive")
+	longitude.ConnectValueChanged(func() {
+		m.settings.Longitude = longitude.Value()
+		m.saveSettings()
+	})
+	locationBox := gtk.NewBox(gtk.OrientationHorizontal, 8)
+	locationBox.Append(latitude)
+	locationBox.Append(longitude)
+	locationRow := m.createSettingRow("Location", "Latitude and longitude used for sunrise and sunset", locationBox)
+	content.Append(locationRow)
+
+	updateRows := func() {
+		hoursRow.SetSensitive(m.settings.ThemeSchedule == "fixed")
+		locationRow.SetSensitive(m.settings.ThemeSchedule == "sun")
+	}
+	updateRows()
+
+	scheduleDropdown.Connect("notify::selected", func() {
+		idx := scheduleDropdown.Selected()
+		if idx < uint(len(themeSchedules)) {
+			m.settings.ThemeSchedule = themeSchedules[idx]
+			m.saveSettings()
+			updateRows()
+		}
+	})
+}
+
+// newClockEntry returns an entry for an "HH:MM" time that calls save with
+// each valid time typed into it
+func newClockEntry(value string, save func(string)) *gtk.Entry {
+	entry := gtk.NewEntry()
+	entry.SetText(value)
+	entry.SetMaxLength(5)
+	entry.SetWidthChars(5)
+	entry.ConnectChanged(func() {
+		t, err := time.Parse("15:04", entry.Text())
+		if err != nil {
+			entry.AddCSSClass("error")
+			return
+		}
+		entry.RemoveCSSClass("error")
+		save(t.Format("15:04"))
+	})
+	return entry
+}
@@ -0,0 +1,329 @@
+package main
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/diamondburned/gotk4/pkg/gdk/v4"
+	"github.com/diamondburned/gotk4/pkg/gdkpixbuf/v2"
+	"github.com/diamondburned/gotk4/pkg/glib/v2"
+	"github.com/diamondburned/gotk4/pkg/gtk/v4"
+)
+
+// Directories holding the bundled wallpapers, searched one level deep
+var wallpaperDirs = []string{"/usr/share/backgrounds"}
+
+// How many recently used wallpapers are kept
+const maxRecentWallpapers = 8
+
+// Thumbnail sizes for the gallery and the current selection
+const (
+	thumbWidth    = 144
+	thumbHeight   = 90
+	previewWidth  = 320
+	previewHeight = 200
+)
+
+// Thumbnails decoded at once; wallpapers can be large
+var thumbnailSlots = make(chan struct{}, 2)
+
+// isWallpaperImage reports whether path is an image the gallery can show
+func isWallpaperImage(path string) bool {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".png", ".jpg", ".jpeg", ".webp":
+		return true
+	}
+	return false
+}
+
+// bundledWallpapers returns the images in wallpaperDirs, sorted by path
+func bundledWallpapers() []string {
+	var paths []string
+	for _, dir := range wallpaperDirs {
+		for _, pattern := range []string{"*", "*/*"} {
+			matches, _ := filepath.Glob(filepath.Join(dir, pattern))
+			for _, match := range matches {
+				if isWallpaperImage(match) {
+					paths = append(paths, match)
+				}
+			}
+		}
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// wallpaperThumbnail scales path to fit width x height into the thumbnail
+// cache and returns the PNG. A cached PNG is reused until the image changes.
+func wallpaperThumbnail(path string, width, height int) (string, error) {
+	src, err := os.Stat(path)
+	if err != nil {
+		return "", err
+	}
+
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	sum := sha1.Sum([]byte(path))
+	size := strconv.Itoa(width) + "x" + strconv.Itoa(height)
+	thumb := filepath.Join(cacheDir, "raven", "thumbnails", size, hex.EncodeToString(sum[:])+".png")
+	if cached, err := os.Stat(thumb); err == nil && !cached.ModTime().Before(src.ModTime()) {
+		return thumb, nil
+	}
+
+	pixbuf, err := gdkpixbuf.NewPixbufFromFileAtScale(path, width, height, true)
+	if err != nil {
+		return "", fmt.Errorf("thumbnail %s: %w", path, err)
+	}
+	if err := os.MkdirAll(filepath.Dir(thumb), 0755); err != nil {
+		return "", err
+	}
+
+	tmp := thumb + ".tmp" + strconv.Itoa(os.Getpid())
+	if err := pixbuf.Savev(tmp, "png", nil, nil); err != nil {
+		os.Remove(tmp)
+		return "", err
+	}
+	if err := os.Rename(tmp, thumb); err != nil {
+		os.Remove(tmp)
+		return "", err
+	}
+	return thumb, nil
+}
+
+// loadThumbnail fills picture with a thumbnail of path once it is ready
+func loadThumbnail(picture *gtk.Picture, path string, width, height int) {
+	go func() {
+		thumbnailSlots <- struct{}{}
+		thumb, err := wallpaperThumbnail(path, width, height)
+		<-thumbnailSlots
+
+		glib.IdleAdd(func() {
+			if err != nil {
+				picture.SetFilename("")
+				return
+			}
+			picture.SetFilename(thumb)
+		})
+	}()
+}
+
+// wallpaperPicker is the wallpaper section of the Desktop page
+type wallpaperPicker struct {
+	preview     *gtk.Picture
+	name        *gtk.Label
+	recentTitle *gtk.Label
+	recent      *gtk.FlowBox
+	recentPaths []string
+}
+
+// createWallpaperSection builds the current wallpaper preview, the recent
+// wallpapers and the bundled gallery
+func (m *RavenSettingsMenu) createWallpaperSection() *gtk.Box {
+	section := gtk.NewBox(gtk.OrientationVertical, 12)
+	section.AddCSSClass("setting-row")
+
+	m.wallpaper = &wallpaperPicker{}
+
+	// Current selection
+	current := gtk.NewBox(gtk.OrientationHorizontal, 16)
+	m.wallpaper.preview = gtk.NewPicture()
+	m.wallpaper.preview.SetSizeRequest(previewWidth, previewHeight)
+	m.wallpaper.preview.SetContentFit(gtk.ContentFitCover)
+	m.wallpaper.preview.AddCSSClass("wallpaper-preview")
+	current.Append(m.wallpaper.preview)
+
+	info := gtk.NewBox(gtk.OrientationVertical, 4)
+	info.SetVAlign(gtk.AlignCenter)
+	title := gtk.NewLabel("Wallpaper")
+	title.AddCSSClass("setting-label")
+	title.SetHAlign(gtk.AlignStart)
+	info.Append(title)
+
+	m.wallpaper.name = gtk.NewLabel("")
+	m.wallpaper.name.AddCSSClass("setting-description")
+	m.wallpaper.name.SetHAlign(gtk.AlignStart)
+	m.wallpaper.name.SetWrap(true)
+	info.Append(m.wallpaper.name)
+
+	hint := gtk.NewLabel("Pick one below, browse, or drop an image here")
+	hint.AddCSSClass("setting-description")
+	hint.SetHAlign(gtk.AlignStart)
+	info.Append(hint)
+
+	browseBtn := gtk.NewButton()
+	browseBtn.SetLabel("Browse")
+	browseBtn.SetHAlign(gtk.AlignStart)
+	browseBtn.SetMarginTop(8)
+	browseBtn.ConnectClicked(m.browseWallpaper)
+	info.Append(browseBtn)
+	current.Append(info)
+	section.Append(current)
+
+	// Recently used
+	m.wallpaper.recentTitle = newGalleryTitle("Recent")
+	section.Append(m.wallpaper.recentTitle)
+	m.wallpaper.recent = newWallpaperGrid()
+	m.wallpaper.recent.ConnectChildActivated(func(child *gtk.FlowBoxChild) {
+		if idx := child.Index(); idx >= 0 && idx < len(m.wallpaper.recentPaths) {
+			m.selectWallpaper(m.wallpaper.recentPaths[idx])
+		}
+	})
+	section.Append(m.wallpaper.recent)
+
+	// Bundled wallpapers
+	section.Append(newGalleryTitle("Gallery"))
+	bundled := bundledWallpapers()
+	if len(bundled) == 0 {
+		empty := gtk.NewLabel("No wallpapers in " + strings.Join(wallpaperDirs, ", "))
+		empty.AddCSSClass("setting-description")
+		empty.SetHAlign(gtk.AlignStart)
+		section.Append(empty)
+	} else {
+		gallery := newWallpaperGrid()
+		for _, path := range bundled {
+			gallery.Insert(newWallpaperThumb(path), -1)
+		}
+		gallery.ConnectChildActivated(func(child *gtk.FlowBoxChild) {
+			if idx := child.Index(); idx >= 0 && idx < len(bundled) {
+				m.selectWallpaper(bundled[idx])
+			}
+		})
+		section.Append(gallery)
+	}
+
+	m.updateWallpaperPreview()
+	m.updateRecentWallpapers()
+	return section
+}
+
+func newGalleryTitle(text string) *gtk.Label {
+	label := gtk.NewLabel(text)
+	label.AddCSSClass("setting-label")
+	label.SetHAlign(gtk.AlignStart)
+	return label
+}
+
+func newWallpaperGrid() *gtk.FlowBox {
+	grid := gtk.NewFlowBox()
+	grid.SetSelectionMode(gtk.SelectionNone)
+	grid.SetActivateOnSingleClick(true)
+	grid.SetHomogeneous(true)
+	grid.SetRowSpacing(8)
+	grid.SetColumnSpacing(8)
+	grid.SetMaxChildrenPerLine(6)
+	grid.SetMinChildrenPerLine(2)
+	return grid
+}
+
+func newWallpaperThumb(path string) *gtk.Picture {
+	picture := gtk.NewPicture()
+	picture.SetSizeRequest(thumbWidth, thumbHeight)
+	picture.SetContentFit(gtk.ContentFitCover)
+	picture.SetTooltipText(filepath.Base(path))
+	picture.AddCSSClass("wallpaper-thumb")
+	loadThumbnail(picture, path, thumbWidth, thumbHeight)
+	return picture
+}
+
+// selectWallpaper makes path the wallpaper and remembers it as recent
+func (m *RavenSettingsMenu) selectWallpaper(path string) {
+	m.settings.WallpaperPath = path
+	recent := []string{path}
+	for _, p := range m.settings.RecentWallpapers {
+		if p != path && len(recent) < maxRecentWallpapers {
+			recent = append(recent, p)
+		}
+	}
+	m.settings.RecentWallpapers = recent
+
+	m.saveSettings()
+	m.applyWallpaper()
+	m.updateWallpaperPreview()
+	m.updateRecentWallpapers()
+}
+
+// updateWallpaperPreview shows the configured wallpaper
+func (m *RavenSettingsMenu) updateWallpaperPreview() {
+	path := m.settings.WallpaperPath
+	if path == "" {
+		m.wallpaper.name.SetText("No wallpaper set")
+		m.wallpaper.preview.SetFilename("")
+		return
+	}
+	m.wallpaper.name.SetText(path)
+	loadThumbnail(m.wallpaper.preview, path, previewWidth, previewHeight)
+}
+
+// updateRecentWallpapers redraws the recent row, skipping deleted images
+func (m *RavenSettingsMenu) updateRecentWallpapers() {
+	for child := m.wallpaper.recent.FirstChild(); child != nil; child = m.wallpaper.recent.FirstChild() {
+		m.wallpaper.recent.Remove(child)
+	}
+
+	m.wallpaper.recentPaths = nil
+	for _, path := range m.settings.RecentWallpapers {
+		if _, err := os.Stat(path); err != nil || !isWallpaperImage(path) {
+			continue
+		}
+		m.wallpaper.recentPaths = append(m.wallpaper.recentPaths, path)
+		m.wallpaper.recent.Insert(newWallpaperThumb(path), -1)
+	}
+
+	visible := len(m.wallpaper.recentPaths) > 0
+	m.wallpaper.recentTitle.SetVisible(visible)
+	m.wallpaper.recent.SetVisible(visible)
+}
+
+// browseWallpaper picks a wallpaper with the file chooser
+func (m *RavenSettingsMenu) browseWallpaper() {
+	dialog := gtk.NewFileChooserNative(
+		"Select Wallpaper",
+		m.window,
+		gtk.FileChooserActionOpen,
+		"Select",
+		"Cancel",
+	)
+
+	filter := gtk.NewFileFilter()
+	filter.SetName("Images")
+	filter.AddMIMEType("image/png")
+	filter.AddMIMEType("image/jpeg")
+	filter.AddMIMEType("image/webp")
+	dialog.AddFilter(filter)
+
+	dialog.ConnectResponse(func(response int) {
+		if response == int(gtk.ResponseAccept) {
+			if file := dialog.File(); file != nil && file.Path() != "" {
+				m.selectWallpaper(file.Path())
+			}
+		}
+	})
+	dialog.Show()
+}
+
+// attachWallpaperDrop sets the first image dropped on page as wallpaper
+func (m *RavenSettingsMenu) attachWallpaperDrop(page gtk.Widgetter) {
+	drop := gtk.NewDropTarget(gdk.GTypeFileList, gdk.ActionCopy)
+	drop.ConnectDrop(func(value *glib.Value, x, y float64) bool {
+		list, ok := value.GoValue().(*gdk.FileList)
+		if !ok {
+			return false
+		}
+		for _, file := range list.Files() {
+			if path := file.Path(); path != "" && isWallpaperImage(path) {
+				m.selectWallpaper(path)
+				return true
+			}
+		}
+		return false
+	})
+	gtk.BaseWidget(page).AddController(drop)
+}
@@ -0,0 +1,5 @@
+module raven-settingsd
+
+go 1.23
+
+require github.com/godbus/dbus/v5 v5.1.0
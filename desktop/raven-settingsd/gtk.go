@@ -0,0 +1,337 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Keys of settings.json that GTK apps are told about
+var gtkKeys = []string{"theme", "icon_theme", "cursor_theme", "font_size"}
+
+// Used while settings.json doesn't say otherwise, as in raven-settings-menu
+const (
+	defaultIconTheme   = "Papirus-Dark"
+	defaultCursorTheme = "Adwaita"
+	defaultFontSize    = 14
+	defaultCursorSize  = 24
+)
+
+// appearance is the part of settings.json passed on to GTK apps
+type appearance struct {
+	Theme       string `json:"theme"` // "dark", "light" or "system"
+	IconTheme   string `json:"icon_theme"`
+	CursorTheme string `json:"cursor_theme"`
+	FontSize    int    `json:"font_size"` // Pixels
+}
+
+// appearance returns the GTK settings in the last version of settings.json
+// read
+func (w *watcher) appearance() appearance {
+	a := appearance{
+		Theme:       "dark",
+		IconTheme:   defaultIconTheme,
+		CursorTheme: defaultCursorTheme,
+		FontSize:    defaultFontSize,
+	}
+
+	w.mu.Lock()
+	values := make(map[string]json.RawMessage, len(gtkKeys))
+	for _, key := range gtkKeys {
+		if value, ok := w.values[key]; ok {
+			values[key] = value
+		}
+	}
+	w.mu.Unlock()
+
+	if data, err := json.Marshal(values); err == nil {
+		json.Unmarshal(data, &a)
+	}
+	return a
+}
+
+// changesGTK reports whether any of keys is passed on to GTK apps
+func changesGTK(keys []string) bool {
+	for _, key := range keys {
+		for _, gtkKey := range gtkKeys {
+			if key == gtkKey {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// applyGTK passes the appearance on to GTK apps. Running apps follow the
+// GNOME interface settings, which xdg-desktop-portal also serves to
+// sandboxed and libadwaita apps as the color scheme; apps started later
+// read the GTK 3 and 4 settings.ini files. The light or dark variant of the
+// GTK and icon themes is used when one is installed.
+func applyGTK(a appearance) {
+	dark := a.Theme != "light"
+	gtkTheme := themeVariant(currentGTKTheme(), a.Theme)
+	iconTheme := iconThemeVariant(a.IconTheme, a.Theme)
+	font := fmt.Sprintf("%s %d", currentFontFamily(), int(math.Round(float64(a.FontSize)*0.75)))
+
+	scheme := "default"
+	switch a.Theme {
+	case "dark":
+		scheme = "prefer-dark"
+	case "light":
+		scheme = "prefer-light"
+	}
+
+	if _, err := exec.LookPath("gsettings"); err == nil {
+		for key, value := range map[string]string{
+			"color-scheme": scheme,
+			"gtk-theme":    gtkTheme,
+			"icon-theme":   iconTheme,
+			"cursor-theme": a.CursorTheme,
+			"font-name":    font,
+		} {
+			// Without the GNOME schemas installed every key fails the same way
+			if out, err := exec.Command("gsettings", "set", "org.gnome.desktop.interface", key, value).CombinedOutput(); err != nil {
+				fmt.Fprintf(os.Stderr, "raven-settingsd: failed to set %s: %s\n", key, strings.TrimSpace(string(out)))
+				break
+			}
+		}
+	}
+
+	ini := map[string]string{
+		"gtk-theme-name":        gtkTheme,
+		"gtk-icon-theme-name":   iconTheme,
+		"gtk-cursor-theme-name": a.CursorTheme,
+		"gtk-font-name":         font,
+	}
+	if a.Theme != "system" {
+		ini["gtk-application-prefer-dark-theme"] = strconv.FormatBool(dark)
+	}
+	if configDir, err := os.UserConfigDir(); err == nil {
+		for _, dir := range []string{"gtk-3.0", "gtk-4.0"} {
+			path := filepath.Join(configDir, dir, "settings.ini")
+			if err := setINIValues(path, "Settings", ini); err != nil {
+				fmt.Fprintf(os.Stderr, "raven-settingsd: failed to update %s: %v\n", path, err)
+			}
+		}
+	}
+
+	// Hyprland draws the cursor over windows that don't set one
+	if os.Getenv("HYPRLAND_INSTANCE_SIGNATURE") != "" {
+		size := defaultCursorSize
+		if n, err := strconv.Atoi(os.Getenv("XCURSOR_SIZE")); err == nil && n > 0 {
+			size = n
+		}
+		exec.Command("hyprctl", "setcursor", a.CursorTheme, strconv.Itoa(size)).Run()
+	}
+}
+
+// currentGTKTheme returns the GTK theme set in the GNOME interface
+// settings, or else in the GTK 3 settings.ini. Raven Settings doesn't
+// choose one.
+func currentGTKTheme() string {
+	if theme := getInterfaceSetting("gtk-theme"); theme != "" {
+		return theme
+	}
+	if configDir, err := os.UserConfigDir(); err == nil {
+		if data, err := os.ReadFile(filepath.Join(configDir, "gtk-3.0", "settings.ini")); err == nil {
+			for _, line := range strings.Split(string(data), "\n") {
+				if name, value, ok := strings.Cut(line, "="); ok && strings.TrimSpace(name) == "gtk-theme-name" {
+					return strings.TrimSpace(value)
+				}
+			}
+		}
+	}
+	return "Adwaita"
+}
+
+// currentFontFamily returns the family of the interface font, without its
+// size
+func currentFontFamily() string {
+	font := getInterfaceSetting("font-name")
+	if i := strings.LastIndex(font, " "); i > 0 {
+		if _, err := strconv.ParseFloat(font[i+1:], 64); err == nil {
+			font = font[:i]
+		}
+	}
+	if font == "" {
+		return "Sans"
+	}
+	return font
+}
+
+// getInterfaceSetting reads a string from the GNOME interface settings. It
+// returns "" when gsettings isn't installed.
+func getInterfaceSetting(key string) string {
+	out, err := exec.Command("gsettings", "get", "org.gnome.desktop.interface", key).Output()
+	if err != nil {
+		return ""
+	}
+	return strings.Trim(strings.TrimSpace(string(out)), "'")
+}
+
+// themeVariant returns the dark variant of a GTK theme for the dark theme,
+// and the theme without one otherwise. Adwaita's dark variant is built into
+// GTK; other themes need a <name>-dark theme installed.
+func themeVariant(name, theme string) string {
+	base := strings.TrimSuffix(strings.TrimSuffix(name, "-dark"), "-Dark")
+	if theme != "dark" {
+		return base
+	}
+	if base == "Adwaita" {
+		return "Adwaita-dark"
+	}
+	for _, variant := range []string{base + "-dark", base + "-Dark"} {
+		if installed(themeDirs(), variant) {
+			return variant
+		}
+	}
+	return base
+}
+
+// iconThemeVariant swaps an icon theme that comes in -Dark and -Light
+// variants, such as Papirus, for the one matching theme. Other themes are
+// used as they are.
+func iconThemeVariant(name, theme string) string {
+	base := name
+	for _, suffix := range []string{"-Dark", "-Light", "-dark", "-light"} {
+		base = strings.TrimSuffix(base, suffix)
+	}
+
+	var variants []string
+	switch theme {
+	case "dark":
+		variants = []string{base + "-Dark", base + "-dark"}
+	case "light":
+		variants = []string{base + "-Light", base + "-light", base}
+	default:
+		return name
+	}
+	for _, variant := range variants {
+		if installed(iconDirs(), variant) {
+			return variant
+		}
+	}
+	return name
+}
+
+// installed reports whether a theme called name is in any of dirs
+func installed(dirs []string, name string) bool {
+	for _, dir := range dirs {
+		if info, err := os.Stat(filepath.Join(dir, name)); err == nil && info.IsDir() {
+			return true
+		}
+	}
+	return false
+}
+
+// themeDirs are searched for GTK themes
+func themeDirs() []string {
+	return dataDirs(".themes", "themes")
+}
+
+// iconDirs are searched for icon and cursor themes
+func iconDirs() []string {
+	return dataDirs(".icons", "icons")
+}
+
+// dataDirs returns ~/<legacy> followed by <sub> in each XDG data directory
+func dataDirs(legacy, sub string) []string {
+	home := os.Getenv("HOME")
+	dirs := []string{filepath.Join(home, legacy)}
+
+	dataHome := os.Getenv("XDG_DATA_HOME")
+	if dataHome == "" {
+		dataHome = filepath.Join(home, ".local", "share")
+	}
+	dirs = append(dirs, filepath.Join(dataHome, sub))
+
+	dataDirs := os.Getenv("XDG_DATA_DIRS")
+	if dataDirs == "" {
+		dataDirs = "/usr/local/share:/usr/share"
+	}
+	for _, dir := range strings.Split(dataDirs, ":") {
+		if dir != "" {
+			dirs = append(dirs, filepath.Join(dir, sub))
+		}
+	}
+	return dirs
+}
+
+// setINIValues sets keys in section of the INI file at path, creating the
+// file, the section or the keys as needed
+func setINIValues(path, section string, values map[string]string) error {
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	var lines []string
+	if len(data) > 0 {
+		lines = strings.Split(strings.TrimRight(string(data), "\n"), "\n")
+	}
+
+	header := "[" + section + "]"
+	inSection := false
+	sectionEnd := -1
+	set := make(map[string]bool)
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "[") {
+			inSection = trimmed == header
+			if inSection {
+				sectionEnd = i + 1
+			}
+			continue
+		}
+		if !inSection {
+			continue
+		}
+		if name, _, ok := strings.Cut(trimmed, "="); ok {
+			key := strings.TrimSpace(name)
+			if value, ok := values[key]; ok {
+				lines[i] = key + "=" + value
+				set[key] = true
+			}
+		}
+		if trimmed != "" {
+			sectionEnd = i + 1
+		}
+	}
+
+	var missing []string
+	for _, key := range sortedKeys(values) {
+		if !set[key] {
+			missing = append(missing, key+"="+values[key])
+		}
+	}
+	if len(missing) > 0 {
+		if sectionEnd < 0 {
+			lines = append(lines, header)
+			lines = append(lines, missing...)
+		} else {
+			lines = append(lines[:sectionEnd], append(missing, lines[sectionEnd:]...)...)
+		}
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0644)
+}
+
+// sortedKeys returns the keys of values in order, so files are written the
+// same way every time
+func sortedKeys(values map[string]string) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	return keys
+}
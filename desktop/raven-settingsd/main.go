@@ -0,0 +1,76 @@
+// raven-settingsd watches ~/.config/raven/settings.json and tells the rest
+// of the desktop which keys changed, so components apply a setting as soon
+// as it is saved instead of the next time they start. It passes the theme,
+// icon and cursor themes and font size on to GTK apps itself.
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/godbus/dbus/v5/introspect"
+)
+
+const (
+	busName    = "org.ravenlinux.Settings"
+	objectPath = dbus.ObjectPath("/org/ravenlinux/Settings")
+	busIface   = "org.ravenlinux.Settings"
+)
+
+// introspectXML describes the interface for busctl and gdbus
+const introspectXML = `
+<node>
+	<interface name="` + busIface + `">
+		<method name="Get">
+			<arg name="key" type="s" direction="in"/>
+			<arg name="value" type="s" direction="out"/>
+		</method>
+		<method name="Keys">
+			<arg name="keys" type="as" direction="out"/>
+		</method>
+		<signal name="Changed">
+			<arg name="keys" type="as"/>
+		</signal>
+	</interface>` + introspect.IntrospectDataString + `</node>`
+
+func main() {
+	path := filepath.Join(os.Getenv("HOME"), ".config", "raven", "settings.json")
+
+	conn, err := dbus.ConnectSessionBus()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "raven-settingsd: cannot connect to the session bus: %v\n", err)
+		os.Exit(1)
+	}
+	defer conn.Close()
+
+	reply, err := conn.RequestName(busName, dbus.NameFlagDoNotQueue)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "raven-settingsd: cannot own %s: %v\n", busName, err)
+		os.Exit(1)
+	}
+	if reply != dbus.RequestNameReplyPrimaryOwner {
+		fmt.Fprintf(os.Stderr, "raven-settingsd: already running\n")
+		os.Exit(1)
+	}
+
+	w := newWatcher(path)
+	conn.Export(w, objectPath, busIface)
+	conn.Export(introspect.Introspectable(introspectXML), objectPath, "org.freedesktop.DBus.Introspectable")
+
+	// GTK apps are brought in line with settings.json once at login, since
+	// it may have been edited while the session wasn't running
+	applyGTK(w.appearance())
+
+	err = w.run(func(keys []string) {
+		if err := conn.Emit(objectPath, busIface+".Changed", keys); err != nil {
+			fmt.Fprintf(os.Stderr, "raven-settingsd: failed to announce %v: %v\n", keys, err)
+		}
+		if changesGTK(keys) {
+			applyGTK(w.appearance())
+		}
+	})
+	fmt.Fprintf(os.Stderr, "raven-settingsd: %v\n", err)
+	os.Exit(1)
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+)
+
+// How long to wait after settings.json is written before reading it.
+// Writers that back up the old file or write a temp file first touch it
+// more than once per save.
+const settleDelay = 150 * time.Millisecond
+
+// watcher keeps the last version of settings.json it read, to tell which
+// keys a write changed. Its exported methods are served on the bus.
+type watcher struct {
+	path string
+
+	mu     sync.Mutex
+	values map[string]json.RawMessage // Compacted, so reformatting isn't a change
+}
+
+func newWatcher(path string) *watcher {
+	w := &watcher{path: path, values: make(map[string]json.RawMessage)}
+	if values, err := readSettings(path); err == nil {
+		w.values = values
+	} else {
+		fmt.Fprintf(os.Stderr, "raven-settingsd: %v\n", err)
+	}
+	return w
+}
+
+// Get returns key's value in settings.json as JSON, or "" when it isn't set
+func (w *watcher) Get(key string) (string, *dbus.Error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return string(w.values[key]), nil
+}
+
+// Keys returns the keys set in settings.json
+func (w *watcher) Keys() ([]string, *dbus.Error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	keys := make([]string, 0, len(w.values))
+	for key := range w.values {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	return keys, nil
+}
+
+// run watches the settings directory and calls changed with the keys each
+// write added, removed or changed. It returns when the watch fails.
+func (w *watcher) run(changed func(keys []string)) error {
+	dir := filepath.Dir(w.path)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC)
+	if err != nil {
+		return fmt.Errorf("inotify: %w", err)
+	}
+	defer syscall.Close(fd)
+	// The directory is watched rather than the file, since atomic writes
+	// replace the file with a new one
+	mask := uint32(syscall.IN_CLOSE_WRITE | syscall.IN_MOVED_TO | syscall.IN_MOVED_FROM | syscall.IN_DELETE)
+	if _, err := syscall.InotifyAddWatch(fd, dir, mask); err != nil {
+		return fmt.Errorf("watch %s: %w", dir, err)
+	}
+
+	events := make(chan struct{}, 1)
+	done := make(chan error, 1)
+	go func() {
+		done <- readEvents(fd, filepath.Base(w.path), events)
+	}()
+
+	for {
+		select {
+		case err := <-done:
+			return err
+		case <-events:
+		}
+		time.Sleep(settleDelay)
+		select {
+		case <-events:
+		default:
+		}
+
+		if keys := w.reload(); len(keys) > 0 {
+			changed(keys)
+		}
+	}
+}
+
+// readEvents signals events whenever the file called name in the watched
+// directory is written, replaced or removed
+func readEvents(fd int, name string, events chan<- struct{}) error {
+	buf := make([]byte, 64*1024)
+	for {
+		n, err := syscall.Read(fd, buf)
+		if err == syscall.EINTR {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("inotify: %w", err)
+		}
+
+		for off := 0; off+syscall.SizeofInotifyEvent <= n; {
+			mask := binary.NativeEndian.Uint32(buf[off+4:])
+			nameLen := int(binary.NativeEndian.Uint32(buf[off+12:]))
+			start := off + syscall.SizeofInotifyEvent
+			eventName := strings.TrimRight(string(buf[start:min(start+nameLen, n)]), "\x00")
+			off = start + nameLen
+
+			if mask&syscall.IN_IGNORED != 0 {
+				return errors.New("settings directory was removed")
+			}
+			if eventName == name || mask&syscall.IN_Q_OVERFLOW != 0 {
+				select {
+				case events <- struct{}{}:
+				default:
+				}
+			}
+		}
+	}
+}
+
+// reload reads settings.json and returns the keys that differ from the
+// last version read. A file that isn't valid JSON is skipped; its keys are
+// compared once it is fixed.
+func (w *watcher) reload() []string {
+	values, err := readSettings(w.path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "raven-settingsd: %v\n", err)
+		return nil
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	var keys []string
+	for key, value := range values {
+		if old, ok := w.values[key]; !ok || !bytes.Equal(old, value) {
+			keys = append(keys, key)
+		}
+	}
+	for key := range w.values {
+		if _, ok := values[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	w.values = values
+	slices.Sort(keys)
+	return keys
+}
+
+// readSettings returns the compacted value of every key in settings.json.
+// A missing file has no keys.
+func readSettings(path string) (map[string]json.RawMessage, error) {
+	values := make(map[string]json.RawMessage)
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return values, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	raw := make(map[string]json.RawMessage)
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", path, err)
+	}
+	for key, value := range raw {
+		var compact bytes.Buffer
+		if err := json.Compact(&compact, value); err != nil {
+			return nil, err
+		}
+		values[key] = compact.Bytes()
+	}
+	return values, nil
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/diamondburned/gotk4/pkg/glib/v2"
+	"github.com/diamondburned/gotk4/pkg/gtk/v4"
+	"github.com/godbus/dbus/v5"
+)
+
+// Longest notification summary spoken, in characters
+const maxSpokenSummary = 120
+
+// setAccessibleLabel names widget for screen readers. Buttons showing only
+// an icon or a picture have no name on the AT-SPI bus otherwise.
+func setAccessibleLabel(widget gtk.Widgetter, label string) {
+	gtk.BaseWidget(widget).UpdateProperty(
+		[]gtk.AccessibleProperty{gtk.AccessiblePropertyLabel},
+		[]glib.Value{*glib.NewValue(label)},
+	)
+}
+
+// setAccessibleDescription tells screen readers what widget does, read
+// after its label
+func setAccessibleDescription(widget gtk.Widgetter, description string) {
+	gtk.BaseWidget(widget).UpdateProperty(
+		[]gtk.AccessibleProperty{gtk.AccessiblePropertyDescription},
+		[]glib.Value{*glib.NewValue(description)},
+	)
+}
+
+// dockItemLabel names a dock button together with the state its styling
+// shows, such as "Firefox, running, minimized"
+func (p *RavenPanel) dockItemLabel(item *DockItem) string {
+	parts := []string{item.Name}
+	switch {
+	case item.Launching:
+		parts = append(parts, "starting")
+	case item.NotResponding:
+		parts = append(parts, "not responding")
+	case item.Running:
+		parts = append(parts, "running")
+	}
+	if item.Minimized {
+		parts = append(parts, "minimized")
+	}
+	if item.Pinned {
+		parts = append(parts, "pinned")
+	}
+	if p.isAppMuted(item) {
+		parts = append(parts, "muted")
+	}
+	return strings.Join(parts, ", ")
+}
+
+// setAnnounceEvents turns spoken announcements on or off and saves the
+// choice as announce_events in settings.json
+func (p *RavenPanel) setAnnounceEvents(on bool) {
+	p.ravenSettings.AnnounceEvents = on
+	if err := updateSettingsFile(p.ravenSettingsPath, map[string]any{"announce_events": on}); err != nil {
+		fmt.Fprintf(os.Stderr, "raven-shell: failed to save announcements: %v\n", err)
+	}
+	if on {
+		p.startAnnouncements()
+		speak("Announcements on")
+	} else {
+		speak("Announcements off")
+	}
+	p.announcing.Store(on)
+}
+
+// startAnnouncements starts listening for the events that are spoken:
+// workspace switches and focus changes from Hyprland's event socket, and
+// notifications on the session bus. The listeners run from the first time
+// announcements are turned on and stay quiet while they are off.
+func (p *RavenPanel) startAnnouncements() {
+	p.announceOnce.Do(func() {
+		go p.watchHyprlandEvents()
+		go p.watchNotifications()
+	})
+}
+
+// announce speaks text while announcements are on
+func (p *RavenPanel) announce(text string) {
+	if p.announcing.Load() {
+		speak(text)
+	}
+}
+
+// speak says text through speech-dispatcher
+func speak(text string) {
+	cmd := exec.Command("spd-say", "--application-name", "raven-shell", "--priority", "message", text)
+	if err := cmd.Start(); err != nil {
+		fmt.Fprintf(os.Stderr, "raven-shell: cannot speak (is speech-dispatcher installed?): %v\n", err)
+		return
+	}
+	go cmd.Wait()
+}
+
+// hyprlandEventSocket returns the path of Hyprland's event socket, which
+// moved from /tmp to the runtime directory in Hyprland 0.40
+func hyprlandEventSocket() string {
+	signature := os.Getenv("HYPRLAND_INSTANCE_SIGNATURE")
+	if signature == "" {
+		return ""
+	}
+	path := filepath.Join(os.Getenv("XDG_RUNTIME_DIR"), "hypr", signature, ".socket2.sock")
+	if _, err := os.Stat(path); err != nil {
+		path = filepath.Join("/tmp", "hypr", signature, ".socket2.sock")
+	}
+	return path
+}
+
+// watchHyprlandEvents announces workspace switches and the newly focused
+// window. The socket is reconnected after Hyprland restarts.
+func (p *RavenPanel) watchHyprlandEvents() {
+	for ; ; time.Sleep(compositorRetryInterval) {
+		path := hyprlandEventSocket()
+		if path == "" {
+			continue
+		}
+		conn, err := net.Dial("unix", path)
+		if err != nil {
+			continue
+		}
+
+		lastWindow := ""
+		scanner := bufio.NewScanner(conn)
+		for scanner.Scan() {
+			event, data, ok := strings.Cut(scanner.Text(), ">>")
+			if !ok {
+				continue
+			}
+			switch event {
+			case "workspace":
+				p.announce("Workspace " + data)
+			case "activewindow":
+				// class,title; the title may itself hold commas
+				class, title, _ := strings.Cut(data, ",")
+				if title == "" {
+					title = class
+				}
+				if title != "" && title != lastWindow {
+					p.announce(title)
+				}
+				lastWindow = title
+			}
+		}
+		conn.Close()
+	}
+}
+
+// watchNotifications announces notifications sent to the notification
+// daemon. It listens on its own connection as a bus monitor, which sees
+// Notify calls meant for another client.
+func (p *RavenPanel) watchNotifications() {
+	conn, err := dbus.SessionBusPrivate()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "raven-shell: notifications won't be announced: %v\n", err)
+		return
+	}
+	err = conn.Auth(nil)
+	if err == nil {
+		err = conn.Hello()
+	}
+	if err == nil {
+		rules := []string{"type='method_call',interface='org.freedesktop.Notifications',member='Notify'"}
+		err = conn.BusObject().Call("org.freedesktop.DBus.Monitoring.BecomeMonitor", 0, rules, uint32(0)).Err
+	}
+	if err != nil {
+		conn.Close()
+		fmt.Fprintf(os.Stderr, "raven-shell: notifications won't be announced: %v\n", err)
+		return
+	}
+
+	messages := make(chan *dbus.Message, 16)
+	conn.Eavesdrop(messages)
+	for msg := range messages {
+		// Notify(app_name, replaces_id, app_icon, summary, body, ...)
+		if len(msg.Body) < 4 {
+			continue
+		}
+		app, _ := msg.Body[0].(string)
+		summary, _ := msg.Body[3].(string)
+		if summary == "" {
+			continue
+		}
+		if runes := []rune(summary); len(runes) > maxSpokenSummary {
+			summary = string(runes[:maxSpokenSummary])
+		}
+		if app != "" {
+			p.announce(fmt.Sprintf("Notification from %s: %s", app, summary))
+		} else {
+			p.announce("Notification: " + summary)
+		}
+	}
+}
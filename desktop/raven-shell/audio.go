@@ -0,0 +1,205 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+
+	"github.com/diamondburned/gotk4/pkg/glib/v2"
+)
+
+// How often the streams of muted apps are checked, so streams they open
+// after being muted are muted too
+const muteCheckSeconds = 2
+
+// audioStream is an app's playback stream (a PulseAudio sink-input) in
+// PipeWire
+type audioStream struct {
+	ID    int
+	Muted bool
+}
+
+// appStreams returns the playback streams of pid and its child processes.
+// Browsers and Electron apps play audio from a child process.
+func appStreams(pid int) ([]audioStream, error) {
+	out, err := exec.Command("pw-dump").Output()
+	if err != nil {
+		return nil, fmt.Errorf("pw-dump: %w", err)
+	}
+
+	var objects []struct {
+		ID   int    `json:"id"`
+		Type string `json:"type"`
+		Info struct {
+			Props  map[string]any `json:"props"`
+			Params struct {
+				Props []struct {
+					Mute bool `json:"mute"`
+				} `json:"Props"`
+			} `json:"params"`
+		} `json:"info"`
+	}
+	if err := json.Unmarshal(out, &objects); err != nil {
+		return nil, fmt.Errorf("pw-dump: %w", err)
+	}
+
+	tree := processTree(pid)
+	var streams []audioStream
+	for _, obj := range objects {
+		if obj.Type != "PipeWire:Interface:Node" || obj.Info.Props["media.class"] != "Stream/Output/Audio" {
+			continue
+		}
+		// The PID is a number, or a string from some clients
+		streamPID, err := strconv.Atoi(fmt.Sprint(obj.Info.Props["application.process.id"]))
+		if err != nil || !tree[streamPID] {
+			continue
+		}
+		stream := audioStream{ID: obj.ID}
+		for _, props := range obj.Info.Params.Props {
+			stream.Muted = stream.Muted || props.Mute
+		}
+		streams = append(streams, stream)
+	}
+	return streams, nil
+}
+
+// processTree returns pid and every process descended from it
+func processTree(pid int) map[int]bool {
+	children := make(map[int][]int)
+	entries, _ := os.ReadDir("/proc")
+	for _, entry := range entries {
+		child, err := strconv.Atoi(entry.Name())
+		if err != nil {
+			continue
+		}
+		fields, err := readStatFields(fmt.Sprintf("/proc/%d/stat", child))
+		if err != nil || len(fields) < 2 {
+			continue
+		}
+		// The parent PID follows the state
+		if parent, err := strconv.Atoi(fields[1]); err == nil {
+			children[parent] = append(children[parent], child)
+		}
+	}
+
+	tree := map[int]bool{pid: true}
+	queue := []int{pid}
+	for len(queue) > 0 {
+		next := queue[0]
+		queue = queue[1:]
+		for _, child := range children[next] {
+			if !tree[child] {
+				tree[child] = true
+				queue = append(queue, child)
+			}
+		}
+	}
+	return tree
+}
+
+// setAppMuted mutes or unmutes the playback streams of pid that aren't
+// already in that state
+func setAppMuted(pid int, muted bool) error {
+	streams, err := appStreams(pid)
+	if err != nil {
+		return err
+	}
+	value := "0"
+	if muted {
+		value = "1"
+	}
+	for _, stream := range streams {
+		if stream.Muted == muted {
+			continue
+		}
+		if err := exec.Command("wpctl", "set-mute", strconv.Itoa(stream.ID), value).Run(); err != nil {
+			return fmt.Errorf("wpctl set-mute %d: %w", stream.ID, err)
+		}
+	}
+	return nil
+}
+
+// isAppMuted reports whether the app behind a dock item was muted from
+// the dock
+func (p *RavenPanel) isAppMuted(item *DockItem) bool {
+	return item.PID > 0 && p.mutedApps[item.PID]
+}
+
+// toggleMute mutes or unmutes a running app. The mute applies to its
+// process, so every window of the app shows the badge.
+func (p *RavenPanel) toggleMute(item *DockItem) {
+	p.mu.RLock()
+	pid := item.PID
+	muted := !p.isAppMuted(item)
+	p.mu.RUnlock()
+	if pid <= 0 {
+		return
+	}
+
+	go func() {
+		if err := setAppMuted(pid, muted); err != nil {
+			fmt.Fprintf(os.Stderr, "raven-shell: failed to mute %s: %v\n", item.Name, err)
+			return
+		}
+		glib.IdleAdd(func() {
+			p.mu.Lock()
+			if p.mutedApps == nil {
+				p.mutedApps = make(map[int]bool)
+			}
+			if muted {
+				p.mutedApps[pid] = true
+			} else {
+				delete(p.mutedApps, pid)
+			}
+			p.mu.Unlock()
+
+			p.renderDock()
+			if muted {
+				p.watchMutedApps()
+			}
+		})
+	}()
+}
+
+// watchMutedApps keeps muted apps muted while any is running, since an app
+// opens new streams as it plays new media. It stops once no muted app is
+// left.
+func (p *RavenPanel) watchMutedApps() {
+	if p.muteWatching {
+		return
+	}
+	p.muteWatching = true
+
+	glib.TimeoutSecondsAdd(muteCheckSeconds, func() bool {
+		p.mu.Lock()
+		running := make(map[int]bool)
+		for _, item := range p.dockItems {
+			if item.Running {
+				running[item.PID] = true
+			}
+		}
+		var pids []int
+		for pid := range p.mutedApps {
+			if running[pid] {
+				pids = append(pids, pid)
+			} else {
+				// The app has exited
+				delete(p.mutedApps, pid)
+			}
+		}
+		p.mu.Unlock()
+
+		if len(pids) == 0 {
+			p.muteWatching = false
+			return false
+		}
+		go func() {
+			for _, pid := range pids {
+				setAppMuted(pid, true)
+			}
+		}()
+		return true
+	})
+}
@@ -0,0 +1,314 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/diamondburned/gotk4/pkg/gdk/v4"
+	"github.com/diamondburned/gotk4/pkg/glib/v2"
+	"github.com/diamondburned/gotk4/pkg/gtk/v4"
+)
+
+// How often the battery level is re-read
+const batteryPollInterval = 30 * time.Second
+
+// Power profiles offered in the popover, as understood by raven-ctl
+var powerProfileChoices = []struct {
+	Name  string
+	Label string
+	Icon  string
+}{
+	{"power-saver", "Power Saver", "power-profile-power-saver-symbolic"},
+	{"balanced", "Balanced", "power-profile-balanced-symbolic"},
+	{"performance", "Performance", "power-profile-performance-symbolic"},
+}
+
+// BatteryStatus is the state of the first system battery
+type BatteryStatus struct {
+	Present bool
+	Percent int
+	Status  string // Charging, Discharging, Full or Not charging
+}
+
+// thermalSensor matches the JSON printed by `raven-ctl thermal --json`
+type thermalSensor struct {
+	Chip     string  `json:"chip"`
+	Label    string  `json:"label"`
+	Celsius  float64 `json:"celsius"`
+	Critical float64 `json:"critical"`
+}
+
+// readBattery reads the first battery in /sys/class/power_supply
+func readBattery() BatteryStatus {
+	batteries, _ := filepath.Glob("/sys/class/power_supply/BAT*")
+	if len(batteries) == 0 {
+		return BatteryStatus{}
+	}
+	capacity, _ := os.ReadFile(filepath.Join(batteries[0], "capacity"))
+	status, _ := os.ReadFile(filepath.Join(batteries[0], "status"))
+
+	percent, err := strconv.Atoi(strings.TrimSpace(string(capacity)))
+	if err != nil {
+		return BatteryStatus{}
+	}
+	return BatteryStatus{Present: true, Percent: percent, Status: strings.TrimSpace(string(status))}
+}
+
+// iconName returns the symbolic battery icon for the level and state
+func (b BatteryStatus) iconName() string {
+	if !b.Present {
+		return "power-profile-balanced-symbolic"
+	}
+	if b.Status == "Full" {
+		return "battery-level-100-charged-symbolic"
+	}
+	level := (b.Percent + 5) / 10 * 10
+	if b.Status == "Charging" {
+		return fmt.Sprintf("battery-level-%d-charging-symbolic", level)
+	}
+	return fmt.Sprintf("battery-level-%d-symbolic", level)
+}
+
+// describe returns the battery line shown in the tooltip and popover
+func (b BatteryStatus) describe() string {
+	if !b.Present {
+		return "No battery"
+	}
+	return fmt.Sprintf("Battery %d%% · %s", b.Percent, b.Status)
+}
+
+// createBatteryIndicator builds the panel power button. Desktops without a
+// battery still get it when power profiles are available.
+func (p *RavenPanel) createBatteryIndicator() *gtk.Button {
+	p.batteryBtn = gtk.NewButton()
+	p.batteryBtn.AddCSSClass("power-indicator")
+	p.batteryBtn.ConnectClicked(func() {
+		p.showBatteryPopover()
+	})
+
+	battery := readBattery()
+	_, err := exec.LookPath("powerprofilesctl")
+	p.batteryBtn.SetVisible(battery.Present || err == nil)
+	p.updateBatteryIndicator(battery)
+	return p.batteryBtn
+}
+
+func (p *RavenPanel) updateBatteryIndicator(battery BatteryStatus) {
+	if p.batteryBtn == nil {
+		return
+	}
+	p.batteryBtn.SetIconName(battery.iconName())
+	p.batteryBtn.SetTooltipText(battery.describe())
+	setAccessibleLabel(p.batteryBtn, battery.describe())
+}
+
+// monitorBattery keeps the indicator icon in step with the battery level
+func (p *RavenPanel) monitorBattery() {
+	if !readBattery().Present {
+		return
+	}
+
+	ticker := time.NewTicker(batteryPollInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		battery := readBattery()
+		glib.IdleAdd(func() {
+			p.updateBatteryIndicator(battery)
+		})
+	}
+}
+
+func (p *RavenPanel) closeBatteryPopover() {
+	if p.batteryWindow != nil {
+		p.batteryWindow.Close()
+		p.batteryWindow = nil
+	}
+}
+
+// showBatteryPopover toggles the popup with the battery level, power
+// profile switcher and temperatures. Profiles and sensors come from
+// raven-ctl so the panel and command line share one implementation.
+func (p *RavenPanel) showBatteryPopover() {
+	if p.batteryWindow != nil {
+		p.closeBatteryPopover()
+		return
+	}
+	p.closeSettingsMenu()
+	p.closePowerMenu()
+	p.closePrintJobs()
+	p.closePhonePopover()
+
+	p.batteryWindow = gtk.NewWindow()
+	p.batteryWindow.SetTitle("Power")
+	p.batteryWindow.SetDecorated(false)
+	p.batteryWindow.SetDefaultSize(300, -1)
+	p.initPopupWindow(p.batteryWindow, p.batteryBtn)
+
+	p.batteryWindow.SetChild(p.createBatteryPopoverContent())
+
+	keyController := gtk.NewEventControllerKey()
+	keyController.ConnectKeyPressed(func(keyval, keycode uint, state gdk.ModifierType) bool {
+		if keyval == gdk.KEY_Escape {
+			p.closeBatteryPopover()
+			return true
+		}
+		return false
+	})
+	p.batteryWindow.AddController(keyController)
+
+	focusController := gtk.NewEventControllerFocus()
+	focusController.ConnectLeave(func() {
+		glib.TimeoutAdd(100, func() bool {
+			p.closeBatteryPopover()
+			return false
+		})
+	})
+	p.batteryWindow.AddController(focusController)
+
+	p.batteryWindow.SetApplication(p.app)
+	p.batteryWindow.Present()
+}
+
+func (p *RavenPanel) createBatteryPopoverContent() *gtk.Box {
+	box := gtk.NewBox(gtk.OrientationVertical, 4)
+	box.AddCSSClass("settings-menu")
+	box.SetMarginTop(8)
+	box.SetMarginBottom(8)
+	box.SetMarginStart(8)
+	box.SetMarginEnd(8)
+
+	battery := readBattery()
+	if battery.Present {
+		level := gtk.NewLabel(battery.describe())
+		level.AddCSSClass("power-row")
+		level.SetHAlign(gtk.AlignStart)
+		box.Append(level)
+	}
+
+	profileLabel := gtk.NewLabel("Power Mode")
+	profileLabel.AddCSSClass("settings-section-label")
+	profileLabel.SetHAlign(gtk.AlignStart)
+	box.Append(profileLabel)
+	box.Append(p.createProfileSwitcher())
+
+	thermalLabel := gtk.NewLabel("Temperatures")
+	thermalLabel.AddCSSClass("settings-section-label")
+	thermalLabel.SetHAlign(gtk.AlignStart)
+	box.Append(thermalLabel)
+
+	sensorBox := gtk.NewBox(gtk.OrientationVertical, 2)
+	box.Append(sensorBox)
+	go func() {
+		sensors, err := readThermalSensors()
+		glib.IdleAdd(func() {
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "raven-shell: temperatures unavailable: %v\n", err)
+				sensorBox.Append(powerDetailLabel("No temperature sensors"))
+				return
+			}
+			for _, s := range sensors {
+				sensorBox.Append(createSensorRow(s))
+			}
+		})
+	}()
+
+	return box
+}
+
+// createProfileSwitcher builds a row of linked toggles, one per profile.
+// They stay insensitive until the active profile has been read.
+func (p *RavenPanel) createProfileSwitcher() *gtk.Box {
+	row := gtk.NewBox(gtk.OrientationHorizontal, 0)
+	row.AddCSSClass("linked")
+	row.SetHomogeneous(true)
+	row.SetSensitive(false)
+
+	var buttons []*gtk.ToggleButton
+	syncing := false
+	for _, choice := range powerProfileChoices {
+		btn := gtk.NewToggleButton()
+		btn.SetIconName(choice.Icon)
+		btn.SetTooltipText(choice.Label)
+		if len(buttons) > 0 {
+			btn.SetGroup(buttons[0])
+		}
+		name := choice.Name
+		btn.ConnectToggled(func() {
+			if syncing || !btn.Active() {
+				return
+			}
+			go func() {
+				if output, err := exec.Command("raven-ctl", "power", "profile", "set", name).CombinedOutput(); err != nil {
+					fmt.Fprintf(os.Stderr, "raven-shell: failed to set power profile %s: %s\n", name, strings.TrimSpace(string(output)))
+				}
+			}()
+		})
+		buttons = append(buttons, btn)
+		row.Append(btn)
+	}
+
+	go func() {
+		output, err := exec.Command("raven-ctl", "power", "profile", "get").Output()
+		active := strings.TrimSpace(string(output))
+		glib.IdleAdd(func() {
+			if err != nil {
+				row.SetTooltipText("Power profiles unavailable (power-profiles-daemon not running)")
+				return
+			}
+			syncing = true
+			for i, choice := range powerProfileChoices {
+				if choice.Name == active {
+					buttons[i].SetActive(true)
+				}
+			}
+			syncing = false
+			row.SetSensitive(true)
+		})
+	}()
+
+	return row
+}
+
+// readThermalSensors asks raven-ctl for the temperature sensors
+func readThermalSensors() ([]thermalSensor, error) {
+	output, err := exec.Command("raven-ctl", "thermal", "--json").Output()
+	if err != nil {
+		return nil, err
+	}
+	var sensors []thermalSensor
+	if err := json.Unmarshal(output, &sensors); err != nil {
+		return nil, err
+	}
+	return sensors, nil
+}
+
+func createSensorRow(s thermalSensor) *gtk.Box {
+	row := gtk.NewBox(gtk.OrientationHorizontal, 8)
+	row.AddCSSClass("power-row")
+
+	name := powerDetailLabel(fmt.Sprintf("%s %s", s.Chip, s.Label))
+	name.SetHExpand(true)
+	row.Append(name)
+
+	temp := gtk.NewLabel(fmt.Sprintf("%.0f°C", s.Celsius))
+	// Warn within 10% of the critical limit
+	if s.Critical > 0 && s.Celsius >= s.Critical*0.9 {
+		temp.AddCSSClass("session-warning")
+	}
+	row.Append(temp)
+	return row
+}
+
+func powerDetailLabel(text string) *gtk.Label {
+	label := gtk.NewLabel(text)
+	label.AddCSSClass("power-detail")
+	label.SetHAlign(gtk.AlignStart)
+	return label
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// batchCommands are the commands a batch file may use, so a typo is caught
+// before any line runs
+var batchCommands = map[string]bool{
+	"focus": true, "minimize": true, "restore": true, "close": true,
+	"list": true, "list-windows": true, "active": true, "get-active": true,
+	"exec": true, "switcher": true, "window-switcher": true,
+	"clipboard": true, "desktop": true, "shell": true, "power": true,
+	"thermal": true, "version": true,
+}
+
+// batchLine is a command read from a batch file
+type batchLine struct {
+	Num  int
+	Args []string
+}
+
+// runBatch handles raven-ctl --batch [file]. Every line is parsed and
+// checked before the first one runs, so a script with a mistake changes
+// nothing. Lines then run in order in this process; a failing line is
+// reported with its line number and the rest still run.
+func runBatch(args []string) error {
+	name := "-"
+	if len(args) > 0 {
+		name = args[0]
+	}
+	if len(args) > 1 {
+		return fmt.Errorf("--batch takes a single file")
+	}
+
+	var in io.Reader = os.Stdin
+	if name != "-" {
+		f, err := os.Open(name)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		in = f
+	} else {
+		name = "<stdin>"
+	}
+
+	lines, errs := parseBatch(in)
+	if len(errs) > 0 {
+		for _, err := range errs {
+			fmt.Fprintf(os.Stderr, "%s:%v\n", name, err)
+		}
+		return fmt.Errorf("%s: %d invalid line(s), nothing was run", name, len(errs))
+	}
+
+	failed := 0
+	for _, line := range lines {
+		if err := runCommand(line.Args); err != nil {
+			fmt.Fprintf(os.Stderr, "%s:%d: %s: %v\n", name, line.Num, line.Args[0], err)
+			failed++
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%s: %d of %d command(s) failed", name, failed, len(lines))
+	}
+	return nil
+}
+
+// parseBatch reads a batch file: one command per line, without the
+// raven-ctl prefix. Blank lines and lines starting with # are skipped.
+// Errors are prefixed with their line number.
+func parseBatch(r io.Reader) ([]batchLine, []error) {
+	var lines []batchLine
+	var errs []error
+
+	scanner := bufio.NewScanner(r)
+	num := 0
+	for scanner.Scan() {
+		num++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		args, err := splitWords(text)
+		if err == nil {
+			err = checkBatchCommand(args)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%d: %w", num, err))
+			continue
+		}
+		lines = append(lines, batchLine{Num: num, Args: args})
+	}
+	if err := scanner.Err(); err != nil {
+		errs = append(errs, fmt.Errorf("%d: %w", num+1, err))
+	}
+	return lines, errs
+}
+
+// checkBatchCommand catches the mistakes that can be found without running
+// a command
+func checkBatchCommand(args []string) error {
+	if args[0] == "raven-ctl" {
+		return fmt.Errorf("leave out the raven-ctl prefix")
+	}
+	if args[0] == "--batch" || args[0] == "batch" {
+		return fmt.Errorf("batch files can't run other batch files")
+	}
+	if !batchCommands[args[0]] {
+		return fmt.Errorf("%w: %s", errUnknownCommand, args[0])
+	}
+	switch args[0] {
+	case "focus", "minimize", "restore", "close":
+		_, err := parsePIDArg(args)
+		return err
+	case "clipboard", "desktop", "shell", "power":
+		if len(args) < 2 {
+			return fmt.Errorf("%s requires a subcommand", args[0])
+		}
+	}
+	return nil
+}
+
+// splitWords splits a line into words the way sh would for simple
+// commands: single quotes are literal, double quotes and backslashes
+// escape, a # starting a word begins a comment and ~/ at the start of an
+// unquoted word is the home directory
+func splitWords(line string) ([]string, error) {
+	var words []string
+	var word strings.Builder
+	inWord := false
+	tilde := false // The word starts with an unquoted ~
+	var quote rune
+	escaped := false
+
+	flush := func() {
+		w := word.String()
+		if tilde && (w == "~" || strings.HasPrefix(w, "~/")) {
+			if home, err := os.UserHomeDir(); err == nil {
+				w = home + w[1:]
+			}
+		}
+		words = append(words, w)
+		word.Reset()
+		inWord, tilde = false, false
+	}
+
+	for _, r := range line {
+		switch {
+		case escaped:
+			word.WriteRune(r)
+			escaped = false
+		case quote == '\'':
+			if r == '\'' {
+				quote = 0
+			} else {
+				word.WriteRune(r)
+			}
+		case quote == '"':
+			switch r {
+			case '"':
+				quote = 0
+			case '\\':
+				escaped = true
+			default:
+				word.WriteRune(r)
+			}
+		case r == '\\':
+			escaped, inWord = true, true
+		case r == '\'' || r == '"':
+			quote, inWord = r, true
+		case r == ' ' || r == '\t':
+			if inWord {
+				flush()
+			}
+		case r == '#' && !inWord:
+			return finishWords(words)
+		case r == '~' && !inWord:
+			word.WriteRune(r)
+			inWord, tilde = true, true
+		default:
+			word.WriteRune(r)
+			inWord = true
+		}
+	}
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated %c quote", quote)
+	}
+	if escaped {
+		return nil, fmt.Errorf("line ends with a backslash")
+	}
+	if inWord {
+		flush()
+	}
+	return finishWords(words)
+}
+
+func finishWords(words []string) ([]string, error) {
+	if len(words) == 0 {
+		return nil, fmt.Errorf("empty command")
+	}
+	return words, nil
+}
@@ -0,0 +1,222 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// Clipboard history is kept by cliphist, which the session feeds from
+// `wl-paste --watch cliphist store` (see scripts/lib/hyprland-config.sh).
+// The live selection is read and written with wl-clipboard.
+
+// ClipboardEntry represents an item in the cliphist history
+type ClipboardEntry struct {
+	ID      string
+	Preview string
+}
+
+// runClipboard handles the clipboard subcommands
+func runClipboard(args []string) error {
+	if len(args) < 1 {
+		return fmt.Errorf("clipboard requires a subcommand (get, set, list-history, clear)")
+	}
+
+	switch args[0] {
+	case "get":
+		if len(args) > 1 {
+			text, err := clipboardDecode(args[1])
+			if err != nil {
+				return err
+			}
+			fmt.Print(text)
+			return nil
+		}
+		text, err := clipboardGet()
+		if err != nil {
+			return err
+		}
+		fmt.Print(text)
+
+	case "set":
+		var text string
+		if len(args) > 1 {
+			text = strings.Join(args[1:], " ")
+		} else {
+			data, err := io.ReadAll(os.Stdin)
+			if err != nil {
+				return fmt.Errorf("failed to read stdin: %w", err)
+			}
+			text = string(data)
+		}
+		return clipboardSet(text)
+
+	case "list-history", "history":
+		limit := 0
+		if len(args) > 1 {
+			n, err := strconv.Atoi(args[1])
+			if err != nil || n < 0 {
+				return fmt.Errorf("invalid history limit '%s'", args[1])
+			}
+			limit = n
+		}
+		entries, err := clipboardHistory(limit)
+		if err != nil {
+			return err
+		}
+		for _, e := range entries {
+			fmt.Printf("%s\t%s\n", e.ID, clipboardPreview(e.Preview))
+		}
+
+	case "clear":
+		return clipboardClear()
+
+	default:
+		return fmt.Errorf("unknown clipboard subcommand: %s", args[0])
+	}
+
+	return nil
+}
+
+// lookTool finds a clipboard helper, naming the package to install if missing
+func lookTool(name, pkg string) (string, error) {
+	path, err := exec.LookPath(name)
+	if err != nil {
+		return "", fmt.Errorf("%s not found (install %s)", name, pkg)
+	}
+	return path, nil
+}
+
+// runTool runs a helper, folding its stderr into the returned error
+func runTool(cmd *exec.Cmd) ([]byte, error) {
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("%s failed: %s", cmd.Args[0], msg)
+		}
+		return nil, fmt.Errorf("%s failed: %w", cmd.Args[0], err)
+	}
+	return output, nil
+}
+
+// clipboardGet returns the current clipboard contents
+func clipboardGet() (string, error) {
+	wlPaste, err := lookTool("wl-paste", "wl-clipboard")
+	if err != nil {
+		return "", err
+	}
+	output, err := runTool(exec.Command(wlPaste, "--no-newline"))
+	if err != nil {
+		return "", err
+	}
+	return string(output), nil
+}
+
+// clipboardSet replaces the clipboard contents and records them in the
+// history. The cliphist watcher would store the new selection too, but
+// storing it directly means history works even when the watcher isn't
+// running; cliphist drops the duplicate.
+func clipboardSet(text string) error {
+	wlCopy, err := lookTool("wl-copy", "wl-clipboard")
+	if err != nil {
+		return err
+	}
+	cmd := exec.Command(wlCopy)
+	cmd.Stdin = strings.NewReader(text)
+	if _, err := runTool(cmd); err != nil {
+		return err
+	}
+
+	cliphist, err := lookTool("cliphist", "cliphist")
+	if err != nil {
+		return fmt.Errorf("clipboard set, but not recorded in history: %w", err)
+	}
+	store := exec.Command(cliphist, "store")
+	store.Stdin = strings.NewReader(text)
+	if _, err := runTool(store); err != nil {
+		return fmt.Errorf("clipboard set, but not recorded in history: %w", err)
+	}
+	return nil
+}
+
+// clipboardHistory returns the stored history, newest first
+func clipboardHistory(limit int) ([]ClipboardEntry, error) {
+	cliphist, err := lookTool("cliphist", "cliphist")
+	if err != nil {
+		return nil, err
+	}
+	output, err := runTool(exec.Command(cliphist, "list"))
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []ClipboardEntry
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		id, preview, ok := strings.Cut(scanner.Text(), "\t")
+		if !ok {
+			continue
+		}
+		entries = append(entries, ClipboardEntry{ID: id, Preview: preview})
+		if limit > 0 && len(entries) == limit {
+			break
+		}
+	}
+	return entries, scanner.Err()
+}
+
+// clipboardDecode returns the full contents of a history entry by ID
+func clipboardDecode(id string) (string, error) {
+	if _, err := strconv.Atoi(id); err != nil {
+		return "", fmt.Errorf("invalid history id '%s'", id)
+	}
+	cliphist, err := lookTool("cliphist", "cliphist")
+	if err != nil {
+		return "", err
+	}
+	// cliphist decode takes a line from `cliphist list` on stdin
+	cmd := exec.Command(cliphist, "decode")
+	cmd.Stdin = strings.NewReader(id + "\t\n")
+	output, err := runTool(cmd)
+	if err != nil {
+		return "", err
+	}
+	return string(output), nil
+}
+
+// clipboardClear clears the live selection and wipes the stored history
+func clipboardClear() error {
+	wlCopy, err := lookTool("wl-copy", "wl-clipboard")
+	if err != nil {
+		return err
+	}
+	if _, err := runTool(exec.Command(wlCopy, "--clear")); err != nil {
+		return err
+	}
+
+	cliphist, err := lookTool("cliphist", "cliphist")
+	if err != nil {
+		return fmt.Errorf("clipboard cleared, but history was not wiped: %w", err)
+	}
+	if _, err := runTool(exec.Command(cliphist, "wipe")); err != nil {
+		return fmt.Errorf("clipboard cleared, but history was not wiped: %w", err)
+	}
+	return nil
+}
+
+// clipboardPreview returns a single-line, truncated version of an entry
+func clipboardPreview(text string) string {
+	text = strings.Join(strings.Fields(text), " ")
+	if runes := []rune(text); len(runes) > 72 {
+		text = string(runes[:69]) + "..."
+	}
+	return text
+}
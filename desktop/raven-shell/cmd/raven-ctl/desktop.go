@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	desktopSocketName = "raven-desktop.sock"
+
+	// raven-desktop waits up to 5s for its main loop before answering, so
+	// give it that plus time for the socket I/O
+	desktopTimeout = 7 * time.Second
+)
+
+// desktopWallpaperModes are the modes raven-desktop can render
+var desktopWallpaperModes = []string{"fill", "fit", "stretch", "center", "tile"}
+
+// runDesktop handles the desktop subcommands, which talk to raven-desktop
+// over its control socket
+func runDesktop(args []string) error {
+	if len(args) < 1 {
+		return fmt.Errorf("desktop requires a subcommand (show-launcher, show-pin-dialog, set-wallpaper, refresh, methods)")
+	}
+
+	cmd := Command{}
+	switch args[0] {
+	case "show-launcher", "launcher":
+		cmd.Action = "show-launcher"
+
+	case "show-pin-dialog", "pin":
+		cmd.Action = "show-pin-dialog"
+
+	case "set-wallpaper", "wallpaper":
+		if len(args) < 2 {
+			return fmt.Errorf("set-wallpaper requires a path")
+		}
+		path, err := filepath.Abs(args[1])
+		if err != nil {
+			return err
+		}
+		cmd.Action = "set-wallpaper"
+		cmd.Path = path
+		if len(args) > 2 {
+			cmd.Mode = args[2]
+			if !slices.Contains(desktopWallpaperModes, cmd.Mode) {
+				return fmt.Errorf("invalid wallpaper mode '%s' (use %s)", cmd.Mode, strings.Join(desktopWallpaperModes, ", "))
+			}
+		}
+
+	case "refresh":
+		cmd.Action = "refresh"
+
+	case "methods":
+		cmd.Action = "help"
+
+	default:
+		return fmt.Errorf("unknown desktop subcommand: %s", args[0])
+	}
+
+	resp, err := sendCommandTimeout(getRuntimeSocketPath(desktopSocketName), "raven-desktop", cmd, desktopTimeout)
+	if err != nil {
+		return err
+	}
+	if !resp.Success {
+		return fmt.Errorf("%s", resp.Error)
+	}
+
+	if methods, ok := resp.Data.([]any); ok {
+		names := make([]string, 0, len(methods))
+		for _, m := range methods {
+			if name, ok := m.(string); ok {
+				names = append(names, name)
+			}
+		}
+		fmt.Println(strings.Join(names, "\n"))
+	}
+	return nil
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// How long exec waits for a Sway window to appear before giving up
+const execWindowTimeout = 10 * time.Second
+
+// execRules are the one-shot window rules for raven-ctl exec
+type execRules struct {
+	Workspace string // Name or number, "" to leave the window where it opens
+	Silent    bool   // Don't switch to Workspace
+	Float     bool
+	Width     int // 0 for the app's own size
+	Height    int
+}
+
+// runExec handles raven-ctl exec [options] <command>
+func runExec(args []string) error {
+	var rules execRules
+	for len(args) > 0 && strings.HasPrefix(args[0], "--") {
+		opt := args[0]
+		args = args[1:]
+		if opt == "--" {
+			break
+		}
+
+		switch opt {
+		case "--workspace":
+			if len(args) < 1 {
+				return fmt.Errorf("--workspace requires a workspace")
+			}
+			rules.Workspace = args[0]
+			args = args[1:]
+		case "--silent":
+			rules.Silent = true
+		case "--float":
+			rules.Float = true
+		case "--size":
+			if len(args) < 1 {
+				return fmt.Errorf("--size requires WIDTHxHEIGHT")
+			}
+			width, height, err := parseSize(args[0])
+			if err != nil {
+				return err
+			}
+			// Tiled windows ignore a size, so it implies floating
+			rules.Width, rules.Height, rules.Float = width, height, true
+			args = args[1:]
+		default:
+			return fmt.Errorf("unknown exec option: %s", opt)
+		}
+	}
+	if len(args) < 1 {
+		return fmt.Errorf("exec requires a command")
+	}
+
+	// A single argument is a command line; several are quoted so each
+	// reaches the program as one argument
+	command := args[0]
+	if len(args) > 1 {
+		quoted := make([]string, len(args))
+		for i, arg := range args {
+			quoted[i] = shellQuote(arg)
+		}
+		command = strings.Join(quoted, " ")
+	}
+
+	if os.Getenv("HYPRLAND_INSTANCE_SIGNATURE") != "" {
+		if hyprctl, err := exec.LookPath("hyprctl"); err == nil {
+			return execHyprland(hyprctl, command, rules)
+		}
+	}
+	if os.Getenv("SWAYSOCK") != "" {
+		if swaymsg, err := exec.LookPath("swaymsg"); err == nil {
+			return execSway(swaymsg, command, rules)
+		}
+	}
+
+	if _, err := startDetached(command); err != nil {
+		return err
+	}
+	if rules != (execRules{}) {
+		fmt.Fprintln(os.Stderr, "Warning: no supported compositor, window rules were not applied")
+	}
+	return nil
+}
+
+// parseSize parses WIDTHxHEIGHT
+func parseSize(size string) (int, int, error) {
+	w, h, ok := strings.Cut(size, "x")
+	width, errW := strconv.Atoi(w)
+	height, errH := strconv.Atoi(h)
+	if !ok || errW != nil || errH != nil || width <= 0 || height <= 0 {
+		return 0, 0, fmt.Errorf("invalid size '%s' (expected WIDTHxHEIGHT, e.g. 800x600)", size)
+	}
+	return width, height, nil
+}
+
+// execHyprland launches command through Hyprland, which applies rules
+// in brackets to the first window of the process it starts
+func execHyprland(hyprctl, command string, rules execRules) error {
+	var list []string
+	if rules.Workspace != "" {
+		rule := "workspace " + rules.Workspace
+		if rules.Silent {
+			rule += " silent"
+		}
+		list = append(list, rule)
+	}
+	if rules.Float {
+		list = append(list, "float")
+	}
+	if rules.Width > 0 {
+		list = append(list, fmt.Sprintf("size %d %d", rules.Width, rules.Height))
+	}
+
+	if len(list) > 0 {
+		command = "[" + strings.Join(list, "; ") + "] " + command
+	}
+	_, err := runTool(exec.Command(hyprctl, "dispatch", "exec", command))
+	return err
+}
+
+// execSway starts command and, as Sway has no rules for a single launch,
+// waits for its window and applies them to it by PID
+func execSway(swaymsg, command string, rules execRules) error {
+	pid, err := startDetached(command)
+	if err != nil || rules == (execRules{}) {
+		return err
+	}
+
+	var commands []string
+	if rules.Workspace != "" {
+		commands = append(commands, "move container to workspace "+rules.Workspace)
+	}
+	if rules.Float {
+		commands = append(commands, "floating enable")
+	}
+	if rules.Width > 0 {
+		commands = append(commands, fmt.Sprintf("resize set %d %d", rules.Width, rules.Height))
+	}
+
+	deadline := time.Now().Add(execWindowTimeout)
+	for time.Now().Before(deadline) {
+		windows, _ := listWindowsSwaymsg()
+		for _, w := range windows {
+			if w.PID != pid {
+				continue
+			}
+			selector := fmt.Sprintf("[pid=%d]", pid)
+			if _, err := runTool(exec.Command(swaymsg, selector, strings.Join(commands, ", "))); err != nil {
+				return err
+			}
+			if rules.Workspace != "" && !rules.Silent {
+				_, err := runTool(exec.Command(swaymsg, "workspace", rules.Workspace))
+				return err
+			}
+			return nil
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	return fmt.Errorf("no window appeared for PID %d, window rules were not applied", pid)
+}
+
+// startDetached runs command in its own session so it outlives raven-ctl,
+// returning its PID
+func startDetached(command string) (int, error) {
+	// exec makes the program take over the shell's PID, so rules by PID
+	// find its window
+	cmd := exec.Command("sh", "-c", "exec "+command)
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+	if err := cmd.Start(); err != nil {
+		return 0, fmt.Errorf("failed to start %s: %w", command, err)
+	}
+	pid := cmd.Process.Pid
+	cmd.Process.Release()
+	return pid, nil
+}
+
+// shellQuote quotes s as a single sh word
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net"
 	"os"
@@ -23,6 +24,8 @@ type Command struct {
 	Action string `json:"action"`
 	PID    int    `json:"pid,omitempty"`
 	WinID  string `json:"win_id,omitempty"`
+	Path   string `json:"path,omitempty"`
+	Mode   string `json:"mode,omitempty"`
 }
 
 // Response represents an IPC response from the compositor
@@ -43,82 +46,69 @@ type WindowInfo struct {
 }
 
 func main() {
+	// Installed as raven-shellctl, raven-ctl only runs shell commands
+	if filepath.Base(os.Args[0]) == "raven-shellctl" {
+		if err := runShell(os.Args[1:]); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	if len(os.Args) < 2 {
 		printUsage()
 		os.Exit(1)
 	}
 
-	cmd := os.Args[1]
-
-	switch cmd {
-	case "help", "-h", "--help":
-		printUsage()
-
-	case "focus":
-		if len(os.Args) < 3 {
-			fmt.Fprintln(os.Stderr, "Error: focus requires a PID argument")
-			os.Exit(1)
-		}
-		pid, err := strconv.Atoi(os.Args[2])
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "Error: invalid PID '%s'\n", os.Args[2])
-			os.Exit(1)
-		}
-		if err := focusWindow(pid); err != nil {
+	if os.Args[1] == "--batch" || os.Args[1] == "batch" {
+		if err := runBatch(os.Args[2:]); err != nil {
 			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 			os.Exit(1)
 		}
+		return
+	}
 
-	case "minimize":
-		if len(os.Args) < 3 {
-			fmt.Fprintln(os.Stderr, "Error: minimize requires a PID argument")
-			os.Exit(1)
-		}
-		pid, err := strconv.Atoi(os.Args[2])
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "Error: invalid PID '%s'\n", os.Args[2])
-			os.Exit(1)
-		}
-		if err := minimizeWindow(pid); err != nil {
-			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-			os.Exit(1)
+	if err := runCommand(os.Args[1:]); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		if errors.Is(err, errUnknownCommand) {
+			printUsage()
 		}
+		os.Exit(1)
+	}
+}
 
-	case "restore":
-		if len(os.Args) < 3 {
-			fmt.Fprintln(os.Stderr, "Error: restore requires a PID argument")
-			os.Exit(1)
-		}
-		pid, err := strconv.Atoi(os.Args[2])
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "Error: invalid PID '%s'\n", os.Args[2])
-			os.Exit(1)
-		}
-		if err := restoreWindow(pid); err != nil {
-			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-			os.Exit(1)
-		}
+// errUnknownCommand is returned by runCommand for a command it doesn't know
+var errUnknownCommand = errors.New("unknown command")
 
-	case "close":
-		if len(os.Args) < 3 {
-			fmt.Fprintln(os.Stderr, "Error: close requires a PID argument")
-			os.Exit(1)
-		}
-		pid, err := strconv.Atoi(os.Args[2])
+// runCommand runs one raven-ctl command, args[0] being its name. It is
+// used for the command line and for every line of a batch file.
+func runCommand(args []string) error {
+	cmd := args[0]
+
+	switch cmd {
+	case "help", "-h", "--help":
+		printUsage()
+
+	case "focus", "minimize", "restore", "close":
+		pid, err := parsePIDArg(args)
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "Error: invalid PID '%s'\n", os.Args[2])
-			os.Exit(1)
+			return err
 		}
-		if err := closeWindow(pid); err != nil {
-			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-			os.Exit(1)
+		switch cmd {
+		case "focus":
+			return focusWindow(pid)
+		case "minimize":
+			return minimizeWindow(pid)
+		case "restore":
+			return restoreWindow(pid)
+		default:
+			return closeWindow(pid)
 		}
 
 	case "list", "list-windows":
 		windows, err := listWindows()
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-			os.Exit(1)
+			return err
 		}
 		for _, w := range windows {
 			status := ""
@@ -133,21 +123,53 @@ func main() {
 	case "active", "get-active":
 		window, err := getActiveWindow()
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-			os.Exit(1)
+			return err
 		}
 		if window != nil {
 			fmt.Printf("%d\t%s\t%s\n", window.PID, window.AppID, window.Title)
 		}
 
+	case "exec":
+		return runExec(args[1:])
+
+	case "switcher", "window-switcher":
+		_, err := startDetached("raven-menu --windows")
+		return err
+
+	case "clipboard":
+		return runClipboard(args[1:])
+
+	case "desktop":
+		return runDesktop(args[1:])
+
+	case "shell":
+		return runShell(args[1:])
+
+	case "power":
+		return runPower(args[1:])
+
+	case "thermal":
+		return runThermal(args[1:])
+
 	case "version", "-v", "--version":
 		fmt.Println("raven-ctl version 0.1.0")
 
 	default:
-		fmt.Fprintf(os.Stderr, "Unknown command: %s\n", cmd)
-		printUsage()
-		os.Exit(1)
+		return fmt.Errorf("%w: %s", errUnknownCommand, cmd)
+	}
+	return nil
+}
+
+// parsePIDArg returns the PID argument of focus, minimize, restore and close
+func parsePIDArg(args []string) (int, error) {
+	if len(args) < 2 {
+		return 0, fmt.Errorf("%s requires a PID argument", args[0])
+	}
+	pid, err := strconv.Atoi(args[1])
+	if err != nil {
+		return 0, fmt.Errorf("invalid PID '%s'", args[1])
 	}
+	return pid, nil
 }
 
 func printUsage() {
@@ -163,13 +185,43 @@ Commands:
   close <pid>       Close the window (graceful termination)
   list              List all windows
   active            Get the currently active window
+  exec [--workspace <ws>] [--silent] [--float] [--size WxH] <command>
+                    Launch an app with rules for its first window
+  switcher          Open the window switcher (raven-menu --windows)
+  clipboard get [id]
+                    Print the clipboard, or a history entry by id
+  clipboard set [text]
+                    Set the clipboard (reads stdin when no text is given)
+  clipboard list-history [n]
+                    List the cliphist history (newest first)
+  clipboard clear   Clear the clipboard and its history
+  desktop show-launcher
+                    Open the desktop fuzzy finder
+  desktop show-pin-dialog
+                    Open the fuzzy finder to pin an app to the desktop
+  desktop set-wallpaper <path> [mode]
+                    Set the wallpaper (fill, fit, stretch, center, tile)
+  desktop refresh   Reload desktop icons
+  desktop methods   List the actions raven-desktop supports
+  shell dump-state  Print a JSON snapshot of the panel, dock and popups
+  shell methods     List the actions raven-shell supports
+  power profile get Print the active power profile
+  power profile set <performance|balanced|power-saver>
+                    Switch the power profile (power-profiles-daemon)
+  thermal [--json]  Show temperature sensors from hwmon
+  --batch [file]    Run the commands in a file, one per line (stdin when
+                    no file or - is given)
   version           Print version information
   help              Print this help message
 
 Examples:
   raven-ctl focus 12345
   raven-ctl minimize 12345
-  raven-ctl list`)
+  raven-ctl list
+  raven-ctl exec --workspace 3 --float --size 800x600 raven-terminal
+  echo hello | raven-ctl clipboard set
+  raven-ctl desktop set-wallpaper ~/Pictures/sky.png fill
+  raven-ctl --batch ~/.config/raven/session.rvn`)
 }
 
 // capitalizeFirst capitalizes the first letter of a string
@@ -180,26 +232,40 @@ func capitalizeFirst(s string) string {
 	return strings.ToUpper(s[:1]) + s[1:]
 }
 
-// getSocketPath returns the path to the compositor socket
-func getSocketPath() string {
+// getRuntimeSocketPath returns the path to a socket in the user runtime dir
+func getRuntimeSocketPath(name string) string {
 	runtimeDir := os.Getenv("XDG_RUNTIME_DIR")
 	if runtimeDir == "" {
 		runtimeDir = fmt.Sprintf("/run/user/%d", os.Getuid())
 	}
-	return filepath.Join(runtimeDir, socketName)
+	return filepath.Join(runtimeDir, name)
+}
+
+// getSocketPath returns the path to the compositor socket
+func getSocketPath() string {
+	return getRuntimeSocketPath(socketName)
 }
 
 // sendCommand sends a command to the compositor via IPC
 func sendCommand(cmd Command) (*Response, error) {
-	socketPath := getSocketPath()
+	return sendCommandTo(getSocketPath(), "compositor", cmd)
+}
+
+// sendCommandTo sends a command to a Raven service listening on socketPath
+func sendCommandTo(socketPath, service string, cmd Command) (*Response, error) {
+	return sendCommandTimeout(socketPath, service, cmd, socketTimeout)
+}
 
-	conn, err := net.DialTimeout("unix", socketPath, socketTimeout)
+// sendCommandTimeout is sendCommandTo with a custom deadline, for services
+// that may take longer than socketTimeout to reply
+func sendCommandTimeout(socketPath, service string, cmd Command, timeout time.Duration) (*Response, error) {
+	conn, err := net.DialTimeout("unix", socketPath, timeout)
 	if err != nil {
-		return nil, fmt.Errorf("compositor not available: %w", err)
+		return nil, fmt.Errorf("%s not available: %w", service, err)
 	}
 	defer conn.Close()
 
-	conn.SetDeadline(time.Now().Add(socketTimeout))
+	conn.SetDeadline(time.Now().Add(timeout))
 
 	encoder := json.NewEncoder(conn)
 	if err := encoder.Encode(cmd); err != nil {
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// powerProfiles are the profiles power-profiles-daemon knows about
+var powerProfiles = []string{"performance", "balanced", "power-saver"}
+
+// Sensor is one temperature reading from hwmon or a thermal zone
+type Sensor struct {
+	Chip     string  `json:"chip"`
+	Label    string  `json:"label"`
+	Celsius  float64 `json:"celsius"`
+	Critical float64 `json:"critical,omitempty"` // 0 when the chip gives no limit
+}
+
+// runPower handles the power subcommands
+func runPower(args []string) error {
+	if len(args) < 1 || args[0] != "profile" {
+		return fmt.Errorf("power requires a subcommand (profile get, profile set <profile>)")
+	}
+	args = args[1:]
+	if len(args) < 1 {
+		return fmt.Errorf("power profile requires get or set")
+	}
+
+	switch args[0] {
+	case "get":
+		profile, err := getPowerProfile()
+		if err != nil {
+			return err
+		}
+		fmt.Println(profile)
+
+	case "set":
+		if len(args) < 2 {
+			return fmt.Errorf("power profile set requires a profile (%s)", strings.Join(powerProfiles, ", "))
+		}
+		return setPowerProfile(args[1])
+
+	default:
+		return fmt.Errorf("unknown power profile subcommand: %s", args[0])
+	}
+	return nil
+}
+
+// getPowerProfile returns the active power-profiles-daemon profile
+func getPowerProfile() (string, error) {
+	ctl, err := lookTool("powerprofilesctl", "power-profiles-daemon")
+	if err != nil {
+		return "", err
+	}
+	output, err := runTool(exec.Command(ctl, "get"))
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+// setPowerProfile switches the active profile
+func setPowerProfile(profile string) error {
+	if !slices.Contains(powerProfiles, profile) {
+		return fmt.Errorf("unknown power profile '%s' (%s)", profile, strings.Join(powerProfiles, ", "))
+	}
+
+	ctl, err := lookTool("powerprofilesctl", "power-profiles-daemon")
+	if err != nil {
+		return err
+	}
+	// The daemon rejects profiles the hardware doesn't offer; runTool
+	// passes its message on
+	_, err = runTool(exec.Command(ctl, "set", profile))
+	return err
+}
+
+// runThermal prints the temperature sensors, as JSON with --json
+func runThermal(args []string) error {
+	sensors, err := readSensors()
+	if err != nil {
+		return err
+	}
+
+	if len(args) > 0 && args[0] == "--json" {
+		return json.NewEncoder(os.Stdout).Encode(sensors)
+	}
+
+	for _, s := range sensors {
+		line := fmt.Sprintf("%-12s %-16s %5.1f°C", s.Chip, s.Label, s.Celsius)
+		if s.Critical > 0 {
+			line += fmt.Sprintf("  (critical %.0f°C)", s.Critical)
+		}
+		fmt.Println(line)
+	}
+	return nil
+}
+
+// readSensors reads every hwmon temperature input. Machines without hwmon
+// temperature chips fall back to the ACPI thermal zones.
+func readSensors() ([]Sensor, error) {
+	sensors := readHwmonSensors()
+	if len(sensors) == 0 {
+		sensors = readThermalZones()
+	}
+	if len(sensors) == 0 {
+		return nil, fmt.Errorf("no temperature sensors found in /sys/class/hwmon or /sys/class/thermal")
+	}
+	return sensors, nil
+}
+
+func readHwmonSensors() []Sensor {
+	chips, _ := filepath.Glob("/sys/class/hwmon/hwmon*")
+	sort.Strings(chips)
+
+	var sensors []Sensor
+	for _, chip := range chips {
+		name := readSysString(filepath.Join(chip, "name"))
+		inputs, _ := filepath.Glob(filepath.Join(chip, "temp*_input"))
+		sort.Strings(inputs)
+
+		for _, input := range inputs {
+			millis, ok := readSysInt(input)
+			if !ok {
+				continue
+			}
+			prefix := strings.TrimSuffix(input, "_input")
+
+			label := readSysString(prefix + "_label")
+			if label == "" {
+				label = filepath.Base(prefix)
+			}
+			sensor := Sensor{Chip: name, Label: label, Celsius: float64(millis) / 1000}
+			if crit, ok := readSysInt(prefix + "_crit"); ok && crit > 0 {
+				sensor.Critical = float64(crit) / 1000
+			}
+			sensors = append(sensors, sensor)
+		}
+	}
+	return sensors
+}
+
+func readThermalZones() []Sensor {
+	zones, _ := filepath.Glob("/sys/class/thermal/thermal_zone*")
+	sort.Strings(zones)
+
+	var sensors []Sensor
+	for _, zone := range zones {
+		millis, ok := readSysInt(filepath.Join(zone, "temp"))
+		if !ok {
+			continue
+		}
+		sensor := Sensor{
+			Chip:    "thermal",
+			Label:   readSysString(filepath.Join(zone, "type")),
+			Celsius: float64(millis) / 1000,
+		}
+		// Trip points are listed by type; "critical" is the shutdown limit
+		trips, _ := filepath.Glob(filepath.Join(zone, "trip_point_*_type"))
+		for _, trip := range trips {
+			if readSysString(trip) != "critical" {
+				continue
+			}
+			if crit, ok := readSysInt(strings.TrimSuffix(trip, "_type") + "_temp"); ok && crit > 0 {
+				sensor.Critical = float64(crit) / 1000
+			}
+		}
+		sensors = append(sensors, sensor)
+	}
+	return sensors
+}
+
+func readSysString(path string) string {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
+
+func readSysInt(path string) (int64, bool) {
+	n, err := strconv.ParseInt(readSysString(path), 10, 64)
+	return n, err == nil
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	shellSocketName = "raven-shell.sock"
+
+	// raven-shell waits up to 5s for its main loop before answering
+	shellTimeout = 7 * time.Second
+)
+
+// runShell handles the shell subcommands, which talk to raven-shell over
+// its control socket. raven-ctl installed as raven-shellctl runs these
+// directly: raven-shellctl dump-state.
+func runShell(args []string) error {
+	if len(args) < 1 {
+		return fmt.Errorf("shell requires a subcommand (dump-state, methods)")
+	}
+
+	cmd := Command{}
+	switch args[0] {
+	case "dump-state", "state":
+		cmd.Action = "dump-state"
+
+	case "methods":
+		cmd.Action = "help"
+
+	default:
+		return fmt.Errorf("unknown shell subcommand: %s", args[0])
+	}
+
+	resp, err := sendCommandTimeout(getRuntimeSocketPath(shellSocketName), "raven-shell", cmd, shellTimeout)
+	if err != nil {
+		return err
+	}
+	if !resp.Success {
+		return fmt.Errorf("%s", resp.Error)
+	}
+
+	if cmd.Action == "help" {
+		if methods, ok := resp.Data.([]any); ok {
+			names := make([]string, 0, len(methods))
+			for _, m := range methods {
+				if name, ok := m.(string); ok {
+					names = append(names, name)
+				}
+			}
+			fmt.Println(strings.Join(names, "\n"))
+		}
+		return nil
+	}
+
+	data, err := json.MarshalIndent(resp.Data, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(data))
+	return nil
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"time"
+
+	"github.com/diamondburned/gotk4/pkg/glib/v2"
+)
+
+// How often dock.json is checked for pins made by other components
+const dockConfigPollSeconds = 2
+
+// watchDockConfig polls dock.json so apps pinned or unpinned from
+// raven-menu show up in the dock without restarting the shell
+func (p *RavenPanel) watchDockConfig() {
+	var lastMod time.Time
+	if info, err := os.Stat(p.configPath); err == nil {
+		lastMod = info.ModTime()
+	}
+
+	glib.TimeoutSecondsAdd(dockConfigPollSeconds, func() bool {
+		info, err := os.Stat(p.configPath)
+		if err != nil || !info.ModTime().After(lastMod) {
+			return true
+		}
+		lastMod = info.ModTime()
+
+		if p.syncPinnedApps() {
+			p.renderDock()
+		}
+		return true
+	})
+}
+
+// syncPinnedApps applies the pinned list in dock.json to the dock items
+// and reports whether anything changed. Our own saveConfig writes land
+// here too and change nothing.
+func (p *RavenPanel) syncPinnedApps() bool {
+	data, err := os.ReadFile(p.configPath)
+	if err != nil {
+		return false
+	}
+	var config PanelConfig
+	if err := json.Unmarshal(data, &config); err != nil {
+		return false
+	}
+
+	pinned := make(map[string]DockItem, len(config.PinnedApps))
+	for _, item := range config.PinnedApps {
+		pinned[item.ID] = item
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	changed := false
+	for id, item := range p.dockItems {
+		if _, ok := pinned[id]; item.Pinned && !ok {
+			item.Pinned = false
+			if !item.Running {
+				delete(p.dockItems, id)
+			}
+			changed = true
+		}
+	}
+	for id, item := range pinned {
+		if existing, ok := p.dockItems[id]; ok {
+			if !existing.Pinned {
+				existing.Pinned = true
+				changed = true
+			}
+			continue
+		}
+		itemCopy := item
+		itemCopy.Pinned = true
+		p.dockItems[id] = &itemCopy
+		changed = true
+	}
+	return changed
+}
@@ -0,0 +1,248 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/diamondburned/gotk4/pkg/glib/v2"
+)
+
+const (
+	// How long a window's process must look stuck before it is flagged
+	hangThreshold = 6 * time.Second
+
+	// Grace period between SIGTERM and SIGKILL when force closing
+	forceKillDelay = 3
+)
+
+// procSample is the last reading for a window's process
+type procSample struct {
+	queued       uint32 // Unread bytes on its Wayland connection
+	at           time.Time
+	suspectSince time.Time
+	hung         bool
+}
+
+// hangDetector looks for windowed processes that have stopped servicing
+// their event loop. High CPU alone is not a hang (games, encoders and
+// software rendering all peg a core), so a process is only suspect while
+// it is stopped, stuck in uninterruptible sleep, or leaving compositor
+// messages (input, frame callbacks, xdg_wm_base pings) unread on its
+// Wayland socket without draining them between polls.
+type hangDetector struct {
+	samples map[int]*procSample
+
+	waylandPath string            // Compositor socket clients connect to
+	queues      map[uint64]uint32 // Client socket inode -> unread bytes
+	queuesAt    time.Time
+}
+
+func newHangDetector() *hangDetector {
+	return &hangDetector{
+		samples:     make(map[int]*procSample),
+		waylandPath: waylandSocketPath(),
+	}
+}
+
+// waylandSocketPath returns the compositor socket from WAYLAND_DISPLAY
+func waylandSocketPath() string {
+	display := os.Getenv("WAYLAND_DISPLAY")
+	if display == "" {
+		display = "wayland-0"
+	}
+	if filepath.IsAbs(display) {
+		return display
+	}
+	return filepath.Join(os.Getenv("XDG_RUNTIME_DIR"), display)
+}
+
+// check samples pid and reports whether it has been suspect for longer
+// than hangThreshold
+func (h *hangDetector) check(pid int, now time.Time) bool {
+	if pid <= 0 {
+		return false
+	}
+
+	sample, ok := h.samples[pid]
+	if ok && now.Equal(sample.at) {
+		// Another window of the same process in this poll
+		return sample.hung
+	}
+
+	state, err := readThreadState(pid)
+	if err != nil {
+		delete(h.samples, pid)
+		return false
+	}
+	queued, hasSocket := h.waylandQueue(pid, now)
+	if !ok {
+		h.samples[pid] = &procSample{queued: queued, at: now}
+		return false
+	}
+
+	suspect := state == "T" || state == "D"
+	if hasSocket && queued > 0 && queued >= sample.queued {
+		// Messages are waiting and nothing was read since the last poll
+		suspect = true
+	}
+
+	sample.queued = queued
+	sample.at = now
+	if !suspect {
+		sample.suspectSince = time.Time{}
+	} else if sample.suspectSince.IsZero() {
+		sample.suspectSince = now
+	}
+	sample.hung = suspect && now.Sub(sample.suspectSince) >= hangThreshold
+	return sample.hung
+}
+
+// waylandQueue returns the unread bytes on pid's Wayland connection. The
+// socket table is dumped once per poll and shared by every window.
+func (h *hangDetector) waylandQueue(pid int, now time.Time) (uint32, bool) {
+	if !h.queuesAt.Equal(now) {
+		h.queuesAt = now
+		// Without unix_diag only the process state check applies
+		h.queues, _ = waylandClientQueues(h.waylandPath)
+	}
+	if len(h.queues) == 0 {
+		return 0, false
+	}
+
+	fds, err := os.ReadDir(fmt.Sprintf("/proc/%d/fd", pid))
+	if err != nil {
+		return 0, false
+	}
+	for _, fd := range fds {
+		target, err := os.Readlink(fmt.Sprintf("/proc/%d/fd/%s", pid, fd.Name()))
+		if err != nil || !strings.HasPrefix(target, "socket:[") {
+			continue
+		}
+		inode, err := strconv.ParseUint(strings.TrimSuffix(strings.TrimPrefix(target, "socket:["), "]"), 10, 64)
+		if err != nil {
+			continue
+		}
+		if queued, ok := h.queues[inode]; ok {
+			return queued, true
+		}
+	}
+	return 0, false
+}
+
+// prune drops samples for processes no longer backing a window
+func (h *hangDetector) prune(alive map[int]bool) {
+	for pid := range h.samples {
+		if !alive[pid] {
+			delete(h.samples, pid)
+		}
+	}
+}
+
+// readThreadState returns the scheduler state of a process's main thread
+func readThreadState(pid int) (string, error) {
+	fields, err := readStatFields(fmt.Sprintf("/proc/%d/task/%d/stat", pid, pid))
+	if err != nil {
+		return "", err
+	}
+	return fields[0], nil
+}
+
+// processStartTime returns when pid started, in clock ticks since boot.
+// Together with the PID it identifies a process across PID reuse.
+func processStartTime(pid int) (uint64, error) {
+	fields, err := readStatFields(fmt.Sprintf("/proc/%d/stat", pid))
+	if err != nil {
+		return 0, err
+	}
+	if len(fields) < 20 {
+		return 0, fmt.Errorf("short stat for pid %d", pid)
+	}
+	// starttime is the 22nd stat field
+	return strconv.ParseUint(fields[19], 10, 64)
+}
+
+// readStatFields returns the fields of a /proc stat file following the
+// command name, so fields[0] is the state (the 3rd stat field)
+func readStatFields(path string) ([]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	// The command name may contain spaces; fields resume after the last ')'
+	stat := string(data)
+	end := strings.LastIndexByte(stat, ')')
+	if end < 0 {
+		return nil, fmt.Errorf("malformed %s", path)
+	}
+	fields := strings.Fields(stat[end+1:])
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("malformed %s", path)
+	}
+	return fields, nil
+}
+
+// setNotResponding updates a dock item's hang flag and redraws on change
+func (p *RavenPanel) setNotResponding(item *DockItem, hung bool) {
+	p.mu.Lock()
+	changed := item.NotResponding != hung
+	item.NotResponding = hung
+	if !hung {
+		item.forceCloseStage = 0
+	}
+	p.mu.Unlock()
+
+	if changed {
+		glib.IdleAdd(func() {
+			p.renderDock()
+		})
+	}
+}
+
+// forceClose escalates against a hung app: the first request sends SIGTERM
+// and schedules SIGKILL if the process is still around after forceKillDelay;
+// a second request kills it immediately. Both kills are skipped once the PID
+// belongs to another process.
+func (p *RavenPanel) forceClose(item *DockItem) {
+	p.mu.Lock()
+	pid := item.PID
+	stage := item.forceCloseStage
+	recorded := item.forceCloseStart
+	item.forceCloseStage++
+	p.mu.Unlock()
+
+	if pid <= 0 {
+		return
+	}
+
+	if stage > 0 {
+		if now, err := processStartTime(pid); err == nil && now == recorded {
+			syscall.Kill(pid, syscall.SIGKILL)
+		}
+		return
+	}
+
+	started, err := processStartTime(pid)
+	if err != nil {
+		return
+	}
+	p.mu.Lock()
+	item.forceCloseStart = started
+	p.mu.Unlock()
+	if err := syscall.Kill(pid, syscall.SIGTERM); err != nil {
+		return
+	}
+	glib.TimeoutSecondsAdd(forceKillDelay, func() bool {
+		// Only kill if the PID still belongs to the same process; it may
+		// have exited and been reused in the meantime
+		if now, err := processStartTime(pid); err == nil && now == started {
+			syscall.Kill(pid, syscall.SIGKILL)
+		}
+		return false
+	})
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/diamondburned/gotk4/pkg/glib/v2"
+)
+
+const (
+	shellSocketName = "raven-shell.sock"
+
+	// How long a command may wait for the main loop. raven-ctl's deadline
+	// is longer, so a slow shell still gets its reply through.
+	ipcTimeout = 5 * time.Second
+)
+
+// ShellCommand is a request sent to the shell control socket
+type ShellCommand struct {
+	Action string `json:"action"`
+}
+
+// ShellResponse is the reply to a ShellCommand
+type ShellResponse struct {
+	Success bool   `json:"success"`
+	Error   string `json:"error,omitempty"`
+	Data    any    `json:"data,omitempty"`
+}
+
+// shellMethods lists the supported actions, returned by "help"
+var shellMethods = []string{
+	"dump-state",
+	"help",
+}
+
+// getShellSocketPath returns the control socket path in the runtime dir
+func getShellSocketPath() string {
+	runtimeDir := os.Getenv("XDG_RUNTIME_DIR")
+	if runtimeDir == "" {
+		runtimeDir = fmt.Sprintf("/run/user/%d", os.Getuid())
+	}
+	return filepath.Join(runtimeDir, shellSocketName)
+}
+
+// startIPCServer listens on the control socket for raven-ctl shell
+func (p *RavenPanel) startIPCServer() error {
+	socketPath := getShellSocketPath()
+
+	// Refuse to steal the socket from another running shell, but clean up
+	// one left behind by a crash
+	if conn, err := net.DialTimeout("unix", socketPath, time.Second); err == nil {
+		conn.Close()
+		return fmt.Errorf("another raven-shell is listening on %s", socketPath)
+	}
+	os.Remove(socketPath)
+
+	listener, err := net.Listen("unix", socketPath)
+	if err != nil {
+		return err
+	}
+	os.Chmod(socketPath, 0600)
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go p.handleIPCConn(conn)
+		}
+	}()
+
+	p.app.ConnectShutdown(func() {
+		listener.Close()
+		os.Remove(socketPath)
+	})
+
+	return nil
+}
+
+// handleIPCConn serves a single request on conn
+func (p *RavenPanel) handleIPCConn(conn net.Conn) {
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(ipcTimeout + time.Second))
+
+	var cmd ShellCommand
+	if err := json.NewDecoder(conn).Decode(&cmd); err != nil {
+		json.NewEncoder(conn).Encode(ShellResponse{Error: "invalid request: " + err.Error()})
+		return
+	}
+
+	// State lives in widgets, so read it on the main loop. A command still
+	// queued when the wait expires is dropped rather than run late.
+	result := make(chan ShellResponse, 1)
+	var mu sync.Mutex
+	abandoned := false
+	glib.IdleAdd(func() {
+		mu.Lock()
+		defer mu.Unlock()
+		if !abandoned {
+			result <- p.dispatchCommand(cmd)
+		}
+	})
+
+	select {
+	case resp := <-result:
+		json.NewEncoder(conn).Encode(resp)
+	case <-time.After(ipcTimeout):
+		mu.Lock()
+		abandoned = true
+		mu.Unlock()
+		select {
+		case resp := <-result:
+			json.NewEncoder(conn).Encode(resp)
+		default:
+			json.NewEncoder(conn).Encode(ShellResponse{Error: "timed out waiting for the shell"})
+		}
+	}
+}
+
+// dispatchCommand runs a control command on the main loop
+func (p *RavenPanel) dispatchCommand(cmd ShellCommand) ShellResponse {
+	switch cmd.Action {
+	case "dump-state":
+		return ShellResponse{Success: true, Data: p.dumpState()}
+
+	case "help":
+		return ShellResponse{Success: true, Data: shellMethods}
+	}
+
+	return ShellResponse{Error: "unknown action: " + cmd.Action}
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+
+	"github.com/diamondburned/gotk4/pkg/glib/v2"
+)
+
+// ShellKeybind is a compositor shortcut owned by the shell
+type ShellKeybind struct {
+	Name    string `json:"name"`
+	Mods    string `json:"mods"` // Hyprland modifiers, e.g. "SUPER SHIFT"
+	Key     string `json:"key"`
+	Exec    string `json:"exec"`
+	Release bool   `json:"release,omitempty"` // Fire on key release (bindr), needed for a lone Super
+}
+
+// KeybindsConfig is ~/.config/raven/keybinds.json
+type KeybindsConfig struct {
+	Binds []ShellKeybind `json:"binds"`
+}
+
+// defaultKeybinds are written to keybinds.json on first start
+var defaultKeybinds = []ShellKeybind{
+	{Name: "launcher", Mods: "SUPER", Key: "Super_L", Exec: "raven-menu", Release: true},
+	{Name: "window-switcher", Mods: "ALT", Key: "Tab", Exec: "raven-menu --windows"},
+	{Name: "clipboard", Mods: "SUPER", Key: "V", Exec: "cliphist list | wofi --dmenu | cliphist decode | wl-copy"},
+	{Name: "screenshot", Mods: "", Key: "Print", Exec: `grim -g "$(slurp)" - | wl-copy`},
+}
+
+// Hyprland modifier bits, as reported by hyprctl binds -j
+var hyprModBits = map[string]int{
+	"SHIFT":   1,
+	"CAPS":    2,
+	"CTRL":    4,
+	"CONTROL": 4,
+	"ALT":     8,
+	"MOD2":    16,
+	"MOD3":    32,
+	"SUPER":   64,
+	"WIN":     64,
+	"LOGO":    64,
+	"MOD4":    64,
+	"MOD5":    128,
+}
+
+// hyprBind is one entry of hyprctl binds -j
+type hyprBind struct {
+	Modmask int    `json:"modmask"`
+	Key     string `json:"key"`
+}
+
+func keybindsPath() string {
+	return filepath.Join(os.Getenv("HOME"), ".config", "raven", "keybinds.json")
+}
+
+// loadKeybinds reads keybinds.json, creating it with the defaults so the
+// shortcuts can be edited without touching hyprland.conf
+func loadKeybinds() []ShellKeybind {
+	path := keybindsPath()
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		config := KeybindsConfig{Binds: defaultKeybinds}
+		if data, err := json.MarshalIndent(config, "", "  "); err == nil {
+			os.MkdirAll(filepath.Dir(path), 0755)
+			os.WriteFile(path, data, 0644)
+		}
+		return defaultKeybinds
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "raven-shell: failed to read %s: %v\n", path, err)
+		return nil
+	}
+
+	var config KeybindsConfig
+	if err := json.Unmarshal(data, &config); err != nil {
+		fmt.Fprintf(os.Stderr, "raven-shell: invalid %s: %v\n", path, err)
+		return nil
+	}
+	return config.Binds
+}
+
+func modMask(mods string) int {
+	mask := 0
+	for _, mod := range strings.FieldsFunc(strings.ToUpper(mods), func(r rune) bool {
+		return r == ' ' || r == '_' || r == '+'
+	}) {
+		mask |= hyprModBits[mod]
+	}
+	return mask
+}
+
+// hyprlandBinds returns the key combinations Hyprland already has bound
+func hyprlandBinds() (map[string]bool, error) {
+	output, err := exec.Command("hyprctl", "binds", "-j").Output()
+	if err != nil {
+		return nil, err
+	}
+	var binds []hyprBind
+	if err := json.Unmarshal(output, &binds); err != nil {
+		return nil, err
+	}
+
+	bound := make(map[string]bool)
+	for _, b := range binds {
+		bound[bindID(b.Modmask, b.Key)] = true
+	}
+	return bound, nil
+}
+
+func bindID(mask int, key string) string {
+	return fmt.Sprintf("%d:%s", mask, strings.ToLower(key))
+}
+
+// registerKeybinds adds the shell's shortcuts to Hyprland. Combinations
+// hyprland.conf already binds are left alone so nothing fires twice.
+func (p *RavenPanel) registerKeybinds() {
+	if os.Getenv("HYPRLAND_INSTANCE_SIGNATURE") == "" {
+		return
+	}
+
+	bound, err := hyprlandBinds()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "raven-shell: cannot list Hyprland binds: %v\n", err)
+		return
+	}
+
+	for _, kb := range loadKeybinds() {
+		if kb.Key == "" || kb.Exec == "" {
+			continue
+		}
+		if bound[bindID(modMask(kb.Mods), kb.Key)] {
+			fmt.Fprintf(os.Stderr, "raven-shell: %s shortcut %s already bound in Hyprland, skipping\n", kb.Name, describeBind(kb))
+			continue
+		}
+
+		keyword := "bind"
+		if kb.Release {
+			keyword = "bindr"
+		}
+		arg := fmt.Sprintf("%s,%s,exec,%s", kb.Mods, kb.Key, kb.Exec)
+		if output, err := exec.Command("hyprctl", "keyword", keyword, arg).CombinedOutput(); err != nil {
+			fmt.Fprintf(os.Stderr, "raven-shell: failed to bind %s: %s\n", describeBind(kb), strings.TrimSpace(string(output)))
+			continue
+		}
+		p.keybinds = append(p.keybinds, kb)
+	}
+
+	p.unregisterKeybindsOnSignal()
+}
+
+// unregisterKeybinds removes the shortcuts registerKeybinds added
+func (p *RavenPanel) unregisterKeybinds() {
+	for _, kb := range p.keybinds {
+		exec.Command("hyprctl", "keyword", "unbind", fmt.Sprintf("%s,%s", kb.Mods, kb.Key)).Run()
+	}
+	p.keybinds = nil
+}
+
+// unregisterKeybindsOnSignal quits the application on SIGINT/SIGTERM so
+// the shutdown handler gets to remove the shortcuts
+func (p *RavenPanel) unregisterKeybindsOnSignal() {
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		<-sigs
+		glib.IdleAdd(func() {
+			p.app.Quit()
+		})
+	}()
+}
+
+func describeBind(kb ShellKeybind) string {
+	if kb.Mods == "" {
+		return kb.Key
+	}
+	return kb.Mods + "+" + kb.Key
+}
@@ -0,0 +1,23 @@
+package main
+
+import "strings"
+
+// kioskAllows reports whether the dock may show item in kiosk mode. An
+// entry in kiosk_apps names an app by its dock ID or program, and a
+// ".desktop" suffix is ignored, so "firefox" and "firefox.desktop" both
+// allow Firefox.
+func (p *RavenPanel) kioskAllows(item *DockItem) bool {
+	if !p.ravenSettings.KioskMode {
+		return true
+	}
+	for _, app := range p.ravenSettings.KioskApps {
+		app = strings.TrimSuffix(app, ".desktop")
+		if app == "" {
+			continue
+		}
+		if app == item.ID || strings.EqualFold(app, commandBase(item.Command)) {
+			return true
+		}
+	}
+	return false
+}
@@ -2,12 +2,14 @@ package main
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 	"unsafe"
 
@@ -15,6 +17,9 @@ import (
 	"github.com/diamondburned/gotk4/pkg/gio/v2"
 	"github.com/diamondburned/gotk4/pkg/glib/v2"
 	"github.com/diamondburned/gotk4/pkg/gtk/v4"
+	"github.com/godbus/dbus/v5"
+
+	"raven-file-manager/pkg/icons"
 )
 
 /*
@@ -107,6 +112,12 @@ import "C"
 const (
 	panelSize = 38
 
+	// How long a dock item pulses while waiting for its first window
+	launchFeedbackTimeout = 15
+
+	// Dock item icon size in pixels
+	dockIconSize = 20
+
 	// Panel orientations
 	OrientationTop    = 0
 	OrientationBottom = 1
@@ -151,17 +162,24 @@ type HyprlandClient struct {
 
 // DockItem represents an application in the dock
 type DockItem struct {
-	ID          string `json:"id"`
-	Name        string `json:"name"`
-	Command     string `json:"command"`
-	Icon        string `json:"icon"`
-	Pinned      bool   `json:"pinned"`
-	Running     bool   `json:"-"`
-	Minimized   bool   `json:"-"`
-	PID         int    `json:"-"`
-	Address     string `json:"-"` // Hyprland window address
-	WorkspaceID int    `json:"-"` // Current workspace ID
-	button      *gtk.Button
+	ID            string `json:"id"`
+	Name          string `json:"name"`
+	Command       string `json:"command"`
+	Icon          string `json:"icon"`
+	Pinned        bool   `json:"pinned"`
+	Running       bool   `json:"-"`
+	Minimized     bool   `json:"-"`
+	PID           int    `json:"-"`
+	Address       string `json:"-"` // Hyprland window address
+	WorkspaceID   int    `json:"-"` // Current workspace ID
+	Launching     bool   `json:"-"` // Waiting for the first window after launch
+	NotResponding bool   `json:"-"` // Window's process appears hung
+	button        *gtk.Button
+
+	launchStarted   time.Time // When the pending launch was started
+	startupWMClass  string    // StartupWMClass hint from the .desktop file
+	forceCloseStage int       // 0 = not attempted, 1 = SIGTERM sent
+	forceCloseStart uint64    // Start time of the process SIGTERM was sent to
 }
 
 // PanelConfig holds persistent panel configuration (dock.json)
@@ -174,32 +192,41 @@ type RavenSettings struct {
 	PanelPosition string `json:"panel_position"` // "top", "bottom", "left", "right"
 	PanelHeight   int    `json:"panel_height"`
 	// Other settings we don't modify but need to preserve
-	Theme                 string  `json:"theme,omitempty"`
-	AccentColor           string  `json:"accent_color,omitempty"`
-	FontSize              int     `json:"font_size,omitempty"`
-	IconTheme             string  `json:"icon_theme,omitempty"`
-	CursorTheme           string  `json:"cursor_theme,omitempty"`
-	PanelOpacity          float64 `json:"panel_opacity,omitempty"`
-	EnableAnimations      bool    `json:"enable_animations,omitempty"`
-	WallpaperPath         string  `json:"wallpaper_path,omitempty"`
-	WallpaperMode         string  `json:"wallpaper_mode,omitempty"`
-	ShowDesktopIcons      bool    `json:"show_desktop_icons,omitempty"`
-	ShowClock             bool    `json:"show_clock,omitempty"`
-	ClockFormat           string  `json:"clock_format,omitempty"`
-	ShowWorkspaces        bool    `json:"show_workspaces,omitempty"`
-	BorderWidth           int     `json:"border_width,omitempty"`
-	GapSize               int     `json:"gap_size,omitempty"`
-	FocusFollowsMouse     bool    `json:"focus_follows_mouse,omitempty"`
-	TitlebarButtons       string  `json:"titlebar_buttons,omitempty"`
-	KeyboardLayout        string  `json:"keyboard_layout,omitempty"`
-	MouseSpeed            float64 `json:"mouse_speed,omitempty"`
-	TouchpadNaturalScroll bool    `json:"touchpad_natural_scroll,omitempty"`
-	TouchpadTapToClick    bool    `json:"touchpad_tap_to_click,omitempty"`
-	ScreenTimeout         int     `json:"screen_timeout,omitempty"`
-	SuspendTimeout        int     `json:"suspend_timeout,omitempty"`
-	LidCloseAction        string  `json:"lid_close_action,omitempty"`
-	MasterVolume          int     `json:"master_volume,omitempty"`
-	MuteOnLock            bool    `json:"mute_on_lock,omitempty"`
+	Theme                 string   `json:"theme,omitempty"`
+	AccentColor           string   `json:"accent_color,omitempty"`
+	FontSize              int      `json:"font_size,omitempty"`
+	IconTheme             string   `json:"icon_theme,omitempty"`
+	CursorTheme           string   `json:"cursor_theme,omitempty"`
+	PanelOpacity          float64  `json:"panel_opacity,omitempty"`
+	EnableAnimations      bool     `json:"enable_animations,omitempty"`
+	WallpaperPath         string   `json:"wallpaper_path,omitempty"`
+	WallpaperMode         string   `json:"wallpaper_mode,omitempty"`
+	ShowDesktopIcons      bool     `json:"show_desktop_icons"` // Kept when false so the desktop sees it
+	ShowClock             bool     `json:"show_clock,omitempty"`
+	ClockFormat           string   `json:"clock_format,omitempty"`
+	ShowWorkspaces        bool     `json:"show_workspaces,omitempty"`
+	BorderWidth           int      `json:"border_width,omitempty"`
+	GapSize               int      `json:"gap_size,omitempty"`
+	FocusFollowsMouse     bool     `json:"focus_follows_mouse,omitempty"`
+	TitlebarButtons       string   `json:"titlebar_buttons,omitempty"`
+	KeyboardLayout        string   `json:"keyboard_layout,omitempty"`
+	MouseSpeed            float64  `json:"mouse_speed,omitempty"`
+	TouchpadNaturalScroll bool     `json:"touchpad_natural_scroll,omitempty"`
+	TouchpadTapToClick    bool     `json:"touchpad_tap_to_click,omitempty"`
+	ScreenTimeout         int      `json:"screen_timeout,omitempty"`
+	SuspendTimeout        int      `json:"suspend_timeout,omitempty"`
+	LidCloseAction        string   `json:"lid_close_action,omitempty"`
+	MasterVolume          int      `json:"master_volume,omitempty"`
+	MuteOnLock            bool     `json:"mute_on_lock,omitempty"`
+	ThemeSchedule         string   `json:"theme_schedule,omitempty"` // "off", "fixed" or "sun"
+	LightThemeStart       string   `json:"light_theme_start,omitempty"`
+	DarkThemeStart        string   `json:"dark_theme_start,omitempty"`
+	Latitude              float64  `json:"latitude,omitempty"`
+	Longitude             float64  `json:"longitude,omitempty"`
+	KioskMode             bool     `json:"kiosk_mode,omitempty"`           // Locked-down panel for shared machines
+	KioskApps             []string `json:"kiosk_apps,omitempty"`           // Apps the dock may show in kiosk mode
+	AnnounceEvents        bool     `json:"announce_events,omitempty"`      // Speak workspace, focus and notification events
+	PhoneHiddenDevices    []string `json:"phone_hidden_devices,omitempty"` // KDE Connect devices left out of the panel
 }
 
 // RavenPanel represents the main panel/taskbar
@@ -220,6 +247,27 @@ type RavenPanel struct {
 	powerWindow       *gtk.Window
 	orientation       int
 	ravenSettings     RavenSettings
+	startupInfos      map[string]startupInfo // .desktop hints by executable name
+	printBtn          *gtk.Button
+	printWindow       *gtk.Window
+	printJobs         []PrintJob
+	trackingChip      *gtk.Button   // Shown while window tracking is unavailable
+	trackingRetry     chan struct{} // Asks the window monitor to look again
+	trackingErr       error
+	batteryBtn        *gtk.Button
+	batteryWindow     *gtk.Window
+	phoneBtn          *gtk.Button
+	phoneLabel        *gtk.Label
+	phoneWindow       *gtk.Window
+	phones            []Phone          // Paired KDE Connect devices
+	phoneConn         *dbus.Conn       // Session bus connection talking to kdeconnectd
+	keybinds          []ShellKeybind   // Shortcuts registered with Hyprland
+	themeCSS          *gtk.CSSProvider // Light theme overrides
+	panelTheme        string           // Theme the panel is styled for
+	mutedApps         map[int]bool     // PIDs muted from the dock
+	muteWatching      bool             // Muted apps are being kept muted
+	announcing        atomic.Bool      // Events are spoken
+	announceOnce      sync.Once        // Starts the event listeners
 }
 
 func main() {
@@ -233,6 +281,7 @@ func main() {
 	panel := &RavenPanel{
 		app:               app,
 		dockItems:         make(map[string]*DockItem),
+		trackingRetry:     make(chan struct{}, 1),
 		configPath:        configPath,
 		ravenSettingsPath: ravenSettingsPath,
 	}
@@ -240,6 +289,9 @@ func main() {
 	app.ConnectActivate(func() {
 		panel.activate()
 	})
+	app.ConnectShutdown(func() {
+		panel.unregisterKeybinds()
+	})
 
 	if code := app.Run(os.Args); code > 0 {
 		os.Exit(code)
@@ -266,6 +318,9 @@ func (p *RavenPanel) activate() {
 	// Apply dark theme CSS
 	p.applyCSS()
 
+	// Light/dark styling and the automatic theme schedule
+	p.startThemeSchedule()
+
 	// Create the panel content
 	content := p.createPanelContent()
 	p.window.SetChild(content)
@@ -276,6 +331,42 @@ func (p *RavenPanel) activate() {
 	// Start process monitor to track running apps
 	go p.monitorProcesses()
 
+	// Index .desktop startup hints for launch feedback
+	go p.loadStartupInfo()
+
+	// Watch CUPS for print jobs
+	go p.monitorPrintJobs()
+
+	// Keep the battery icon current
+	go p.monitorBattery()
+
+	// Phones paired with KDE Connect
+	if !p.ravenSettings.KioskMode {
+		go p.monitorPhones()
+	}
+
+	// Pick up pins made from raven-menu
+	p.watchDockConfig()
+
+	// Apply settings changed in raven-settings-menu and elsewhere
+	if err := watchSettingsChanges(p.settingsChanged); err != nil {
+		fmt.Fprintf(os.Stderr, "raven-shell: settings changes apply after a restart: %v\n", err)
+	}
+
+	// Shortcuts from keybinds.json
+	p.registerKeybinds()
+
+	// Spoken workspace, focus and notification events
+	if p.ravenSettings.AnnounceEvents {
+		p.announcing.Store(true)
+		p.startAnnouncements()
+	}
+
+	// Control socket for raven-ctl shell
+	if err := p.startIPCServer(); err != nil {
+		fmt.Fprintf(os.Stderr, "raven-shell: control socket unavailable: %v\n", err)
+	}
+
 	p.window.SetApplication(p.app)
 	p.window.Present()
 }
@@ -411,6 +502,30 @@ func (p *RavenPanel) applyCSS() {
 		.dock-item-minimized {
 			opacity: 0.6;
 		}
+
+		@keyframes dock-launch-pulse {
+			0% { opacity: 1.0; }
+			50% { opacity: 0.35; }
+			100% { opacity: 1.0; }
+		}
+
+		.dock-item-launching {
+			background: rgba(0, 150, 136, 0.2);
+			animation: dock-launch-pulse 1s ease-in-out infinite;
+		}
+
+		.dock-muted-badge {
+			background: rgba(0, 0, 0, 0.75);
+			color: #ff8a65;
+			border-radius: 8px;
+			padding: 1px;
+		}
+
+		.dock-item-not-responding {
+			background: rgba(255, 80, 80, 0.2);
+			border-bottom: 2px solid rgba(255, 120, 80, 0.9);
+			opacity: 0.7;
+		}
 		
 		.clock {
 			font-weight: 600;
@@ -457,6 +572,71 @@ func (p *RavenPanel) applyCSS() {
 			color: rgba(255, 150, 150, 1);
 		}
 
+		.context-menu-warning {
+			color: rgba(255, 180, 100, 0.95);
+			font-size: 12px;
+			padding: 6px 16px 2px 16px;
+		}
+
+		.session-warning {
+			color: rgba(255, 180, 100, 0.95);
+			font-size: 12px;
+		}
+
+		.tracking-chip {
+			color: rgba(255, 180, 100, 0.95);
+			background: rgba(255, 180, 100, 0.12);
+			border-radius: 10px;
+			padding: 0 8px;
+			font-size: 12px;
+		}
+
+		.tracking-chip:hover {
+			background: rgba(255, 180, 100, 0.22);
+		}
+
+		.print-indicator {
+			color: rgba(200, 200, 200, 0.9);
+		}
+
+		.print-indicator:hover {
+			background: rgba(255, 255, 255, 0.15);
+		}
+
+		.print-job {
+			padding: 6px 8px;
+		}
+
+		.print-job-detail {
+			color: rgba(170, 170, 170, 0.9);
+			font-size: 11px;
+		}
+
+		.power-indicator {
+			color: rgba(200, 200, 200, 0.9);
+		}
+
+		.power-indicator:hover {
+			background: rgba(255, 255, 255, 0.15);
+		}
+
+		.phone-indicator {
+			color: rgba(200, 200, 200, 0.9);
+		}
+
+		.phone-indicator:hover {
+			background: rgba(255, 255, 255, 0.15);
+		}
+
+		.power-row {
+			padding: 6px 8px;
+		}
+
+		.power-detail {
+			color: rgba(170, 170, 170, 0.9);
+			font-size: 11px;
+		}
+
 		.settings-button {
 			color: rgba(200, 200, 200, 0.9);
 		}
@@ -539,8 +719,9 @@ func (p *RavenPanel) loadConfig() {
 	// Load panel position from raven settings.json
 	p.orientation = OrientationTop // Default
 	p.ravenSettings = RavenSettings{
-		PanelPosition: "top",
-		PanelHeight:   38,
+		PanelPosition:    "top",
+		PanelHeight:      38,
+		ShowDesktopIcons: true,
 	}
 
 	if data, err := os.ReadFile(p.ravenSettingsPath); err == nil {
@@ -608,30 +789,13 @@ func (p *RavenPanel) saveConfig() {
 	os.WriteFile(p.configPath, data, 0644)
 }
 
-// saveRavenSettings saves panel position to raven settings.json
+// saveRavenSettings saves panel position to raven settings.json. Only the
+// position is written, so changes other components made since startup stay.
 func (p *RavenPanel) saveRavenSettings() {
-	// Convert orientation to string
-	switch p.orientation {
-	case OrientationTop:
-		p.ravenSettings.PanelPosition = "top"
-	case OrientationBottom:
-		p.ravenSettings.PanelPosition = "bottom"
-	case OrientationLeft:
-		p.ravenSettings.PanelPosition = "left"
-	case OrientationRight:
-		p.ravenSettings.PanelPosition = "right"
-	}
-
-	// Ensure directory exists
-	ravenConfigDir := filepath.Dir(p.ravenSettingsPath)
-	os.MkdirAll(ravenConfigDir, 0755)
-
-	data, err := json.MarshalIndent(p.ravenSettings, "", "  ")
-	if err != nil {
-		return
+	p.ravenSettings.PanelPosition = orientationNames[p.orientation]
+	if err := updateSettingsFile(p.ravenSettingsPath, map[string]any{"panel_position": p.ravenSettings.PanelPosition}); err != nil {
+		fmt.Fprintf(os.Stderr, "raven-shell: failed to save panel position: %v\n", err)
 	}
-
-	os.WriteFile(p.ravenSettingsPath, data, 0644)
 }
 
 func (p *RavenPanel) setOrientation(orientation int) {
@@ -651,6 +815,9 @@ func (p *RavenPanel) rebuildPanel() {
 	// Close any open menus
 	p.closeSettingsMenu()
 	p.closePowerMenu()
+	p.closePrintJobs()
+	p.closeBatteryPopover()
+	p.closePhonePopover()
 
 	// Destroy current window
 	if p.window != nil {
@@ -711,6 +878,7 @@ func (p *RavenPanel) createPanelContent() *gtk.Box {
 	p.startBtn.SetChild(ravenContent)
 	p.startBtn.AddCSSClass("start-button")
 	p.startBtn.SetTooltipText("Raven Menu")
+	setAccessibleLabel(p.startBtn, "Raven Menu")
 	p.startBtn.ConnectClicked(func() {
 		p.showMenu()
 	})
@@ -730,6 +898,7 @@ func (p *RavenPanel) createPanelContent() *gtk.Box {
 	// Center section: Dock
 	p.dockBox = gtk.NewBox(boxOrientation, 4)
 	p.dockBox.AddCSSClass("dock-container")
+	setAccessibleLabel(p.dockBox, "Dock")
 
 	// Render initial pinned apps
 	p.renderDock()
@@ -754,11 +923,32 @@ func (p *RavenPanel) createPanelContent() *gtk.Box {
 	}
 	endBox.AddCSSClass("panel-section")
 
+	// Shown only when Hyprland can't be asked for windows
+	endBox.Append(p.createTrackingChip())
+
+	// Print job indicator, hidden while the queue is empty
+	endBox.Append(p.createPrintIndicator())
+
+	// Battery level, power profile and temperatures
+	endBox.Append(p.createBatteryIndicator())
+
+	// Connected phone's battery and notifications. Kiosk mode leaves it
+	// out, as the phone is someone's own.
+	if !p.ravenSettings.KioskMode {
+		endBox.Append(p.createPhoneIndicator())
+	}
+
 	p.clockLabel = gtk.NewLabel("")
 	p.clockLabel.AddCSSClass("clock")
 	p.updateClockLabel()
 	endBox.Append(p.clockLabel)
 
+	// Kiosk mode leaves out the settings and power controls
+	if p.ravenSettings.KioskMode {
+		p.mainBox.Append(endBox)
+		return p.mainBox
+	}
+
 	sep1 := gtk.NewSeparator(separatorOrientation)
 	endBox.Append(sep1)
 
@@ -824,9 +1014,10 @@ func (p *RavenPanel) renderDock() {
 	p.mu.RLock()
 	defer p.mu.RUnlock()
 
-	// First render pinned apps, then running (non-pinned) apps
+	// First render pinned apps, then running (non-pinned) apps. Kiosk mode
+	// only shows the allowed ones.
 	for _, item := range p.dockItems {
-		if item.Pinned {
+		if item.Pinned && p.kioskAllows(item) {
 			btn := p.createDockItem(item)
 			item.button = btn
 			p.dockBox.Append(btn)
@@ -834,7 +1025,7 @@ func (p *RavenPanel) renderDock() {
 	}
 
 	for _, item := range p.dockItems {
-		if !item.Pinned && item.Running {
+		if !item.Pinned && item.Running && p.kioskAllows(item) {
 			btn := p.createDockItem(item)
 			item.button = btn
 			p.dockBox.Append(btn)
@@ -844,9 +1035,41 @@ func (p *RavenPanel) renderDock() {
 
 func (p *RavenPanel) createDockItem(item *DockItem) *gtk.Button {
 	btn := gtk.NewButton()
-	btn.SetLabel(item.Name)
 	btn.AddCSSClass("dock-item")
 
+	// Icon from the shared icon cache, when the theme has one. A muted
+	// app has a badge on its icon, or after its name without one.
+	hasIcon := icons.Path(item.Icon, dockIconSize) != ""
+	muted := p.isAppMuted(item)
+	if hasIcon || muted {
+		content := gtk.NewBox(gtk.OrientationHorizontal, 6)
+		var badge *gtk.Image
+		if muted {
+			badge = gtk.NewImageFromIconName("audio-volume-muted-symbolic")
+			badge.SetPixelSize(12)
+			badge.AddCSSClass("dock-muted-badge")
+			badge.SetTooltipText(item.Name + " is muted")
+		}
+		if hasIcon {
+			overlay := gtk.NewOverlay()
+			overlay.SetChild(icons.NewImage(item.Icon, dockIconSize))
+			if badge != nil {
+				badge.SetHAlign(gtk.AlignEnd)
+				badge.SetVAlign(gtk.AlignEnd)
+				overlay.AddOverlay(badge)
+				badge = nil
+			}
+			content.Append(overlay)
+		}
+		content.Append(gtk.NewLabel(item.Name))
+		if badge != nil {
+			content.Append(badge)
+		}
+		btn.SetChild(content)
+	} else {
+		btn.SetLabel(item.Name)
+	}
+
 	if item.Running {
 		btn.AddCSSClass("dock-item-running")
 	}
@@ -856,13 +1079,23 @@ func (p *RavenPanel) createDockItem(item *DockItem) *gtk.Button {
 	if item.Minimized {
 		btn.AddCSSClass("dock-item-minimized")
 	}
+	if item.Launching {
+		btn.AddCSSClass("dock-item-launching")
+		btn.SetTooltipText("Starting " + item.Name + "...")
+	}
+	if item.NotResponding {
+		btn.AddCSSClass("dock-item-not-responding")
+		btn.SetTooltipText(item.Name + " is not responding")
+	}
+	setAccessibleLabel(btn, p.dockItemLabel(item))
+	setAccessibleDescription(btn, "Menu key for more options")
 
 	// Left click: launch or focus
 	btn.ConnectClicked(func() {
 		if item.Running {
 			p.focusApp(item)
-		} else {
-			p.launchApp(item.Command)
+		} else if !item.Launching {
+			p.launchDockItem(item)
 		}
 	})
 
@@ -874,6 +1107,17 @@ func (p *RavenPanel) createDockItem(item *DockItem) *gtk.Button {
 	})
 	btn.AddController(rightClick)
 
+	// The Menu key or Shift+F10 opens it from the keyboard
+	keys := gtk.NewEventControllerKey()
+	keys.ConnectKeyPressed(func(keyval, keycode uint, state gdk.ModifierType) bool {
+		if keyval == gdk.KEY_Menu || (keyval == gdk.KEY_F10 && state&gdk.ShiftMask != 0) {
+			p.showDockItemMenu(item, btn)
+			return true
+		}
+		return false
+	})
+	btn.AddController(keys)
+
 	return btn
 }
 
@@ -881,21 +1125,45 @@ func (p *RavenPanel) showDockItemMenu(item *DockItem, btn *gtk.Button) {
 	popover := gtk.NewPopover()
 	popover.SetParent(btn)
 	popover.AddCSSClass("context-menu")
+	setAccessibleLabel(popover, item.Name+" options")
 
 	menuBox := gtk.NewBox(gtk.OrientationVertical, 2)
 
-	// Pin/Unpin button
-	pinBtn := gtk.NewButton()
-	if item.Pinned {
-		pinBtn.SetLabel("Unpin from Dock")
-	} else {
-		pinBtn.SetLabel("Pin to Dock")
+	// Offer to force close a hung app before anything else
+	if item.Running && item.NotResponding {
+		warning := gtk.NewLabel("Not responding — Force close?")
+		warning.AddCSSClass("context-menu-warning")
+		warning.SetHAlign(gtk.AlignStart)
+		menuBox.Append(warning)
+
+		forceBtn := gtk.NewButton()
+		if item.forceCloseStage > 0 {
+			forceBtn.SetLabel("Kill Now")
+		} else {
+			forceBtn.SetLabel("Force Close")
+		}
+		forceBtn.AddCSSClass("context-menu-close")
+		forceBtn.ConnectClicked(func() {
+			p.forceClose(item)
+			popover.Popdown()
+		})
+		menuBox.Append(forceBtn)
+	}
+
+	// Pin/Unpin button; the kiosk dock can't be changed
+	if !p.ravenSettings.KioskMode {
+		pinBtn := gtk.NewButton()
+		if item.Pinned {
+			pinBtn.SetLabel("Unpin from Dock")
+		} else {
+			pinBtn.SetLabel("Pin to Dock")
+		}
+		pinBtn.ConnectClicked(func() {
+			p.togglePin(item)
+			popover.Popdown()
+		})
+		menuBox.Append(pinBtn)
 	}
-	pinBtn.ConnectClicked(func() {
-		p.togglePin(item)
-		popover.Popdown()
-	})
-	menuBox.Append(pinBtn)
 
 	// Only show these options for running apps
 	if item.Running {
@@ -912,6 +1180,19 @@ func (p *RavenPanel) showDockItemMenu(item *DockItem, btn *gtk.Button) {
 		})
 		menuBox.Append(minBtn)
 
+		// Mute or unmute the app's audio streams
+		muteBtn := gtk.NewButton()
+		if p.isAppMuted(item) {
+			muteBtn.SetLabel("Unmute Application")
+		} else {
+			muteBtn.SetLabel("Mute Application")
+		}
+		muteBtn.ConnectClicked(func() {
+			p.toggleMute(item)
+			popover.Popdown()
+		})
+		menuBox.Append(muteBtn)
+
 		// Close button
 		closeBtn := gtk.NewButton()
 		closeBtn.SetLabel("Close")
@@ -1006,6 +1287,200 @@ func (p *RavenPanel) launchApp(cmd string) {
 	}()
 }
 
+// launchDockItem launches a dock item and pulses it until its first window
+// appears or launchFeedbackTimeout expires. Apps whose .desktop entry sets
+// StartupNotify=false are launched without feedback.
+func (p *RavenPanel) launchDockItem(item *DockItem) {
+	info := p.lookupStartupInfo(item.Command)
+	if !info.notify {
+		p.launchApp(item.Command)
+		return
+	}
+
+	started := time.Now()
+	token := activationToken(item.Command)
+
+	p.mu.Lock()
+	item.Launching = true
+	item.launchStarted = started
+	item.startupWMClass = info.wmClass
+	p.mu.Unlock()
+
+	glib.IdleAdd(func() {
+		p.renderDock()
+	})
+
+	command := item.Command
+	go func() {
+		cmd := exec.Command("sh", "-c", command)
+		if token != "" {
+			// Wayland apps pass XDG_ACTIVATION_TOKEN back to the compositor
+			// when their window maps, so it gets focus; X11 apps under
+			// Xwayland use the same value as DESKTOP_STARTUP_ID
+			cmd.Env = append(os.Environ(), "XDG_ACTIVATION_TOKEN="+token, "DESKTOP_STARTUP_ID="+token)
+		}
+		if err := cmd.Start(); err != nil {
+			glib.IdleAdd(func() {
+				p.finishLaunch(item, started)
+			})
+			return
+		}
+		// Reap the shell so it doesn't linger as a zombie
+		cmd.Wait()
+	}()
+
+	glib.TimeoutSecondsAdd(launchFeedbackTimeout, func() bool {
+		p.finishLaunch(item, started)
+		return false
+	})
+}
+
+// finishLaunch stops the launch feedback for item if it still belongs to the
+// launch that began at started
+func (p *RavenPanel) finishLaunch(item *DockItem, started time.Time) {
+	p.mu.Lock()
+	if !item.Launching || !item.launchStarted.Equal(started) {
+		p.mu.Unlock()
+		return
+	}
+	item.Launching = false
+	p.mu.Unlock()
+
+	p.renderDock()
+}
+
+// completeLaunches ends launch feedback for any launching dock item whose
+// command or StartupWMClass matches a newly mapped window
+func (p *RavenPanel) completeLaunches(class, command string) {
+	p.mu.Lock()
+	changed := false
+	for _, item := range p.dockItems {
+		if !item.Launching {
+			continue
+		}
+		if launchMatchesWindow(item, class, command) {
+			item.Launching = false
+			changed = true
+		}
+	}
+	p.mu.Unlock()
+
+	if changed {
+		glib.IdleAdd(func() {
+			p.renderDock()
+		})
+	}
+}
+
+// launchMatchesWindow reports whether a window with the given class/command
+// belongs to the launching dock item
+func launchMatchesWindow(item *DockItem, class, command string) bool {
+	if item.startupWMClass != "" && strings.EqualFold(item.startupWMClass, class) {
+		return true
+	}
+	base := commandBase(item.Command)
+	if base == "" {
+		return false
+	}
+	return strings.EqualFold(base, class) || strings.EqualFold(base, commandBase(command))
+}
+
+// commandBase returns the executable name of a shell command line
+func commandBase(cmd string) string {
+	fields := strings.Fields(cmd)
+	if len(fields) == 0 {
+		return ""
+	}
+	return filepath.Base(fields[0])
+}
+
+// activationToken asks the compositor for an xdg-activation token for a
+// launch. GDK's Wayland launch context requests it using the click that
+// triggered the launch, so this must run on the main thread.
+func activationToken(command string) string {
+	display := gdk.DisplayGetDefault()
+	if display == nil {
+		return ""
+	}
+	appInfo, err := gio.AppInfoCreateFromCommandline(command, "", gio.AppInfoCreateNone)
+	if err != nil {
+		return ""
+	}
+	return display.AppLaunchContext().StartupNotifyID(appInfo, nil)
+}
+
+// startupInfo holds startup-notification hints from a .desktop entry
+type startupInfo struct {
+	notify  bool
+	wmClass string
+}
+
+// loadStartupInfo indexes the StartupNotify/StartupWMClass hints of every
+// .desktop entry by executable name, so dock clicks don't have to read the
+// application directories. Earlier directories take precedence.
+func (p *RavenPanel) loadStartupInfo() {
+	index := make(map[string]startupInfo)
+
+	appDirs := []string{
+		filepath.Join(os.Getenv("HOME"), ".local/share/applications"),
+		"/usr/local/share/applications",
+		"/usr/share/applications",
+	}
+
+	for _, dir := range appDirs {
+		files, _ := filepath.Glob(filepath.Join(dir, "*.desktop"))
+		for _, file := range files {
+			data, err := os.ReadFile(file)
+			if err != nil {
+				continue
+			}
+
+			var execLine, notify, wmClass string
+			inDesktopEntry := false
+			for _, line := range strings.Split(string(data), "\n") {
+				line = strings.TrimSpace(line)
+				if strings.HasPrefix(line, "[") {
+					inDesktopEntry = line == "[Desktop Entry]"
+					continue
+				}
+				if !inDesktopEntry {
+					continue
+				}
+				switch {
+				case strings.HasPrefix(line, "Exec="):
+					execLine = strings.TrimPrefix(line, "Exec=")
+				case strings.HasPrefix(line, "StartupNotify="):
+					notify = strings.TrimPrefix(line, "StartupNotify=")
+				case strings.HasPrefix(line, "StartupWMClass="):
+					wmClass = strings.TrimPrefix(line, "StartupWMClass=")
+				}
+			}
+
+			base := commandBase(execLine)
+			if _, seen := index[base]; base == "" || seen {
+				continue
+			}
+			index[base] = startupInfo{notify: notify != "false", wmClass: wmClass}
+		}
+	}
+
+	p.mu.Lock()
+	p.startupInfos = index
+	p.mu.Unlock()
+}
+
+// lookupStartupInfo returns the startup hints for command. Commands without
+// an entry, or launched before the index is built, get feedback by default.
+func (p *RavenPanel) lookupStartupInfo(command string) startupInfo {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	if info, ok := p.startupInfos[commandBase(command)]; ok {
+		return info
+	}
+	return startupInfo{notify: true}
+}
+
 // AddRunningApp adds a new running application to the dock
 func (p *RavenPanel) AddRunningApp(id, name, command string, pid int) {
 	p.mu.Lock()
@@ -1039,6 +1514,8 @@ func (p *RavenPanel) RemoveRunningApp(id string) {
 		item.Running = false
 		item.PID = 0
 		item.Minimized = false
+		item.NotResponding = false
+		item.forceCloseStage = 0
 
 		// Remove if not pinned
 		if !item.Pinned {
@@ -1066,7 +1543,7 @@ func getHyprlandClients() ([]HyprlandClient, error) {
 	return clients, nil
 }
 
-// monitorWindows monitors Hyprland windows and updates the dock
+// monitorProcesses monitors Hyprland windows and updates the dock
 func (p *RavenPanel) monitorProcesses() {
 	// Known application class mappings (window class -> display info)
 	knownApps := map[string]struct {
@@ -1102,16 +1579,33 @@ func (p *RavenPanel) monitorProcesses() {
 	}
 
 	trackedAddresses := make(map[string]string) // Address -> dock item ID
+	hangs := newHangDetector()
+	failures := 0
 
-	ticker := time.NewTicker(1500 * time.Millisecond) // Reduced polling for better performance under software rendering
-	for range ticker.C {
+	for ; ; p.waitForWindowPoll() {
 		clients, err := getHyprlandClients()
 		if err != nil {
-			// Hyprland not running or hyprctl failed
+			// Hyprland not running or hyprctl failed. A single failure
+			// may be Hyprland reloading, so windows are only dropped once
+			// it keeps failing.
+			failures++
+			if failures < compositorFailureLimit && !errors.Is(err, exec.ErrNotFound) {
+				continue
+			}
+			p.setWindowTracking(err)
+			for addr, itemID := range trackedAddresses {
+				p.RemoveRunningApp(itemID)
+				delete(trackedAddresses, addr)
+			}
+			hangs.prune(nil)
 			continue
 		}
+		failures = 0
+		p.setWindowTracking(nil)
 
 		currentAddresses := make(map[string]bool)
+		windowPIDs := make(map[int]bool)
+		now := time.Now()
 
 		for _, client := range clients {
 			// Skip excluded classes
@@ -1125,17 +1619,22 @@ func (p *RavenPanel) monitorProcesses() {
 			}
 
 			currentAddresses[client.Address] = true
+			windowPIDs[client.PID] = true
 
 			// Check if already tracked
 			if existingID, tracked := trackedAddresses[client.Address]; tracked {
 				// Update minimized state based on workspace
 				p.mu.Lock()
-				if item, ok := p.dockItems[existingID]; ok {
+				item, ok := p.dockItems[existingID]
+				if ok {
 					// Check if in special workspace (minimized)
 					item.Minimized = strings.HasPrefix(client.Workspace.Name, "special:")
 					item.WorkspaceID = client.Workspace.ID
 				}
 				p.mu.Unlock()
+				if ok {
+					p.setNotResponding(item, hangs.check(client.PID, now))
+				}
 				continue
 			}
 
@@ -1164,6 +1663,9 @@ func (p *RavenPanel) monitorProcesses() {
 				icon = "application-x-executable"
 			}
 
+			// A launched app has shown its window; stop the launch feedback
+			p.completeLaunches(class, command)
+
 			// Create unique ID using address
 			itemID := fmt.Sprintf("hypr-%s", client.Address)
 			trackedAddresses[client.Address] = itemID
@@ -1181,6 +1683,7 @@ func (p *RavenPanel) monitorProcesses() {
 				delete(trackedAddresses, addr)
 			}
 		}
+		hangs.prune(windowPIDs)
 	}
 }
 
@@ -1218,6 +1721,55 @@ func (p *RavenPanel) showMenu() {
 	p.launchApp("raven-menu")
 }
 
+// popupMargins computes layer-shell margins that place a popup next to the
+// panel button that opened it. The first value is the distance from the screen
+// edge the panel is docked to; the second aligns the popup with the button
+// along the panel (from the right edge for horizontal panels, from the top for
+// vertical ones), matching the anchors used by init_popup_layer_shell_oriented.
+func (p *RavenPanel) popupMargins(btn *gtk.Button) (int, int) {
+	edgeMargin := panelSize + 4
+	alongMargin := 10
+
+	if btn == nil || p.window == nil {
+		return edgeMargin, alongMargin
+	}
+
+	x, y, ok := btn.TranslateCoordinates(p.window, 0, 0)
+	if !ok {
+		return edgeMargin, alongMargin
+	}
+
+	switch p.orientation {
+	case OrientationLeft, OrientationRight:
+		if width := p.window.Width(); width > 0 {
+			edgeMargin = width + 4
+		}
+		alongMargin = int(y)
+	default:
+		if height := p.window.Height(); height > 0 {
+			edgeMargin = height + 4
+		}
+		// Right-align the popup with the button's right edge
+		alongMargin = p.window.Width() - int(x) - btn.Width()
+	}
+
+	if alongMargin < 0 {
+		alongMargin = 0
+	}
+
+	return edgeMargin, alongMargin
+}
+
+// initPopupWindow makes win a layer-shell popup placed next to btn
+func (p *RavenPanel) initPopupWindow(win *gtk.Window, btn *gtk.Button) {
+	obj := win.Object
+	if obj != nil {
+		ptr := obj.Native()
+		edgeMargin, alongMargin := p.popupMargins(btn)
+		C.init_popup_layer_shell_oriented((*C.GtkWidget)(unsafe.Pointer(ptr)), C.int(p.orientation), C.int(edgeMargin), C.int(alongMargin))
+	}
+}
+
 func (p *RavenPanel) closePowerMenu() {
 	if p.powerWindow != nil {
 		p.powerWindow.Close()
@@ -1247,7 +1799,8 @@ func (p *RavenPanel) showPowerMenu() {
 	obj := p.powerWindow.Object
 	if obj != nil {
 		ptr := obj.Native()
-		C.init_popup_layer_shell_oriented((*C.GtkWidget)(unsafe.Pointer(ptr)), C.int(p.orientation), C.int(panelSize+4), C.int(10))
+		edgeMargin, alongMargin := p.popupMargins(p.powerBtn)
+		C.init_popup_layer_shell_oriented((*C.GtkWidget)(unsafe.Pointer(ptr)), C.int(p.orientation), C.int(edgeMargin), C.int(alongMargin))
 	}
 
 	menuBox := gtk.NewBox(gtk.OrientationVertical, 4)
@@ -1275,6 +1828,44 @@ func (p *RavenPanel) showPowerMenu() {
 	})
 	menuBox.Append(lockBtn)
 
+	// Switch user - locks this session and brings up the greeter
+	switchUserBtn := gtk.NewButton()
+	switchUserBtn.SetLabel("Switch User")
+	switchUserBtn.ConnectClicked(func() {
+		p.closePowerMenu()
+		go func() {
+			if err := switchUser(); err != nil {
+				notifySessionError("Switch user failed", err)
+			}
+		}()
+	})
+	menuBox.Append(switchUserBtn)
+
+	// Log out other sessions - only offered when there are any. loginctl is
+	// slow with many sessions, so they're listed off the main thread.
+	powerWindow := p.powerWindow
+	go func() {
+		sessions, err := listLogindSessions()
+		if err != nil {
+			return
+		}
+		others, err := otherUserSessions(sessions)
+		if err != nil || len(others) == 0 {
+			return
+		}
+		glib.IdleAdd(func() {
+			if p.powerWindow != powerWindow {
+				return
+			}
+			othersBtn := gtk.NewButton()
+			othersBtn.SetLabel(fmt.Sprintf("Log Out Other Sessions (%d)", len(others)))
+			othersBtn.ConnectClicked(func() {
+				p.powerWindow.SetChild(p.createSessionConfirm(others))
+			})
+			menuBox.InsertChildAfter(othersBtn, switchUserBtn)
+		})
+	}()
+
 	// Reboot button
 	rebootBtn := gtk.NewButton()
 	rebootBtn.SetLabel("Reboot")
@@ -1350,7 +1941,8 @@ func (p *RavenPanel) showSettingsMenu() {
 	obj := p.settingsWindow.Object
 	if obj != nil {
 		ptr := obj.Native()
-		C.init_popup_layer_shell_oriented((*C.GtkWidget)(unsafe.Pointer(ptr)), C.int(p.orientation), C.int(panelSize+4), C.int(100))
+		edgeMargin, alongMargin := p.popupMargins(p.settingsBtn)
+		C.init_popup_layer_shell_oriented((*C.GtkWidget)(unsafe.Pointer(ptr)), C.int(p.orientation), C.int(edgeMargin), C.int(alongMargin))
 	}
 
 	menuBox := gtk.NewBox(gtk.OrientationVertical, 0)
@@ -1382,7 +1974,7 @@ func (p *RavenPanel) showSettingsMenu() {
 	bluetoothBtn.AddCSSClass("quick-toggle")
 	bluetoothBtn.ConnectClicked(func() {
 		p.closeSettingsMenu()
-		p.launchApp("blueman-manager || blueberry || gnome-bluetooth-panel")
+		p.launchApp("raven-settings-menu --page Bluetooth")
 	})
 	menuBox.Append(bluetoothBtn)
 
@@ -1421,7 +2013,7 @@ func (p *RavenPanel) showSettingsMenu() {
 	networkBtn.SetLabel("Network")
 	networkBtn.ConnectClicked(func() {
 		p.closeSettingsMenu()
-		p.launchApp("nm-connection-editor || gnome-control-center network || raven-wifi")
+		p.launchApp("raven-settings-menu --page Network")
 	})
 	menuBox.Append(networkBtn)
 
@@ -1537,6 +2129,30 @@ func (p *RavenPanel) showSettingsMenu() {
 	positionBox.Append(leftRightBox)
 	menuBox.Append(positionBox)
 
+	// Separator
+	sepAccess := gtk.NewSeparator(gtk.OrientationHorizontal)
+	sepAccess.AddCSSClass("settings-menu-separator")
+	menuBox.Append(sepAccess)
+
+	// Accessibility Section
+	accessLabel := gtk.NewLabel("Accessibility")
+	accessLabel.AddCSSClass("settings-section-label")
+	accessLabel.SetHAlign(gtk.AlignStart)
+	menuBox.Append(accessLabel)
+
+	// Spoken workspace, window and notification announcements
+	announceBtn := gtk.NewButton()
+	announceBtn.SetLabel("Announce Events")
+	announceBtn.SetTooltipText("Speak workspace switches, the focused window and notifications")
+	if p.ravenSettings.AnnounceEvents {
+		announceBtn.AddCSSClass("quick-toggle-active")
+	}
+	announceBtn.ConnectClicked(func() {
+		p.setAnnounceEvents(!p.ravenSettings.AnnounceEvents)
+		p.closeSettingsMenu()
+	})
+	menuBox.Append(announceBtn)
+
 	// Separator
 	sep4 := gtk.NewSeparator(gtk.OrientationHorizontal)
 	sep4.AddCSSClass("settings-menu-separator")
@@ -0,0 +1,503 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/diamondburned/gotk4/pkg/gdk/v4"
+	"github.com/diamondburned/gotk4/pkg/glib/v2"
+	"github.com/diamondburned/gotk4/pkg/gtk/v4"
+	"github.com/godbus/dbus/v5"
+)
+
+// kdeconnectd's bus name, and the objects and interfaces used here
+const (
+	kdeconnectName               = "org.kde.kdeconnect"
+	kdeconnectPath               = dbus.ObjectPath("/modules/kdeconnect")
+	kdeconnectDaemonIface        = "org.kde.kdeconnect.daemon"
+	kdeconnectDeviceIface        = "org.kde.kdeconnect.device"
+	kdeconnectBatteryIface       = "org.kde.kdeconnect.device.battery"
+	kdeconnectNotificationsIface = "org.kde.kdeconnect.device.notifications"
+	kdeconnectNotificationIface  = "org.kde.kdeconnect.device.notifications.notification"
+)
+
+// The kdeconnectd plugin that sends desktop notifications to the phone
+const sendNotificationsPlugin = "kdeconnect_sendnotifications"
+
+// How long signals from kdeconnectd are gathered before the phones are
+// read again. A phone connecting sends a burst of them.
+const phoneRefreshDelay = 300 * time.Millisecond
+
+// Phone is a device paired through KDE Connect
+type Phone struct {
+	ID            string
+	Name          string
+	Reachable     bool
+	Charge        int // Percent, -1 when the battery isn't reported
+	Charging      bool
+	Mirroring     bool // Desktop notifications are sent to it
+	Notifications []PhoneNotification
+}
+
+// PhoneNotification is a notification showing on a phone
+type PhoneNotification struct {
+	ID          string
+	App         string
+	Title       string
+	Text        string
+	Dismissable bool
+}
+
+// describe returns the phone's state for the tooltip and popover
+func (ph Phone) describe() string {
+	if !ph.Reachable {
+		return ph.Name + ": not connected"
+	}
+	parts := []string{ph.Name + ":"}
+	if ph.Charge >= 0 {
+		battery := fmt.Sprintf("%d%%", ph.Charge)
+		if ph.Charging {
+			battery += ", charging"
+		}
+		parts = append(parts, battery)
+	}
+	if n := len(ph.Notifications); n == 1 {
+		parts = append(parts, "1 notification")
+	} else if n > 1 {
+		parts = append(parts, fmt.Sprintf("%d notifications", n))
+	}
+	if len(parts) == 1 {
+		return ph.Name + ": connected"
+	}
+	return parts[0] + " " + strings.Join(parts[1:], ", ")
+}
+
+// readPhones asks kdeconnectd for the paired devices. It returns nothing
+// while kdeconnectd isn't running, and doesn't start it.
+func readPhones(conn *dbus.Conn) []Phone {
+	var running bool
+	if err := conn.BusObject().Call("org.freedesktop.DBus.NameHasOwner", 0, kdeconnectName).Store(&running); err != nil || !running {
+		return nil
+	}
+
+	var ids []string
+	daemon := conn.Object(kdeconnectName, kdeconnectPath)
+	// devices(onlyReachable, onlyPaired)
+	if err := daemon.Call(kdeconnectDaemonIface+".devices", dbus.FlagNoAutoStart, false, true).Store(&ids); err != nil {
+		fmt.Fprintf(os.Stderr, "raven-shell: failed to list KDE Connect devices: %v\n", err)
+		return nil
+	}
+
+	phones := make([]Phone, 0, len(ids))
+	for _, id := range ids {
+		phones = append(phones, readPhone(conn, id))
+	}
+	sort.Slice(phones, func(i, j int) bool {
+		return strings.ToLower(phones[i].Name) < strings.ToLower(phones[j].Name)
+	})
+	return phones
+}
+
+// readPhone reads one device and, while it is connected, its battery and
+// notifications. Plugins that are turned off leave their part empty.
+func readPhone(conn *dbus.Conn, id string) Phone {
+	path := kdeconnectPath + "/devices/" + dbus.ObjectPath(id)
+	phone := Phone{ID: id, Name: id, Charge: -1}
+
+	device := conn.Object(kdeconnectName, path)
+	if v, err := device.GetProperty(kdeconnectDeviceIface + ".name"); err == nil {
+		if name, ok := v.Value().(string); ok && name != "" {
+			phone.Name = name
+		}
+	}
+	if v, err := device.GetProperty(kdeconnectDeviceIface + ".isReachable"); err == nil {
+		phone.Reachable, _ = v.Value().(bool)
+	}
+	device.Call(kdeconnectDeviceIface+".isPluginEnabled", dbus.FlagNoAutoStart, sendNotificationsPlugin).Store(&phone.Mirroring)
+	if !phone.Reachable {
+		return phone
+	}
+
+	battery := conn.Object(kdeconnectName, path+"/battery")
+	if v, err := battery.GetProperty(kdeconnectBatteryIface + ".charge"); err == nil {
+		if charge, ok := v.Value().(int32); ok && charge >= 0 {
+			phone.Charge = int(charge)
+		}
+	}
+	if v, err := battery.GetProperty(kdeconnectBatteryIface + ".isCharging"); err == nil {
+		phone.Charging, _ = v.Value().(bool)
+	}
+
+	var notificationIDs []string
+	notifications := conn.Object(kdeconnectName, path+"/notifications")
+	if err := notifications.Call(kdeconnectNotificationsIface+".activeNotifications", dbus.FlagNoAutoStart).Store(&notificationIDs); err != nil {
+		return phone
+	}
+	for _, nid := range notificationIDs {
+		var props map[string]dbus.Variant
+		obj := conn.Object(kdeconnectName, path+"/notifications/"+dbus.ObjectPath(nid))
+		if err := obj.Call("org.freedesktop.DBus.Properties.GetAll", dbus.FlagNoAutoStart, kdeconnectNotificationIface).Store(&props); err != nil {
+			continue
+		}
+		n := PhoneNotification{ID: nid}
+		n.App, _ = props["appName"].Value().(string)
+		n.Title, _ = props["title"].Value().(string)
+		n.Text, _ = props["text"].Value().(string)
+		n.Dismissable, _ = props["dismissable"].Value().(bool)
+		// Older phones only send a ticker, "title: text"
+		if n.Title == "" && n.Text == "" {
+			n.Text, _ = props["ticker"].Value().(string)
+		}
+		phone.Notifications = append(phone.Notifications, n)
+	}
+	return phone
+}
+
+// monitorPhones follows kdeconnectd on the session bus and keeps the phone
+// indicator and popover current. kdeconnectd may start after the panel or
+// not at all; the indicator stays hidden until a paired phone connects.
+func (p *RavenPanel) monitorPhones() {
+	conn, err := dbus.ConnectSessionBus()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "raven-shell: phones unavailable: %v\n", err)
+		return
+	}
+	err = conn.AddMatchSignal(dbus.WithMatchSender(kdeconnectName))
+	if err == nil {
+		err = conn.AddMatchSignal(
+			dbus.WithMatchInterface("org.freedesktop.DBus"),
+			dbus.WithMatchMember("NameOwnerChanged"),
+			dbus.WithMatchArg(0, kdeconnectName),
+		)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "raven-shell: phones unavailable: %v\n", err)
+		return
+	}
+
+	p.mu.Lock()
+	p.phoneConn = conn
+	p.mu.Unlock()
+
+	signals := make(chan *dbus.Signal, 64)
+	conn.Signal(signals)
+
+	refresh := func() {
+		phones := readPhones(conn)
+		p.mu.Lock()
+		p.phones = phones
+		p.mu.Unlock()
+		glib.IdleAdd(func() {
+			p.updatePhoneIndicator()
+			if p.phoneWindow != nil {
+				p.phoneWindow.SetChild(p.createPhoneList())
+			}
+		})
+	}
+	refresh()
+
+	var delay <-chan time.Time
+	for {
+		select {
+		case _, ok := <-signals:
+			if !ok {
+				return
+			}
+			if delay == nil {
+				delay = time.After(phoneRefreshDelay)
+			}
+		case <-delay:
+			delay = nil
+			refresh()
+		}
+	}
+}
+
+// phoneShown reports whether the phone's battery and notifications are
+// shown in the panel
+func (p *RavenPanel) phoneShown(id string) bool {
+	return !slices.Contains(p.ravenSettings.PhoneHiddenDevices, id)
+}
+
+// setPhoneShown shows or hides a phone's battery and notifications in the
+// panel, saved as phone_hidden_devices in settings.json
+func (p *RavenPanel) setPhoneShown(id string, shown bool) {
+	hidden := slices.DeleteFunc(slices.Clone(p.ravenSettings.PhoneHiddenDevices), func(d string) bool {
+		return d == id
+	})
+	if !shown {
+		hidden = append(hidden, id)
+	}
+	p.ravenSettings.PhoneHiddenDevices = hidden
+	if err := updateSettingsFile(p.ravenSettingsPath, map[string]any{"phone_hidden_devices": hidden}); err != nil {
+		fmt.Fprintf(os.Stderr, "raven-shell: failed to save phone settings: %v\n", err)
+	}
+	p.updatePhoneIndicator()
+	if p.phoneWindow != nil {
+		p.phoneWindow.SetChild(p.createPhoneList())
+	}
+}
+
+// callPhone calls method on one of a phone's kdeconnectd objects off the
+// main loop. kdeconnectd signals the change, which redraws the popover.
+func (p *RavenPanel) callPhone(path dbus.ObjectPath, method string, args ...any) {
+	p.mu.RLock()
+	conn := p.phoneConn
+	p.mu.RUnlock()
+	if conn == nil {
+		return
+	}
+	go func() {
+		if err := conn.Object(kdeconnectName, path).Call(method, dbus.FlagNoAutoStart, args...).Err; err != nil {
+			fmt.Fprintf(os.Stderr, "raven-shell: %s failed: %v\n", method, err)
+		}
+	}()
+}
+
+// setPhoneMirroring turns sending desktop notifications to a phone on or
+// off. kdeconnectd keeps the setting.
+func (p *RavenPanel) setPhoneMirroring(id string, on bool) {
+	path := kdeconnectPath + "/devices/" + dbus.ObjectPath(id)
+	p.callPhone(path, kdeconnectDeviceIface+".setPluginEnabled", sendNotificationsPlugin, on)
+}
+
+// dismissPhoneNotification removes a notification from the phone
+func (p *RavenPanel) dismissPhoneNotification(phoneID, notificationID string) {
+	path := kdeconnectPath + "/devices/" + dbus.ObjectPath(phoneID) + "/notifications/" + dbus.ObjectPath(notificationID)
+	p.callPhone(path, kdeconnectNotificationIface+".dismiss")
+}
+
+// createPhoneIndicator builds the panel button shown while a paired phone
+// is connected
+func (p *RavenPanel) createPhoneIndicator() *gtk.Button {
+	box := gtk.NewBox(gtk.OrientationHorizontal, 4)
+	box.Append(gtk.NewImageFromIconName("phone-symbolic"))
+	p.phoneLabel = gtk.NewLabel("")
+	box.Append(p.phoneLabel)
+
+	p.phoneBtn = gtk.NewButton()
+	p.phoneBtn.SetChild(box)
+	p.phoneBtn.AddCSSClass("phone-indicator")
+	p.phoneBtn.ConnectClicked(func() {
+		p.showPhonePopover()
+	})
+	p.updatePhoneIndicator()
+	return p.phoneBtn
+}
+
+// updatePhoneIndicator shows the battery of the first connected phone and
+// the number of notifications on the phones shown in the panel
+func (p *RavenPanel) updatePhoneIndicator() {
+	if p.phoneBtn == nil {
+		return
+	}
+
+	p.mu.RLock()
+	phones := p.phones
+	p.mu.RUnlock()
+
+	connected := false
+	charge := -1
+	count := 0
+	var lines []string
+	for _, phone := range phones {
+		if !phone.Reachable {
+			continue
+		}
+		connected = true
+		if !p.phoneShown(phone.ID) {
+			continue
+		}
+		if charge < 0 {
+			charge = phone.Charge
+		}
+		count += len(phone.Notifications)
+		lines = append(lines, phone.describe())
+	}
+
+	var parts []string
+	if charge >= 0 {
+		parts = append(parts, fmt.Sprintf("%d%%", charge))
+	}
+	if count > 0 {
+		parts = append(parts, fmt.Sprint(count))
+	}
+	p.phoneLabel.SetText(strings.Join(parts, " · "))
+	p.phoneLabel.SetVisible(len(parts) > 0)
+
+	text := "Phone connected"
+	if len(lines) > 0 {
+		text = strings.Join(lines, "\n")
+	}
+	p.phoneBtn.SetTooltipText(text)
+	setAccessibleLabel(p.phoneBtn, strings.ReplaceAll(text, "\n", ". "))
+	p.phoneBtn.SetVisible(connected)
+	if !connected {
+		p.closePhonePopover()
+	}
+}
+
+func (p *RavenPanel) closePhonePopover() {
+	if p.phoneWindow != nil {
+		p.phoneWindow.Close()
+		p.phoneWindow = nil
+	}
+}
+
+// showPhonePopover toggles the popup listing the paired phones with their
+// notifications and settings
+func (p *RavenPanel) showPhonePopover() {
+	if p.phoneWindow != nil {
+		p.closePhonePopover()
+		return
+	}
+	p.closeSettingsMenu()
+	p.closePowerMenu()
+	p.closePrintJobs()
+	p.closeBatteryPopover()
+
+	p.phoneWindow = gtk.NewWindow()
+	p.phoneWindow.SetTitle("Phones")
+	p.phoneWindow.SetDecorated(false)
+	p.phoneWindow.SetDefaultSize(320, -1)
+	p.initPopupWindow(p.phoneWindow, p.phoneBtn)
+
+	p.phoneWindow.SetChild(p.createPhoneList())
+
+	keyController := gtk.NewEventControllerKey()
+	keyController.ConnectKeyPressed(func(keyval, keycode uint, state gdk.ModifierType) bool {
+		if keyval == gdk.KEY_Escape {
+			p.closePhonePopover()
+			return true
+		}
+		return false
+	})
+	p.phoneWindow.AddController(keyController)
+
+	focusController := gtk.NewEventControllerFocus()
+	focusController.ConnectLeave(func() {
+		glib.TimeoutAdd(100, func() bool {
+			p.closePhonePopover()
+			return false
+		})
+	})
+	p.phoneWindow.AddController(focusController)
+
+	p.phoneWindow.SetApplication(p.app)
+	p.phoneWindow.Present()
+}
+
+// createPhoneList builds the popup content: for each paired phone its
+// state, whether it is shown in the panel and sent desktop notifications,
+// and the notifications showing on it
+func (p *RavenPanel) createPhoneList() *gtk.Box {
+	box := gtk.NewBox(gtk.OrientationVertical, 4)
+	box.AddCSSClass("settings-menu")
+	box.SetMarginTop(8)
+	box.SetMarginBottom(8)
+	box.SetMarginStart(8)
+	box.SetMarginEnd(8)
+
+	p.mu.RLock()
+	phones := append([]Phone(nil), p.phones...)
+	p.mu.RUnlock()
+
+	for _, phone := range phones {
+		title := gtk.NewLabel(phone.Name)
+		title.AddCSSClass("settings-section-label")
+		title.SetHAlign(gtk.AlignStart)
+		box.Append(title)
+
+		shown := p.phoneShown(phone.ID)
+		if shown {
+			state := gtk.NewLabel(strings.TrimPrefix(phone.describe(), phone.Name+": "))
+			state.AddCSSClass("power-row")
+			state.SetHAlign(gtk.AlignStart)
+			box.Append(state)
+		}
+
+		id := phone.ID
+		showBtn := gtk.NewButton()
+		showBtn.SetLabel("Show in Panel")
+		showBtn.SetTooltipText("Show the phone's battery and notifications here")
+		if shown {
+			showBtn.AddCSSClass("quick-toggle-active")
+		}
+		showBtn.ConnectClicked(func() {
+			p.setPhoneShown(id, !shown)
+		})
+		box.Append(showBtn)
+
+		mirroring := phone.Mirroring
+		mirrorBtn := gtk.NewButton()
+		mirrorBtn.SetLabel("Send Desktop Notifications")
+		mirrorBtn.SetTooltipText("Show this computer's notifications on the phone")
+		if mirroring {
+			mirrorBtn.AddCSSClass("quick-toggle-active")
+		}
+		mirrorBtn.ConnectClicked(func() {
+			mirrorBtn.SetSensitive(false)
+			p.setPhoneMirroring(id, !mirroring)
+		})
+		box.Append(mirrorBtn)
+
+		if !shown || !phone.Reachable {
+			continue
+		}
+		for _, n := range phone.Notifications {
+			box.Append(p.createPhoneNotificationRow(id, n))
+		}
+	}
+
+	return box
+}
+
+// createPhoneNotificationRow shows one of a phone's notifications, with a
+// button dismissing it on the phone when the app allows that
+func (p *RavenPanel) createPhoneNotificationRow(phoneID string, n PhoneNotification) *gtk.Box {
+	row := gtk.NewBox(gtk.OrientationHorizontal, 8)
+	row.AddCSSClass("print-job")
+
+	info := gtk.NewBox(gtk.OrientationVertical, 2)
+	info.SetHExpand(true)
+
+	heading := n.App
+	if n.Title != "" {
+		heading = n.App + ": " + n.Title
+	}
+	name := gtk.NewLabel(heading)
+	name.SetHAlign(gtk.AlignStart)
+	name.SetEllipsize(3) // PANGO_ELLIPSIZE_END
+	name.SetMaxWidthChars(36)
+	info.Append(name)
+
+	if n.Text != "" {
+		text := gtk.NewLabel(n.Text)
+		text.AddCSSClass("print-job-detail")
+		text.SetHAlign(gtk.AlignStart)
+		text.SetWrap(true)
+		text.SetMaxWidthChars(40)
+		text.SetXAlign(0)
+		info.Append(text)
+	}
+	row.Append(info)
+
+	if n.Dismissable {
+		dismissBtn := gtk.NewButton()
+		dismissBtn.SetIconName("window-close-symbolic")
+		dismissBtn.AddCSSClass("context-menu-close")
+		dismissBtn.SetTooltipText("Dismiss on the phone")
+		setAccessibleLabel(dismissBtn, "Dismiss "+heading)
+		dismissBtn.SetVAlign(gtk.AlignCenter)
+		dismissBtn.ConnectClicked(func() {
+			dismissBtn.SetSensitive(false)
+			p.dismissPhoneNotification(phoneID, n.ID)
+		})
+		row.Append(dismissBtn)
+	}
+	return row
+}
@@ -0,0 +1,279 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/diamondburned/gotk4/pkg/gdk/v4"
+	"github.com/diamondburned/gotk4/pkg/glib/v2"
+	"github.com/diamondburned/gotk4/pkg/gtk/v4"
+)
+
+// How often CUPS is polled for print jobs
+const printJobPollInterval = 3 * time.Second
+
+// PrintJob is a queued or printing CUPS job as reported by lpstat
+type PrintJob struct {
+	ID       string // CUPS job name, e.g. "LaserJet-42"
+	Printer  string
+	User     string
+	Size     int64
+	Printing bool
+}
+
+// Title returns a short label for the job list
+func (j PrintJob) Title() string {
+	if number, ok := strings.CutPrefix(j.ID, j.Printer+"-"); ok {
+		return fmt.Sprintf("Job %s on %s", number, j.Printer)
+	}
+	return j.ID
+}
+
+// listPrintJobs returns the not-yet-completed jobs of all printers
+func listPrintJobs() ([]PrintJob, error) {
+	output, err := exec.Command("lpstat", "-o").Output()
+	if err != nil {
+		return nil, err
+	}
+
+	// lpstat -p names the job each printer is working on
+	printing := make(map[string]bool)
+	if status, err := exec.Command("lpstat", "-p").Output(); err == nil {
+		for _, line := range strings.Split(string(status), "\n") {
+			_, rest, ok := strings.Cut(line, "now printing ")
+			if !ok {
+				continue
+			}
+			job, _, _ := strings.Cut(rest, ".")
+			printing[strings.TrimSpace(job)] = true
+		}
+	}
+
+	var jobs []PrintJob
+	for _, line := range strings.Split(string(output), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 3 {
+			continue
+		}
+
+		job := PrintJob{ID: fields[0], User: fields[1], Printing: printing[fields[0]]}
+		job.Size, _ = strconv.ParseInt(fields[2], 10, 64)
+		if i := strings.LastIndexByte(job.ID, '-'); i > 0 {
+			job.Printer = job.ID[:i]
+		}
+		jobs = append(jobs, job)
+	}
+	return jobs, nil
+}
+
+// cancelPrintJob removes a job from its queue
+func cancelPrintJob(id string) error {
+	if output, err := exec.Command("cancel", id).CombinedOutput(); err != nil {
+		return fmt.Errorf("%v: %s", err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// createPrintIndicator builds the panel button shown while jobs are queued
+func (p *RavenPanel) createPrintIndicator() *gtk.Button {
+	p.printBtn = gtk.NewButton()
+	p.printBtn.SetIconName("printer-symbolic")
+	p.printBtn.AddCSSClass("print-indicator")
+	p.printBtn.ConnectClicked(func() {
+		p.showPrintJobs()
+	})
+	p.updatePrintIndicator()
+	return p.printBtn
+}
+
+// updatePrintIndicator shows the printer button only while jobs exist
+func (p *RavenPanel) updatePrintIndicator() {
+	if p.printBtn == nil {
+		return
+	}
+
+	p.mu.RLock()
+	count := len(p.printJobs)
+	p.mu.RUnlock()
+
+	p.printBtn.SetVisible(count > 0)
+	text := fmt.Sprintf("%d print jobs", count)
+	if count == 1 {
+		text = "1 print job"
+	}
+	p.printBtn.SetTooltipText(text)
+	setAccessibleLabel(p.printBtn, text)
+}
+
+// monitorPrintJobs polls CUPS and keeps the indicator and job list current.
+// It stops quietly on systems without the CUPS client tools.
+func (p *RavenPanel) monitorPrintJobs() {
+	if _, err := exec.LookPath("lpstat"); err != nil {
+		return
+	}
+
+	var last string
+	ticker := time.NewTicker(printJobPollInterval)
+	defer ticker.Stop()
+
+	for ; ; <-ticker.C {
+		jobs, err := listPrintJobs()
+		if err != nil {
+			// The scheduler may not be running; treat as an empty queue
+			jobs = nil
+		}
+
+		key := fmt.Sprint(jobs)
+		if key == last {
+			continue
+		}
+		last = key
+
+		p.mu.Lock()
+		p.printJobs = jobs
+		p.mu.Unlock()
+
+		glib.IdleAdd(func() {
+			p.updatePrintIndicator()
+			if p.printWindow == nil {
+				return
+			}
+			if len(jobs) == 0 {
+				p.closePrintJobs()
+			} else {
+				p.printWindow.SetChild(p.createPrintJobList())
+			}
+		})
+	}
+}
+
+func (p *RavenPanel) closePrintJobs() {
+	if p.printWindow != nil {
+		p.printWindow.Close()
+		p.printWindow = nil
+	}
+}
+
+// showPrintJobs toggles the popup listing print jobs
+func (p *RavenPanel) showPrintJobs() {
+	if p.printWindow != nil {
+		p.closePrintJobs()
+		return
+	}
+	p.closeSettingsMenu()
+	p.closePowerMenu()
+
+	p.printWindow = gtk.NewWindow()
+	p.printWindow.SetTitle("Print Jobs")
+	p.printWindow.SetDecorated(false)
+	p.printWindow.SetDefaultSize(280, -1)
+	p.initPopupWindow(p.printWindow, p.printBtn)
+
+	p.printWindow.SetChild(p.createPrintJobList())
+
+	keyController := gtk.NewEventControllerKey()
+	keyController.ConnectKeyPressed(func(keyval, keycode uint, state gdk.ModifierType) bool {
+		if keyval == gdk.KEY_Escape {
+			p.closePrintJobs()
+			return true
+		}
+		return false
+	})
+	p.printWindow.AddController(keyController)
+
+	focusController := gtk.NewEventControllerFocus()
+	focusController.ConnectLeave(func() {
+		glib.TimeoutAdd(100, func() bool {
+			p.closePrintJobs()
+			return false
+		})
+	})
+	p.printWindow.AddController(focusController)
+
+	p.printWindow.SetApplication(p.app)
+	p.printWindow.Present()
+}
+
+// createPrintJobList builds the popup content: one row per job with its
+// state and a cancel button. CUPS doesn't report page progress through
+// lpstat, so the printing job gets an activity bar.
+func (p *RavenPanel) createPrintJobList() *gtk.Box {
+	box := gtk.NewBox(gtk.OrientationVertical, 4)
+	box.AddCSSClass("settings-menu")
+	box.SetMarginTop(8)
+	box.SetMarginBottom(8)
+	box.SetMarginStart(8)
+	box.SetMarginEnd(8)
+
+	title := gtk.NewLabel("Print Jobs")
+	title.AddCSSClass("settings-section-label")
+	title.SetHAlign(gtk.AlignStart)
+	box.Append(title)
+
+	p.mu.RLock()
+	jobs := append([]PrintJob(nil), p.printJobs...)
+	p.mu.RUnlock()
+
+	for _, job := range jobs {
+		row := gtk.NewBox(gtk.OrientationHorizontal, 8)
+		row.AddCSSClass("print-job")
+
+		info := gtk.NewBox(gtk.OrientationVertical, 2)
+		info.SetHExpand(true)
+
+		name := gtk.NewLabel(job.Title())
+		name.SetHAlign(gtk.AlignStart)
+		info.Append(name)
+
+		state := "Queued"
+		if job.Printing {
+			state = "Printing"
+		}
+		detail := gtk.NewLabel(fmt.Sprintf("%s · %s · %d KB", state, job.User, (job.Size+1023)/1024))
+		detail.AddCSSClass("print-job-detail")
+		detail.SetHAlign(gtk.AlignStart)
+		info.Append(detail)
+
+		if job.Printing {
+			progress := gtk.NewProgressBar()
+			progress.SetPulseStep(0.1)
+			glib.TimeoutAdd(150, func() bool {
+				if progress.Root() == nil {
+					return false
+				}
+				progress.Pulse()
+				return true
+			})
+			info.Append(progress)
+		}
+
+		row.Append(info)
+
+		id := job.ID
+		cancelBtn := gtk.NewButton()
+		cancelBtn.SetIconName("process-stop-symbolic")
+		cancelBtn.AddCSSClass("context-menu-close")
+		cancelBtn.SetTooltipText("Cancel")
+		cancelBtn.SetVAlign(gtk.AlignCenter)
+		cancelBtn.ConnectClicked(func() {
+			cancelBtn.SetSensitive(false)
+			go func() {
+				if err := cancelPrintJob(id); err != nil {
+					fmt.Fprintf(os.Stderr, "raven-shell: failed to cancel print job %s: %v\n", id, err)
+					glib.IdleAdd(func() {
+						cancelBtn.SetSensitive(true)
+					})
+				}
+			}()
+		})
+		row.Append(cancelBtn)
+
+		box.Append(row)
+	}
+
+	return box
+}
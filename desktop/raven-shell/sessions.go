@@ -0,0 +1,269 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/diamondburned/gotk4/pkg/gtk/v4"
+)
+
+// LogindSession describes a login session as reported by loginctl
+type LogindSession struct {
+	ID     string
+	User   string
+	Seat   string
+	TTY    string
+	VTNr   int
+	Type   string // wayland, x11, tty, ...
+	Class  string // user, greeter, lock-screen, ...
+	State  string // active, online, closing
+	Remote bool
+}
+
+// Description returns a short human-readable summary of the session
+func (s LogindSession) Description() string {
+	where := s.TTY
+	if s.VTNr > 0 {
+		where = fmt.Sprintf("VT %d", s.VTNr)
+	}
+	if s.Remote {
+		where = "remote"
+	}
+	if where == "" {
+		where = s.Seat
+	}
+	return fmt.Sprintf("%s on %s (%s, %s)", s.User, where, s.Type, s.State)
+}
+
+// listLogindSessions enumerates sessions known to systemd-logind
+func listLogindSessions() ([]LogindSession, error) {
+	output, err := exec.Command("loginctl", "list-sessions", "--no-legend").Output()
+	if err != nil {
+		return nil, err
+	}
+
+	var sessions []LogindSession
+	for _, line := range strings.Split(string(output), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+
+		props, err := exec.Command("loginctl", "show-session", fields[0],
+			"-p", "Id", "-p", "Name", "-p", "Seat", "-p", "TTY", "-p", "VTNr",
+			"-p", "Type", "-p", "Class", "-p", "State", "-p", "Remote").Output()
+		if err != nil {
+			continue
+		}
+
+		session := LogindSession{ID: fields[0]}
+		for _, prop := range strings.Split(string(props), "\n") {
+			key, value, ok := strings.Cut(strings.TrimSpace(prop), "=")
+			if !ok {
+				continue
+			}
+			switch key {
+			case "Name":
+				session.User = value
+			case "Seat":
+				session.Seat = value
+			case "TTY":
+				session.TTY = value
+			case "VTNr":
+				session.VTNr, _ = strconv.Atoi(value)
+			case "Type":
+				session.Type = value
+			case "Class":
+				session.Class = value
+			case "State":
+				session.State = value
+			case "Remote":
+				session.Remote = value == "yes"
+			}
+		}
+		sessions = append(sessions, session)
+	}
+
+	return sessions, nil
+}
+
+// currentSessionID resolves the logind session the shell belongs to.
+// logind maps our own process to its session, which works even when the
+// shell was started without XDG_SESSION_ID in its environment.
+func currentSessionID() (string, error) {
+	output, err := exec.Command("loginctl", "show-session", "self", "-p", "Id", "--value").Output()
+	if err == nil {
+		if id := strings.TrimSpace(string(output)); id != "" {
+			return id, nil
+		}
+	}
+
+	if id := os.Getenv("XDG_SESSION_ID"); id != "" {
+		return id, nil
+	}
+	return "", fmt.Errorf("could not determine the current login session")
+}
+
+// otherUserSessions returns the user sessions other than the one we run in.
+// It fails rather than guess when the current session is unknown, since the
+// result is used to terminate sessions.
+func otherUserSessions(sessions []LogindSession) ([]LogindSession, error) {
+	current, err := currentSessionID()
+	if err != nil {
+		return nil, err
+	}
+
+	var others []LogindSession
+	for _, s := range sessions {
+		if s.ID == current || s.Class != "user" || s.State == "closing" {
+			continue
+		}
+		others = append(others, s)
+	}
+	return others, nil
+}
+
+// greeterVT picks the VT to switch to for a new login: an existing greeter
+// session if there is one, otherwise the first VT without a session.
+func greeterVT(sessions []LogindSession) int {
+	used := make(map[int]bool)
+	for _, s := range sessions {
+		if s.Class == "greeter" && s.VTNr > 0 {
+			return s.VTNr
+		}
+		used[s.VTNr] = true
+	}
+	for vt := 1; vt <= 12; vt++ {
+		if !used[vt] {
+			return vt
+		}
+	}
+	return 0
+}
+
+// lockSession locks the current session before the greeter is shown.
+// swaylock -f only returns once the screen is locked; hyprlock stays in the
+// foreground, so it is started and given a moment to take the screen.
+func lockSession(sessionID string) error {
+	if path, err := exec.LookPath("swaylock"); err == nil {
+		return exec.Command(path, "-f").Run()
+	}
+	if path, err := exec.LookPath("hyprlock"); err == nil {
+		if err := exec.Command(path).Start(); err != nil {
+			return err
+		}
+		time.Sleep(time.Second)
+		return nil
+	}
+	return exec.Command("loginctl", "lock-session", sessionID).Run()
+}
+
+// switchUser locks the current session and brings up a greeter so another
+// user can log in, through the display manager if it supports switching or
+// otherwise by switching to a free VT.
+func switchUser() error {
+	sessionID, err := currentSessionID()
+	if err != nil {
+		return err
+	}
+	if err := lockSession(sessionID); err != nil {
+		return fmt.Errorf("failed to lock the session: %w", err)
+	}
+
+	if path, err := exec.LookPath("dm-tool"); err == nil {
+		return exec.Command(path, "switch-to-greeter").Run()
+	}
+
+	sessions, err := listLogindSessions()
+	if err != nil {
+		return fmt.Errorf("failed to list sessions: %w", err)
+	}
+	vt := greeterVT(sessions)
+	if vt == 0 {
+		return fmt.Errorf("no free virtual terminal for a new login")
+	}
+	return exec.Command("chvt", strconv.Itoa(vt)).Run()
+}
+
+// notifySessionError reports a failed session action, since the power menu
+// is already closed by the time it completes
+func notifySessionError(summary string, err error) {
+	fmt.Fprintf(os.Stderr, "raven-shell: %s: %v\n", strings.ToLower(summary), err)
+	exec.Command("notify-send", "-a", "Raven Shell", "-u", "critical", "-i", "dialog-error", summary, err.Error()).Start()
+}
+
+// terminateSessions ends the given logind sessions. Terminating another
+// user's session is authorized by polkit.
+func terminateSessions(sessions []LogindSession) error {
+	var failed []string
+	for _, s := range sessions {
+		if err := exec.Command("loginctl", "terminate-session", s.ID).Run(); err != nil {
+			failed = append(failed, s.ID)
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("failed to terminate sessions: %s", strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+// createSessionConfirm builds the power menu view that warns before logging
+// out other sessions, since any unsaved work in them will be lost
+func (p *RavenPanel) createSessionConfirm(sessions []LogindSession) *gtk.Box {
+	box := gtk.NewBox(gtk.OrientationVertical, 4)
+	box.AddCSSClass("settings-menu")
+	box.SetMarginTop(8)
+	box.SetMarginBottom(8)
+	box.SetMarginStart(8)
+	box.SetMarginEnd(8)
+
+	title := gtk.NewLabel("Log Out Other Sessions")
+	title.AddCSSClass("settings-section-label")
+	title.SetHAlign(gtk.AlignStart)
+	box.Append(title)
+
+	for _, s := range sessions {
+		label := gtk.NewLabel(s.Description())
+		label.SetHAlign(gtk.AlignStart)
+		label.SetMarginStart(16)
+		box.Append(label)
+	}
+
+	warning := gtk.NewLabel("Unsaved work in these sessions will be lost.")
+	warning.AddCSSClass("session-warning")
+	warning.SetHAlign(gtk.AlignStart)
+	warning.SetMarginStart(16)
+	warning.SetMarginTop(6)
+	box.Append(warning)
+
+	buttons := gtk.NewBox(gtk.OrientationHorizontal, 4)
+	buttons.SetHomogeneous(true)
+	buttons.SetMarginTop(6)
+
+	cancelBtn := gtk.NewButton()
+	cancelBtn.SetLabel("Cancel")
+	cancelBtn.ConnectClicked(func() {
+		p.closePowerMenu()
+	})
+	buttons.Append(cancelBtn)
+
+	confirmBtn := gtk.NewButton()
+	confirmBtn.SetLabel("Log Out")
+	confirmBtn.AddCSSClass("context-menu-close")
+	confirmBtn.ConnectClicked(func() {
+		p.closePowerMenu()
+		go func() {
+			if err := terminateSessions(sessions); err != nil {
+				notifySessionError("Log out other sessions failed", err)
+			}
+		}()
+	})
+	buttons.Append(confirmBtn)
+
+	box.Append(buttons)
+	return box
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+
+	"github.com/diamondburned/gotk4/pkg/glib/v2"
+	"github.com/godbus/dbus/v5"
+)
+
+// Where raven-settingsd announces changes to settings.json
+const (
+	settingsdPath  = dbus.ObjectPath("/org/ravenlinux/Settings")
+	settingsdIface = "org.ravenlinux.Settings"
+)
+
+// watchSettingsChanges calls changed on the main loop with the keys of
+// settings.json that raven-settingsd reports changed
+func watchSettingsChanges(changed func(keys []string)) error {
+	conn, err := dbus.ConnectSessionBus()
+	if err != nil {
+		return err
+	}
+	err = conn.AddMatchSignal(
+		dbus.WithMatchObjectPath(settingsdPath),
+		dbus.WithMatchInterface(settingsdIface),
+		dbus.WithMatchMember("Changed"),
+	)
+	if err != nil {
+		return err
+	}
+
+	signals := make(chan *dbus.Signal, 16)
+	conn.Signal(signals)
+	go func() {
+		for sig := range signals {
+			if sig.Name != settingsdIface+".Changed" || len(sig.Body) == 0 {
+				continue
+			}
+			if keys, ok := sig.Body[0].([]string); ok {
+				glib.IdleAdd(func() {
+					changed(keys)
+				})
+			}
+		}
+	}()
+	return nil
+}
+
+// settingsChanged applies settings changed by another component, such as
+// the panel position set in raven-settings-menu
+func (p *RavenPanel) settingsChanged(keys []string) {
+	settings := p.ravenSettings
+	if data, err := os.ReadFile(p.ravenSettingsPath); err == nil {
+		json.Unmarshal(data, &settings)
+	}
+
+	themeChanged := false
+	for _, key := range keys {
+		switch key {
+		case "panel_position":
+			p.ravenSettings.PanelPosition = settings.PanelPosition
+			for orientation, name := range orientationNames {
+				if name == settings.PanelPosition && orientation != p.orientation {
+					p.orientation = orientation
+					p.rebuildPanel()
+				}
+			}
+		case "theme", "theme_schedule", "light_theme_start", "dark_theme_start", "latitude", "longitude", "wallpaper_path":
+			themeChanged = true
+		case "phone_hidden_devices":
+			p.ravenSettings.PhoneHiddenDevices = settings.PhoneHiddenDevices
+			p.updatePhoneIndicator()
+		case "announce_events":
+			p.ravenSettings.AnnounceEvents = settings.AnnounceEvents
+			if settings.AnnounceEvents {
+				p.startAnnouncements()
+			}
+			p.announcing.Store(settings.AnnounceEvents)
+		}
+	}
+	if themeChanged {
+		p.checkTheme()
+	}
+}
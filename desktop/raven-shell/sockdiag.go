@@ -0,0 +1,143 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"syscall"
+)
+
+// Constants from linux/sock_diag.h and linux/unix_diag.h
+const (
+	netlinkSockDiag   = 4
+	sockDiagByFamily  = 20
+	udiagShowName     = 0x01
+	udiagShowPeer     = 0x04
+	udiagShowRQLen    = 0x10
+	unixDiagName      = 0
+	unixDiagPeer      = 2
+	unixDiagRQLen     = 4
+	unixStateEstab    = 1
+	unixDiagMsgLen    = 16
+	unixDiagReqLen    = 24
+	rtAttrHeaderLen   = 4
+	sockDiagRecvBytes = 32 * 1024
+)
+
+// unixSocket is one entry of the kernel's unix socket table
+type unixSocket struct {
+	name    string
+	peer    uint32
+	rqueue  uint32
+	estab   bool
+	hasPeer bool
+}
+
+// waylandClientQueues maps the inode of every client socket connected to
+// the compositor at socketPath to the number of bytes waiting unread in it.
+// Accepted sockets carry the listening socket's path, so the compositor
+// side of each connection is found by name and the client side via peer.
+func waylandClientQueues(socketPath string) (map[uint64]uint32, error) {
+	sockets, err := dumpUnixSockets()
+	if err != nil {
+		return nil, err
+	}
+
+	queues := make(map[uint64]uint32)
+	for _, s := range sockets {
+		if !s.estab || !s.hasPeer || s.name != socketPath {
+			continue
+		}
+		if client, ok := sockets[s.peer]; ok {
+			queues[uint64(s.peer)] = client.rqueue
+		}
+	}
+	return queues, nil
+}
+
+// dumpUnixSockets lists unix sockets by inode through NETLINK_SOCK_DIAG
+func dumpUnixSockets() (map[uint32]unixSocket, error) {
+	fd, err := syscall.Socket(syscall.AF_NETLINK, syscall.SOCK_DGRAM|syscall.SOCK_CLOEXEC, netlinkSockDiag)
+	if err != nil {
+		return nil, err
+	}
+	defer syscall.Close(fd)
+
+	req := make([]byte, syscall.NLMSG_HDRLEN+unixDiagReqLen)
+	binary.NativeEndian.PutUint32(req[0:], uint32(len(req)))
+	binary.NativeEndian.PutUint16(req[4:], sockDiagByFamily)
+	binary.NativeEndian.PutUint16(req[6:], syscall.NLM_F_REQUEST|syscall.NLM_F_DUMP)
+	body := req[syscall.NLMSG_HDRLEN:]
+	body[0] = syscall.AF_UNIX
+	binary.NativeEndian.PutUint32(body[4:], 0xffffffff) // All states
+	binary.NativeEndian.PutUint32(body[12:], udiagShowName|udiagShowPeer|udiagShowRQLen)
+
+	if err := syscall.Sendto(fd, req, 0, &syscall.SockaddrNetlink{Family: syscall.AF_NETLINK}); err != nil {
+		return nil, err
+	}
+
+	sockets := make(map[uint32]unixSocket)
+	buf := make([]byte, sockDiagRecvBytes)
+	for {
+		n, _, err := syscall.Recvfrom(fd, buf, 0)
+		if err != nil {
+			return nil, err
+		}
+		msgs, err := syscall.ParseNetlinkMessage(buf[:n])
+		if err != nil {
+			return nil, err
+		}
+		for _, msg := range msgs {
+			switch msg.Header.Type {
+			case syscall.NLMSG_DONE:
+				return sockets, nil
+			case syscall.NLMSG_ERROR:
+				// ENOENT here means the kernel lacks unix_diag support
+				if len(msg.Data) >= 4 {
+					errno := -int32(binary.NativeEndian.Uint32(msg.Data))
+					return nil, fmt.Errorf("sock_diag dump failed: %w", syscall.Errno(errno))
+				}
+				return nil, fmt.Errorf("sock_diag dump failed")
+			}
+			if len(msg.Data) < unixDiagMsgLen {
+				continue
+			}
+			inode, s := parseUnixDiagMsg(msg.Data)
+			sockets[inode] = s
+		}
+	}
+}
+
+// parseUnixDiagMsg decodes a unix_diag_msg and its attributes
+func parseUnixDiagMsg(data []byte) (uint32, unixSocket) {
+	s := unixSocket{estab: data[2] == unixStateEstab}
+	inode := binary.NativeEndian.Uint32(data[4:])
+
+	attrs := data[unixDiagMsgLen:]
+	for len(attrs) >= rtAttrHeaderLen {
+		length := int(binary.NativeEndian.Uint16(attrs[0:]))
+		if length < rtAttrHeaderLen || length > len(attrs) {
+			break
+		}
+		payload := attrs[rtAttrHeaderLen:length]
+		switch binary.NativeEndian.Uint16(attrs[2:]) {
+		case unixDiagName:
+			s.name = string(payload)
+		case unixDiagPeer:
+			if len(payload) >= 4 {
+				s.peer = binary.NativeEndian.Uint32(payload)
+				s.hasPeer = true
+			}
+		case unixDiagRQLen:
+			if len(payload) >= 4 {
+				s.rqueue = binary.NativeEndian.Uint32(payload)
+			}
+		}
+		// Attributes are padded to 4 bytes
+		aligned := (length + 3) &^ 3
+		if aligned > len(attrs) {
+			break
+		}
+		attrs = attrs[aligned:]
+	}
+	return inode, s
+}
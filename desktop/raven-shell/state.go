@@ -0,0 +1,165 @@
+package main
+
+import (
+	"sort"
+	"time"
+
+	"github.com/diamondburned/gotk4/pkg/gtk/v4"
+)
+
+// ShellState is the snapshot returned by dump-state, for bug reports and
+// theme development
+type ShellState struct {
+	Panel    PanelState      `json:"panel"`
+	Widgets  *WidgetState    `json:"widgets,omitempty"` // Panel widget tree
+	Dock     []DockItemState `json:"dock"`
+	Popups   []string        `json:"popups"` // Popup windows currently open
+	Keybinds []ShellKeybind  `json:"keybinds"`
+	Settings RavenSettings   `json:"settings"` // settings.json as the shell read it
+	Config   PanelStatePaths `json:"config"`
+}
+
+// PanelState describes the panel window
+type PanelState struct {
+	Position string `json:"position"`
+	Size     int    `json:"size"`
+	Width    int    `json:"width"`
+	Height   int    `json:"height"`
+}
+
+// PanelStatePaths are the files the shell reads its configuration from
+type PanelStatePaths struct {
+	Dock     string `json:"dock"`
+	Settings string `json:"settings"`
+	Keybinds string `json:"keybinds"`
+}
+
+// WidgetState is one node of the widget tree, named as in CSS selectors
+type WidgetState struct {
+	Node     string         `json:"node"`
+	Name     string         `json:"name,omitempty"`
+	Classes  []string       `json:"classes,omitempty"`
+	Label    string         `json:"label,omitempty"`
+	Tooltip  string         `json:"tooltip,omitempty"`
+	Visible  bool           `json:"visible"`
+	Children []*WidgetState `json:"children,omitempty"`
+}
+
+// DockItemState is a dock item with its runtime state
+type DockItemState struct {
+	ID            string `json:"id"`
+	Name          string `json:"name"`
+	Command       string `json:"command"`
+	Icon          string `json:"icon"`
+	Pinned        bool   `json:"pinned"`
+	Running       bool   `json:"running"`
+	Minimized     bool   `json:"minimized"`
+	PID           int    `json:"pid,omitempty"`
+	Address       string `json:"address,omitempty"`
+	WorkspaceID   int    `json:"workspace_id,omitempty"`
+	Launching     bool   `json:"launching"`
+	LaunchSeconds int    `json:"launch_seconds,omitempty"` // Time spent waiting for the window
+	NotResponding bool   `json:"not_responding"`
+}
+
+// orientationNames maps orientations to their settings.json values
+var orientationNames = map[int]string{
+	OrientationTop:    "top",
+	OrientationBottom: "bottom",
+	OrientationLeft:   "left",
+	OrientationRight:  "right",
+}
+
+// dumpState captures the shell state. Runs on the main loop.
+func (p *RavenPanel) dumpState() ShellState {
+	state := ShellState{
+		Panel: PanelState{
+			Position: orientationNames[p.orientation],
+			Size:     panelSize,
+		},
+		Dock:     []DockItemState{},
+		Popups:   []string{},
+		Keybinds: p.keybinds,
+		Settings: p.ravenSettings,
+		Config: PanelStatePaths{
+			Dock:     p.configPath,
+			Settings: p.ravenSettingsPath,
+			Keybinds: keybindsPath(),
+		},
+	}
+
+	if p.window != nil {
+		state.Panel.Width = p.window.Width()
+		state.Panel.Height = p.window.Height()
+	}
+	if p.mainBox != nil {
+		state.Widgets = widgetState(p.mainBox)
+	}
+
+	popups := []struct {
+		name   string
+		window *gtk.Window
+	}{
+		{"settings", p.settingsWindow},
+		{"power", p.powerWindow},
+		{"print-jobs", p.printWindow},
+		{"battery", p.batteryWindow},
+	}
+	for _, popup := range popups {
+		if popup.window != nil {
+			state.Popups = append(state.Popups, popup.name)
+		}
+	}
+
+	p.mu.RLock()
+	for _, item := range p.dockItems {
+		itemState := DockItemState{
+			ID:            item.ID,
+			Name:          item.Name,
+			Command:       item.Command,
+			Icon:          item.Icon,
+			Pinned:        item.Pinned,
+			Running:       item.Running,
+			Minimized:     item.Minimized,
+			PID:           item.PID,
+			Address:       item.Address,
+			WorkspaceID:   item.WorkspaceID,
+			Launching:     item.Launching,
+			NotResponding: item.NotResponding,
+		}
+		if item.Launching && !item.launchStarted.IsZero() {
+			itemState.LaunchSeconds = int(time.Since(item.launchStarted).Seconds())
+		}
+		state.Dock = append(state.Dock, itemState)
+	}
+	p.mu.RUnlock()
+	sort.Slice(state.Dock, func(i, j int) bool {
+		return state.Dock[i].ID < state.Dock[j].ID
+	})
+
+	return state
+}
+
+// widgetState walks the widget tree below w
+func widgetState(w gtk.Widgetter) *WidgetState {
+	widget := gtk.BaseWidget(w)
+	node := &WidgetState{
+		Node:    widget.CSSName(),
+		Name:    widget.Name(),
+		Classes: widget.CSSClasses(),
+		Tooltip: widget.TooltipText(),
+		Visible: widget.Visible(),
+	}
+	if label, ok := w.(*gtk.Label); ok {
+		node.Label = label.Label()
+	}
+	// GTK names widgets after their type unless told otherwise
+	if node.Name == widget.Type().Name() {
+		node.Name = ""
+	}
+
+	for child := widget.FirstChild(); child != nil; child = gtk.BaseWidget(child).NextSibling() {
+		node.Children = append(node.Children, widgetState(child))
+	}
+	return node
+}
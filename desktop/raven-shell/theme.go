@@ -0,0 +1,382 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/diamondburned/gotk4/pkg/gdk/v4"
+	"github.com/diamondburned/gotk4/pkg/glib/v2"
+	"github.com/diamondburned/gotk4/pkg/gtk/v4"
+)
+
+// How often the theme schedule is checked
+const themeCheckSeconds = 60
+
+// Switch times used by the "fixed" schedule, and by "sun" where the sun
+// doesn't rise or set that day
+const (
+	defaultLightStart = "07:00"
+	defaultDarkStart  = "19:00"
+)
+
+// lightPanelCSS overrides the dark panel styles when the light theme is on
+const lightPanelCSS = `
+	window {
+		background-color: rgba(245, 245, 245, 0.85);
+	}
+
+	.panel-container {
+		background: linear-gradient(
+			to bottom,
+			rgba(255, 255, 255, 0.9) 0%,
+			rgba(245, 245, 245, 0.85) 100%
+		);
+		border-color: rgba(0, 0, 0, 0.1);
+	}
+
+	.dock-container {
+		background-color: rgba(0, 0, 0, 0.05);
+	}
+
+	button, label, .clock {
+		color: rgba(0, 0, 0, 0.85);
+	}
+
+	button:hover, .dock-item:hover {
+		background-color: rgba(0, 0, 0, 0.08);
+	}
+
+	button:active, .dock-item:active {
+		background-color: rgba(0, 0, 0, 0.14);
+	}
+
+	.dock-item-pinned {
+		background-color: rgba(0, 0, 0, 0.04);
+	}
+
+	.start-button, .start-button label {
+		color: white;
+	}
+
+	separator {
+		background-color: rgba(0, 0, 0, 0.12);
+	}
+
+	.context-menu, .settings-menu {
+		background-color: rgba(250, 250, 250, 0.97);
+		border-color: rgba(0, 0, 0, 0.12);
+	}
+
+	.context-menu button:hover, .settings-menu button:hover {
+		background-color: rgba(0, 0, 0, 0.06);
+	}
+
+	.settings-section-label, .print-job-detail, .power-detail {
+		color: rgba(0, 0, 0, 0.55);
+	}
+
+	.quick-toggle {
+		background-color: rgba(0, 0, 0, 0.06);
+	}
+`
+
+// scheduledTheme returns the theme s's schedule asks for at now, or false
+// when automatic switching is off
+func scheduledTheme(s RavenSettings, now time.Time) (string, bool) {
+	lightStart := clockMinutes(s.LightThemeStart, defaultLightStart)
+	darkStart := clockMinutes(s.DarkThemeStart, defaultDarkStart)
+
+	switch s.ThemeSchedule {
+	case "fixed":
+	case "sun":
+		if sunrise, sunset, ok := sunTimes(now, s.Latitude, s.Longitude); ok {
+			lightStart = sunrise.Hour()*60 + sunrise.Minute()
+			darkStart = sunset.Hour()*60 + sunset.Minute()
+		}
+	default:
+		return "", false
+	}
+
+	minute := now.Hour()*60 + now.Minute()
+	light := minute >= lightStart && minute < darkStart
+	if lightStart > darkStart {
+		// Light period runs past midnight
+		light = minute >= lightStart || minute < darkStart
+	}
+	if light {
+		return "light", true
+	}
+	return "dark", true
+}
+
+// clockMinutes parses an "HH:MM" time as minutes after midnight, using
+// fallback when value isn't one
+func clockMinutes(value, fallback string) int {
+	t, err := time.Parse("15:04", value)
+	if err != nil {
+		t, _ = time.Parse("15:04", fallback)
+	}
+	return t.Hour()*60 + t.Minute()
+}
+
+// sunTimes returns the sunrise and sunset on day's date at the given
+// latitude and longitude (degrees, east positive), in day's location. It
+// uses the NOAA sunrise equation and reports false during polar day or night.
+func sunTimes(day time.Time, latitude, longitude float64) (time.Time, time.Time, bool) {
+	const rad = math.Pi / 180
+
+	y, m, d := day.Date()
+	noon := time.Date(y, m, d, 12, 0, 0, 0, time.UTC)
+	julianDay := float64(noon.Unix())/86400 + 2440587.5
+	n := math.Round(julianDay - 2451545.0 + 0.0008)
+
+	meanSolarNoon := n - longitude/360
+	anomaly := math.Mod(357.5291+0.98560028*meanSolarNoon, 360)
+	center := 1.9148*math.Sin(anomaly*rad) + 0.02*math.Sin(2*anomaly*rad) + 0.0003*math.Sin(3*anomaly*rad)
+	eclipticLongitude := math.Mod(anomaly+center+180+102.9372, 360)
+	transit := 2451545.0 + meanSolarNoon + 0.0053*math.Sin(anomaly*rad) - 0.0069*math.Sin(2*eclipticLongitude*rad)
+
+	sinDeclination := math.Sin(eclipticLongitude*rad) * math.Sin(23.4397*rad)
+	cosDeclination := math.Cos(math.Asin(sinDeclination))
+	cosHourAngle := (math.Sin(-0.833*rad) - math.Sin(latitude*rad)*sinDeclination) / (math.Cos(latitude*rad) * cosDeclination)
+	if cosHourAngle < -1 || cosHourAngle > 1 {
+		return time.Time{}, time.Time{}, false
+	}
+	hourAngle := math.Acos(cosHourAngle) / rad
+
+	fromJulian := func(j float64) time.Time {
+		return time.Unix(int64((j-2440587.5)*86400), 0).In(day.Location())
+	}
+	return fromJulian(transit - hourAngle/360), fromJulian(transit + hourAngle/360), true
+}
+
+// startThemeSchedule styles the panel for the current theme and checks
+// settings.json every minute, switching the theme when the schedule says
+// so and restyling when the theme was changed elsewhere
+func (p *RavenPanel) startThemeSchedule() {
+	p.checkTheme()
+	glib.TimeoutSecondsAdd(themeCheckSeconds, func() bool {
+		p.checkTheme()
+		return true
+	})
+}
+
+// checkTheme applies the scheduled theme, if any, and the panel styles
+func (p *RavenPanel) checkTheme() {
+	current := p.ravenSettings
+	if data, err := os.ReadFile(p.ravenSettingsPath); err == nil {
+		json.Unmarshal(data, &current)
+	}
+	p.ravenSettings.Theme = current.Theme
+	p.ravenSettings.ThemeSchedule = current.ThemeSchedule
+	p.ravenSettings.LightThemeStart = current.LightThemeStart
+	p.ravenSettings.DarkThemeStart = current.DarkThemeStart
+	p.ravenSettings.Latitude = current.Latitude
+	p.ravenSettings.Longitude = current.Longitude
+	p.ravenSettings.WallpaperPath = current.WallpaperPath
+
+	if theme, ok := scheduledTheme(current, time.Now()); ok && theme != current.Theme {
+		p.switchTheme(theme)
+	}
+	p.applyPanelTheme(p.ravenSettings.Theme)
+}
+
+// switchTheme makes theme the desktop theme: it is saved to settings.json
+// together with the matching wallpaper variant, and passed on to GTK apps
+func (p *RavenPanel) switchTheme(theme string) {
+	values := map[string]any{"theme": theme}
+	if wallpaper := wallpaperVariant(p.ravenSettings.WallpaperPath, theme); wallpaper != "" {
+		values["wallpaper_path"] = wallpaper
+		p.ravenSettings.WallpaperPath = wallpaper
+	}
+	p.ravenSettings.Theme = theme
+
+	if err := updateSettingsFile(p.ravenSettingsPath, values); err != nil {
+		fmt.Fprintf(os.Stderr, "raven-shell: failed to save theme: %v\n", err)
+	}
+	go propagateGTKTheme(theme == "dark")
+}
+
+// applyPanelTheme loads the light overrides, or clears them for any other
+// theme. They sit above the panel's own styles.
+func (p *RavenPanel) applyPanelTheme(theme string) {
+	if theme == p.panelTheme {
+		return
+	}
+	p.panelTheme = theme
+
+	if p.themeCSS == nil {
+		p.themeCSS = gtk.NewCSSProvider()
+		gtk.StyleContextAddProviderForDisplay(gdk.DisplayGetDefault(), p.themeCSS, gtk.STYLE_PROVIDER_PRIORITY_APPLICATION+1)
+	}
+	if theme == "light" {
+		p.themeCSS.LoadFromString(lightPanelCSS)
+	} else {
+		p.themeCSS.LoadFromString("")
+	}
+}
+
+// wallpaperVariant returns the light or dark version of wallpaper: an image
+// next to it named <name>-light or <name>-dark with the same extension. It
+// returns "" when there is no such file.
+func wallpaperVariant(wallpaper, theme string) string {
+	if wallpaper == "" {
+		return ""
+	}
+	ext := filepath.Ext(wallpaper)
+	base := strings.TrimSuffix(wallpaper, ext)
+	base = strings.TrimSuffix(strings.TrimSuffix(base, "-light"), "-dark")
+
+	variant := base + "-" + theme + ext
+	if variant == wallpaper {
+		return ""
+	}
+	if _, err := os.Stat(variant); err != nil {
+		return ""
+	}
+	return variant
+}
+
+// propagateGTKTheme tells GTK apps to prefer the dark or light variant of
+// their theme, through the desktop color scheme and the GTK 3 and 4
+// settings.ini files
+func propagateGTKTheme(dark bool) {
+	scheme := "prefer-light"
+	if dark {
+		scheme = "prefer-dark"
+	}
+	if _, err := exec.LookPath("gsettings"); err == nil {
+		exec.Command("gsettings", "set", "org.gnome.desktop.interface", "color-scheme", scheme).Run()
+	}
+
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		return
+	}
+	value := "false"
+	if dark {
+		value = "true"
+	}
+	for _, dir := range []string{"gtk-3.0", "gtk-4.0"} {
+		path := filepath.Join(configDir, dir, "settings.ini")
+		if err := setINIValue(path, "Settings", "gtk-application-prefer-dark-theme", value); err != nil {
+			fmt.Fprintf(os.Stderr, "raven-shell: failed to update %s: %v\n", path, err)
+		}
+	}
+}
+
+// setINIValue sets key in section of the INI file at path, creating the
+// file, the section or the key as needed
+func setINIValue(path, section, key, value string) error {
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	var lines []string
+	if len(data) > 0 {
+		lines = strings.Split(strings.TrimRight(string(data), "\n"), "\n")
+	}
+
+	header := "[" + section + "]"
+	inSection, done := false, false
+	sectionEnd := -1
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "[") {
+			inSection = trimmed == header
+			if inSection {
+				sectionEnd = i + 1
+			}
+			continue
+		}
+		if !inSection {
+			continue
+		}
+		if name, _, ok := strings.Cut(trimmed, "="); ok && strings.TrimSpace(name) == key {
+			lines[i] = key + "=" + value
+			done = true
+			break
+		}
+		if trimmed != "" {
+			sectionEnd = i + 1
+		}
+	}
+
+	if !done {
+		entry := key + "=" + value
+		if sectionEnd < 0 {
+			lines = append(lines, header, entry)
+		} else {
+			lines = append(lines[:sectionEnd], append([]string{entry}, lines[sectionEnd:]...)...)
+		}
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0644)
+}
+
+// updateSettingsFile sets values in settings.json, keeping every other key
+// as it is
+func updateSettingsFile(path string, values map[string]any) error {
+	// A file that doesn't parse is left alone rather than replaced by
+	// the few keys being set
+	config := make(map[string]json.RawMessage)
+	if data, err := os.ReadFile(path); err == nil {
+		if err := json.Unmarshal(data, &config); err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+	for key, value := range values {
+		raw, err := json.Marshal(value)
+		if err != nil {
+			return err
+		}
+		config[key] = raw
+	}
+
+	data, err := json.MarshalIndent(config, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return writeFileAtomic(path, data)
+}
+
+// writeFileAtomic replaces path through a temporary file, so a crash
+// midway never leaves it truncated
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Write(data)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Chmod(tmp.Name(), 0644)
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+	}
+	return err
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"time"
+
+	"github.com/diamondburned/gotk4/pkg/glib/v2"
+	"github.com/diamondburned/gotk4/pkg/gtk/v4"
+)
+
+// How often Hyprland is asked for its windows
+const windowPollInterval = 1500 * time.Millisecond // Reduced polling for better performance under software rendering
+
+// How often Hyprland is looked for again while window tracking is
+// unavailable
+const compositorRetryInterval = 10 * time.Second
+
+// Failed window lists in a row before window tracking is reported
+// unavailable
+const compositorFailureLimit = 3
+
+// waitForWindowPoll waits until the window list should be read again:
+// soon while tracking works, less often while the compositor is missing,
+// and at once when the status chip is clicked
+func (p *RavenPanel) waitForWindowPoll() {
+	p.mu.RLock()
+	interval := windowPollInterval
+	if p.trackingErr != nil {
+		interval = compositorRetryInterval
+	}
+	p.mu.RUnlock()
+
+	select {
+	case <-time.After(interval):
+	case <-p.trackingRetry:
+	}
+}
+
+// setWindowTracking records whether the window list can be read, nil
+// meaning it can. Without it the dock only launches its pinned apps.
+func (p *RavenPanel) setWindowTracking(err error) {
+	p.mu.Lock()
+	was := p.trackingErr
+	p.trackingErr = err
+	p.mu.Unlock()
+
+	if (was == nil) == (err == nil) {
+		return
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "raven-shell: window tracking unavailable: %v\n", err)
+	} else {
+		fmt.Fprintf(os.Stderr, "raven-shell: window tracking available again\n")
+	}
+	glib.IdleAdd(func() {
+		p.updateTrackingChip()
+	})
+}
+
+// createTrackingChip builds the panel chip shown while the compositor
+// can't be asked for windows. Clicking it looks for the compositor again.
+func (p *RavenPanel) createTrackingChip() *gtk.Button {
+	p.trackingChip = gtk.NewButtonWithLabel("Window tracking unavailable")
+	p.trackingChip.AddCSSClass("tracking-chip")
+	p.trackingChip.ConnectClicked(func() {
+		select {
+		case p.trackingRetry <- struct{}{}:
+		default:
+		}
+	})
+	p.updateTrackingChip()
+	return p.trackingChip
+}
+
+func (p *RavenPanel) updateTrackingChip() {
+	if p.trackingChip == nil {
+		return
+	}
+
+	p.mu.RLock()
+	err := p.trackingErr
+	p.mu.RUnlock()
+
+	p.trackingChip.SetVisible(err != nil)
+	if err == nil {
+		return
+	}
+	reason := "Hyprland is not answering (hyprctl clients failed)"
+	if errors.Is(err, exec.ErrNotFound) {
+		reason = "hyprctl is not installed"
+	}
+	p.trackingChip.SetTooltipText(reason + ".\nThe dock only launches pinned apps until it is found again. Click to retry now.")
+}
@@ -43,6 +43,13 @@ cd "$PROJECT_ROOT"
 echo "raven-settings-menu built"
 echo ""
 
+echo ">>> Building raven-settingsd (settings change events)..."
+cd "$PROJECT_ROOT/desktop/raven-settingsd"
+go build -o raven-settingsd .
+cd "$PROJECT_ROOT"
+echo "raven-settingsd built"
+echo ""
+
 # Build terminal
 echo ">>> Building raven-terminal..."
 cd "$PROJECT_ROOT/tools/raven-terminal"
@@ -69,6 +76,7 @@ echo "  - desktop/raven-shell/raven-shell"
 echo "  - desktop/raven-desktop/raven-desktop"
 echo "  - desktop/raven-menu/raven-menu"
 echo "  - desktop/raven-settings-menu/raven-settings-menu"
+echo "  - desktop/raven-settingsd/raven-settingsd"
 echo "  - tools/raven-terminal/raven-terminal"
 echo ""
 echo "Configuration installed to:"
@@ -679,7 +679,7 @@ if [ ! -e /dev/fd ] && [ -d /proc/self/fd ]; then
 fi
 
 # Create mount points
-mkdir -p /mnt/cdrom /mnt/squashfs /mnt/root /mnt/overlay /mnt/work
+mkdir -p /mnt/cdrom /mnt/squashfs /mnt/root /mnt/overlay /mnt/work /mnt/isodevice
 
 # Suppress kernel messages for cleaner output
 dmesg -n 1 2>/dev/null || true
@@ -708,8 +708,34 @@ step "Searching for boot device"
 BOOT_DEVICE=""
 ISO_LABEL="RAVEN_LIVE"
 
+# Method 0: The ISO as a file on a multi-boot drive, named by findiso=
+FINDISO=""
+for arg in $(cat /proc/cmdline 2>/dev/null); do
+    case "$arg" in
+        findiso=*) FINDISO="${arg#findiso=}" ;;
+    esac
+done
+
+if [ -n "$FINDISO" ] && command -v blkid &>/dev/null; then
+    info "Looking for $FINDISO..."
+    for dev in $(blkid 2>/dev/null | awk -F: '{print $1}'); do
+        [ -b "$dev" ] 2>/dev/null || continue
+        mount -o ro "$dev" /mnt/isodevice 2>/dev/null || continue
+        if [ -f "/mnt/isodevice$FINDISO" ]; then
+            loop=$(losetup -f 2>/dev/null)
+            if [ -n "$loop" ] && losetup -r "$loop" "/mnt/isodevice$FINDISO" 2>/dev/null; then
+                ok "Found $FINDISO on $dev"
+                BOOT_DEVICE="$loop"
+                break
+            fi
+        fi
+        umount /mnt/isodevice 2>/dev/null
+    done
+    [ -z "$BOOT_DEVICE" ] && warn "$FINDISO not found, searching for the live medium"
+fi
+
 # Method 1: Look for device with our label using blkid
-if command -v blkid &>/dev/null; then
+if [ -z "$BOOT_DEVICE" ] && command -v blkid &>/dev/null; then
     # Avoid bash process substitution here; it depends on /dev/fd existing.
     for dev in $(blkid 2>/dev/null | awk -F: '{print $1}'); do
         [ -b "$dev" ] 2>/dev/null || continue
@@ -815,7 +841,23 @@ fi
 # -----------------------------------------------------------------------------
 step "Setting up overlay filesystem"
 
-if mount -t tmpfs tmpfs /mnt/overlay 2>/dev/null; then
+# Keep changes on a persistence partition (made by raven-usb) when there is one
+PERSIST_LABEL="casper-rw"
+PERSIST_DEVICE=""
+if command -v blkid &>/dev/null; then
+    for dev in $(blkid 2>/dev/null | awk -F: '{print $1}'); do
+        [ -b "$dev" ] 2>/dev/null || continue
+        label=$(blkid -o value -s LABEL "$dev" 2>/dev/null)
+        if [ "$label" = "$PERSIST_LABEL" ]; then
+            PERSIST_DEVICE="$dev"
+            break
+        fi
+    done
+fi
+
+if [ -n "$PERSIST_DEVICE" ] && mount -t ext4 "$PERSIST_DEVICE" /mnt/overlay 2>/dev/null; then
+    ok "Mounted persistent storage: $PERSIST_DEVICE"
+elif mount -t tmpfs tmpfs /mnt/overlay 2>/dev/null; then
     ok "Created tmpfs for overlay"
 else
     fail "Failed to create overlay tmpfs"
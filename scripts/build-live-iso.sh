@@ -953,6 +953,20 @@ build_raven_desktop() {
         cd "${PROJECT_ROOT}"
     fi
 
+    # Build raven-settingsd (settings change events)
+    if [[ -d "${desktop_dir}/raven-settingsd" ]]; then
+        log_info "  Building raven-settingsd..."
+        cd "${desktop_dir}/raven-settingsd"
+        if go build -o raven-settingsd . 2>&1; then
+            cp raven-settingsd "${LIVE_ROOT}/bin/"
+            chmod +x "${LIVE_ROOT}/bin/raven-settingsd"
+            log_info "  Installed raven-settingsd"
+        else
+            log_warn "  Failed to build raven-settingsd"
+        fi
+        cd "${PROJECT_ROOT}"
+    fi
+
     # Copy GTK4 layer-shell library (required for panels/docks on Wayland)
     log_info "  Copying GTK4 layer-shell library..."
     for lib in /usr/lib/libgtk4-layer-shell* /usr/lib64/libgtk4-layer-shell*; do
@@ -1253,6 +1267,18 @@ copy_desktop_services() {
         fi
     done
 
+    # GTK portal backend, which serves the color scheme from gsettings to
+    # sandboxed and libadwaita apps
+    for bin_path in /usr/libexec/xdg-desktop-portal-gtk /usr/lib/xdg-desktop-portal-gtk; do
+        if [[ -f "$bin_path" ]]; then
+            mkdir -p "${LIVE_ROOT}/usr/libexec"
+            cp "$bin_path" "${LIVE_ROOT}/usr/libexec/" 2>/dev/null || true
+            chmod +x "${LIVE_ROOT}/usr/libexec/xdg-desktop-portal-gtk"
+            log_info "  Added xdg-desktop-portal-gtk"
+            break
+        fi
+    done
+
     # Portal configuration
     if [[ -d /usr/share/xdg-desktop-portal ]]; then
         mkdir -p "${LIVE_ROOT}/usr/share/xdg-desktop-portal"
@@ -1267,7 +1293,7 @@ copy_desktop_services() {
     fi
 
     # Portal D-Bus service files
-    for svc in org.freedesktop.portal.Desktop.service org.freedesktop.impl.portal.desktop.hyprland.service; do
+    for svc in org.freedesktop.portal.Desktop.service org.freedesktop.impl.portal.desktop.hyprland.service org.freedesktop.impl.portal.desktop.gtk.service; do
         if [[ -f "/usr/share/dbus-1/services/${svc}" ]]; then
             mkdir -p "${LIVE_ROOT}/usr/share/dbus-1/services"
             cp "/usr/share/dbus-1/services/${svc}" "${LIVE_ROOT}/usr/share/dbus-1/services/" 2>/dev/null || true
@@ -1281,6 +1307,7 @@ copy_desktop_services() {
 default=hyprland;gtk
 org.freedesktop.impl.portal.Screenshot=hyprland
 org.freedesktop.impl.portal.ScreenCast=hyprland
+org.freedesktop.impl.portal.Settings=gtk
 EOF
 
     log_success "Desktop services installed"
@@ -1976,6 +2003,25 @@ menuentry "Reboot" --class restart {
 menuentry "Shutdown" --class shutdown {
     halt
 }
+EOF
+
+    # Entries for booting the ISO as a file, as raven-usb's multi-boot drives
+    # do; GRUB sets iso_path and the initramfs finds the file from findiso=
+    cat > "${ISO_DIR}/iso-root/boot/grub/loopback.cfg" << 'EOF'
+menuentry "Raven Linux Live" --class raven {
+    linux /boot/vmlinuz rdinit=/init quiet loglevel=3 findiso=${iso_path}
+    initrd /boot/initramfs.img
+}
+
+menuentry "Raven Linux Live (Wayland)" --class raven {
+    linux /boot/vmlinuz rdinit=/init quiet loglevel=3 raven.graphics=wayland raven.wayland=raven findiso=${iso_path}
+    initrd /boot/initramfs.img
+}
+
+menuentry "Raven Linux Install" --class raven {
+    linux /boot/vmlinuz rdinit=/init raven.installer findiso=${iso_path}
+    initrd /boot/initramfs.img
+}
 EOF
 
     # Create EFI bootloader
@@ -12,26 +12,23 @@ write_hyprland_config() {
 # =====================
 monitor=,preferred,auto,1
 
+# Display layout confirmed in Raven Settings (Displays)
+source = ~/.config/hypr/raven-monitors.conf
+
 # =====================
 # Startup Applications
 # =====================
-# Core Raven desktop components
-exec-once = raven-desktop
-exec-once = raven-shell
+# Raven shell, desktop, notification daemon, power daemon, idle daemon,
+# night light and clipboard history; Raven Settings (Services) turns them
+# on and off
+source = ~/.config/hypr/raven-autostart.conf
 
 # Wallpaper (uses swaybg, reads from raven settings)
 exec-once = ~/.config/raven/scripts/set-wallpaper.sh
 
-# Notification daemon
-exec-once = mako || dunst || swaync
-
 # PolicyKit agent (for privilege escalation)
 exec-once = /usr/lib/polkit-gnome/polkit-gnome-authentication-agent-1 || /usr/lib/polkit-kde-authentication-agent-1
 
-# Clipboard manager
-exec-once = wl-paste --type text --watch cliphist store
-exec-once = wl-paste --type image --watch cliphist store
-
 # =====================
 # Environment Variables
 # =====================
@@ -71,6 +68,9 @@ input {
     }
 }
 
+# Keyboard layout, mouse speed and touchpad options from Raven Settings
+source = ~/.config/hypr/raven-input.conf
+
 # =====================
 # General Settings
 # =====================
@@ -194,7 +194,7 @@ bind = $mainMod, Q, killactive,
 bind = $mainMod SHIFT, Q, exit,
 bind = $mainMod SHIFT, F, fullscreen, 0
 bind = $mainMod CTRL, F, fullscreen, 1
-bind = $mainMod, V, togglefloating,
+bind = $mainMod SHIFT, V, togglefloating,
 bind = $mainMod SHIFT, P, pseudo,
 bind = $mainMod, J, togglesplit,
 
@@ -301,6 +301,79 @@ bind = $mainMod, I, exec, raven-settings-menu
 
 # File manager
 bind = $mainMod SHIFT, E, exec, raven-file-manager
+
+# Shortcuts rebound in Raven Settings (Shortcuts); must stay last
+source = ~/.config/hypr/raven-binds.conf
+EOF
+    write_hyprland_fragment_defaults "$(dirname "$dest")"
+}
+
+# Hyprland reports an error for a missing source file, so the fragments
+# raven-settings-menu rewrites start out with the defaults above
+write_hyprland_fragment_defaults() {
+    local dir="$1"
+
+    if [[ ! -f "$dir/raven-monitors.conf" ]]; then
+        echo "# Written by raven-settings-menu (Displays)" > "$dir/raven-monitors.conf"
+    fi
+
+    if [[ ! -f "$dir/raven-binds.conf" ]]; then
+        echo "# Written by raven-settings-menu (Shortcuts)" > "$dir/raven-binds.conf"
+    fi
+
+    # Each service logs to $XDG_RUNTIME_DIR/<name>.log for the Services page
+    if [[ ! -f "$dir/raven-autostart.conf" ]]; then
+        cat > "$dir/raven-autostart.conf" << 'EOF'
+# Written by raven-settings-menu (Services)
+exec-once = (raven-settingsd) > "$XDG_RUNTIME_DIR/raven-settingsd.log" 2>&1
+exec-once = (raven-shell) > "$XDG_RUNTIME_DIR/raven-shell.log" 2>&1
+exec-once = (raven-desktop) > "$XDG_RUNTIME_DIR/raven-desktop.log" 2>&1
+exec-once = (mako || dunst || swaync) > "$XDG_RUNTIME_DIR/notifications.log" 2>&1
+exec-once = (raven-powerd) > "$XDG_RUNTIME_DIR/raven-powerd.log" 2>&1
+exec-once = (hypridle || swayidle -w) > "$XDG_RUNTIME_DIR/idle.log" 2>&1
+exec-once = (hyprsunset) > "$XDG_RUNTIME_DIR/night-light.log" 2>&1
+exec-once = (wl-paste --type text --watch cliphist store) > "$XDG_RUNTIME_DIR/clipboard.log" 2>&1
+exec-once = (wl-paste --type image --watch cliphist store) >> "$XDG_RUNTIME_DIR/clipboard.log" 2>&1
+exec-once = (/usr/lib/kdeconnectd || /usr/libexec/kdeconnectd) > "$XDG_RUNTIME_DIR/kdeconnect.log" 2>&1
+EOF
+    fi
+
+    # The Power page's default timeouts: screen off after 5 minutes,
+    # suspend after 15
+    if [[ ! -f "$dir/hypridle.conf" ]]; then
+        cat > "$dir/hypridle.conf" << 'EOF'
+# Written by raven-settings-menu (Power)
+general {
+    lock_cmd = pidof hyprlock swaylock || hyprlock || swaylock
+    before_sleep_cmd = loginctl lock-session
+    after_sleep_cmd = hyprctl dispatch dpms on
+}
+
+listener {
+    timeout = 300
+    on-timeout = hyprctl dispatch dpms off
+    on-resume = hyprctl dispatch dpms on
+}
+
+listener {
+    timeout = 900
+    on-timeout = loginctl suspend
+}
+EOF
+    fi
+
+    [[ -f "$dir/raven-input.conf" ]] && return 0
+    cat > "$dir/raven-input.conf" << 'EOF'
+# Written by raven-settings-menu from ~/.config/raven/settings.json
+input {
+    kb_layout = us
+    sensitivity = 0.00
+
+    touchpad {
+        natural_scroll = true
+        tap-to-click = true
+    }
+}
 EOF
 }
 
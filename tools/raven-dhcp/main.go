@@ -65,6 +65,7 @@ func main() {
 	var iface string
 	var timeoutSeconds int
 	var resolvConfPath string
+	var staticConfigPath string
 	var all bool
 	var quiet bool
 
@@ -73,6 +74,7 @@ func main() {
 	flag.IntVar(&timeoutSeconds, "t", 10, "Timeout in seconds")
 	flag.IntVar(&timeoutSeconds, "timeout", 10, "Timeout in seconds")
 	flag.StringVar(&resolvConfPath, "resolv-conf", "/etc/resolv.conf", "Path to write resolv.conf")
+	flag.StringVar(&staticConfigPath, "static-config", defaultStaticConfigPath, "Path of the static interface config")
 	flag.BoolVar(&all, "all", false, "Configure all non-loopback ethernet interfaces")
 	flag.BoolVar(&quiet, "q", false, "Quiet (only print errors)")
 	flag.BoolVar(&quiet, "quiet", false, "Quiet (only print errors)")
@@ -83,8 +85,13 @@ func main() {
 		fatalf("invalid timeout: %d", timeoutSeconds)
 	}
 
+	static, err := loadStaticConfigs(staticConfigPath)
+	if err != nil {
+		fatalf("%v", err)
+	}
+
 	if all {
-		if err := runAll(timeout, resolvConfPath, quiet); err != nil {
+		if err := runAll(static, timeout, resolvConfPath, quiet); err != nil {
 			fatalf("%v", err)
 		}
 		return
@@ -94,12 +101,12 @@ func main() {
 		fatalf("missing -i/--interface (or use --all)")
 	}
 
-	if err := runOne(iface, timeout, resolvConfPath, quiet); err != nil {
+	if err := runOne(iface, static, timeout, resolvConfPath, quiet); err != nil {
 		fatalf("%s: %v", iface, err)
 	}
 }
 
-func runAll(timeout time.Duration, resolvConfPath string, quiet bool) error {
+func runAll(static map[string]leaseConfig, timeout time.Duration, resolvConfPath string, quiet bool) error {
 	ifaces, err := net.Interfaces()
 	if err != nil {
 		return err
@@ -125,14 +132,18 @@ func runAll(timeout time.Duration, resolvConfPath string, quiet bool) error {
 
 	var firstErr error
 	for _, name := range targets {
-		if err := runOne(name, timeout, resolvConfPath, quiet); err != nil && firstErr == nil {
+		if err := runOne(name, static, timeout, resolvConfPath, quiet); err != nil && firstErr == nil {
 			firstErr = fmt.Errorf("%s: %w", name, err)
 		}
 	}
 	return firstErr
 }
 
-func runOne(iface string, timeout time.Duration, resolvConfPath string, quiet bool) error {
+func runOne(iface string, static map[string]leaseConfig, timeout time.Duration, resolvConfPath string, quiet bool) error {
+	if cfg, ok := static[iface]; ok {
+		return applyStatic(iface, cfg, resolvConfPath, quiet)
+	}
+
 	if err := ipLinkUp(iface); err != nil && !quiet {
 		fmt.Fprintf(os.Stderr, "raven-dhcp: %s: failed to set link up: %v\n", iface, err)
 	}
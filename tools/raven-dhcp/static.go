@@ -0,0 +1,96 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+)
+
+// Interfaces set to a static address in raven-settings-menu. raven-dhcp
+// applies these instead of asking a DHCP server.
+const defaultStaticConfigPath = "/etc/raven/network.json"
+
+type interfaceConfig struct {
+	Method  string   `json:"method"`  // "dhcp" or "static"
+	Address string   `json:"address"` // CIDR, e.g. 192.168.1.10/24
+	Gateway string   `json:"gateway"`
+	DNS     []string `json:"dns"`
+}
+
+type networkConfig struct {
+	Interfaces map[string]interfaceConfig `json:"interfaces"`
+}
+
+// loadStaticConfigs returns the static interfaces of the config at path.
+// A missing file means every interface uses DHCP.
+func loadStaticConfigs(path string) (map[string]leaseConfig, error) {
+	static := make(map[string]leaseConfig)
+	if path == "" {
+		return static, nil
+	}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return static, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var config networkConfig
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	for name, ifc := range config.Interfaces {
+		if ifc.Method != "static" {
+			continue
+		}
+		cfg, err := ifc.lease()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s: %w", path, name, err)
+		}
+		static[name] = cfg
+	}
+	return static, nil
+}
+
+// lease converts a static interface to the config a DHCP lease would give
+func (ifc interfaceConfig) lease() (leaseConfig, error) {
+	ip, ipnet, err := net.ParseCIDR(ifc.Address)
+	if err != nil || ip.To4() == nil {
+		return leaseConfig{}, fmt.Errorf("invalid address %q", ifc.Address)
+	}
+	prefix, _ := ipnet.Mask.Size()
+	cfg := leaseConfig{IP: ip.To4(), Prefix: prefix}
+
+	if ifc.Gateway != "" {
+		if cfg.Gateway = net.ParseIP(ifc.Gateway).To4(); cfg.Gateway == nil {
+			return leaseConfig{}, fmt.Errorf("invalid gateway %q", ifc.Gateway)
+		}
+	}
+	for _, server := range ifc.DNS {
+		ip := net.ParseIP(strings.TrimSpace(server)).To4()
+		if ip == nil {
+			return leaseConfig{}, fmt.Errorf("invalid DNS server %q", server)
+		}
+		cfg.DNS = append(cfg.DNS, ip)
+	}
+	return cfg, nil
+}
+
+// applyStatic configures iface with its static address instead of a lease
+func applyStatic(iface string, cfg leaseConfig, resolvConfPath string, quiet bool) error {
+	if err := applyConfig(iface, cfg); err != nil {
+		return err
+	}
+	if resolvConfPath != "" && len(cfg.DNS) > 0 {
+		if err := writeResolvConf(resolvConfPath, cfg.DNS); err != nil && !quiet {
+			fmt.Fprintf(os.Stderr, "raven-dhcp: %s: failed to write %s: %v\n", iface, resolvConfPath, err)
+		}
+	}
+	if !quiet {
+		fmt.Printf("%s: static %s/%d\n", iface, cfg.IP.String(), cfg.Prefix)
+	}
+	return nil
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/color"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"gioui.org/layout"
+	"gioui.org/op/clip"
+	"gioui.org/op/paint"
+	"gioui.org/unit"
+	"gioui.org/widget"
+	"gioui.org/widget/material"
+)
+
+// accentColors match the choices in raven-settings-menu
+var accentColors = []string{"#009688", "#2196F3", "#9C27B0", "#FF5722", "#4CAF50", "#FFC107"}
+
+func drawDesktop(gtx layout.Context, th *material.Theme, state *InstallerState) layout.Dimensions {
+	state.theme = state.themeEnum.Value
+
+	return layout.Flex{Axis: layout.Vertical}.Layout(gtx,
+		layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+			title := material.H6(th, "Desktop")
+			return title.Layout(gtx)
+		}),
+		layout.Rigid(layout.Spacer{Height: unit.Dp(10)}.Layout),
+		layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+			desc := material.Body2(th, "Your first login starts with these. They can be changed later in Raven Settings.")
+			desc.Color = color.NRGBA{R: 150, G: 150, B: 150, A: 255}
+			return desc.Layout(gtx)
+		}),
+		layout.Rigid(layout.Spacer{Height: unit.Dp(20)}.Layout),
+		layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+			return drawChoiceField(gtx, th, "Theme:", func(gtx layout.Context) layout.Dimensions {
+				return layout.Flex{Axis: layout.Horizontal}.Layout(gtx,
+					layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+						gtx.Constraints.Min.X = gtx.Dp(unit.Dp(170))
+						return material.RadioButton(th, &state.themeEnum, "dark", "Dark").Layout(gtx)
+					}),
+					layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+						return material.RadioButton(th, &state.themeEnum, "light", "Light").Layout(gtx)
+					}),
+				)
+			})
+		}),
+		layout.Rigid(layout.Spacer{Height: unit.Dp(15)}.Layout),
+		layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+			return drawChoiceField(gtx, th, "Accent Color:", func(gtx layout.Context) layout.Dimensions {
+				var swatches []layout.FlexChild
+				for i, hex := range accentColors {
+					swatches = append(swatches, layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+						return layout.Inset{Right: unit.Dp(10)}.Layout(gtx, func(gtx layout.Context) layout.Dimensions {
+							return drawSwatch(gtx, &state.accentClicks[i], parseHexColor(hex), hex == state.accentColor)
+						})
+					}))
+				}
+				return layout.Flex{Axis: layout.Horizontal}.Layout(gtx, swatches...)
+			})
+		}),
+	)
+}
+
+// drawChoiceField lays out a label and its choices like drawFormField
+func drawChoiceField(gtx layout.Context, th *material.Theme, label string, choices layout.Widget) layout.Dimensions {
+	return layout.Flex{Axis: layout.Horizontal}.Layout(gtx,
+		layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+			gtx.Constraints.Min.X = gtx.Dp(unit.Dp(150))
+			lbl := material.Body1(th, label)
+			return layout.Inset{Top: unit.Dp(8)}.Layout(gtx, lbl.Layout)
+		}),
+		layout.Flexed(1, choices),
+	)
+}
+
+// drawSwatch draws a clickable color square, outlined when selected
+func drawSwatch(gtx layout.Context, click *widget.Clickable, c color.NRGBA, selected bool) layout.Dimensions {
+	return material.Clickable(gtx, click, func(gtx layout.Context) layout.Dimensions {
+		border := colorBackground
+		if selected {
+			border = colorAccent
+		}
+		return widget.Border{
+			Color:        border,
+			Width:        unit.Dp(2),
+			CornerRadius: unit.Dp(8),
+		}.Layout(gtx, func(gtx layout.Context) layout.Dimensions {
+			return layout.UniformInset(unit.Dp(4)).Layout(gtx, func(gtx layout.Context) layout.Dimensions {
+				size := image.Pt(gtx.Dp(unit.Dp(32)), gtx.Dp(unit.Dp(32)))
+				paint.FillShape(gtx.Ops, c, clip.UniformRRect(image.Rectangle{Max: size}, gtx.Dp(unit.Dp(6))).Op(gtx.Ops))
+				return layout.Dimensions{Size: size}
+			})
+		})
+	})
+}
+
+// parseHexColor converts "#RRGGBB" for drawing
+func parseHexColor(hex string) color.NRGBA {
+	v, err := strconv.ParseUint(strings.TrimPrefix(hex, "#"), 16, 32)
+	if err != nil {
+		return colorSurface
+	}
+	return color.NRGBA{R: uint8(v >> 16), G: uint8(v >> 8), B: uint8(v), A: 255}
+}
+
+// writeDesktopDefaults gives the new user a settings.json with the theme
+// and accent color of the Desktop step and the keyboard layout of the
+// Region step, so the first login matches what was picked
+func writeDesktopDefaults(target, username, theme, accent, keyboard string) error {
+	if err := checkMounted(target); err != nil {
+		return err
+	}
+	home, _, ok := lookupPasswd(filepath.Join(target, "etc/passwd"), username)
+	if !ok {
+		return fmt.Errorf("user %s not in /etc/passwd", username)
+	}
+	uid, gid, ok := lookupIDs(filepath.Join(target, "etc/passwd"), username)
+	if !ok {
+		return fmt.Errorf("user %s has no valid uid/gid", username)
+	}
+
+	configDir := filepath.Join(target, home, ".config", "raven")
+	if err := os.MkdirAll(configDir, 0755); err != nil {
+		return err
+	}
+
+	settings := map[string]any{
+		"theme":           theme,
+		"accent_color":    accent,
+		"keyboard_layout": keyboard,
+	}
+	data, err := json.MarshalIndent(settings, "", "  ")
+	if err != nil {
+		return err
+	}
+	settingsPath := filepath.Join(configDir, "settings.json")
+	if err := os.WriteFile(settingsPath, data, 0644); err != nil {
+		return err
+	}
+
+	// The directories were made as root; hand them to the user
+	for _, path := range []string{filepath.Join(target, home, ".config"), configDir, settingsPath} {
+		if err := os.Chown(path, uid, gid); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// lookupIDs returns the uid and gid of a user
+func lookupIDs(path, username string) (uid, gid int, ok bool) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, 0, false
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		fields := strings.Split(line, ":")
+		if len(fields) >= 7 && fields[0] == username {
+			uid, err1 := strconv.Atoi(fields[2])
+			gid, err2 := strconv.Atoi(fields[3])
+			return uid, gid, err1 == nil && err2 == nil
+		}
+	}
+	return 0, 0, false
+}
@@ -0,0 +1,307 @@
+package main
+
+import (
+	"archive/tar"
+	"bufio"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/dustin/go-humanize"
+)
+
+// installerPackagesPath lists the packages of an online install, one name
+// per line. Without it every package in the repository is installed.
+const installerPackagesPath = "/usr/share/raven-installer/packages"
+
+// Where downloaded packages are kept on the new system, as rvn caches them
+const packageCacheDir = "/var/cache/rvn/packages"
+
+// defaultParallelDownloads matches rvn's general.parallel_downloads default
+const defaultParallelDownloads = 5
+
+// downloadProgress is the combined progress of the parallel downloads. It
+// is written by the download workers and read while drawing.
+type downloadProgress struct {
+	total   int64
+	count   int
+	started time.Time
+	done    atomic.Int64
+	files   atomic.Int32
+}
+
+// fraction returns how much has been downloaded, from 0 to 1
+func (p *downloadProgress) fraction() float32 {
+	if p.total <= 0 {
+		return 0
+	}
+	return min(float32(p.done.Load())/float32(p.total), 1)
+}
+
+// describe returns the line shown under the progress bar
+func (p *downloadProgress) describe() string {
+	done := p.done.Load()
+	line := fmt.Sprintf("%d of %d packages · %s of %s", p.files.Load(), p.count, humanize.Bytes(uint64(done)), humanize.Bytes(uint64(p.total)))
+
+	elapsed := time.Since(p.started).Seconds()
+	if elapsed < 1 || done == 0 {
+		return line
+	}
+	speed := float64(done) / elapsed
+	line += fmt.Sprintf(" · %s/s", humanize.Bytes(uint64(speed)))
+	if left := p.total - done; left > 0 {
+		eta := time.Duration(float64(left)/speed) * time.Second
+		line += fmt.Sprintf(" · %s left", eta.Round(time.Second))
+	}
+	return line
+}
+
+// progressWriter counts the bytes written through it
+type progressWriter struct {
+	progress *downloadProgress
+}
+
+func (w progressWriter) Write(p []byte) (int, error) {
+	w.progress.done.Add(int64(len(p)))
+	return len(p), nil
+}
+
+// installPackageNames reads installerPackagesPath. It returns nil when the
+// file is missing.
+func installPackageNames() []string {
+	file, err := os.Open(installerPackagesPath)
+	if err != nil {
+		return nil
+	}
+	defer file.Close()
+
+	var names []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line != "" && !strings.HasPrefix(line, "#") {
+			names = append(names, line)
+		}
+	}
+	return names
+}
+
+// selectPackages returns the named packages and everything they depend on.
+// With no names, every package in the index is selected.
+func selectPackages(index *repoIndex, names []string) ([]repoPackage, error) {
+	if len(names) == 0 {
+		return index.Packages, nil
+	}
+
+	byName := make(map[string]repoPackage)
+	for _, pkg := range index.Packages {
+		byName[pkg.Name] = pkg
+	}
+
+	var selected []repoPackage
+	seen := make(map[string]bool)
+	var add func(name string) error
+	add = func(name string) error {
+		// Dependencies may carry a version constraint, as in "glibc>=2.38"
+		if i := strings.IndexAny(name, "<>= "); i >= 0 {
+			name = name[:i]
+		}
+		if seen[name] {
+			return nil
+		}
+		seen[name] = true
+		pkg, ok := byName[name]
+		if !ok {
+			return fmt.Errorf("package %s is not in the repository", name)
+		}
+		for _, dep := range pkg.Dependencies {
+			if err := add(dep); err != nil {
+				return err
+			}
+		}
+		selected = append(selected, pkg)
+		return nil
+	}
+	for _, name := range names {
+		if err := add(name); err != nil {
+			return nil, err
+		}
+	}
+	return selected, nil
+}
+
+// parallelDownloads returns rvn's general.parallel_downloads setting
+func parallelDownloads() int {
+	data, err := os.ReadFile(rvnConfigPath)
+	if err != nil {
+		return defaultParallelDownloads
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		key, value, ok := strings.Cut(line, "=")
+		if !ok || strings.TrimSpace(key) != "parallel_downloads" {
+			continue
+		}
+		if n, err := strconv.Atoi(strings.TrimSpace(value)); err == nil && n > 0 {
+			return n
+		}
+	}
+	return defaultParallelDownloads
+}
+
+// downloadPackages downloads pkgs from mirror into dir, workers at a time.
+// The first failure stops the other downloads.
+func downloadPackages(mirror string, pkgs []repoPackage, dir string, workers int, progress *downloadProgress) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	jobs := make(chan repoPackage)
+	var wg sync.WaitGroup
+	var once sync.Once
+	var firstErr error
+	for range min(workers, len(pkgs)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for pkg := range jobs {
+				if err := downloadPackage(ctx, mirror, pkg, dir, progress); err != nil {
+					once.Do(func() {
+						firstErr = fmt.Errorf("%s: %w", pkg.Name, err)
+						cancel()
+					})
+					continue
+				}
+				progress.files.Add(1)
+			}
+		}()
+	}
+
+	for _, pkg := range pkgs {
+		if ctx.Err() != nil {
+			break
+		}
+		jobs <- pkg
+	}
+	close(jobs)
+	wg.Wait()
+	return firstErr
+}
+
+// downloadPackage downloads one package into dir and checks its sha256
+func downloadPackage(ctx context.Context, mirror string, pkg repoPackage, dir string, progress *downloadProgress) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, packageURL(mirror, pkg), nil)
+	if err != nil {
+		return err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+
+	path := filepath.Join(dir, filepath.Base(pkg.Filename))
+	tmp, err := os.CreateTemp(dir, ".download-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	hash := sha256.New()
+	_, err = io.Copy(io.MultiWriter(tmp, hash, progressWriter{progress}), resp.Body)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	if pkg.Sha256 != "" && !strings.EqualFold(hex.EncodeToString(hash.Sum(nil)), pkg.Sha256) {
+		return fmt.Errorf("checksum mismatch")
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// unpackPackage extracts the data/ files of an .rvn package into target
+func unpackPackage(path, target string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	gz, err := gzip.NewReader(file)
+	if err != nil {
+		return err
+	}
+	defer gz.Close()
+
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		name, ok := strings.CutPrefix(strings.TrimPrefix(hdr.Name, "./"), "data/")
+		if !ok || name == "" {
+			continue
+		}
+		dest := filepath.Join(target, name)
+		if !strings.HasPrefix(dest, filepath.Clean(target)+string(os.PathSeparator)) {
+			return fmt.Errorf("unsafe path %s", hdr.Name)
+		}
+		mode := os.FileMode(hdr.Mode) & os.ModePerm
+
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			err = os.MkdirAll(dest, mode)
+		case tar.TypeSymlink:
+			os.Remove(dest)
+			err = os.MkdirAll(filepath.Dir(dest), 0755)
+			if err == nil {
+				err = os.Symlink(hdr.Linkname, dest)
+			}
+		case tar.TypeReg:
+			err = os.MkdirAll(filepath.Dir(dest), 0755)
+			if err == nil {
+				err = writeFile(dest, tr, mode)
+			}
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// writeFile replaces path with the contents of r
+func writeFile(path string, r io.Reader, mode os.FileMode) error {
+	os.Remove(path)
+	out, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, mode)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(out, r)
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
@@ -0,0 +1,420 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+
+	"gioui.org/io/pointer"
+	"gioui.org/layout"
+	"gioui.org/op/clip"
+	"gioui.org/op/paint"
+	"gioui.org/unit"
+	"gioui.org/widget/material"
+	"github.com/dustin/go-humanize"
+)
+
+// How the selected disk is used
+const (
+	modeErase     = "erase"     // The whole disk is given to RavenLinux
+	modeAlongside = "alongside" // The largest partition is shrunk to make room
+)
+
+// Least space RavenLinux is installed in, and the free space left to a
+// shrunk system on top of what it uses
+const (
+	minInstallSize = 20 << 30
+	minSpareSize   = 2 << 30
+)
+
+// File systems that can be shrunk to install alongside
+var resizableFS = map[string]bool{"ntfs": true, "ext2": true, "ext3": true, "ext4": true}
+
+// Partition is a partition of a Disk and what was found on it
+type Partition struct {
+	Path   string
+	Name   string
+	Size   uint64
+	Used   uint64 // Bytes in use, 0 when the file system couldn't be read
+	FSType string
+	System string // Operating system installed on it, if any
+	EFI    bool   // It is an EFI system partition
+}
+
+// detectPartitions lists the partitions of disk name in order, probing
+// each for an installed system the way os-prober does
+func detectPartitions(name string) []Partition {
+	sysPath := filepath.Join("/sys/block", name)
+	entries, err := os.ReadDir(sysPath)
+	if err != nil {
+		return nil
+	}
+
+	type numbered struct {
+		n    uint64
+		part Partition
+	}
+	var found []numbered
+	for _, entry := range entries {
+		partPath := filepath.Join(sysPath, entry.Name())
+		number, err := os.ReadFile(filepath.Join(partPath, "partition"))
+		if err != nil {
+			continue
+		}
+		sizeBytes, _ := os.ReadFile(filepath.Join(partPath, "size"))
+		part := Partition{
+			Path: filepath.Join("/dev", entry.Name()),
+			Name: entry.Name(),
+			Size: parseUint(strings.TrimSpace(string(sizeBytes))) * 512,
+		}
+		probePartition(&part)
+		found = append(found, numbered{parseUint(strings.TrimSpace(string(number))), part})
+	}
+	sort.Slice(found, func(i, j int) bool { return found[i].n < found[j].n })
+
+	parts := make([]Partition, len(found))
+	for i, f := range found {
+		parts[i] = f.part
+	}
+	return parts
+}
+
+// probePartition fills in the file system of part and, mounting it read-only
+// when the live system hasn't, the system installed on it and the space used
+func probePartition(part *Partition) {
+	out, _ := exec.Command("blkid", "-o", "value", "-s", "TYPE", part.Path).Output()
+	part.FSType = strings.TrimSpace(string(out))
+	switch part.FSType {
+	case "", "swap", "crypto_LUKS", "LVM2_member":
+		return
+	}
+
+	root := mountPoint(part.Path)
+	if root == "" {
+		dir, err := os.MkdirTemp("", "raven-probe-")
+		if err != nil {
+			return
+		}
+		defer os.Remove(dir)
+		if err := exec.Command("mount", "-o", "ro", part.Path, dir).Run(); err != nil {
+			return
+		}
+		defer exec.Command("umount", dir).Run()
+		root = dir
+	}
+
+	var fs syscall.Statfs_t
+	if syscall.Statfs(root, &fs) == nil {
+		part.Used = (fs.Blocks - fs.Bfree) * uint64(fs.Bsize)
+	}
+	part.EFI = part.FSType == "vfat" && findFold(root, "EFI") != ""
+	part.System = identifySystem(root)
+}
+
+// identifySystem names the operating system installed in root, or
+// returns "" when there is none
+func identifySystem(root string) string {
+	if findFold(root, "EFI/Microsoft/Boot/bootmgfw.efi") != "" {
+		return "Windows Boot Manager"
+	}
+	if findFold(root, "Windows/System32/ntoskrnl.exe") != "" {
+		return "Windows"
+	}
+
+	data, err := os.ReadFile(filepath.Join(root, "etc/os-release"))
+	if err != nil {
+		return ""
+	}
+	name := "Linux"
+	for _, line := range strings.Split(string(data), "\n") {
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			continue
+		}
+		value = strings.Trim(value, `"'`)
+		switch key {
+		case "PRETTY_NAME":
+			return value
+		case "NAME":
+			name = value
+		}
+	}
+	return name
+}
+
+// findFold returns the path of rel under root, matching names without
+// regard to case as Windows does, or "" if there is no such file
+func findFold(root, rel string) string {
+	path := root
+	for _, name := range strings.Split(rel, "/") {
+		entries, err := os.ReadDir(path)
+		if err != nil {
+			return ""
+		}
+		next := ""
+		for _, entry := range entries {
+			if strings.EqualFold(entry.Name(), name) {
+				next = filepath.Join(path, entry.Name())
+				break
+			}
+		}
+		if next == "" {
+			return ""
+		}
+		path = next
+	}
+	return path
+}
+
+// mountPoint returns where device is mounted, or "" if it isn't
+func mountPoint(device string) string {
+	data, err := os.ReadFile("/proc/mounts")
+	if err != nil {
+		return ""
+	}
+	unescape := strings.NewReplacer(`\040`, " ", `\011`, "\t", `\134`, `\`)
+	for _, line := range strings.Split(string(data), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) >= 2 && fields[0] == device {
+			return unescape.Replace(fields[1])
+		}
+	}
+	return ""
+}
+
+// systems lists the operating systems found on the disk
+func (d Disk) systems() []string {
+	var names []string
+	for _, part := range d.Partitions {
+		if part.System != "" {
+			names = append(names, part.System)
+		}
+	}
+	return names
+}
+
+// efiPartition returns the disk's EFI system partition, which an install
+// alongside shares
+func (d Disk) efiPartition() (Partition, bool) {
+	for _, part := range d.Partitions {
+		if part.EFI {
+			return part, true
+		}
+	}
+	return Partition{}, false
+}
+
+// resizeCandidate returns the index of the largest partition that can be
+// shrunk to fit RavenLinux, or -1 if none can
+func (d Disk) resizeCandidate() int {
+	best := -1
+	for i, part := range d.Partitions {
+		if !resizableFS[part.FSType] || part.Used == 0 {
+			continue
+		}
+		if lo, hi := shrinkBounds(part); lo > hi {
+			continue
+		}
+		if best < 0 || part.Size > d.Partitions[best].Size {
+			best = i
+		}
+	}
+	return best
+}
+
+// shrinkBounds returns the smallest and largest size part can be shrunk
+// to, keeping room for its system and leaving enough for RavenLinux
+func shrinkBounds(part Partition) (lo, hi uint64) {
+	lo = part.Used + max(part.Used/10, minSpareSize)
+	if part.Size < minInstallSize {
+		return lo, 0
+	}
+	return lo, part.Size - minInstallSize
+}
+
+// alongsidePartition returns the partition an install alongside shrinks on
+// the selected disk
+func alongsidePartition(state *InstallerState) (Partition, bool) {
+	if state.selectedDisk < 0 || state.selectedDisk >= len(state.disks) {
+		return Partition{}, false
+	}
+	disk := state.disks[state.selectedDisk]
+	i := disk.resizeCandidate()
+	if i < 0 {
+		return Partition{}, false
+	}
+	return disk.Partitions[i], true
+}
+
+// resetAlongside puts the divider midway between the bounds of the
+// partition to shrink, and goes back to erasing the disk when it has none
+func resetAlongside(state *InstallerState) {
+	part, ok := alongsidePartition(state)
+	if !ok {
+		state.modeEnum.Value = modeErase
+		return
+	}
+	lo, hi := shrinkBounds(part)
+	state.resizeSplit.Value = float32(lo+(hi-lo)/2) / float32(part.Size)
+}
+
+// keptSize is the size the partition being shrunk keeps, in whole MiB
+func keptSize(state *InstallerState, part Partition) uint64 {
+	lo, hi := shrinkBounds(part)
+	size := uint64(float64(state.resizeSplit.Value) * float64(part.Size))
+	return min(max(size, lo), hi) &^ (1<<20 - 1)
+}
+
+// otherSystems lists the systems the installed bootloader should offer,
+// leaving out those on a disk that is erased
+func otherSystems(state *InstallerState) []string {
+	var names []string
+	for i, disk := range state.disks {
+		if i == state.selectedDisk && state.installMode == modeErase {
+			continue
+		}
+		names = append(names, disk.systems()...)
+	}
+	return names
+}
+
+// partitionSteps returns the install log steps that prepare the disk
+func partitionSteps(state *InstallerState, disk Disk) []string {
+	if state.installMode != modeAlongside && !state.uefi {
+		return []string{
+			"Creating MBR partition table...",
+			"Creating root partition...",
+			"Formatting root partition (ext4)...",
+		}
+	}
+	if state.installMode != modeAlongside {
+		return []string{
+			"Creating GPT partition table...",
+			"Creating EFI partition...",
+			"Creating root partition...",
+			"Formatting EFI partition (FAT32)...",
+			"Formatting root partition (ext4)...",
+		}
+	}
+
+	part, _ := alongsidePartition(state)
+	kept := keptSize(state, part)
+	steps := []string{
+		fmt.Sprintf("Checking %s for errors...", part.Path),
+		fmt.Sprintf("Shrinking %s to %s...", part.Path, humanize.IBytes(kept)),
+		fmt.Sprintf("Creating root partition in the freed %s...", humanize.IBytes(part.Size-kept)),
+	}
+	// BIOS systems boot from the disk's boot sector and need no EFI partition
+	if efi, ok := disk.efiPartition(); ok && state.uefi {
+		steps = append(steps, fmt.Sprintf("Sharing EFI partition %s...", efi.Path))
+	} else if state.uefi {
+		steps = append(steps, "Creating EFI partition...", "Formatting EFI partition (FAT32)...")
+	}
+	return append(steps, "Formatting root partition (ext4)...")
+}
+
+// enableOSProber lets grub-mkconfig add boot entries for the other
+// systems, which GRUB leaves out by default, and regenerates the menu
+func enableOSProber(target string) error {
+	if err := checkMounted(target); err != nil {
+		return err
+	}
+	path := filepath.Join(target, "etc/default/grub")
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	const setting = "GRUB_DISABLE_OS_PROBER=false"
+	lines := strings.Split(strings.TrimRight(string(data), "\n"), "\n")
+	found := false
+	for i, line := range lines {
+		if strings.HasPrefix(strings.TrimLeft(line, "# "), "GRUB_DISABLE_OS_PROBER=") {
+			lines[i] = setting
+			found = true
+		}
+	}
+	if !found {
+		lines = append(lines, setting)
+	}
+	if err := os.WriteFile(path, []byte(strings.TrimLeft(strings.Join(lines, "\n")+"\n", "\n")), 0644); err != nil {
+		return err
+	}
+
+	if _, err := os.Stat(filepath.Join(target, "usr/bin/grub-mkconfig")); err != nil {
+		return nil
+	}
+	if out, err := exec.Command("chroot", target, "grub-mkconfig", "-o", "/boot/grub/grub.cfg").CombinedOutput(); err != nil {
+		return fmt.Errorf("grub-mkconfig: %v: %s", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// drawAlongside draws the divider for installing alongside part, with the
+// sizes either side of it
+func drawAlongside(gtx layout.Context, th *material.Theme, state *InstallerState, part Partition) layout.Dimensions {
+	system := part.System
+	if system == "" {
+		system = part.Path
+	}
+	kept := keptSize(state, part)
+
+	return layout.Flex{Axis: layout.Vertical}.Layout(gtx,
+		layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+			desc := material.Body2(th, fmt.Sprintf("Drag the divider to share %s (%s, %s) between %s and RavenLinux.",
+				part.Path, part.FSType, humanize.IBytes(part.Size), system))
+			desc.Color = color.NRGBA{R: 150, G: 150, B: 150, A: 255}
+			return desc.Layout(gtx)
+		}),
+		layout.Rigid(layout.Spacer{Height: unit.Dp(15)}.Layout),
+		layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+			return drawResizeBar(gtx, state, part)
+		}),
+		layout.Rigid(layout.Spacer{Height: unit.Dp(10)}.Layout),
+		layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+			return layout.Flex{Axis: layout.Horizontal, Spacing: layout.SpaceBetween}.Layout(gtx,
+				layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+					lbl := material.Body1(th, fmt.Sprintf("%s: %s (%s used)", system, humanize.IBytes(kept), humanize.IBytes(part.Used)))
+					return lbl.Layout(gtx)
+				}),
+				layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+					lbl := material.Body1(th, "RavenLinux: "+humanize.IBytes(part.Size-kept))
+					lbl.Color = colorAccent
+					return lbl.Layout(gtx)
+				}),
+			)
+		}),
+	)
+}
+
+// drawResizeBar draws part as a bar split where it is shrunk to, its used
+// space shaded, and lets the split be dragged within shrinkBounds
+func drawResizeBar(gtx layout.Context, state *InstallerState, part Partition) layout.Dimensions {
+	size := image.Pt(gtx.Constraints.Max.X, gtx.Dp(unit.Dp(40)))
+	gtx.Constraints = layout.Exact(size)
+
+	state.resizeSplit.Update(gtx)
+	kept := keptSize(state, part)
+	state.resizeSplit.Value = float32(kept) / float32(part.Size)
+
+	split := int(state.resizeSplit.Value * float32(size.X))
+	used := int(float32(part.Used) / float32(part.Size) * float32(size.X))
+	paint.FillShape(gtx.Ops, colorSurface, clip.Rect{Max: image.Pt(split, size.Y)}.Op())
+	paint.FillShape(gtx.Ops, color.NRGBA{R: 60, G: 70, B: 90, A: 255}, clip.Rect{Max: image.Pt(used, size.Y)}.Op())
+	paint.FillShape(gtx.Ops, colorPrimary, clip.Rect{Min: image.Pt(split, 0), Max: size}.Op())
+
+	handle := gtx.Dp(unit.Dp(3))
+	area := clip.Rect{Min: image.Pt(split-handle, 0), Max: image.Pt(split+handle, size.Y)}
+	paint.FillShape(gtx.Ops, colorAccent, area.Op())
+	stack := clip.Rect{Min: image.Pt(split-4*handle, 0), Max: image.Pt(split+4*handle, size.Y)}.Push(gtx.Ops)
+	pointer.CursorColResize.Add(gtx.Ops)
+	stack.Pop()
+
+	return state.resizeSplit.Layout(gtx, layout.Horizontal, unit.Dp(0))
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"strings"
+
+	"gioui.org/layout"
+	"gioui.org/unit"
+	"gioui.org/widget/material"
+)
+
+// Bootloaders the installer sets up. UEFI systems get GRUB or systemd-boot
+// in the EFI partition; BIOS systems get GRUB in the disk's boot sector.
+const (
+	bootGRUBEFI = "grub-efi"
+	bootSystemd = "systemd-boot"
+	bootGRUBPC  = "grub-pc"
+)
+
+// Partition table types as blkid names them
+const (
+	tableGPT = "gpt"
+	tableMBR = "dos"
+)
+
+// detectUEFI reports whether the live system was booted by UEFI firmware,
+// which the installed system then boots with too
+func detectUEFI() bool {
+	_, err := os.Stat("/sys/firmware/efi")
+	return err == nil
+}
+
+// partitionTable returns the type of device's partition table, "" when
+// it has none
+func partitionTable(device string) string {
+	out, err := exec.Command("blkid", "-o", "value", "-s", "PTTYPE", device).Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// wantedTable is the partition table the firmware boots from
+func wantedTable(uefi bool) string {
+	if uefi {
+		return tableGPT
+	}
+	return tableMBR
+}
+
+// tableName names a partition table type for the user
+func tableName(table string) string {
+	switch table {
+	case tableGPT:
+		return "GPT"
+	case tableMBR:
+		return "MBR"
+	}
+	return strings.ToUpper(table)
+}
+
+// firmwareName names the firmware for the user
+func firmwareName(uefi bool) string {
+	if uefi {
+		return "UEFI"
+	}
+	return "BIOS (legacy)"
+}
+
+// tableMismatch explains how the selected disk's partition table doesn't
+// suit the firmware, or returns "" when it does or the disk is blank
+func tableMismatch(state *InstallerState) string {
+	if state.selectedDisk < 0 || state.selectedDisk >= len(state.disks) {
+		return ""
+	}
+	table := state.disks[state.selectedDisk].PartTable
+	want := wantedTable(state.uefi)
+	if table == "" || table == want {
+		return ""
+	}
+
+	msg := "This computer starts with " + firmwareName(state.uefi) + " firmware, which boots from " +
+		tableName(want) + " disks, but the disk's partition table is " + tableName(table) + ". "
+	if state.installMode == modeAlongside {
+		return msg + "Installing alongside keeps it, so the new system may not boot, and the systems on it may need the firmware switched to start."
+	}
+	return msg + "Erasing the disk replaces it with " + tableName(want) + "."
+}
+
+// eraseLayout describes the partitions created when the disk is erased
+func eraseLayout(uefi bool) string {
+	if uefi {
+		return `The following partition layout will be created:
+
+  /dev/sdX1 - EFI System Partition (512 MB, FAT32)
+  /dev/sdX2 - Root Partition (Remaining space, ext4)
+
+This uses a simple GPT layout suitable for UEFI systems.
+For advanced partitioning, use manual installation.`
+	}
+	return `The following partition layout will be created:
+
+  /dev/sdX1 - Root Partition (Whole disk, ext4, bootable)
+
+This uses an MBR layout with GRUB in the boot sector, as BIOS
+systems need. For advanced partitioning, use manual installation.`
+}
+
+// bootloaderName names a bootloader for the install log
+func bootloaderName(bootloader string) string {
+	switch bootloader {
+	case bootSystemd:
+		return "systemd-boot"
+	case bootGRUBPC:
+		return "GRUB, BIOS"
+	}
+	return "GRUB, UEFI"
+}
+
+// drawBootloader draws the bootloader choice, offered on UEFI systems
+func drawBootloader(gtx layout.Context, th *material.Theme, state *InstallerState) layout.Dimensions {
+	if !state.uefi {
+		return drawChoiceField(gtx, th, "Bootloader:", func(gtx layout.Context) layout.Dimensions {
+			return layout.Inset{Top: unit.Dp(8)}.Layout(gtx, material.Body1(th, "GRUB in the boot sector (BIOS)").Layout)
+		})
+	}
+	return drawChoiceField(gtx, th, "Bootloader:", func(gtx layout.Context) layout.Dimensions {
+		return layout.Flex{Axis: layout.Horizontal}.Layout(gtx,
+			layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+				gtx.Constraints.Min.X = gtx.Dp(unit.Dp(170))
+				return material.RadioButton(th, &state.bootEnum, bootGRUBEFI, "GRUB").Layout(gtx)
+			}),
+			layout.Rigid(material.RadioButton(th, &state.bootEnum, bootSystemd, "systemd-boot").Layout),
+		)
+	})
+}
@@ -1,18 +1,20 @@
 module github.com/ravenlinux/raven-installer
 
-go 1.23
+go 1.24.0
 
 require (
-	gioui.org v0.8.0
+	gioui.org v0.9.0
 	github.com/dustin/go-humanize v1.0.1
+	raven-wifi v0.0.0
 )
 
 require (
 	gioui.org/shader v1.0.8 // indirect
-	github.com/go-text/typesetting v0.2.1 // indirect
-	golang.org/x/exp v0.0.0-20240707233637-46b078467d37 // indirect
-	golang.org/x/exp/shiny v0.0.0-20240707233637-46b078467d37 // indirect
-	golang.org/x/image v0.18.0 // indirect
-	golang.org/x/sys v0.22.0 // indirect
-	golang.org/x/text v0.16.0 // indirect
+	github.com/go-text/typesetting v0.3.0 // indirect
+	golang.org/x/exp/shiny v0.0.0-20250408133849-7e4ce0ab07d0 // indirect
+	golang.org/x/image v0.26.0 // indirect
+	golang.org/x/sys v0.33.0 // indirect
+	golang.org/x/text v0.24.0 // indirect
 )
+
+replace raven-wifi => ../raven-wifi
@@ -0,0 +1,328 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"gioui.org/font"
+	"gioui.org/layout"
+	"gioui.org/op/clip"
+	"gioui.org/op/paint"
+	"gioui.org/unit"
+	"gioui.org/widget"
+	"gioui.org/widget/material"
+	"github.com/dustin/go-humanize"
+)
+
+// Hardware is what the Install step shows about the computer
+type Hardware struct {
+	CPU      string
+	Memory   uint64
+	GPUs     []string
+	Disks    []string
+	WiFi     []string
+	Warnings []string // Hardware that needs more than the kernel to work well
+}
+
+// pciDevice is a PCI device found in sysfs
+type pciDevice struct {
+	Slot   string
+	Class  string
+	Vendor string
+	Device string
+	Driver string
+}
+
+// PCI vendor IDs, named when lspci is missing
+const (
+	vendorIntel    = "0x8086"
+	vendorAMD      = "0x1002"
+	vendorNVIDIA   = "0x10de"
+	vendorBroadcom = "0x14e4"
+	vendorRealtek  = "0x10ec"
+	vendorMediaTek = "0x14c3"
+)
+
+var pciVendors = map[string]string{
+	vendorIntel:    "Intel",
+	vendorAMD:      "AMD",
+	vendorNVIDIA:   "NVIDIA",
+	vendorBroadcom: "Broadcom",
+	vendorRealtek:  "Realtek",
+	vendorMediaTek: "MediaTek",
+	"0x168c":       "Qualcomm Atheros",
+	"0x17cb":       "Qualcomm",
+	"0x1af4":       "Virtio",
+	"0x15ad":       "VMware",
+	"0x1234":       "QEMU",
+}
+
+// Slide time on the Install step's slideshow
+const slideDuration = 8 * time.Second
+
+// installSlides are shown while installing
+var installSlides = []struct {
+	Title string
+	Body  string
+}{
+	{"Vem", "A GPU-accelerated text editor with modal editing, built for speed on large files."},
+	{"Carrion", "A modern programming language with a friendly syntax, ready to use from the first boot."},
+	{"Ivaldi", "Next-generation version control with timelines, seals and automatic sync."},
+	{"rvn", "The Raven package manager: rvn install, rvn upgrade, and development toolchains with rvn dev."},
+	{"The Raven desktop", "A Wayland desktop with a dock, launcher and settings app, themed in the colors you just picked."},
+}
+
+// detectHardware summarizes the CPU, memory, graphics, disks and WiFi,
+// warning about hardware known to need extra firmware or drivers
+func detectHardware(disks []Disk) Hardware {
+	hw := Hardware{
+		CPU:    cpuModel(),
+		Memory: memTotal(),
+	}
+	for _, disk := range disks {
+		hw.Disks = append(hw.Disks, fmt.Sprintf("%s (%s)", strings.TrimSpace(disk.Name+" "+disk.Model), humanize.Bytes(disk.Size)))
+	}
+
+	for _, dev := range pciDevices() {
+		name := pciName(dev)
+		switch {
+		case strings.HasPrefix(dev.Class, "0x03"):
+			hw.GPUs = append(hw.GPUs, name)
+			if dev.Vendor == vendorNVIDIA && dev.Driver != "nvidia" {
+				hw.Warnings = append(hw.Warnings, name+": the open nouveau driver is slow on recent NVIDIA cards; install NVIDIA's driver after the first boot")
+			}
+		case strings.HasPrefix(dev.Class, "0x0280"):
+			hw.WiFi = append(hw.WiFi, name)
+			switch {
+			case dev.Vendor == vendorBroadcom:
+				hw.Warnings = append(hw.Warnings, name+": Broadcom WiFi usually needs the broadcom-wl driver or b43 firmware")
+			case dev.Driver == "":
+				hw.Warnings = append(hw.Warnings, name+": no driver is loaded for this WiFi chip, so it won't work until one is installed")
+			}
+		}
+	}
+	hw.Warnings = append(hw.Warnings, missingFirmware()...)
+	return hw
+}
+
+// cpuModel returns the CPU model and its thread count
+func cpuModel() string {
+	data, err := os.ReadFile("/proc/cpuinfo")
+	if err != nil {
+		return "Unknown"
+	}
+	model, threads := "", 0
+	for _, line := range strings.Split(string(data), "\n") {
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		switch strings.TrimSpace(key) {
+		case "processor":
+			threads++
+		case "model name":
+			if model == "" {
+				model = strings.TrimSpace(value)
+			}
+		}
+	}
+	if model == "" {
+		model = "Unknown"
+	}
+	return fmt.Sprintf("%s (%d %s)", model, threads, plural(threads, "thread", "threads"))
+}
+
+// memTotal returns the installed memory in bytes
+func memTotal() uint64 {
+	data, err := os.ReadFile("/proc/meminfo")
+	if err != nil {
+		return 0
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if fields := strings.Fields(line); len(fields) >= 2 && fields[0] == "MemTotal:" {
+			return parseUint(fields[1]) * 1024
+		}
+	}
+	return 0
+}
+
+// pciDevices lists the PCI devices with the driver bound to each
+func pciDevices() []pciDevice {
+	dirs, _ := filepath.Glob("/sys/bus/pci/devices/*")
+	var devices []pciDevice
+	for _, dir := range dirs {
+		read := func(name string) string {
+			data, _ := os.ReadFile(filepath.Join(dir, name))
+			return strings.TrimSpace(string(data))
+		}
+		dev := pciDevice{
+			Slot:   filepath.Base(dir),
+			Class:  read("class"),
+			Vendor: read("vendor"),
+			Device: read("device"),
+		}
+		if driver, err := os.Readlink(filepath.Join(dir, "driver")); err == nil {
+			dev.Driver = filepath.Base(driver)
+		}
+		devices = append(devices, dev)
+	}
+	return devices
+}
+
+// pciName names a device as lspci does, falling back to its vendor and
+// device IDs without lspci
+func pciName(dev pciDevice) string {
+	if out, err := exec.Command("lspci", "-s", dev.Slot).Output(); err == nil {
+		// "00:02.0 VGA compatible controller: Intel Corporation ..."
+		if parts := strings.SplitN(strings.TrimSpace(string(out)), ": ", 2); len(parts) == 2 {
+			return parts[1]
+		}
+	}
+	vendor, ok := pciVendors[dev.Vendor]
+	if !ok {
+		vendor = "Vendor " + strings.TrimPrefix(dev.Vendor, "0x")
+	}
+	return fmt.Sprintf("%s device %s", vendor, strings.TrimPrefix(dev.Device, "0x"))
+}
+
+// The kernel's message when a driver asks for firmware that isn't there
+var firmwareFailed = regexp.MustCompile(`Direct firmware load for (\S+) failed`)
+
+// missingFirmware returns a warning for each firmware file the kernel
+// couldn't load since boot
+func missingFirmware() []string {
+	out, err := exec.Command("dmesg").Output()
+	if err != nil {
+		return nil
+	}
+	seen := map[string]bool{}
+	var warnings []string
+	for _, match := range firmwareFailed.FindAllStringSubmatch(string(out), -1) {
+		if seen[match[1]] {
+			continue
+		}
+		seen[match[1]] = true
+		warnings = append(warnings, "Firmware "+match[1]+" is missing; install linux-firmware for the device needing it")
+	}
+	return warnings
+}
+
+// drawShowcase draws the slideshow and the hardware summary beside the
+// install checklist
+func drawShowcase(gtx layout.Context, th *material.Theme, state *InstallerState, started time.Time) layout.Dimensions {
+	return layout.Flex{Axis: layout.Vertical}.Layout(gtx,
+		layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+			return drawSlideshow(gtx, th, started)
+		}),
+		layout.Rigid(layout.Spacer{Height: unit.Dp(20)}.Layout),
+		layout.Flexed(1, func(gtx layout.Context) layout.Dimensions {
+			return material.List(th, &state.hardwareList).Layout(gtx, 1, func(gtx layout.Context, _ int) layout.Dimensions {
+				return drawHardware(gtx, th, state.hardware)
+			})
+		}),
+	)
+}
+
+// drawSlideshow draws the slide for the time since the install started,
+// with a dot for each slide
+func drawSlideshow(gtx layout.Context, th *material.Theme, started time.Time) layout.Dimensions {
+	current := int(time.Since(started)/slideDuration) % len(installSlides)
+	slide := installSlides[current]
+
+	return widget.Border{
+		Color: colorSurface,
+		Width: unit.Dp(2),
+	}.Layout(gtx, func(gtx layout.Context) layout.Dimensions {
+		return layout.UniformInset(unit.Dp(15)).Layout(gtx, func(gtx layout.Context) layout.Dimensions {
+			return layout.Flex{Axis: layout.Vertical}.Layout(gtx,
+				layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+					title := material.H6(th, slide.Title)
+					title.Color = colorAccent
+					return title.Layout(gtx)
+				}),
+				layout.Rigid(layout.Spacer{Height: unit.Dp(8)}.Layout),
+				layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+					gtx.Constraints.Min.Y = gtx.Dp(unit.Dp(45))
+					body := material.Body1(th, slide.Body)
+					return body.Layout(gtx)
+				}),
+				layout.Rigid(layout.Spacer{Height: unit.Dp(10)}.Layout),
+				layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+					size := gtx.Dp(unit.Dp(8))
+					gap := gtx.Dp(unit.Dp(6))
+					for i := range installSlides {
+						c := colorSurface
+						if i == current {
+							c = colorPrimary
+						}
+						x := i * (size + gap)
+						paint.FillShape(gtx.Ops, c, clip.Ellipse{Min: image.Pt(x, 0), Max: image.Pt(x+size, size)}.Op(gtx.Ops))
+					}
+					return layout.Dimensions{Size: image.Pt(len(installSlides)*(size+gap), size)}
+				}),
+			)
+		})
+	})
+}
+
+// drawHardware draws the hardware summary and its warnings
+func drawHardware(gtx layout.Context, th *material.Theme, hw *Hardware) layout.Dimensions {
+	gray := color.NRGBA{R: 150, G: 150, B: 150, A: 255}
+	if hw == nil {
+		lbl := material.Body2(th, "Detecting hardware...")
+		lbl.Color = gray
+		return lbl.Layout(gtx)
+	}
+
+	row := func(label string, values []string) layout.FlexChild {
+		if len(values) == 0 {
+			values = []string{"None found"}
+		}
+		return layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+			return layout.Flex{Axis: layout.Horizontal}.Layout(gtx,
+				layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+					gtx.Constraints.Min.X = gtx.Dp(unit.Dp(70))
+					lbl := material.Body2(th, label)
+					lbl.Font.Weight = font.Bold
+					return lbl.Layout(gtx)
+				}),
+				layout.Flexed(1, func(gtx layout.Context) layout.Dimensions {
+					lbl := material.Body2(th, strings.Join(values, "\n"))
+					lbl.Color = gray
+					return lbl.Layout(gtx)
+				}),
+			)
+		})
+	}
+
+	children := []layout.FlexChild{
+		layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+			lbl := material.Body1(th, "Your Hardware")
+			lbl.Font.Weight = font.Bold
+			return lbl.Layout(gtx)
+		}),
+		layout.Rigid(layout.Spacer{Height: unit.Dp(5)}.Layout),
+		row("CPU", []string{hw.CPU}),
+		row("Memory", []string{humanize.IBytes(hw.Memory)}),
+		row("Graphics", hw.GPUs),
+		row("Disks", hw.Disks),
+		row("WiFi", hw.WiFi),
+	}
+	for _, warning := range hw.Warnings {
+		children = append(children, layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+			return layout.Inset{Top: unit.Dp(5)}.Layout(gtx, func(gtx layout.Context) layout.Dimensions {
+				lbl := material.Body2(th, "⚠ "+warning)
+				lbl.Color = colorDanger
+				return lbl.Layout(gtx)
+			})
+		}))
+	}
+	return layout.Flex{Axis: layout.Vertical}.Layout(gtx, children...)
+}
@@ -10,6 +10,9 @@ import (
 	"os/exec"
 	"path/filepath"
 	"strings"
+	"time"
+
+	"raven-wifi/wifi"
 
 	"gioui.org/app"
 	"gioui.org/font"
@@ -32,53 +35,127 @@ var (
 	colorAccent     = color.NRGBA{R: 80, G: 200, B: 255, A: 255}  // Cyan/light blue
 	colorText       = color.NRGBA{R: 150, G: 180, B: 220, A: 255} // Soft blue-gray
 	colorDanger     = color.NRGBA{R: 255, G: 80, B: 80, A: 255}   // Red
+	colorWarning    = color.NRGBA{R: 255, G: 170, B: 60, A: 255}  // Orange
 )
 
 // Installation steps
 const (
 	StepWelcome = iota
+	StepRequirements
 	StepDiskSelection
 	StepPartitioning
+	StepNetwork
+	StepSource
 	StepConfiguration
+	StepRegion
+	StepDesktop
 	StepInstallation
 	StepComplete
 )
 
 // Disk represents a storage device
 type Disk struct {
-	Path   string
-	Name   string
-	Size   uint64
-	Model  string
-	Vendor string
+	Path       string
+	Name       string
+	Size       uint64
+	Model      string
+	Vendor     string
+	PartTable  string // Type of partition table, "" when blank
+	Live       bool   // Holds the live medium, so it can\'t be installed onto
+	Partitions []Partition
 }
 
 // InstallerState holds the current installer state
 type InstallerState struct {
-	currentStep   int
-	disks         []Disk
-	selectedDisk  int
-	hostname      string
-	username      string
-	password      string
-	rootPassword  string
-	timezone      string
-	locale        string
-	installLog    []string
-	installDone   bool
-	installError  string
+	currentStep    int
+	disks          []Disk
+	selectedDisk   int
+	hostname       string
+	username       string
+	password       string
+	rootPassword   string
+	timezone       string
+	locale         string
+	keyboardLayout string
+	theme          string
+	accentColor    string
+	installLog     []string
+	installDone    bool
+	installError   string
+	verifyResults  []VerifyCheck
+	progress       *installProgress
+	logStatus      string
+	installMode    string
+	uefi           bool
+	bootloader     string
+	hardware       *Hardware
+	preflight      []PreflightCheck
+
+	// Region
+	timezones        []string
+	timezonePicked   bool
+	timezoneDetected bool
+
+	// Network
+	wifi           *wifi.Manager
+	netChecking    bool
+	netChecked     bool
+	online         bool
+	netDetail      string
+	wifiNetworks   []wifi.Network
+	wifiScanning   bool
+	wifiConnecting bool
+	wifiError      string
+	selectedWifi   int
+	installUpdates bool
+
+	// Online install
+	installSource  string
+	mirrors        []Mirror
+	selectedMirror int
+	testingMirrors bool
+	download       *downloadProgress
 
 	// Widgets
-	nextBtn       widget.Clickable
-	backBtn       widget.Clickable
-	installBtn    widget.Clickable
-	refreshBtn    widget.Clickable
-	diskList      widget.List
-	diskClicks    []widget.Clickable
-	hostnameEdit  widget.Editor
-	usernameEdit  widget.Editor
-	passwordEdit  widget.Editor
-	rootPassEdit  widget.Editor
+	nextBtn             widget.Clickable
+	backBtn             widget.Clickable
+	installBtn          widget.Clickable
+	refreshBtn          widget.Clickable
+	preflightBtn        widget.Clickable
+	preflightList       widget.List
+	diskList            widget.List
+	diskClicks          []widget.Clickable
+	modeEnum            widget.Enum
+	resizeSplit         widget.Float
+	bootEnum            widget.Enum
+	hostnameEdit        widget.Editor
+	usernameEdit        widget.Editor
+	passwordEdit        widget.Editor
+	rootPassEdit        widget.Editor
+	passwordConfirmEdit widget.Editor
+	rootConfirmEdit     widget.Editor
+	configList          widget.List
+	languageEnum        widget.Enum
+	keyboardEnum        widget.Enum
+	timezoneSearch      widget.Editor
+	timezoneList        widget.List
+	timezoneClicks      []widget.Clickable
+	themeEnum           widget.Enum
+	accentClicks        []widget.Clickable
+	sourceEnum          widget.Enum
+	mirrorList          widget.List
+	mirrorClicks        []widget.Clickable
+	retestBtn           widget.Clickable
+	recheckBtn          widget.Clickable
+	scanBtn             widget.Clickable
+	connectBtn          widget.Clickable
+	wifiList            widget.List
+	wifiClicks          []widget.Clickable
+	wifiPassEdit        widget.Editor
+	updatesCheck        widget.Bool
+	stepList            widget.List
+	hardwareList        widget.List
+	saveLogBtn          widget.Clickable
 }
 
 func main() {
@@ -105,22 +182,71 @@ func run(w *app.Window) error {
 	th.Palette.ContrastFg = colorBackground
 
 	state := &InstallerState{
-		currentStep:  StepWelcome,
-		selectedDisk: -1,
-		hostname:     "raven",
-		username:     "raven",
-		timezone:     "UTC",
-		locale:       "en_US.UTF-8",
+		currentStep:    StepWelcome,
+		selectedDisk:   -1,
+		hostname:       "raven",
+		username:       "raven",
+		timezone:       "UTC",
+		locale:         "en_US.UTF-8",
+		keyboardLayout: "us",
+		theme:          "dark",
+		accentColor:    accentColors[0],
+		installMode:    modeErase,
+		installSource:  sourceMedia,
+		selectedMirror: -1,
+		selectedWifi:   -1,
+		wifi:           wifi.NewManager(),
 	}
 
 	// Initialize editors
 	state.hostnameEdit.SetText(state.hostname)
 	state.usernameEdit.SetText(state.username)
+	for _, ed := range []*widget.Editor{&state.hostnameEdit, &state.usernameEdit, &state.passwordEdit, &state.passwordConfirmEdit, &state.rootPassEdit, &state.rootConfirmEdit} {
+		ed.SingleLine = true
+	}
+	for _, ed := range []*widget.Editor{&state.passwordEdit, &state.passwordConfirmEdit, &state.rootPassEdit, &state.rootConfirmEdit} {
+		ed.Mask = '•'
+	}
+	state.languageEnum.Value = state.locale
+	state.keyboardEnum.Value = state.keyboardLayout
+	state.timezoneSearch.SingleLine = true
+	state.timezoneList.Axis = layout.Vertical
+	state.configList.Axis = layout.Vertical
+	state.stepList.Axis = layout.Vertical
+	state.preflightList.Axis = layout.Vertical
+	state.hardwareList.Axis = layout.Vertical
+	state.wifiList.Axis = layout.Vertical
+	state.wifiPassEdit.SingleLine = true
+	state.wifiPassEdit.Mask = '•'
+	state.themeEnum.Value = state.theme
+	state.sourceEnum.Value = state.installSource
+	state.modeEnum.Value = state.installMode
+
+	// Boot the way the live system was booted
+	state.uefi = detectUEFI()
+	state.bootloader = bootGRUBPC
+	if state.uefi {
+		state.bootloader = bootGRUBEFI
+	}
+	state.bootEnum.Value = state.bootloader
+	state.accentClicks = make([]widget.Clickable, len(accentColors))
+
+	// List timezones and guess ours
+	state.timezones = loadTimezones()
+	state.timezoneClicks = make([]widget.Clickable, len(state.timezones))
+	detectTimezone(state, w)
 
 	// Detect disks
 	state.disks = detectDisks()
 	state.diskClicks = make([]widget.Clickable, len(state.disks))
 
+	// Summarize the hardware for the Install step
+	go func(disks []Disk) {
+		hw := detectHardware(disks)
+		state.hardware = &hw
+		w.Invalidate()
+	}(state.disks)
+
 	var ops op.Ops
 	for {
 		switch e := w.Event().(type) {
@@ -139,7 +265,7 @@ func drawUI(gtx layout.Context, th *material.Theme, state *InstallerState, w *ap
 	paint.FillShape(gtx.Ops, colorBackground, clip.Rect{Max: gtx.Constraints.Max}.Op())
 
 	// Handle button clicks
-	if state.nextBtn.Clicked(gtx) {
+	if state.nextBtn.Clicked(gtx) && canAdvance(state) {
 		if state.currentStep < StepComplete {
 			state.currentStep++
 			if state.currentStep == StepInstallation {
@@ -158,12 +284,68 @@ func drawUI(gtx layout.Context, th *material.Theme, state *InstallerState, w *ap
 	if state.refreshBtn.Clicked(gtx) {
 		state.disks = detectDisks()
 		state.diskClicks = make([]widget.Clickable, len(state.disks))
+		resetAlongside(state)
+	}
+	if state.preflightBtn.Clicked(gtx) {
+		state.disks = detectDisks()
+		state.diskClicks = make([]widget.Clickable, len(state.disks))
+		state.selectedDisk = -1
+		resetAlongside(state)
+		state.preflight = runPreflight(state.disks, state.uefi)
 	}
 
 	// Handle disk clicks
 	for i := range state.diskClicks {
-		if state.diskClicks[i].Clicked(gtx) {
+		if state.diskClicks[i].Clicked(gtx) && i != state.selectedDisk && !state.disks[i].Live {
 			state.selectedDisk = i
+			resetAlongside(state)
+		}
+	}
+
+	// Handle network clicks
+	if state.recheckBtn.Clicked(gtx) && !state.netChecking {
+		checkNetwork(state, w)
+	}
+	if state.scanBtn.Clicked(gtx) && !state.wifiScanning {
+		scanWifi(state, w)
+	}
+	for i := range state.wifiClicks {
+		if state.wifiClicks[i].Clicked(gtx) && i != state.selectedWifi {
+			state.selectedWifi = i
+			state.wifiPassEdit.SetText("")
+		}
+	}
+	if state.connectBtn.Clicked(gtx) && !state.wifiConnecting {
+		connectWifi(state, w)
+	}
+
+	// Handle mirror clicks
+	if state.retestBtn.Clicked(gtx) && !state.testingMirrors {
+		startMirrorTest(state, w)
+	}
+	for i := range state.mirrorClicks {
+		if state.mirrorClicks[i].Clicked(gtx) && i < len(state.mirrors) && state.mirrors[i].Err == nil {
+			state.selectedMirror = i
+		}
+	}
+
+	// Handle timezone clicks
+	for i := range state.timezoneClicks {
+		if state.timezoneClicks[i].Clicked(gtx) {
+			state.timezone = state.timezones[i]
+			state.timezonePicked = true
+			state.timezoneDetected = false
+		}
+	}
+
+	if state.saveLogBtn.Clicked(gtx) {
+		state.logStatus = saveInstallLog(state.installLog)
+	}
+
+	// Handle accent color clicks
+	for i := range state.accentClicks {
+		if state.accentClicks[i].Clicked(gtx) {
+			state.accentColor = accentColors[i]
 		}
 	}
 
@@ -175,7 +357,7 @@ func drawUI(gtx layout.Context, th *material.Theme, state *InstallerState, w *ap
 		}),
 		// Content
 		layout.Flexed(1, func(gtx layout.Context) layout.Dimensions {
-			return drawContent(gtx, th, state)
+			return drawContent(gtx, th, state, w)
 		}),
 		// Footer with navigation
 		layout.Rigid(func(gtx layout.Context) layout.Dimensions {
@@ -195,7 +377,7 @@ func drawHeader(gtx layout.Context, th *material.Theme, state *InstallerState) l
 			}),
 			layout.Rigid(layout.Spacer{Height: unit.Dp(10)}.Layout),
 			layout.Rigid(func(gtx layout.Context) layout.Dimensions {
-				steps := []string{"Welcome", "Disk", "Partitions", "Config", "Install", "Done"}
+				steps := []string{"Welcome", "Checks", "Disk", "Partitions", "Network", "Source", "Config", "Region", "Desktop", "Install", "Done"}
 				return drawProgressBar(gtx, th, state.currentStep, steps)
 			}),
 		)
@@ -227,21 +409,31 @@ func drawProgressBar(gtx layout.Context, th *material.Theme, current int, steps
 	)
 }
 
-func drawContent(gtx layout.Context, th *material.Theme, state *InstallerState) layout.Dimensions {
+func drawContent(gtx layout.Context, th *material.Theme, state *InstallerState, w *app.Window) layout.Dimensions {
 	return layout.UniformInset(unit.Dp(30)).Layout(gtx, func(gtx layout.Context) layout.Dimensions {
 		switch state.currentStep {
 		case StepWelcome:
 			return drawWelcome(gtx, th)
+		case StepRequirements:
+			return drawRequirements(gtx, th, state)
 		case StepDiskSelection:
 			return drawDiskSelection(gtx, th, state)
 		case StepPartitioning:
 			return drawPartitioning(gtx, th, state)
+		case StepNetwork:
+			return drawNetwork(gtx, th, state, w)
+		case StepSource:
+			return drawSource(gtx, th, state, w)
 		case StepConfiguration:
 			return drawConfiguration(gtx, th, state)
+		case StepRegion:
+			return drawRegion(gtx, th, state)
+		case StepDesktop:
+			return drawDesktop(gtx, th, state)
 		case StepInstallation:
 			return drawInstallation(gtx, th, state)
 		case StepComplete:
-			return drawComplete(gtx, th)
+			return drawComplete(gtx, th, state)
 		default:
 			return layout.Dimensions{}
 		}
@@ -282,7 +474,7 @@ A modern, developer-focused Linux distribution featuring:`)
 		}),
 		layout.Rigid(layout.Spacer{Height: unit.Dp(15)}.Layout),
 		layout.Rigid(func(gtx layout.Context) layout.Dimensions {
-				features := material.Body1(th, `Vem - GPU-accelerated text editor
+			features := material.Body1(th, `Vem - GPU-accelerated text editor
 Carrion - Modern programming language
 Ivaldi - Next-generation version control
 rvn - Raven package manager
@@ -309,7 +501,7 @@ func drawDiskSelection(gtx layout.Context, th *material.Theme, state *InstallerS
 		}),
 		layout.Rigid(layout.Spacer{Height: unit.Dp(10)}.Layout),
 		layout.Rigid(func(gtx layout.Context) layout.Dimensions {
-			warn := material.Body2(th, "⚠ Warning: The selected disk will be erased. Make sure to backup important data.")
+			warn := material.Body2(th, "⚠ Warning: Unless RavenLinux is installed alongside the systems on it, the selected disk will be erased. Make sure to backup important data.")
 			warn.Color = colorDanger
 			return warn.Layout(gtx)
 		}),
@@ -336,6 +528,9 @@ func drawDiskSelection(gtx layout.Context, th *material.Theme, state *InstallerS
 							Width: unit.Dp(2),
 						}.Layout(gtx, func(gtx layout.Context) layout.Dimensions {
 							return layout.UniformInset(unit.Dp(15)).Layout(gtx, func(gtx layout.Context) layout.Dimensions {
+								if disk.Live {
+									gtx = gtx.Disabled()
+								}
 								return layout.Flex{Axis: layout.Vertical}.Layout(gtx,
 									layout.Rigid(func(gtx layout.Context) layout.Dimensions {
 										name := material.Body1(th, fmt.Sprintf("%s - %s", disk.Path, disk.Model))
@@ -349,6 +544,23 @@ func drawDiskSelection(gtx layout.Context, th *material.Theme, state *InstallerS
 										size.Color = color.NRGBA{R: 150, G: 150, B: 150, A: 255}
 										return size.Layout(gtx)
 									}),
+									layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+										if !disk.Live {
+											return layout.Dimensions{}
+										}
+										live := material.Body2(th, "Installation medium: RavenLinux is running from this disk")
+										live.Color = colorDanger
+										return live.Layout(gtx)
+									}),
+									layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+										systems := disk.systems()
+										if len(systems) == 0 {
+											return layout.Dimensions{}
+										}
+										found := material.Body2(th, "Installed: "+strings.Join(systems, ", "))
+										found.Color = colorAccent
+										return found.Layout(gtx)
+									}),
 								)
 							})
 						})
@@ -360,23 +572,77 @@ func drawDiskSelection(gtx layout.Context, th *material.Theme, state *InstallerS
 }
 
 func drawPartitioning(gtx layout.Context, th *material.Theme, state *InstallerState) layout.Dimensions {
-	return layout.Flex{Axis: layout.Vertical}.Layout(gtx,
+	part, canShrink := alongsidePartition(state)
+	if !canShrink {
+		state.modeEnum.Value = modeErase
+	}
+	state.installMode = state.modeEnum.Value
+	if state.uefi {
+		state.bootloader = state.bootEnum.Value
+	}
+	mismatch := tableMismatch(state)
+
+	var systems []string
+	if state.selectedDisk >= 0 && state.selectedDisk < len(state.disks) {
+		systems = state.disks[state.selectedDisk].systems()
+	}
+
+	children := []layout.FlexChild{
 		layout.Rigid(func(gtx layout.Context) layout.Dimensions {
 			title := material.H6(th, "Partition Layout")
 			return title.Layout(gtx)
 		}),
 		layout.Rigid(layout.Spacer{Height: unit.Dp(20)}.Layout),
-		layout.Rigid(func(gtx layout.Context) layout.Dimensions {
-			desc := material.Body1(th, `The following partition layout will be created:
+	}
+	if canShrink {
+		alongside := "Install alongside the existing partitions"
+		if len(systems) > 0 {
+			alongside = "Install alongside " + strings.Join(systems, ", ")
+		}
+		children = append(children,
+			layout.Rigid(material.RadioButton(th, &state.modeEnum, modeErase, "Erase the disk and install RavenLinux").Layout),
+			layout.Rigid(material.RadioButton(th, &state.modeEnum, modeAlongside, alongside).Layout),
+			layout.Rigid(layout.Spacer{Height: unit.Dp(20)}.Layout),
+		)
+	}
 
-  /dev/sdX1 - EFI System Partition (512 MB, FAT32)
-  /dev/sdX2 - Root Partition (Remaining space, ext4)
+	if state.installMode == modeAlongside {
+		children = append(children, layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+			return drawAlongside(gtx, th, state, part)
+		}))
+	} else {
+		children = append(children,
+			layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+				desc := material.Body1(th, eraseLayout(state.uefi))
+				return desc.Layout(gtx)
+			}),
+			layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+				if len(systems) == 0 {
+					return layout.Dimensions{}
+				}
+				warn := material.Body1(th, "\nThis erases "+strings.Join(systems, ", ")+" from the disk.")
+				warn.Color = colorDanger
+				return warn.Layout(gtx)
+			}),
+		)
+	}
 
-This uses a simple GPT layout suitable for UEFI systems.
-For advanced partitioning, use manual installation.`)
-			return desc.Layout(gtx)
+	children = append(children,
+		layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+			if mismatch == "" {
+				return layout.Dimensions{}
+			}
+			return layout.Inset{Top: unit.Dp(15)}.Layout(gtx, func(gtx layout.Context) layout.Dimensions {
+				warn := material.Body2(th, "⚠ "+mismatch)
+				warn.Color = colorDanger
+				return warn.Layout(gtx)
+			})
 		}),
-		layout.Rigid(layout.Spacer{Height: unit.Dp(30)}.Layout),
+		layout.Rigid(layout.Spacer{Height: unit.Dp(20)}.Layout),
+		layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+			return drawBootloader(gtx, th, state)
+		}),
+		layout.Rigid(layout.Spacer{Height: unit.Dp(20)}.Layout),
 		layout.Rigid(func(gtx layout.Context) layout.Dimensions {
 			if state.selectedDisk >= 0 && state.selectedDisk < len(state.disks) {
 				disk := state.disks[state.selectedDisk]
@@ -389,6 +655,7 @@ For advanced partitioning, use manual installation.`)
 			return warn.Layout(gtx)
 		}),
 	)
+	return layout.Flex{Axis: layout.Vertical}.Layout(gtx, children...)
 }
 
 func drawConfiguration(gtx layout.Context, th *material.Theme, state *InstallerState) layout.Dimensions {
@@ -398,28 +665,48 @@ func drawConfiguration(gtx layout.Context, th *material.Theme, state *InstallerS
 	state.password = state.passwordEdit.Text()
 	state.rootPassword = state.rootPassEdit.Text()
 
-	return layout.Flex{Axis: layout.Vertical}.Layout(gtx,
-		layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+	problems := validateConfig(state)
+
+	fields := []layout.Widget{
+		func(gtx layout.Context) layout.Dimensions {
 			title := material.H6(th, "System Configuration")
 			return title.Layout(gtx)
-		}),
-		layout.Rigid(layout.Spacer{Height: unit.Dp(20)}.Layout),
-		layout.Rigid(func(gtx layout.Context) layout.Dimensions {
-			return drawFormField(gtx, th, "Hostname:", &state.hostnameEdit)
-		}),
-		layout.Rigid(layout.Spacer{Height: unit.Dp(15)}.Layout),
-		layout.Rigid(func(gtx layout.Context) layout.Dimensions {
-			return drawFormField(gtx, th, "Username:", &state.usernameEdit)
-		}),
-		layout.Rigid(layout.Spacer{Height: unit.Dp(15)}.Layout),
-		layout.Rigid(func(gtx layout.Context) layout.Dimensions {
-			return drawFormField(gtx, th, "Password:", &state.passwordEdit)
-		}),
-		layout.Rigid(layout.Spacer{Height: unit.Dp(15)}.Layout),
-		layout.Rigid(func(gtx layout.Context) layout.Dimensions {
-			return drawFormField(gtx, th, "Root Password:", &state.rootPassEdit)
-		}),
-	)
+		},
+		layout.Spacer{Height: unit.Dp(20)}.Layout,
+		func(gtx layout.Context) layout.Dimensions {
+			return drawValidatedField(gtx, th, "Hostname:", &state.hostnameEdit, problems.hostname)
+		},
+		layout.Spacer{Height: unit.Dp(15)}.Layout,
+		func(gtx layout.Context) layout.Dimensions {
+			return drawValidatedField(gtx, th, "Username:", &state.usernameEdit, problems.username)
+		},
+		layout.Spacer{Height: unit.Dp(15)}.Layout,
+		func(gtx layout.Context) layout.Dimensions {
+			return drawValidatedField(gtx, th, "Password:", &state.passwordEdit, problems.password)
+		},
+		func(gtx layout.Context) layout.Dimensions {
+			return drawStrengthMeter(gtx, th, state.password, state.username)
+		},
+		layout.Spacer{Height: unit.Dp(15)}.Layout,
+		func(gtx layout.Context) layout.Dimensions {
+			return drawValidatedField(gtx, th, "Confirm Password:", &state.passwordConfirmEdit, problems.passwordConfirm)
+		},
+		layout.Spacer{Height: unit.Dp(15)}.Layout,
+		func(gtx layout.Context) layout.Dimensions {
+			return drawValidatedField(gtx, th, "Root Password:", &state.rootPassEdit, problems.rootPassword)
+		},
+		func(gtx layout.Context) layout.Dimensions {
+			return drawStrengthMeter(gtx, th, state.rootPassword, "root")
+		},
+		layout.Spacer{Height: unit.Dp(15)}.Layout,
+		func(gtx layout.Context) layout.Dimensions {
+			return drawValidatedField(gtx, th, "Confirm Root:", &state.rootConfirmEdit, problems.rootPasswordConfirm)
+		},
+	}
+	// The form is taller than the window, so it scrolls
+	return material.List(th, &state.configList).Layout(gtx, len(fields), func(gtx layout.Context, i int) layout.Dimensions {
+		return fields[i](gtx)
+	})
 }
 
 func drawFormField(gtx layout.Context, th *material.Theme, label string, editor *widget.Editor) layout.Dimensions {
@@ -449,7 +736,16 @@ func drawInstallation(gtx layout.Context, th *material.Theme, state *InstallerSt
 			return title.Layout(gtx)
 		}),
 		layout.Rigid(layout.Spacer{Height: unit.Dp(20)}.Layout),
+		layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+			if progress := state.download; progress != nil {
+				return drawDownloadProgress(gtx, th, progress)
+			}
+			return layout.Dimensions{}
+		}),
 		layout.Flexed(1, func(gtx layout.Context) layout.Dimensions {
+			if progress := state.progress; progress != nil {
+				return drawInstallProgress(gtx, th, state, progress)
+			}
 			logText := strings.Join(state.installLog, "\n")
 			lbl := material.Body2(th, logText)
 			lbl.Color = colorText
@@ -463,24 +759,77 @@ func drawInstallation(gtx layout.Context, th *material.Theme, state *InstallerSt
 			}
 			return layout.Dimensions{}
 		}),
+		layout.Rigid(layout.Spacer{Height: unit.Dp(10)}.Layout),
+		layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+			return drawSaveLog(gtx, th, &state.saveLogBtn, state.logStatus)
+		}),
 	)
 }
 
-func drawComplete(gtx layout.Context, th *material.Theme) layout.Dimensions {
-	return layout.Flex{Axis: layout.Vertical, Alignment: layout.Middle}.Layout(gtx,
+func drawComplete(gtx layout.Context, th *material.Theme, state *InstallerState) layout.Dimensions {
+	failed := 0
+	for _, check := range state.verifyResults {
+		if !check.Passed {
+			failed++
+		}
+	}
+
+	children := []layout.FlexChild{
 		layout.Rigid(func(gtx layout.Context) layout.Dimensions {
-			check := material.H3(th, "✓")
-			check.Color = colorAccent
-			return check.Layout(gtx)
+			mark := material.H3(th, "✓")
+			mark.Color = colorAccent
+			if failed > 0 {
+				mark.Text = "!"
+				mark.Color = colorDanger
+			}
+			return mark.Layout(gtx)
 		}),
 		layout.Rigid(layout.Spacer{Height: unit.Dp(20)}.Layout),
 		layout.Rigid(func(gtx layout.Context) layout.Dimensions {
 			title := material.H5(th, "Installation Complete!")
 			title.Color = colorAccent
+			if failed > 0 {
+				title.Text = "Installation Finished With Problems"
+				title.Color = colorDanger
+			}
 			title.Alignment = text.Middle
 			return title.Layout(gtx)
 		}),
 		layout.Rigid(layout.Spacer{Height: unit.Dp(20)}.Layout),
+	}
+
+	// Post-install checklist
+	for _, check := range state.verifyResults {
+		check := check
+		children = append(children, layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+			mark, markColor := "✓", colorAccent
+			if !check.Passed {
+				mark, markColor = "✗", colorDanger
+			}
+			return layout.Flex{Axis: layout.Horizontal}.Layout(gtx,
+				layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+					gtx.Constraints.Min.X = gtx.Dp(unit.Dp(30))
+					lbl := material.Body1(th, mark)
+					lbl.Color = markColor
+					return lbl.Layout(gtx)
+				}),
+				layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+					gtx.Constraints.Min.X = gtx.Dp(unit.Dp(200))
+					lbl := material.Body1(th, check.Name)
+					lbl.Font.Weight = font.Bold
+					return lbl.Layout(gtx)
+				}),
+				layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+					lbl := material.Body2(th, check.Detail)
+					lbl.Color = color.NRGBA{R: 150, G: 150, B: 150, A: 255}
+					return lbl.Layout(gtx)
+				}),
+			)
+		}))
+	}
+
+	children = append(children,
+		layout.Rigid(layout.Spacer{Height: unit.Dp(20)}.Layout),
 		layout.Rigid(func(gtx layout.Context) layout.Dimensions {
 			desc := material.Body1(th, `RavenLinux has been successfully installed!
 
@@ -488,10 +837,22 @@ Please remove the installation media and reboot your computer
 to start using RavenLinux.
 
 Thank you for choosing RavenLinux!`)
+			if failed > 0 {
+				desc.Text = `Some checks failed, so the installed system may not boot or
+let you log in. Fix the problems listed above from this live
+session (the new system is mounted at ` + installTarget + `) before rebooting.`
+				desc.Color = colorDanger
+			}
 			desc.Alignment = text.Middle
 			return desc.Layout(gtx)
 		}),
+		layout.Rigid(layout.Spacer{Height: unit.Dp(20)}.Layout),
+		layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+			return drawSaveLog(gtx, th, &state.saveLogBtn, state.logStatus)
+		}),
 	)
+
+	return layout.Flex{Axis: layout.Vertical, Alignment: layout.Middle}.Layout(gtx, children...)
 }
 
 func drawFooter(gtx layout.Context, th *material.Theme, state *InstallerState) layout.Dimensions {
@@ -509,6 +870,9 @@ func drawFooter(gtx layout.Context, th *material.Theme, state *InstallerState) l
 				if state.currentStep < StepInstallation {
 					btn := material.Button(th, &state.nextBtn, "Next")
 					btn.Background = colorPrimary
+					if !canAdvance(state) {
+						gtx = gtx.Disabled()
+					}
 					return btn.Layout(gtx)
 				} else if state.currentStep == StepComplete {
 					btn := material.Button(th, &state.nextBtn, "Reboot")
@@ -524,6 +888,7 @@ func drawFooter(gtx layout.Context, th *material.Theme, state *InstallerState) l
 // detectDisks finds available storage devices
 func detectDisks() []Disk {
 	var disks []Disk
+	live := liveDisk()
 
 	// Read from /sys/block
 	entries, err := os.ReadDir("/sys/block")
@@ -566,11 +931,14 @@ func detectDisks() []Disk {
 		}
 
 		disks = append(disks, Disk{
-			Path:   path,
-			Name:   name,
-			Size:   size,
-			Model:  model,
-			Vendor: vendor,
+			Path:       path,
+			Name:       name,
+			Size:       size,
+			Model:      model,
+			Vendor:     vendor,
+			PartTable:  partitionTable(path),
+			Live:       name == live,
+			Partitions: detectPartitions(name),
 		})
 	}
 
@@ -603,24 +971,105 @@ func runInstallation(state *InstallerState, w *app.Window) {
 
 	// In a real installer, these would perform actual operations
 	// For now, we simulate the process
-	steps := []string{
-		"Creating partition table...",
-		"Creating EFI partition...",
-		"Creating root partition...",
-		"Formatting EFI partition (FAT32)...",
-		"Formatting root partition (ext4)...",
-		"Mounting partitions...",
-		"Copying system files...",
-		"Installing bootloader...",
-		"Configuring system...",
-		"Setting up users...",
-		"Finalizing installation...",
-	}
-
-	for _, step := range steps {
-		addLog(step)
-		// Simulate work
+	simulate := func() error {
 		exec.Command("sleep", "1").Run()
+		return nil
+	}
+	var progress *installProgress
+	var tasks []installTask
+	for _, step := range partitionSteps(state, disk) {
+		tasks = append(tasks, installTask{name: step, run: simulate})
+	}
+	tasks = append(tasks,
+		installTask{name: "Mounting partitions...", run: simulate},
+		installTask{name: "Copying system files...", weight: copyWeight, run: func() error {
+			// Until partitioning and mounting are real, the copy is too
+			if !isMountpoint(installTarget) {
+				addLog(fmt.Sprintf("  %s is not mounted; simulating the copy", installTarget))
+				return simulateCopy(progress)
+			}
+			if state.installSource == sourceOnline {
+				return installOnline(state, w, addLog)
+			}
+			return copyFromMedia(installTarget, progress, addLog)
+		}},
+	)
+	if state.installUpdates && state.installSource == sourceMedia {
+		tasks = append(tasks, installTask{name: "Downloading updates...", weight: copyWeight / 2, run: func() error {
+			// The copied system still works, so this is not fatal
+			if err := installUpdates(installTarget); err != nil {
+				addLog(fmt.Sprintf("  Warning: %v", err))
+			}
+			return nil
+		}})
+	}
+	tasks = append(tasks,
+		installTask{name: fmt.Sprintf("Installing bootloader (%s)...", bootloaderName(state.bootloader)), run: simulate},
+		installTask{name: "Configuring system...", run: simulate},
+		installTask{name: "Setting up users...", run: simulate},
+	)
+	// systemd-boot finds Windows on the EFI partition by itself
+	if systems := otherSystems(state); len(systems) > 0 && state.bootloader != bootSystemd {
+		tasks = append(tasks, installTask{name: fmt.Sprintf("Adding boot entries for %s...", strings.Join(systems, ", ")), run: func() error {
+			if err := enableOSProber(installTarget); err != nil {
+				addLog(fmt.Sprintf("  Warning: %v", err))
+			}
+			return nil
+		}})
+	}
+	tasks = append(tasks,
+		installTask{name: fmt.Sprintf("Setting region (%s, %s, %s keyboard)...", state.timezone, state.locale, state.keyboardLayout), run: func() error {
+			if err := writeRegion(installTarget, state.timezone, state.locale, state.keyboardLayout); err != nil {
+				addLog(fmt.Sprintf("  Warning: %v", err))
+			}
+			return nil
+		}},
+		installTask{name: fmt.Sprintf("Applying desktop defaults (%s theme)...", state.theme), run: func() error {
+			if err := writeDesktopDefaults(installTarget, state.username, state.theme, state.accentColor, state.keyboardLayout); err != nil {
+				addLog(fmt.Sprintf("  Warning: %v", err))
+			}
+			return nil
+		}},
+		installTask{name: "Finalizing installation...", run: simulate},
+		installTask{name: "Verifying installation...", run: func() error {
+			state.verifyResults = verifyInstallation(installTarget, state.bootloader, state.username, state.password)
+			for _, check := range state.verifyResults {
+				status := "ok"
+				if !check.Passed {
+					status = "FAILED"
+				}
+				addLog(fmt.Sprintf("  %s: %s (%s)", check.Name, status, check.Detail))
+			}
+			return nil
+		}},
+	)
+	progress = newInstallProgress(tasks)
+	state.progress = progress
+
+	// Redraw while installing so the elapsed time moves, following the
+	// package downloads when installing online
+	stop := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(500 * time.Millisecond)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				if download := state.download; download != nil {
+					progress.setPartial(float64(download.fraction()))
+				}
+				w.Invalidate()
+			}
+		}
+	}()
+	err := progress.run(addLog)
+	close(stop)
+	if err != nil {
+		state.installError = err.Error()
+		w.Invalidate()
+		return
 	}
 
 	addLog("Installation complete!")
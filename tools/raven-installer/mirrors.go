@@ -0,0 +1,286 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dustin/go-humanize"
+)
+
+// Where rvn keeps its repositories, and an optional list of mirrors of the
+// main repository, one base URL per line
+const (
+	rvnConfigPath  = "/etc/rvn/config.toml"
+	mirrorListPath = "/etc/rvn/mirrorlist"
+)
+
+// defaultMirror is rvn's built-in repository, used when the live system
+// names none
+const defaultMirror = "https://repo.theravenlinux.org/raven_linux_v0.1.0"
+
+// How long a mirror gets to answer before it counts as unreachable
+const mirrorTestTimeout = 10 * time.Second
+
+// How much of a package is downloaded to measure a mirror's speed, when its
+// index is too small to tell
+const mirrorSampleBytes = 1 << 20
+
+// Mirror is a repository server the packages can be downloaded from
+type Mirror struct {
+	URL     string
+	Latency time.Duration // Until the index started arriving
+	Speed   float64       // Bytes per second
+	Err     error
+	index   *repoIndex // Downloaded while testing
+}
+
+// repoIndex is the part of a repository's index.json the installer uses
+type repoIndex struct {
+	Name     string        `json:"name"`
+	Packages []repoPackage `json:"packages"`
+}
+
+// repoPackage is a package listed in index.json
+type repoPackage struct {
+	Name         string   `json:"name"`
+	Version      string   `json:"version"`
+	Dependencies []string `json:"dependencies"`
+	DownloadSize int64    `json:"download_size"`
+	Filename     string   `json:"filename"`
+	Sha256       string   `json:"sha256"`
+}
+
+// describe returns the speed line shown under the mirror's URL
+func (m Mirror) describe() string {
+	if m.Err != nil {
+		return "Unreachable: " + m.Err.Error()
+	}
+	return fmt.Sprintf("%d ms · %s/s · %d packages", m.Latency.Milliseconds(), humanize.Bytes(uint64(m.Speed)), len(m.index.Packages))
+}
+
+// candidateMirrors returns the mirror list followed by the enabled rvn
+// repositories, without duplicates
+func candidateMirrors() []string {
+	var urls []string
+	if file, err := os.Open(mirrorListPath); err == nil {
+		scanner := bufio.NewScanner(file)
+		for scanner.Scan() {
+			line := strings.TrimSpace(scanner.Text())
+			if line != "" && !strings.HasPrefix(line, "#") {
+				urls = append(urls, line)
+			}
+		}
+		file.Close()
+	}
+	for _, repo := range rvnRepositories() {
+		if repo.enabled && repo.repoType == "" {
+			urls = append(urls, repo.url)
+		}
+	}
+	if len(urls) == 0 {
+		urls = append(urls, defaultMirror)
+	}
+
+	seen := make(map[string]bool)
+	var unique []string
+	for _, url := range urls {
+		url = strings.TrimRight(url, "/")
+		if !seen[url] {
+			seen[url] = true
+			unique = append(unique, url)
+		}
+	}
+	return unique
+}
+
+// rvnRepository is a [[repositories]] table of rvn's config.toml
+type rvnRepository struct {
+	url      string
+	enabled  bool
+	repoType string // "" for plain HTTP repositories, "github" for GitHub raw
+}
+
+// rvnRepositories reads the repositories from rvn's config.toml. Only the
+// simple key = value lines rvn writes are understood.
+func rvnRepositories() []rvnRepository {
+	file, err := os.Open(rvnConfigPath)
+	if err != nil {
+		return nil
+	}
+	defer file.Close()
+
+	var repos []rvnRepository
+	var current *rvnRepository
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, "[") {
+			current = nil
+			if line == "[[repositories]]" {
+				repos = append(repos, rvnRepository{})
+				current = &repos[len(repos)-1]
+			}
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if current == nil || !ok {
+			continue
+		}
+		value = strings.TrimSpace(value)
+		switch strings.TrimSpace(key) {
+		case "url":
+			current.url, _ = strconv.Unquote(value)
+		case "enabled":
+			current.enabled = value == "true"
+		case "type":
+			current.repoType, _ = strconv.Unquote(value)
+		}
+	}
+	return repos
+}
+
+// testMirrors measures every mirror at once and returns them fastest
+// first, with the unreachable ones last
+func testMirrors(urls []string) []Mirror {
+	mirrors := make([]Mirror, len(urls))
+	var wg sync.WaitGroup
+	for i, url := range urls {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			mirrors[i] = testMirror(url)
+		}()
+	}
+	wg.Wait()
+
+	sort.SliceStable(mirrors, func(i, j int) bool {
+		if (mirrors[i].Err == nil) != (mirrors[j].Err == nil) {
+			return mirrors[i].Err == nil
+		}
+		return mirrors[i].Speed > mirrors[j].Speed
+	})
+	return mirrors
+}
+
+// testMirror downloads the mirror's index, and a piece of a package when
+// the index is too small to measure the speed with
+func testMirror(url string) Mirror {
+	m := Mirror{URL: url}
+	client := &http.Client{Timeout: mirrorTestTimeout}
+
+	// Like rvn, look for the index at the root and then under packages/
+	for _, indexURL := range []string{url + "/index.json", url + "/packages/index.json"} {
+		start := time.Now()
+		resp, err := client.Get(indexURL)
+		if err != nil {
+			m.Err = err
+			continue
+		}
+		m.Latency = time.Since(start)
+		data, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err == nil && resp.StatusCode != http.StatusOK {
+			err = fmt.Errorf("HTTP %d", resp.StatusCode)
+		}
+		if err != nil {
+			m.Err = err
+			continue
+		}
+
+		var index repoIndex
+		if err := json.Unmarshal(data, &index); err != nil {
+			m.Err = fmt.Errorf("invalid index: %w", err)
+			continue
+		}
+		m.index = &index
+		m.Err = nil
+		m.Speed = float64(len(data)) / time.Since(start).Seconds()
+		break
+	}
+	if m.Err != nil || m.index == nil {
+		if m.Err == nil {
+			m.Err = fmt.Errorf("no index")
+		}
+		return m
+	}
+
+	if sample := samplePackage(m.index); sample != nil {
+		if speed, err := measureDownload(client, packageURL(url, *sample)); err == nil {
+			m.Speed = speed
+		}
+	}
+	return m
+}
+
+// samplePackage returns the package that best shows a mirror's speed: the
+// smallest one of at least mirrorSampleBytes, or else the largest
+func samplePackage(index *repoIndex) *repoPackage {
+	var best *repoPackage
+	for i := range index.Packages {
+		pkg := &index.Packages[i]
+		switch {
+		case best == nil:
+			best = pkg
+		case best.DownloadSize < mirrorSampleBytes:
+			if pkg.DownloadSize > best.DownloadSize {
+				best = pkg
+			}
+		case pkg.DownloadSize >= mirrorSampleBytes && pkg.DownloadSize < best.DownloadSize:
+			best = pkg
+		}
+	}
+	return best
+}
+
+// measureDownload reads up to mirrorSampleBytes of url and returns the
+// speed in bytes per second
+func measureDownload(client *http.Client, url string) (float64, error) {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Range", fmt.Sprintf("bytes=0-%d", mirrorSampleBytes-1))
+
+	start := time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusPartialContent {
+		return 0, fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+	n, err := io.Copy(io.Discard, io.LimitReader(resp.Body, mirrorSampleBytes))
+	if err != nil {
+		return 0, err
+	}
+	return float64(n) / time.Since(start).Seconds(), nil
+}
+
+// packageURL returns where a mirror keeps a package, as rvn looks for it
+func packageURL(mirror string, pkg repoPackage) string {
+	return mirror + "/packages/" + pkg.Filename
+}
+
+// usableMirror returns the mirror to install from: the one the user picked,
+// or else the fastest that answered. It returns -1 when none did.
+func usableMirror(mirrors []Mirror, picked int) int {
+	if picked >= 0 && picked < len(mirrors) && mirrors[picked].Err == nil {
+		return picked
+	}
+	for i, m := range mirrors {
+		if m.Err == nil {
+			return i
+		}
+	}
+	return -1
+}
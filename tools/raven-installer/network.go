@@ -0,0 +1,306 @@
+package main
+
+import (
+	"fmt"
+	"image/color"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"raven-wifi/wifi"
+
+	"gioui.org/app"
+	"gioui.org/font"
+	"gioui.org/layout"
+	"gioui.org/unit"
+	"gioui.org/widget"
+	"gioui.org/widget/material"
+)
+
+// How long the connectivity check waits for the default mirror
+const networkCheckTimeout = 5 * time.Second
+
+// checkNetwork finds out in the background whether the default mirror
+// can be reached, and through which interfaces
+func checkNetwork(state *InstallerState, w *app.Window) {
+	state.netChecking = true
+	go func() {
+		client := &http.Client{Timeout: networkCheckTimeout}
+		resp, err := client.Head(defaultMirror)
+		if err == nil {
+			resp.Body.Close()
+		}
+
+		state.online = err == nil
+		state.netDetail = describeInterfaces()
+		if err != nil {
+			state.netDetail = "The package mirror can't be reached. Connect a cable or join a WiFi network."
+		}
+		state.netChecking = false
+		state.netChecked = true
+		w.Invalidate()
+	}()
+}
+
+// describeInterfaces lists the interfaces that have an address, such as
+// "enp3s0 192.168.1.5, wlan0 10.0.0.7"
+func describeInterfaces() string {
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return ""
+	}
+	var up []string
+	for _, iface := range ifaces {
+		if iface.Flags&net.FlagLoopback != 0 || iface.Flags&net.FlagUp == 0 {
+			continue
+		}
+		addrs, _ := iface.Addrs()
+		for _, addr := range addrs {
+			if ipnet, ok := addr.(*net.IPNet); ok && ipnet.IP.To4() != nil {
+				up = append(up, iface.Name+" "+ipnet.IP.String())
+				break
+			}
+		}
+	}
+	if len(up) == 0 {
+		return "Connected"
+	}
+	return "Connected through " + strings.Join(up, ", ")
+}
+
+// hasWireless reports whether the computer has a WiFi interface to offer
+func hasWireless(wm *wifi.Manager) bool {
+	_, err := os.Stat(filepath.Join("/sys/class/net", wm.Interface(), "wireless"))
+	return err == nil
+}
+
+// scanWifi lists the WiFi networks in range in the background
+func scanWifi(state *InstallerState, w *app.Window) {
+	state.wifiScanning = true
+	go func() {
+		networks, err := state.wifi.Scan()
+		state.wifiError = ""
+		if err != nil {
+			state.wifiError = fmt.Sprintf("Scan failed: %v", err)
+		}
+		state.wifiNetworks = networks
+		state.wifiClicks = make([]widget.Clickable, len(networks))
+		state.selectedWifi = -1
+		state.wifiScanning = false
+		w.Invalidate()
+	}()
+}
+
+// connectWifi joins the selected network in the background, then checks
+// the connection again
+func connectWifi(state *InstallerState, w *app.Window) {
+	if state.selectedWifi < 0 || state.selectedWifi >= len(state.wifiNetworks) {
+		return
+	}
+	network := state.wifiNetworks[state.selectedWifi]
+	password := state.wifiPassEdit.Text()
+	state.wifiConnecting = true
+	state.wifiError = ""
+	go func() {
+		if err := state.wifi.Connect(network.SSID, password); err != nil {
+			state.wifiError = strings.TrimSpace(err.Error())
+		}
+		state.wifiConnecting = false
+		checkNetwork(state, w)
+		w.Invalidate()
+	}()
+}
+
+// installUpdates brings the copied system up to date from its
+// repositories with rvn
+func installUpdates(target string) error {
+	if err := checkMounted(target); err != nil {
+		return err
+	}
+	if _, err := os.Stat(filepath.Join(target, "usr/bin/rvn")); err != nil {
+		return fmt.Errorf("rvn is not installed in the new system")
+	}
+	for _, args := range [][]string{{"rvn", "sync"}, {"rvn", "upgrade"}} {
+		out, err := exec.Command("chroot", append([]string{target}, args...)...).CombinedOutput()
+		if err != nil {
+			return fmt.Errorf("%s: %v: %s", strings.Join(args, " "), err, strings.TrimSpace(string(out)))
+		}
+	}
+	return nil
+}
+
+func drawNetwork(gtx layout.Context, th *material.Theme, state *InstallerState, w *app.Window) layout.Dimensions {
+	if !state.netChecked && !state.netChecking {
+		checkNetwork(state, w)
+	}
+	wireless := hasWireless(state.wifi)
+	if wireless && state.wifiNetworks == nil && !state.wifiScanning {
+		scanWifi(state, w)
+	}
+	state.installUpdates = state.updatesCheck.Value && state.online
+
+	status, statusColor := "Checking the connection...", colorText
+	if !state.netChecking {
+		status, statusColor = "✓ Online", colorAccent
+		if !state.online {
+			status, statusColor = "✗ Offline", colorDanger
+		}
+	}
+
+	children := []layout.FlexChild{
+		layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+			title := material.H6(th, "Network")
+			return title.Layout(gtx)
+		}),
+		layout.Rigid(layout.Spacer{Height: unit.Dp(20)}.Layout),
+		layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+			return layout.Flex{Axis: layout.Horizontal, Alignment: layout.Middle}.Layout(gtx,
+				layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+					lbl := material.Body1(th, status)
+					lbl.Color = statusColor
+					lbl.Font.Weight = font.Bold
+					return lbl.Layout(gtx)
+				}),
+				layout.Rigid(layout.Spacer{Width: unit.Dp(15)}.Layout),
+				layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+					if state.netChecking {
+						return layout.Dimensions{}
+					}
+					btn := material.Button(th, &state.recheckBtn, "Check Again")
+					btn.Background = colorSurface
+					return btn.Layout(gtx)
+				}),
+			)
+		}),
+		layout.Rigid(layout.Spacer{Height: unit.Dp(5)}.Layout),
+		layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+			desc := material.Body2(th, state.netDetail)
+			desc.Color = color.NRGBA{R: 150, G: 150, B: 150, A: 255}
+			return desc.Layout(gtx)
+		}),
+		layout.Rigid(layout.Spacer{Height: unit.Dp(10)}.Layout),
+		layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+			if !state.online {
+				gtx = gtx.Disabled()
+			}
+			return material.CheckBox(th, &state.updatesCheck, "Download updates while installing").Layout(gtx)
+		}),
+	}
+	if !wireless {
+		return layout.Flex{Axis: layout.Vertical}.Layout(gtx, children...)
+	}
+
+	children = append(children,
+		layout.Rigid(layout.Spacer{Height: unit.Dp(20)}.Layout),
+		layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+			return layout.Flex{Axis: layout.Horizontal, Alignment: layout.Middle}.Layout(gtx,
+				layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+					lbl := material.Body1(th, "WiFi ("+state.wifi.Interface()+")")
+					lbl.Font.Weight = font.Bold
+					return lbl.Layout(gtx)
+				}),
+				layout.Rigid(layout.Spacer{Width: unit.Dp(15)}.Layout),
+				layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+					if state.wifiScanning {
+						lbl := material.Body2(th, "Scanning...")
+						lbl.Color = color.NRGBA{R: 150, G: 150, B: 150, A: 255}
+						return lbl.Layout(gtx)
+					}
+					btn := material.Button(th, &state.scanBtn, "Scan")
+					btn.Background = colorSurface
+					return btn.Layout(gtx)
+				}),
+			)
+		}),
+		layout.Rigid(layout.Spacer{Height: unit.Dp(10)}.Layout),
+		layout.Flexed(1, func(gtx layout.Context) layout.Dimensions {
+			return material.List(th, &state.wifiList).Layout(gtx, len(state.wifiNetworks), func(gtx layout.Context, i int) layout.Dimensions {
+				return drawWifiNetwork(gtx, th, state, i)
+			})
+		}),
+		layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+			if state.selectedWifi < 0 || state.selectedWifi >= len(state.wifiNetworks) {
+				return layout.Dimensions{}
+			}
+			network := state.wifiNetworks[state.selectedWifi]
+			return layout.Inset{Top: unit.Dp(10)}.Layout(gtx, func(gtx layout.Context) layout.Dimensions {
+				return layout.Flex{Axis: layout.Horizontal, Alignment: layout.Middle}.Layout(gtx,
+					layout.Flexed(1, func(gtx layout.Context) layout.Dimensions {
+						if network.Security == "Open" {
+							lbl := material.Body1(th, network.SSID+" is an open network")
+							return lbl.Layout(gtx)
+						}
+						return drawFormField(gtx, th, "Password:", &state.wifiPassEdit)
+					}),
+					layout.Rigid(layout.Spacer{Width: unit.Dp(10)}.Layout),
+					layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+						label := "Connect"
+						if state.wifiConnecting {
+							label = "Connecting..."
+							gtx = gtx.Disabled()
+						}
+						btn := material.Button(th, &state.connectBtn, label)
+						btn.Background = colorPrimary
+						return btn.Layout(gtx)
+					}),
+				)
+			})
+		}),
+		layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+			if state.wifiError == "" {
+				return layout.Dimensions{}
+			}
+			return layout.Inset{Top: unit.Dp(5)}.Layout(gtx, func(gtx layout.Context) layout.Dimensions {
+				lbl := material.Body2(th, state.wifiError)
+				lbl.Color = colorDanger
+				return lbl.Layout(gtx)
+			})
+		}),
+	)
+	return layout.Flex{Axis: layout.Vertical}.Layout(gtx, children...)
+}
+
+// drawWifiNetwork draws a row of the WiFi list, like a mirror on the
+// Source step
+func drawWifiNetwork(gtx layout.Context, th *material.Theme, state *InstallerState, i int) layout.Dimensions {
+	network := state.wifiNetworks[i]
+	return layout.UniformInset(unit.Dp(3)).Layout(gtx, func(gtx layout.Context) layout.Dimensions {
+		selected := i == state.selectedWifi
+		bg := colorSurface
+		if selected {
+			bg = colorPrimary
+		}
+
+		return material.Clickable(gtx, &state.wifiClicks[i], func(gtx layout.Context) layout.Dimensions {
+			return widget.Border{
+				Color: bg,
+				Width: unit.Dp(2),
+			}.Layout(gtx, func(gtx layout.Context) layout.Dimensions {
+				return layout.UniformInset(unit.Dp(10)).Layout(gtx, func(gtx layout.Context) layout.Dimensions {
+					return layout.Flex{Axis: layout.Horizontal, Spacing: layout.SpaceBetween}.Layout(gtx,
+						layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+							name := material.Body1(th, network.SSID)
+							if selected || network.Connected {
+								name.Font.Weight = font.Bold
+							}
+							return name.Layout(gtx)
+						}),
+						layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+							detail := fmt.Sprintf("%s · %d%%", network.Security, network.Signal)
+							if network.Connected {
+								detail = "Connected · " + detail
+							}
+							lbl := material.Body2(th, detail)
+							lbl.Color = color.NRGBA{R: 150, G: 150, B: 150, A: 255}
+							return lbl.Layout(gtx)
+						}),
+					)
+				})
+			})
+		})
+	})
+}
@@ -0,0 +1,319 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"gioui.org/font"
+	"gioui.org/layout"
+	"gioui.org/unit"
+	"gioui.org/widget/material"
+	"github.com/dustin/go-humanize"
+)
+
+// Outcome of a requirement check. Blockers keep the installer on the
+// Requirements step until they are fixed.
+type checkLevel int
+
+const (
+	checkPassed checkLevel = iota
+	checkWarning
+	checkBlocker
+)
+
+// PreflightCheck is a requirement checked before choosing a disk, with a
+// hint at how to fix it when it isn't met
+type PreflightCheck struct {
+	Name   string
+	Level  checkLevel
+	Detail string
+	Hint   string
+}
+
+// Memory needed to install, and to run the desktop comfortably
+const (
+	minMemory         = 1 << 30
+	recommendedMemory = 2 << 30
+)
+
+// Battery charge below which installing on battery is refused
+const minBattery = 20
+
+// The live medium as the initramfs finds and mounts it
+const (
+	liveLabel = "RAVEN_LIVE"
+	liveMount = "/mnt/cdrom"
+)
+
+// secureBootVar holds the firmware's Secure Boot state: four bytes of
+// attributes, then 1 when it is enforced
+const secureBootVar = "/sys/firmware/efi/efivars/SecureBoot-8be4df61-93ca-11d2-aa0d-00e098032b8c"
+
+// runPreflight checks the computer can take RavenLinux
+func runPreflight(disks []Disk, uefi bool) []PreflightCheck {
+	return []PreflightCheck{
+		checkMemory(),
+		checkDiskSpace(disks),
+		checkLiveMedium(disks),
+		checkPower(),
+		checkSecureBoot(uefi),
+	}
+}
+
+// preflightBlocked reports whether any check is a blocker
+func preflightBlocked(checks []PreflightCheck) bool {
+	for _, check := range checks {
+		if check.Level == checkBlocker {
+			return true
+		}
+	}
+	return false
+}
+
+func checkMemory() PreflightCheck {
+	check := PreflightCheck{Name: "Memory"}
+	total := memTotal()
+	switch {
+	case total == 0:
+		check.Level = checkWarning
+		check.Detail = "The amount of memory could not be read"
+	case total < minMemory:
+		check.Level = checkBlocker
+		check.Detail = fmt.Sprintf("%s installed, but at least %s is needed", humanize.IBytes(total), humanize.IBytes(minMemory))
+		check.Hint = "Add memory, or give the virtual machine more."
+	case total < recommendedMemory:
+		check.Level = checkWarning
+		check.Detail = fmt.Sprintf("%s installed; the desktop may be slow with less than %s", humanize.IBytes(total), humanize.IBytes(recommendedMemory))
+		check.Hint = "RavenLinux will install, but consider adding memory."
+	default:
+		check.Detail = humanize.IBytes(total) + " installed"
+	}
+	return check
+}
+
+func checkDiskSpace(disks []Disk) PreflightCheck {
+	check := PreflightCheck{Name: "Disk space"}
+	var fit, liveFit int
+	for _, disk := range disks {
+		if disk.Size < minInstallSize {
+			continue
+		}
+		if disk.Live {
+			liveFit++
+		} else {
+			fit++
+		}
+	}
+
+	switch {
+	case fit > 0:
+		check.Detail = fmt.Sprintf("%d %s of at least %s", fit, plural(fit, "disk", "disks"), humanize.IBytes(minInstallSize))
+	case liveFit > 0:
+		check.Level = checkBlocker
+		check.Detail = "The only disk large enough holds the installation medium"
+		check.Hint = fmt.Sprintf("Connect a disk of at least %s to install onto.", humanize.IBytes(minInstallSize))
+	default:
+		check.Level = checkBlocker
+		check.Detail = fmt.Sprintf("No disk has the %s RavenLinux needs", humanize.IBytes(minInstallSize))
+		check.Hint = "Connect a larger disk, or check the disk is enabled in the firmware settings."
+	}
+	return check
+}
+
+func checkLiveMedium(disks []Disk) PreflightCheck {
+	check := PreflightCheck{Name: "Installation medium"}
+	for _, disk := range disks {
+		if disk.Live {
+			check.Detail = "Running from " + disk.Path + ", which can't be installed onto"
+			return check
+		}
+	}
+	if name := liveDisk(); name != "" {
+		check.Detail = "Running from /dev/" + name
+		return check
+	}
+	check.Detail = "Not running from a RavenLinux live medium"
+	return check
+}
+
+func checkPower() PreflightCheck {
+	check := PreflightCheck{Name: "Power"}
+	supplies, _ := filepath.Glob("/sys/class/power_supply/*")
+	read := func(supply, name string) string {
+		data, _ := os.ReadFile(filepath.Join(supply, name))
+		return strings.TrimSpace(string(data))
+	}
+
+	pluggedIn, battery := false, -1
+	for _, supply := range supplies {
+		switch read(supply, "type") {
+		case "Mains", "USB":
+			if read(supply, "online") == "1" {
+				pluggedIn = true
+			}
+		case "Battery":
+			if capacity, err := strconv.Atoi(read(supply, "capacity")); err == nil {
+				battery = max(battery, capacity)
+			}
+		}
+	}
+
+	switch {
+	case battery < 0:
+		check.Detail = "No battery; running on mains power"
+	case pluggedIn:
+		check.Detail = fmt.Sprintf("Plugged in, battery at %d%%", battery)
+	case battery < minBattery:
+		check.Level = checkBlocker
+		check.Detail = fmt.Sprintf("On battery at %d%%", battery)
+		check.Hint = "Plug in the charger. A computer that runs out of power while installing may not start."
+	default:
+		check.Level = checkWarning
+		check.Detail = fmt.Sprintf("On battery at %d%%", battery)
+		check.Hint = "Plug in the charger before installing, in case the install takes longer than the battery lasts."
+	}
+	return check
+}
+
+func checkSecureBoot(uefi bool) PreflightCheck {
+	check := PreflightCheck{Name: "Secure Boot"}
+	if !uefi {
+		check.Detail = "BIOS firmware, which has no Secure Boot"
+		return check
+	}
+	data, err := os.ReadFile(secureBootVar)
+	if err != nil || len(data) < 5 || data[4] != 1 {
+		check.Detail = "Off"
+		return check
+	}
+	check.Level = checkWarning
+	check.Detail = "On; the installed bootloader isn't signed, so the firmware may refuse to start it"
+	check.Hint = "Turn off Secure Boot in the firmware settings, usually under Security or Boot, before rebooting."
+	return check
+}
+
+// liveDisk returns the name of the disk holding the live medium, such as
+// "sdb" or "sr0", or "" when not running from one
+func liveDisk() string {
+	device := ""
+	if mounts, err := os.ReadFile("/proc/mounts"); err == nil {
+		for _, line := range strings.Split(string(mounts), "\n") {
+			if fields := strings.Fields(line); len(fields) >= 2 && fields[1] == liveMount {
+				device = fields[0]
+				break
+			}
+		}
+	}
+	if device == "" {
+		out, err := exec.Command("blkid", "-L", liveLabel).Output()
+		if err != nil {
+			return ""
+		}
+		device = string(bytes.TrimSpace(out))
+	}
+	if !strings.HasPrefix(device, "/dev/") {
+		return ""
+	}
+
+	// A partition's sysfs directory is inside its disk's
+	name := filepath.Base(device)
+	sysPath, err := filepath.EvalSymlinks(filepath.Join("/sys/class/block", name))
+	if err != nil {
+		return name
+	}
+	if _, err := os.Stat(filepath.Join(sysPath, "partition")); err == nil {
+		return filepath.Base(filepath.Dir(sysPath))
+	}
+	return name
+}
+
+func drawRequirements(gtx layout.Context, th *material.Theme, state *InstallerState) layout.Dimensions {
+	if state.preflight == nil {
+		state.preflight = runPreflight(state.disks, state.uefi)
+	}
+
+	return layout.Flex{Axis: layout.Vertical}.Layout(gtx,
+		layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+			title := material.H6(th, "Requirements")
+			return title.Layout(gtx)
+		}),
+		layout.Rigid(layout.Spacer{Height: unit.Dp(10)}.Layout),
+		layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+			summary := "This computer meets RavenLinux's requirements."
+			summaryColor := colorText
+			if preflightBlocked(state.preflight) {
+				summary = "Fix the problems marked ✗, then check again."
+				summaryColor = colorDanger
+			}
+			lbl := material.Body1(th, summary)
+			lbl.Color = summaryColor
+			return lbl.Layout(gtx)
+		}),
+		layout.Rigid(layout.Spacer{Height: unit.Dp(10)}.Layout),
+		layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+			btn := material.Button(th, &state.preflightBtn, "Check Again")
+			btn.Background = colorSurface
+			return btn.Layout(gtx)
+		}),
+		layout.Rigid(layout.Spacer{Height: unit.Dp(15)}.Layout),
+		layout.Flexed(1, func(gtx layout.Context) layout.Dimensions {
+			return material.List(th, &state.preflightList).Layout(gtx, len(state.preflight), func(gtx layout.Context, i int) layout.Dimensions {
+				return drawPreflightCheck(gtx, th, state.preflight[i])
+			})
+		}),
+	)
+}
+
+// drawPreflightCheck draws a check with a mark for its outcome and, when
+// it isn't met, how to fix it
+func drawPreflightCheck(gtx layout.Context, th *material.Theme, check PreflightCheck) layout.Dimensions {
+	mark, markColor := "✓", colorPrimary
+	switch check.Level {
+	case checkWarning:
+		mark, markColor = "⚠", colorWarning
+	case checkBlocker:
+		mark, markColor = "✗", colorDanger
+	}
+
+	return layout.Inset{Bottom: unit.Dp(10)}.Layout(gtx, func(gtx layout.Context) layout.Dimensions {
+		return layout.Flex{Axis: layout.Horizontal}.Layout(gtx,
+			layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+				gtx.Constraints.Min.X = gtx.Dp(unit.Dp(30))
+				lbl := material.Body1(th, mark)
+				lbl.Color = markColor
+				return lbl.Layout(gtx)
+			}),
+			layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+				gtx.Constraints.Min.X = gtx.Dp(unit.Dp(170))
+				lbl := material.Body1(th, check.Name)
+				lbl.Font.Weight = font.Bold
+				return lbl.Layout(gtx)
+			}),
+			layout.Flexed(1, func(gtx layout.Context) layout.Dimensions {
+				return layout.Flex{Axis: layout.Vertical}.Layout(gtx,
+					layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+						lbl := material.Body1(th, check.Detail)
+						lbl.Color = colorText
+						if check.Level != checkPassed {
+							lbl.Color = markColor
+						}
+						return lbl.Layout(gtx)
+					}),
+					layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+						if check.Hint == "" {
+							return layout.Dimensions{}
+						}
+						lbl := material.Body2(th, check.Hint)
+						lbl.Color = colorAccent
+						return lbl.Layout(gtx)
+					}),
+				)
+			}),
+		)
+	})
+}
@@ -0,0 +1,341 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"image/color"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"gioui.org/layout"
+	"gioui.org/unit"
+	"gioui.org/widget"
+	"gioui.org/widget/material"
+	"github.com/dustin/go-humanize"
+)
+
+// State of a step on the Install step checklist
+type stepStatus int
+
+const (
+	stepPending stepStatus = iota
+	stepRunning
+	stepDone
+	stepFailed
+)
+
+// installTask is a step of the installation. weight is its share of the
+// overall progress bar relative to the other steps.
+type installTask struct {
+	name   string
+	weight float64
+	run    func() error
+}
+
+// Weight of copying the system files, which takes most of the install
+const copyWeight = 20
+
+// installLogPath is where Save log writes the install log, on the live
+// system and on the installed one
+const installLogPath = "/var/log/raven-install.log"
+
+// mediaImage is the live system image copied to the new system
+const mediaImage = "/run/raven/filesystem.squashfs"
+
+// installProgress tracks the steps of a running install for the checklist
+// and the progress bar. It is written by runInstallation and read while
+// drawing.
+type installProgress struct {
+	mu       sync.Mutex
+	tasks    []installTask
+	status   []stepStatus
+	current  int
+	partial  float64 // How much of the current step is done, 0 to 1
+	started  time.Time
+	finished time.Time
+}
+
+func newInstallProgress(tasks []installTask) *installProgress {
+	return &installProgress{
+		tasks:   tasks,
+		status:  make([]stepStatus, len(tasks)),
+		current: -1,
+		started: time.Now(),
+	}
+}
+
+// run runs the steps in order, stopping at the first that fails
+func (p *installProgress) run(addLog func(string)) error {
+	for i, task := range p.tasks {
+		p.mu.Lock()
+		p.current, p.partial = i, 0
+		p.status[i] = stepRunning
+		p.mu.Unlock()
+
+		addLog(task.name)
+		err := task.run()
+
+		p.mu.Lock()
+		p.status[i] = stepDone
+		if err != nil {
+			p.status[i] = stepFailed
+			p.finished = time.Now()
+		}
+		p.mu.Unlock()
+		if err != nil {
+			addLog(fmt.Sprintf("  Failed: %v", err))
+			return err
+		}
+	}
+	p.mu.Lock()
+	p.finished = time.Now()
+	p.mu.Unlock()
+	return nil
+}
+
+// setPartial records how much of the running step is done, for steps that
+// count the bytes they copy
+func (p *installProgress) setPartial(fraction float64) {
+	p.mu.Lock()
+	p.partial = min(max(fraction, 0), 1)
+	p.mu.Unlock()
+}
+
+// fraction returns how much of the install is done, from 0 to 1
+func (p *installProgress) fraction() float32 {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var total, done float64
+	for i, task := range p.tasks {
+		weight := max(task.weight, 1)
+		total += weight
+		switch {
+		case p.status[i] == stepDone:
+			done += weight
+		case i == p.current && p.status[i] == stepRunning:
+			done += weight * p.partial
+		}
+	}
+	if total == 0 {
+		return 0
+	}
+	return float32(done / total)
+}
+
+// describe returns the line under the progress bar: the percentage, the
+// time taken and an estimate of the time left
+func (p *installProgress) describe() string {
+	fraction := p.fraction()
+	p.mu.Lock()
+	end, running := p.finished, p.finished.IsZero()
+	p.mu.Unlock()
+	if running {
+		end = time.Now()
+	}
+	elapsed := end.Sub(p.started).Round(time.Second)
+
+	line := fmt.Sprintf("%d%% · %s elapsed", int(fraction*100), elapsed)
+	if running && fraction > 0.02 && fraction < 1 {
+		left := time.Duration(float64(elapsed) / float64(fraction) * float64(1-fraction))
+		line += fmt.Sprintf(" · about %s left", left.Round(time.Second))
+	}
+	return line
+}
+
+// steps returns the names and states of the steps for drawing
+func (p *installProgress) steps() ([]string, []stepStatus) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	names := make([]string, len(p.tasks))
+	for i, task := range p.tasks {
+		names[i] = strings.TrimSuffix(task.name, "...")
+	}
+	return names, append([]stepStatus(nil), p.status...)
+}
+
+// simulateCopy stands in for the copy when there is nothing to copy to,
+// moving the progress bar along so the ETA can still be followed
+func simulateCopy(progress *installProgress) error {
+	for i := 1; i <= 20; i++ {
+		time.Sleep(250 * time.Millisecond)
+		progress.setPartial(float64(i) / 20)
+	}
+	return nil
+}
+
+// copyFromMedia copies the live system image to target with rsync,
+// feeding its byte counts to the progress bar. Outside the live system,
+// where there is no image, the copy is simulated.
+func copyFromMedia(target string, progress *installProgress, addLog func(string)) error {
+	if err := checkMounted(target); err != nil {
+		return err
+	}
+	if _, err := os.Stat(mediaImage); err != nil {
+		return simulateCopy(progress)
+	}
+
+	source, err := os.MkdirTemp("", "raven-media-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(source)
+	if out, err := exec.Command("mount", "-t", "squashfs", "-o", "ro,loop", mediaImage, source).CombinedOutput(); err != nil {
+		return fmt.Errorf("mount %s: %v: %s", mediaImage, err, strings.TrimSpace(string(out)))
+	}
+	defer exec.Command("umount", source).Run()
+
+	cmd := exec.Command("rsync", "-aHAX", "--no-inc-recursive", "--info=progress2", source+"/", target+"/")
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("rsync: %w", err)
+	}
+
+	// rsync redraws its progress line with carriage returns:
+	// "  1,234,567  45%  10.00MB/s  0:00:05 (xfr#12, to-chk=34/56)"
+	var copied uint64
+	scanner := bufio.NewScanner(stdout)
+	scanner.Split(scanProgressLines)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 || !strings.HasSuffix(fields[1], "%") {
+			continue
+		}
+		if n, err := strconv.ParseUint(strings.ReplaceAll(fields[0], ",", ""), 10, 64); err == nil {
+			copied = n
+		}
+		if percent, err := strconv.Atoi(strings.TrimSuffix(fields[1], "%")); err == nil {
+			progress.setPartial(float64(percent) / 100)
+		}
+	}
+	if err := cmd.Wait(); err != nil {
+		return fmt.Errorf("rsync: %v: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	addLog(fmt.Sprintf("  Copied %s", humanize.Bytes(copied)))
+	return nil
+}
+
+// scanProgressLines splits output into lines ending in \n or \r
+func scanProgressLines(data []byte, atEOF bool) (advance int, token []byte, err error) {
+	if i := bytes.IndexAny(data, "\r\n"); i >= 0 {
+		return i + 1, data[:i], nil
+	}
+	if atEOF && len(data) > 0 {
+		return len(data), data, nil
+	}
+	return 0, nil, nil
+}
+
+// saveInstallLog writes the install log to installLogPath on the live
+// system and, once it is mounted and its /var/log exists, on the installed
+// one. It returns what to tell the user.
+func saveInstallLog(lines []string) string {
+	data := []byte(strings.Join(lines, "\n") + "\n")
+	if err := os.WriteFile(installLogPath, data, 0644); err != nil {
+		return fmt.Sprintf("Could not save the log: %v", err)
+	}
+
+	targetLog := filepath.Join(installTarget, installLogPath)
+	if !isMountpoint(installTarget) {
+		return "Log saved to " + installLogPath
+	}
+	if _, err := os.Stat(filepath.Dir(targetLog)); err != nil {
+		return "Log saved to " + installLogPath
+	}
+	if err := os.WriteFile(targetLog, data, 0644); err != nil {
+		return fmt.Sprintf("Log saved to %s, but not to the new system: %v", installLogPath, err)
+	}
+	return "Log saved to " + installLogPath + " here and on the new system"
+}
+
+// drawInstallProgress draws the overall progress bar, the checklist of
+// steps and, beside it, the slideshow and hardware summary
+func drawInstallProgress(gtx layout.Context, th *material.Theme, state *InstallerState, progress *installProgress) layout.Dimensions {
+	names, status := progress.steps()
+
+	return layout.Flex{Axis: layout.Vertical}.Layout(gtx,
+		layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+			bar := material.ProgressBar(th, progress.fraction())
+			bar.Color = colorPrimary
+			bar.TrackColor = colorSurface
+			return bar.Layout(gtx)
+		}),
+		layout.Rigid(layout.Spacer{Height: unit.Dp(5)}.Layout),
+		layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+			lbl := material.Body2(th, progress.describe())
+			lbl.Color = colorText
+			return lbl.Layout(gtx)
+		}),
+		layout.Rigid(layout.Spacer{Height: unit.Dp(15)}.Layout),
+		layout.Flexed(1, func(gtx layout.Context) layout.Dimensions {
+			return layout.Flex{Axis: layout.Horizontal}.Layout(gtx,
+				layout.Flexed(1, func(gtx layout.Context) layout.Dimensions {
+					return material.List(th, &state.stepList).Layout(gtx, len(names), func(gtx layout.Context, i int) layout.Dimensions {
+						return drawStepStatus(gtx, th, names[i], status[i])
+					})
+				}),
+				layout.Rigid(layout.Spacer{Width: unit.Dp(20)}.Layout),
+				layout.Flexed(1, func(gtx layout.Context) layout.Dimensions {
+					return drawShowcase(gtx, th, state, progress.started)
+				}),
+			)
+		}),
+	)
+}
+
+// drawStepStatus draws a line of the checklist with a mark for its state
+func drawStepStatus(gtx layout.Context, th *material.Theme, name string, status stepStatus) layout.Dimensions {
+	gray := color.NRGBA{R: 150, G: 150, B: 150, A: 255}
+	mark, markColor, textColor := "○", gray, gray
+	switch status {
+	case stepRunning:
+		mark, markColor, textColor = "▸", colorAccent, colorAccent
+	case stepDone:
+		mark, markColor, textColor = "✓", colorPrimary, colorText
+	case stepFailed:
+		mark, markColor, textColor = "✗", colorDanger, colorDanger
+	}
+
+	return layout.Inset{Bottom: unit.Dp(4)}.Layout(gtx, func(gtx layout.Context) layout.Dimensions {
+		return layout.Flex{Axis: layout.Horizontal}.Layout(gtx,
+			layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+				gtx.Constraints.Min.X = gtx.Dp(unit.Dp(30))
+				lbl := material.Body1(th, mark)
+				lbl.Color = markColor
+				return lbl.Layout(gtx)
+			}),
+			layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+				lbl := material.Body1(th, name)
+				lbl.Color = textColor
+				return lbl.Layout(gtx)
+			}),
+		)
+	})
+}
+
+// drawSaveLog draws the Save log button and what became of the last save
+func drawSaveLog(gtx layout.Context, th *material.Theme, click *widget.Clickable, status string) layout.Dimensions {
+	return layout.Flex{Axis: layout.Horizontal, Alignment: layout.Middle}.Layout(gtx,
+		layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+			btn := material.Button(th, click, "Save Log")
+			btn.Background = colorSurface
+			return btn.Layout(gtx)
+		}),
+		layout.Rigid(layout.Spacer{Width: unit.Dp(10)}.Layout),
+		layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+			lbl := material.Body2(th, status)
+			lbl.Color = color.NRGBA{R: 150, G: 150, B: 150, A: 255}
+			return lbl.Layout(gtx)
+		}),
+	)
+}
@@ -0,0 +1,293 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"image/color"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"gioui.org/app"
+	"gioui.org/font"
+	"gioui.org/layout"
+	"gioui.org/unit"
+	"gioui.org/widget"
+	"gioui.org/widget/material"
+)
+
+// installerLanguages are the languages offered on the Region step
+var installerLanguages = []struct {
+	Locale string
+	Name   string
+}{
+	{"en_US.UTF-8", "English (US)"},
+	{"en_GB.UTF-8", "English (UK)"},
+	{"de_DE.UTF-8", "Deutsch"},
+	{"fr_FR.UTF-8", "Français"},
+	{"es_ES.UTF-8", "Español"},
+	{"it_IT.UTF-8", "Italiano"},
+	{"pt_BR.UTF-8", "Português (Brasil)"},
+	{"ru_RU.UTF-8", "Русский"},
+}
+
+// keyboardLayouts match the choices in raven-settings-menu. Keymap is the
+// console's name for the layout.
+var keyboardLayouts = []struct {
+	Layout string
+	Keymap string
+	Name   string
+}{
+	{"us", "us", "English (US)"},
+	{"gb", "uk", "English (UK)"},
+	{"de", "de", "German"},
+	{"fr", "fr", "French"},
+	{"es", "es", "Spanish"},
+	{"it", "it", "Italian"},
+	{"ru", "ru", "Russian"},
+	{"jp", "jp106", "Japanese"},
+}
+
+// Choices per row of radio buttons
+const choicesPerRow = 4
+
+// GeoIP service used to guess the timezone, answering with the
+// {"time_zone": "Area/City"} JSON Calamares also reads
+const (
+	geoIPURL     = "https://geoip.kde.org/v1/calamares"
+	geoIPTimeout = 5 * time.Second
+)
+
+// Where the timezone database is installed, on the live system as on
+// the new one
+const zoneinfoDir = "/usr/share/zoneinfo"
+
+// loadTimezones lists the timezones in the live system's database, UTC
+// first and then the rest in order
+func loadTimezones() []string {
+	zones := []string{"UTC"}
+	for _, table := range []string{"zone1970.tab", "zone.tab"} {
+		f, err := os.Open(filepath.Join(zoneinfoDir, table))
+		if err != nil {
+			continue
+		}
+		var names []string
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			line := scanner.Text()
+			if strings.HasPrefix(line, "#") {
+				continue
+			}
+			if fields := strings.Split(line, "\t"); len(fields) >= 3 {
+				names = append(names, fields[2])
+			}
+		}
+		f.Close()
+		sort.Strings(names)
+		return append(zones, names...)
+	}
+	return zones
+}
+
+// detectTimezone asks the GeoIP service for the timezone in the
+// background and selects it, unless the user already picked one. Offline
+// the request fails and the timezone stays as it is.
+func detectTimezone(state *InstallerState, w *app.Window) {
+	go func() {
+		client := &http.Client{Timeout: geoIPTimeout}
+		resp, err := client.Get(geoIPURL)
+		if err != nil {
+			return
+		}
+		defer resp.Body.Close()
+
+		var answer struct {
+			TimeZone string `json:"time_zone"`
+		}
+		if resp.StatusCode != http.StatusOK || json.NewDecoder(resp.Body).Decode(&answer) != nil {
+			return
+		}
+		if state.timezonePicked || !knownTimezone(state.timezones, answer.TimeZone) {
+			return
+		}
+		state.timezone = answer.TimeZone
+		state.timezoneDetected = true
+		w.Invalidate()
+	}()
+}
+
+func knownTimezone(zones []string, zone string) bool {
+	for _, z := range zones {
+		if z == zone {
+			return true
+		}
+	}
+	return false
+}
+
+// matchingTimezones returns the indexes of the timezones containing query,
+// ignoring case and treating spaces as the underscores in the names
+func matchingTimezones(zones []string, query string) []int {
+	query = strings.ToLower(strings.ReplaceAll(strings.TrimSpace(query), " ", "_"))
+	var matches []int
+	for i, zone := range zones {
+		if strings.Contains(strings.ToLower(zone), query) {
+			matches = append(matches, i)
+		}
+	}
+	return matches
+}
+
+func drawRegion(gtx layout.Context, th *material.Theme, state *InstallerState) layout.Dimensions {
+	state.locale = state.languageEnum.Value
+	state.keyboardLayout = state.keyboardEnum.Value
+
+	zoneNote := "Pick the timezone the clock shows."
+	if state.timezoneDetected {
+		zoneNote = "Detected from your location. Pick another if it is wrong."
+	}
+	matches := matchingTimezones(state.timezones, state.timezoneSearch.Text())
+
+	return layout.Flex{Axis: layout.Vertical}.Layout(gtx,
+		layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+			title := material.H6(th, "Region")
+			return title.Layout(gtx)
+		}),
+		layout.Rigid(layout.Spacer{Height: unit.Dp(20)}.Layout),
+		layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+			return drawChoiceField(gtx, th, "Language:", func(gtx layout.Context) layout.Dimensions {
+				choices := make([][2]string, len(installerLanguages))
+				for i, lang := range installerLanguages {
+					choices[i] = [2]string{lang.Locale, lang.Name}
+				}
+				return drawRadioGrid(gtx, th, &state.languageEnum, choices)
+			})
+		}),
+		layout.Rigid(layout.Spacer{Height: unit.Dp(15)}.Layout),
+		layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+			return drawChoiceField(gtx, th, "Keyboard:", func(gtx layout.Context) layout.Dimensions {
+				choices := make([][2]string, len(keyboardLayouts))
+				for i, kb := range keyboardLayouts {
+					choices[i] = [2]string{kb.Layout, kb.Name}
+				}
+				return drawRadioGrid(gtx, th, &state.keyboardEnum, choices)
+			})
+		}),
+		layout.Rigid(layout.Spacer{Height: unit.Dp(15)}.Layout),
+		layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+			return drawFormField(gtx, th, "Timezone:", &state.timezoneSearch)
+		}),
+		layout.Rigid(layout.Spacer{Height: unit.Dp(5)}.Layout),
+		layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+			return layout.Inset{Left: unit.Dp(150)}.Layout(gtx, func(gtx layout.Context) layout.Dimensions {
+				note := material.Body2(th, state.timezone+" - "+zoneNote)
+				note.Color = color.NRGBA{R: 150, G: 150, B: 150, A: 255}
+				return note.Layout(gtx)
+			})
+		}),
+		layout.Rigid(layout.Spacer{Height: unit.Dp(5)}.Layout),
+		layout.Flexed(1, func(gtx layout.Context) layout.Dimensions {
+			return layout.Inset{Left: unit.Dp(150)}.Layout(gtx, func(gtx layout.Context) layout.Dimensions {
+				return material.List(th, &state.timezoneList).Layout(gtx, len(matches), func(gtx layout.Context, i int) layout.Dimensions {
+					zone := state.timezones[matches[i]]
+					return material.Clickable(gtx, &state.timezoneClicks[matches[i]], func(gtx layout.Context) layout.Dimensions {
+						return layout.UniformInset(unit.Dp(4)).Layout(gtx, func(gtx layout.Context) layout.Dimensions {
+							lbl := material.Body1(th, strings.ReplaceAll(zone, "_", " "))
+							if zone == state.timezone {
+								lbl.Color = colorAccent
+								lbl.Font.Weight = font.Bold
+							}
+							return lbl.Layout(gtx)
+						})
+					})
+				})
+			})
+		}),
+	)
+}
+
+// drawRadioGrid lays out radio buttons for choices of {value, label} in
+// rows of choicesPerRow
+func drawRadioGrid(gtx layout.Context, th *material.Theme, enum *widget.Enum, choices [][2]string) layout.Dimensions {
+	var rows []layout.FlexChild
+	for start := 0; start < len(choices); start += choicesPerRow {
+		row := choices[start:min(start+choicesPerRow, len(choices))]
+		rows = append(rows, layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+			var buttons []layout.FlexChild
+			for _, choice := range row {
+				buttons = append(buttons, layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+					gtx.Constraints.Min.X = gtx.Dp(unit.Dp(170))
+					return material.RadioButton(th, enum, choice[0], choice[1]).Layout(gtx)
+				}))
+			}
+			return layout.Flex{Axis: layout.Horizontal}.Layout(gtx, buttons...)
+		}))
+	}
+	return layout.Flex{Axis: layout.Vertical}.Layout(gtx, rows...)
+}
+
+// writeRegion applies the Region step to the installed system: the
+// timezone, the system locale and the console keyboard layout
+func writeRegion(target, timezone, locale, keyboard string) error {
+	if err := checkMounted(target); err != nil {
+		return err
+	}
+	if _, err := os.Stat(filepath.Join(zoneinfoDir, timezone)); err != nil {
+		return fmt.Errorf("timezone %s: %w", timezone, err)
+	}
+	localtime := filepath.Join(target, "etc/localtime")
+	os.Remove(localtime)
+	if err := os.Symlink(filepath.Join(zoneinfoDir, timezone), localtime); err != nil {
+		return fmt.Errorf("timezone: %w", err)
+	}
+
+	if err := writeLocale(target, locale); err != nil {
+		return fmt.Errorf("locale: %w", err)
+	}
+
+	keymap := keyboard
+	for _, kb := range keyboardLayouts {
+		if kb.Layout == keyboard {
+			keymap = kb.Keymap
+		}
+	}
+	if err := os.WriteFile(filepath.Join(target, "etc/vconsole.conf"), []byte("KEYMAP="+keymap+"\n"), 0644); err != nil {
+		return fmt.Errorf("keyboard: %w", err)
+	}
+	return nil
+}
+
+// writeLocale sets LANG in /etc/locale.conf and enables the locale in
+// /etc/locale.gen when the system has one
+func writeLocale(target, locale string) error {
+	if err := os.WriteFile(filepath.Join(target, "etc/locale.conf"), []byte("LANG="+locale+"\n"), 0644); err != nil {
+		return err
+	}
+
+	genPath := filepath.Join(target, "etc/locale.gen")
+	data, err := os.ReadFile(genPath)
+	if err != nil {
+		return nil
+	}
+	charset := "UTF-8"
+	if i := strings.Index(locale, "."); i >= 0 {
+		charset = locale[i+1:]
+	}
+	entry := locale + " " + charset
+	lines := strings.Split(string(data), "\n")
+	found := false
+	for i, line := range lines {
+		if strings.TrimSpace(strings.TrimLeft(line, "# ")) == entry {
+			lines[i] = entry
+			found = true
+		}
+	}
+	if !found {
+		lines = append(lines, entry)
+	}
+	return os.WriteFile(genPath, []byte(strings.Join(lines, "\n")), 0644)
+}
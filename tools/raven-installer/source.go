@@ -0,0 +1,225 @@
+package main
+
+import (
+	"fmt"
+	"image/color"
+	"path/filepath"
+	"time"
+
+	"gioui.org/app"
+	"gioui.org/font"
+	"gioui.org/layout"
+	"gioui.org/unit"
+	"gioui.org/widget"
+	"gioui.org/widget/material"
+	"github.com/dustin/go-humanize"
+)
+
+// Where the new system's files come from
+const (
+	sourceMedia  = "media"  // Copied from the installation media
+	sourceOnline = "online" // Downloaded from a mirror
+)
+
+// startMirrorTest measures the mirrors in the background and selects the
+// fastest, unless the user already picked one that answered
+func startMirrorTest(state *InstallerState, w *app.Window) {
+	state.testingMirrors = true
+	go func() {
+		var picked string
+		if state.selectedMirror >= 0 && state.selectedMirror < len(state.mirrors) {
+			picked = state.mirrors[state.selectedMirror].URL
+		}
+
+		mirrors := testMirrors(candidateMirrors())
+		selected := -1
+		for i, m := range mirrors {
+			if m.URL == picked {
+				selected = i
+			}
+		}
+
+		state.mirrors = mirrors
+		state.mirrorClicks = make([]widget.Clickable, len(mirrors))
+		state.selectedMirror = usableMirror(mirrors, selected)
+		state.testingMirrors = false
+		w.Invalidate()
+	}()
+}
+
+func drawSource(gtx layout.Context, th *material.Theme, state *InstallerState, w *app.Window) layout.Dimensions {
+	state.installSource = state.sourceEnum.Value
+	if state.installSource == sourceOnline && state.mirrors == nil && !state.testingMirrors {
+		startMirrorTest(state, w)
+	}
+
+	children := []layout.FlexChild{
+		layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+			title := material.H6(th, "Installation Source")
+			return title.Layout(gtx)
+		}),
+		layout.Rigid(layout.Spacer{Height: unit.Dp(20)}.Layout),
+		layout.Rigid(material.RadioButton(th, &state.sourceEnum, sourceMedia, "Copy from the installation media").Layout),
+		layout.Rigid(material.RadioButton(th, &state.sourceEnum, sourceOnline, "Download the latest packages (online)").Layout),
+	}
+	if state.installSource != sourceOnline {
+		return layout.Flex{Axis: layout.Vertical}.Layout(gtx, children...)
+	}
+
+	status := "Testing mirrors..."
+	if !state.testingMirrors {
+		status = "The fastest mirror is selected. Click another mirror to use it instead."
+		if state.selectedMirror < 0 {
+			status = "No mirror could be reached. Check the network connection and test again."
+		}
+	}
+
+	children = append(children,
+		layout.Rigid(layout.Spacer{Height: unit.Dp(20)}.Layout),
+		layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+			desc := material.Body2(th, status)
+			desc.Color = color.NRGBA{R: 150, G: 150, B: 150, A: 255}
+			if !state.testingMirrors && state.selectedMirror < 0 {
+				desc.Color = colorDanger
+			}
+			return desc.Layout(gtx)
+		}),
+		layout.Rigid(layout.Spacer{Height: unit.Dp(10)}.Layout),
+		layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+			if state.testingMirrors {
+				return layout.Dimensions{}
+			}
+			btn := material.Button(th, &state.retestBtn, "Test Again")
+			btn.Background = colorSurface
+			return btn.Layout(gtx)
+		}),
+		layout.Rigid(layout.Spacer{Height: unit.Dp(10)}.Layout),
+		layout.Flexed(1, func(gtx layout.Context) layout.Dimensions {
+			if state.testingMirrors {
+				return layout.Dimensions{}
+			}
+			return material.List(th, &state.mirrorList).Layout(gtx, len(state.mirrors), func(gtx layout.Context, i int) layout.Dimensions {
+				return drawMirror(gtx, th, state, i)
+			})
+		}),
+	)
+	return layout.Flex{Axis: layout.Vertical}.Layout(gtx, children...)
+}
+
+// drawMirror draws a row of the mirror list, like a disk on the Disk step
+func drawMirror(gtx layout.Context, th *material.Theme, state *InstallerState, i int) layout.Dimensions {
+	mirror := state.mirrors[i]
+	return layout.UniformInset(unit.Dp(5)).Layout(gtx, func(gtx layout.Context) layout.Dimensions {
+		selected := i == state.selectedMirror
+		bg := colorSurface
+		if selected {
+			bg = colorPrimary
+		}
+
+		return material.Clickable(gtx, &state.mirrorClicks[i], func(gtx layout.Context) layout.Dimensions {
+			return widget.Border{
+				Color: bg,
+				Width: unit.Dp(2),
+			}.Layout(gtx, func(gtx layout.Context) layout.Dimensions {
+				return layout.UniformInset(unit.Dp(15)).Layout(gtx, func(gtx layout.Context) layout.Dimensions {
+					return layout.Flex{Axis: layout.Vertical}.Layout(gtx,
+						layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+							name := material.Body1(th, mirror.URL)
+							if selected {
+								name.Font.Weight = font.Bold
+							}
+							return name.Layout(gtx)
+						}),
+						layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+							speed := material.Body2(th, mirror.describe())
+							speed.Color = color.NRGBA{R: 150, G: 150, B: 150, A: 255}
+							if mirror.Err != nil {
+								speed.Color = colorDanger
+							}
+							return speed.Layout(gtx)
+						}),
+					)
+				})
+			})
+		})
+	})
+}
+
+// drawDownloadProgress draws the combined progress of the package
+// downloads on the Install step
+func drawDownloadProgress(gtx layout.Context, th *material.Theme, progress *downloadProgress) layout.Dimensions {
+	return layout.Flex{Axis: layout.Vertical}.Layout(gtx,
+		layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+			bar := material.ProgressBar(th, progress.fraction())
+			bar.Color = colorPrimary
+			bar.TrackColor = colorSurface
+			return bar.Layout(gtx)
+		}),
+		layout.Rigid(layout.Spacer{Height: unit.Dp(5)}.Layout),
+		layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+			lbl := material.Body2(th, progress.describe())
+			lbl.Color = colorText
+			return lbl.Layout(gtx)
+		}),
+		layout.Rigid(layout.Spacer{Height: unit.Dp(15)}.Layout),
+	)
+}
+
+// installOnline downloads the packages from the selected mirror, several at
+// a time, and unpacks them into the new system
+func installOnline(state *InstallerState, w *app.Window, addLog func(string)) error {
+	if err := checkMounted(installTarget); err != nil {
+		return err
+	}
+	selected := usableMirror(state.mirrors, state.selectedMirror)
+	if selected < 0 {
+		return fmt.Errorf("no mirror could be reached")
+	}
+	mirror := state.mirrors[selected]
+	addLog(fmt.Sprintf("Using mirror %s", mirror.URL))
+
+	pkgs, err := selectPackages(mirror.index, installPackageNames())
+	if err != nil {
+		return err
+	}
+	progress := &downloadProgress{count: len(pkgs), started: time.Now()}
+	for _, pkg := range pkgs {
+		progress.total += pkg.DownloadSize
+	}
+
+	workers := parallelDownloads()
+	addLog(fmt.Sprintf("Downloading %d packages (%s), %d at a time...", len(pkgs), humanize.Bytes(uint64(progress.total)), workers))
+	state.download = progress
+	w.Invalidate()
+
+	// Redraw while downloading so the progress bar moves
+	stop := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(250 * time.Millisecond)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				w.Invalidate()
+			}
+		}
+	}()
+	cache := filepath.Join(installTarget, packageCacheDir)
+	err = downloadPackages(mirror.URL, pkgs, cache, workers, progress)
+	close(stop)
+	state.download = nil
+	w.Invalidate()
+	if err != nil {
+		return err
+	}
+
+	addLog("Unpacking packages...")
+	for _, pkg := range pkgs {
+		if err := unpackPackage(filepath.Join(cache, filepath.Base(pkg.Filename)), installTarget); err != nil {
+			return fmt.Errorf("%s: %w", pkg.Name, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,223 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"gioui.org/layout"
+	"gioui.org/op/clip"
+	"gioui.org/op/paint"
+	"gioui.org/unit"
+	"gioui.org/widget"
+	"gioui.org/widget/material"
+)
+
+// Shortest password accepted for the user and root
+const minPasswordLength = 8
+
+// The names useradd accepts by default. Hostnames are checked against
+// hostnamePattern, as the installed system is.
+var usernamePattern = regexp.MustCompile(`^[a-z_][a-z0-9_-]{0,31}$`)
+
+// reservedUsernames belong to the system accounts and groups of the
+// installed system
+var reservedUsernames = map[string]bool{
+	"root": true, "bin": true, "daemon": true, "adm": true, "lp": true,
+	"sync": true, "shutdown": true, "halt": true, "mail": true, "news": true,
+	"uucp": true, "operator": true, "games": true, "ftp": true, "nobody": true,
+	"dbus": true, "polkitd": true, "seatd": true, "sshd": true, "messagebus": true,
+	"systemd-network": true, "systemd-resolve": true, "avahi": true, "rtkit": true,
+	"wheel": true, "users": true, "video": true, "audio": true, "input": true,
+	"render": true, "kvm": true, "disk": true, "tty": true, "sys": true,
+}
+
+// configProblems are what keeps each Config step field from being
+// accepted; "" for a field that is fine
+type configProblems struct {
+	hostname, username                string
+	password, passwordConfirm         string
+	rootPassword, rootPasswordConfirm string
+}
+
+func (p configProblems) ok() bool {
+	return p == configProblems{}
+}
+
+// validateConfig checks the Config step fields as they are in state
+func validateConfig(state *InstallerState) configProblems {
+	return configProblems{
+		hostname:            hostnameProblem(state.hostname),
+		username:            usernameProblem(state.username),
+		password:            passwordProblem(state.password),
+		passwordConfirm:     confirmProblem(state.password, state.passwordConfirmEdit.Text()),
+		rootPassword:        passwordProblem(state.rootPassword),
+		rootPasswordConfirm: confirmProblem(state.rootPassword, state.rootConfirmEdit.Text()),
+	}
+}
+
+func hostnameProblem(hostname string) string {
+	switch {
+	case hostname == "":
+		return "Enter a name for this computer"
+	case len(hostname) > 63:
+		return "Use at most 63 characters"
+	case !hostnamePattern.MatchString(hostname):
+		return "Use letters, digits and hyphens, not starting or ending with a hyphen"
+	}
+	return ""
+}
+
+func usernameProblem(username string) string {
+	switch {
+	case username == "":
+		return "Enter a username"
+	case len(username) > 32:
+		return "Use at most 32 characters"
+	case !usernamePattern.MatchString(username):
+		return "Start with a lowercase letter, then use lowercase letters, digits, - and _"
+	case reservedUsernames[username]:
+		return username + " is used by the system"
+	}
+	return ""
+}
+
+func passwordProblem(password string) string {
+	switch {
+	case password == "":
+		return "Enter a password"
+	case len([]rune(password)) < minPasswordLength:
+		return fmt.Sprintf("Use at least %d characters", minPasswordLength)
+	}
+	return ""
+}
+
+func confirmProblem(password, confirm string) string {
+	if password != "" && confirm != password {
+		return "The passwords don't match"
+	}
+	return ""
+}
+
+// passwordStrength rates password from 0 (weak) to 3 (strong) by its
+// length and the kinds of characters in it. Passwords containing the
+// username are rated weak.
+func passwordStrength(password, username string) int {
+	if len([]rune(password)) < minPasswordLength {
+		return 0
+	}
+	if username != "" && strings.Contains(strings.ToLower(password), strings.ToLower(username)) {
+		return 0
+	}
+
+	var lower, upper, digit, other bool
+	for _, r := range password {
+		switch {
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsDigit(r):
+			digit = true
+		default:
+			other = true
+		}
+	}
+	kinds := 0
+	for _, has := range []bool{lower, upper, digit, other} {
+		if has {
+			kinds++
+		}
+	}
+
+	score := 0
+	if kinds >= 2 {
+		score++
+	}
+	if kinds >= 3 {
+		score++
+	}
+	if len([]rune(password)) >= 12 {
+		score++
+	}
+	return min(score, 3)
+}
+
+// Labels and colors of the password strengths
+var (
+	strengthLabels = []string{"Weak", "Fair", "Good", "Strong"}
+	strengthColors = []color.NRGBA{
+		colorDanger,
+		colorWarning,
+		colorPrimary,
+		colorAccent,
+	}
+)
+
+// drawValidatedField draws a form field with the problem keeping it from
+// being accepted below it
+func drawValidatedField(gtx layout.Context, th *material.Theme, label string, editor *widget.Editor, problem string) layout.Dimensions {
+	return layout.Flex{Axis: layout.Vertical}.Layout(gtx,
+		layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+			return drawFormField(gtx, th, label, editor)
+		}),
+		layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+			if problem == "" {
+				return layout.Dimensions{}
+			}
+			return layout.Inset{Left: unit.Dp(150), Top: unit.Dp(4)}.Layout(gtx, func(gtx layout.Context) layout.Dimensions {
+				lbl := material.Body2(th, problem)
+				lbl.Color = colorDanger
+				return lbl.Layout(gtx)
+			})
+		}),
+	)
+}
+
+// drawStrengthMeter draws the strength of password as a bar of segments
+// and its label, under the password field
+func drawStrengthMeter(gtx layout.Context, th *material.Theme, password, username string) layout.Dimensions {
+	if password == "" {
+		return layout.Dimensions{}
+	}
+	strength := passwordStrength(password, username)
+
+	return layout.Inset{Left: unit.Dp(150), Top: unit.Dp(6)}.Layout(gtx, func(gtx layout.Context) layout.Dimensions {
+		return layout.Flex{Axis: layout.Horizontal, Alignment: layout.Middle}.Layout(gtx,
+			layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+				segment := image.Pt(gtx.Dp(unit.Dp(40)), gtx.Dp(unit.Dp(6)))
+				gap := gtx.Dp(unit.Dp(4))
+				for i := range strengthLabels {
+					c := colorSurface
+					if i <= strength {
+						c = strengthColors[strength]
+					}
+					x := i * (segment.X + gap)
+					paint.FillShape(gtx.Ops, c, clip.Rect{Min: image.Pt(x, 0), Max: image.Pt(x+segment.X, segment.Y)}.Op())
+				}
+				return layout.Dimensions{Size: image.Pt(len(strengthLabels)*(segment.X+gap), segment.Y)}
+			}),
+			layout.Rigid(layout.Spacer{Width: unit.Dp(8)}.Layout),
+			layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+				lbl := material.Body2(th, strengthLabels[strength])
+				lbl.Color = strengthColors[strength]
+				return lbl.Layout(gtx)
+			}),
+		)
+	})
+}
+
+// canAdvance reports whether the current step is complete enough for
+// Next; the Requirements and Config steps are checked
+func canAdvance(state *InstallerState) bool {
+	switch state.currentStep {
+	case StepRequirements:
+		return !preflightBlocked(state.preflight)
+	case StepConfiguration:
+		return validateConfig(state).ok()
+	}
+	return true
+}
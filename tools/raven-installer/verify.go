@@ -0,0 +1,400 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// installTarget is where the new system is mounted during installation
+const installTarget = "/mnt/raven"
+
+// checkMounted fails unless target is a mountpoint, so nothing meant for
+// the new system is written into the live one's directory underneath
+func checkMounted(target string) error {
+	if !isMountpoint(target) {
+		return fmt.Errorf("%s is not mounted", target)
+	}
+	return nil
+}
+
+// isMountpoint reports whether path is listed in /proc/self/mountinfo
+func isMountpoint(path string) bool {
+	file, err := os.Open("/proc/self/mountinfo")
+	if err != nil {
+		return false
+	}
+	defer file.Close()
+
+	path = filepath.Clean(path)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		// "36 35 98:0 /mnt1 /mnt/raven rw,noatime master:1 - ext4 /dev/sda2 rw"
+		fields := strings.Fields(scanner.Text())
+		if len(fields) > 4 && unescapeMountinfo(fields[4]) == path {
+			return true
+		}
+	}
+	return false
+}
+
+// unescapeMountinfo decodes the octal escapes, such as \040 for a space,
+// that the kernel uses in mountinfo paths
+func unescapeMountinfo(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+3 < len(s) {
+			if n, err := strconv.ParseUint(s[i+1:i+4], 8, 8); err == nil {
+				b.WriteByte(byte(n))
+				i += 3
+				continue
+			}
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+// VerifyCheck is one line of the post-install checklist
+type VerifyCheck struct {
+	Name   string
+	Passed bool
+	Detail string
+}
+
+// verifyInstallation inspects the installed system before the user is
+// offered a reboot, so a broken install is caught here instead of at boot
+func verifyInstallation(target, bootloader, username, password string) []VerifyCheck {
+	return []VerifyCheck{
+		checkBootloader(target, bootloader),
+		checkFstab(target),
+		checkUserLogin(target, username, password),
+		checkNetworkConfig(target),
+	}
+}
+
+// checkBootloader verifies the bootloader is installed and its menu
+// entries point at kernels that exist
+func checkBootloader(target, bootloader string) VerifyCheck {
+	check := VerifyCheck{Name: "Bootloader"}
+
+	switch bootloader {
+	case bootSystemd:
+		return checkSystemdBoot(target)
+	case bootGRUBPC:
+		if _, err := os.Stat(filepath.Join(target, "boot/grub/i386-pc/core.img")); err != nil {
+			check.Detail = "GRUB is not installed for BIOS (boot/grub/i386-pc/core.img missing)"
+			return check
+		}
+	default:
+		efiBinary := filepath.Join(target, "boot/efi/EFI/RavenLinux/grubx64.efi")
+		if _, err := os.Stat(efiBinary); err != nil {
+			check.Detail = "GRUB EFI binary missing from the EFI partition"
+			return check
+		}
+	}
+
+	data, err := os.ReadFile(filepath.Join(target, "boot/grub/grub.cfg"))
+	if err != nil {
+		check.Detail = "boot/grub/grub.cfg not found"
+		return check
+	}
+
+	entries := 0
+	for _, line := range strings.Split(string(data), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		switch fields[0] {
+		case "menuentry":
+			entries++
+		case "linux":
+			if len(fields) > 1 {
+				if _, err := os.Stat(filepath.Join(target, fields[1])); err != nil {
+					check.Detail = fmt.Sprintf("kernel %s referenced by grub.cfg is missing", fields[1])
+					return check
+				}
+			}
+		}
+	}
+	if entries == 0 {
+		check.Detail = "grub.cfg has no boot entries"
+		return check
+	}
+
+	check.Passed = true
+	check.Detail = fmt.Sprintf("%d boot %s", entries, plural(entries, "entry", "entries"))
+	return check
+}
+
+// checkSystemdBoot verifies systemd-boot is installed in the EFI partition,
+// mounted at /boot, and its loader entries point at kernels that exist
+func checkSystemdBoot(target string) VerifyCheck {
+	check := VerifyCheck{Name: "Bootloader"}
+
+	boot := filepath.Join(target, "boot")
+	if _, err := os.Stat(filepath.Join(boot, "EFI/systemd/systemd-bootx64.efi")); err != nil {
+		check.Detail = "systemd-boot EFI binary missing from the EFI partition"
+		return check
+	}
+
+	entries, _ := filepath.Glob(filepath.Join(boot, "loader/entries/*.conf"))
+	for _, entry := range entries {
+		data, err := os.ReadFile(entry)
+		if err != nil {
+			continue
+		}
+		for _, line := range strings.Split(string(data), "\n") {
+			fields := strings.Fields(line)
+			if len(fields) > 1 && fields[0] == "linux" {
+				if _, err := os.Stat(filepath.Join(boot, fields[1])); err != nil {
+					check.Detail = fmt.Sprintf("kernel %s referenced by %s is missing", fields[1], filepath.Base(entry))
+					return check
+				}
+			}
+		}
+	}
+	if len(entries) == 0 {
+		check.Detail = "loader/entries has no boot entries"
+		return check
+	}
+
+	check.Passed = true
+	check.Detail = fmt.Sprintf("%d boot %s", len(entries), plural(len(entries), "entry", "entries"))
+	return check
+}
+
+// checkFstab verifies every fstab source device exists and every mount
+// point is present in the installed tree
+func checkFstab(target string) VerifyCheck {
+	check := VerifyCheck{Name: "File systems"}
+
+	f, err := os.Open(filepath.Join(target, "etc/fstab"))
+	if err != nil {
+		check.Detail = "etc/fstab not found"
+		return check
+	}
+	defer f.Close()
+
+	mounts := 0
+	hasRoot := false
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) < 3 {
+			check.Detail = fmt.Sprintf("malformed line: %s", line)
+			return check
+		}
+		source, mountPoint, fsType := fields[0], fields[1], fields[2]
+
+		if device := resolveFstabSource(source); device != "" {
+			if _, err := os.Stat(device); err != nil {
+				check.Detail = fmt.Sprintf("%s for %s does not exist", source, mountPoint)
+				return check
+			}
+		}
+		if fsType != "swap" && mountPoint != "none" {
+			if info, err := os.Stat(filepath.Join(target, mountPoint)); err != nil || !info.IsDir() {
+				check.Detail = fmt.Sprintf("mount point %s is missing", mountPoint)
+				return check
+			}
+		}
+		if mountPoint == "/" {
+			hasRoot = true
+		}
+		mounts++
+	}
+	if !hasRoot {
+		check.Detail = "no entry for the root file system"
+		return check
+	}
+
+	check.Passed = true
+	check.Detail = fmt.Sprintf("%d %s resolve", mounts, plural(mounts, "mount", "mounts"))
+	return check
+}
+
+// resolveFstabSource maps an fstab source to the device node it names.
+// Pseudo file systems such as tmpfs have no device and give "".
+func resolveFstabSource(source string) string {
+	for prefix, dir := range map[string]string{
+		"UUID=":     "/dev/disk/by-uuid",
+		"PARTUUID=": "/dev/disk/by-partuuid",
+		"LABEL=":    "/dev/disk/by-label",
+	} {
+		if value, ok := strings.CutPrefix(source, prefix); ok {
+			return filepath.Join(dir, strings.Trim(value, `"`))
+		}
+	}
+	if strings.HasPrefix(source, "/dev/") {
+		return source
+	}
+	return ""
+}
+
+// checkUserLogin verifies the user account exists and that the password
+// entered in the installer matches the stored hash
+func checkUserLogin(target, username, password string) VerifyCheck {
+	check := VerifyCheck{Name: "User login"}
+
+	home, shell, ok := lookupPasswd(filepath.Join(target, "etc/passwd"), username)
+	if !ok {
+		check.Detail = fmt.Sprintf("user %s not in /etc/passwd", username)
+		return check
+	}
+	if _, err := os.Stat(filepath.Join(target, shell)); err != nil {
+		check.Detail = fmt.Sprintf("login shell %s is missing", shell)
+		return check
+	}
+	if _, err := os.Stat(filepath.Join(target, home)); err != nil {
+		check.Detail = fmt.Sprintf("home directory %s is missing", home)
+		return check
+	}
+
+	hash, ok := lookupShadow(filepath.Join(target, "etc/shadow"), username)
+	if !ok {
+		check.Detail = fmt.Sprintf("user %s not in /etc/shadow", username)
+		return check
+	}
+	if hash == "" || strings.HasPrefix(hash, "!") || strings.HasPrefix(hash, "*") {
+		check.Detail = "account is locked or has no password"
+		return check
+	}
+
+	matched, verified := checkPasswordHash(hash, password)
+	if verified && !matched {
+		check.Detail = "stored password does not match the one entered"
+		return check
+	}
+
+	check.Passed = true
+	if verified {
+		check.Detail = "password verified"
+	} else {
+		check.Detail = "password set (hash type not checked)"
+	}
+	return check
+}
+
+// lookupPasswd returns the home directory and shell of a user
+func lookupPasswd(path, username string) (home, shell string, ok bool) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", "", false
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		fields := strings.Split(line, ":")
+		if len(fields) >= 7 && fields[0] == username {
+			return fields[5], fields[6], true
+		}
+	}
+	return "", "", false
+}
+
+// lookupShadow returns the password hash of a user
+func lookupShadow(path, username string) (string, bool) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", false
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		fields := strings.Split(line, ":")
+		if len(fields) >= 2 && fields[0] == username {
+			return fields[1], true
+		}
+	}
+	return "", false
+}
+
+// checkPasswordHash re-hashes password with the stored salt using openssl.
+// verified is false for hash types openssl can't produce (e.g. yescrypt).
+func checkPasswordHash(hash, password string) (matched, verified bool) {
+	parts := strings.Split(hash, "$")
+	if len(parts) != 4 {
+		return false, false
+	}
+	var algorithm string
+	switch parts[1] {
+	case "1":
+		algorithm = "-1"
+	case "5":
+		algorithm = "-5"
+	case "6":
+		algorithm = "-6"
+	default:
+		return false, false
+	}
+	// openssl can't take a custom SHA-crypt rounds= parameter
+	if strings.HasPrefix(parts[2], "rounds=") {
+		return false, false
+	}
+	salt := parts[2]
+
+	cmd := exec.Command("openssl", "passwd", algorithm, "-salt", salt, "-stdin")
+	cmd.Stdin = strings.NewReader(password + "\n")
+	output, err := cmd.Output()
+	if err != nil {
+		return false, false
+	}
+	return strings.TrimSpace(string(output)) == hash, true
+}
+
+var hostnamePattern = regexp.MustCompile(`^[a-zA-Z0-9]([a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?$`)
+
+// checkNetworkConfig verifies the hostname, name resolution files and
+// that a network service is installed
+func checkNetworkConfig(target string) VerifyCheck {
+	check := VerifyCheck{Name: "Network configuration"}
+
+	data, err := os.ReadFile(filepath.Join(target, "etc/hostname"))
+	if err != nil {
+		check.Detail = "etc/hostname not found"
+		return check
+	}
+	hostname := strings.TrimSpace(string(data))
+	if !hostnamePattern.MatchString(hostname) {
+		check.Detail = fmt.Sprintf("invalid hostname %q", hostname)
+		return check
+	}
+
+	hosts, err := os.ReadFile(filepath.Join(target, "etc/hosts"))
+	if err != nil || !strings.Contains(string(hosts), "localhost") {
+		check.Detail = "etc/hosts has no localhost entry"
+		return check
+	}
+	if _, err := os.Lstat(filepath.Join(target, "etc/resolv.conf")); err != nil {
+		check.Detail = "etc/resolv.conf not found"
+		return check
+	}
+
+	for _, service := range []string{
+		"usr/bin/NetworkManager",
+		"usr/libexec/iwd",
+		"bin/raven-dhcp",
+	} {
+		if _, err := os.Stat(filepath.Join(target, service)); err == nil {
+			check.Passed = true
+			check.Detail = fmt.Sprintf("hostname %s, %s installed", hostname, filepath.Base(service))
+			return check
+		}
+	}
+	check.Detail = "no network service installed"
+	return check
+}
+
+func plural(n int, singular, plural string) string {
+	if n == 1 {
+		return singular
+	}
+	return plural
+}
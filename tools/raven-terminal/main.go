@@ -2,12 +2,14 @@ package main
 
 import (
 	"log"
+	"os"
 	"strings"
 	"time"
 
 	"raven-terminal/commands"
 	"raven-terminal/keybindings"
 	"raven-terminal/render"
+	"raven-terminal/shell"
 	"raven-terminal/tab"
 	"raven-terminal/window"
 
@@ -45,7 +47,36 @@ func (lb *lineBuffer) getLine() string {
 	return lb.buffer.String()
 }
 
+// startupCommand returns the command given with -e, if any
+func startupCommand(args []string) string {
+	for i, arg := range args {
+		if arg == "-e" {
+			return strings.Join(args[i+1:], " ")
+		}
+	}
+	return ""
+}
+
+// workingDirectory returns the folder given with --working-directory, where
+// shells start instead of $HOME. Arguments after -e belong to its command.
+func workingDirectory(args []string) string {
+	for i, arg := range args {
+		if arg == "-e" {
+			break
+		}
+		if dir, ok := strings.CutPrefix(arg, "--working-directory="); ok {
+			return dir
+		}
+		if arg == "--working-directory" && i+1 < len(args) {
+			return args[i+1]
+		}
+	}
+	return ""
+}
+
 func main() {
+	shell.StartDir = workingDirectory(os.Args[1:])
+
 	// Create window
 	config := window.DefaultConfig()
 	win, err := window.NewWindow(config)
@@ -71,6 +102,12 @@ func main() {
 		log.Fatalf("Failed to create tab manager: %v", err)
 	}
 
+	// -e runs a command in the first tab; the shell stays open afterwards
+	// so its output can be read
+	if command := startupCommand(os.Args[1:]); command != "" {
+		tabManager.ActiveTab().Write([]byte(command + "\r"))
+	}
+
 	// Set up input callbacks
 	var currentMods glfw.ModifierKey
 	cursorVisible := true
@@ -28,6 +28,9 @@ func getDistroName() string {
 	return "linux"
 }
 
+// StartDir is where new shells start; $HOME when empty or missing
+var StartDir string
+
 // PtySession manages a pseudo-terminal connection to a shell
 type PtySession struct {
 	cmd      *exec.Cmd
@@ -87,8 +90,11 @@ func NewPtySession(cols, rows uint16) (*PtySession, error) {
 		"WAYLAND_DISPLAY=" + os.Getenv("WAYLAND_DISPLAY"),
 	}
 
-	// Start in home directory
+	// Start in the requested directory, else home
 	cmd.Dir = currentUser.HomeDir
+	if info, err := os.Stat(StartDir); StartDir != "" && err == nil && info.IsDir() {
+		cmd.Dir = StartDir
+	}
 
 	ptmx, err := pty.StartWithSize(cmd, &pty.Winsize{
 		Cols: cols,
@@ -0,0 +1,471 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"image/color"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gioui.org/app"
+	"gioui.org/font"
+	"gioui.org/layout"
+	"gioui.org/op"
+	"gioui.org/op/clip"
+	"gioui.org/op/paint"
+	"gioui.org/unit"
+	"gioui.org/widget"
+	"gioui.org/widget/material"
+)
+
+// defaultManifestURL lists the ISOs offered for download. The release
+// workflow publishes it next to each ISO; RAVEN_USB_MANIFEST points at
+// another, such as one that also lists other distributions.
+const defaultManifestURL = "https://github.com/javanhut/RavenLinux/releases/latest/download/isos.json"
+
+// How long fetching the manifest may take
+const manifestTimeout = 15 * time.Second
+
+// Download states
+const (
+	DownloadRunning = iota
+	DownloadDone
+	DownloadFailed
+	DownloadCanceled
+)
+
+// CatalogImage is an ISO listed in the manifest
+type CatalogImage struct {
+	Name        string `json:"name"`
+	Version     string `json:"version"`
+	Description string `json:"description"`
+	URL         string `json:"url"`
+	SHA256      string `json:"sha256"`
+	Size        uint64 `json:"size"`
+}
+
+// catalogManifest is the JSON document at the manifest URL
+type catalogManifest struct {
+	Images []CatalogImage `json:"images"`
+}
+
+// ISODownload is an ISO being downloaded from the catalog. Guarded by
+// AppState.mu.
+type ISODownload struct {
+	Image   CatalogImage
+	Path    string
+	Status  int
+	Done    int64
+	Total   int64
+	Resumed int64 // Bytes already on disk from an earlier attempt
+	Started time.Time
+	Error   string
+	cancel  context.CancelFunc
+}
+
+// manifestURL returns the manifest to list ISOs from
+func manifestURL() string {
+	if url := os.Getenv("RAVEN_USB_MANIFEST"); url != "" {
+		return url
+	}
+	return defaultManifestURL
+}
+
+// downloadDir keeps downloaded ISOs, and the partial downloads resumed
+// from there
+func downloadDir() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		dir = os.TempDir()
+	}
+	return filepath.Join(dir, "raven-usb")
+}
+
+// loadCatalog fetches the manifest in the background. Called with
+// state.mu held.
+func loadCatalog(state *AppState, w *app.Window) {
+	state.catalogLoading = true
+	state.catalogErr = ""
+	go func() {
+		images, err := fetchManifest(manifestURL())
+
+		state.mu.Lock()
+		state.catalogLoading = false
+		state.catalog = images
+		state.catalogClicks = make([]widget.Clickable, len(images))
+		state.selectedImage = -1
+		if err != nil {
+			state.catalogErr = err.Error()
+		}
+		state.mu.Unlock()
+		w.Invalidate()
+	}()
+}
+
+func fetchManifest(url string) ([]CatalogImage, error) {
+	client := &http.Client{Timeout: manifestTimeout}
+	resp, err := client.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s: HTTP %d", url, resp.StatusCode)
+	}
+
+	var manifest catalogManifest
+	if err := json.NewDecoder(resp.Body).Decode(&manifest); err != nil {
+		return nil, fmt.Errorf("invalid manifest: %v", err)
+	}
+	var images []CatalogImage
+	for _, image := range manifest.Images {
+		if image.URL != "" {
+			images = append(images, image)
+		}
+	}
+	return images, nil
+}
+
+// startDownload downloads the selected image in the background and, once
+// its checksum matches, picks it as the ISO to write. Called with state.mu
+// held.
+func startDownload(state *AppState, w *app.Window) {
+	if state.selectedImage < 0 || state.selectedImage >= len(state.catalog) {
+		return
+	}
+	image := state.catalog[state.selectedImage]
+	ctx, cancel := context.WithCancel(context.Background())
+	dl := &ISODownload{
+		Image:   image,
+		Path:    filepath.Join(downloadDir(), path.Base(image.URL)),
+		Total:   int64(image.Size),
+		Started: time.Now(),
+		cancel:  cancel,
+	}
+	state.download = dl
+
+	go func() {
+		err := downloadISO(ctx, dl, func(done, total int64) {
+			state.mu.Lock()
+			dl.Done, dl.Total = done, total
+			state.mu.Unlock()
+			w.Invalidate()
+		})
+
+		state.mu.Lock()
+		switch {
+		case ctx.Err() != nil:
+			dl.Status = DownloadCanceled
+		case err != nil:
+			dl.Status, dl.Error = DownloadFailed, err.Error()
+		default:
+			dl.Status = DownloadDone
+			if info, err := os.Stat(dl.Path); err == nil {
+				state.isoPath = dl.Path
+				state.isoSize = uint64(info.Size())
+				state.verify = &ISOVerification{
+					ISOPath:  dl.Path,
+					Status:   VerifyPassed,
+					SHA256:   strings.ToLower(dl.Image.SHA256),
+					Expected: strings.ToLower(dl.Image.SHA256),
+					Source:   manifestURL(),
+				}
+				if dl.Image.SHA256 == "" {
+					state.verify.Status = VerifyNone
+				}
+				state.checksumEdit.SetText(state.verify.Expected)
+				state.catalogOpen = false
+				checkWindowsISO(state, w)
+			}
+		}
+		state.mu.Unlock()
+		w.Invalidate()
+	}()
+}
+
+// downloadISO downloads dl.Image to dl.Path, resuming a partial download
+// left by an earlier attempt, and checks its SHA256 against the manifest
+func downloadISO(ctx context.Context, dl *ISODownload, progress func(done, total int64)) error {
+	if err := os.MkdirAll(filepath.Dir(dl.Path), 0755); err != nil {
+		return err
+	}
+	want := strings.ToLower(dl.Image.SHA256)
+
+	// Already downloaded
+	if want != "" {
+		if sum, err := hashFile(dl.Path, func(float64) {}); err == nil && sum == want {
+			if info, err := os.Stat(dl.Path); err == nil {
+				progress(info.Size(), info.Size())
+			}
+			return nil
+		}
+	}
+
+	partial := dl.Path + ".part"
+	file, err := os.OpenFile(partial, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	// The hash covers what an earlier attempt already downloaded
+	hash := sha256.New()
+	offset, err := io.Copy(hash, file)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, dl.Image.URL, nil)
+	if err != nil {
+		return err
+	}
+	if offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+		dl.Resumed = offset
+	case http.StatusOK:
+		// The server ignored the range, so start over
+		if err := file.Truncate(0); err != nil {
+			return err
+		}
+		if _, err := file.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+		hash.Reset()
+		offset = 0
+	case http.StatusRequestedRangeNotSatisfiable:
+		// The partial download is already complete
+	default:
+		return fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+
+	total := offset + max(resp.ContentLength, 0)
+	if resp.StatusCode == http.StatusRequestedRangeNotSatisfiable || resp.ContentLength < 0 {
+		total = int64(dl.Image.Size)
+	}
+	done := offset
+	buffer := make([]byte, 256*1024)
+	lastUpdate := time.Now()
+	for resp.StatusCode != http.StatusRequestedRangeNotSatisfiable {
+		n, err := resp.Body.Read(buffer)
+		if n > 0 {
+			if _, werr := file.Write(buffer[:n]); werr != nil {
+				return werr
+			}
+			hash.Write(buffer[:n])
+			done += int64(n)
+			if time.Since(lastUpdate) > 250*time.Millisecond {
+				lastUpdate = time.Now()
+				progress(done, total)
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+	}
+	progress(done, max(total, done))
+
+	if want != "" && hex.EncodeToString(hash.Sum(nil)) != want {
+		file.Close()
+		os.Remove(partial)
+		return fmt.Errorf("SHA256 does not match the manifest; the download was removed")
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	return os.Rename(partial, dl.Path)
+}
+
+// drawPageDownload lists the ISOs in the manifest, with the progress of
+// the one being downloaded
+func drawPageDownload(gtx layout.Context, th *material.Theme, state *AppState) layout.Dimensions {
+	dl := state.download
+	downloading := dl != nil && dl.Status == DownloadRunning
+
+	return layout.Flex{Axis: layout.Vertical}.Layout(gtx,
+		layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+			title := material.H6(th, "Download ISO")
+			title.Color = colorTextBright
+			return title.Layout(gtx)
+		}),
+		layout.Rigid(layout.Spacer{Height: unit.Dp(5)}.Layout),
+		layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+			subtitle := material.Caption(th, "From "+manifestURL())
+			subtitle.Color = colorText
+			return subtitle.Layout(gtx)
+		}),
+		layout.Rigid(layout.Spacer{Height: unit.Dp(15)}.Layout),
+		layout.Flexed(1, func(gtx layout.Context) layout.Dimensions {
+			return drawCatalogList(gtx, th, state)
+		}),
+		layout.Rigid(layout.Spacer{Height: unit.Dp(10)}.Layout),
+		layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+			if dl == nil {
+				return layout.Dimensions{}
+			}
+			return layout.Inset{Bottom: unit.Dp(10)}.Layout(gtx, func(gtx layout.Context) layout.Dimensions {
+				return drawDownloadProgress(gtx, th, dl)
+			})
+		}),
+		layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+			return layout.Flex{Axis: layout.Horizontal}.Layout(gtx,
+				layout.Flexed(1, func(gtx layout.Context) layout.Dimensions {
+					return layout.Dimensions{}
+				}),
+				layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+					if downloading {
+						btn := material.Button(th, &state.cancelDownload, "Cancel")
+						btn.Background = colorSurface
+						btn.Color = colorText
+						return btn.Layout(gtx)
+					}
+					btn := material.Button(th, &state.downloadBtn, "Download")
+					btn.Background = colorPrimary
+					if state.selectedImage < 0 {
+						btn.Background = colorDisabled
+					}
+					return btn.Layout(gtx)
+				}),
+			)
+		}),
+	)
+}
+
+// drawCatalogList draws the ISOs in the manifest, like the USB list
+func drawCatalogList(gtx layout.Context, th *material.Theme, state *AppState) layout.Dimensions {
+	return widget.Border{
+		Color: colorSurface,
+		Width: unit.Dp(2),
+	}.Layout(gtx, func(gtx layout.Context) layout.Dimensions {
+		paint.FillShape(gtx.Ops, colorSurface, clip.Rect{Max: gtx.Constraints.Max}.Op())
+
+		if len(state.catalog) == 0 {
+			msg := "Loading the list of ISOs..."
+			msgColor := colorDisabled
+			if !state.catalogLoading {
+				msg = "No ISOs listed"
+				if state.catalogErr != "" {
+					msg, msgColor = "Could not load the list: "+state.catalogErr, colorDanger
+				}
+			}
+			return layout.Center.Layout(gtx, func(gtx layout.Context) layout.Dimensions {
+				lbl := material.Body1(th, msg)
+				lbl.Color = msgColor
+				return layout.UniformInset(unit.Dp(15)).Layout(gtx, lbl.Layout)
+			})
+		}
+
+		return material.List(th, &state.catalogScroll).Layout(gtx, len(state.catalog), func(gtx layout.Context, i int) layout.Dimensions {
+			image := state.catalog[i]
+			bg, borderColor, textColor := colorSurface, colorSurface, colorText
+			if i == state.selectedImage {
+				bg = color.NRGBA{R: 0, G: 80, B: 150, A: 255}
+				borderColor = colorPrimary
+				textColor = colorTextBright
+			}
+
+			return state.catalogClicks[i].Layout(gtx, func(gtx layout.Context) layout.Dimensions {
+				return widget.Border{
+					Color: borderColor,
+					Width: unit.Dp(1),
+				}.Layout(gtx, func(gtx layout.Context) layout.Dimensions {
+					macro := op.Record(gtx.Ops)
+					dims := layout.UniformInset(unit.Dp(12)).Layout(gtx, func(gtx layout.Context) layout.Dimensions {
+						return layout.Flex{Axis: layout.Vertical}.Layout(gtx,
+							layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+								name := material.Body1(th, strings.TrimSpace(image.Name+" "+image.Version))
+								name.Color = textColor
+								name.Font.Weight = font.Bold
+								return name.Layout(gtx)
+							}),
+							layout.Rigid(layout.Spacer{Height: unit.Dp(4)}.Layout),
+							layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+								details := path.Base(image.URL)
+								if image.Size > 0 {
+									details += "  -  " + formatSize(image.Size)
+								}
+								if image.Description != "" {
+									details = image.Description + "\n" + details
+								}
+								info := material.Caption(th, details)
+								info.Color = colorText
+								return info.Layout(gtx)
+							}),
+						)
+					})
+					call := macro.Stop()
+
+					defer clip.Rect{Max: dims.Size}.Push(gtx.Ops).Pop()
+					paint.FillShape(gtx.Ops, bg, clip.Rect{Max: dims.Size}.Op())
+					call.Add(gtx.Ops)
+					return dims
+				})
+			})
+		})
+	})
+}
+
+// drawDownloadProgress shows how much of the ISO is downloaded, and how
+// the download ended
+func drawDownloadProgress(gtx layout.Context, th *material.Theme, dl *ISODownload) layout.Dimensions {
+	var fraction float64
+	if dl.Total > 0 {
+		fraction = float64(dl.Done) / float64(dl.Total)
+	}
+
+	status, statusColor := "", colorText
+	switch dl.Status {
+	case DownloadRunning:
+		status = fmt.Sprintf("Downloading %s: %s / %s", path.Base(dl.Image.URL), formatSize(uint64(dl.Done)), formatSize(uint64(dl.Total)))
+		if elapsed := time.Since(dl.Started).Seconds(); elapsed > 0 && dl.Done > dl.Resumed {
+			status += fmt.Sprintf("  -  %.1f MB/s", float64(dl.Done-dl.Resumed)/elapsed/(1024*1024))
+		}
+		if dl.Resumed > 0 {
+			status += "  -  Resumed"
+		}
+	case DownloadDone:
+		status, statusColor = "Downloaded and verified "+path.Base(dl.Image.URL), colorSuccess
+		if dl.Image.SHA256 == "" {
+			status, statusColor = "Downloaded "+path.Base(dl.Image.URL)+" (no checksum in the manifest)", colorWarning
+		}
+	case DownloadFailed:
+		status, statusColor = "Download failed: "+dl.Error, colorDanger
+	case DownloadCanceled:
+		status, statusColor = "Download canceled; it resumes from where it stopped", colorWarning
+	}
+
+	return layout.Flex{Axis: layout.Vertical}.Layout(gtx,
+		layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+			if dl.Status != DownloadRunning {
+				return layout.Dimensions{}
+			}
+			return layout.Inset{Bottom: unit.Dp(6)}.Layout(gtx, func(gtx layout.Context) layout.Dimensions {
+				return drawProgressBar(gtx, fraction)
+			})
+		}),
+		layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+			lbl := material.Body2(th, status)
+			lbl.Color = statusColor
+			return lbl.Layout(gtx)
+		}),
+	)
+}
@@ -0,0 +1,293 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Exit codes of the command line mode
+const (
+	exitOK       = 0
+	exitFailed   = 1   // The write failed
+	exitUsage    = 2   // Bad flags, or a device or ISO that can't be used
+	exitVerify   = 3   // The ISO failed verification
+	exitCanceled = 130 // Interrupted, or declined at the prompt
+)
+
+// How often progress is printed when stdout isn't a terminal
+const cliLogInterval = 5 * time.Second
+
+// runCLI writes an ISO without the GUI, for scripts and SSH sessions, and
+// returns the exit code
+func runCLI(args []string) int {
+	fs := flag.NewFlagSet("raven-usb", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: raven-usb [ISO]")
+		fmt.Fprintln(fs.Output(), "       raven-usb --write ISO --device DEVICE [options]")
+		fmt.Fprintln(fs.Output(), "       raven-usb --check ISO [--sha256 SUM]")
+		fmt.Fprintln(fs.Output(), "       raven-usb --list")
+		fmt.Fprintln(fs.Output())
+		fs.PrintDefaults()
+	}
+	list := fs.Bool("list", false, "list USB devices and exit")
+	check := fs.String("check", "", "verify an ISO's SHA256 and signature and exit, without writing")
+	isoPath := fs.String("write", "", "ISO to write")
+	devPath := fs.String("device", "", "USB device to write to, such as /dev/sdb")
+	yes := fs.Bool("yes", false, "erase the device without asking")
+	verify := fs.Bool("verify", false, "check the ISO's SHA256 and signature before writing")
+	sum := fs.String("sha256", "", "expected SHA256, instead of a checksum file next to the ISO (implies --verify)")
+	format := fs.Bool("format", false, "format the USB before writing")
+	persist := fs.Uint64("persist", 0, "add a persistence partition of this many MiB after the ISO")
+	limit := fs.Int("limit", 0, "limit the write speed to this many MB/s")
+	noWipe := fs.Bool("no-wipe", false, "leave the partition table alone when interrupted")
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return exitOK
+		}
+		return exitUsage
+	}
+
+	if *list {
+		for _, dev := range detectUSBDevices() {
+			fmt.Printf("%s\t%s\t%s %s\n", dev.Path, formatSize(dev.Size), dev.Vendor, dev.Model)
+		}
+		return exitOK
+	}
+
+	// Verifying only reads the ISO, so it needs no root
+	if *check != "" {
+		if _, err := os.Stat(*check); err != nil {
+			fmt.Fprintln(os.Stderr, "raven-usb: cannot read ISO:", err)
+			return exitUsage
+		}
+		return cliVerify(*check, *sum)
+	}
+
+	if *isoPath == "" || *devPath == "" {
+		fmt.Fprintln(os.Stderr, "raven-usb: --write and --device are required")
+		fs.Usage()
+		return exitUsage
+	}
+	if os.Geteuid() != 0 {
+		fmt.Fprintln(os.Stderr, "raven-usb: root access is required; run with sudo")
+		return exitUsage
+	}
+
+	info, err := os.Stat(*isoPath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "raven-usb: cannot read ISO:", err)
+		return exitUsage
+	}
+	isoSize := uint64(info.Size())
+
+	// Only USB drives can be written, never the computer's own disks
+	var dev USBDevice
+	for _, d := range detectUSBDevices() {
+		if d.Path == *devPath {
+			dev = d
+		}
+	}
+	if dev.Path == "" {
+		fmt.Fprintf(os.Stderr, "raven-usb: %s is not a USB drive; see raven-usb --list\n", *devPath)
+		return exitUsage
+	}
+	if dev.Size < isoSize {
+		fmt.Fprintf(os.Stderr, "raven-usb: USB too small: need %s, have %s\n", formatSize(isoSize), formatSize(dev.Size))
+		return exitUsage
+	}
+
+	windows := isWindowsISO(*isoPath)
+	var persistSize uint64
+	if *persist > 0 {
+		if windows {
+			fmt.Fprintln(os.Stderr, "raven-usb: Windows ISOs can't have persistence")
+			return exitUsage
+		}
+		persistSize = *persist * 1024 * 1024
+		lo, hi, ok := persistRange(dev, isoSize)
+		if !ok {
+			fmt.Fprintf(os.Stderr, "raven-usb: no room for persistence: it needs %s free after the ISO\n", formatSize(minPersistSize))
+			return exitUsage
+		}
+		if persistSize < lo || persistSize > hi {
+			fmt.Fprintf(os.Stderr, "raven-usb: persistence must be between %d and %d MiB on this USB\n", lo/partAlign, hi/partAlign)
+			return exitUsage
+		}
+	}
+
+	if *verify || *sum != "" {
+		if code := cliVerify(*isoPath, *sum); code != exitOK {
+			return code
+		}
+	}
+
+	if !*yes {
+		if !isTerminal(os.Stdin) {
+			fmt.Fprintln(os.Stderr, "raven-usb: not erasing", dev.Path, "without --yes when not run from a terminal")
+			return exitUsage
+		}
+		fmt.Printf("All data on %s (%s %s, %s) will be permanently erased!\n", dev.Path, dev.Vendor, dev.Model, formatSize(dev.Size))
+		fmt.Print(`Type "yes" to continue: `)
+		answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+		if strings.TrimSpace(answer) != "yes" {
+			fmt.Fprintln(os.Stderr, "raven-usb: aborted")
+			return exitCanceled
+		}
+	}
+
+	job := &WriteJob{
+		Device:    dev,
+		ISOPath:   *isoPath,
+		ISOSize:   isoSize,
+		Format:    *format,
+		RateLimit: float64(*limit) * 1024 * 1024,
+		Persist:   persistSize,
+		Windows:   windows,
+	}
+	state := &AppState{jobs: []*WriteJob{job}}
+	state.wipeCheck.Value = !*noWipe
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-signals
+		fmt.Fprintln(os.Stderr, "\nCanceling...")
+		state.mu.Lock()
+		cancelJobs(state)
+		state.mu.Unlock()
+	}()
+
+	fmt.Printf("Writing %s to %s (%s)\n", filepath.Base(job.ISOPath), dev.Path, writeModeLabel(windows))
+	printer := &cliProgress{state: state, job: job, tty: isTerminal(os.Stdout)}
+	writeJob(state, job, printer)
+	printer.finish()
+
+	switch job.Status {
+	case JobDone:
+		fmt.Printf("Bootable USB created on %s\n", dev.Path)
+		return exitOK
+	case JobCanceled:
+		fmt.Fprintln(os.Stderr, "raven-usb:", job.Error)
+		return exitCanceled
+	default:
+		fmt.Fprintln(os.Stderr, "raven-usb:", job.Error)
+		return exitFailed
+	}
+}
+
+// cliVerify checks the ISO like the Verify button does, printing the
+// outcome
+func cliVerify(isoPath, sum string) int {
+	expected, source := normalizeChecksum(sum), ""
+	if expected == "" {
+		expected, source = findChecksum(isoPath)
+	}
+	if expected != "" && !validChecksum(expected) {
+		fmt.Fprintln(os.Stderr, "raven-usb: the expected checksum isn't a SHA256 (64 hex digits)")
+		return exitUsage
+	}
+
+	tty := isTerminal(os.Stdout)
+	lastPrint := time.Now()
+	got, err := hashFile(isoPath, func(p float64) {
+		if tty {
+			fmt.Printf("\rVerifying %s: %.0f%%", filepath.Base(isoPath), p*100)
+		} else if time.Since(lastPrint) > cliLogInterval {
+			lastPrint = time.Now()
+			fmt.Printf("Verifying %s: %.0f%%\n", filepath.Base(isoPath), p*100)
+		}
+	})
+	if tty {
+		fmt.Println()
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "raven-usb: cannot verify ISO:", err)
+		return exitVerify
+	}
+
+	switch {
+	case expected == "":
+		fmt.Printf("SHA256 %s (no checksum to compare against)\n", got)
+	case got == expected:
+		fmt.Println("SHA256 matches")
+	default:
+		fmt.Fprintf(os.Stderr, "raven-usb: SHA256 mismatch: expected %s, got %s\n", expected, got)
+		return exitVerify
+	}
+
+	// Prefer the ISO's own signature, then the checksum file's
+	sig, signed := findSignature(isoPath), isoPath
+	if sig == "" && source != "" {
+		sig, signed = findSignature(source), source
+	}
+	if sig == "" {
+		return exitOK
+	}
+	status, detail := checkSignature(sig, signed)
+	if status == SigBad {
+		fmt.Fprintln(os.Stderr, "raven-usb:", detail)
+		return exitVerify
+	}
+	fmt.Println(detail)
+	return exitOK
+}
+
+// cliProgress prints a job's log and progress as writeJob reports them.
+// Progress is redrawn in place on a terminal, and printed every
+// cliLogInterval otherwise.
+type cliProgress struct {
+	state     *AppState
+	job       *WriteJob
+	tty       bool
+	logged    int  // Log lines already printed
+	inline    bool // A progress line is waiting for its newline
+	lastPrint time.Time
+}
+
+func (p *cliProgress) Invalidate() {
+	p.state.mu.Lock()
+	logs := p.state.statusLog[p.logged:]
+	p.logged = len(p.state.statusLog)
+	line := strings.TrimSpace(fmt.Sprintf("%s  %s", p.job.ProgressTxt, p.job.ETAText))
+	p.state.mu.Unlock()
+
+	for _, msg := range logs {
+		p.endLine()
+		fmt.Println(msg)
+	}
+
+	switch {
+	case line == "":
+	case p.tty:
+		fmt.Printf("\r\033[K%s", line)
+		p.inline = true
+	case time.Since(p.lastPrint) > cliLogInterval:
+		p.lastPrint = time.Now()
+		fmt.Println(line)
+	}
+}
+
+// finish ends the progress line, once the job is over
+func (p *cliProgress) finish() {
+	p.Invalidate()
+	p.endLine()
+}
+
+func (p *cliProgress) endLine() {
+	if p.inline {
+		fmt.Println()
+		p.inline = false
+	}
+}
+
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
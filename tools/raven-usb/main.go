@@ -1,12 +1,12 @@
 // RavenLinux USB Creator - GUI tool to create bootable USB drives
 // Works with RavenLinux and any other Linux distribution ISO
+// Run with flags, such as --write, to use it without the GUI
 package main
 
 import (
 	"fmt"
 	"image"
 	"image/color"
-	"io"
 	"log"
 	"os"
 	"os/exec"
@@ -14,7 +14,6 @@ import (
 	"sort"
 	"strings"
 	"sync"
-	"syscall"
 	"time"
 
 	"gioui.org/app"
@@ -70,37 +69,76 @@ type AppState struct {
 	selectedUSB  int
 	isoPath      string
 	isoSize      uint64
-	progress     float64
-	progressTxt  string
+	isoWindows   bool // The ISO is a Windows installer, copied file by file
+	jobs         []*WriteJob
 	statusLog    []string
-	writeError   string
-	writeDone    bool
 	isRoot       bool
 	formatUSBOpt bool
 	writeSpeed   float64 // bytes per second for time estimation
 	startTime    time.Time
-	etaText      string
+	verify       *ISOVerification
+	abortNote    string // How each job ended when writing was canceled
+
+	// Download catalog
+	catalog        []CatalogImage
+	catalogLoading bool
+	catalogErr     string
+	catalogOpen    bool
+	selectedImage  int
+	download       *ISODownload
+
+	// Multi-boot drive manager
+	multibootOpen bool
+	multiboot     *MultibootDrive
 
 	mu sync.Mutex
 
 	// Widgets
-	refreshBtn   widget.Clickable
-	browseBtn    widget.Clickable
-	formatCheck  widget.Bool
-	nextBtn      widget.Clickable
-	backBtn      widget.Clickable
-	exitBtn      widget.Clickable
-	startOverBtn widget.Clickable
-	deviceClicks []widget.Clickable
-	confirmCheck widget.Bool
+	refreshBtn     widget.Clickable
+	browseBtn      widget.Clickable
+	formatCheck    widget.Bool
+	nextBtn        widget.Clickable
+	backBtn        widget.Clickable
+	exitBtn        widget.Clickable
+	startOverBtn   widget.Clickable
+	deviceClicks   []widget.Clickable
+	confirmCheck   widget.Bool
+	speedLimit     widget.Enum
+	queueBtn       widget.Clickable
+	writeQueue     widget.Clickable
+	clearQueue     widget.Clickable
+	parallel       widget.Bool
+	cancelWrite    widget.Clickable
+	wipeCheck      widget.Bool
+	checksumEdit   widget.Editor
+	verifyBtn      widget.Clickable
+	persistCheck   widget.Bool
+	persistSize    widget.Float
+	catalogBtn     widget.Clickable
+	catalogClicks  []widget.Clickable
+	downloadBtn    widget.Clickable
+	cancelDownload widget.Clickable
+
+	multibootBtn     widget.Clickable
+	multibootConfirm widget.Bool
+	prepareBtn       widget.Clickable
+	addISOBtn        widget.Clickable
+	multibootRemove  []widget.Clickable
 
 	// Scroll states for pages
-	confirmScroll widget.List
-	writingScroll widget.List
-	completeScroll widget.List
+	isoScroll       widget.List
+	catalogScroll   widget.List
+	multibootScroll widget.List
+	confirmScroll   widget.List
+	writingScroll   widget.List
+	completeScroll  widget.List
 }
 
 func main() {
+	if len(os.Args) > 1 && strings.HasPrefix(os.Args[1], "-") {
+		os.Exit(runCLI(os.Args[1:]))
+	}
+
 	go func() {
 		w := new(app.Window)
 		w.Option(
@@ -124,13 +162,21 @@ func run(w *app.Window) error {
 	th.Palette.ContrastFg = colorBackground
 
 	state := &AppState{
-		currentPage: PageSelectUSB,
-		selectedUSB: -1,
-		isRoot:      os.Geteuid() == 0,
-		confirmScroll: widget.List{List: layout.List{Axis: layout.Vertical}},
-		writingScroll: widget.List{List: layout.List{Axis: layout.Vertical}},
-		completeScroll: widget.List{List: layout.List{Axis: layout.Vertical}},
+		currentPage:     PageSelectUSB,
+		selectedUSB:     -1,
+		selectedImage:   -1,
+		isRoot:          os.Geteuid() == 0,
+		isoScroll:       widget.List{List: layout.List{Axis: layout.Vertical}},
+		catalogScroll:   widget.List{List: layout.List{Axis: layout.Vertical}},
+		multibootScroll: widget.List{List: layout.List{Axis: layout.Vertical}},
+		confirmScroll:   widget.List{List: layout.List{Axis: layout.Vertical}},
+		writingScroll:   widget.List{List: layout.List{Axis: layout.Vertical}},
+		completeScroll:  widget.List{List: layout.List{Axis: layout.Vertical}},
 	}
+	state.speedLimit.Value = "0"
+	state.checksumEdit.SingleLine = true
+	state.persistSize.Value = 1
+	state.wipeCheck.Value = true
 
 	// Check command line for ISO path
 	if len(os.Args) > 1 {
@@ -138,6 +184,8 @@ func run(w *app.Window) error {
 		if info, err := os.Stat(path); err == nil && strings.HasSuffix(strings.ToLower(path), ".iso") {
 			state.isoPath = path
 			state.isoSize = uint64(info.Size())
+			loadChecksum(state)
+			checkWindowsISO(state, w)
 		}
 	}
 
@@ -175,7 +223,7 @@ func drawUI(gtx layout.Context, th *material.Theme, state *AppState, w *app.Wind
 
 	// Handle device selection
 	for i := range state.deviceClicks {
-		if state.deviceClicks[i].Clicked(gtx) {
+		if state.deviceClicks[i].Clicked(gtx) && !isQueued(state, state.devices[i].Path) {
 			state.selectedUSB = i
 		}
 	}
@@ -188,6 +236,8 @@ func drawUI(gtx layout.Context, th *material.Theme, state *AppState, w *app.Wind
 					state.mu.Lock()
 					state.isoPath = isoPath
 					state.isoSize = uint64(info.Size())
+					loadChecksum(state)
+					checkWindowsISO(state, w)
 					state.mu.Unlock()
 					w.Invalidate()
 				}
@@ -195,8 +245,56 @@ func drawUI(gtx layout.Context, th *material.Theme, state *AppState, w *app.Wind
 		}()
 	}
 
+	if state.verifyBtn.Clicked(gtx) && state.isoPath != "" && !verifyBlocks(state) {
+		startVerify(state, w)
+	}
+
+	// Handle the download catalog
+	if state.catalogBtn.Clicked(gtx) {
+		state.catalogOpen = true
+		if (state.catalog == nil || state.catalogErr != "") && !state.catalogLoading {
+			loadCatalog(state, w)
+		}
+	}
+	downloading := state.download != nil && state.download.Status == DownloadRunning
+	for i := range state.catalogClicks {
+		if state.catalogClicks[i].Clicked(gtx) && !downloading {
+			state.selectedImage = i
+		}
+	}
+	if state.downloadBtn.Clicked(gtx) && !downloading {
+		startDownload(state, w)
+	}
+	if state.cancelDownload.Clicked(gtx) && downloading {
+		state.download.cancel()
+	}
+
+	// Handle the multi-boot drive manager
+	if state.multibootBtn.Clicked(gtx) && state.currentPage == PageSelectUSB && state.selectedUSB >= 0 &&
+		!state.multibootOpen && !isQueued(state, state.devices[state.selectedUSB].Path) {
+		openMultiboot(state, w)
+	}
+	if mb := state.multiboot; mb != nil && mb.Task == "" {
+		if state.prepareBtn.Clicked(gtx) && mb.Data == "" && state.multibootConfirm.Value {
+			prepareDrive(state, w)
+		}
+		if state.addISOBtn.Clicked(gtx) && mb.Mount != "" {
+			addToDrive(state, w)
+		}
+		for i := range state.multibootRemove {
+			if state.multibootRemove[i].Clicked(gtx) && i < len(mb.ISOs) {
+				removeFromDrive(state, w, i)
+				break
+			}
+		}
+	}
+
 	if state.backBtn.Clicked(gtx) {
-		if state.currentPage > PageSelectUSB && state.currentPage < PageWriting {
+		if state.multibootOpen {
+			closeMultiboot(state)
+		} else if state.catalogOpen {
+			state.catalogOpen = false
+		} else if state.currentPage > PageSelectUSB && state.currentPage < PageWriting {
 			state.currentPage--
 		}
 	}
@@ -204,32 +302,54 @@ func drawUI(gtx layout.Context, th *material.Theme, state *AppState, w *app.Wind
 	if state.nextBtn.Clicked(gtx) {
 		switch state.currentPage {
 		case PageSelectUSB:
-			if state.selectedUSB >= 0 {
+			if state.selectedUSB >= 0 && !state.multibootOpen {
 				state.currentPage = PageFormat
 			}
 		case PageFormat:
 			state.formatUSBOpt = state.formatCheck.Value
 			state.currentPage = PageSelectISO
 		case PageSelectISO:
-			if state.isoPath != "" && state.selectedUSB >= 0 {
+			if state.isoPath != "" && state.selectedUSB >= 0 && !state.catalogOpen {
 				dev := state.devices[state.selectedUSB]
-				if dev.Size >= state.isoSize {
+				if dev.Size >= state.isoSize && !verifyBlocks(state) {
 					state.currentPage = PageConfirm
 					state.confirmCheck.Value = false
 				}
 			}
 		case PageConfirm:
 			if state.confirmCheck.Value {
+				state.jobs = append(state.jobs, newWriteJob(state))
 				state.currentPage = PageWriting
-				state.progress = 0
 				state.statusLog = nil
-				state.writeError = ""
-				state.etaText = ""
-				go writeToUSB(state, w)
+				state.abortNote = ""
+				go runQueue(state, w)
 			}
 		}
 	}
 
+	// Queue the write and pick the next device, keeping the ISO and options
+	if state.queueBtn.Clicked(gtx) && state.currentPage == PageConfirm && state.confirmCheck.Value {
+		state.jobs = append(state.jobs, newWriteJob(state))
+		state.currentPage = PageSelectUSB
+		state.selectedUSB = -1
+		state.confirmCheck.Value = false
+	}
+
+	if state.writeQueue.Clicked(gtx) && state.currentPage == PageSelectUSB && len(state.jobs) > 0 {
+		state.currentPage = PageWriting
+		state.statusLog = nil
+		state.abortNote = ""
+		go runQueue(state, w)
+	}
+
+	if state.clearQueue.Clicked(gtx) && state.currentPage == PageSelectUSB {
+		state.jobs = nil
+	}
+
+	if state.cancelWrite.Clicked(gtx) && state.currentPage == PageWriting {
+		cancelJobs(state)
+	}
+
 	if state.exitBtn.Clicked(gtx) {
 		os.Exit(0)
 	}
@@ -239,11 +359,16 @@ func drawUI(gtx layout.Context, th *material.Theme, state *AppState, w *app.Wind
 		state.selectedUSB = -1
 		state.isoPath = ""
 		state.isoSize = 0
-		state.progress = 0
-		state.writeError = ""
-		state.writeDone = false
+		state.isoWindows = false
+		state.verify = nil
+		state.checksumEdit.SetText("")
+		state.catalogOpen = false
+		state.jobs = nil
+		state.statusLog = nil
+		state.abortNote = ""
 		state.formatUSBOpt = false
 		state.formatCheck.Value = false
+		state.persistCheck.Value = false
 		state.confirmCheck.Value = false
 	}
 
@@ -337,10 +462,16 @@ func drawContent(gtx layout.Context, th *material.Theme, state *AppState) layout
 
 		switch state.currentPage {
 		case PageSelectUSB:
+			if state.multibootOpen {
+				return drawPageMultiboot(gtx, th, state)
+			}
 			return drawPageSelectUSB(gtx, th, state)
 		case PageFormat:
 			return drawPageFormat(gtx, th, state)
 		case PageSelectISO:
+			if state.catalogOpen {
+				return drawPageDownload(gtx, th, state)
+			}
 			return drawPageSelectISO(gtx, th, state)
 		case PageConfirm:
 			return drawPageConfirm(gtx, th, state)
@@ -398,6 +529,16 @@ func drawPageSelectUSB(gtx layout.Context, th *material.Theme, state *AppState)
 				layout.Flexed(1, func(gtx layout.Context) layout.Dimensions {
 					return layout.Dimensions{}
 				}),
+				layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+					btn := material.Button(th, &state.multibootBtn, "Multi-Boot...")
+					btn.Background = colorSurface
+					btn.Color = colorText
+					if state.selectedUSB < 0 {
+						btn.Color = colorDisabled
+					}
+					return btn.Layout(gtx)
+				}),
+				layout.Rigid(layout.Spacer{Width: unit.Dp(10)}.Layout),
 				layout.Rigid(func(gtx layout.Context) layout.Dimensions {
 					btn := material.Button(th, &state.refreshBtn, "Refresh")
 					btn.Background = colorSurface
@@ -410,9 +551,85 @@ func drawPageSelectUSB(gtx layout.Context, th *material.Theme, state *AppState)
 		layout.Flexed(1, func(gtx layout.Context) layout.Dimensions {
 			return drawUSBList(gtx, th, state)
 		}),
+		layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+			if state.abortNote == "" {
+				return layout.Dimensions{}
+			}
+			return layout.Inset{Top: unit.Dp(10)}.Layout(gtx, func(gtx layout.Context) layout.Dimensions {
+				return drawAbortNote(gtx, th, state.abortNote)
+			})
+		}),
+		layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+			if len(state.jobs) == 0 {
+				return layout.Dimensions{}
+			}
+			return layout.Inset{Top: unit.Dp(10)}.Layout(gtx, func(gtx layout.Context) layout.Dimensions {
+				return drawQueueBox(gtx, th, state)
+			})
+		}),
+	)
+}
+
+// drawQueueBox lists the queued writes, with buttons to start or drop them
+func drawQueueBox(gtx layout.Context, th *material.Theme, state *AppState) layout.Dimensions {
+	return layout.Flex{Axis: layout.Vertical}.Layout(gtx,
+		layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+			return drawInfoBox(gtx, th, fmt.Sprintf("Queued Writes (%d)", len(state.jobs)), queueSummary(state.jobs))
+		}),
+		layout.Rigid(layout.Spacer{Height: unit.Dp(8)}.Layout),
+		layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+			return layout.Flex{Axis: layout.Horizontal, Alignment: layout.Middle}.Layout(gtx,
+				layout.Flexed(1, func(gtx layout.Context) layout.Dimensions {
+					return drawParallelCheck(gtx, th, state)
+				}),
+				layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+					btn := material.Button(th, &state.clearQueue, "Clear Queue")
+					btn.Background = colorSurface
+					btn.Color = colorText
+					return btn.Layout(gtx)
+				}),
+				layout.Rigid(layout.Spacer{Width: unit.Dp(10)}.Layout),
+				layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+					btn := material.Button(th, &state.writeQueue, "Write Queue")
+					btn.Background = colorDanger
+					return btn.Layout(gtx)
+				}),
+			)
+		}),
 	)
 }
 
+// drawAbortNote tells how each job ended after writing was canceled
+func drawAbortNote(gtx layout.Context, th *material.Theme, note string) layout.Dimensions {
+	return widget.Border{
+		Color: colorWarning,
+		Width: unit.Dp(1),
+	}.Layout(gtx, func(gtx layout.Context) layout.Dimensions {
+		return layout.UniformInset(unit.Dp(15)).Layout(gtx, func(gtx layout.Context) layout.Dimensions {
+			return layout.Flex{Axis: layout.Vertical}.Layout(gtx,
+				layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+					lbl := material.Caption(th, "Writing Aborted")
+					lbl.Color = colorWarning
+					lbl.Font.Weight = font.Bold
+					return lbl.Layout(gtx)
+				}),
+				layout.Rigid(layout.Spacer{Height: unit.Dp(5)}.Layout),
+				layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+					lbl := material.Body2(th, note)
+					lbl.Color = colorText
+					return lbl.Layout(gtx)
+				}),
+			)
+		})
+	})
+}
+
+func drawParallelCheck(gtx layout.Context, th *material.Theme, state *AppState) layout.Dimensions {
+	cb := material.CheckBox(th, &state.parallel, fmt.Sprintf("Write in parallel (up to %d at once)", maxParallelWrites))
+	cb.Color = colorText
+	return cb.Layout(gtx)
+}
+
 func drawUSBList(gtx layout.Context, th *material.Theme, state *AppState) layout.Dimensions {
 	return widget.Border{
 		Color: colorSurface,
@@ -445,7 +662,10 @@ func drawUSBList(gtx layout.Context, th *material.Theme, state *AppState) layout
 			bg := colorSurface
 			textColor := colorText
 			borderColor := colorSurface
-			if i == state.selectedUSB {
+			queued := isQueued(state, dev.Path)
+			if queued {
+				textColor = colorDisabled
+			} else if i == state.selectedUSB {
 				bg = color.NRGBA{R: 0, G: 80, B: 150, A: 255}
 				textColor = colorTextBright
 				borderColor = colorPrimary
@@ -468,7 +688,11 @@ func drawUSBList(gtx layout.Context, th *material.Theme, state *AppState) layout
 							}),
 							layout.Rigid(layout.Spacer{Height: unit.Dp(4)}.Layout),
 							layout.Rigid(func(gtx layout.Context) layout.Dimensions {
-								info := material.Caption(th, fmt.Sprintf("%s  -  %s", dev.Path, formatSize(dev.Size)))
+								details := fmt.Sprintf("%s  -  %s", dev.Path, formatSize(dev.Size))
+								if queued {
+									details += "  -  Queued"
+								}
+								info := material.Caption(th, details)
 								info.Color = colorText
 								return info.Layout(gtx)
 							}),
@@ -535,27 +759,68 @@ func drawPageFormat(gtx layout.Context, th *material.Theme, state *AppState) lay
 			warn.Color = colorWarning
 			return warn.Layout(gtx)
 		}),
+		layout.Rigid(layout.Spacer{Height: unit.Dp(20)}.Layout),
+		layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+			return drawSpeedLimit(gtx, th, state)
+		}),
 	)
 }
 
-// Page 3: Select ISO
+// drawSpeedLimit picks the write speed limit of the job being set up
+func drawSpeedLimit(gtx layout.Context, th *material.Theme, state *AppState) layout.Dimensions {
+	return widget.Border{
+		Color: colorSurface,
+		Width: unit.Dp(2),
+	}.Layout(gtx, func(gtx layout.Context) layout.Dimensions {
+		return layout.UniformInset(unit.Dp(20)).Layout(gtx, func(gtx layout.Context) layout.Dimensions {
+			return layout.Flex{Axis: layout.Vertical}.Layout(gtx,
+				layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+					lbl := material.Body1(th, "Write speed limit")
+					lbl.Color = colorText
+					return lbl.Layout(gtx)
+				}),
+				layout.Rigid(layout.Spacer{Height: unit.Dp(6)}.Layout),
+				layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+					var children []layout.FlexChild
+					for _, limit := range speedLimits {
+						key := fmt.Sprintf("%d", limit)
+						children = append(children, layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+							rb := material.RadioButton(th, &state.speedLimit, key, speedLimitLabel(limit))
+							rb.Color = colorText
+							return rb.Layout(gtx)
+						}))
+					}
+					return layout.Flex{Axis: layout.Horizontal}.Layout(gtx, children...)
+				}),
+				layout.Rigid(layout.Spacer{Height: unit.Dp(6)}.Layout),
+				layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+					note := material.Caption(th, "Slower writes keep cheap sticks and busy hubs cooler. Writes also slow down by themselves when the USB starts failing or runs hot.")
+					note.Color = colorDisabled
+					return note.Layout(gtx)
+				}),
+			)
+		})
+	})
+}
+
+// Page 3: Select ISO (scrollable for smaller windows)
 func drawPageSelectISO(gtx layout.Context, th *material.Theme, state *AppState) layout.Dimensions {
 	dev := state.devices[state.selectedUSB]
 
-	return layout.Flex{Axis: layout.Vertical}.Layout(gtx,
-		layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+	items := []layout.Widget{
+		func(gtx layout.Context) layout.Dimensions {
 			title := material.H6(th, "Select ISO Image")
 			title.Color = colorTextBright
 			return title.Layout(gtx)
-		}),
-		layout.Rigid(layout.Spacer{Height: unit.Dp(5)}.Layout),
-		layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+		},
+		layout.Spacer{Height: unit.Dp(5)}.Layout,
+		func(gtx layout.Context) layout.Dimensions {
 			subtitle := material.Body2(th, "Choose the Linux ISO to write to the USB")
 			subtitle.Color = colorText
 			return subtitle.Layout(gtx)
-		}),
-		layout.Rigid(layout.Spacer{Height: unit.Dp(25)}.Layout),
-		layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+		},
+		layout.Spacer{Height: unit.Dp(25)}.Layout,
+		func(gtx layout.Context) layout.Dimensions {
 			return layout.Flex{Axis: layout.Horizontal}.Layout(gtx,
 				layout.Flexed(1, func(gtx layout.Context) layout.Dimensions {
 					return widget.Border{
@@ -580,17 +845,44 @@ func drawPageSelectISO(gtx layout.Context, th *material.Theme, state *AppState)
 					btn.Background = colorPrimary
 					return btn.Layout(gtx)
 				}),
+				layout.Rigid(layout.Spacer{Width: unit.Dp(10)}.Layout),
+				layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+					btn := material.Button(th, &state.catalogBtn, "Download...")
+					btn.Background = colorSurface
+					btn.Color = colorText
+					return btn.Layout(gtx)
+				}),
 			)
-		}),
-		layout.Rigid(layout.Spacer{Height: unit.Dp(20)}.Layout),
-		layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+		},
+		layout.Spacer{Height: unit.Dp(20)}.Layout,
+		func(gtx layout.Context) layout.Dimensions {
 			if state.isoPath == "" {
 				return layout.Dimensions{}
 			}
-			return drawInfoBox(gtx, th, "ISO Details", fmt.Sprintf("File: %s\nSize: %s", filepath.Base(state.isoPath), formatSize(state.isoSize)))
-		}),
-		layout.Rigid(layout.Spacer{Height: unit.Dp(15)}.Layout),
-		layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+			details := fmt.Sprintf("File: %s\nSize: %s", filepath.Base(state.isoPath), formatSize(state.isoSize))
+			if state.isoWindows {
+				details += "\nType: Windows installer; its files are copied to a GPT + FAT32 USB that boots on UEFI computers"
+			}
+			return drawInfoBox(gtx, th, "ISO Details", details)
+		},
+		layout.Spacer{Height: unit.Dp(15)}.Layout,
+		func(gtx layout.Context) layout.Dimensions {
+			if state.isoPath == "" {
+				return layout.Dimensions{}
+			}
+			return layout.Inset{Bottom: unit.Dp(15)}.Layout(gtx, func(gtx layout.Context) layout.Dimensions {
+				return drawVerify(gtx, th, state)
+			})
+		},
+		func(gtx layout.Context) layout.Dimensions {
+			if state.isoPath == "" || dev.Size < state.isoSize || state.isoWindows {
+				return layout.Dimensions{}
+			}
+			return layout.Inset{Bottom: unit.Dp(15)}.Layout(gtx, func(gtx layout.Context) layout.Dimensions {
+				return drawPersistence(gtx, th, state)
+			})
+		},
+		func(gtx layout.Context) layout.Dimensions {
 			if state.isoPath == "" {
 				return layout.Dimensions{}
 			}
@@ -605,8 +897,12 @@ func drawPageSelectISO(gtx layout.Context, th *material.Theme, state *AppState)
 			info := material.Body2(th, fmt.Sprintf("Estimated write time: %s", estimatedTime))
 			info.Color = colorSuccess
 			return info.Layout(gtx)
-		}),
-	)
+		},
+	}
+
+	return material.List(th, &state.isoScroll).Layout(gtx, len(items), func(gtx layout.Context, i int) layout.Dimensions {
+		return items[i](gtx)
+	})
 }
 
 func drawInfoBox(gtx layout.Context, th *material.Theme, title, content string) layout.Dimensions {
@@ -661,16 +957,42 @@ func drawPageConfirm(gtx layout.Context, th *material.Theme, state *AppState) la
 			return warn.Layout(gtx)
 		},
 		layout.Spacer{Height: unit.Dp(20)}.Layout,
+		func(gtx layout.Context) layout.Dimensions {
+			if state.abortNote == "" {
+				return layout.Dimensions{}
+			}
+			return layout.Inset{Bottom: unit.Dp(15)}.Layout(gtx, func(gtx layout.Context) layout.Dimensions {
+				return drawAbortNote(gtx, th, state.abortNote)
+			})
+		},
 		func(gtx layout.Context) layout.Dimensions {
 			return drawInfoBox(gtx, th, "Summary", fmt.Sprintf(
-				"USB: %s %s (%s)\nISO: %s (%s)\nFormat: %s\nEstimated time: %s",
+				"USB: %s %s (%s)\nISO: %s (%s)\nVerified: %s\nWrite mode: %s\nFormat: %s\nPersistence: %s\nSpeed limit: %s\nEstimated time: %s",
 				dev.Vendor, dev.Model, formatSize(dev.Size),
 				filepath.Base(state.isoPath), formatSize(state.isoSize),
+				verifySummary(state),
+				writeModeLabel(state.isoWindows),
 				yesNo(state.formatUSBOpt),
+				persistSummary(persistChoice(state)),
+				speedLimitLabel(parseSpeedLimit(state.speedLimit.Value)),
 				estimatedTime,
 			))
 		},
-		layout.Spacer{Height: unit.Dp(25)}.Layout,
+		layout.Spacer{Height: unit.Dp(15)}.Layout,
+		func(gtx layout.Context) layout.Dimensions {
+			if len(state.jobs) == 0 {
+				return layout.Dimensions{}
+			}
+			return layout.Flex{Axis: layout.Vertical}.Layout(gtx,
+				layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+					return drawInfoBox(gtx, th, "Also Writing", queueSummary(state.jobs))
+				}),
+				layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+					return drawParallelCheck(gtx, th, state)
+				}),
+			)
+		},
+		layout.Spacer{Height: unit.Dp(10)}.Layout,
 		func(gtx layout.Context) layout.Dimensions {
 			cb := material.CheckBox(th, &state.confirmCheck, "I understand and want to proceed")
 			cb.Color = colorText
@@ -687,41 +1009,31 @@ func drawPageConfirm(gtx layout.Context, th *material.Theme, state *AppState) la
 
 // Page 5: Writing
 func drawPageWriting(gtx layout.Context, th *material.Theme, state *AppState) layout.Dimensions {
-	progress := state.progress
-	progressTxt := state.progressTxt
-	etaText := state.etaText
+	jobs := state.jobs
 	logs := make([]string, len(state.statusLog))
 	copy(logs, state.statusLog)
 
+	heading := "Writing ISO to USB..."
+	if len(jobs) > 1 {
+		heading = fmt.Sprintf("Writing %d USB drives...", len(jobs))
+	}
+
 	return layout.Flex{Axis: layout.Vertical}.Layout(gtx,
 		layout.Rigid(func(gtx layout.Context) layout.Dimensions {
-			title := material.H6(th, "Writing ISO to USB...")
+			title := material.H6(th, heading)
 			title.Color = colorAccent
 			title.Alignment = text.Middle
 			return title.Layout(gtx)
 		}),
-		layout.Rigid(layout.Spacer{Height: unit.Dp(8)}.Layout),
-		layout.Rigid(func(gtx layout.Context) layout.Dimensions {
-			if etaText != "" {
-				eta := material.Body2(th, etaText)
-				eta.Color = colorText
-				eta.Alignment = text.Middle
-				return eta.Layout(gtx)
-			}
-			return layout.Dimensions{}
-		}),
-		layout.Rigid(layout.Spacer{Height: unit.Dp(25)}.Layout),
-		layout.Rigid(func(gtx layout.Context) layout.Dimensions {
-			return drawProgressBar(gtx, progress)
+		layout.Rigid(layout.Spacer{Height: unit.Dp(15)}.Layout),
+		layout.Flexed(2, func(gtx layout.Context) layout.Dimensions {
+			return material.List(th, &state.writingScroll).Layout(gtx, len(jobs), func(gtx layout.Context, i int) layout.Dimensions {
+				return layout.Inset{Bottom: unit.Dp(15)}.Layout(gtx, func(gtx layout.Context) layout.Dimensions {
+					return drawJobProgress(gtx, th, jobs[i])
+				})
+			})
 		}),
 		layout.Rigid(layout.Spacer{Height: unit.Dp(10)}.Layout),
-		layout.Rigid(func(gtx layout.Context) layout.Dimensions {
-			pct := material.Body1(th, progressTxt)
-			pct.Color = colorTextBright
-			pct.Alignment = text.Middle
-			return pct.Layout(gtx)
-		}),
-		layout.Rigid(layout.Spacer{Height: unit.Dp(25)}.Layout),
 		layout.Flexed(1, func(gtx layout.Context) layout.Dimensions {
 			return widget.Border{
 				Color: colorSurface,
@@ -738,6 +1050,59 @@ func drawPageWriting(gtx layout.Context, th *material.Theme, state *AppState) la
 	)
 }
 
+// drawJobProgress shows how far along a job is, and why it is paused
+func drawJobProgress(gtx layout.Context, th *material.Theme, job *WriteJob) layout.Dimensions {
+	status := job.ETAText
+	statusColor := colorText
+	switch job.Status {
+	case JobQueued:
+		status = "Waiting..."
+		statusColor = colorDisabled
+	case JobFailed:
+		status = job.Error
+		statusColor = colorDanger
+	case JobCanceled:
+		status = job.Error
+		statusColor = colorWarning
+	}
+
+	return layout.Flex{Axis: layout.Vertical}.Layout(gtx,
+		layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+			name := material.Body1(th, jobTitle(job))
+			name.Color = colorTextBright
+			name.Font.Weight = font.Bold
+			return name.Layout(gtx)
+		}),
+		layout.Rigid(layout.Spacer{Height: unit.Dp(6)}.Layout),
+		layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+			return drawProgressBar(gtx, job.Progress)
+		}),
+		layout.Rigid(layout.Spacer{Height: unit.Dp(6)}.Layout),
+		layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+			return layout.Flex{Axis: layout.Horizontal, Spacing: layout.SpaceBetween}.Layout(gtx,
+				layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+					lbl := material.Body2(th, status)
+					lbl.Color = statusColor
+					return lbl.Layout(gtx)
+				}),
+				layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+					lbl := material.Body2(th, job.ProgressTxt)
+					lbl.Color = colorTextBright
+					return lbl.Layout(gtx)
+				}),
+			)
+		}),
+		layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+			if job.Note == "" {
+				return layout.Dimensions{}
+			}
+			note := material.Caption(th, job.Note)
+			note.Color = colorWarning
+			return note.Layout(gtx)
+		}),
+	)
+}
+
 func drawProgressBar(gtx layout.Context, progress float64) layout.Dimensions {
 	height := gtx.Dp(unit.Dp(28))
 	width := gtx.Constraints.Max.X
@@ -758,34 +1123,58 @@ func drawProgressBar(gtx layout.Context, progress float64) layout.Dimensions {
 
 // Page 6: Complete
 func drawPageComplete(gtx layout.Context, th *material.Theme, state *AppState) layout.Dimensions {
-	writeError := state.writeError
+	var written, failed []*WriteJob
+	for _, job := range state.jobs {
+		if job.Status == JobDone {
+			written = append(written, job)
+		} else {
+			failed = append(failed, job)
+		}
+	}
 
-	if writeError != "" {
-		return layout.Flex{Axis: layout.Vertical, Alignment: layout.Middle}.Layout(gtx,
-			layout.Rigid(layout.Spacer{Height: unit.Dp(30)}.Layout),
-			layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+	if len(failed) > 0 {
+		heading := "Write Failed"
+		if len(state.jobs) > 1 {
+			heading = fmt.Sprintf("%d of %d Writes Failed", len(failed), len(state.jobs))
+		}
+		lines := make([]string, len(failed))
+		for i, job := range failed {
+			lines[i] = job.Error
+			if len(state.jobs) > 1 {
+				lines[i] = job.Device.Path + ": " + job.Error
+			}
+		}
+		if len(written) > 0 {
+			lines = append(lines, "", "Written successfully: "+jobPaths(written))
+		}
+
+		items := []layout.Widget{
+			layout.Spacer{Height: unit.Dp(30)}.Layout,
+			func(gtx layout.Context) layout.Dimensions {
 				icon := material.H1(th, "X")
 				icon.Color = colorDanger
 				return icon.Layout(gtx)
-			}),
-			layout.Rigid(layout.Spacer{Height: unit.Dp(15)}.Layout),
-			layout.Rigid(func(gtx layout.Context) layout.Dimensions {
-				title := material.H5(th, "Write Failed")
+			},
+			layout.Spacer{Height: unit.Dp(15)}.Layout,
+			func(gtx layout.Context) layout.Dimensions {
+				title := material.H5(th, heading)
 				title.Color = colorDanger
 				title.Alignment = text.Middle
 				return title.Layout(gtx)
-			}),
-			layout.Rigid(layout.Spacer{Height: unit.Dp(15)}.Layout),
-			layout.Rigid(func(gtx layout.Context) layout.Dimensions {
-				err := material.Body1(th, writeError)
+			},
+			layout.Spacer{Height: unit.Dp(15)}.Layout,
+			func(gtx layout.Context) layout.Dimensions {
+				err := material.Body1(th, strings.Join(lines, "\n"))
 				err.Color = colorText
 				err.Alignment = text.Middle
 				return err.Layout(gtx)
-			}),
-		)
+			},
+		}
+		return material.List(th, &state.completeScroll).Layout(gtx, len(items), func(gtx layout.Context, i int) layout.Dimensions {
+			return layout.Center.Layout(gtx, items[i])
+		})
 	}
 
-	dev := state.devices[state.selectedUSB]
 	return layout.Flex{Axis: layout.Vertical, Alignment: layout.Middle}.Layout(gtx,
 		layout.Rigid(layout.Spacer{Height: unit.Dp(30)}.Layout),
 		layout.Rigid(func(gtx layout.Context) layout.Dimensions {
@@ -802,7 +1191,7 @@ func drawPageComplete(gtx layout.Context, th *material.Theme, state *AppState) l
 		}),
 		layout.Rigid(layout.Spacer{Height: unit.Dp(20)}.Layout),
 		layout.Rigid(func(gtx layout.Context) layout.Dimensions {
-			msg := material.Body1(th, fmt.Sprintf("Bootable USB created on %s\n\nYou can now boot from this drive.", dev.Path))
+			msg := material.Body1(th, fmt.Sprintf("Bootable USB created on %s\n\nYou can now boot from this drive.", jobPaths(written)))
 			msg.Color = colorText
 			msg.Alignment = text.Middle
 			return msg.Layout(gtx)
@@ -821,7 +1210,7 @@ func drawFooter(gtx layout.Context, th *material.Theme, state *AppState) layout.
 					btn.Color = colorText
 					return btn.Layout(gtx)
 				}
-				if state.currentPage > PageSelectUSB && state.currentPage < PageWriting {
+				if (state.currentPage > PageSelectUSB && state.currentPage < PageWriting) || state.multibootOpen {
 					btn := material.Button(th, &state.backBtn, "Back")
 					btn.Background = colorSurface
 					btn.Color = colorText
@@ -833,12 +1222,17 @@ func drawFooter(gtx layout.Context, th *material.Theme, state *AppState) layout.
 					btn.Color = colorText
 					return btn.Layout(gtx)
 				}
+				if state.currentPage == PageWriting {
+					cb := material.CheckBox(th, &state.wipeCheck, "Wipe the partition table if canceled")
+					cb.Color = colorText
+					return cb.Layout(gtx)
+				}
 				return layout.Dimensions{}
 			}),
 			layout.Rigid(func(gtx layout.Context) layout.Dimensions {
 				// Next/Confirm button
 				if state.currentPage == PageWriting {
-					return layout.Dimensions{}
+					return drawCancelButton(gtx, th, state)
 				}
 				if state.currentPage == PageComplete {
 					btn := material.Button(th, &state.exitBtn, "Exit")
@@ -853,7 +1247,7 @@ func drawFooter(gtx layout.Context, th *material.Theme, state *AppState) layout.
 				switch state.currentPage {
 				case PageSelectUSB:
 					label = "Next"
-					enabled = state.selectedUSB >= 0
+					enabled = state.selectedUSB >= 0 && !state.multibootOpen
 					btnColor = colorPrimary
 				case PageFormat:
 					label = "Next"
@@ -861,7 +1255,7 @@ func drawFooter(gtx layout.Context, th *material.Theme, state *AppState) layout.
 					btnColor = colorPrimary
 				case PageSelectISO:
 					label = "Next"
-					enabled = state.isoPath != "" && state.selectedUSB >= 0 && state.devices[state.selectedUSB].Size >= state.isoSize
+					enabled = state.isoPath != "" && state.selectedUSB >= 0 && state.devices[state.selectedUSB].Size >= state.isoSize && !verifyBlocks(state) && !state.catalogOpen
 					btnColor = colorPrimary
 				case PageConfirm:
 					label = "Start Writing"
@@ -875,12 +1269,41 @@ func drawFooter(gtx layout.Context, th *material.Theme, state *AppState) layout.
 				} else {
 					btn.Background = colorDisabled
 				}
-				return btn.Layout(gtx)
+				if state.currentPage != PageConfirm {
+					return btn.Layout(gtx)
+				}
+
+				// Queue this write and set up another
+				queueBtn := material.Button(th, &state.queueBtn, "Add to Queue")
+				queueBtn.Background = colorSurface
+				queueBtn.Color = colorText
+				if !enabled {
+					queueBtn.Color = colorDisabled
+				}
+				return layout.Flex{Axis: layout.Horizontal}.Layout(gtx,
+					layout.Rigid(queueBtn.Layout),
+					layout.Rigid(layout.Spacer{Width: unit.Dp(10)}.Layout),
+					layout.Rigid(btn.Layout),
+				)
 			}),
 		)
 	})
 }
 
+// drawCancelButton stops the writes, or shows they are stopping
+func drawCancelButton(gtx layout.Context, th *material.Theme, state *AppState) layout.Dimensions {
+	label, bg := "Cancel", colorDanger
+	for _, job := range state.jobs {
+		if job.Canceled {
+			label, bg = "Canceling...", colorDisabled
+			break
+		}
+	}
+	btn := material.Button(th, &state.cancelWrite, label)
+	btn.Background = bg
+	return btn.Layout(gtx)
+}
+
 // ============ Helper Functions ============
 
 func yesNo(b bool) string {
@@ -1031,9 +1454,7 @@ func browseForISO() string {
 	return ""
 }
 
-func formatUSBDevice(state *AppState, w *app.Window) error {
-	dev := state.devices[state.selectedUSB]
-
+func formatUSBDevice(dev USBDevice) error {
 	// Unmount
 	partitions, _ := filepath.Glob(dev.Path + "*")
 	for _, part := range partitions {
@@ -1060,163 +1481,6 @@ func formatUSBDevice(state *AppState, w *app.Window) error {
 	return nil
 }
 
-func writeToUSB(state *AppState, w *app.Window) {
-	addLog := func(msg string) {
-		state.mu.Lock()
-		state.statusLog = append(state.statusLog, msg)
-		state.mu.Unlock()
-		w.Invalidate()
-	}
-
-	setProgress := func(p float64, text string) {
-		state.mu.Lock()
-		state.progress = p
-		state.progressTxt = text
-		state.mu.Unlock()
-		w.Invalidate()
-	}
-
-	setETA := func(text string) {
-		state.mu.Lock()
-		state.etaText = text
-		state.mu.Unlock()
-		w.Invalidate()
-	}
-
-	setError := func(err string) {
-		state.mu.Lock()
-		state.writeError = err
-		state.currentPage = PageComplete
-		state.mu.Unlock()
-		w.Invalidate()
-	}
-
-	state.mu.Lock()
-	dev := state.devices[state.selectedUSB]
-	isoPath := state.isoPath
-	doFormat := state.formatUSBOpt
-	state.mu.Unlock()
-
-	addLog("Starting write process...")
-	setProgress(0.02, "Preparing...")
-
-	// Format if requested
-	if doFormat {
-		addLog("Formatting USB...")
-		setProgress(0.05, "Formatting...")
-		if err := formatUSBDevice(state, w); err != nil {
-			setError("Format failed: " + err.Error())
-			return
-		}
-		addLog("Format complete")
-	}
-
-	// Unmount
-	addLog("Unmounting device...")
-	setProgress(0.08, "Unmounting...")
-	partitions, _ := filepath.Glob(dev.Path + "*")
-	for _, part := range partitions {
-		exec.Command("umount", "-f", part).Run()
-	}
-	exec.Command("sync").Run()
-	time.Sleep(500 * time.Millisecond)
-
-	setProgress(0.1, "Opening files...")
-
-	// Open ISO
-	info, err := os.Stat(isoPath)
-	if err != nil {
-		setError("Cannot read ISO: " + err.Error())
-		return
-	}
-	totalSize := info.Size()
-
-	isoFile, err := os.Open(isoPath)
-	if err != nil {
-		setError("Cannot open ISO: " + err.Error())
-		return
-	}
-	defer isoFile.Close()
-
-	// Open device
-	device, err := os.OpenFile(dev.Path, os.O_WRONLY|os.O_SYNC, 0)
-	if err != nil {
-		setError("Cannot open device: " + err.Error())
-		return
-	}
-	defer device.Close()
-
-	addLog("Writing ISO to USB...")
-	setProgress(0.1, "Writing...")
-
-	buffer := make([]byte, 4*1024*1024) // 4MB buffer
-	var written int64
-	startTime := time.Now()
-	lastUpdate := startTime
-
-	for {
-		n, err := isoFile.Read(buffer)
-		if n > 0 {
-			_, werr := device.Write(buffer[:n])
-			if werr != nil {
-				setError("Write error: " + werr.Error())
-				return
-			}
-			written += int64(n)
-
-			// Update progress every 500ms or so
-			now := time.Now()
-			if now.Sub(lastUpdate) > 500*time.Millisecond {
-				lastUpdate = now
-
-				progress := 0.1 + (float64(written)/float64(totalSize))*0.85
-				progressText := fmt.Sprintf("%s / %s (%.1f%%)", formatSize(uint64(written)), formatSize(uint64(totalSize)), progress*100)
-				setProgress(progress, progressText)
-
-				// Calculate ETA
-				elapsed := now.Sub(startTime).Seconds()
-				if elapsed > 0 && written > 0 {
-					speed := float64(written) / elapsed
-					remaining := float64(totalSize - written)
-					etaSeconds := remaining / speed
-					speedMB := speed / (1024 * 1024)
-
-					if etaSeconds < 60 {
-						setETA(fmt.Sprintf("Speed: %.1f MB/s  -  ETA: %d seconds", speedMB, int(etaSeconds)))
-					} else {
-						etaMinutes := etaSeconds / 60
-						setETA(fmt.Sprintf("Speed: %.1f MB/s  -  ETA: %.1f minutes", speedMB, etaMinutes))
-					}
-				}
-			}
-		}
-		if err == io.EOF {
-			break
-		}
-		if err != nil {
-			setError("Read error: " + err.Error())
-			return
-		}
-	}
-
-	addLog("Syncing data...")
-	setProgress(0.96, "Syncing...")
-	setETA("Syncing to disk...")
-	device.Sync()
-	syscall.Sync()
-
-	elapsed := time.Since(startTime)
-	setProgress(1.0, "Complete!")
-	setETA(fmt.Sprintf("Completed in %s", formatDuration(elapsed)))
-	addLog(fmt.Sprintf("Write completed in %s", formatDuration(elapsed)))
-
-	state.mu.Lock()
-	state.writeDone = true
-	state.currentPage = PageComplete
-	state.mu.Unlock()
-	w.Invalidate()
-}
-
 func formatDuration(d time.Duration) string {
 	if d < time.Minute {
 		return fmt.Sprintf("%d seconds", int(d.Seconds()))
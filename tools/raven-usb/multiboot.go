@@ -0,0 +1,642 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"gioui.org/app"
+	"gioui.org/font"
+	"gioui.org/layout"
+	"gioui.org/op/clip"
+	"gioui.org/op/paint"
+	"gioui.org/unit"
+	"gioui.org/widget"
+	"gioui.org/widget/material"
+)
+
+// A multi-boot drive has a small FAT32 partition with GRUB and an exFAT
+// partition holding any number of ISOs, each booted as a file from the
+// GRUB menu
+const (
+	multibootBootLabel = "RAVENBOOT"
+	multibootDataLabel = "RAVENISO"
+	multibootBootSize  = "257MiB" // End of the boot partition, which starts at 1MiB
+	multibootISODir    = "isos"
+	multibootMenu      = "raven-usb.cfg" // Menu of the ISOs, on the data partition
+)
+
+// multibootGrubCfg is the boot partition's GRUB config. It finds the data
+// partition and loads the menu raven-usb writes there.
+const multibootGrubCfg = `set timeout=10
+set default=0
+
+insmod part_msdos
+insmod fat
+insmod exfat
+insmod loopback
+insmod iso9660
+insmod all_video
+
+search --no-floppy --set=root --label ` + multibootDataLabel + `
+if [ -f /` + multibootMenu + ` ]; then
+    source /` + multibootMenu + `
+fi
+
+menuentry "Reboot" --class restart {
+    reboot
+}
+
+menuentry "Shutdown" --class shutdown {
+    halt
+}
+`
+
+// MultibootDrive is the USB being managed as a multi-boot drive. Guarded by
+// AppState.mu.
+type MultibootDrive struct {
+	Device   USBDevice
+	Data     string // Partition holding the ISOs, "" until the drive is prepared
+	Mount    string // Where Data is mounted while the drive is open
+	Checked  bool   // Whether the drive has been looked at for Data
+	ISOs     []MultibootISO
+	Free     uint64
+	Task     string // What is running, "" when idle
+	Progress float64
+	Error    string
+	Note     string
+}
+
+// MultibootISO is an ISO on a multi-boot drive
+type MultibootISO struct {
+	Name string
+	Size uint64
+}
+
+// openMultiboot opens the selected USB as a multi-boot drive, mounting its
+// data partition when it is already prepared. Called with state.mu held.
+func openMultiboot(state *AppState, w *app.Window) {
+	dev := state.devices[state.selectedUSB]
+	mb := &MultibootDrive{Device: dev}
+	state.multiboot = mb
+	state.multibootOpen = true
+	state.multibootConfirm.Value = false
+
+	runMultiboot(state, w, "Checking "+dev.Path+"...", func(func(float64)) error {
+		part := findDataPartition(dev)
+		state.mu.Lock()
+		mb.Checked = true
+		state.mu.Unlock()
+		if part == "" {
+			return nil
+		}
+		mount, err := mountData(part)
+		if err != nil {
+			return err
+		}
+		state.mu.Lock()
+		mb.Data, mb.Mount = part, mount
+		state.mu.Unlock()
+		// Pick up ISOs copied onto the drive elsewhere
+		return writeMultibootMenu(mount)
+	})
+}
+
+// closeMultiboot leaves the multi-boot manager, unmounting the drive.
+// Called with state.mu held.
+func closeMultiboot(state *AppState) {
+	mb := state.multiboot
+	if mb != nil && mb.Task != "" {
+		return
+	}
+	state.multibootOpen = false
+	state.multiboot = nil
+	if mb != nil && mb.Mount != "" {
+		go unmountData(mb.Mount)
+	}
+}
+
+// runMultiboot runs work on the drive in the background, then lists its
+// ISOs again. Called with state.mu held.
+func runMultiboot(state *AppState, w *app.Window, task string, work func(progress func(float64)) error) {
+	mb := state.multiboot
+	mb.Task, mb.Progress, mb.Error = task, 0, ""
+
+	go func() {
+		err := work(func(p float64) {
+			state.mu.Lock()
+			mb.Progress = p
+			state.mu.Unlock()
+			w.Invalidate()
+		})
+
+		state.mu.Lock()
+		mount := mb.Mount
+		state.mu.Unlock()
+		var isos []MultibootISO
+		var free uint64
+		if mount != "" {
+			var listErr error
+			isos, free, listErr = listISOs(mount)
+			if err == nil {
+				err = listErr
+			}
+		}
+
+		state.mu.Lock()
+		mb.Task = ""
+		mb.ISOs, mb.Free = isos, free
+		state.multibootRemove = make([]widget.Clickable, len(isos))
+		if err != nil {
+			mb.Error = err.Error()
+		}
+		state.mu.Unlock()
+		w.Invalidate()
+	}()
+}
+
+// prepareDrive erases the drive and prepares it for multi-boot. Called
+// with state.mu held.
+func prepareDrive(state *AppState, w *app.Window) {
+	mb := state.multiboot
+	dev := mb.Device
+	runMultiboot(state, w, "Preparing "+dev.Path+"...", func(func(float64)) error {
+		data, note, err := prepareMultiboot(dev)
+		if err != nil {
+			return err
+		}
+		mount, err := mountData(data)
+		if err != nil {
+			return err
+		}
+		state.mu.Lock()
+		mb.Data, mb.Mount, mb.Note = data, mount, note
+		state.mu.Unlock()
+		if err := os.MkdirAll(filepath.Join(mount, multibootISODir), 0755); err != nil {
+			return err
+		}
+		return writeMultibootMenu(mount)
+	})
+}
+
+// addToDrive asks for an ISO and copies it onto the drive
+func addToDrive(state *AppState, w *app.Window) {
+	go func() {
+		src := browseForISO()
+		if src == "" {
+			return
+		}
+		state.mu.Lock()
+		defer state.mu.Unlock()
+		mb := state.multiboot
+		if mb == nil || mb.Task != "" || mb.Mount == "" {
+			return
+		}
+		mount, free := mb.Mount, mb.Free
+		runMultiboot(state, w, "Copying "+filepath.Base(src)+"...", func(progress func(float64)) error {
+			return addISO(mount, src, free, progress)
+		})
+	}()
+}
+
+// removeFromDrive deletes the i-th ISO from the drive. Called with
+// state.mu held.
+func removeFromDrive(state *AppState, w *app.Window, i int) {
+	mb := state.multiboot
+	mount, name := mb.Mount, mb.ISOs[i].Name
+	runMultiboot(state, w, "Removing "+name+"...", func(func(float64)) error {
+		if err := os.Remove(filepath.Join(mount, multibootISODir, name)); err != nil {
+			return err
+		}
+		return writeMultibootMenu(mount)
+	})
+}
+
+// prepareMultiboot partitions dev into a boot and a data partition and
+// installs GRUB for UEFI and BIOS. It returns the data partition, and a
+// note when only one of them could be set up.
+func prepareMultiboot(dev USBDevice) (data, note string, err error) {
+	partitions, _ := filepath.Glob(dev.Path + "*")
+	for _, part := range partitions {
+		exec.Command("umount", "-f", part).Run()
+	}
+	time.Sleep(500 * time.Millisecond)
+
+	if out, err := exec.Command("parted", "-s", dev.Path,
+		"mklabel", "msdos",
+		"mkpart", "primary", "fat32", "1MiB", multibootBootSize,
+		"set", "1", "esp", "on",
+		"set", "1", "boot", "on",
+		"mkpart", "primary", multibootBootSize, "100%").CombinedOutput(); err != nil {
+		return "", "", fmt.Errorf("failed to partition %s: %v: %s", dev.Path, err, strings.TrimSpace(string(out)))
+	}
+	exec.Command("partprobe", dev.Path).Run()
+	time.Sleep(2 * time.Second)
+
+	boot, data := partitionPath(dev, 1), partitionPath(dev, 2)
+	if out, err := exec.Command("mkfs.vfat", "-F", "32", "-n", multibootBootLabel, boot).CombinedOutput(); err != nil {
+		return "", "", fmt.Errorf("failed to format %s: %v: %s", boot, err, strings.TrimSpace(string(out)))
+	}
+	if out, err := exec.Command("mkfs.exfat", "-L", multibootDataLabel, data).CombinedOutput(); err != nil {
+		return "", "", fmt.Errorf("failed to format %s: %v: %s", data, err, strings.TrimSpace(string(out)))
+	}
+
+	mount, err := os.MkdirTemp("", "raven-usb-boot-")
+	if err != nil {
+		return "", "", err
+	}
+	defer os.Remove(mount)
+	if out, err := exec.Command("mount", boot, mount).CombinedOutput(); err != nil {
+		return "", "", fmt.Errorf("failed to mount %s: %v: %s", boot, err, strings.TrimSpace(string(out)))
+	}
+	defer exec.Command("umount", mount).Run()
+
+	bootDir := filepath.Join(mount, "boot")
+	efiErr := exec.Command("grub-install", "--target=x86_64-efi", "--efi-directory="+mount,
+		"--boot-directory="+bootDir, "--removable", "--no-nvram").Run()
+	biosErr := exec.Command("grub-install", "--target=i386-pc", "--boot-directory="+bootDir, dev.Path).Run()
+	switch {
+	case efiErr != nil && biosErr != nil:
+		return "", "", fmt.Errorf("failed to install GRUB: %v", efiErr)
+	case efiErr != nil:
+		note = "GRUB for UEFI could not be installed; the drive boots on BIOS computers only"
+	case biosErr != nil:
+		note = "GRUB for BIOS could not be installed; the drive boots on UEFI computers only"
+	}
+
+	if err := os.WriteFile(filepath.Join(bootDir, "grub", "grub.cfg"), []byte(multibootGrubCfg), 0644); err != nil {
+		return "", "", err
+	}
+	exec.Command("sync").Run()
+	return data, note, nil
+}
+
+// partitionPath returns the n-th partition of dev, such as /dev/sdb2 or
+// /dev/mmcblk0p2
+func partitionPath(dev USBDevice, n int) string {
+	if last := dev.Name[len(dev.Name)-1]; last >= '0' && last <= '9' {
+		return fmt.Sprintf("%sp%d", dev.Path, n)
+	}
+	return fmt.Sprintf("%s%d", dev.Path, n)
+}
+
+// findDataPartition returns dev's multi-boot data partition, or "" when
+// the drive isn't prepared
+func findDataPartition(dev USBDevice) string {
+	parts, _ := filepath.Glob(filepath.Join("/sys/block", dev.Name, dev.Name+"*", "partition"))
+	for _, path := range parts {
+		part := filepath.Join("/dev", filepath.Base(filepath.Dir(path)))
+		out, err := exec.Command("blkid", "-o", "value", "-s", "LABEL", part).Output()
+		if err == nil && strings.TrimSpace(string(out)) == multibootDataLabel {
+			return part
+		}
+	}
+	return ""
+}
+
+func mountData(part string) (string, error) {
+	mount, err := os.MkdirTemp("", "raven-usb-isos-")
+	if err != nil {
+		return "", err
+	}
+	if out, err := exec.Command("mount", "-t", "exfat", part, mount).CombinedOutput(); err != nil {
+		os.Remove(mount)
+		return "", fmt.Errorf("failed to mount %s: %v: %s", part, err, strings.TrimSpace(string(out)))
+	}
+	return mount, nil
+}
+
+func unmountData(mount string) {
+	exec.Command("sync").Run()
+	if exec.Command("umount", mount).Run() == nil {
+		os.Remove(mount)
+	}
+}
+
+// listISOs returns the ISOs on the drive mounted at mount, and its free
+// space
+func listISOs(mount string) ([]MultibootISO, uint64, error) {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(mount, &stat); err != nil {
+		return nil, 0, err
+	}
+	free := stat.Bavail * uint64(stat.Bsize)
+
+	entries, err := os.ReadDir(filepath.Join(mount, multibootISODir))
+	if os.IsNotExist(err) {
+		return nil, free, nil
+	}
+	if err != nil {
+		return nil, free, err
+	}
+	var isos []MultibootISO
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(strings.ToLower(entry.Name()), ".iso") {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		isos = append(isos, MultibootISO{Name: entry.Name(), Size: uint64(info.Size())})
+	}
+	return isos, free, nil
+}
+
+// addISO copies src into the drive's ISO folder and adds it to the menu
+func addISO(mount, src string, free uint64, progress func(float64)) error {
+	info, err := os.Stat(src)
+	if err != nil {
+		return err
+	}
+	name := filepath.Base(src)
+	dst := filepath.Join(mount, multibootISODir, name)
+	if _, err := os.Stat(dst); err == nil {
+		return fmt.Errorf("%s is already on the drive", name)
+	}
+	size := uint64(info.Size())
+	if size > free {
+		return fmt.Errorf("%s needs %s, but only %s is free", name, formatSize(size), formatSize(free))
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	partial := dst + ".part"
+	out, err := os.Create(partial)
+	if err != nil {
+		return err
+	}
+
+	buffer := make([]byte, writeChunkSize)
+	var done uint64
+	lastUpdate := time.Now()
+	for {
+		n, err := in.Read(buffer)
+		if n > 0 {
+			if _, werr := out.Write(buffer[:n]); werr != nil {
+				out.Close()
+				os.Remove(partial)
+				return werr
+			}
+			done += uint64(n)
+			if time.Since(lastUpdate) > 250*time.Millisecond {
+				lastUpdate = time.Now()
+				progress(float64(done) / float64(size))
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			out.Close()
+			os.Remove(partial)
+			return err
+		}
+	}
+	progress(1)
+
+	if err := out.Sync(); err != nil {
+		out.Close()
+		os.Remove(partial)
+		return err
+	}
+	if err := out.Close(); err != nil {
+		os.Remove(partial)
+		return err
+	}
+	if err := os.Rename(partial, dst); err != nil {
+		return err
+	}
+	return writeMultibootMenu(mount)
+}
+
+// writeMultibootMenu writes a GRUB menu entry for each ISO on the drive.
+// An ISO's own loopback.cfg boots it best; its grub.cfg is tried otherwise.
+func writeMultibootMenu(mount string) error {
+	isos, _, err := listISOs(mount)
+	if err != nil {
+		return err
+	}
+
+	var menu strings.Builder
+	menu.WriteString("# Written by raven-usb; changes are lost when ISOs are added or removed\n")
+	for _, iso := range isos {
+		title := strings.TrimSuffix(iso.Name, filepath.Ext(iso.Name))
+		fmt.Fprintf(&menu, "\nmenuentry %s {\n", grubQuote(title))
+		fmt.Fprintf(&menu, "    set iso_path=%s\n", grubQuote("/"+multibootISODir+"/"+iso.Name))
+		menu.WriteString("    export iso_path\n")
+		menu.WriteString("    loopback loop \"$iso_path\"\n")
+		menu.WriteString("    set root=(loop)\n")
+		menu.WriteString("    if [ -f /boot/grub/loopback.cfg ]; then\n")
+		menu.WriteString("        configfile /boot/grub/loopback.cfg\n")
+		menu.WriteString("    else\n")
+		menu.WriteString("        configfile /boot/grub/grub.cfg\n")
+		menu.WriteString("    fi\n")
+		menu.WriteString("}\n")
+	}
+
+	path := filepath.Join(mount, multibootMenu)
+	if err := os.WriteFile(path+".tmp", []byte(menu.String()), 0644); err != nil {
+		return err
+	}
+	return os.Rename(path+".tmp", path)
+}
+
+// grubQuote quotes s as a GRUB word
+func grubQuote(s string) string {
+	s = strings.NewReplacer(`\`, `\\`, `"`, `\"`, `$`, `\$`).Replace(s)
+	return `"` + s + `"`
+}
+
+// drawPageMultiboot manages the ISOs on a multi-boot drive, or offers to
+// prepare the drive when it isn't one yet
+func drawPageMultiboot(gtx layout.Context, th *material.Theme, state *AppState) layout.Dimensions {
+	mb := state.multiboot
+	dev := mb.Device
+
+	return layout.Flex{Axis: layout.Vertical}.Layout(gtx,
+		layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+			title := material.H6(th, "Multi-Boot USB")
+			title.Color = colorTextBright
+			return title.Layout(gtx)
+		}),
+		layout.Rigid(layout.Spacer{Height: unit.Dp(5)}.Layout),
+		layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+			subtitle := material.Body2(th, fmt.Sprintf("Keep several ISOs on %s %s (%s) and pick one when booting", dev.Vendor, dev.Model, dev.Path))
+			subtitle.Color = colorText
+			return subtitle.Layout(gtx)
+		}),
+		layout.Rigid(layout.Spacer{Height: unit.Dp(15)}.Layout),
+		layout.Flexed(1, func(gtx layout.Context) layout.Dimensions {
+			if mb.Data == "" {
+				if !mb.Checked {
+					return layout.Dimensions{}
+				}
+				return drawPrepareDrive(gtx, th, state)
+			}
+			return drawMultibootList(gtx, th, state)
+		}),
+		layout.Rigid(layout.Spacer{Height: unit.Dp(10)}.Layout),
+		layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+			return drawMultibootStatus(gtx, th, mb)
+		}),
+		layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+			if mb.Data == "" {
+				return layout.Dimensions{}
+			}
+			return layout.Inset{Top: unit.Dp(10)}.Layout(gtx, func(gtx layout.Context) layout.Dimensions {
+				return layout.Flex{Axis: layout.Horizontal, Alignment: layout.Middle}.Layout(gtx,
+					layout.Flexed(1, func(gtx layout.Context) layout.Dimensions {
+						lbl := material.Caption(th, fmt.Sprintf("%s free", formatSize(mb.Free)))
+						lbl.Color = colorText
+						return lbl.Layout(gtx)
+					}),
+					layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+						btn := material.Button(th, &state.addISOBtn, "Add ISO...")
+						btn.Background = colorPrimary
+						if mb.Task != "" {
+							btn.Background = colorDisabled
+						}
+						return btn.Layout(gtx)
+					}),
+				)
+			})
+		}),
+	)
+}
+
+// drawPrepareDrive explains the multi-boot layout and asks before erasing
+// the drive to set it up
+func drawPrepareDrive(gtx layout.Context, th *material.Theme, state *AppState) layout.Dimensions {
+	mb := state.multiboot
+	return layout.Flex{Axis: layout.Vertical}.Layout(gtx,
+		layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+			return drawInfoBox(gtx, th, "Not a Multi-Boot Drive Yet", fmt.Sprintf(
+				"Preparing the drive creates:\n"+
+					"  %s - 256 MB FAT32 with GRUB for UEFI and BIOS computers\n"+
+					"  %s - the rest of the drive, exFAT, holding the ISOs\n\n"+
+					"Add and remove ISOs here afterwards, without writing the drive again.",
+				multibootBootLabel, multibootDataLabel))
+		}),
+		layout.Rigid(layout.Spacer{Height: unit.Dp(15)}.Layout),
+		layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+			cb := material.CheckBox(th, &state.multibootConfirm, fmt.Sprintf("Erase everything on %s and prepare it", mb.Device.Path))
+			cb.Color = colorText
+			return cb.Layout(gtx)
+		}),
+		layout.Rigid(layout.Spacer{Height: unit.Dp(10)}.Layout),
+		layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+			btn := material.Button(th, &state.prepareBtn, "Prepare Drive")
+			btn.Background = colorDanger
+			if !state.multibootConfirm.Value || mb.Task != "" {
+				btn.Background = colorDisabled
+			}
+			return btn.Layout(gtx)
+		}),
+	)
+}
+
+// drawMultibootList lists the ISOs on the drive, each with a Remove button
+func drawMultibootList(gtx layout.Context, th *material.Theme, state *AppState) layout.Dimensions {
+	mb := state.multiboot
+	return widget.Border{
+		Color: colorSurface,
+		Width: unit.Dp(2),
+	}.Layout(gtx, func(gtx layout.Context) layout.Dimensions {
+		paint.FillShape(gtx.Ops, colorSurface, clip.Rect{Max: gtx.Constraints.Max}.Op())
+
+		if len(mb.ISOs) == 0 {
+			return layout.Center.Layout(gtx, func(gtx layout.Context) layout.Dimensions {
+				return layout.Flex{Axis: layout.Vertical, Alignment: layout.Middle}.Layout(gtx,
+					layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+						msg := material.Body1(th, "No ISOs on the drive")
+						msg.Color = colorDisabled
+						return msg.Layout(gtx)
+					}),
+					layout.Rigid(layout.Spacer{Height: unit.Dp(10)}.Layout),
+					layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+						hint := material.Caption(th, "Click Add ISO... to copy one onto it")
+						hint.Color = colorDisabled
+						return hint.Layout(gtx)
+					}),
+				)
+			})
+		}
+
+		return material.List(th, &state.multibootScroll).Layout(gtx, len(mb.ISOs), func(gtx layout.Context, i int) layout.Dimensions {
+			iso := mb.ISOs[i]
+			return layout.UniformInset(unit.Dp(12)).Layout(gtx, func(gtx layout.Context) layout.Dimensions {
+				return layout.Flex{Axis: layout.Horizontal, Alignment: layout.Middle}.Layout(gtx,
+					layout.Flexed(1, func(gtx layout.Context) layout.Dimensions {
+						return layout.Flex{Axis: layout.Vertical}.Layout(gtx,
+							layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+								name := material.Body1(th, iso.Name)
+								name.Color = colorTextBright
+								name.Font.Weight = font.Bold
+								return name.Layout(gtx)
+							}),
+							layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+								info := material.Caption(th, formatSize(iso.Size))
+								info.Color = colorText
+								return info.Layout(gtx)
+							}),
+						)
+					}),
+					layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+						btn := material.Button(th, &state.multibootRemove[i], "Remove")
+						btn.Background = colorBackground
+						btn.Color = colorDanger
+						if mb.Task != "" {
+							btn.Color = colorDisabled
+						}
+						return btn.Layout(gtx)
+					}),
+				)
+			})
+		})
+	})
+}
+
+// drawMultibootStatus shows what is running on the drive, or how the last
+// change went
+func drawMultibootStatus(gtx layout.Context, th *material.Theme, mb *MultibootDrive) layout.Dimensions {
+	status, statusColor := "", colorText
+	switch {
+	case mb.Task != "":
+		status = mb.Task
+	case mb.Error != "":
+		status, statusColor = mb.Error, colorDanger
+	case mb.Note != "":
+		status, statusColor = mb.Note, colorWarning
+	}
+	if status == "" {
+		return layout.Dimensions{}
+	}
+
+	return layout.Flex{Axis: layout.Vertical}.Layout(gtx,
+		layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+			if mb.Task == "" || mb.Progress <= 0 {
+				return layout.Dimensions{}
+			}
+			return layout.Inset{Bottom: unit.Dp(6)}.Layout(gtx, func(gtx layout.Context) layout.Dimensions {
+				return drawProgressBar(gtx, mb.Progress)
+			})
+		}),
+		layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+			lbl := material.Body2(th, status)
+			lbl.Color = statusColor
+			return lbl.Layout(gtx)
+		}),
+	)
+}
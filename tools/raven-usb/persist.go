@@ -0,0 +1,144 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gioui.org/layout"
+	"gioui.org/unit"
+	"gioui.org/widget/material"
+)
+
+// persistLabel names the partition the live system keeps its changes on.
+// The initramfs mounts it as the overlay's upper layer instead of a tmpfs.
+const persistLabel = "casper-rw"
+
+const (
+	minPersistSize = 512 * 1024 * 1024 // Smallest persistence partition offered
+	partAlign      = 1024 * 1024       // Partitions start and end on MiB boundaries
+	sectorSize     = 512
+)
+
+// persistRange returns the smallest and largest persistence partition that
+// fits on dev after an ISO of isoSize, or ok false when none does
+func persistRange(dev USBDevice, isoSize uint64) (lo, hi uint64, ok bool) {
+	start := alignUp(isoSize)
+	if dev.Size < start+partAlign {
+		return 0, 0, false
+	}
+	hi = (dev.Size - start - partAlign) / partAlign * partAlign
+	if hi < minPersistSize {
+		return 0, 0, false
+	}
+	return minPersistSize, hi, true
+}
+
+// persistChoice returns the persistence size picked in the wizard, 0 for
+// none
+func persistChoice(state *AppState) uint64 {
+	if !state.persistCheck.Value || state.selectedUSB < 0 || state.isoWindows {
+		return 0
+	}
+	lo, hi, ok := persistRange(state.devices[state.selectedUSB], state.isoSize)
+	if !ok {
+		return 0
+	}
+	size := lo + uint64(float64(state.persistSize.Value)*float64(hi-lo))
+	return size / partAlign * partAlign
+}
+
+// persistSummary describes a persistence size for the Confirm page
+func persistSummary(size uint64) string {
+	if size == 0 {
+		return "None"
+	}
+	return formatSize(size) + " (" + persistLabel + ")"
+}
+
+func alignUp(n uint64) uint64 {
+	return (n + partAlign - 1) / partAlign * partAlign
+}
+
+// createPersistence adds an ext4 partition labelled persistLabel after the
+// ISO written to dev, in the space the ISO's partition table leaves free
+func createPersistence(dev USBDevice, isoSize, size uint64) error {
+	start := alignUp(isoSize) / sectorSize
+	table := fmt.Sprintf("start=%d, size=%d, type=83\n", start, size/sectorSize)
+	cmd := exec.Command("sfdisk", "--append", "--no-reread", dev.Path)
+	cmd.Stdin = strings.NewReader(table)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to add partition: %v: %s", err, strings.TrimSpace(string(out)))
+	}
+	exec.Command("partprobe", dev.Path).Run()
+	time.Sleep(2 * time.Second)
+
+	part, err := partitionAt(dev, start)
+	if err != nil {
+		return err
+	}
+	if out, err := exec.Command("mkfs.ext4", "-F", "-L", persistLabel, part).CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to format %s: %v: %s", part, err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// partitionAt finds dev's partition starting at sector start
+func partitionAt(dev USBDevice, start uint64) (string, error) {
+	starts, _ := filepath.Glob(filepath.Join("/sys/block", dev.Name, dev.Name+"*", "start"))
+	for _, path := range starts {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		if parseUint(strings.TrimSpace(string(data))) == start {
+			return filepath.Join("/dev", filepath.Base(filepath.Dir(path))), nil
+		}
+	}
+	return "", fmt.Errorf("the new partition did not appear on %s", dev.Path)
+}
+
+// drawPersistence draws the persistent storage option and its size slider
+func drawPersistence(gtx layout.Context, th *material.Theme, state *AppState) layout.Dimensions {
+	dev := state.devices[state.selectedUSB]
+	lo, hi, ok := persistRange(dev, state.isoSize)
+
+	return layout.Flex{Axis: layout.Vertical}.Layout(gtx,
+		layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+			if !ok {
+				gtx = gtx.Disabled()
+			}
+			cb := material.CheckBox(th, &state.persistCheck, "Persistent storage (keep files and settings across reboots)")
+			cb.Color = colorText
+			return cb.Layout(gtx)
+		}),
+		layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+			if !ok {
+				note := material.Caption(th, fmt.Sprintf("Needs at least %s free on the USB after the ISO", formatSize(minPersistSize)))
+				note.Color = colorDisabled
+				return layout.Inset{Left: unit.Dp(32)}.Layout(gtx, note.Layout)
+			}
+			if !state.persistCheck.Value {
+				return layout.Dimensions{}
+			}
+			return layout.Inset{Left: unit.Dp(32)}.Layout(gtx, func(gtx layout.Context) layout.Dimensions {
+				return layout.Flex{Axis: layout.Vertical}.Layout(gtx,
+					layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+						slider := material.Slider(th, &state.persistSize)
+						slider.Color = colorPrimary
+						return slider.Layout(gtx)
+					}),
+					layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+						lbl := material.Caption(th, fmt.Sprintf("%s for persistence (%s to %s), labelled %s",
+							formatSize(persistChoice(state)), formatSize(lo), formatSize(hi), persistLabel))
+						lbl.Color = colorText
+						return lbl.Layout(gtx)
+					}),
+				)
+			})
+		}),
+	)
+}
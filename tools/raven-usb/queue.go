@@ -0,0 +1,488 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// Most jobs written at the same time when writing in parallel
+const maxParallelWrites = 4
+
+// Speed limits offered per job, in MB/s; 0 is no limit
+var speedLimits = []int{0, 40, 20, 10}
+
+// Job states
+const (
+	JobQueued = iota
+	JobWriting
+	JobDone
+	JobFailed
+	JobCanceled
+)
+
+// invalidator is told when a job changes: the GUI's window, or the
+// command line's progress printer
+type invalidator interface {
+	Invalidate()
+}
+
+// WriteJob is one ISO to write to one USB device. Jobs are queued and
+// written one after another, or several at a time. Guarded by AppState.mu.
+type WriteJob struct {
+	Device    USBDevice
+	ISOPath   string
+	ISOSize   uint64
+	Format    bool
+	RateLimit float64 // Bytes per second, 0 for no limit
+	Persist   uint64  // Size of the persistence partition to add, 0 for none
+	Windows   bool    // Copy the ISO's files, as Windows ISOs need, instead of writing the image
+
+	Status      int
+	Progress    float64
+	ProgressTxt string
+	ETAText     string
+	Note        string // Why the job is paused, if it is
+	Error       string
+	Canceled    bool // Set by Cancel; the job stops at the next chunk
+	Wipe        bool // Wipe the partition table when stopping early
+}
+
+// newWriteJob returns a job for the device, ISO and options picked in the
+// wizard
+func newWriteJob(state *AppState) *WriteJob {
+	limit := parseSpeedLimit(state.speedLimit.Value)
+	return &WriteJob{
+		Device:    state.devices[state.selectedUSB],
+		ISOPath:   state.isoPath,
+		ISOSize:   state.isoSize,
+		Format:    state.formatUSBOpt,
+		RateLimit: float64(limit) * 1024 * 1024,
+		Persist:   persistChoice(state),
+		Windows:   state.isoWindows,
+	}
+}
+
+// isQueued reports whether a job for the device at path is queued
+func isQueued(state *AppState, path string) bool {
+	for _, job := range state.jobs {
+		if job.Device.Path == path {
+			return true
+		}
+	}
+	return false
+}
+
+// parseSpeedLimit returns the MB/s of a speed limit picked in the wizard
+func parseSpeedLimit(value string) int {
+	mbps, _ := strconv.Atoi(value)
+	return mbps
+}
+
+func speedLimitLabel(mbps int) string {
+	if mbps == 0 {
+		return "Unlimited"
+	}
+	return fmt.Sprintf("%d MB/s", mbps)
+}
+
+// runQueue writes every queued job, up to maxParallelWrites at a time when
+// parallel writing is on, then shows the results
+func runQueue(state *AppState, w invalidator) {
+	state.mu.Lock()
+	jobs := state.jobs
+	slots := 1
+	if state.parallel.Value {
+		slots = maxParallelWrites
+	}
+	state.mu.Unlock()
+
+	sem := make(chan struct{}, slots)
+	var wg sync.WaitGroup
+	for _, job := range jobs {
+		sem <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			writeJob(state, job, w)
+			<-sem
+		}()
+	}
+	wg.Wait()
+
+	state.mu.Lock()
+	if note := abortSummary(jobs); note != "" {
+		// Back to where the write was started, to try again
+		state.abortNote = note
+		state.jobs = nil
+		state.confirmCheck.Value = false
+		state.currentPage = PageConfirm
+		if state.selectedUSB < 0 {
+			state.currentPage = PageSelectUSB
+		}
+	} else {
+		state.currentPage = PageComplete
+	}
+	state.mu.Unlock()
+	w.Invalidate()
+}
+
+// cancelJobs stops every job that hasn't finished. Called with state.mu
+// held.
+func cancelJobs(state *AppState) {
+	for _, job := range state.jobs {
+		if job.Status == JobQueued || job.Status == JobWriting {
+			job.Canceled = true
+			job.Wipe = state.wipeCheck.Value
+		}
+	}
+}
+
+// abortSummary describes how each job ended when any was canceled, or
+// returns "" when none was
+func abortSummary(jobs []*WriteJob) string {
+	aborted := false
+	lines := make([]string, len(jobs))
+	for i, job := range jobs {
+		switch job.Status {
+		case JobCanceled:
+			aborted = true
+			lines[i] = job.Device.Path + ": " + job.Error
+		case JobDone:
+			lines[i] = job.Device.Path + ": Written"
+		default:
+			lines[i] = job.Device.Path + ": " + job.Error
+		}
+	}
+	if !aborted {
+		return ""
+	}
+	return strings.Join(lines, "\n")
+}
+
+// wipePartitionTable zeroes the start and end of a device, where its
+// partition tables are, so a partly written USB isn't mistaken for a
+// bootable one
+func wipePartitionTable(device *os.File, size uint64) error {
+	zeros := make([]byte, partAlign)
+	if _, err := device.WriteAt(zeros, 0); err != nil {
+		return err
+	}
+	if size > 2*partAlign {
+		// The backup GPT header
+		if _, err := device.WriteAt(zeros, int64(size-partAlign)); err != nil {
+			return err
+		}
+	}
+	return device.Sync()
+}
+
+func writeJob(state *AppState, job *WriteJob, w invalidator) {
+	dev := job.Device
+
+	addLog := func(msg string) {
+		state.mu.Lock()
+		state.statusLog = append(state.statusLog, fmt.Sprintf("[%s] %s", dev.Name, msg))
+		state.mu.Unlock()
+		w.Invalidate()
+	}
+
+	setProgress := func(p float64, text string) {
+		state.mu.Lock()
+		job.Progress = p
+		job.ProgressTxt = text
+		state.mu.Unlock()
+		w.Invalidate()
+	}
+
+	setETA := func(text string) {
+		state.mu.Lock()
+		job.ETAText = text
+		state.mu.Unlock()
+		w.Invalidate()
+	}
+
+	setNote := func(text string) {
+		state.mu.Lock()
+		job.Note = text
+		state.mu.Unlock()
+		w.Invalidate()
+		if text != "" {
+			addLog(text)
+		}
+	}
+
+	setError := func(err string) {
+		state.mu.Lock()
+		job.Error = err
+		job.Status = JobFailed
+		job.Note = ""
+		state.mu.Unlock()
+		w.Invalidate()
+		addLog(err)
+	}
+
+	canceled := func() bool {
+		state.mu.Lock()
+		defer state.mu.Unlock()
+		return job.Canceled
+	}
+
+	setAborted := func(msg string) {
+		state.mu.Lock()
+		job.Error = msg
+		job.Status = JobCanceled
+		job.Note = ""
+		state.mu.Unlock()
+		w.Invalidate()
+		addLog(msg)
+	}
+
+	if canceled() {
+		setAborted("Aborted before writing; the USB was not changed")
+		return
+	}
+
+	state.mu.Lock()
+	job.Status = JobWriting
+	state.mu.Unlock()
+
+	addLog("Starting write process...")
+	setProgress(0.02, "Preparing...")
+
+	// Format if requested
+	if job.Format {
+		addLog("Formatting USB...")
+		setProgress(0.05, "Formatting...")
+		if err := formatUSBDevice(dev); err != nil {
+			setError("Format failed: " + err.Error())
+			return
+		}
+		addLog("Format complete")
+	}
+
+	// Unmount
+	addLog("Unmounting device...")
+	setProgress(0.08, "Unmounting...")
+	partitions, _ := filepath.Glob(dev.Path + "*")
+	for _, part := range partitions {
+		exec.Command("umount", "-f", part).Run()
+	}
+	exec.Command("sync").Run()
+	time.Sleep(500 * time.Millisecond)
+
+	// report shows how much of total is written, every 500ms or so
+	startTime := time.Now()
+	lastUpdate := startTime
+	report := func(written, total int64, throttled bool) {
+		now := time.Now()
+		if now.Sub(lastUpdate) <= 500*time.Millisecond {
+			return
+		}
+		lastUpdate = now
+
+		progress := 0.1 + (float64(written)/float64(total))*0.85
+		progressText := fmt.Sprintf("%s / %s (%.1f%%)", formatSize(uint64(written)), formatSize(uint64(total)), progress*100)
+		setProgress(progress, progressText)
+
+		// Calculate ETA
+		elapsed := now.Sub(startTime).Seconds()
+		if elapsed > 0 && written > 0 {
+			speed := float64(written) / elapsed
+			remaining := float64(total - written)
+			etaSeconds := remaining / speed
+			speedMB := speed / (1024 * 1024)
+
+			throttledText := ""
+			if throttled {
+				throttledText = "  -  Throttled"
+			}
+			if etaSeconds < 60 {
+				setETA(fmt.Sprintf("Speed: %.1f MB/s  -  ETA: %d seconds%s", speedMB, int(etaSeconds), throttledText))
+			} else {
+				etaMinutes := etaSeconds / 60
+				setETA(fmt.Sprintf("Speed: %.1f MB/s  -  ETA: %.1f minutes%s", speedMB, etaMinutes, throttledText))
+			}
+		}
+	}
+
+	setDone := func() {
+		elapsed := time.Since(startTime)
+		setProgress(1.0, "Complete!")
+		setETA(fmt.Sprintf("Completed in %s", formatDuration(elapsed)))
+		addLog(fmt.Sprintf("Write completed in %s", formatDuration(elapsed)))
+
+		state.mu.Lock()
+		job.Status = JobDone
+		state.mu.Unlock()
+		w.Invalidate()
+	}
+
+	// Windows ISOs don't boot as a disk image, so their files are copied
+	if job.Windows {
+		addLog("Windows ISO: copying its files to a GPT + FAT32 USB...")
+		setProgress(0.1, "Copying...")
+		err := writeWindowsISO(dev, job.ISOPath, addLog, canceled, func(done, total int64) {
+			report(done, total, false)
+		})
+		switch {
+		case errors.Is(err, errWriteCanceled):
+			msg := "Aborted while copying files"
+			state.mu.Lock()
+			wipe := job.Wipe
+			state.mu.Unlock()
+			if wipe {
+				if err := wipeDevice(dev); err != nil {
+					msg += "; wiping the partition table failed (" + err.Error() + "), so format the USB before using it"
+				} else {
+					msg += "; the partition table was wiped"
+				}
+			}
+			exec.Command("partprobe", dev.Path).Run()
+			setAborted(msg)
+		case err != nil:
+			setError("Windows copy failed: " + err.Error())
+		default:
+			setDone()
+		}
+		return
+	}
+
+	setProgress(0.1, "Opening files...")
+
+	// Open ISO
+	info, err := os.Stat(job.ISOPath)
+	if err != nil {
+		setError("Cannot read ISO: " + err.Error())
+		return
+	}
+	totalSize := info.Size()
+
+	isoFile, err := os.Open(job.ISOPath)
+	if err != nil {
+		setError("Cannot open ISO: " + err.Error())
+		return
+	}
+	defer isoFile.Close()
+
+	// Open device
+	device, err := os.OpenFile(dev.Path, os.O_WRONLY|os.O_SYNC, 0)
+	if err != nil {
+		setError("Cannot open device: " + err.Error())
+		return
+	}
+	defer device.Close()
+
+	addLog("Writing ISO to USB...")
+	if job.RateLimit > 0 {
+		addLog(fmt.Sprintf("Speed limited to %.0f MB/s", job.RateLimit/(1024*1024)))
+	}
+	setProgress(0.1, "Writing...")
+
+	buffer := make([]byte, writeChunkSize)
+	t := newThrottle(dev, job.RateLimit, canceled)
+	var written int64
+	startTime = time.Now()
+
+	for {
+		if canceled() {
+			addLog("Canceling...")
+			setETA("Canceling...")
+			device.Sync()
+			msg := fmt.Sprintf("Aborted after writing %s of %s", formatSize(uint64(written)), formatSize(uint64(totalSize)))
+			state.mu.Lock()
+			wipe := job.Wipe
+			state.mu.Unlock()
+			if wipe {
+				if err := wipePartitionTable(device, dev.Size); err != nil {
+					msg += "; wiping the partition table failed (" + err.Error() + "), so format the USB before using it"
+				} else {
+					msg += "; the partition table was wiped"
+				}
+			} else {
+				msg += "; the USB may be half-bootable, so write or format it before using it"
+			}
+			syscall.Sync()
+			exec.Command("partprobe", dev.Path).Run()
+			setAborted(msg)
+			return
+		}
+
+		n, err := isoFile.Read(buffer)
+		if n > 0 {
+			if werr := t.write(device, buffer[:n], written, setNote); werr != nil {
+				// Canceled while paused; the check above aborts the job
+				if errors.Is(werr, errWriteCanceled) {
+					continue
+				}
+				setError("Write error: " + werr.Error())
+				return
+			}
+			written += int64(n)
+			report(written, totalSize, t.throttled)
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			setError("Read error: " + err.Error())
+			return
+		}
+	}
+
+	addLog("Syncing data...")
+	setProgress(0.96, "Syncing...")
+	setETA("Syncing to disk...")
+	device.Sync()
+	syscall.Sync()
+
+	if job.Persist > 0 {
+		addLog(fmt.Sprintf("Creating %s persistence partition...", formatSize(job.Persist)))
+		setProgress(0.98, "Creating persistent storage...")
+		device.Close()
+		if err := createPersistence(dev, uint64(totalSize), job.Persist); err != nil {
+			setError("Persistence failed: " + err.Error())
+			return
+		}
+		addLog("Persistent storage ready")
+	}
+
+	setDone()
+}
+
+// jobTitle names a job's device and ISO
+func jobTitle(job *WriteJob) string {
+	return fmt.Sprintf("%s %s (%s)  <-  %s", job.Device.Vendor, job.Device.Model, job.Device.Path, filepath.Base(job.ISOPath))
+}
+
+// jobPaths lists the jobs' devices
+func jobPaths(jobs []*WriteJob) string {
+	paths := make([]string, len(jobs))
+	for i, job := range jobs {
+		paths[i] = job.Device.Path
+	}
+	return strings.Join(paths, ", ")
+}
+
+// queueSummary lists the queued jobs, one per line
+func queueSummary(jobs []*WriteJob) string {
+	lines := make([]string, len(jobs))
+	for i, job := range jobs {
+		lines[i] = fmt.Sprintf("%s  -  %s", jobTitle(job), speedLimitLabel(int(job.RateLimit/(1024*1024))))
+		if job.Persist > 0 {
+			lines[i] += fmt.Sprintf("  -  %s persistence", formatSize(job.Persist))
+		}
+		if job.Windows {
+			lines[i] += "  -  Windows file copy"
+		}
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	writeChunkSize = 4 * 1024 * 1024 // Bytes per write while the device is healthy
+	minWriteChunk  = 256 * 1024      // Smallest write when throttled
+
+	// Write errors are counted over errorWindow. Two of them start
+	// throttling; maxDeviceErrors fail the job.
+	errorWindow       = 2 * time.Minute
+	throttleAfter     = 2
+	maxDeviceErrors   = 10
+	minErrorPause     = 5 * time.Second
+	maxErrorPause     = time.Minute
+	recoverAfterBytes = 256 * 1024 * 1024 // Clean writes before speeding up again
+
+	// Drives that report a temperature are paused above hotTemperature
+	// until they are back under coolTemperature, in degrees Celsius
+	hotTemperature      = 60
+	coolTemperature     = 50
+	temperatureInterval = 5 * time.Second
+
+	// How often a paused job checks whether it was canceled
+	cancelPollInterval = 250 * time.Millisecond
+)
+
+// throttle slows a job down when its device struggles. USB sticks that
+// overheat usually fail a growing number of writes before giving up
+// entirely; pausing and writing smaller blocks often lets them recover.
+type throttle struct {
+	device    USBDevice
+	rateLimit float64 // Bytes per second, 0 for none
+	canceled  func() bool
+
+	chunk     int         // Bytes per write
+	errors    []time.Time // Recent write errors
+	pause     time.Duration
+	clean     int // Bytes written since the last error
+	lastTemp  time.Time
+	throttled bool
+}
+
+func newThrottle(device USBDevice, rateLimit float64, canceled func() bool) *throttle {
+	return &throttle{device: device, rateLimit: rateLimit, canceled: canceled, chunk: writeChunkSize}
+}
+
+// write writes data to the device at offset. Failed writes are retried
+// after a pause; the error is returned once the device keeps failing, or
+// errWriteCanceled if the job is canceled while paused. notify is told why
+// the job is paused, or "" once it goes on.
+func (t *throttle) write(device *os.File, data []byte, offset int64, notify func(string)) error {
+	for len(data) > 0 {
+		if err := t.checkTemperature(notify); err != nil {
+			return err
+		}
+
+		size := min(len(data), t.chunk)
+		start := time.Now()
+		if _, err := device.WriteAt(data[:size], offset); err != nil {
+			if !t.failed(time.Now()) {
+				return fmt.Errorf("%v (%d write errors within %d minutes)", err, len(t.errors), int(errorWindow.Minutes()))
+			}
+			notify(fmt.Sprintf("Write error, pausing %s: %v", t.pause, err))
+			if err := t.wait(t.pause); err != nil {
+				return err
+			}
+			notify("")
+			continue
+		}
+		t.succeeded(size)
+		if err := t.limit(size, time.Since(start)); err != nil {
+			return err
+		}
+
+		data = data[size:]
+		offset += int64(size)
+	}
+	return nil
+}
+
+// failed records a write error and reports whether to retry
+func (t *throttle) failed(now time.Time) bool {
+	recent := t.errors[:0]
+	for _, at := range t.errors {
+		if now.Sub(at) < errorWindow {
+			recent = append(recent, at)
+		}
+	}
+	t.errors = append(recent, now)
+	t.clean = 0
+
+	if len(t.errors) >= maxDeviceErrors {
+		return false
+	}
+	if len(t.errors) < throttleAfter {
+		// A single error is retried after a moment
+		t.pause = time.Second
+		return true
+	}
+	t.throttled = true
+	t.chunk = max(t.chunk/2, minWriteChunk)
+	t.pause = min(max(t.pause*2, minErrorPause), maxErrorPause)
+	return true
+}
+
+// succeeded lets a throttled job speed up again after enough clean writes
+func (t *throttle) succeeded(size int) {
+	t.clean += size
+	if !t.throttled || t.clean < recoverAfterBytes {
+		return
+	}
+	t.clean = 0
+	t.chunk = min(t.chunk*2, writeChunkSize)
+	t.pause /= 2
+	if t.chunk == writeChunkSize {
+		t.throttled = false
+		t.pause = 0
+	}
+}
+
+// limit sleeps long enough to keep to the job's speed limit
+func (t *throttle) limit(size int, took time.Duration) error {
+	if t.rateLimit <= 0 {
+		return nil
+	}
+	want := time.Duration(float64(size) / t.rateLimit * float64(time.Second))
+	if want > took {
+		return t.wait(want - took)
+	}
+	return nil
+}
+
+// wait sleeps for d, or returns errWriteCanceled as soon as the job is
+// canceled
+func (t *throttle) wait(d time.Duration) error {
+	deadline := time.Now().Add(d)
+	for !t.canceled() {
+		left := time.Until(deadline)
+		if left <= 0 {
+			return nil
+		}
+		time.Sleep(min(left, cancelPollInterval))
+	}
+	return errWriteCanceled
+}
+
+// checkTemperature waits for a hot drive to cool down. Only drives whose
+// temperature the kernel can read, such as SSDs in USB enclosures, have
+// one; sticks don't.
+func (t *throttle) checkTemperature(notify func(string)) error {
+	if time.Since(t.lastTemp) < temperatureInterval {
+		return nil
+	}
+	t.lastTemp = time.Now()
+
+	temp, ok := deviceTemperature(t.device.Name)
+	if !ok || temp < hotTemperature {
+		return nil
+	}
+	for ok && temp >= coolTemperature {
+		notify(fmt.Sprintf("Drive at %d°C, pausing until it cools below %d°C", temp, coolTemperature))
+		if err := t.wait(temperatureInterval); err != nil {
+			return err
+		}
+		temp, ok = deviceTemperature(t.device.Name)
+	}
+	notify("")
+	return nil
+}
+
+// deviceTemperature returns a drive's temperature in degrees Celsius from
+// its hwmon sensor (the drivetemp driver), if it has one
+func deviceTemperature(name string) (int, bool) {
+	inputs, _ := filepath.Glob(filepath.Join("/sys/block", name, "device/hwmon/hwmon*/temp1_input"))
+	for _, input := range inputs {
+		data, err := os.ReadFile(input)
+		if err != nil {
+			continue
+		}
+		// Millidegrees
+		if milli, err := strconv.Atoi(strings.TrimSpace(string(data))); err == nil {
+			return milli / 1000, true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,424 @@
+package main
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gioui.org/app"
+	"gioui.org/font"
+	"gioui.org/layout"
+	"gioui.org/unit"
+	"gioui.org/widget"
+	"gioui.org/widget/material"
+)
+
+// Verification states
+const (
+	VerifyNone = iota
+	VerifyRunning
+	VerifyHashed   // Hashed, with no checksum to compare against
+	VerifyPassed   // The checksum matches
+	VerifyMismatch // The checksum doesn't match; writing is blocked
+	VerifyFailed   // The ISO couldn't be read, or the checksum is malformed
+)
+
+// Signature states
+const (
+	SigNone      = iota // No signature next to the ISO
+	SigGood             // Signed with a trusted key
+	SigBad              // The signature doesn't match; writing is blocked
+	SigUnchecked        // gpg or the signing key is missing
+)
+
+// ravenKeyring holds the key RavenLinux ISOs are signed with. Without it
+// signatures are checked against the user's own keyring.
+const ravenKeyring = "/usr/share/raven/keys/ravenlinux.gpg"
+
+// Checksum files published next to ISOs, besides <iso>.sha256
+var checksumFiles = []string{"SHA256SUMS", "sha256sums.txt"}
+
+// ISOVerification is what is known about an ISO's checksum and signature.
+// Guarded by AppState.mu.
+type ISOVerification struct {
+	ISOPath   string
+	Status    int
+	Progress  float64
+	SHA256    string
+	Expected  string
+	Source    string // The checksum file Expected was read from, if any
+	SigStatus int
+	SigDetail string
+	Error     string
+}
+
+// loadChecksum starts a verification of the ISO picked in the wizard,
+// filling in the checksum published next to it. Called with state.mu held.
+func loadChecksum(state *AppState) {
+	expected, source := findChecksum(state.isoPath)
+	state.verify = &ISOVerification{ISOPath: state.isoPath, Expected: expected, Source: source}
+	state.checksumEdit.SetText(expected)
+}
+
+// findChecksum looks for the ISO's SHA256 in a checksum file next to it,
+// in sha256sum's "<hash>  <name>" format
+func findChecksum(isoPath string) (string, string) {
+	dir, name := filepath.Split(isoPath)
+	candidates := []string{isoPath + ".sha256"}
+	for _, file := range checksumFiles {
+		candidates = append(candidates, filepath.Join(dir, file))
+	}
+
+	for _, candidate := range candidates {
+		f, err := os.Open(candidate)
+		if err != nil {
+			continue
+		}
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			fields := strings.Fields(scanner.Text())
+			if len(fields) == 0 || !validChecksum(fields[0]) {
+				continue
+			}
+			// A file named after the ISO may hold just the hash
+			if len(fields) == 1 && candidate == isoPath+".sha256" ||
+				len(fields) >= 2 && filepath.Base(strings.TrimPrefix(fields[1], "*")) == name {
+				f.Close()
+				return strings.ToLower(fields[0]), candidate
+			}
+		}
+		f.Close()
+	}
+	return "", ""
+}
+
+// validChecksum reports whether s is a SHA256 in hex
+func validChecksum(s string) bool {
+	if len(s) != sha256.Size*2 {
+		return false
+	}
+	_, err := hex.DecodeString(s)
+	return err == nil
+}
+
+// normalizeChecksum takes the hash from a pasted checksum, which may be a
+// whole line of a checksum file
+func normalizeChecksum(s string) string {
+	fields := strings.Fields(s)
+	if len(fields) == 0 {
+		return ""
+	}
+	return strings.ToLower(strings.TrimPrefix(fields[0], "sha256:"))
+}
+
+// startVerify hashes the ISO in the background and compares it with the
+// expected checksum, then checks the signature. Called with state.mu held.
+func startVerify(state *AppState, w *app.Window) {
+	v := state.verify
+	if v == nil || v.ISOPath != state.isoPath {
+		loadChecksum(state)
+		v = state.verify
+	}
+	expected := normalizeChecksum(state.checksumEdit.Text())
+	if expected != v.Expected {
+		v.Expected, v.Source = expected, ""
+	}
+	if expected != "" && !validChecksum(expected) {
+		v.Status = VerifyFailed
+		v.Error = "The expected checksum isn't a SHA256 (64 hex digits)"
+		return
+	}
+	v.Status, v.Progress, v.Error = VerifyRunning, 0, ""
+	source := v.Source
+
+	go func() {
+		sum, err := hashFile(v.ISOPath, func(p float64) {
+			state.mu.Lock()
+			v.Progress = p
+			state.mu.Unlock()
+			w.Invalidate()
+		})
+
+		// Prefer the ISO's own signature, then the checksum file's
+		sigStatus, sigDetail := SigNone, ""
+		if err == nil {
+			if sig := findSignature(v.ISOPath); sig != "" {
+				sigStatus, sigDetail = checkSignature(sig, v.ISOPath)
+			} else if source != "" {
+				if sig := findSignature(source); sig != "" {
+					sigStatus, sigDetail = checkSignature(sig, source)
+				}
+			}
+		}
+
+		state.mu.Lock()
+		switch {
+		case err != nil:
+			v.Status, v.Error = VerifyFailed, err.Error()
+		case expected == "":
+			v.Status = VerifyHashed
+		case sum == expected:
+			v.Status = VerifyPassed
+		default:
+			v.Status = VerifyMismatch
+		}
+		v.SHA256 = sum
+		v.SigStatus, v.SigDetail = sigStatus, sigDetail
+		state.mu.Unlock()
+		w.Invalidate()
+	}()
+}
+
+// hashFile returns the SHA256 of the file at path, telling progress how
+// much of it is read
+func hashFile(path string, progress func(float64)) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return "", err
+	}
+
+	h := sha256.New()
+	buffer := make([]byte, writeChunkSize)
+	var read int64
+	lastUpdate := time.Now()
+	for {
+		n, err := f.Read(buffer)
+		h.Write(buffer[:n])
+		read += int64(n)
+		if time.Since(lastUpdate) > 200*time.Millisecond && info.Size() > 0 {
+			lastUpdate = time.Now()
+			progress(float64(read) / float64(info.Size()))
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", err
+		}
+	}
+	progress(1)
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// findSignature returns the detached signature next to path, if any
+func findSignature(path string) string {
+	for _, ext := range []string{".sig", ".asc", ".gpg"} {
+		if _, err := os.Stat(path + ext); err == nil {
+			return path + ext
+		}
+	}
+	return ""
+}
+
+// checkSignature verifies a detached signature of data with gpg, against
+// the RavenLinux key when it is installed
+func checkSignature(sig, data string) (int, string) {
+	if _, err := exec.LookPath("gpg"); err != nil {
+		return SigUnchecked, "gpg is not installed, so " + filepath.Base(sig) + " was not checked"
+	}
+	args := []string{"--batch", "--status-fd", "1"}
+	if _, err := os.Stat(ravenKeyring); err == nil {
+		args = append(args, "--no-default-keyring", "--keyring", ravenKeyring)
+	}
+	args = append(args, "--verify", sig, data)
+	out, _ := exec.Command("gpg", args...).Output()
+
+	// "[GNUPG:] GOODSIG <key id> <user id>"
+	for _, line := range strings.Split(string(out), "\n") {
+		fields := strings.Fields(strings.TrimPrefix(line, "[GNUPG:] "))
+		if len(fields) == 0 {
+			continue
+		}
+		switch fields[0] {
+		case "BADSIG":
+			return SigBad, "Bad signature in " + filepath.Base(sig)
+		case "GOODSIG":
+			signer := "an unknown key"
+			if len(fields) > 2 {
+				signer = strings.Join(fields[2:], " ")
+			}
+			return SigGood, "Good signature from " + signer
+		case "NO_PUBKEY":
+			return SigUnchecked, "The key that signed " + filepath.Base(sig) + " isn't installed"
+		}
+	}
+	return SigUnchecked, "gpg could not check " + filepath.Base(sig)
+}
+
+// verifyBlocks reports whether the ISO failed verification, or is being
+// verified, so it must not be written
+func verifyBlocks(state *AppState) bool {
+	v := state.verify
+	if v == nil || v.ISOPath != state.isoPath {
+		return false
+	}
+	return v.Status == VerifyRunning || v.Status == VerifyMismatch || v.SigStatus == SigBad
+}
+
+// verifySummary describes the verification for the Confirm page
+func verifySummary(state *AppState) string {
+	v := state.verify
+	if v == nil || v.ISOPath != state.isoPath {
+		return "Not verified"
+	}
+	summary := "Not verified"
+	switch v.Status {
+	case VerifyHashed:
+		summary = "Hashed, no checksum to compare"
+	case VerifyPassed:
+		summary = "SHA256 matches"
+	}
+	if v.SigStatus == SigGood {
+		summary += ", signature good"
+	}
+	return summary
+}
+
+// drawVerify draws the checksum to compare against, the Verify button and
+// the outcome
+func drawVerify(gtx layout.Context, th *material.Theme, state *AppState) layout.Dimensions {
+	v := state.verify
+	if v != nil && v.ISOPath != state.isoPath {
+		v = nil
+	}
+
+	// A result is for the checksum it was compared against
+	for {
+		ev, ok := state.checksumEdit.Update(gtx)
+		if !ok {
+			break
+		}
+		if _, changed := ev.(widget.ChangeEvent); changed && v != nil && v.Status != VerifyRunning &&
+			normalizeChecksum(state.checksumEdit.Text()) != v.Expected {
+			v.Status, v.SigStatus = VerifyNone, SigNone
+		}
+	}
+
+	return widget.Border{
+		Color: colorSurface,
+		Width: unit.Dp(1),
+	}.Layout(gtx, func(gtx layout.Context) layout.Dimensions {
+		return layout.UniformInset(unit.Dp(15)).Layout(gtx, func(gtx layout.Context) layout.Dimensions {
+			return layout.Flex{Axis: layout.Vertical}.Layout(gtx,
+				layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+					lbl := material.Caption(th, "Verify ISO (Optional)")
+					lbl.Color = colorPrimary
+					lbl.Font.Weight = font.Bold
+					return lbl.Layout(gtx)
+				}),
+				layout.Rigid(layout.Spacer{Height: unit.Dp(8)}.Layout),
+				layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+					return layout.Flex{Axis: layout.Horizontal, Alignment: layout.Middle}.Layout(gtx,
+						layout.Flexed(1, func(gtx layout.Context) layout.Dimensions {
+							return widget.Border{
+								Color: colorSurface,
+								Width: unit.Dp(1),
+							}.Layout(gtx, func(gtx layout.Context) layout.Dimensions {
+								return layout.UniformInset(unit.Dp(8)).Layout(gtx, func(gtx layout.Context) layout.Dimensions {
+									ed := material.Editor(th, &state.checksumEdit, "Expected SHA256 (paste from the download page)")
+									ed.Color = colorTextBright
+									ed.HintColor = colorDisabled
+									return ed.Layout(gtx)
+								})
+							})
+						}),
+						layout.Rigid(layout.Spacer{Width: unit.Dp(10)}.Layout),
+						layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+							label := "Verify"
+							if v != nil && v.Status == VerifyRunning {
+								label = "Verifying..."
+								gtx = gtx.Disabled()
+							}
+							btn := material.Button(th, &state.verifyBtn, label)
+							btn.Background = colorPrimary
+							return btn.Layout(gtx)
+						}),
+					)
+				}),
+				layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+					if v == nil || v.Source == "" {
+						return layout.Dimensions{}
+					}
+					lbl := material.Caption(th, "Checksum from "+filepath.Base(v.Source))
+					lbl.Color = colorText
+					return layout.Inset{Top: unit.Dp(5)}.Layout(gtx, lbl.Layout)
+				}),
+				layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+					if v == nil || v.Status == VerifyNone {
+						return layout.Dimensions{}
+					}
+					return layout.Inset{Top: unit.Dp(8)}.Layout(gtx, func(gtx layout.Context) layout.Dimensions {
+						return drawVerifyResult(gtx, th, v)
+					})
+				}),
+			)
+		})
+	})
+}
+
+// drawVerifyResult shows the hashing progress, or whether the checksum
+// and signature match
+func drawVerifyResult(gtx layout.Context, th *material.Theme, v *ISOVerification) layout.Dimensions {
+	if v.Status == VerifyRunning {
+		return layout.Flex{Axis: layout.Vertical}.Layout(gtx,
+			layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+				return drawProgressBar(gtx, v.Progress)
+			}),
+			layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+				lbl := material.Caption(th, fmt.Sprintf("Computing SHA256... %.0f%%", v.Progress*100))
+				lbl.Color = colorText
+				return layout.Inset{Top: unit.Dp(4)}.Layout(gtx, lbl.Layout)
+			}),
+		)
+	}
+
+	msg, msgColor := "", colorText
+	switch v.Status {
+	case VerifyHashed:
+		msg, msgColor = "SHA256: "+v.SHA256+"\nNo checksum to compare against", colorWarning
+	case VerifyPassed:
+		msg, msgColor = "✓ SHA256 matches", colorSuccess
+	case VerifyMismatch:
+		msg, msgColor = "✗ SHA256 does not match! The ISO is corrupt or has been tampered with; download it again.\nGot "+v.SHA256, colorDanger
+	case VerifyFailed:
+		msg, msgColor = "Verification failed: "+v.Error, colorDanger
+	}
+
+	sig, sigColor := "", colorText
+	switch v.SigStatus {
+	case SigGood:
+		sig, sigColor = "✓ "+v.SigDetail, colorSuccess
+	case SigBad:
+		sig, sigColor = "✗ "+v.SigDetail+"! Do not write this ISO.", colorDanger
+	case SigUnchecked:
+		sig, sigColor = v.SigDetail, colorWarning
+	}
+
+	return layout.Flex{Axis: layout.Vertical}.Layout(gtx,
+		layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+			lbl := material.Body2(th, msg)
+			lbl.Color = msgColor
+			return lbl.Layout(gtx)
+		}),
+		layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+			if sig == "" {
+				return layout.Dimensions{}
+			}
+			lbl := material.Body2(th, sig)
+			lbl.Color = sigColor
+			return layout.Inset{Top: unit.Dp(4)}.Layout(gtx, lbl.Layout)
+		}),
+	)
+}
@@ -0,0 +1,344 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gioui.org/app"
+)
+
+// Windows ISOs don't boot when written as a disk image. Their files are
+// copied onto a FAT32 partition of a GPT drive instead, which UEFI
+// firmware boots from directly.
+const (
+	windowsBootLabel    = "WINUSB"
+	windowsInstallLabel = "WININSTALL"
+	windowsBootSize     = "1025MiB" // End of the boot partition in NTFS mode
+	fat32MaxFile        = 1<<32 - 1
+	wimSplitSize        = "3800" // MiB per part when splitting install.wim
+)
+
+// The image Windows Setup installs, which outgrows FAT32 in recent releases
+const installWim = "sources/install.wim"
+
+var errWriteCanceled = errors.New("canceled")
+
+// checkWindowsISO finds out in the background whether the ISO picked in
+// the wizard is a Windows installer. Called with state.mu held.
+func checkWindowsISO(state *AppState, w *app.Window) {
+	path := state.isoPath
+	state.isoWindows = false
+	go func() {
+		windows := isWindowsISO(path)
+		state.mu.Lock()
+		if state.isoPath == path {
+			state.isoWindows = windows
+		}
+		state.mu.Unlock()
+		w.Invalidate()
+	}()
+}
+
+// isWindowsISO reports whether the ISO at path holds Windows Setup
+func isWindowsISO(path string) bool {
+	mount, err := mountISO(path)
+	if err != nil {
+		return false
+	}
+	defer unmountData(mount)
+	if findFold(mount, "sources/boot.wim") == "" {
+		return false
+	}
+	for _, image := range []string{installWim, "sources/install.esd", "sources/install.swm"} {
+		if findFold(mount, image) != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// writeModeLabel describes how an ISO is written, for the Confirm page
+func writeModeLabel(windows bool) string {
+	if windows {
+		return "Windows file copy (GPT + FAT32, UEFI)"
+	}
+	return "Disk image"
+}
+
+// writeWindowsISO partitions dev as GPT and copies the Windows ISO onto
+// it. An install.wim too large for FAT32 is split with wimlib; without
+// wimlib the whole ISO is also copied to a second, NTFS partition, which
+// Setup installs from.
+func writeWindowsISO(dev USBDevice, isoPath string, log func(string), canceled func() bool, progress func(done, total int64)) error {
+	iso, err := mountISO(isoPath)
+	if err != nil {
+		return err
+	}
+	defer unmountData(iso)
+
+	var total int64
+	var large []string
+	err = filepath.WalkDir(iso, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		total += info.Size()
+		if info.Size() > fat32MaxFile {
+			rel, _ := filepath.Rel(iso, path)
+			large = append(large, filepath.ToSlash(rel))
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// Pick how to deal with files FAT32 can't hold
+	wim := findFold(iso, installWim)
+	wimRel, _ := filepath.Rel(iso, wim)
+	wimRel = filepath.ToSlash(wimRel)
+	split, ntfs := false, false
+	for _, rel := range large {
+		if !strings.EqualFold(rel, installWim) {
+			return fmt.Errorf("%s is larger than FAT32 allows", rel)
+		}
+		if _, err := exec.LookPath("wimlib-imagex"); err == nil {
+			split = true
+		} else if _, err := exec.LookPath("mkfs.ntfs"); err == nil {
+			ntfs = true
+		} else {
+			return fmt.Errorf("%s is larger than 4 GB: install wimlib to split it, or ntfs-3g to copy it to NTFS", installWim)
+		}
+	}
+
+	// Partition and format
+	partitions, _ := filepath.Glob(dev.Path + "*")
+	for _, part := range partitions {
+		exec.Command("umount", "-f", part).Run()
+	}
+	args := []string{"-s", dev.Path, "mklabel", "gpt"}
+	if ntfs {
+		log("install.wim is larger than 4 GB; copying the ISO to an NTFS partition too")
+		if info, err := os.Stat(wim); err == nil {
+			total = 2*total - info.Size()
+		}
+		args = append(args, "mkpart", windowsBootLabel, "fat32", "1MiB", windowsBootSize,
+			"mkpart", windowsInstallLabel, "ntfs", windowsBootSize, "100%")
+	} else {
+		args = append(args, "mkpart", windowsBootLabel, "fat32", "1MiB", "100%")
+	}
+	if out, err := exec.Command("parted", args...).CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to partition %s: %v: %s", dev.Path, err, strings.TrimSpace(string(out)))
+	}
+	exec.Command("partprobe", dev.Path).Run()
+	time.Sleep(2 * time.Second)
+
+	boot := partitionPath(dev, 1)
+	if out, err := exec.Command("mkfs.vfat", "-F", "32", "-n", windowsBootLabel, boot).CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to format %s: %v: %s", boot, err, strings.TrimSpace(string(out)))
+	}
+	bootMount, err := mountPartition(boot)
+	if err != nil {
+		return err
+	}
+	defer unmountData(bootMount)
+
+	var installMount string
+	if ntfs {
+		install := partitionPath(dev, 2)
+		if out, err := exec.Command("mkfs.ntfs", "-Q", "-L", windowsInstallLabel, install).CombinedOutput(); err != nil {
+			return fmt.Errorf("failed to format %s: %v: %s", install, err, strings.TrimSpace(string(out)))
+		}
+		if installMount, err = mountPartition(install); err != nil {
+			return err
+		}
+		defer unmountData(installMount)
+	}
+
+	// Copy, leaving install.wim out of the FAT32 partition when it doesn't fit
+	var done int64
+	report := func(n int64) {
+		done += n
+		progress(done, total)
+	}
+	var skip string
+	if split || ntfs {
+		skip = wimRel
+	}
+	log("Copying files...")
+	if err := copyTree(iso, bootMount, skip, canceled, report); err != nil {
+		return err
+	}
+
+	switch {
+	case split:
+		log("Splitting install.wim into FAT32-sized parts...")
+		dst := filepath.Join(bootMount, filepath.Dir(wimRel), "install.swm")
+		if err := runCancelable(canceled, "wimlib-imagex", "split", wim, dst, wimSplitSize); err != nil {
+			return fmt.Errorf("failed to split install.wim: %w", err)
+		}
+		if info, err := os.Stat(wim); err == nil {
+			report(info.Size())
+		}
+	case ntfs:
+		log("Copying files to the NTFS partition...")
+		if err := copyTree(iso, installMount, "", canceled, report); err != nil {
+			return err
+		}
+	}
+
+	log("Syncing data...")
+	exec.Command("sync").Run()
+	return nil
+}
+
+// copyTree copies the files under src into dst, except skip, a path
+// relative to src
+func copyTree(src, dst, skip string, canceled func() bool, report func(int64)) error {
+	return filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(src, path)
+		if skip != "" && filepath.ToSlash(rel) == skip {
+			return nil
+		}
+		target := filepath.Join(dst, rel)
+		if d.IsDir() {
+			return os.MkdirAll(target, 0755)
+		}
+		return copyFile(path, target, canceled, report)
+	})
+}
+
+func copyFile(src, dst string, canceled func() bool, report func(int64)) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	buffer := make([]byte, writeChunkSize)
+	for {
+		if canceled() {
+			return errWriteCanceled
+		}
+		n, err := in.Read(buffer)
+		if n > 0 {
+			if _, werr := out.Write(buffer[:n]); werr != nil {
+				return werr
+			}
+			report(int64(n))
+		}
+		if err == io.EOF {
+			return out.Close()
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// runCancelable runs a command, killing it when canceled reports true
+func runCancelable(canceled func() bool, name string, args ...string) error {
+	cmd := exec.Command(name, args...)
+	var output strings.Builder
+	cmd.Stdout, cmd.Stderr = &output, &output
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	exited := make(chan error, 1)
+	go func() { exited <- cmd.Wait() }()
+
+	ticker := time.NewTicker(500 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		select {
+		case err := <-exited:
+			if err != nil {
+				return fmt.Errorf("%v: %s", err, strings.TrimSpace(output.String()))
+			}
+			return nil
+		case <-ticker.C:
+			if canceled() {
+				cmd.Process.Kill()
+				<-exited
+				return errWriteCanceled
+			}
+		}
+	}
+}
+
+// wipeDevice wipes the partition table of a device that isn't open
+func wipeDevice(dev USBDevice) error {
+	device, err := os.OpenFile(dev.Path, os.O_WRONLY, 0)
+	if err != nil {
+		return err
+	}
+	defer device.Close()
+	return wipePartitionTable(device, dev.Size)
+}
+
+// findFold returns the file at rel under root, matching names without
+// regard to case as Windows does, or "" when there is none
+func findFold(root, rel string) string {
+	path := root
+	for _, name := range strings.Split(rel, "/") {
+		entries, err := os.ReadDir(path)
+		if err != nil {
+			return ""
+		}
+		found := ""
+		for _, entry := range entries {
+			if strings.EqualFold(entry.Name(), name) {
+				found = entry.Name()
+				break
+			}
+		}
+		if found == "" {
+			return ""
+		}
+		path = filepath.Join(path, found)
+	}
+	return path
+}
+
+func mountISO(path string) (string, error) {
+	mount, err := os.MkdirTemp("", "raven-usb-iso-")
+	if err != nil {
+		return "", err
+	}
+	if out, err := exec.Command("mount", "-o", "loop,ro", path, mount).CombinedOutput(); err != nil {
+		os.Remove(mount)
+		return "", fmt.Errorf("failed to mount %s: %v: %s", filepath.Base(path), err, strings.TrimSpace(string(out)))
+	}
+	return mount, nil
+}
+
+func mountPartition(part string) (string, error) {
+	mount, err := os.MkdirTemp("", "raven-usb-part-")
+	if err != nil {
+		return "", err
+	}
+	if out, err := exec.Command("mount", part, mount).CombinedOutput(); err != nil {
+		os.Remove(mount)
+		return "", fmt.Errorf("failed to mount %s: %v: %s", part, err, strings.TrimSpace(string(out)))
+	}
+	return mount, nil
+}
@@ -8,16 +8,18 @@ import (
 
 	"gioui.org/layout"
 	"gioui.org/widget"
+
+	"raven-wifi/wifi"
 )
 
 // AppState holds all application state for the Gio UI
 type AppState struct {
 	// WiFi backend
-	wifi *WiFiManager
+	wifi *wifi.Manager
 
 	// Network data (protected by mutex)
 	mu          sync.Mutex
-	networks    []Network
+	networks    []wifi.Network
 	currentSSID string // Currently connected SSID
 
 	// UI state
@@ -70,7 +72,7 @@ func NewAppState() *AppState {
 	cfg := LoadConfig()
 
 	s := &AppState{
-		wifi:          NewWiFiManager(),
+		wifi:          wifi.NewManager(),
 		config:        cfg,
 		networkClicks: make(map[int]*widget.Clickable),
 		savedDeletes:  make(map[string]*widget.Clickable),
@@ -151,7 +153,7 @@ func (s *AppState) refreshNetworks() {
 }
 
 // handleNetworkClick handles a click on a network item
-func (s *AppState) handleNetworkClick(net Network) {
+func (s *AppState) handleNetworkClick(net wifi.Network) {
 	// Do nothing if already connected to this network
 	if net.Connected {
 		return
@@ -11,6 +11,8 @@ import (
 	"gioui.org/widget"
 	"gioui.org/widget/material"
 	"golang.org/x/exp/shiny/materialdesign/icons"
+
+	"raven-wifi/wifi"
 )
 
 // Type aliases for convenience
@@ -119,7 +121,7 @@ func (s *AppState) layoutStatusCard(gtx C, th *Theme) D {
 // layoutNetworkList renders the scrollable list of networks
 func (s *AppState) layoutNetworkList(gtx C, th *Theme) D {
 	s.mu.Lock()
-	networks := make([]Network, len(s.networks))
+	networks := make([]wifi.Network, len(s.networks))
 	copy(networks, s.networks)
 	s.mu.Unlock()
 
@@ -131,7 +133,7 @@ func (s *AppState) layoutNetworkList(gtx C, th *Theme) D {
 }
 
 // layoutNetworkItem renders a single network list item
-func (s *AppState) layoutNetworkItem(gtx C, th *Theme, index int, net Network) D {
+func (s *AppState) layoutNetworkItem(gtx C, th *Theme, index int, net wifi.Network) D {
 	click := s.getNetworkClickable(index)
 
 	// Handle click
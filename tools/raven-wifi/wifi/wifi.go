@@ -1,4 +1,6 @@
-package main
+// Package wifi drives the iwd or wpa_supplicant backend for raven-wifi and
+// the Network page of raven-settings-menu
+package wifi
 
 import (
 	"bufio"
@@ -12,6 +14,9 @@ import (
 	"time"
 )
 
+// wpa_supplicant's configuration, holding the saved networks
+const wpaConfigPath = "/etc/wpa_supplicant/wpa_supplicant.conf"
+
 // Network represents a WiFi network
 type Network struct {
 	SSID      string
@@ -30,21 +35,31 @@ type ConnectionStatus struct {
 	Interface string
 }
 
-// WiFiManager handles WiFi operations
-type WiFiManager struct {
+// Manager handles WiFi operations
+type Manager struct {
 	iface   string
 	backend string // "iwd" or "wpa"
 }
 
-// NewWiFiManager creates a new WiFi manager
-func NewWiFiManager() *WiFiManager {
-	wm := &WiFiManager{}
+// NewManager creates a new WiFi manager
+func NewManager() *Manager {
+	wm := &Manager{}
 	wm.detectBackend()
 	wm.detectInterface()
 	return wm
 }
 
-func (wm *WiFiManager) detectBackend() {
+// Interface returns the wireless interface, e.g. wlan0
+func (wm *Manager) Interface() string {
+	return wm.iface
+}
+
+// Backend returns "iwd", "wpa" or "none"
+func (wm *Manager) Backend() string {
+	return wm.backend
+}
+
+func (wm *Manager) detectBackend() {
 	// Prefer iwd if available AND daemon is running
 	if _, err := exec.LookPath("iwctl"); err == nil && isIWDRunning() && isDBusRunning() {
 		wm.backend = "iwd"
@@ -86,7 +101,7 @@ func isDBusRunning() bool {
 	return false
 }
 
-func (wm *WiFiManager) detectInterface() {
+func (wm *Manager) detectInterface() {
 	// Try to find wireless interface
 	interfaces := []string{"wlan0", "wlp2s0", "wlp3s0", "wifi0"}
 
@@ -111,7 +126,7 @@ func (wm *WiFiManager) detectInterface() {
 }
 
 // Scan scans for available WiFi networks
-func (wm *WiFiManager) Scan() ([]Network, error) {
+func (wm *Manager) Scan() ([]Network, error) {
 	switch wm.backend {
 	case "iwd":
 		return wm.scanIWD()
@@ -122,7 +137,7 @@ func (wm *WiFiManager) Scan() ([]Network, error) {
 	}
 }
 
-func (wm *WiFiManager) scanIWD() ([]Network, error) {
+func (wm *Manager) scanIWD() ([]Network, error) {
 	// Trigger scan
 	exec.Command("iwctl", "station", wm.iface, "scan").Run()
 	time.Sleep(2 * time.Second)
@@ -208,7 +223,7 @@ func (wm *WiFiManager) scanIWD() ([]Network, error) {
 	return networks, nil
 }
 
-func (wm *WiFiManager) scanWPA() ([]Network, error) {
+func (wm *Manager) scanWPA() ([]Network, error) {
 	// Trigger scan
 	exec.Command("wpa_cli", "-i", wm.iface, "scan").Run()
 	time.Sleep(2 * time.Second)
@@ -271,7 +286,7 @@ func (wm *WiFiManager) scanWPA() ([]Network, error) {
 	return networks, nil
 }
 
-func (wm *WiFiManager) scanIW() ([]Network, error) {
+func (wm *Manager) scanIW() ([]Network, error) {
 	// Use raw iw command as fallback
 	exec.Command("ip", "link", "set", wm.iface, "up").Run()
 
@@ -339,7 +354,7 @@ func (wm *WiFiManager) scanIW() ([]Network, error) {
 }
 
 // Connect connects to a WiFi network
-func (wm *WiFiManager) Connect(ssid, password string) error {
+func (wm *Manager) Connect(ssid, password string) error {
 	switch wm.backend {
 	case "iwd":
 		return wm.connectIWD(ssid, password)
@@ -350,7 +365,7 @@ func (wm *WiFiManager) Connect(ssid, password string) error {
 	}
 }
 
-func (wm *WiFiManager) connectIWD(ssid, password string) error {
+func (wm *Manager) connectIWD(ssid, password string) error {
 	if password != "" {
 		// For networks requiring password, iwd will prompt or use stored credentials
 		// We need to store the passphrase first
@@ -380,7 +395,7 @@ func (wm *WiFiManager) connectIWD(ssid, password string) error {
 	return nil
 }
 
-func (wm *WiFiManager) connectWPA(ssid, password string) error {
+func (wm *Manager) connectWPA(ssid, password string) error {
 	// Generate wpa_supplicant config
 	var config string
 	if password != "" {
@@ -395,7 +410,7 @@ func (wm *WiFiManager) connectWPA(ssid, password string) error {
 	}
 
 	// Write config
-	configPath := "/etc/wpa_supplicant/wpa_supplicant.conf"
+	configPath := wpaConfigPath
 	baseConfig := "ctrl_interface=/run/wpa_supplicant\nupdate_config=1\n\n"
 
 	if err := os.WriteFile(configPath, []byte(baseConfig+config), 0600); err != nil {
@@ -414,33 +429,39 @@ func (wm *WiFiManager) connectWPA(ssid, password string) error {
 	return nil
 }
 
-func (wm *WiFiManager) requestDHCP() {
+func (wm *Manager) requestDHCP() {
+	RequestDHCP(wm.iface)
+}
+
+// RequestDHCP asks for an address on iface with the first DHCP client
+// installed
+func RequestDHCP(iface string) {
 	// Try dhcpcd first
 	if _, err := exec.LookPath("dhcpcd"); err == nil {
-		exec.Command("dhcpcd", "-n", wm.iface).Run()
+		exec.Command("dhcpcd", "-n", iface).Run()
 		return
 	}
 
 	// Try dhclient
 	if _, err := exec.LookPath("dhclient"); err == nil {
-		exec.Command("dhclient", wm.iface).Run()
+		exec.Command("dhclient", iface).Run()
 		return
 	}
 
 	// Try udhcpc (busybox)
 	if _, err := exec.LookPath("udhcpc"); err == nil {
-		exec.Command("udhcpc", "-i", wm.iface, "-n", "-q").Run()
+		exec.Command("udhcpc", "-i", iface, "-n", "-q").Run()
 		return
 	}
 
 	// Try raven-dhcp (built-in)
 	if _, err := exec.LookPath("raven-dhcp"); err == nil {
-		exec.Command("raven-dhcp", "-i", wm.iface).Run()
+		exec.Command("raven-dhcp", "-i", iface).Run()
 	}
 }
 
 // Disconnect disconnects from current network
-func (wm *WiFiManager) Disconnect() error {
+func (wm *Manager) Disconnect() error {
 	switch wm.backend {
 	case "iwd":
 		cmd := exec.Command("iwctl", "station", wm.iface, "disconnect")
@@ -455,7 +476,7 @@ func (wm *WiFiManager) Disconnect() error {
 }
 
 // GetStatus returns current connection status
-func (wm *WiFiManager) GetStatus() (ConnectionStatus, error) {
+func (wm *Manager) GetStatus() (ConnectionStatus, error) {
 	status := ConnectionStatus{Interface: wm.iface}
 
 	// Check if interface is connected using iw
@@ -491,7 +512,7 @@ func (wm *WiFiManager) GetStatus() (ConnectionStatus, error) {
 }
 
 // GetSavedNetworks returns list of saved network SSIDs
-func (wm *WiFiManager) GetSavedNetworks() ([]string, error) {
+func (wm *Manager) GetSavedNetworks() ([]string, error) {
 	var networks []string
 
 	switch wm.backend {
@@ -517,7 +538,7 @@ func (wm *WiFiManager) GetSavedNetworks() ([]string, error) {
 
 	case "wpa":
 		// Parse wpa_supplicant.conf
-		data, err := os.ReadFile("/etc/wpa_supplicant/wpa_supplicant.conf")
+		data, err := os.ReadFile(wpaConfigPath)
 		if err == nil {
 			re := regexp.MustCompile(`ssid="([^"]+)"`)
 			matches := re.FindAllStringSubmatch(string(data), -1)
@@ -533,7 +554,7 @@ func (wm *WiFiManager) GetSavedNetworks() ([]string, error) {
 }
 
 // IsKnownNetwork checks if a network is already saved
-func (wm *WiFiManager) IsKnownNetwork(ssid string) bool {
+func (wm *Manager) IsKnownNetwork(ssid string) bool {
 	saved, _ := wm.GetSavedNetworks()
 	for _, s := range saved {
 		if s == ssid {
@@ -544,7 +565,7 @@ func (wm *WiFiManager) IsKnownNetwork(ssid string) bool {
 }
 
 // ForgetNetwork removes a saved network
-func (wm *WiFiManager) ForgetNetwork(ssid string) error {
+func (wm *Manager) ForgetNetwork(ssid string) error {
 	switch wm.backend {
 	case "iwd":
 		// Remove iwd config files
@@ -555,7 +576,7 @@ func (wm *WiFiManager) ForgetNetwork(ssid string) error {
 
 	case "wpa":
 		// Remove from wpa_supplicant.conf
-		data, err := os.ReadFile("/etc/wpa_supplicant/wpa_supplicant.conf")
+		data, err := os.ReadFile(wpaConfigPath)
 		if err != nil {
 			return err
 		}
@@ -565,12 +586,83 @@ func (wm *WiFiManager) ForgetNetwork(ssid string) error {
 		re := regexp.MustCompile(`(?s)network=\{[^}]*ssid="` + regexp.QuoteMeta(ssid) + `"[^}]*\}`)
 		content = re.ReplaceAllString(content, "")
 
-		return os.WriteFile("/etc/wpa_supplicant/wpa_supplicant.conf", []byte(content), 0600)
+		return os.WriteFile(wpaConfigPath, []byte(content), 0600)
 	}
 
 	return nil
 }
 
+// SupportsPriority reports whether saved networks can be given a
+// priority. Only wpa_supplicant has one; iwd picks known networks by
+// signal and how recently they were used.
+func (wm *Manager) SupportsPriority() bool {
+	return wm.backend == "wpa"
+}
+
+// wpaNetworkBlock matches a network={...} block of wpa_supplicant.conf
+var wpaNetworkBlock = regexp.MustCompile(`(?s)network=\{[^}]*\}`)
+
+// wpaPriority matches the priority line of a network block
+var wpaPriority = regexp.MustCompile(`(?m)^[ \t]*priority=(-?\d+)[ \t]*\n?`)
+
+// GetPriorities returns the priority of each saved network that has one.
+// Networks without a priority are at 0.
+func (wm *Manager) GetPriorities() map[string]int {
+	priorities := make(map[string]int)
+	if wm.backend != "wpa" {
+		return priorities
+	}
+	data, err := os.ReadFile(wpaConfigPath)
+	if err != nil {
+		return priorities
+	}
+	ssidRe := regexp.MustCompile(`ssid="([^"]+)"`)
+	for _, block := range wpaNetworkBlock.FindAllString(string(data), -1) {
+		ssid := ssidRe.FindStringSubmatch(block)
+		prio := wpaPriority.FindStringSubmatch(block)
+		if ssid == nil || prio == nil {
+			continue
+		}
+		if n, err := strconv.Atoi(prio[1]); err == nil {
+			priorities[ssid[1]] = n
+		}
+	}
+	return priorities
+}
+
+// SetPriority sets the priority of a saved network. wpa_supplicant tries
+// networks with a higher priority first.
+func (wm *Manager) SetPriority(ssid string, priority int) error {
+	if !wm.SupportsPriority() {
+		return fmt.Errorf("the %s backend has no network priorities", wm.backend)
+	}
+
+	data, err := os.ReadFile(wpaConfigPath)
+	if err != nil {
+		return err
+	}
+
+	found := false
+	ssidLine := `ssid="` + ssid + `"`
+	content := wpaNetworkBlock.ReplaceAllStringFunc(string(data), func(block string) string {
+		if !strings.Contains(block, ssidLine) {
+			return block
+		}
+		found = true
+		block = wpaPriority.ReplaceAllString(block, "")
+		return strings.TrimSuffix(block, "}") + fmt.Sprintf("\tpriority=%d\n}", priority)
+	})
+	if !found {
+		return fmt.Errorf("%s is not a saved network", ssid)
+	}
+
+	if err := os.WriteFile(wpaConfigPath, []byte(content), 0600); err != nil {
+		return err
+	}
+	exec.Command("wpa_cli", "-i", wm.iface, "reconfigure").Run()
+	return nil
+}
+
 // Helper functions
 
 func dbmToPercent(dbm int) int {
@@ -4,6 +4,7 @@ pub mod dev;
 pub mod info;
 pub mod install;
 pub mod list;
+pub mod owns;
 pub mod remove;
 pub mod repo;
 pub mod search;
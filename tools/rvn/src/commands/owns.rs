@@ -0,0 +1,28 @@
+//! File ownership query
+
+use anyhow::Result;
+
+use crate::database::Database;
+
+/// Prints the name of the package that installed `path`, so scripts and
+/// the desktop can use the output as is. Fails when no package owns it.
+pub async fn run(path: &str) -> Result<()> {
+    let db = Database::open_default()?;
+
+    // Files are recorded by absolute path; resolve relative arguments and
+    // fall back to the canonical path for symlinked directories
+    let absolute = std::env::current_dir()?.join(path);
+    let mut candidates = vec![absolute.to_string_lossy().to_string()];
+    if let Ok(canonical) = std::fs::canonicalize(&absolute) {
+        candidates.push(canonical.to_string_lossy().to_string());
+    }
+
+    for candidate in &candidates {
+        if let Some(name) = db.owner_of(candidate)? {
+            println!("{}", name);
+            return Ok(());
+        }
+    }
+
+    anyhow::bail!("No installed package owns {}", path)
+}
@@ -108,6 +108,21 @@ impl Database {
         }
     }
 
+    /// Name of the installed package that owns `path`, if any
+    pub fn owner_of(&self, path: &str) -> Result<Option<String>> {
+        let result = self.conn.query_row(
+            "SELECT p.name FROM files f JOIN packages p ON p.id = f.package_id WHERE f.path = ?",
+            params![path],
+            |row| row.get(0),
+        );
+
+        match result {
+            Ok(name) => Ok(Some(name)),
+            Err(rusqlite::Error::QueryReturnedNoRows) => Ok(None),
+            Err(e) => Err(e.into()),
+        }
+    }
+
     pub fn record_installation(
         &self,
         name: &str,
@@ -89,6 +89,12 @@ enum Commands {
         explicit: bool,
     },
 
+    /// Show which installed package owns a file
+    Owns {
+        /// Path of the file
+        path: String,
+    },
+
     /// Synchronize package database
     Sync {
         /// Force full refresh
@@ -308,6 +314,7 @@ async fn main() -> Result<()> {
         Commands::List { pattern, explicit } => {
             commands::list::run(pattern.as_deref(), explicit).await
         }
+        Commands::Owns { path } => commands::owns::run(&path).await,
         Commands::Sync { force } => commands::sync::run(force).await,
         Commands::Clean { all } => commands::clean::run(all).await,
         Commands::Repo(cmd) => match cmd {